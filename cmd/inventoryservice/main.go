@@ -2,43 +2,338 @@ package main
 
 import (
 	"context"
-	"log"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/amqpbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/configloader"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/debugserver"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/health"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/backorder"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/config"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/grpcserver"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/kafka"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/reservation"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/snapshot"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/natsbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/pubsubbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/snssqs"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	_ "github.com/lib/pq"
 )
 
+// readinessTimeout bounds how long dependency checks may take per probe.
+const readinessTimeout = 2 * time.Second
+
+// drainCommitGrace is how long shutdown waits beyond the shutdown timeout
+// for a consumer that abandoned a message at its drain deadline to commit
+// the messages handled before it.
+const drainCommitGrace = 5 * time.Second
+
 func main() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("No .env file found or error loading .env:", err)
+	logging.Setup("inventoryservice")
+
+	if err := godotenv.Load(); err != nil {
+		log.Warn().Msg("No .env file found, using environment variables")
 	}
 
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Failed to load Inventory Service configuration: %v", err)
+		log.Fatal().Err(err).Msg("Failed to load Inventory Service configuration")
+	}
+	if err := logging.SetLevel(cfg.LogLevel); err != nil {
+		log.Fatal().Err(err).Msg("Invalid log level")
+	}
+	if err := logging.SetFormat(cfg.LogFormat); err != nil {
+		log.Fatal().Err(err).Msg("Invalid log format")
 	}
 
-	log.Printf("Inventory Service Configuration loaded: %+v\n", cfg)
+	log.Info().
+		Str("broker", cfg.MessageBroker).
+		Strs("brokers", cfg.KafkaBrokers).
+		Str("topic", cfg.KafkaTopic).
+		Str("group_id", cfg.KafkaGroupID).
+		Str("events_topic", cfg.EventsTopic).
+		Str("mode", string(cfg.FlowMode)).
+		Str("environment", cfg.Environment).
+		Msg("Inventory Service configuration loaded")
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "inventoryservice", cfg.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error().Err(err).Msg("Failed to flush traces")
+		}
+	}()
+
+	// Readiness checks the broker and, when configured, the database.
+	probe := health.NewProbe()
+
+	// Track stock per warehouse when the inventory database is configured;
+	// without it every reservation succeeds.
+	var stockStore *stock.PostgresStore
+	if cfg.DatabaseURL != "" {
+		db, err := sql.Open("postgres", cfg.DatabaseURL)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error connecting to database")
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close database")
+			}
+		}()
+		pingCtx, cancelPing := context.WithTimeout(context.Background(), 5*time.Second)
+		err = db.PingContext(pingCtx)
+		cancelPing()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to ping database")
+		}
+		probe.AddCheck("database", health.DatabaseCheck(db))
+		stockStore = stock.NewPostgresStore(db, cfg.Stock.Strategy)
+		log.Info().Str("strategy", string(cfg.Stock.Strategy)).Msg("Tracking stock per warehouse")
+	} else {
+		log.Warn().Msg("INVENTORY_DATABASE_URL is not set; stock is not tracked and every reservation succeeds")
+	}
 
-	// Initialize Kafka Consumer
-	orderPlacedConsumer := kafka.NewConsumer(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.KafkaGroupID)
+	// Initialize the producer of reservation outcomes and the consumer of
+	// reservation requests on the configured broker; subscribe creates
+	// consumers of other topics in the service's group. Consumer lag is only
+	// monitored on Kafka.
+	var (
+		eventProducer       broker.EventPublisher
+		orderPlacedConsumer broker.EventSubscriber
+		subscribe           func(topic string) (broker.EventSubscriber, error)
+		lagMonitor          *kafka.LagMonitor
+	)
+	switch cfg.MessageBroker {
+	case broker.NATS:
+		natsClient, err := natsbroker.Connect(cfg.NATS, "inventoryservice")
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to connect to NATS")
+		}
+		defer func() {
+			if err := natsClient.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close NATS connection")
+			}
+		}()
+		if eventProducer, err = natsClient.Publisher(context.Background(), cfg.EventsTopic); err != nil {
+			log.Fatal().Err(err).Msg("Failed to create NATS publisher")
+		}
+		subscribe = func(topic string) (broker.EventSubscriber, error) {
+			return natsClient.Subscriber(context.Background(), topic, cfg.KafkaGroupID)
+		}
+		if orderPlacedConsumer, err = subscribe(cfg.KafkaTopic); err != nil {
+			log.Fatal().Err(err).Msg("Failed to create NATS subscriber")
+		}
+		probe.AddCheck(cfg.MessageBroker, natsClient.Check)
+	case broker.RabbitMQ:
+		rabbitClient, err := amqpbroker.Connect(cfg.RabbitMQ, "inventoryservice")
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to connect to RabbitMQ")
+		}
+		defer func() {
+			if err := rabbitClient.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close RabbitMQ connection")
+			}
+		}()
+		if eventProducer, err = rabbitClient.Publisher(cfg.EventsTopic); err != nil {
+			log.Fatal().Err(err).Msg("Failed to create RabbitMQ publisher")
+		}
+		subscribe = func(topic string) (broker.EventSubscriber, error) {
+			return rabbitClient.Subscriber(topic, cfg.KafkaGroupID)
+		}
+		if orderPlacedConsumer, err = subscribe(cfg.KafkaTopic); err != nil {
+			log.Fatal().Err(err).Msg("Failed to create RabbitMQ subscriber")
+		}
+		probe.AddCheck(cfg.MessageBroker, rabbitClient.Check)
+	case broker.SNSSQS:
+		awsClient, err := snssqs.Connect(context.Background(), cfg.SNSSQS)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to set up SNS/SQS clients")
+		}
+		if eventProducer, err = awsClient.Publisher(context.Background(), cfg.EventsTopic); err != nil {
+			log.Fatal().Err(err).Msg("Failed to create SNS publisher")
+		}
+		subscribe = func(topic string) (broker.EventSubscriber, error) {
+			return awsClient.Subscriber(context.Background(), topic, cfg.KafkaGroupID)
+		}
+		if orderPlacedConsumer, err = subscribe(cfg.KafkaTopic); err != nil {
+			log.Fatal().Err(err).Msg("Failed to create SQS subscriber")
+		}
+		probe.AddCheck(cfg.MessageBroker, awsClient.Check)
+	case broker.PubSub:
+		pubsubClient, err := pubsubbroker.Connect(context.Background(), cfg.PubSub)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to set up Pub/Sub client")
+		}
+		defer func() {
+			if err := pubsubClient.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close Pub/Sub client")
+			}
+		}()
+		if eventProducer, err = pubsubClient.Publisher(context.Background(), cfg.EventsTopic); err != nil {
+			log.Fatal().Err(err).Msg("Failed to create Pub/Sub publisher")
+		}
+		subscribe = func(topic string) (broker.EventSubscriber, error) {
+			return pubsubClient.Subscriber(context.Background(), topic, cfg.KafkaGroupID)
+		}
+		if orderPlacedConsumer, err = subscribe(cfg.KafkaTopic); err != nil {
+			log.Fatal().Err(err).Msg("Failed to create Pub/Sub subscriber")
+		}
+		probe.AddCheck(cfg.MessageBroker, pubsubClient.Check)
+	default:
+		kafkaAuth, err := kafkaauth.New(cfg.KafkaAuth)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to configure Kafka authentication")
+		}
+		eventProducer = kafka.NewProducer(cfg.KafkaBrokers, cfg.EventsTopic, kafka.WithAuth(kafkaAuth), kafka.WithRequiredAcks(cfg.KafkaRequiredAcks))
+		subscribe = func(topic string) (broker.EventSubscriber, error) {
+			return kafka.NewConsumer(cfg.KafkaBrokers, topic, cfg.KafkaGroupID,
+				kafka.WithAuth(kafkaAuth), kafka.WithBatching(cfg.ConsumerBatchSize, cfg.ConsumerBatchLinger),
+				kafka.WithDrainTimeout(cfg.ShutdownTimeout), kafka.WithConsumeRetry(cfg.ConsumeRetry)), nil
+		}
+		orderPlacedConsumer, _ = subscribe(cfg.KafkaTopic)
+		lagMonitor = kafka.NewLagMonitor(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.KafkaGroupID, kafka.WithAuth(kafkaAuth))
+		probe.AddCheck(broker.Kafka, health.KafkaCheck(cfg.KafkaBrokers, 2*time.Second))
+	}
+	defer func() {
+		if err := eventProducer.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close event producer")
+		}
+	}()
 	defer func() {
 		if err := orderPlacedConsumer.Close(); err != nil {
-			log.Printf("Failed to close Kafka consumer: %v", err)
+			log.Error().Err(err).Msg("Failed to close event consumer")
 		}
 	}()
 
+	// Profiling (internal-only)
+	if cfg.PprofEnabled {
+		debugServer := debugserver.New(cfg.PprofAddr)
+		go func() {
+			log.Info().Str("addr", cfg.PprofAddr).Msg("pprof debug server listening")
+			if err := debugServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error().Err(err).Msg("pprof debug server failed")
+			}
+		}()
+		defer debugServer.Close()
+	}
+
 	// Context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel() // Ensure context is cancelled on main exit
 
-	// Start consuming in a goroutine
-	go orderPlacedConsumer.StartConsuming(ctx)
+	// Configuration reload (config file changes or SIGHUP)
+	go configloader.Watch(ctx, os.Getenv(configloader.FileEnv), configWatchInterval, configReloader(cfg))
+
+	// Expose Prometheus metrics, the liveness and readiness probes and, on
+	// Kafka, consumer lag
+	metricsMux := http.NewServeMux()
+	if lagMonitor != nil {
+		go lagMonitor.Run(ctx, cfg.LagPollInterval)
+		metricsMux.Handle("/lag", lagMonitor)
+	}
+	metricsMux.Handle("/metrics", promhttp.Handler())
+	metricsMux.Handle("/healthz", health.LivenessHandler())
+	metricsMux.Handle("/readyz", probe.ReadinessHandler(readinessTimeout))
+	metricsMux.Handle("/admin/log-level", logging.LevelHandler(cfg.AdminToken))
+	var reservationOpts []reservation.Option
+	if stockStore != nil {
+		stock.NewHandler(stockStore, cfg.AdminToken).Register(metricsMux)
+		reservationOpts = append(reservationOpts, reservation.WithReserver(stockStore))
+	}
+	metricsServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.MetricsPort),
+		Handler: metricsMux,
+	}
+	go func() {
+		log.Info().Int("port", cfg.MetricsPort).Msg("Metrics server listening")
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Msg("Metrics server failed")
+		}
+	}()
+	defer func() {
+		if err := metricsServer.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close metrics server")
+		}
+	}()
+
+	// Serve the gRPC query API and health service from the stock store
+	var grpcServer *grpc.Server
+	grpcHealth := grpchealth.NewServer()
+	if stockStore != nil {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+		if err != nil {
+			log.Fatal().Err(err).Int("port", cfg.GRPCPort).Msg("Failed to listen for gRPC")
+		}
+		grpcServer = grpc.NewServer()
+		grpcserver.NewServer(stockStore).Register(grpcServer)
+		healthpb.RegisterHealthServer(grpcServer, grpcHealth)
+		go func() {
+			log.Info().Int("port", cfg.GRPCPort).Msg("gRPC server listening")
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Error().Err(err).Msg("gRPC server failed")
+			}
+		}()
+		defer grpcServer.Stop()
+	}
+
+	// Allocate arriving stock to backorders and snapshot stock levels
+	if stockStore != nil {
+		go backorder.NewAllocator(stockStore, eventProducer).Run(ctx, cfg.Stock.BackorderInterval)
+		go snapshot.NewSnapshotter(stockStore, cfg.Stock.SnapshotInterval, cfg.Stock.SnapshotRetention).Run(ctx)
+	}
+
+	// Start consuming in goroutines
+	var consumers sync.WaitGroup
+	consumers.Add(1)
+	go func() {
+		defer consumers.Done()
+		orderPlacedConsumer.Consume(ctx, reservation.Handler(eventProducer, reservationOpts...))
+	}()
+	// Release the stock of orders that failed after it was reserved
+	if stockStore != nil {
+		releaseConsumer, err := subscribe(events.TopicInventoryReleases)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create inventory release consumer")
+		}
+		defer func() {
+			if err := releaseConsumer.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close inventory release consumer")
+			}
+		}()
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			releaseConsumer.Consume(ctx, reservation.ReleaseHandler(stockStore))
+		}()
+	}
+	consumerDone := make(chan struct{})
+	go func() {
+		consumers.Wait()
+		close(consumerDone)
+	}()
 
 	// Listen for OS signals for graceful shutdown
 	quit := make(chan os.Signal, 1)
@@ -46,5 +341,37 @@ func main() {
 
 	// Block until a signal is received
 	<-quit
-	log.Println("Inventory Service: Shutting down...")
+	log.Info().Msg("Inventory Service: Shutting down...")
+	probe.SetShuttingDown()
+	grpcHealth.Shutdown()
+	if grpcServer != nil {
+		stopGRPC(grpcServer, cfg.ShutdownTimeout)
+	}
+
+	// Stop fetching and let the consumer finish the message it holds and
+	// commit its pending batch. A Kafka consumer abandons the message at the
+	// shutdown timeout, leaving it uncommitted, so the reader is only closed
+	// once the consumer has returned. Deferred cleanup then closes the
+	// consumer (flushing offset commits) before the producer.
+	cancel()
+	select {
+	case <-consumerDone:
+	case <-time.After(cfg.ShutdownTimeout + drainCommitGrace):
+		log.Warn().Dur("timeout", cfg.ShutdownTimeout).Msg("Consumer did not drain before the shutdown timeout")
+	}
+}
+
+// stopGRPC lets in-flight gRPC calls finish, cutting them off after timeout.
+func stopGRPC(srv *grpc.Server, timeout time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		srv.GracefulStop()
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		log.Warn().Dur("timeout", timeout).Msg("gRPC calls did not finish before the shutdown timeout")
+		srv.Stop()
+	}
 }
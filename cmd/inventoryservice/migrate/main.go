@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/config"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/migrations"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/migration"
+)
+
+func main() {
+	// Load .env file for database URL
+	err := godotenv.Load()
+	if err != nil {
+		log.Println("No .env file found or error loading .env:", err)
+	}
+
+	cmd := migration.Command{
+		Name:   "migrate",
+		Source: migrations.FS,
+		DatabaseURL: func() (string, error) {
+			cfg, err := config.LoadConfig()
+			if err != nil {
+				return "", err
+			}
+			if cfg.DatabaseURL == "" {
+				return "", errors.New("INVENTORY_DATABASE_URL is not set")
+			}
+			return cfg.DatabaseURL, nil
+		},
+	}
+	os.Exit(cmd.Run(os.Args[1:]))
+}
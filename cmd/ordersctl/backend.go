@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/amqpbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/natsbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/api"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/config"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/export"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/kafka"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/service"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/pubsubbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/snssqs"
+	_ "github.com/lib/pq"
+)
+
+// backend performs order operations either through the order service's API
+// (client.HTTPClient) or, in break-glass mode, directly against its database.
+type backend interface {
+	GetOrder(ctx context.Context, id uuid.UUID) (*api.OrderResponse, error)
+	ListOrders(ctx context.Context, filter repository.OrderFilter) ([]api.OrderResponse, error)
+	UpdateOrderStatus(ctx context.Context, id uuid.UUID, status domain.OrderStatus) (*api.OrderResponse, error)
+	CancelOrder(ctx context.Context, id uuid.UUID) (*api.OrderResponse, error)
+	ResendOrderPlaced(ctx context.Context, id uuid.UUID) (*api.OrderResponse, error)
+	ExportOrders(ctx context.Context, filter repository.OrderFilter, format export.Format, w io.Writer) error
+}
+
+// dbBackend runs operations through the order service's domain layer against
+// its database and message broker, so status transitions are still enforced.
+// It is meant for when the API is unavailable.
+type dbBackend struct {
+	orders   service.OrderService
+	db       *sql.DB
+	producer broker.EventPublisher
+	// nats, rabbitmq and pubsub are the clients the producer uses when the
+	// broker is NATS, RabbitMQ or Pub/Sub.
+	nats     *natsbroker.Client
+	rabbitmq *amqpbroker.Client
+	pubsub   *pubsubbroker.Client
+}
+
+// newDBBackend connects using the order service's own configuration.
+func newDBBackend(ctx context.Context) (*dbBackend, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	b := &dbBackend{db: db}
+	switch cfg.MessageBroker {
+	case broker.NATS:
+		if b.nats, err = natsbroker.Connect(cfg.NATS, "ordersctl"); err != nil {
+			db.Close()
+			return nil, err
+		}
+		if b.producer, err = b.nats.Publisher(ctx, events.TopicOrdersPlaced); err != nil {
+			b.Close()
+			return nil, err
+		}
+	case broker.RabbitMQ:
+		if b.rabbitmq, err = amqpbroker.Connect(cfg.RabbitMQ, "ordersctl"); err != nil {
+			db.Close()
+			return nil, err
+		}
+		if b.producer, err = b.rabbitmq.Publisher(events.TopicOrdersPlaced); err != nil {
+			b.Close()
+			return nil, err
+		}
+	case broker.SNSSQS:
+		client, err := snssqs.Connect(ctx, cfg.SNSSQS)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		if b.producer, err = client.Publisher(ctx, events.TopicOrdersPlaced); err != nil {
+			db.Close()
+			return nil, err
+		}
+	case broker.PubSub:
+		if b.pubsub, err = pubsubbroker.Connect(ctx, cfg.PubSub); err != nil {
+			db.Close()
+			return nil, err
+		}
+		if b.producer, err = b.pubsub.Publisher(ctx, events.TopicOrdersPlaced); err != nil {
+			b.Close()
+			return nil, err
+		}
+	default:
+		auth, err := kafkaauth.New(cfg.KafkaAuth)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to configure Kafka authentication: %w", err)
+		}
+		b.producer = kafka.NewProducer(cfg.KafkaBrokers, events.TopicOrdersPlaced, kafka.WithAuth(auth), kafka.WithRequiredAcks(cfg.KafkaRequiredAcks), kafka.WithRetry(cfg.KafkaPublishRetry))
+	}
+
+	repo := repository.NewPostgresOrderRepository(db, repository.WithSlowQueryThreshold(cfg.SlowQueryThreshold))
+	b.orders = service.NewOrderService(repo, b.producer)
+	return b, nil
+}
+
+func (b *dbBackend) GetOrder(ctx context.Context, id uuid.UUID) (*api.OrderResponse, error) {
+	return orderResponse(b.orders.GetOrderByID(ctx, id))
+}
+
+func (b *dbBackend) ListOrders(ctx context.Context, filter repository.OrderFilter) ([]api.OrderResponse, error) {
+	orders, err := b.orders.ListOrders(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]api.OrderResponse, len(orders))
+	for i, order := range orders {
+		resp[i] = api.NewOrderResponse(order)
+	}
+	return resp, nil
+}
+
+func (b *dbBackend) UpdateOrderStatus(ctx context.Context, id uuid.UUID, status domain.OrderStatus) (*api.OrderResponse, error) {
+	return orderResponse(b.orders.UpdateOrderStatus(ctx, id, status))
+}
+
+func (b *dbBackend) CancelOrder(ctx context.Context, id uuid.UUID) (*api.OrderResponse, error) {
+	return orderResponse(b.orders.UpdateOrderStatus(ctx, id, domain.OrderStatusCancelled))
+}
+
+func (b *dbBackend) ResendOrderPlaced(ctx context.Context, id uuid.UUID) (*api.OrderResponse, error) {
+	return orderResponse(b.orders.ResendOrderPlaced(ctx, id))
+}
+
+func (b *dbBackend) ExportOrders(ctx context.Context, filter repository.OrderFilter, format export.Format, w io.Writer) error {
+	ew, err := export.NewWriter(w, format)
+	if err != nil {
+		return err
+	}
+	if err := b.orders.StreamOrders(ctx, filter, ew.Write); err != nil {
+		return err
+	}
+	return ew.Flush()
+}
+
+// Close flushes the producer and closes the database.
+func (b *dbBackend) Close() error {
+	var producerErr error
+	if b.producer != nil {
+		producerErr = b.producer.Close()
+	}
+	if b.nats != nil {
+		producerErr = errors.Join(producerErr, b.nats.Close())
+	}
+	if b.rabbitmq != nil {
+		producerErr = errors.Join(producerErr, b.rabbitmq.Close())
+	}
+	if b.pubsub != nil {
+		producerErr = errors.Join(producerErr, b.pubsub.Close())
+	}
+	if err := b.db.Close(); err != nil {
+		return err
+	}
+	return producerErr
+}
+
+func orderResponse(order *domain.Order, err error) (*api.OrderResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+	resp := api.NewOrderResponse(order)
+	return &resp, nil
+}
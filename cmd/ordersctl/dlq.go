@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/dlq"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/config"
+	"github.com/segmentio/kafka-go"
+)
+
+const dlqUsage = `Usage: ordersctl dlq replay [flags] DLQ_TOPIC
+
+Republishes messages from a dead-letter topic (such as orders.placed.dlq) to
+the topic they came from, adding the header replayed=true. The dead-letter
+topic is read up to its current end; replayed messages are not removed from
+it, so narrow repeated replays with the filters below.
+
+Flags:
+  -order ID          only replay messages for this order
+  -from TIME         only replay messages dead-lettered at or after TIME (RFC 3339)
+  -to TIME           only replay messages dead-lettered before TIME (RFC 3339)
+  -to-topic TOPIC    replay to TOPIC instead of the original topic
+  -limit N           stop after N messages
+  -dry-run           list the matching messages without republishing them`
+
+// dlqCommand runs the "dlq" command. It uses the order service configuration
+// for the brokers and authentication.
+func dlqCommand(ctx context.Context, args []string, stdout io.Writer) int {
+	if len(args) == 0 {
+		log.Println(dlqUsage)
+		return exitUsage
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "help", "-h", "--help":
+		fmt.Fprintln(stdout, dlqUsage)
+		return exitOK
+	case "replay":
+	default:
+		log.Printf("Unknown dlq command: %s\n\n%s", cmd, dlqUsage)
+		return exitUsage
+	}
+
+	flags := flag.NewFlagSet("dlq replay", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(flags.Output(), dlqUsage) }
+	orderID := flags.String("order", "", "order ID")
+	from := flags.String("from", "", "dead-lettered at or after (RFC 3339)")
+	to := flags.String("to", "", "dead-lettered before (RFC 3339)")
+	toTopic := flags.String("to-topic", "", "destination topic")
+	limit := flags.Int("limit", 0, "maximum number of messages")
+	dryRun := flags.Bool("dry-run", false, "list without republishing")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		log.Printf("dlq replay requires DLQ_TOPIC\n\n%s", dlqUsage)
+		return exitUsage
+	}
+	if *limit < 0 {
+		log.Printf("Invalid limit %d\n\n%s", *limit, dlqUsage)
+		return exitUsage
+	}
+	dlqTopic := flags.Arg(0)
+
+	var filter dlq.Filter
+	if *orderID != "" {
+		id, err := uuid.Parse(*orderID)
+		if err != nil {
+			log.Printf("Invalid order ID %q\n\n%s", *orderID, dlqUsage)
+			return exitUsage
+		}
+		filter.OrderID = id
+	}
+	var err error
+	if filter.From, err = parseTime(*from); err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+	if filter.To, err = parseTime(*to); err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return exitError
+	}
+	auth, err := kafkaauth.New(cfg.KafkaAuth)
+	if err != nil {
+		log.Printf("Failed to configure Kafka authentication: %v", err)
+		return exitError
+	}
+	replayer := dlq.NewReplayer(cfg.KafkaBrokers, auth)
+	defer replayer.Close()
+
+	summary, err := replayer.Replay(ctx, dlqTopic, dlq.Options{
+		Filter:      filter,
+		TargetTopic: *toTopic,
+		Limit:       *limit,
+		DryRun:      *dryRun,
+		OnMessage: func(msg kafka.Message, target string) {
+			fmt.Fprintf(stdout, "%d/%d\t%s\t%s\t%s\n", msg.Partition, msg.Offset, msg.Key,
+				dlq.FailedAt(msg).UTC().Format(time.RFC3339), target)
+		},
+	})
+	verb := "Replayed"
+	if *dryRun {
+		verb = "Would replay"
+	}
+	log.Printf("%s %d of %d message(s) from %s", verb, summary.Replayed, summary.Scanned, dlqTopic)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,342 @@
+// Command ordersctl is an operator CLI for inspecting and repairing orders.
+// It talks to the order service's API, or directly to its database with -db.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/api"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/client"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/export"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+)
+
+// Exit codes, matching the migrate commands.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const usage = `Usage: ordersctl [flags] <command> [arguments]
+
+Commands:
+  get ORDER_ID                 show an order
+  list [filters]               list orders, newest first unless -sort is given (run 'list -h' for filters)
+  cancel ORDER_ID              cancel a pending or processing order
+  set-status ORDER_ID STATUS   move an order to STATUS (pending, processing, completed, cancelled, failed)
+  resend ORDER_ID              publish the order's orders.placed event again
+  export [filters]             stream matching orders, oldest first, as CSV or JSON Lines
+                               (run 'export -h' for filters and output options)
+  topics COMMAND [TOPIC...]    describe, create or alter the Kafka topics the services need
+                               (run 'topics help' for details)
+  dlq replay [flags] TOPIC     republish dead-lettered messages to their original topic
+                               (run 'dlq help' for filters)
+  backfill [filters]           regenerate and publish events for existing orders
+                               (run 'backfill -h' for the topic and filters)
+  replay [flags]               publish stored outbox messages or order events again,
+                               to a topic or one consumer group (run 'replay -h' for details)
+
+Flags:
+  -addr URL      order service base URL (default $ORDERSCTL_ADDR or http://localhost:8080)
+  -token TOKEN   admin token for cancel, set-status and resend (default $ADMIN_TOKEN)
+  -timeout D     request timeout (default 10s; export, dlq, backfill and replay are not limited)
+  -db            break-glass mode: bypass the API and use the database and Kafka
+                 settings from the order service configuration
+  -json          print JSON instead of a table`
+
+func main() {
+	// Load .env file for the admin token and, with -db, the service configuration
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Println("Error loading .env:", err)
+	}
+
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+func run(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("ordersctl", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(flags.Output(), usage) }
+	addr := flags.String("addr", envOr("ORDERSCTL_ADDR", "http://localhost:8080"), "order service base URL")
+	token := flags.String("token", os.Getenv("ADMIN_TOKEN"), "admin token")
+	timeout := flags.Duration("timeout", 10*time.Second, "request timeout")
+	useDB := flags.Bool("db", false, "use the database directly")
+	asJSON := flags.Bool("json", false, "print JSON")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return exitUsage
+	}
+	cmd, cmdArgs := flags.Arg(0), flags.Args()[1:]
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if cmd != "export" && cmd != "dlq" && cmd != "backfill" && cmd != "replay" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	if cmd == "topics" {
+		return topics(ctx, cmdArgs, stdout)
+	}
+	if cmd == "dlq" {
+		return dlqCommand(ctx, cmdArgs, stdout)
+	}
+	if cmd == "backfill" {
+		return backfillCommand(ctx, cmdArgs, stdout)
+	}
+	if cmd == "replay" {
+		return replayCommand(ctx, cmdArgs, stdout)
+	}
+
+	var b backend
+	if *useDB {
+		log.Println("Break-glass mode: bypassing the order service API")
+		dbb, err := newDBBackend(ctx)
+		if err != nil {
+			log.Println(err)
+			return exitError
+		}
+		defer dbb.Close()
+		b = dbb
+	} else {
+		b = client.NewHTTPClient(*addr, *token, *timeout)
+	}
+
+	out := printer{w: stdout, json: *asJSON}
+	switch cmd {
+	case "get":
+		return withOrderID(cmd, cmdArgs, 1, func(id uuid.UUID) (*api.OrderResponse, error) {
+			return b.GetOrder(ctx, id)
+		}, out)
+	case "list":
+		flags := flag.NewFlagSet("list", flag.ContinueOnError)
+		filter := addFilterFlags(flags)
+		flags.IntVar(&filter.Limit, "limit", 50, "maximum number of orders")
+		flags.IntVar(&filter.Offset, "offset", 0, "number of orders to skip")
+		flags.Func("sort", "sort keys such as status,created_at:desc (sortable: created_at, updated_at, total_price, status)", func(v string) (err error) {
+			filter.Sort, err = repository.ParseSort(v)
+			return err
+		})
+		if code := parseFilterFlags(flags, cmdArgs, filter); code >= 0 {
+			return code
+		}
+		orders, err := b.ListOrders(ctx, *filter.OrderFilter)
+		if err != nil {
+			return fail(err)
+		}
+		out.orders(orders)
+		return exitOK
+	case "cancel":
+		return withOrderID(cmd, cmdArgs, 1, func(id uuid.UUID) (*api.OrderResponse, error) {
+			return b.CancelOrder(ctx, id)
+		}, out)
+	case "set-status":
+		if len(cmdArgs) != 2 {
+			log.Printf("set-status requires ORDER_ID and STATUS\n\n%s", usage)
+			return exitUsage
+		}
+		status, ok := domain.ParseOrderStatus(cmdArgs[1])
+		if !ok {
+			log.Printf("Unknown status %q\n\n%s", cmdArgs[1], usage)
+			return exitUsage
+		}
+		return withOrderID(cmd, cmdArgs, 2, func(id uuid.UUID) (*api.OrderResponse, error) {
+			return b.UpdateOrderStatus(ctx, id, status)
+		}, out)
+	case "resend":
+		return withOrderID(cmd, cmdArgs, 1, func(id uuid.UUID) (*api.OrderResponse, error) {
+			return b.ResendOrderPlaced(ctx, id)
+		}, out)
+	case "export":
+		return exportOrders(ctx, b, cmdArgs, stdout)
+	default:
+		log.Printf("Unknown command: %s\n\n%s", cmd, usage)
+		return exitUsage
+	}
+}
+
+// withOrderID parses the ORDER_ID argument, runs fn and prints the order it
+// returns.
+func withOrderID(cmd string, args []string, nargs int, fn func(uuid.UUID) (*api.OrderResponse, error), out printer) int {
+	if len(args) != nargs {
+		log.Printf("%s requires ORDER_ID\n\n%s", cmd, usage)
+		return exitUsage
+	}
+	id, err := uuid.Parse(args[0])
+	if err != nil {
+		log.Printf("Invalid order ID %q", args[0])
+		return exitUsage
+	}
+	order, err := fn(id)
+	if err != nil {
+		return fail(err)
+	}
+	out.orders([]api.OrderResponse{*order})
+	return exitOK
+}
+
+// filterFlags holds the raw values of the order filter flags shared by list
+// and export.
+type filterFlags struct {
+	*repository.OrderFilter
+	status, customer, from, to *string
+}
+
+// addFilterFlags registers the order filter flags on flags.
+func addFilterFlags(flags *flag.FlagSet) filterFlags {
+	return filterFlags{
+		OrderFilter: &repository.OrderFilter{},
+		status:      flags.String("status", "", "only orders with this status"),
+		customer:    flags.String("customer", "", "only orders of this customer ID"),
+		from:        flags.String("from", "", "only orders created at or after this RFC 3339 time"),
+		to:          flags.String("to", "", "only orders created before this RFC 3339 time"),
+	}
+}
+
+// parseFilterFlags parses args and fills in filter. It returns the exit code
+// to stop with, or a negative value when parsing succeeded.
+func parseFilterFlags(flags *flag.FlagSet, args []string, filter filterFlags) int {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if *filter.status != "" {
+		s, ok := domain.ParseOrderStatus(*filter.status)
+		if !ok {
+			log.Printf("Unknown status %q", *filter.status)
+			return exitUsage
+		}
+		filter.Status = s
+	}
+	if *filter.customer != "" {
+		id, err := uuid.Parse(*filter.customer)
+		if err != nil {
+			log.Printf("Invalid customer ID %q", *filter.customer)
+			return exitUsage
+		}
+		filter.CustomerID = id
+	}
+	for _, t := range []struct {
+		value string
+		dst   *time.Time
+	}{{*filter.from, &filter.CreatedFrom}, {*filter.to, &filter.CreatedTo}} {
+		parsed, err := parseTime(t.value)
+		if err != nil {
+			log.Println(err)
+			return exitUsage
+		}
+		*t.dst = parsed
+	}
+	return -1
+}
+
+// parseTime parses an RFC 3339 time flag. An empty value is the zero time.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339, e.g. 2024-01-31T00:00:00Z", value)
+	}
+	return t, nil
+}
+
+// exportOrders runs the export command, writing to the -o file or stdout.
+func exportOrders(ctx context.Context, b backend, args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	filter := addFilterFlags(flags)
+	formatFlag := flags.String("format", "csv", "output format: csv or jsonl")
+	output := flags.String("o", "", "write to this file instead of stdout")
+	if code := parseFilterFlags(flags, args, filter); code >= 0 {
+		return code
+	}
+	format, ok := export.ParseFormat(*formatFlag)
+	if !ok {
+		log.Printf("Unknown format %q: use csv or jsonl", *formatFlag)
+		return exitUsage
+	}
+
+	w := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fail(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := b.ExportOrders(ctx, *filter.OrderFilter, format, w); err != nil {
+		return fail(err)
+	}
+	if *output != "" {
+		log.Printf("Exported orders to %s", *output)
+	}
+	return exitOK
+}
+
+// fail logs err and returns the error exit code.
+func fail(err error) int {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		log.Println("Unauthorized: pass the admin token with -token or ADMIN_TOKEN")
+		return exitError
+	}
+	log.Println(err)
+	return exitError
+}
+
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+// orders prints orders as a table, or as JSON (one object per order).
+func (p printer) orders(orders []api.OrderResponse) {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		for _, order := range orders {
+			_ = enc.Encode(order)
+		}
+		return
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCUSTOMER\tSTATUS\tITEMS\tTOTAL\tCREATED")
+	for _, o := range orders {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.2f\t%s\n", o.ID, o.CustomerID, o.Status, len(o.Items), o.TotalPrice, o.CreatedAt.Format(time.RFC3339))
+	}
+	tw.Flush()
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
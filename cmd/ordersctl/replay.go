@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/config"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/replay"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/segmentio/kafka-go"
+)
+
+const replayUsage = `Usage: ordersctl replay [flags]
+
+Publishes stored events again, oldest first, with the header replayed=true.
+Use it to rebuild a read model or to seed a new service from history. It reads
+the database and Kafka settings from the order service configuration.
+
+Flags:
+  -source SOURCE   outbox (messages the outbox relay published) or events
+                   (the order event streams, as JSON envelopes) (default outbox)
+  -target TOPIC    publish to TOPIC; required for events, and outbox messages
+                   go back to their own topic without it
+  -group GROUP     address the replay to consumer group GROUP: consumers in
+                   other groups of the target topic skip the messages
+  -topic TOPIC     only outbox messages published to TOPIC
+  -type TYPE       only events of TYPE, e.g. OrderPlaced or order_status_changed
+  -order ID        only events of this order
+  -from TIME       only events stored at or after TIME (RFC 3339)
+  -to TIME         only events stored before TIME (RFC 3339)
+  -rate N          publish at most N messages per second (default unlimited)
+  -batch N         messages per Kafka write (default %d)
+  -dry-run         count the matching events without publishing`
+
+// replayCommand runs the "replay" command.
+func replayCommand(ctx context.Context, args []string, stdout io.Writer) int {
+	help := fmt.Sprintf(replayUsage, replay.DefaultBatchSize)
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(flags.Output(), help) }
+	sourceFlag := flags.String("source", string(replay.SourceOutbox), "outbox or events")
+	target := flags.String("target", "", "topic to publish to")
+	group := flags.String("group", "", "consumer group the replay is addressed to")
+	topic := flags.String("topic", "", "only outbox messages published to this topic")
+	eventType := flags.String("type", "", "only events of this type")
+	orderID := flags.String("order", "", "only events of this order ID")
+	from := flags.String("from", "", "only events stored at or after this RFC 3339 time")
+	to := flags.String("to", "", "only events stored before this RFC 3339 time")
+	perSecond := flags.Float64("rate", 0, "messages per second")
+	batch := flags.Int("batch", replay.DefaultBatchSize, "messages per Kafka write")
+	dryRun := flags.Bool("dry-run", false, "count without publishing")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	source, ok := replay.ParseSource(*sourceFlag)
+	if !ok || flags.NArg() > 0 || *batch < 1 || *perSecond < 0 {
+		log.Println(help)
+		return exitUsage
+	}
+	if source == replay.SourceEvents && *target == "" {
+		log.Println("Replaying order events requires -target")
+		return exitUsage
+	}
+	filter := repository.ReplayFilter{Topic: *topic, Type: *eventType}
+	if *orderID != "" {
+		id, err := uuid.Parse(*orderID)
+		if err != nil {
+			log.Printf("Invalid order ID %q", *orderID)
+			return exitUsage
+		}
+		filter.OrderID = id
+	}
+	for _, t := range []struct {
+		value string
+		dst   *time.Time
+	}{{*from, &filter.From}, {*to, &filter.To}} {
+		parsed, err := parseTime(t.value)
+		if err != nil {
+			log.Println(err)
+			return exitUsage
+		}
+		*t.dst = parsed
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return exitError
+	}
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		log.Printf("Failed to open database: %v", err)
+		return exitError
+	}
+	defer db.Close()
+	auth, err := kafkaauth.New(cfg.KafkaAuth)
+	if err != nil {
+		log.Printf("Failed to configure Kafka authentication: %v", err)
+		return exitError
+	}
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.KafkaBrokers...),
+		Balancer:     &kafka.LeastBytes{},
+		RequiredAcks: cfg.KafkaRequiredAcks,
+		BatchTimeout: 10 * time.Millisecond,
+		BatchSize:    *batch,
+		Transport:    auth.Transport(),
+	}
+	defer writer.Close()
+
+	// One correlation ID for the whole run ties every replayed message to it.
+	correlationID := logging.NewCorrelationID()
+	ctx = logging.WithCorrelationID(ctx, correlationID, nil)
+
+	summary, err := replay.New(repository.NewPostgresReplayRepository(db), writer, replay.Options{
+		Source:    source,
+		Filter:    filter,
+		Target:    *target,
+		Group:     *group,
+		Rate:      *perSecond,
+		BatchSize: *batch,
+		DryRun:    *dryRun,
+	}).Run(ctx)
+	if *dryRun {
+		fmt.Fprintf(stdout, "%d event(s) match; nothing published (dry run)\n", summary.Events)
+	} else {
+		fmt.Fprintf(stdout, "Replayed %d %s event(s) with correlation ID %s\n", summary.Published, source, correlationID)
+	}
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkatopics"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/config"
+)
+
+// exitDrift is returned by "topics describe" when a topic is missing or does
+// not match its spec, so provisioning checks can fail a pipeline.
+const exitDrift = 3
+
+const topicsUsage = `Usage: ordersctl topics <command> [flags] [TOPIC...]
+
+Manages the Kafka topics the services need, sized by KAFKA_TOPIC_PARTITIONS,
+KAFKA_TOPIC_REPLICATION_FACTOR and KAFKA_TOPIC_RETENTION (see APP_ENV profiles).
+Without TOPIC arguments every topic is selected.
+
+Commands:
+  describe   compare the topics on the cluster with their spec (exit 3 on drift)
+  create     create the selected topics that do not exist yet
+  alter      add partitions and update retention and cleanup policy of existing topics
+
+Flags for create and alter, overriding the configured spec:
+  -partitions N          number of partitions
+  -retention D           retention period such as 72h, or "forever"
+  -cleanup-policy P      delete, compact or compact,delete`
+
+// topics runs the "topics" command. It uses the order service configuration
+// for the brokers, authentication and topic sizing.
+func topics(ctx context.Context, args []string, stdout io.Writer) int {
+	if len(args) == 0 {
+		log.Println(topicsUsage)
+		return exitUsage
+	}
+	cmd, args := args[0], args[1:]
+	if cmd == "help" || cmd == "-h" || cmd == "--help" {
+		fmt.Fprintln(stdout, topicsUsage)
+		return exitOK
+	}
+
+	flags := flag.NewFlagSet("topics "+cmd, flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(flags.Output(), topicsUsage) }
+	partitions := flags.Int("partitions", 0, "number of partitions")
+	retention := flags.String("retention", "", "retention period or forever")
+	cleanupPolicy := flags.String("cleanup-policy", "", "cleanup policy")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return exitError
+	}
+	specs, err := selectSpecs(kafkatopics.Specs(cfg.KafkaTopics), flags.Args())
+	if err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+	if cmd != "describe" {
+		if err := overrideSpecs(specs, *partitions, *retention, *cleanupPolicy); err != nil {
+			log.Println(err)
+			return exitUsage
+		}
+	}
+
+	auth, err := kafkaauth.New(cfg.KafkaAuth)
+	if err != nil {
+		log.Printf("Failed to configure Kafka authentication: %v", err)
+		return exitError
+	}
+	admin := kafkatopics.NewAdmin(cfg.KafkaBrokers, auth)
+
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.Name
+	}
+	states, err := admin.Describe(ctx, names)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+
+	switch cmd {
+	case "describe":
+		return describeTopics(stdout, specs, states)
+	case "create":
+		var missing []kafkatopics.Spec
+		for i, spec := range specs {
+			if states[i].Exists {
+				log.Printf("Topic %s already exists, skipping", spec.Name)
+				continue
+			}
+			missing = append(missing, spec)
+		}
+		if len(missing) == 0 {
+			return exitOK
+		}
+		if err := admin.Create(ctx, missing); err != nil {
+			log.Println(err)
+			return exitError
+		}
+		for _, spec := range missing {
+			log.Printf("Created topic %s", spec.Name)
+		}
+		return exitOK
+	case "alter":
+		code := exitOK
+		for i, spec := range specs {
+			diffs := kafkatopics.Diff(spec, states[i])
+			if len(diffs) == 0 {
+				log.Printf("Topic %s is up to date", spec.Name)
+				continue
+			}
+			if err := admin.Alter(ctx, spec, states[i]); err != nil {
+				log.Println(err)
+				code = exitError
+				continue
+			}
+			log.Printf("Altered topic %s: %s", spec.Name, strings.Join(diffs, "; "))
+		}
+		return code
+	default:
+		log.Printf("Unknown topics command: %s\n\n%s", cmd, topicsUsage)
+		return exitUsage
+	}
+}
+
+// selectSpecs returns the specs named by names, or all of them.
+func selectSpecs(all []kafkatopics.Spec, names []string) ([]kafkatopics.Spec, error) {
+	if len(names) == 0 {
+		return all, nil
+	}
+	known := make([]string, len(all))
+	for i, spec := range all {
+		known[i] = spec.Name
+	}
+	var selected []kafkatopics.Spec
+	for _, name := range names {
+		found := false
+		for _, spec := range all {
+			if spec.Name == name {
+				selected = append(selected, spec)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown topic %q: expected one of %s", name, strings.Join(known, ", "))
+		}
+	}
+	return selected, nil
+}
+
+// overrideSpecs applies the -partitions, -retention and -cleanup-policy flags.
+func overrideSpecs(specs []kafkatopics.Spec, partitions int, retention, cleanupPolicy string) error {
+	var retentionValue time.Duration
+	switch retention {
+	case "":
+	case "forever":
+		retentionValue = -1
+	default:
+		d, err := time.ParseDuration(retention)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid retention %q: use a positive duration such as 72h, or forever", retention)
+		}
+		retentionValue = d
+	}
+	switch cleanupPolicy {
+	case "", kafkatopics.CleanupDelete, kafkatopics.CleanupCompact, kafkatopics.CleanupCompactDelete:
+	default:
+		return fmt.Errorf("invalid cleanup policy %q: use delete, compact or compact,delete", cleanupPolicy)
+	}
+	if partitions < 0 {
+		return fmt.Errorf("invalid number of partitions %d", partitions)
+	}
+
+	for i := range specs {
+		if partitions > 0 {
+			specs[i].Partitions = partitions
+		}
+		if retentionValue != 0 {
+			specs[i].Retention = retentionValue
+		}
+		if cleanupPolicy != "" {
+			specs[i].CleanupPolicy = cleanupPolicy
+		}
+	}
+	return nil
+}
+
+// describeTopics prints the actual state of each topic and how it differs
+// from its spec.
+func describeTopics(w io.Writer, specs []kafkatopics.Spec, states []kafkatopics.State) int {
+	code := exitOK
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tPARTITIONS\tREPLICATION\tRETENTION\tCLEANUP\tSTATUS")
+	for i, spec := range specs {
+		state := states[i]
+		status := "ok"
+		if diffs := kafkatopics.Diff(spec, state); len(diffs) > 0 {
+			status = strings.Join(diffs, "; ")
+			code = exitDrift
+		}
+		if !state.Exists {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\t%s\n", spec.Name, status)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\n", state.Name, state.Partitions, state.ReplicationFactor,
+			kafkatopics.FormatRetention(state.Retention), state.CleanupPolicy, status)
+	}
+	tw.Flush()
+	return code
+}
@@ -5,21 +5,45 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/kafka"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/buildinfo"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/configloader"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/debugserver"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/errorreporting"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/health"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/migration"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/address"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/api"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/backpressure"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/cache"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/catalog"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/config"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/customer"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/inventory"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/outbox"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/projection"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/quota"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/saga"
+	orderserver "github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/server"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/service"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/redisclient"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/jonamarkin/e-commerce-order-processing/migrations"
 	_ "github.com/lib/pq"
 
 	_ "github.com/jonamarkin/e-commerce-order-processing/docs"
@@ -27,6 +51,7 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // @title E-Commerce Order Processing Service API
@@ -45,10 +70,14 @@ import (
 // @BasePath /api/v1
 // @schemes http
 
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
+// @description Operator endpoints expect "Bearer <ADMIN_TOKEN>".
+
 func main() {
 
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339})
-	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	logging.Setup("orderservice")
 
 	// Load environment variables from .env file
 	if err := godotenv.Load(); err != nil {
@@ -61,6 +90,45 @@ func main() {
 	if err != nil {
 		log.Fatal().Err(err).Msg("Error loading configuration")
 	}
+	if err := logging.SetLevel(cfg.LogLevel); err != nil {
+		log.Fatal().Err(err).Msg("Invalid log level")
+	}
+	if err := logging.SetFormat(cfg.LogFormat); err != nil {
+		log.Fatal().Err(err).Msg("Invalid log format")
+	}
+	log.Info().Str("environment", cfg.Environment).Str("log_level", cfg.LogLevel).Msg("Configuration loaded")
+
+	// --- Tracing ---
+	shutdownTracing, err := tracing.Setup(context.Background(), "orderservice", cfg.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error().Err(err).Msg("Failed to flush traces")
+		}
+	}()
+	log.Info().Bool("enabled", cfg.Tracing.Enabled).Msg("Tracing configured")
+
+	// --- Error Reporting ---
+	flushErrorReports, err := errorreporting.Init("orderservice", cfg.ErrorReporting)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up error reporting")
+	}
+	defer flushErrorReports()
+	log.Info().Bool("enabled", errorreporting.Enabled()).Msg("Error reporting configured")
+
+	// --- Profiling (internal-only) ---
+	if cfg.PprofEnabled {
+		debugServer := debugserver.New(cfg.PprofAddr)
+		go func() {
+			log.Info().Str("addr", cfg.PprofAddr).Msg("pprof debug server listening")
+			if err := debugServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error().Err(err).Msg("pprof debug server failed")
+			}
+		}()
+		defer debugServer.Close()
+	}
 
 	// --- Database Connection ---
 	db, err := sql.Open("postgres", cfg.DatabaseURL)
@@ -72,6 +140,9 @@ func main() {
 			log.Error().Err(err).Msg("Failed to close database connection")
 		}
 	}()
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
 
 	// Ping database to verify connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -81,29 +152,355 @@ func main() {
 	}
 	log.Info().Msg("Successfully connected to the database!")
 
-	// --- Kafka Producer Initialization ---
-	const orderPlacedTopic = "orders.placed"
-	kafkaProducer := kafka.NewProducer(cfg.KafkaBrokers, orderPlacedTopic)
+	if cfg.MigrateOnStart {
+		version, err := migration.Up(migrations.FS, cfg.DatabaseURL)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to migrate database")
+		}
+		log.Info().Uint("version", version).Msg("Database schema is up to date")
+	}
+
+	// --- Circuit Breakers ---
+	// Open breakers fail calls to a broken dependency at once; the API
+	// answers them with 503 instead of letting every request time out.
+	var dbBreaker, brokerBreaker *circuitbreaker.Breaker
+	if cfg.CircuitBreaker.Enabled {
+		dbBreaker = newBreaker("database", cfg.CircuitBreaker)
+		brokerBreaker = newBreaker(cfg.MessageBroker, cfg.CircuitBreaker)
+	}
+
+	// --- Message Broker and Event Producer Initialization ---
+	msgs, err := newMessaging(cfg, brokerBreaker)
+	if err != nil {
+		log.Fatal().Err(err).Str("broker", cfg.MessageBroker).Msg("Failed to connect to the message broker")
+	}
 	defer func() {
-		if err := kafkaProducer.Close(); err != nil {
-			log.Error().Err(err).Msg("Failed to close Kafka producer")
+		if err := msgs.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close message broker connection")
 		}
 	}()
-	log.Info().Str("topic", orderPlacedTopic).Strs("brokers", cfg.KafkaBrokers).Msg("Kafka producer initialized")
+	const orderPlacedTopic = events.TopicOrdersPlaced
+	eventProducer, err := msgs.publisher(orderPlacedTopic)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create event producer")
+	}
+	defer func() {
+		if err := eventProducer.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close event producer")
+		}
+	}()
+	log.Info().Str("topic", orderPlacedTopic).Str("broker", cfg.MessageBroker).Msg("Event producer initialized")
+
+	// --- Product Catalog Client ---
+	var catalogClient catalog.Client
+	if cfg.CatalogServiceURL != "" {
+		catalogClient = catalog.NewHTTPClient(cfg.CatalogServiceURL, cfg.CatalogTimeout)
+		log.Info().Str("url", cfg.CatalogServiceURL).Msg("Using catalog service for product validation")
+	} else {
+		catalogClient = catalog.NewStubClient()
+		log.Warn().Msg("CATALOG_SERVICE_URL not set, using stub catalog that accepts all products")
+	}
 
 	// --- Initialize Repository, Service, and API Handler ---
-	orderRepo := repository.NewPostgresOrderRepository(db)
-	orderService := service.NewOrderService(orderRepo, kafkaProducer)
-	orderHandler := api.NewHandler(orderService)
+	// Events are stored in the outbox with their order and published by the relay.
+	var relay *outbox.Relay
+	var outboxBacklog backpressure.OutboxBacklog
+	if cfg.Outbox.Enabled && cfg.Outbox.Relay == outbox.RelayInternal {
+		var relayOpts []outbox.Option
+		if id := cfg.Outbox.KafkaTransactionalID; id != "" {
+			// Each batch is published in a Kafka transaction, so a relay
+			// crash doesn't publish events twice.
+			txProducer := msgs.transactionalProducer(id)
+			defer func() {
+				if err := txProducer.Close(); err != nil {
+					log.Error().Err(err).Msg("Failed to close transactional producer")
+				}
+			}()
+			relayOpts = append(relayOpts, outbox.WithTransactor(txProducer))
+			log.Info().Str("transactional_id", id).Msg("Outbox relay publishes in Kafka transactions")
+		}
+		relay = outbox.NewRelay(repository.NewPostgresOutboxRepository(db), map[string]broker.EventPublisher{
+			orderPlacedTopic: eventProducer,
+		}, cfg.Outbox, relayOpts...)
+		outboxBacklog = relay
+	}
+	// New orders are rejected while the outbox or the database falls behind.
+	backpressureMonitor := backpressure.NewMonitor(cfg.Backpressure, outboxBacklog)
+
+	repoOpts := []repository.Option{
+		repository.WithSlowQueryThreshold(cfg.SlowQueryThreshold),
+		repository.WithTransactions(cfg.Transactions),
+		repository.WithLatencyObserver(backpressureMonitor.ObserveDBLatency),
+	}
+	if cfg.Outbox.Enabled && cfg.Outbox.Relay == outbox.RelayDebezium {
+		// A Debezium connector publishes the outbox from the write-ahead log.
+		repoOpts = append(repoOpts, repository.WithCapturedOutbox())
+		log.Info().Msg("Outbox is published by Debezium; the relay is not started")
+	}
+	orderRepo := repository.NewPostgresOrderRepository(db, repoOpts...)
+	defer func() {
+		if err := orderRepo.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close prepared statements")
+		}
+	}()
+	var orders repository.OrderRepository = orderRepo
+	if cfg.EventSourcing.Enabled {
+		orders = repository.NewEventSourcedOrderRepository(orderRepo, cfg.EventSourcing)
+		log.Info().Int("snapshot_every", cfg.EventSourcing.SnapshotEvery).Msg("Orders are event-sourced")
+	}
+	if cfg.Projections.Enabled {
+		// Lists, exports and stats read the models kept by the projection worker.
+		orders = repository.NewReadModelOrderRepository(orders, orderRepo)
+	}
+	serviceOpts := []service.Option{service.WithCatalog(catalogClient), service.WithTimeouts(cfg.Timeouts)}
+	if cfg.EventSourcing.Enabled {
+		serviceOpts = append(serviceOpts, service.WithOrderHistory(repository.NewPostgresReplayRepository(db)))
+	}
+	switch cfg.CustomerValidator {
+	case "database":
+		serviceOpts = append(serviceOpts, service.WithCustomerValidator(repository.NewPostgresCustomerRepository(db)))
+	case "http":
+		serviceOpts = append(serviceOpts, service.WithCustomerValidator(customer.NewHTTPClient(cfg.CustomerServiceURL, cfg.CustomerTimeout)))
+	}
+	log.Info().Str("mode", cfg.CustomerValidator).Msg("Customer validation configured")
+
+	if cfg.AddressVerifier == "http" {
+		serviceOpts = append(serviceOpts, service.WithAddressVerifier(address.NewHTTPClient(cfg.AddressServiceURL, cfg.AddressTimeout)))
+		log.Info().Str("url", cfg.AddressServiceURL).Msg("Shipping addresses are verified")
+	}
+
+	if cfg.InventoryServiceAddr != "" {
+		inventoryClient, err := inventory.NewGRPCClient(cfg.InventoryServiceAddr, cfg.InventoryTimeout)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create inventory client")
+		}
+		defer func() {
+			if err := inventoryClient.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close inventory client")
+			}
+		}()
+		serviceOpts = append(serviceOpts, service.WithAvailabilityCheck(inventoryClient))
+		log.Info().Str("addr", cfg.InventoryServiceAddr).Msg("Orders are checked against inventory stock")
+	}
+
+	if cfg.OrderCache.Enabled {
+		serviceOpts = append(serviceOpts, service.WithOrderCache(cache.New(cfg.OrderCache)))
+		log.Info().Int("size", cfg.OrderCache.Size).Dur("ttl", cfg.OrderCache.TTL).Msg("Order cache enabled")
+	}
+
+	if cfg.OrderLocks.Enabled {
+		serviceOpts = append(serviceOpts, service.WithOrderLocks(repository.NewPostgresOrderLocker(db, cfg.OrderLocks.Timeout)))
+		log.Info().Dur("timeout", cfg.OrderLocks.Timeout).Msg("Order locks enabled")
+	}
+
+	if cfg.DuplicateOrders.Enabled {
+		serviceOpts = append(serviceOpts, service.WithDuplicateDetection(cfg.DuplicateOrders))
+		log.Info().Dur("window", cfg.DuplicateOrders.Window).Str("action", cfg.DuplicateOrders.Action).Msg("Duplicate order detection enabled")
+	}
+
+	if cfg.CustomerQuota.Enabled {
+		redisClient := redisclient.New(cfg.Redis)
+		defer func() {
+			if err := redisClient.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close Redis client")
+			}
+		}()
+		serviceOpts = append(serviceOpts, service.WithOrderQuota(quota.NewRedisLimiter(redisClient, cfg.CustomerQuota)))
+		log.Info().
+			Int("orders_per_minute", cfg.CustomerQuota.OrdersPerMinute).
+			Int("orders_per_day", cfg.CustomerQuota.OrdersPerDay).
+			Msg("Customer order quotas enabled")
+	}
+
+	if cfg.StatusEvents {
+		statusProducer, err := msgs.publisher(events.TopicOrderStatusChanged)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create order status producer")
+		}
+		defer func() {
+			if err := statusProducer.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close order status producer")
+			}
+		}()
+		serviceOpts = append(serviceOpts, service.WithStatusEvents(statusProducer))
+		log.Info().Str("topic", events.TopicOrderStatusChanged).Msg("Order status changes are published")
+	}
+
+	if relay != nil {
+		serviceOpts = append(serviceOpts, service.WithOutbox(relay.Notify))
+	} else if cfg.Outbox.Enabled {
+		serviceOpts = append(serviceOpts, service.WithOutbox(func() {}))
+	}
+	orderService := service.NewOrderService(repository.NewCircuitBreakerRepository(orders, dbBreaker), eventProducer, serviceOpts...)
+
+	// --- Configuration Reload (config file changes or SIGHUP) ---
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go configloader.Watch(watchCtx, os.Getenv(configloader.FileEnv), configWatchInterval, configReloader(cfg, orderRepo))
+	var handlerOpts []api.HandlerOption
+	if cfg.EventSourcing.Enabled {
+		handlerOpts = append(handlerOpts, api.WithOrderEvents())
+	}
+	orderHandler := api.NewHandler(orderService, handlerOpts...)
+
+	// --- Saga Consumers ---
+	consumerCtx, cancelConsumers := context.WithCancel(context.Background())
+	defer cancelConsumers()
+	var consumers sync.WaitGroup
+
+	if relay != nil {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			relay.Run(consumerCtx)
+		}()
+	}
+	if cfg.Projections.Enabled {
+		projector := projection.NewProjector(repository.NewPostgresProjectionRepository(db), cfg.Projections)
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			projector.Run(consumerCtx)
+		}()
+	}
+
+	inventoryConsumer, err := msgs.subscriber(events.TopicInventoryEvents, cfg.KafkaGroupID)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create inventory events consumer")
+	}
+	defer func() {
+		if err := inventoryConsumer.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close inventory events consumer")
+		}
+	}()
+	consumers.Add(1)
+	go func() {
+		defer consumers.Done()
+		inventoryConsumer.Consume(consumerCtx, saga.InventoryEventHandler(orderService))
+	}()
+
+	if cfg.PaymentEvents {
+		releaseProducer, err := msgs.publisher(events.TopicInventoryReleases)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create inventory release producer")
+		}
+		defer func() {
+			if err := releaseProducer.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close inventory release producer")
+			}
+		}()
+		paymentConsumer, err := msgs.subscriber(events.TopicPaymentEvents, cfg.KafkaGroupID)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create payment events consumer")
+		}
+		defer func() {
+			if err := paymentConsumer.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close payment events consumer")
+			}
+		}()
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			paymentConsumer.Consume(consumerCtx, saga.PaymentEventHandler(orderService, releaseProducer))
+		}()
+		log.Info().Str("topic", events.TopicPaymentEvents).Msg("Order status follows payment events")
+	}
+
+	if cfg.ShippingEvents {
+		shippingConsumer, err := msgs.subscriber(events.TopicShippingEvents, cfg.KafkaGroupID)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create shipping events consumer")
+		}
+		defer func() {
+			if err := shippingConsumer.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close shipping events consumer")
+			}
+		}()
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			shippingConsumer.Consume(consumerCtx, saga.ShippingEventHandler(orderService))
+		}()
+		log.Info().Str("topic", events.TopicShippingEvents).Msg("Orders complete on delivery events")
+	}
+
+	if cfg.FlowMode == events.FlowModeOrchestration {
+		commandProducer, err := msgs.publisher(events.TopicInventoryCommands)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create saga command producer")
+		}
+		defer func() {
+			if err := commandProducer.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close saga command producer")
+			}
+		}()
+		orchestrator := saga.NewOrchestrator(commandProducer)
+
+		orchestratorConsumer, err := msgs.subscriber(events.TopicOrdersPlaced, cfg.KafkaGroupID+"-orchestrator")
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create saga orchestrator consumer")
+		}
+		defer func() {
+			if err := orchestratorConsumer.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close saga orchestrator consumer")
+			}
+		}()
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			orchestratorConsumer.Consume(consumerCtx, orchestrator.HandleOrderPlaced)
+		}()
+	}
+	log.Info().Str("mode", string(cfg.FlowMode)).Msg("Saga flow configured")
+
+	// --- Health Probes ---
+	probe := health.NewProbe()
+	probe.AddCheck("database", health.DatabaseCheck(db))
+	probe.AddCheck(cfg.MessageBroker, msgs.check())
+	healthHandler := api.NewHealthHandler(probe)
 
 	// --- Gin Router Setup ---
 	router := gin.Default()
+	router.Use(otelgin.Middleware("orderservice"))
+	router.Use(api.CorrelationIDMiddleware())
+	router.Use(api.ErrorReportingMiddleware())
+	router.Use(api.MetricsMiddleware())
+	router.Use(api.CompressionMiddleware(cfg.Compression))
+	router.Use(api.LocalizationMiddleware())
+	router.Use(api.BodyLimitMiddleware(cfg.MaxBodyBytes))
 
-	v1 := router.Group("/api/v1")
+	// Every API route needs the database, so requests are shed while its
+	// breaker is open. Broker outages only fail the routes that publish.
+	v1 := router.Group("/api/v1", api.LoadSheddingMiddleware(cfg.LoadShedding, dbBreaker))
 	{
-		v1.POST("/orders", orderHandler.CreateOrder)
+		if cfg.Backpressure.Enabled {
+			v1.POST("/orders", api.BackpressureMiddleware(backpressureMonitor), orderHandler.CreateOrder)
+		} else {
+			v1.POST("/orders", orderHandler.CreateOrder)
+		}
+		v1.GET("/orders", orderHandler.ListOrders)
+		v1.GET("/orders/by-reference", orderHandler.GetOrderByExternalReference)
 		v1.GET("/orders/:id", orderHandler.GetOrderByID)
+		v1.GET("/orders/:id/items", orderHandler.GetOrderItems)
+		v1.GET("/orders/:id/events", orderHandler.GetOrderEvents)
 	}
+	// Operator actions on orders (require ADMIN_TOKEN)
+	adminV1 := v1.Group("", api.AdminAuthMiddleware(cfg.AdminToken))
+	{
+		adminV1.GET("/orders/export", orderHandler.ExportOrders)
+		adminV1.GET("/orders/stats", orderHandler.OrderStats)
+		adminV1.PUT("/orders/:id/status", orderHandler.UpdateOrderStatus)
+		adminV1.POST("/orders/:id/cancel", orderHandler.CancelOrder)
+		adminV1.POST("/orders/:id/resend-event", orderHandler.ResendOrderPlaced)
+	}
+
+	router.GET("/health", orderHandler.HealthCheck)
+	router.GET("/healthz", healthHandler.Liveness)
+	router.GET("/readyz", healthHandler.Readiness)
+	router.GET("/version", api.VersionHandler(buildinfo.Get(cfg.Features())))
+
+	// Admin endpoints (require ADMIN_TOKEN)
+	router.Any("/admin/log-level", gin.WrapH(logging.LevelHandler(cfg.AdminToken)))
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	// Prometheus metrics endpoint
@@ -115,9 +512,31 @@ func main() {
 		Handler: router,
 	}
 
+	if cfg.TLS.Enabled {
+		redirectServer, err := orderserver.ConfigureTLS(server, cfg.TLS, cfg.ServerPort)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to configure TLS")
+		}
+		if redirectServer != nil {
+			go func() {
+				log.Info().Int("port", cfg.TLS.RedirectPort).Msg("HTTP to HTTPS redirect listening")
+				if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Error().Err(err).Msg("HTTP redirect server failed")
+				}
+			}()
+			defer redirectServer.Close()
+		}
+	}
+
 	go func() {
-		log.Info().Int("port", cfg.ServerPort).Msg("Server listening")
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Info().Int("port", cfg.ServerPort).Bool("tls", cfg.TLS.Enabled).Msg("Server listening")
+		var err error
+		if cfg.TLS.Enabled {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("Server failed to listen")
 		}
 	}()
@@ -126,12 +545,54 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Info().Msg("Shutting down server...")
+	probe.SetShuttingDown()
+	if cfg.ShutdownDrainDelay > 0 {
+		log.Info().Dur("delay", cfg.ShutdownDrainDelay).Msg("Waiting for load balancers to stop routing traffic")
+		time.Sleep(cfg.ShutdownDrainDelay)
+	}
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer shutdownCancel()
 
+	// Finish in-flight requests first; they may still publish events.
 	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Fatal().Err(err).Msg("Server forced to shutdown")
+		log.Error().Err(err).Msg("Server forced to shutdown")
 	}
+
+	// Stop fetching and let consumers finish and commit the messages they
+	// hold; the outbox relay finishes the batch it is publishing.
+	cancelConsumers()
+	if !waitWithTimeout(shutdownCtx, &consumers) {
+		log.Warn().Dur("timeout", cfg.ShutdownTimeout).Msg("Consumers did not drain before the shutdown timeout")
+	}
+
+	// Deferred cleanup then closes consumers (flushing offset commits),
+	// producers and finally the database, in that order.
 	log.Info().Msg("Server exited gracefully.")
 }
+
+// newBreaker creates the circuit breaker for a dependency, exporting its state
+// as a metric and logging every transition.
+func newBreaker(name string, cfg circuitbreaker.Config) *circuitbreaker.Breaker {
+	metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(circuitbreaker.StateClosed))
+	return circuitbreaker.New(name, cfg, circuitbreaker.WithStateChangeHook(func(name string, from, to circuitbreaker.State) {
+		metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(to))
+		log.Warn().Str("dependency", name).Str("from", from.String()).Str("to", to.String()).Msg("Circuit breaker state changed")
+	}))
+}
+
+// waitWithTimeout waits for wg and reports whether it finished before ctx
+// expired.
+func waitWithTimeout(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/amqpbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/health"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/natsbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/config"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/kafka"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/pubsubbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/snssqs"
+)
+
+// messaging creates the publishers and subscribers of the message broker
+// selected by MESSAGE_BROKER.
+type messaging struct {
+	cfg     *config.Config
+	breaker *circuitbreaker.Breaker
+
+	kafkaAuth *kafkaauth.Auth
+	nats      *natsbroker.Client
+	rabbitmq  *amqpbroker.Client
+	aws       *snssqs.Client
+	pubsub    *pubsubbroker.Client
+}
+
+// newMessaging connects to the configured broker. Publishers created from it
+// fail fast while breaker is open.
+func newMessaging(cfg *config.Config, breaker *circuitbreaker.Breaker) (*messaging, error) {
+	m := &messaging{cfg: cfg, breaker: breaker}
+	var err error
+	switch cfg.MessageBroker {
+	case broker.NATS:
+		m.nats, err = natsbroker.Connect(cfg.NATS, "orderservice")
+	case broker.RabbitMQ:
+		m.rabbitmq, err = amqpbroker.Connect(cfg.RabbitMQ, "orderservice")
+	case broker.SNSSQS:
+		m.aws, err = snssqs.Connect(context.Background(), cfg.SNSSQS)
+	case broker.PubSub:
+		m.pubsub, err = pubsubbroker.Connect(context.Background(), cfg.PubSub)
+	default:
+		if m.kafkaAuth, err = kafkaauth.New(cfg.KafkaAuth); err != nil {
+			err = fmt.Errorf("failed to configure Kafka authentication: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// publisher returns a publisher for topic.
+func (m *messaging) publisher(topic string) (broker.EventPublisher, error) {
+	switch {
+	case m.nats != nil:
+		return m.nats.Publisher(context.Background(), topic, natsbroker.WithCircuitBreaker(m.breaker))
+	case m.rabbitmq != nil:
+		return m.rabbitmq.Publisher(topic, amqpbroker.WithCircuitBreaker(m.breaker))
+	case m.aws != nil:
+		return m.aws.Publisher(context.Background(), topic, snssqs.WithCircuitBreaker(m.breaker))
+	case m.pubsub != nil:
+		return m.pubsub.Publisher(context.Background(), topic, pubsubbroker.WithCircuitBreaker(m.breaker))
+	}
+	return kafka.NewProducer(m.cfg.KafkaBrokers, topic,
+		kafka.WithAuth(m.kafkaAuth),
+		kafka.WithRequiredAcks(m.cfg.KafkaRequiredAcks),
+		kafka.WithRetry(m.cfg.KafkaPublishRetry),
+		kafka.WithCircuitBreaker(m.breaker)), nil
+}
+
+// transactionalProducer returns the producer publishing outbox batches in
+// Kafka transactions with transactionalID. Only Kafka has transactions.
+func (m *messaging) transactionalProducer(transactionalID string) *kafka.TransactionalProducer {
+	return kafka.NewTransactionalProducer(m.cfg.KafkaBrokers, transactionalID, kafka.WithAuth(m.kafkaAuth))
+}
+
+// subscriber returns a subscriber to topic for the consumer group groupID.
+func (m *messaging) subscriber(topic, groupID string) (broker.EventSubscriber, error) {
+	switch {
+	case m.nats != nil:
+		return m.nats.Subscriber(context.Background(), topic, groupID)
+	case m.rabbitmq != nil:
+		return m.rabbitmq.Subscriber(topic, groupID)
+	case m.aws != nil:
+		return m.aws.Subscriber(context.Background(), topic, groupID)
+	case m.pubsub != nil:
+		return m.pubsub.Subscriber(context.Background(), topic, groupID)
+	}
+	return kafka.NewConsumer(m.cfg.KafkaBrokers, topic, groupID, kafka.WithAuth(m.kafkaAuth), kafka.WithConsumeRetry(m.cfg.KafkaConsumeRetry)), nil
+}
+
+// check returns the readiness check of the broker.
+func (m *messaging) check() health.Check {
+	switch {
+	case m.nats != nil:
+		return m.nats.Check
+	case m.rabbitmq != nil:
+		return m.rabbitmq.Check
+	case m.aws != nil:
+		return m.aws.Check
+	case m.pubsub != nil:
+		return m.pubsub.Check
+	}
+	return health.KafkaCheck(m.cfg.KafkaBrokers, 2*time.Second)
+}
+
+// Close closes the connection to NATS, RabbitMQ or Pub/Sub. Kafka clients
+// hold their own connections and are closed one by one.
+func (m *messaging) Close() error {
+	switch {
+	case m.nats != nil:
+		return m.nats.Close()
+	case m.rabbitmq != nil:
+		return m.rabbitmq.Close()
+	case m.pubsub != nil:
+		return m.pubsub.Close()
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/config"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// configWatchInterval is how often the config file is checked for changes.
+const configWatchInterval = 5 * time.Second
+
+// configReloader returns a function that reloads the configuration and
+// applies the settings that can change without a restart: the log level and
+// the slow-query threshold. Invalid configuration is logged and ignored, so a
+// bad edit never takes the service down.
+func configReloader(current *config.Config, orderRepo *repository.PostgresOrderRepository) func() {
+	return func() {
+		next, err := config.LoadConfig()
+		if err != nil {
+			log.Error().Err(err).Msg("Configuration reload failed, keeping current settings")
+			return
+		}
+
+		if next.LogLevel != current.LogLevel {
+			if err := logging.SetLevel(next.LogLevel); err != nil {
+				log.Error().Err(err).Msg("Failed to apply reloaded log level")
+			} else {
+				log.Info().Str("from", current.LogLevel).Str("to", next.LogLevel).Msg("Log level reloaded")
+			}
+		}
+		if next.SlowQueryThreshold != current.SlowQueryThreshold {
+			orderRepo.SetSlowQueryThreshold(next.SlowQueryThreshold)
+			log.Info().
+				Dur("from", current.SlowQueryThreshold).
+				Dur("to", next.SlowQueryThreshold).
+				Msg("Slow query threshold reloaded")
+		}
+		current = next
+	}
+}
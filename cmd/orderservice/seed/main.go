@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/config"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/seed"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	// Load .env file for database URL
+	err := godotenv.Load()
+	if err != nil {
+		log.Println("No .env file found or error loading .env:", err)
+	}
+
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	opts := seed.DefaultOptions
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.IntVar(&opts.Customers, "customers", opts.Customers, "number of customers to create")
+	flags.IntVar(&opts.Products, "products", opts.Products, "number of catalog products to generate")
+	flags.IntVar(&opts.Orders, "orders", opts.Orders, "number of orders to create")
+	flags.IntVar(&opts.MaxItemsPerOrder, "max-items", opts.MaxItemsPerOrder, "maximum number of items per order")
+	flags.IntVar(&opts.Days, "days", opts.Days, "spread order creation times over this many past days")
+	flags.Int64Var(&opts.Seed, "seed", opts.Seed, "random seed; the same seed generates the same data")
+	productsOut := flags.String("products-out", "", "write the generated products as JSON to this file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if opts.Customers < 1 || opts.Products < 1 || opts.Orders < 0 || opts.MaxItemsPerOrder < 1 || opts.Days < 1 {
+		log.Println("customers, products, max-items and days must be positive, and orders must not be negative")
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return 1
+	}
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		log.Printf("Failed to open database: %v", err)
+		return 1
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := db.PingContext(ctx); err != nil {
+		log.Printf("Failed to connect to database: %v", err)
+		return 1
+	}
+
+	seeder := seed.New(
+		repository.NewPostgresCustomerRepository(db),
+		repository.NewPostgresOrderRepository(db, repository.WithSlowQueryThreshold(0)),
+		opts,
+	)
+	log.Printf("Seeding %d customers and %d orders (seed %d)...", opts.Customers, opts.Orders, opts.Seed)
+	summary, err := seeder.Run(ctx)
+	if err != nil {
+		log.Printf("Seeding failed: %v", err)
+		return 1
+	}
+
+	log.Printf("Created %d customers and %d orders.", summary.Customers, summary.Orders)
+	for status, count := range summary.OrdersByStatus {
+		log.Printf("  %-10s %d", status, count)
+	}
+
+	if *productsOut != "" {
+		if err := writeProducts(*productsOut, summary.Products); err != nil {
+			log.Printf("Failed to write products: %v", err)
+			return 1
+		}
+		log.Printf("Wrote %d products to %s.", len(summary.Products), *productsOut)
+	}
+	return 0
+}
+
+// writeProducts saves the generated products so they can be loaded into the
+// catalog service, which owns product data.
+func writeProducts(path string, products []seed.Product) error {
+	data, err := json.MarshalIndent(products, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
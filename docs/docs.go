@@ -44,14 +44,140 @@ const docTemplate = `{
                 }
             }
         },
+        "/healthz": {
+            "get": {
+                "description": "Reports whether the process is running. Does not check dependencies.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "Alive",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/orders": {
+            "get": {
+                "description": "List orders matching the given filters, newest first unless sort says otherwise.",
+                "produces": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "List orders",
+                "parameters": [
+                    {
+                        "enum": [
+                            "pending",
+                            "processing",
+                            "completed",
+                            "cancelled",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Order status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Customer ID",
+                        "name": "customer_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only orders created at or after this time (RFC 3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only orders created before this time (RFC 3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of orders to return (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of orders to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "created_at:desc,total_price:asc",
+                        "description": "Comma-separated sort keys, each a field with an optional :asc or :desc; sortable fields are created_at, updated_at, total_price and status (default created_at:desc)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated order fields to return, such as id,status,total_price (default: all)",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Orders retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/api.OrderListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Dependency unavailable or service overloaded; retry after Retry-After seconds",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database or Kafka operation timed out",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
             "post": {
                 "description": "Create a new customer order with provided items.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/xml"
                 ],
                 "tags": [
                     "orders"
@@ -76,7 +202,279 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid request payload or validation error",
+                        "description": "Malformed payload, unknown field or invalid field value",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Same items ordered by the same customer moments ago, or external reference already used",
+                        "schema": {
+                            "$ref": "#/definitions/api.DuplicateOrderResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unknown customer, unknown/unsellable products, out-of-stock products or invalid/undeliverable shipping address",
+                        "schema": {
+                            "$ref": "#/definitions/api.UnsellableProductsResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Customer over its order quota; retry after Retry-After seconds",
+                        "schema": {
+                            "$ref": "#/definitions/api.QuotaExceededResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Dependency unavailable or service overloaded; retry after Retry-After seconds",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database or Kafka operation timed out",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/by-reference": {
+            "get": {
+                "description": "Get the order imported from another system with the given reference.",
+                "produces": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Get order by external reference",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "shopify",
+                        "description": "System the order was imported from",
+                        "name": "source",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "#1001",
+                        "description": "Order number in that system",
+                        "name": "reference",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated order fields to return, such as id,status,total_price (default: all)",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Order retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/api.OrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing or invalid source or reference",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Order not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Dependency unavailable or service overloaded; retry after Retry-After seconds",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database or Kafka operation timed out",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/export": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Stream every order matching the filters, oldest first, as CSV (one row per order) or newline-delimited JSON (one object per order, including items). Orders are read from the database in batches while the response is written, so exports of any size use constant memory. Column order is stable. Requires the admin token.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Export orders",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "jsonl",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "default": "csv",
+                        "description": "Export format; ndjson is the same as jsonl",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "processing",
+                            "completed",
+                            "cancelled",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Order status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Customer ID",
+                        "name": "customer_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only orders created at or after this time (RFC 3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only orders created before this time (RFC 3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Exported orders",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter or format",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/stats": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Count the orders matching the filters and total their items and prices, overall and per status. Reads the order_summaries read model, so it does not scan the orders themselves. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Order statistics",
+                "parameters": [
+                    {
+                        "enum": [
+                            "pending",
+                            "processing",
+                            "completed",
+                            "cancelled",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Order status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Customer ID",
+                        "name": "customer_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only orders created at or after this time (RFC 3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only orders created before this time (RFC 3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Order statistics",
+                        "schema": {
+                            "$ref": "#/definitions/api.OrderStatsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
@@ -86,18 +484,31 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Dependency unavailable or service overloaded; retry after Retry-After seconds",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database or Kafka operation timed out",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
                     }
                 }
             }
         },
         "/orders/{id}": {
             "get": {
-                "description": "Get a single order's details by its unique ID.",
+                "description": "Get a single order's details by its unique ID. The response carries an ETag; send it back in If-None-Match to get 304 Not Modified while the order is unchanged.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/xml"
                 ],
                 "tags": [
                     "orders"
@@ -111,6 +522,18 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated order fields to return, such as id,status,total_price (default: all)",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a previously retrieved version of the order",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -118,24 +541,458 @@ const docTemplate = `{
                         "description": "Order retrieved successfully",
                         "schema": {
                             "$ref": "#/definitions/api.OrderResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the order"
+                            }
                         }
                     },
+                    "304": {
+                        "description": "Order unchanged since the version in If-None-Match"
+                    },
                     "400": {
                         "description": "Invalid order ID format",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Order not found",
+                    "404": {
+                        "description": "Order not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Dependency unavailable or service overloaded; retry after Retry-After seconds",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database or Kafka operation timed out",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/{id}/cancel": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Cancel a pending or processing order. Requires the admin token.",
+                "produces": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Cancel an order",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Order cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/api.OrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid order ID format",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Order not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Order can no longer be cancelled, or order locked by another change",
+                        "schema": {
+                            "$ref": "#/definitions/api.InvalidTransitionResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Dependency unavailable or service overloaded; retry after Retry-After seconds",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database or Kafka operation timed out",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/{id}/events": {
+            "get": {
+                "description": "Get the events recorded for an order, oldest first. Orders only have events while event sourcing is enabled, and orders stored before it was have none until their first status change.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Get the events of an order",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Events retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/api.OrderEventsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid order ID format",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Order not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Dependency unavailable or service overloaded; retry after Retry-After seconds",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database or Kafka operation timed out",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/{id}/items": {
+            "get": {
+                "description": "Get the items of a single order.",
+                "produces": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Get the items of an order",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Items retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/api.OrderItemsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid order ID format",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Order not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Dependency unavailable or service overloaded; retry after Retry-After seconds",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database or Kafka operation timed out",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/{id}/resend-event": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Publish the order's orders.placed event again. Requires the admin token.",
+                "produces": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Resend the order placed event",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Event published",
+                        "schema": {
+                            "$ref": "#/definitions/api.OrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid order ID format",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Order not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Dependency unavailable or service overloaded; retry after Retry-After seconds",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database or Kafka operation timed out",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/orders/{id}/status": {
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Move an order to a new status, subject to the allowed status transitions. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Change order status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New status",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.UpdateOrderStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Order status updated",
+                        "schema": {
+                            "$ref": "#/definitions/api.OrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid order ID, malformed payload or unknown status",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Order not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Status transition not allowed, or order locked by another change",
+                        "schema": {
+                            "$ref": "#/definitions/api.InvalidTransitionResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Dependency unavailable or service overloaded; retry after Retry-After seconds",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database or Kafka operation timed out",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Reports whether the service can accept traffic: database and Kafka must be reachable and the service must not be shutting down.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "Ready",
+                        "schema": {
+                            "$ref": "#/definitions/health.Result"
+                        }
+                    },
+                    "503": {
+                        "description": "Not ready",
                         "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
+                            "$ref": "#/definitions/health.Result"
                         }
-                    },
-                    "500": {
-                        "description": "Internal server error",
+                    }
+                }
+            }
+        },
+        "/version": {
+            "get": {
+                "description": "Reports the version, git commit, build time and Go version of the running service, and which optional features its configuration enables.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Build information",
+                "responses": {
+                    "200": {
+                        "description": "Build information",
                         "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
+                            "$ref": "#/definitions/buildinfo.Info"
                         }
                     }
                 }
@@ -143,6 +1000,39 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "api.AddressBody": {
+            "type": "object",
+            "properties": {
+                "city": {
+                    "type": "string",
+                    "example": "London"
+                },
+                "country": {
+                    "type": "string",
+                    "example": "GB"
+                },
+                "line1": {
+                    "type": "string",
+                    "example": "12 St James's Square"
+                },
+                "line2": {
+                    "type": "string",
+                    "example": "Flat 3"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Ada Lovelace"
+                },
+                "postal_code": {
+                    "type": "string",
+                    "example": "SW1Y 4LB"
+                },
+                "region": {
+                    "type": "string",
+                    "example": "Greater London"
+                }
+            }
+        },
         "api.CreateOrderItem": {
             "type": "object",
             "required": [
@@ -176,21 +1066,163 @@ const docTemplate = `{
                     "type": "string",
                     "example": "a1b2c3d4-e5f6-7890-1234-567890abcdef"
                 },
+                "external_reference": {
+                    "description": "ExternalReference identifies the order in the system it is imported\nfrom. An order with a reference already used in that system is\nrejected, so imports can safely be retried.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.ExternalReferenceBody"
+                        }
+                    ]
+                },
                 "items": {
                     "type": "array",
                     "minItems": 1,
                     "items": {
                         "$ref": "#/definitions/api.CreateOrderItem"
                     }
+                },
+                "shipping_address": {
+                    "description": "ShippingAddress is where the order is delivered. It is normalized\nbefore it is stored, and rejected if it is incomplete or, with address\nverification enabled, undeliverable.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.AddressBody"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.DuplicateOrderResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "ORDER_NOT_FOUND"
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ErrorDetail"
+                    }
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Order not found"
+                },
+                "order_id": {
+                    "description": "OrderID is the earlier order the request repeats.",
+                    "type": "string",
+                    "example": "a1b2c3d4-e5f6-7890-1234-567890abcdef"
+                }
+            }
+        },
+        "api.ErrorDetail": {
+            "type": "object",
+            "properties": {
+                "constraint": {
+                    "description": "Constraint is the rule the field breaks: a binding rule such as\nrequired or gt=0, type for a value of the wrong JSON type, format for\na malformed value such as a UUID, or unknown for an unexpected field.",
+                    "type": "string",
+                    "example": "gt=0"
+                },
+                "field": {
+                    "description": "Field is the JSON path of the offending field or query parameter, if\nthe problem concerns one.",
+                    "type": "string",
+                    "example": "items[0].quantity"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "must be greater than 0"
+                },
+                "value": {
+                    "description": "Value is the value sent, when it is known and not too large to echo.",
+                    "type": "string",
+                    "example": "-1"
                 }
             }
         },
         "api.ErrorResponse": {
             "type": "object",
             "properties": {
-                "error": {
+                "code": {
+                    "type": "string",
+                    "example": "ORDER_NOT_FOUND"
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ErrorDetail"
+                    }
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Order not found"
+                }
+            }
+        },
+        "api.ExternalReferenceBody": {
+            "type": "object",
+            "required": [
+                "reference",
+                "source"
+            ],
+            "properties": {
+                "reference": {
+                    "type": "string",
+                    "example": "#1001"
+                },
+                "source": {
+                    "type": "string",
+                    "example": "shopify"
+                }
+            }
+        },
+        "api.InvalidTransitionResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "ORDER_NOT_FOUND"
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ErrorDetail"
+                    }
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Order not found"
+                },
+                "requested_status": {
+                    "type": "string",
+                    "example": "completed"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "cancelled"
+                }
+            }
+        },
+        "api.Link": {
+            "type": "object",
+            "properties": {
+                "href": {
                     "type": "string",
-                    "example": "Invalid request payload"
+                    "example": "/api/v1/orders/a1b2c3d4-e5f6-7890-1234-567890abcdef"
+                },
+                "method": {
+                    "type": "string",
+                    "example": "GET"
+                }
+            }
+        },
+        "api.OrderEventsResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "object"
+                    }
                 }
             }
         },
@@ -211,6 +1243,56 @@ const docTemplate = `{
                 }
             }
         },
+        "api.OrderItemsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.OrderItemResponse"
+                    }
+                }
+            }
+        },
+        "api.OrderLinks": {
+            "type": "object",
+            "properties": {
+                "cancel": {
+                    "$ref": "#/definitions/api.Link"
+                },
+                "customer_orders": {
+                    "$ref": "#/definitions/api.Link"
+                },
+                "events": {
+                    "$ref": "#/definitions/api.Link"
+                },
+                "items": {
+                    "$ref": "#/definitions/api.Link"
+                },
+                "self": {
+                    "$ref": "#/definitions/api.Link"
+                }
+            }
+        },
+        "api.OrderListResponse": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "example": 50
+                },
+                "offset": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "orders": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.OrderResponse"
+                    }
+                }
+            }
+        },
         "api.OrderResponse": {
             "type": "object",
             "properties": {
@@ -222,6 +1304,19 @@ const docTemplate = `{
                     "type": "string",
                     "example": "a1b2c3d4-e5f6-7890-1234-567890abcdef"
                 },
+                "duplicate_of": {
+                    "description": "DuplicateOf is only set when creating an order that repeats a recent\nidentical order, with duplicate detection in flag mode.",
+                    "type": "string",
+                    "example": "a1b2c3d4-e5f6-7890-1234-567890abcdef"
+                },
+                "external_reference": {
+                    "description": "ExternalReference is only set for orders imported from another system.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.ExternalReferenceBody"
+                        }
+                    ]
+                },
                 "id": {
                     "type": "string",
                     "example": "a1b2c3d4-e5f6-7890-1234-567890abcdef"
@@ -232,6 +1327,17 @@ const docTemplate = `{
                         "$ref": "#/definitions/api.OrderItemResponse"
                     }
                 },
+                "links": {
+                    "$ref": "#/definitions/api.OrderLinks"
+                },
+                "shipping_address": {
+                    "description": "ShippingAddress is only set for orders placed with one.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.AddressBody"
+                        }
+                    ]
+                },
                 "status": {
                     "description": "Changed to string for JSON serialization",
                     "type": "string",
@@ -246,6 +1352,172 @@ const docTemplate = `{
                     "example": "2023-10-27T10:00:00Z"
                 }
             }
+        },
+        "api.OrderStatsResponse": {
+            "type": "object",
+            "properties": {
+                "by_status": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.OrderStatusStatsItem"
+                    }
+                },
+                "items": {
+                    "type": "integer",
+                    "example": 97
+                },
+                "orders": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "total_price": {
+                    "type": "number",
+                    "example": 4210.5
+                }
+            }
+        },
+        "api.OrderStatusStatsItem": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "integer",
+                    "example": 71
+                },
+                "orders": {
+                    "type": "integer",
+                    "example": 30
+                },
+                "status": {
+                    "type": "string",
+                    "example": "completed"
+                },
+                "total_price": {
+                    "type": "number",
+                    "example": 3150.25
+                }
+            }
+        },
+        "api.QuotaExceededResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "ORDER_NOT_FOUND"
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ErrorDetail"
+                    }
+                },
+                "limit": {
+                    "type": "integer",
+                    "example": 30
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Order not found"
+                },
+                "retry_after_seconds": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "window": {
+                    "description": "Window is the quota period that is used up: minute or day.",
+                    "type": "string",
+                    "example": "minute"
+                }
+            }
+        },
+        "api.UnsellableProductsResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "ORDER_NOT_FOUND"
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ErrorDetail"
+                    }
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Order not found"
+                },
+                "product_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.UpdateOrderStatusRequest": {
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "example": "cancelled"
+                }
+            }
+        },
+        "buildinfo.Info": {
+            "type": "object",
+            "properties": {
+                "build_time": {
+                    "type": "string",
+                    "example": "2024-05-01T12:00:00Z"
+                },
+                "commit": {
+                    "type": "string",
+                    "example": "3f9c2e1d5b7a4c6e8f0a1b2c3d4e5f6a7b8c9d0e"
+                },
+                "features": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "boolean"
+                    }
+                },
+                "go_version": {
+                    "type": "string",
+                    "example": "go1.24.4"
+                },
+                "modified": {
+                    "description": "Modified is set when the build included uncommitted changes.",
+                    "type": "boolean"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "v1.4.0"
+                }
+            }
+        },
+        "health.Result": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "ready": {
+                    "type": "boolean"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "description": "Operator endpoints expect \"Bearer \u003cADMIN_TOKEN\u003e\".",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,92 @@
+package amqpbroker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeDeclarer records the topology declared on it.
+type fakeDeclarer struct {
+	exchanges map[string]string
+	queues    map[string]amqp.Table
+	bindings  []string
+	err       error
+}
+
+func newFakeDeclarer() *fakeDeclarer {
+	return &fakeDeclarer{exchanges: map[string]string{}, queues: map[string]amqp.Table{}}
+}
+
+func (f *fakeDeclarer) ExchangeDeclare(name, kind string, _, _, _, _ bool, _ amqp.Table) error {
+	f.exchanges[name] = kind
+	return f.err
+}
+
+func (f *fakeDeclarer) QueueDeclare(name string, _, _, _, _ bool, args amqp.Table) (amqp.Queue, error) {
+	f.queues[name] = args
+	return amqp.Queue{Name: name}, nil
+}
+
+func (f *fakeDeclarer) QueueBind(name, key, exchange string, _ bool, _ amqp.Table) error {
+	f.bindings = append(f.bindings, exchange+" --"+key+"--> "+name)
+	return nil
+}
+
+func testClient() *Client {
+	return &Client{cfg: Config{Exchange: "events", DeadLetterExchange: "events.dlx", Prefetch: 10}}
+}
+
+func TestDeclareQueue(t *testing.T) {
+	ch := newFakeDeclarer()
+
+	queue, err := testClient().declareQueue(ch, "orders.placed", "inventory-service-group")
+	require.NoError(t, err)
+
+	assert.Equal(t, "inventory-service-group.orders.placed", queue)
+	assert.Equal(t, map[string]string{"events": amqp.ExchangeTopic, "events.dlx": amqp.ExchangeDirect}, ch.exchanges)
+	assert.Equal(t, amqp.Table{
+		"x-dead-letter-exchange":    "events.dlx",
+		"x-dead-letter-routing-key": "inventory-service-group.orders.placed",
+	}, ch.queues[queue])
+	assert.Contains(t, ch.queues, "inventory-service-group.orders.placed.dlq")
+	assert.ElementsMatch(t, []string{
+		"events --orders.placed--> inventory-service-group.orders.placed",
+		"events.dlx --inventory-service-group.orders.placed--> inventory-service-group.orders.placed.dlq",
+	}, ch.bindings)
+}
+
+func TestDeclareQueue_Error(t *testing.T) {
+	ch := newFakeDeclarer()
+	ch.err = errors.New("access refused")
+
+	_, err := testClient().declareQueue(ch, "orders.placed", "inventory-service-group")
+	assert.ErrorContains(t, err, "failed to declare exchange events: access refused")
+}
+
+func TestTableCarrier_PropagatesTraces(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	headers := amqp.Table{KeyHeader: "order-1"}
+	ctx, publishSpan := tracing.StartPublishSpan(context.Background(), "test", "rabbitmq", "orders.placed", tableCarrier(headers))
+	publishSpan.End()
+	require.NoError(t, headers.Validate(), "headers must remain a valid AMQP table")
+
+	_, processSpan := tracing.StartProcessSpan(context.Background(), "test", "rabbitmq", "orders.placed", tableCarrier(headers))
+	defer processSpan.End()
+
+	assert.Equal(t,
+		trace.SpanContextFromContext(ctx).TraceID(),
+		processSpan.SpanContext().TraceID(),
+		"processing should continue the publisher's trace")
+}
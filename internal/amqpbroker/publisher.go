@@ -0,0 +1,143 @@
+package amqpbroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/jonamarkin/e-commerce-order-processing/internal/amqpbroker"
+
+// errNacked is returned when the server refuses to take a published message.
+var errNacked = errors.New("message was nacked by the server")
+
+// Option configures a Publisher.
+type Option func(*Publisher)
+
+// WithCircuitBreaker makes a publisher fail fast with a
+// circuitbreaker.OpenError while breaker is open.
+func WithCircuitBreaker(breaker *circuitbreaker.Breaker) Option {
+	return func(p *Publisher) {
+		p.breaker = breaker
+	}
+}
+
+// Publisher publishes messages to the exchange with one topic as routing key.
+// Publishes wait for the server to confirm the message is stored.
+type Publisher struct {
+	client  *Client
+	topic   string
+	breaker *circuitbreaker.Breaker
+
+	mu sync.Mutex
+	ch *amqp.Channel
+}
+
+var _ broker.EventPublisher = (*Publisher)(nil)
+
+// Publisher returns a publisher for topic, declaring the exchange if needed.
+func (c *Client) Publisher(topic string, opts ...Option) (*Publisher, error) {
+	p := &Publisher{client: c, topic: topic}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if _, err := p.channel(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// PublishMessage publishes a key-value message as a persistent message and
+// waits for the server to confirm it.
+func (p *Publisher) PublishMessage(ctx context.Context, key, value []byte) error {
+	headers := amqp.Table{KeyHeader: string(key)}
+	if id := logging.CorrelationID(ctx); id != "" {
+		headers[logging.CorrelationIDKafkaHeader] = id
+	}
+	ctx, span := tracing.StartPublishSpan(ctx, tracerName, "rabbitmq", p.topic, tableCarrier(headers),
+		attribute.String("messaging.rabbitmq.destination.routing_key", p.topic))
+	defer span.End()
+
+	if err := p.breaker.Allow(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "circuit breaker open")
+		return err
+	}
+	err := p.publish(ctx, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		Body:         value,
+	})
+	p.breaker.Done(err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+		return fmt.Errorf("failed to publish message to RabbitMQ: %w", err)
+	}
+	return nil
+}
+
+func (p *Publisher) publish(ctx context.Context, msg amqp.Publishing) error {
+	ch, err := p.channel()
+	if err != nil {
+		return err
+	}
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx, p.client.cfg.Exchange, p.topic, false, false, msg)
+	if err != nil {
+		return err
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return errNacked
+	}
+	return nil
+}
+
+// channel returns the publisher's channel in confirm mode, opening a new one
+// if it was closed.
+func (p *Publisher) channel() (*amqp.Channel, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ch != nil && !p.ch.IsClosed() {
+		return p.ch, nil
+	}
+	ch, err := p.client.channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.client.declareExchange(ch); err != nil {
+		ch.Close()
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	p.ch = ch
+	return ch, nil
+}
+
+// Close closes the publisher's channel.
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ch == nil || p.ch.IsClosed() {
+		return nil
+	}
+	return p.ch.Close()
+}
@@ -0,0 +1,145 @@
+package amqpbroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// errDeliveriesClosed is returned when the server or the connection ends a
+// consumer.
+var errDeliveriesClosed = errors.New("delivery channel closed")
+
+// Subscriber consumes the queue of one consumer group for one topic.
+type Subscriber struct {
+	client *Client
+	topic  string
+	group  string
+	queue  string
+}
+
+var _ broker.EventSubscriber = (*Subscriber)(nil)
+
+// Subscriber returns a subscriber to topic for the consumer group groupID,
+// declaring its queues and bindings if needed. The queue only receives the
+// messages published after it was first declared.
+func (c *Client) Subscriber(topic, groupID string) (*Subscriber, error) {
+	ch, err := c.channel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+	queue, err := c.declareQueue(ch, topic, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return &Subscriber{client: c, topic: topic, group: groupID, queue: queue}, nil
+}
+
+// Consume passes messages to handler until ctx is cancelled, reconnecting
+// when the connection is lost. A message is acknowledged once handled, or
+// rejected to the dead-letter queue if handler fails. Cancelling ctx stops
+// fetching but lets the message in flight finish and be acknowledged, so
+// Consume returns only once that work is done; prefetched messages are
+// returned to the queue.
+func (s *Subscriber) Consume(ctx context.Context, handler broker.MessageHandler) {
+	processCtx := context.WithoutCancel(ctx)
+	log.Info().Str("topic", s.topic).Str("queue", s.queue).Msg("Starting RabbitMQ consumer")
+	for {
+		err := s.consume(ctx, processCtx, handler)
+		if ctx.Err() != nil {
+			log.Info().Str("topic", s.topic).Msg("RabbitMQ consumer context cancelled. Shutting down.")
+			return
+		}
+		log.Error().Err(err).Str("queue", s.queue).Msg("RabbitMQ consumer stopped, restarting")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// consume handles the deliveries of one channel until ctx is cancelled or
+// the channel closes.
+func (s *Subscriber) consume(ctx, processCtx context.Context, handler broker.MessageHandler) error {
+	ch, err := s.client.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	if _, err := s.client.declareQueue(ch, s.topic, s.group); err != nil {
+		return err
+	}
+	if err := ch.Qos(s.client.cfg.Prefetch, 0, false); err != nil {
+		return fmt.Errorf("failed to set prefetch: %w", err)
+	}
+	deliveries, err := ch.Consume(s.queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume queue %s: %w", s.queue, err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case d, ok := <-deliveries:
+			if !ok {
+				return errDeliveriesClosed
+			}
+			s.process(processCtx, d, handler)
+		}
+	}
+}
+
+// process handles a single delivery and settles it.
+func (s *Subscriber) process(ctx context.Context, d amqp.Delivery, handler broker.MessageHandler) {
+	key, _ := d.Headers[KeyHeader].(string)
+	msgCtx, span := tracing.StartProcessSpan(s.messageContext(ctx, d, key), tracerName, "rabbitmq", s.topic,
+		tableCarrier(d.Headers),
+		attribute.String("messaging.rabbitmq.destination.routing_key", d.RoutingKey),
+		attribute.String("messaging.consumer.group.name", s.group))
+	defer span.End()
+
+	if err := handler(msgCtx, []byte(key), d.Body); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "handler failed")
+		log.Ctx(msgCtx).Error().Err(err).Msg("Failed to handle message, dead-lettering it")
+		if err := d.Nack(false, false); err != nil {
+			log.Ctx(msgCtx).Error().Err(err).Msg("Error rejecting message")
+		}
+		return
+	}
+	if err := d.Ack(false); err != nil {
+		log.Ctx(msgCtx).Error().Err(err).Msg("Error acknowledging message")
+	}
+}
+
+// messageContext returns a context whose logger carries the message's
+// correlation ID (generating one if the publisher didn't set it), topic,
+// queue and key.
+func (s *Subscriber) messageContext(ctx context.Context, d amqp.Delivery, key string) context.Context {
+	id, _ := d.Headers[logging.CorrelationIDKafkaHeader].(string)
+	if id == "" {
+		id = logging.NewCorrelationID()
+	}
+	return logging.WithCorrelationID(ctx, id, map[string]any{
+		"topic":    s.topic,
+		"queue":    s.queue,
+		"order_id": key,
+	})
+}
+
+// Close does nothing: Consume closes its channel when its context is
+// cancelled and the connection belongs to the Client.
+func (s *Subscriber) Close() error {
+	return nil
+}
@@ -0,0 +1,46 @@
+// Package broker defines how the services publish and consume events,
+// independently of the message broker carrying them. The Kafka clients of
+// each service, the NATS JetStream clients of natsbroker, the RabbitMQ
+// clients of amqpbroker, the SNS/SQS clients of snssqs and the Google Cloud
+// Pub/Sub clients of pubsubbroker implement these interfaces; MESSAGE_BROKER
+// selects between them.
+package broker
+
+import "context"
+
+// Supported message brokers.
+const (
+	Kafka    = "kafka"
+	NATS     = "nats"
+	RabbitMQ = "rabbitmq"
+	SNSSQS   = "sns-sqs"
+	PubSub   = "pubsub"
+)
+
+// Kinds lists the supported message brokers.
+var Kinds = []string{Kafka, NATS, RabbitMQ, SNSSQS, PubSub}
+
+// EventPublisher publishes messages to one topic. Keys are order IDs
+// throughout the system; brokers that order messages do so per key.
+type EventPublisher interface {
+	PublishMessage(ctx context.Context, key, value []byte) error
+	Close() error
+}
+
+// MessageHandler processes a single message, and may be passed the same
+// message again. A returned error is logged, and a message that keeps
+// failing is set aside so a poison message can't block the topic: Kafka
+// consumers retry it with backoff, then write it to its dead-letter topic
+// and only then commit its offset; RabbitMQ, SNS/SQS and Pub/Sub dead-letter
+// it; NATS acknowledges it anyway.
+type MessageHandler func(ctx context.Context, key, value []byte) error
+
+// EventSubscriber delivers the messages of one topic to a consumer group:
+// each message is handled by one member of the group.
+type EventSubscriber interface {
+	// Consume passes messages to handler until ctx is cancelled. Cancelling
+	// ctx stops fetching but lets the message in flight finish and be
+	// acknowledged, so Consume returns only once that work is done.
+	Consume(ctx context.Context, handler MessageHandler)
+	Close() error
+}
@@ -0,0 +1,64 @@
+// Package buildinfo describes the running binary, so each environment can
+// report what is deployed. Version, Commit and BuildTime are set when linking:
+//
+//	go build -ldflags "-X github.com/jonamarkin/e-commerce-order-processing/internal/buildinfo.Version=v1.4.0 \
+//		-X github.com/jonamarkin/e-commerce-order-processing/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//		-X github.com/jonamarkin/e-commerce-order-processing/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without them, the commit and time recorded by the Go toolchain for builds
+// from a git checkout are used instead.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at link time with -ldflags "-X ...".
+var (
+	Version   string
+	Commit    string
+	BuildTime string
+)
+
+// Info @Description The build of the running service and the optional features its configuration enables.
+type Info struct {
+	Version   string `json:"version,omitempty" example:"v1.4.0"`
+	Commit    string `json:"commit,omitempty" example:"3f9c2e1d5b7a4c6e8f0a1b2c3d4e5f6a7b8c9d0e"`
+	BuildTime string `json:"build_time,omitempty" example:"2024-05-01T12:00:00Z"`
+	GoVersion string `json:"go_version" example:"go1.24.4"`
+	// Modified is set when the build included uncommitted changes.
+	Modified bool            `json:"modified,omitempty"`
+	Features map[string]bool `json:"features,omitempty"`
+}
+
+// Get returns the build information of the running binary with features.
+func Get(features map[string]bool) Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		Features:  features,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+	}
+	return info
+}
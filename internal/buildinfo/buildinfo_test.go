@@ -0,0 +1,21 @@
+package buildinfo_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/buildinfo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	buildinfo.Commit = "3f9c2e1"
+	buildinfo.BuildTime = "2024-05-01T12:00:00Z"
+	t.Cleanup(func() { buildinfo.Commit, buildinfo.BuildTime = "", "" })
+
+	info := buildinfo.Get(map[string]bool{"outbox": true})
+	assert.Equal(t, "3f9c2e1", info.Commit, "link-time values win")
+	assert.Equal(t, "2024-05-01T12:00:00Z", info.BuildTime)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Equal(t, map[string]bool{"outbox": true}, info.Features)
+}
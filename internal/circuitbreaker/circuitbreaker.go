@@ -0,0 +1,241 @@
+// Package circuitbreaker stops calls to a dependency that keeps failing, so
+// callers fail fast instead of waiting for every call to time out.
+//
+// A Breaker starts closed and lets every call through. After
+// FailureThreshold consecutive failures it opens and rejects calls with an
+// *OpenError for OpenTimeout. It then lets a single probe call through
+// (half-open): a success closes it again, a failure reopens it.
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrOpen is matched by the errors returned while a breaker is open.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Config configures the breakers of a service.
+type Config struct {
+	Enabled bool `key:"enabled" env:"CIRCUIT_BREAKER_ENABLED" default:"true"`
+	// FailureThreshold is the number of consecutive failures that opens a
+	// breaker.
+	FailureThreshold int `key:"failure_threshold" env:"CIRCUIT_BREAKER_FAILURE_THRESHOLD" default:"5"`
+	// OpenTimeout is how long an open breaker rejects calls before letting a
+	// probe through.
+	OpenTimeout time.Duration `key:"open_timeout" env:"CIRCUIT_BREAKER_OPEN_TIMEOUT" default:"30s"`
+}
+
+// OpenError is returned by a breaker that rejects a call.
+type OpenError struct {
+	// Name is the dependency the breaker protects.
+	Name string
+	// RetryAfter is how long until the breaker lets a probe through.
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, ErrOpen)
+}
+
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+// RetryAfter returns how long the caller should wait before retrying if err
+// was returned by an open breaker.
+func RetryAfter(err error) (time.Duration, bool) {
+	var openErr *OpenError
+	if !errors.As(err, &openErr) {
+		return 0, false
+	}
+	return openErr.RetryAfter, true
+}
+
+// Breaker is a circuit breaker for one dependency. A nil *Breaker lets every
+// call through, so callers need not check whether breakers are enabled.
+type Breaker struct {
+	name          string
+	cfg           Config
+	onStateChange func(name string, from, to State)
+	now           func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// Option configures a Breaker.
+type Option func(*Breaker)
+
+// WithStateChangeHook calls fn, with the breaker's lock held, whenever the
+// breaker changes state. Services use it to export the state as a metric.
+func WithStateChangeHook(fn func(name string, from, to State)) Option {
+	return func(b *Breaker) {
+		b.onStateChange = fn
+	}
+}
+
+// WithClock replaces time.Now; it is meant for tests.
+func WithClock(now func() time.Time) Option {
+	return func(b *Breaker) {
+		b.now = now
+	}
+}
+
+// New creates a closed Breaker protecting the dependency called name.
+func New(name string, cfg Config, opts ...Option) *Breaker {
+	if cfg.FailureThreshold < 1 {
+		cfg.FailureThreshold = 1
+	}
+	b := &Breaker{name: name, cfg: cfg, now: time.Now}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Name returns the name of the protected dependency.
+func (b *Breaker) Name() string {
+	if b == nil {
+		return ""
+	}
+	return b.name
+}
+
+// State returns the current state, moving an open breaker whose timeout has
+// elapsed to half-open.
+func (b *Breaker) State() State {
+	if b == nil {
+		return StateClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	return b.state
+}
+
+// Allow reports whether a call may proceed. It returns an *OpenError if not.
+// Every allowed call must be followed by Done with its result.
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	switch b.state {
+	case StateOpen:
+		return &OpenError{Name: b.name, RetryAfter: b.cfg.OpenTimeout - b.now().Sub(b.openedAt)}
+	case StateHalfOpen:
+		if b.probing {
+			return &OpenError{Name: b.name, RetryAfter: b.cfg.OpenTimeout}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Check returns an *OpenError if the breaker is open, without counting as a
+// call. A half-open breaker passes, so the caller may go on to make the probe.
+func (b *Breaker) Check() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	if b.state != StateOpen {
+		return nil
+	}
+	return &OpenError{Name: b.name, RetryAfter: b.cfg.OpenTimeout - b.now().Sub(b.openedAt)}
+}
+
+// Done records the result of a call let through by Allow. Cancellations by
+// the caller are not the dependency's fault and count as neither success nor
+// failure.
+func (b *Breaker) Done(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasProbe := b.state == StateHalfOpen && b.probing
+	if wasProbe {
+		b.probing = false
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+	case err != nil:
+		b.failures++
+		if wasProbe || b.failures >= b.cfg.FailureThreshold {
+			b.openedAt = b.now()
+			b.setState(StateOpen)
+		}
+	default:
+		b.failures = 0
+		b.setState(StateClosed)
+	}
+}
+
+// Do runs fn if the breaker allows it and records its result. isFailure
+// decides which errors count against the dependency; nil counts every error.
+func (b *Breaker) Do(fn func() error, isFailure func(error) bool) error {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+	err := fn()
+	if err != nil && isFailure != nil && !isFailure(err) {
+		b.Done(nil)
+	} else {
+		b.Done(err)
+	}
+	return err
+}
+
+// refresh moves an open breaker whose timeout has elapsed to half-open.
+func (b *Breaker) refresh() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.setState(StateHalfOpen)
+	}
+}
+
+func (b *Breaker) setState(to State) {
+	if b.state == to {
+		return
+	}
+	from := b.state
+	b.state = to
+	if to != StateHalfOpen {
+		b.probing = false
+	}
+	if b.onStateChange != nil {
+		b.onStateChange(b.name, from, to)
+	}
+}
@@ -0,0 +1,113 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errDown = errors.New("database is down")
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newBreaker(t *testing.T) (*circuitbreaker.Breaker, *clock, *[]string) {
+	t.Helper()
+	clk := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var transitions []string
+	b := circuitbreaker.New("database",
+		circuitbreaker.Config{Enabled: true, FailureThreshold: 3, OpenTimeout: 10 * time.Second},
+		circuitbreaker.WithClock(clk.now),
+		circuitbreaker.WithStateChangeHook(func(name string, from, to circuitbreaker.State) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		}),
+	)
+	return b, clk, &transitions
+}
+
+func fail() error    { return errDown }
+func succeed() error { return nil }
+
+func TestBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	b, clk, _ := newBreaker(t)
+
+	require.ErrorIs(t, b.Do(fail, nil), errDown)
+	require.ErrorIs(t, b.Do(fail, nil), errDown)
+	require.NoError(t, b.Do(succeed, nil)) // resets the count
+	require.ErrorIs(t, b.Do(fail, nil), errDown)
+	require.ErrorIs(t, b.Do(fail, nil), errDown)
+	assert.Equal(t, circuitbreaker.StateClosed, b.State())
+
+	require.ErrorIs(t, b.Do(fail, nil), errDown)
+	assert.Equal(t, circuitbreaker.StateOpen, b.State())
+
+	clk.advance(4 * time.Second)
+	called := false
+	err := b.Do(func() error { called = true; return nil }, nil)
+	assert.False(t, called)
+	require.ErrorIs(t, err, circuitbreaker.ErrOpen)
+	retryAfter, ok := circuitbreaker.RetryAfter(err)
+	require.True(t, ok)
+	assert.Equal(t, 6*time.Second, retryAfter)
+	assert.EqualError(t, err, "database: circuit breaker is open")
+}
+
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	t.Run("success closes the breaker", func(t *testing.T) {
+		b, clk, transitions := newBreaker(t)
+		for range 3 {
+			_ = b.Do(fail, nil)
+		}
+		clk.advance(10 * time.Second)
+		assert.Equal(t, circuitbreaker.StateHalfOpen, b.State())
+
+		require.NoError(t, b.Allow())
+		// Only one probe at a time.
+		require.ErrorIs(t, b.Allow(), circuitbreaker.ErrOpen)
+		b.Done(nil)
+
+		assert.Equal(t, circuitbreaker.StateClosed, b.State())
+		assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->closed"}, *transitions)
+	})
+
+	t.Run("failure reopens the breaker", func(t *testing.T) {
+		b, clk, _ := newBreaker(t)
+		for range 3 {
+			_ = b.Do(fail, nil)
+		}
+		clk.advance(10 * time.Second)
+
+		require.ErrorIs(t, b.Do(fail, nil), errDown)
+		assert.Equal(t, circuitbreaker.StateOpen, b.State())
+		clk.advance(9 * time.Second)
+		assert.Equal(t, circuitbreaker.StateOpen, b.State())
+	})
+}
+
+func TestBreaker_IgnoredErrors(t *testing.T) {
+	b, _, _ := newBreaker(t)
+	notFound := errors.New("not found")
+	isFailure := func(err error) bool { return !errors.Is(err, notFound) }
+
+	for range 5 {
+		require.ErrorIs(t, b.Do(func() error { return notFound }, isFailure), notFound)
+		require.ErrorIs(t, b.Do(func() error { return context.Canceled }, nil), context.Canceled)
+	}
+	assert.Equal(t, circuitbreaker.StateClosed, b.State())
+}
+
+func TestBreaker_Nil(t *testing.T) {
+	var b *circuitbreaker.Breaker
+
+	require.ErrorIs(t, b.Do(fail, nil), errDown)
+	assert.Equal(t, circuitbreaker.StateClosed, b.State())
+	_, ok := circuitbreaker.RetryAfter(errDown)
+	assert.False(t, ok)
+}
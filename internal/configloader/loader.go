@@ -0,0 +1,373 @@
+// Package configloader fills service configuration structs from struct-tag
+// defaults, an optional YAML or TOML file and environment variables, in that
+// order of increasing precedence.
+//
+// Fields are described with three tags:
+//
+//	key     dotted path of the value in the config file; nested structs
+//	        prefix the keys of their fields with their own key
+//	env     environment variable that overrides the file value
+//	default value used when neither the file nor the environment set one
+//	secret  "true" if the value may be a secret reference; see ResolveSecrets
+//
+// Supported field types are string (and string-based types), bool, signed
+// integers, float64, time.Duration, []string and any type implementing
+// encoding.TextUnmarshaler. Lists may be given as a comma-separated string in
+// both the environment and the file.
+//
+// Profiles add a layer of environment-specific defaults between the tag
+// defaults and the file; see WithProfile.
+package configloader
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv is the environment variable naming the config file to load.
+const FileEnv = "CONFIG_FILE"
+
+// ProfileEnv is the environment variable selecting the configuration profile.
+const ProfileEnv = "APP_ENV"
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Profiles maps a profile name, such as "dev" or "prod", to the defaults it
+// overrides, keyed by file key.
+type Profiles map[string]map[string]string
+
+// LoadOption customises Load.
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	profiles Profiles
+	profile  string
+}
+
+// WithProfile applies profiles[name] on top of the tag defaults. An empty name
+// selects no profile; an unknown one is reported as a problem with APP_ENV.
+func WithProfile(profiles Profiles, name string) LoadOption {
+	return func(o *loadOptions) {
+		o.profiles = profiles
+		o.profile = name
+	}
+}
+
+// Load populates dst, which must be a pointer to a struct, from defaults, the
+// file at path and the environment. An empty path skips the file. Keys in the
+// file that don't map to any field are reported as problems so typos surface
+// at startup.
+//
+// Values that fail to parse don't stop loading: they are collected and
+// returned together as a *ValidationError, leaving the affected fields at
+// their defaults. Any other error means the file itself couldn't be used.
+func Load(dst any, path string, opts ...LoadOption) error {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("configloader: destination must be a pointer to a struct, got %T", dst)
+	}
+
+	fields := collectFields(v.Elem(), "")
+
+	for _, f := range fields {
+		if f.defaultValue == "" {
+			continue
+		}
+		if err := setFromString(f.value, f.defaultValue); err != nil {
+			return fmt.Errorf("configloader: invalid default for %s: %w", f.label(), err)
+		}
+	}
+
+	var problems []Problem
+	if o.profile != "" {
+		profile, ok := o.profiles[o.profile]
+		if !ok {
+			names := make([]string, 0, len(o.profiles))
+			for name := range o.profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			problems = append(problems, Problem{Field: ProfileEnv, Message: fmt.Sprintf("unknown profile %q, must be one of %s", o.profile, strings.Join(names, ", "))})
+		}
+		for _, f := range fields {
+			raw, ok := profile[f.key]
+			if !ok {
+				continue
+			}
+			if err := setFromString(f.value, raw); err != nil {
+				return fmt.Errorf("configloader: invalid %s default for %s: %w", o.profile, f.label(), err)
+			}
+		}
+	}
+
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return err
+		}
+		byKey := make(map[string]field, len(fields))
+		for _, f := range fields {
+			if f.key != "" {
+				byKey[f.key] = f
+			}
+		}
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			f, ok := byKey[k]
+			if !ok {
+				problems = append(problems, Problem{Field: k, Message: "unknown key in " + path})
+				continue
+			}
+			if err := setFromFile(f.value, values[k]); err != nil {
+				problems = append(problems, Problem{Field: f.label(), Message: fmt.Sprintf("invalid value in %s: %v", path, err)})
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(f.env)
+		if !ok || raw == "" {
+			continue
+		}
+		if err := setFromString(f.value, raw); err != nil {
+			problems = append(problems, Problem{Field: f.label(), Message: fmt.Sprintf("invalid value %q: %v", raw, err)})
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+type field struct {
+	value        reflect.Value
+	key          string
+	env          string
+	defaultValue string
+	secret       bool
+}
+
+// label identifies the field in error messages by its environment variable
+// and file key.
+func (f field) label() string {
+	switch {
+	case f.env != "" && f.key != "":
+		return f.env + " (" + f.key + ")"
+	case f.env != "":
+		return f.env
+	default:
+		return f.key
+	}
+}
+
+// collectFields walks v and returns every settable leaf field. Struct fields
+// other than time.Duration are descended into with their key as prefix.
+func collectFields(v reflect.Value, prefix string) []field {
+	var fields []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		key := sf.Tag.Get("key")
+		if key != "" && prefix != "" {
+			key = prefix + "." + key
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && !isLeafType(fv.Type()) {
+			nestedPrefix := key
+			if nestedPrefix == "" {
+				nestedPrefix = prefix
+			}
+			fields = append(fields, collectFields(fv, nestedPrefix)...)
+			continue
+		}
+		if key == "" && sf.Tag.Get("env") == "" {
+			continue
+		}
+		fields = append(fields, field{
+			value:        fv,
+			key:          key,
+			env:          sf.Tag.Get("env"),
+			defaultValue: sf.Tag.Get("default"),
+			secret:       sf.Tag.Get("secret") == "true",
+		})
+	}
+	return fields
+}
+
+// isLeafType reports whether values of t are set as a whole rather than
+// descended into.
+func isLeafType(t reflect.Type) bool {
+	return t == durationType || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// readFile decodes a YAML or TOML file, chosen by extension, and flattens it
+// into dotted keys.
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q: use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	flat := map[string]any{}
+	flatten(flat, "", raw)
+	return flat, nil
+}
+
+func flatten(dst map[string]any, prefix string, src map[string]any) {
+	for k, v := range src {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]any); ok {
+			flatten(dst, key, nested)
+			continue
+		}
+		dst[key] = v
+	}
+}
+
+// setFromFile assigns a decoded file value. Lists are accepted for []string
+// fields; every other value is formatted and parsed like an environment
+// variable so both sources behave the same.
+func setFromFile(v reflect.Value, raw any) error {
+	if list, ok := raw.([]any); ok {
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("expected a single value, got a list")
+		}
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+		return nil
+	}
+	if raw == nil {
+		return nil
+	}
+	return setFromString(v, fmt.Sprint(raw))
+}
+
+func setFromString(v reflect.Value, raw string) error {
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported slice type %s", v.Type())
+		}
+		v.Set(reflect.ValueOf(SplitAndTrim(raw, ",")).Convert(v.Type()))
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// SplitAndTrim splits s on sep and drops empty, whitespace-only parts.
+func SplitAndTrim(s, sep string) []string {
+	var result []string
+	parts := strings.Split(s, sep)
+	for _, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		if trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
+
+// ResolveSecrets replaces the value of every non-empty string field tagged
+// `secret:"true"` with resolve's result, so those fields may hold a reference
+// to a secret store instead of the secret itself. Failures are collected and
+// returned as a *ValidationError.
+func ResolveSecrets(ctx context.Context, dst any, resolve func(context.Context, string) (string, error)) error {
+	var problems []Problem
+	for _, f := range collectFields(reflect.ValueOf(dst).Elem(), "") {
+		if !f.secret || f.value.Kind() != reflect.String || f.value.String() == "" {
+			continue
+		}
+		resolved, err := resolve(ctx, f.value.String())
+		if err != nil {
+			problems = append(problems, Problem{Field: f.label(), Message: err.Error()})
+			continue
+		}
+		f.value.SetString(resolved)
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package configloader_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/configloader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nested struct {
+	Enabled bool `key:"enabled" env:"TEST_NESTED_ENABLED"`
+}
+
+type testConfig struct {
+	Port    int           `key:"server.port" env:"TEST_PORT" default:"8080"`
+	Name    string        `key:"name" env:"TEST_NAME" default:"svc"`
+	Timeout time.Duration `key:"timeout" env:"TEST_TIMEOUT" default:"2s"`
+	Brokers []string      `key:"kafka.brokers" env:"TEST_BROKERS"`
+	Ratio   float64       `key:"ratio" env:"TEST_RATIO" default:"1.0"`
+	Nested  nested        `key:"nested"`
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	var cfg testConfig
+	require.NoError(t, configloader.Load(&cfg, ""))
+
+	assert.Equal(t, testConfig{Port: 8080, Name: "svc", Timeout: 2 * time.Second, Ratio: 1.0}, cfg)
+}
+
+func TestLoad_FileFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+server:
+  port: 9000
+timeout: 5s
+kafka:
+  brokers: [a:9092, b:9092]
+nested:
+  enabled: true
+`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `
+timeout = "5s"
+
+[server]
+port = 9000
+
+[kafka]
+brokers = "a:9092, b:9092"
+
+[nested]
+enabled = true
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg testConfig
+			require.NoError(t, configloader.Load(&cfg, writeFile(t, tt.file, tt.content)))
+
+			assert.Equal(t, 9000, cfg.Port)
+			assert.Equal(t, "svc", cfg.Name)
+			assert.Equal(t, 5*time.Second, cfg.Timeout)
+			assert.Equal(t, []string{"a:9092", "b:9092"}, cfg.Brokers)
+			assert.True(t, cfg.Nested.Enabled)
+		})
+	}
+}
+
+func TestLoad_EnvOverridesFile(t *testing.T) {
+	path := writeFile(t, "config.yaml", "server:\n  port: 9000\nname: from-file\n")
+	t.Setenv("TEST_PORT", "9100")
+	t.Setenv("TEST_BROKERS", "c:9092")
+
+	var cfg testConfig
+	require.NoError(t, configloader.Load(&cfg, path))
+
+	assert.Equal(t, 9100, cfg.Port)
+	assert.Equal(t, "from-file", cfg.Name)
+	assert.Equal(t, []string{"c:9092"}, cfg.Brokers)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	t.Run("unknown key", func(t *testing.T) {
+		var cfg testConfig
+		err := configloader.Load(&cfg, writeFile(t, "config.yaml", "server:\n  prot: 9000\n"))
+		assert.ErrorContains(t, err, "server.prot: unknown key")
+	})
+
+	t.Run("invalid env value", func(t *testing.T) {
+		t.Setenv("TEST_TIMEOUT", "soon")
+		var cfg testConfig
+		err := configloader.Load(&cfg, "")
+		assert.ErrorContains(t, err, `TEST_TIMEOUT (timeout): invalid value "soon"`)
+	})
+
+	t.Run("all problems reported", func(t *testing.T) {
+		t.Setenv("TEST_PORT", "eighty")
+		t.Setenv("TEST_RATIO", "half")
+		var cfg testConfig
+		err := configloader.Load(&cfg, writeFile(t, "config.yaml", "unknown: 1\n"))
+
+		var verr *configloader.ValidationError
+		require.ErrorAs(t, err, &verr)
+		assert.Len(t, verr.Problems, 3)
+		assert.Equal(t, 8080, cfg.Port, "fields that fail to parse keep their default")
+	})
+
+	t.Run("unsupported extension", func(t *testing.T) {
+		var cfg testConfig
+		err := configloader.Load(&cfg, writeFile(t, "config.json", "{}"))
+		assert.ErrorContains(t, err, "unsupported config file format")
+	})
+
+	t.Run("not a struct pointer", func(t *testing.T) {
+		err := configloader.Load(testConfig{}, "")
+		assert.Error(t, err)
+	})
+}
+
+func TestResolveSecrets(t *testing.T) {
+	type secretConfig struct {
+		DSN   string `env:"TEST_DSN" secret:"true"`
+		Token string `env:"TEST_TOKEN" secret:"true"`
+		Plain string `env:"TEST_PLAIN"`
+	}
+	cfg := secretConfig{DSN: "ref://dsn", Token: "ref://missing", Plain: "ref://dsn"}
+
+	err := configloader.ResolveSecrets(context.Background(), &cfg, func(_ context.Context, v string) (string, error) {
+		if v == "ref://dsn" {
+			return "resolved", nil
+		}
+		return "", errors.New("no such secret")
+	})
+
+	var verr *configloader.ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, []configloader.Problem{{Field: "TEST_TOKEN", Message: "no such secret"}}, verr.Problems)
+	assert.Equal(t, "resolved", cfg.DSN)
+	assert.Equal(t, "ref://dsn", cfg.Plain, "untagged fields are left alone")
+}
+
+func TestLoad_Profile(t *testing.T) {
+	profiles := configloader.Profiles{
+		"dev":  {"name": "dev-svc", "timeout": "10s"},
+		"prod": {"name": "prod-svc"},
+	}
+
+	t.Run("profile defaults sit between tag defaults and the file", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", "timeout: 3s\n")
+		var cfg testConfig
+		require.NoError(t, configloader.Load(&cfg, path, configloader.WithProfile(profiles, "dev")))
+
+		assert.Equal(t, "dev-svc", cfg.Name)
+		assert.Equal(t, 3*time.Second, cfg.Timeout)
+		assert.Equal(t, 8080, cfg.Port)
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		var cfg testConfig
+		err := configloader.Load(&cfg, "", configloader.WithProfile(profiles, "qa"))
+		assert.ErrorContains(t, err, `APP_ENV: unknown profile "qa", must be one of dev, prod`)
+	})
+}
+
+type level int
+
+func (l *level) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("must be low or high")
+	}
+	return nil
+}
+
+func TestLoad_TextUnmarshaler(t *testing.T) {
+	var cfg struct {
+		Level level `env:"TEST_LEVEL" default:"low"`
+	}
+	require.NoError(t, configloader.Load(&cfg, ""))
+	assert.Equal(t, level(1), cfg.Level)
+
+	t.Setenv("TEST_LEVEL", "extreme")
+	assert.ErrorContains(t, configloader.Load(&cfg, ""), "must be low or high")
+}
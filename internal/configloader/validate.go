@@ -0,0 +1,184 @@
+package configloader
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Problem is a single invalid configuration value.
+type Problem struct {
+	// Field names the offending setting, e.g. "SERVER_PORT (server.port)".
+	Field   string
+	Message string
+}
+
+// ValidationError reports every configuration problem found at startup.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		parts[i] = p.Field + ": " + p.Message
+	}
+	return fmt.Sprintf("invalid configuration (%d problems): %s", len(e.Problems), strings.Join(parts, "; "))
+}
+
+// Validator collects problems with a loaded config struct. Fields are passed
+// as pointers into the struct so problems can be reported under the same
+// environment variable and file key the value was loaded from. Only the first
+// problem per field is kept.
+type Validator struct {
+	fields   []field
+	problems []Problem
+	seen     map[string]bool
+}
+
+// NewValidator creates a Validator for cfg, which must be the pointer that was
+// passed to Load.
+func NewValidator(cfg any) *Validator {
+	return &Validator{
+		fields: collectFields(reflect.ValueOf(cfg).Elem(), ""),
+		seen:   map[string]bool{},
+	}
+}
+
+// Merge adds the problems from a *ValidationError returned by Load. Any other
+// non-nil error is returned unchanged, since it means loading itself failed.
+func (v *Validator) Merge(err error) error {
+	if err == nil {
+		return nil
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+	for _, p := range verr.Problems {
+		v.add(p.Field, p.Message)
+	}
+	return nil
+}
+
+// Err returns a *ValidationError listing every problem, or nil.
+func (v *Validator) Err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// Addf records a problem with the field ptr points to.
+func (v *Validator) Addf(ptr any, format string, args ...any) {
+	v.add(v.label(ptr), fmt.Sprintf(format, args...))
+}
+
+// Required checks that the field is not its zero value.
+func (v *Validator) Required(ptr any) {
+	if reflect.ValueOf(ptr).Elem().IsZero() {
+		v.Addf(ptr, "is required")
+	}
+}
+
+// Port checks that a port number is between 1 and 65535.
+func (v *Validator) Port(ptr *int) {
+	if *ptr < 1 || *ptr > 65535 {
+		v.Addf(ptr, "must be between 1 and 65535, got %d", *ptr)
+	}
+}
+
+// Positive checks that a duration is greater than zero.
+func (v *Validator) Positive(ptr *time.Duration) {
+	if *ptr <= 0 {
+		v.Addf(ptr, "must be a positive duration, got %s", *ptr)
+	}
+}
+
+// Ratio checks that a value is between 0 and 1 inclusive.
+func (v *Validator) Ratio(ptr *float64) {
+	if *ptr < 0 || *ptr > 1 {
+		v.Addf(ptr, "must be between 0 and 1, got %v", *ptr)
+	}
+}
+
+// OneOf checks that a string is one of allowed.
+func (v *Validator) OneOf(ptr *string, allowed ...string) {
+	if !slices.Contains(allowed, *ptr) {
+		v.Addf(ptr, "must be one of %s, got %q", strings.Join(allowed, ", "), *ptr)
+	}
+}
+
+// URL checks that a non-empty string is an absolute URL with one of schemes.
+// Empty values are left to Required.
+func (v *Validator) URL(ptr *string, schemes ...string) {
+	if *ptr == "" {
+		return
+	}
+	u, err := url.Parse(*ptr)
+	if err != nil {
+		v.Addf(ptr, "must be a valid URL: %v", err)
+		return
+	}
+	if !slices.Contains(schemes, u.Scheme) || u.Host == "" {
+		v.Addf(ptr, "must be an absolute %s URL", strings.Join(schemes, " or "))
+	}
+}
+
+// HostPort checks that a non-empty string is a host:port address.
+func (v *Validator) HostPort(ptr *string) {
+	if *ptr == "" {
+		return
+	}
+	if err := checkHostPort(*ptr); err != nil {
+		v.Addf(ptr, "%v", err)
+	}
+}
+
+// Brokers checks that every entry is a host:port address.
+func (v *Validator) Brokers(ptr *[]string) {
+	for _, addr := range *ptr {
+		if err := checkHostPort(addr); err != nil {
+			v.Addf(ptr, "%v", err)
+			return
+		}
+	}
+}
+
+func checkHostPort(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%q must be a host:port address", addr)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%q has an invalid port", addr)
+	}
+	return nil
+}
+
+func (v *Validator) add(label, message string) {
+	if v.seen[label] {
+		return
+	}
+	v.seen[label] = true
+	v.problems = append(v.problems, Problem{Field: label, Message: message})
+}
+
+// label finds the field ptr points to. Pointers outside the struct are a
+// programming error and are reported as such rather than panicking.
+func (v *Validator) label(ptr any) string {
+	p := reflect.ValueOf(ptr)
+	for _, f := range v.fields {
+		if f.value.Addr().Pointer() == p.Pointer() && f.value.Type() == p.Type().Elem() {
+			return f.label()
+		}
+	}
+	return fmt.Sprintf("<unknown field %T>", ptr)
+}
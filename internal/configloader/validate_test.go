@@ -0,0 +1,52 @@
+package configloader_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/configloader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator(t *testing.T) {
+	t.Setenv("TEST_TIMEOUT", "later")
+	cfg := &testConfig{}
+	v := configloader.NewValidator(cfg)
+	require.NoError(t, v.Merge(configloader.Load(cfg, "")))
+
+	cfg.Port = 70000
+	cfg.Brokers = []string{"kafka:9092", "no-port"}
+	cfg.Ratio = 1.5
+	cfg.Name = "ftp://example.com"
+
+	v.Port(&cfg.Port)
+	v.Required(&cfg.Brokers)
+	v.Brokers(&cfg.Brokers)
+	v.Ratio(&cfg.Ratio)
+	v.URL(&cfg.Name, "http", "https")
+	v.Positive(&cfg.Timeout)
+	v.Required(&cfg.Nested.Enabled)
+
+	var verr *configloader.ValidationError
+	require.ErrorAs(t, v.Err(), &verr)
+	assert.Equal(t, []configloader.Problem{
+		{Field: "TEST_TIMEOUT (timeout)", Message: `invalid value "later": time: invalid duration "later"`},
+		{Field: "TEST_PORT (server.port)", Message: "must be between 1 and 65535, got 70000"},
+		{Field: "TEST_BROKERS (kafka.brokers)", Message: `"no-port" must be a host:port address`},
+		{Field: "TEST_RATIO (ratio)", Message: "must be between 0 and 1, got 1.5"},
+		{Field: "TEST_NAME (name)", Message: "must be an absolute http or https URL"},
+		{Field: "TEST_NESTED_ENABLED (nested.enabled)", Message: "is required"},
+	}, verr.Problems)
+}
+
+func TestValidator_NoProblems(t *testing.T) {
+	cfg := &testConfig{}
+	v := configloader.NewValidator(cfg)
+	require.NoError(t, v.Merge(configloader.Load(cfg, "")))
+
+	v.Port(&cfg.Port)
+	v.Positive(&cfg.Timeout)
+	assert.Equal(t, 2*time.Second, cfg.Timeout)
+	assert.NoError(t, v.Err())
+}
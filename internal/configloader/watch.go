@@ -0,0 +1,50 @@
+package configloader
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Watch calls reload whenever the file at path changes, checked every
+// interval, or the process receives SIGHUP. With an empty path only SIGHUP
+// triggers a reload. Watch blocks until ctx is done.
+func Watch(ctx context.Context, path string, interval time.Duration, reload func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var poll <-chan time.Time
+	var last os.FileInfo
+	if path != "" {
+		last, _ = os.Stat(path)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reload()
+		case <-poll:
+			info, err := os.Stat(path)
+			if err != nil || !fileChanged(last, info) {
+				continue
+			}
+			last = info
+			reload()
+		}
+	}
+}
+
+func fileChanged(before, after os.FileInfo) bool {
+	if before == nil {
+		return true
+	}
+	return !after.ModTime().Equal(before.ModTime()) || after.Size() != before.Size()
+}
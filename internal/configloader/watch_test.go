@@ -0,0 +1,32 @@
+package configloader_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/configloader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch_FileChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("log:\n  level: info\n"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan struct{}, 1)
+	go configloader.Watch(ctx, path, 10*time.Millisecond, func() { reloaded <- struct{}{} })
+
+	// Let the watcher record the initial state before changing the file.
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte("log:\n  level: debug\n"), 0o600))
+
+	select {
+	case <-reloaded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("reload was not triggered by the file change")
+	}
+}
@@ -0,0 +1,134 @@
+package dlq
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// RetryConfig is how Kafka consumers retry a message their handler failed
+// on before dead-lettering it. Writes to the dead-letter topic are retried
+// with the same backoff until they succeed.
+type RetryConfig struct {
+	MaxAttempts    int           `key:"max_attempts" env:"KAFKA_CONSUME_MAX_ATTEMPTS" default:"3"`
+	InitialBackoff time.Duration `key:"initial_backoff" env:"KAFKA_CONSUME_INITIAL_BACKOFF" default:"200ms"`
+	MaxBackoff     time.Duration `key:"max_backoff" env:"KAFKA_CONSUME_MAX_BACKOFF" default:"5s"`
+}
+
+// DefaultRetry is used by consumers not given a RetryConfig.
+var DefaultRetry = RetryConfig{
+	MaxAttempts:    3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// backoff returns a random delay before the retry following attempt:
+// between half and all of InitialBackoff doubled attempt-1 times, capped at
+// MaxBackoff.
+func (c RetryConfig) backoff(attempt int) time.Duration {
+	d := c.InitialBackoff
+	for i := 1; i < attempt && d < c.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, c.MaxBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// Writer writes messages to Kafka. *kafka.Writer implements it.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// NewWriter returns the writer consumers dead-letter messages with. Every
+// replica must store a dead-lettered message before its offset is committed.
+func NewWriter(brokers []string, transport kafka.RoundTripper) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+		Transport:    transport,
+	}
+}
+
+// Message builds the message that dead-letters msg, which its consumer
+// failed to handle with err: the same key, value and headers, sent to the
+// dead-letter topic of msg's topic with the original topic, the error and
+// failedAt as headers. The dead-letter headers of an earlier failure of a
+// replayed message are replaced.
+func Message(msg kafka.Message, err error, failedAt time.Time) kafka.Message {
+	deadLetter := kafka.Message{Topic: events.DeadLetterTopic(msg.Topic), Key: msg.Key, Value: msg.Value}
+	for _, h := range msg.Headers {
+		switch h.Key {
+		case events.HeaderOriginalTopic, events.HeaderError, events.HeaderFailedAt:
+			continue
+		}
+		deadLetter.Headers = append(deadLetter.Headers, h)
+	}
+	deadLetter.Headers = append(deadLetter.Headers,
+		kafka.Header{Key: events.HeaderOriginalTopic, Value: []byte(msg.Topic)},
+		kafka.Header{Key: events.HeaderError, Value: []byte(err.Error())},
+		kafka.Header{Key: events.HeaderFailedAt, Value: []byte(failedAt.UTC().Format(time.RFC3339))},
+	)
+	return deadLetter
+}
+
+// Process passes msg to handle, trying again with backoff until cfg.MaxAttempts
+// attempts failed, and then dead-letters msg with w. It returns once msg is
+// handled or dead-lettered, so its offset may be committed, and reports
+// whether it was dead-lettered. If ctx ends first, Process returns ctx's
+// error and msg must be left uncommitted, to be delivered again.
+func Process(ctx context.Context, msg kafka.Message, cfg RetryConfig, w Writer, handle func(ctx context.Context) error) (bool, error) {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = handle(ctx); err == nil {
+			return false, nil
+		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if attempt >= cfg.MaxAttempts {
+			break
+		}
+		log.Ctx(ctx).Warn().Err(err).Int("attempt", attempt).Msg("Failed to handle message, retrying")
+		if !sleep(ctx, cfg.backoff(attempt)) {
+			return false, ctx.Err()
+		}
+	}
+
+	deadLetter := Message(msg, err, time.Now())
+	for attempt := 1; ; attempt++ {
+		writeErr := w.WriteMessages(ctx, deadLetter)
+		if writeErr == nil {
+			log.Ctx(ctx).Error().Err(err).Str("dead_letter_topic", deadLetter.Topic).Msg("Failed to handle message, dead-lettered it")
+			return true, nil
+		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		log.Ctx(ctx).Error().Err(writeErr).Str("dead_letter_topic", deadLetter.Topic).Int("attempt", attempt).
+			Msg("Failed to dead-letter message, retrying")
+		if !sleep(ctx, cfg.backoff(attempt)) {
+			return false, ctx.Err()
+		}
+	}
+}
+
+// sleep waits for d, reporting false if ctx ended first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
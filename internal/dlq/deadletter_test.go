@@ -0,0 +1,118 @@
+package dlq_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/dlq"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWriter records the messages written to it, failing the first failures
+// writes.
+type fakeWriter struct {
+	mu       sync.Mutex
+	failures int
+	written  []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("kafka: leader not available")
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+var testRetry = dlq.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+func TestMessage(t *testing.T) {
+	failedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	msg := kafka.Message{
+		Topic:     "orders.placed",
+		Partition: 2,
+		Offset:    41,
+		Key:       []byte("order-1"),
+		Value:     []byte(`{}`),
+		Headers: []kafka.Header{
+			{Key: "correlation_id", Value: []byte("abc")},
+			{Key: events.HeaderError, Value: []byte("an earlier failure")},
+		},
+	}
+
+	deadLetter := dlq.Message(msg, errors.New("db down"), failedAt)
+
+	assert.Equal(t, kafka.Message{
+		Topic: "orders.placed.dlq",
+		Key:   []byte("order-1"),
+		Value: []byte(`{}`),
+		Headers: []kafka.Header{
+			{Key: "correlation_id", Value: []byte("abc")},
+			{Key: events.HeaderOriginalTopic, Value: []byte("orders.placed")},
+			{Key: events.HeaderError, Value: []byte("db down")},
+			{Key: events.HeaderFailedAt, Value: []byte("2025-03-01T12:00:00Z")},
+		},
+	}, deadLetter)
+	topic, err := dlq.OriginalTopic(deadLetter, deadLetter.Topic)
+	require.NoError(t, err)
+	assert.Equal(t, "orders.placed", topic)
+	assert.Equal(t, failedAt, dlq.FailedAt(deadLetter))
+}
+
+func TestProcess(t *testing.T) {
+	msg := kafka.Message{Topic: "inventory.events", Key: []byte("order-1")}
+
+	t.Run("recovers from a transient failure", func(t *testing.T) {
+		w := &fakeWriter{}
+		attempts := 0
+		deadLettered, err := dlq.Process(context.Background(), msg, testRetry, w, func(context.Context) error {
+			attempts++
+			if attempts < 2 {
+				return errors.New("db down")
+			}
+			return nil
+		})
+
+		require.NoError(t, err)
+		assert.False(t, deadLettered)
+		assert.Equal(t, 2, attempts)
+		assert.Empty(t, w.written)
+	})
+
+	t.Run("dead-letters after the last attempt", func(t *testing.T) {
+		// Writing to the dead-letter topic is retried too.
+		w := &fakeWriter{failures: 2}
+		attempts := 0
+		deadLettered, err := dlq.Process(context.Background(), msg, testRetry, w, func(context.Context) error {
+			attempts++
+			return errors.New("db down")
+		})
+
+		require.NoError(t, err)
+		assert.True(t, deadLettered)
+		assert.Equal(t, 3, attempts)
+		require.Len(t, w.written, 1)
+		assert.Equal(t, "inventory.events.dlq", w.written[0].Topic)
+	})
+
+	t.Run("gives up when ctx ends", func(t *testing.T) {
+		w := &fakeWriter{failures: 1000}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		deadLettered, err := dlq.Process(ctx, msg, testRetry, w, func(context.Context) error {
+			return errors.New("db down")
+		})
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.False(t, deadLettered, "Expected the message to be left for redelivery")
+	})
+}
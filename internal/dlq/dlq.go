@@ -0,0 +1,277 @@
+// Package dlq moves the messages Kafka consumers cannot handle to dead-letter
+// topics and replays them back to the topics they came from. Dead-lettered
+// messages follow the contract in package events: they keep their key and
+// value and carry the original topic, error and failure time as headers.
+package dlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/segmentio/kafka-go"
+)
+
+// Filter selects the dead-lettered messages to replay. Zero-valued fields
+// match everything.
+type Filter struct {
+	// OrderID matches the message key, which is the order ID throughout the system.
+	OrderID uuid.UUID
+	// From and To bound the time the message was dead-lettered; To is exclusive.
+	From time.Time
+	To   time.Time
+}
+
+// Match reports whether msg passes the filter.
+func (f Filter) Match(msg kafka.Message) bool {
+	if f.OrderID != uuid.Nil && string(msg.Key) != f.OrderID.String() {
+		return false
+	}
+	failedAt := FailedAt(msg)
+	if !f.From.IsZero() && failedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !failedAt.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// FailedAt returns when msg was dead-lettered, falling back to its Kafka
+// timestamp when the header is missing or malformed.
+func FailedAt(msg kafka.Message) time.Time {
+	if v, ok := header(msg, events.HeaderFailedAt); ok {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+	}
+	return msg.Time
+}
+
+// OriginalTopic returns the topic msg should be replayed to: the
+// original_topic header, or dlqTopic without its dead-letter suffix.
+func OriginalTopic(msg kafka.Message, dlqTopic string) (string, error) {
+	if v, ok := header(msg, events.HeaderOriginalTopic); ok && v != "" {
+		return v, nil
+	}
+	if topic, ok := strings.CutSuffix(dlqTopic, events.DeadLetterSuffix); ok && topic != "" {
+		return topic, nil
+	}
+	return "", fmt.Errorf("message at offset %d of partition %d has no %s header", msg.Offset, msg.Partition, events.HeaderOriginalTopic)
+}
+
+// ReplayMessage builds the message republished for the dead-lettered msg: the
+// same key, value and headers (such as the correlation ID), minus the
+// dead-letter headers, plus replayed=true and replayed_from.
+func ReplayMessage(msg kafka.Message, dlqTopic, targetTopic string) kafka.Message {
+	replay := kafka.Message{Topic: targetTopic, Key: msg.Key, Value: msg.Value}
+	for _, h := range msg.Headers {
+		switch h.Key {
+		case events.HeaderOriginalTopic, events.HeaderError, events.HeaderFailedAt, events.HeaderReplayed, events.HeaderReplayedFrom:
+			continue
+		}
+		replay.Headers = append(replay.Headers, h)
+	}
+	replay.Headers = append(replay.Headers,
+		kafka.Header{Key: events.HeaderReplayed, Value: []byte("true")},
+		kafka.Header{Key: events.HeaderReplayedFrom, Value: []byte(dlqTopic)},
+	)
+	return replay
+}
+
+func header(msg kafka.Message, key string) (string, bool) {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
+
+// Options controls a replay.
+type Options struct {
+	Filter Filter
+	// TargetTopic overrides the topic messages are replayed to.
+	TargetTopic string
+	// Limit stops the replay after this many messages; zero means no limit.
+	Limit int
+	// DryRun reports the matching messages without republishing them.
+	DryRun bool
+	// OnMessage, if set, is called for every message selected for replay.
+	OnMessage func(msg kafka.Message, targetTopic string)
+}
+
+// Summary reports what a replay did.
+type Summary struct {
+	Scanned  int
+	Replayed int
+}
+
+// Replayer copies messages from a dead-letter topic back to their topics.
+type Replayer struct {
+	brokers []string
+	client  *kafka.Client
+	dialer  *kafka.Dialer
+	writer  *kafka.Writer
+}
+
+// NewReplayer creates a Replayer for the cluster at brokers.
+func NewReplayer(brokers []string, auth *kafkaauth.Auth) *Replayer {
+	return &Replayer{
+		brokers: brokers,
+		client: &kafka.Client{
+			Addr:      kafka.TCP(brokers...),
+			Timeout:   30 * time.Second,
+			Transport: auth.Transport(),
+		},
+		dialer: auth.Dialer(10 * time.Second),
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Balancer:     &kafka.LeastBytes{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: 10 * time.Millisecond,
+			Transport:    auth.Transport(),
+		},
+	}
+}
+
+// replayBatchSize is how many messages are republished per write.
+const replayBatchSize = 100
+
+// Replay reads dlqTopic from the beginning up to its current end and
+// republishes the messages matching opts.Filter. Messages stay in the
+// dead-letter topic, so running the same replay twice publishes them twice.
+func (r *Replayer) Replay(ctx context.Context, dlqTopic string, opts Options) (Summary, error) {
+	var summary Summary
+	bounds, err := r.partitionBounds(ctx, dlqTopic)
+	if err != nil {
+		return summary, err
+	}
+
+	for _, b := range bounds {
+		if b.FirstOffset >= b.LastOffset {
+			continue
+		}
+		done, err := r.replayPartition(ctx, dlqTopic, b, opts, &summary)
+		if err != nil {
+			return summary, fmt.Errorf("failed to replay partition %d of %s: %w", b.Partition, dlqTopic, err)
+		}
+		if done {
+			break
+		}
+	}
+	return summary, nil
+}
+
+// replayPartition replays one partition up to the end offset captured when
+// the replay started. It reports whether the limit was reached.
+func (r *Replayer) replayPartition(ctx context.Context, dlqTopic string, bounds kafka.PartitionOffsets, opts Options, summary *Summary) (bool, error) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   r.brokers,
+		Topic:     dlqTopic,
+		Partition: bounds.Partition,
+		MaxWait:   500 * time.Millisecond,
+		Dialer:    r.dialer,
+	})
+	defer reader.Close()
+	if err := reader.SetOffset(bounds.FirstOffset); err != nil {
+		return false, err
+	}
+
+	var batch []kafka.Message
+	flush := func() error {
+		if len(batch) == 0 || opts.DryRun {
+			batch = batch[:0]
+			return nil
+		}
+		if err := r.writer.WriteMessages(ctx, batch...); err != nil {
+			return fmt.Errorf("failed to republish messages: %w", err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		if opts.Limit > 0 && summary.Replayed >= opts.Limit {
+			return true, flush()
+		}
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return false, err
+		}
+		summary.Scanned++
+
+		if opts.Filter.Match(msg) {
+			target := opts.TargetTopic
+			if target == "" {
+				if target, err = OriginalTopic(msg, dlqTopic); err != nil {
+					return false, err
+				}
+			}
+			if opts.OnMessage != nil {
+				opts.OnMessage(msg, target)
+			}
+			batch = append(batch, ReplayMessage(msg, dlqTopic, target))
+			summary.Replayed++
+			if len(batch) >= replayBatchSize {
+				if err := flush(); err != nil {
+					return false, err
+				}
+			}
+		}
+
+		if msg.Offset >= bounds.LastOffset-1 {
+			return false, flush()
+		}
+	}
+}
+
+// partitionBounds returns the first and end offsets of every partition of topic.
+func (r *Replayer) partitionBounds(ctx context.Context, topic string) ([]kafka.PartitionOffsets, error) {
+	meta, err := r.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+	}
+	var requests []kafka.OffsetRequest
+	for _, t := range meta.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, fmt.Errorf("failed to fetch metadata for topic %s: %w", topic, t.Error)
+		}
+		for _, p := range t.Partitions {
+			requests = append(requests, kafka.FirstOffsetOf(p.ID), kafka.LastOffsetOf(p.ID))
+		}
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("topic %s does not exist", topic)
+	}
+
+	offsets, err := r.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{topic: requests},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets: %w", err)
+	}
+	var errs []error
+	for _, p := range offsets.Topics[topic] {
+		if p.Error != nil {
+			errs = append(errs, fmt.Errorf("partition %d: %w", p.Partition, p.Error))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("failed to list offsets: %w", err)
+	}
+	return offsets.Topics[topic], nil
+}
+
+// Close flushes and closes the producer.
+func (r *Replayer) Close() error {
+	return r.writer.Close()
+}
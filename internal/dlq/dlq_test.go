@@ -0,0 +1,72 @@
+package dlq_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/dlq"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterMatch(t *testing.T) {
+	orderID := uuid.New()
+	failedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	msg := kafka.Message{
+		Key:     []byte(orderID.String()),
+		Time:    failedAt.Add(time.Hour),
+		Headers: []kafka.Header{{Key: events.HeaderFailedAt, Value: []byte(failedAt.Format(time.RFC3339))}},
+	}
+
+	assert.True(t, dlq.Filter{}.Match(msg))
+	assert.True(t, dlq.Filter{OrderID: orderID}.Match(msg))
+	assert.False(t, dlq.Filter{OrderID: uuid.New()}.Match(msg))
+	assert.True(t, dlq.Filter{From: failedAt, To: failedAt.Add(time.Minute)}.Match(msg))
+	assert.False(t, dlq.Filter{To: failedAt}.Match(msg), "To is exclusive")
+	assert.False(t, dlq.Filter{From: failedAt.Add(time.Second)}.Match(msg))
+
+	msg.Headers = nil
+	assert.False(t, dlq.Filter{To: failedAt.Add(time.Minute)}.Match(msg), "falls back to the message timestamp")
+}
+
+func TestOriginalTopic(t *testing.T) {
+	withHeader := kafka.Message{Headers: []kafka.Header{{Key: events.HeaderOriginalTopic, Value: []byte("inventory.events")}}}
+	topic, err := dlq.OriginalTopic(withHeader, "orders.placed.dlq")
+	require.NoError(t, err)
+	assert.Equal(t, "inventory.events", topic)
+
+	topic, err = dlq.OriginalTopic(kafka.Message{}, events.DeadLetterTopic(events.TopicOrdersPlaced))
+	require.NoError(t, err)
+	assert.Equal(t, events.TopicOrdersPlaced, topic)
+
+	_, err = dlq.OriginalTopic(kafka.Message{}, "failures")
+	assert.Error(t, err)
+}
+
+func TestReplayMessage(t *testing.T) {
+	msg := kafka.Message{
+		Topic: "orders.placed.dlq",
+		Key:   []byte("order-1"),
+		Value: []byte(`{"order_id":"order-1"}`),
+		Headers: []kafka.Header{
+			{Key: "correlation_id", Value: []byte("abc")},
+			{Key: events.HeaderOriginalTopic, Value: []byte("orders.placed")},
+			{Key: events.HeaderError, Value: []byte("boom")},
+			{Key: events.HeaderFailedAt, Value: []byte("2025-03-01T12:00:00Z")},
+		},
+	}
+
+	replay := dlq.ReplayMessage(msg, "orders.placed.dlq", "orders.placed")
+
+	assert.Equal(t, "orders.placed", replay.Topic)
+	assert.Equal(t, msg.Key, replay.Key)
+	assert.Equal(t, msg.Value, replay.Value)
+	assert.Equal(t, []kafka.Header{
+		{Key: "correlation_id", Value: []byte("abc")},
+		{Key: events.HeaderReplayed, Value: []byte("true")},
+		{Key: events.HeaderReplayedFrom, Value: []byte("orders.placed.dlq")},
+	}, replay.Headers)
+}
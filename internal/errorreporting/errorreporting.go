@@ -0,0 +1,76 @@
+// Package errorreporting forwards panics and server errors to Sentry (or any
+// Sentry-compatible backend). Reporting is disabled unless a DSN is configured.
+package errorreporting
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Config controls the Sentry client.
+type Config struct {
+	DSN         string  `key:"dsn" env:"SENTRY_DSN" secret:"true"`
+	Environment string  `key:"environment" env:"SENTRY_ENVIRONMENT"`
+	Release     string  `key:"release" env:"SENTRY_RELEASE"`
+	SampleRate  float64 `key:"sample_rate" env:"SENTRY_SAMPLE_RATE" default:"1.0"`
+}
+
+// Init configures the global Sentry client. It returns a function that flushes
+// buffered events; call it before the process exits.
+func Init(serviceName string, cfg Config) (func(), error) {
+	if cfg.DSN == "" {
+		return func() {}, nil
+	}
+
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: cfg.Environment,
+		Release:     cfg.Release,
+		SampleRate:  cfg.SampleRate,
+		ServerName:  serviceName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialise error reporting: %w", err)
+	}
+	return func() { sentry.Flush(2 * time.Second) }, nil
+}
+
+// Enabled reports whether a Sentry client has been configured.
+func Enabled() bool {
+	return sentry.CurrentHub().Client() != nil
+}
+
+// CaptureError reports err with the given tags. It is a no-op when reporting
+// is disabled.
+func CaptureError(ctx context.Context, err error, tags map[string]string) {
+	if !Enabled() || err == nil {
+		return
+	}
+	hub := sentry.GetHubFromContext(ctx)
+	if hub == nil {
+		hub = sentry.CurrentHub().Clone()
+	}
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(tags)
+		hub.CaptureException(err)
+	})
+}
+
+// CapturePanic reports a recovered panic value with the given tags.
+func CapturePanic(ctx context.Context, recovered any, tags map[string]string) {
+	if !Enabled() {
+		return
+	}
+	hub := sentry.GetHubFromContext(ctx)
+	if hub == nil {
+		hub = sentry.CurrentHub().Clone()
+	}
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(tags)
+		scope.SetLevel(sentry.LevelFatal)
+		hub.RecoverWithContext(ctx, recovered)
+	})
+}
@@ -0,0 +1,211 @@
+// Package events defines the Kafka topics and message contracts shared by the
+// order, inventory, payment and shipping services. Both saga modes exchange the same payloads;
+// they only differ in which topic the inventory service listens on.
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// TopicOrdersPlaced carries OrderPlaced events published by the order service.
+	TopicOrdersPlaced = "orders.placed"
+	// TopicInventoryCommands carries ReserveInventory commands issued by the
+	// saga orchestrator. Only used in orchestration mode.
+	TopicInventoryCommands = "inventory.commands"
+	// TopicInventoryEvents carries InventoryEvent outcomes published by the
+	// inventory service.
+	TopicInventoryEvents = "inventory.events"
+	// TopicInventoryReleases carries ReleaseInventory commands issued by the
+	// order service to free the stock of orders that failed after it was
+	// reserved.
+	TopicInventoryReleases = "inventory.releases"
+	// TopicPaymentEvents carries PaymentEvent outcomes published by the
+	// payment service.
+	TopicPaymentEvents = "payment.events"
+	// TopicShippingEvents carries ShippingEvent updates published by the
+	// shipping service.
+	TopicShippingEvents = "shipping.events"
+	// TopicOrderStatusChanged carries OrderStatusChanged events published by
+	// the order service for notifiers to consume.
+	TopicOrderStatusChanged = "orders.status_changed"
+	// TopicOutboxProgress records the outbox messages each transactional
+	// outbox relay published, in the Kafka transaction publishing them.
+	TopicOutboxProgress = "outbox.progress"
+)
+
+// DeadLetterSuffix is appended to a topic's name to form the dead-letter topic
+// that receives the messages its consumers could not process.
+const DeadLetterSuffix = ".dlq"
+
+// DeadLetterTopic returns the dead-letter topic of topic.
+func DeadLetterTopic(topic string) string {
+	return topic + DeadLetterSuffix
+}
+
+// Kafka headers describing dead-lettered and replayed messages.
+const (
+	// HeaderOriginalTopic names the topic a dead-lettered message was read from.
+	HeaderOriginalTopic = "original_topic"
+	// HeaderError carries the processing error of a dead-lettered message.
+	HeaderError = "error"
+	// HeaderFailedAt is the RFC 3339 time the message was dead-lettered.
+	HeaderFailedAt = "failed_at"
+	// HeaderReplayed is "true" on messages republished by operator tooling
+	// rather than produced by the normal flow.
+	HeaderReplayed = "replayed"
+	// HeaderReplayedFrom names the topic a replayed message was copied from,
+	// or the store it was read back from.
+	HeaderReplayedFrom = "replayed_from"
+	// HeaderReplayGroup names the only consumer group a replayed message is
+	// meant for. Consumers in other groups skip it.
+	HeaderReplayGroup = "replay_group"
+)
+
+// FlowMode selects how the cross-service order flow is coordinated.
+type FlowMode string
+
+const (
+	// FlowModeChoreography lets the inventory service react to OrderPlaced
+	// events directly.
+	FlowModeChoreography FlowMode = "choreography"
+	// FlowModeOrchestration routes the flow through the order service's saga
+	// orchestrator, which sends ReserveInventory commands to the inventory service.
+	FlowModeOrchestration FlowMode = "orchestration"
+)
+
+// ParseFlowMode validates s and returns it as a FlowMode. An empty string
+// selects FlowModeChoreography.
+func ParseFlowMode(s string) (FlowMode, bool) {
+	switch FlowMode(s) {
+	case "", FlowModeChoreography:
+		return FlowModeChoreography, true
+	case FlowModeOrchestration:
+		return FlowModeOrchestration, true
+	default:
+		return "", false
+	}
+}
+
+// OrderItem is a line item carried in order events and commands.
+type OrderItem struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	UnitPrice float64   `json:"unit_price"`
+}
+
+// Location is a point on Earth in decimal degrees.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// OrderPlaced is published once an order has been persisted. Timestamp is
+// when the order was placed, in UTC. Destination is where the order ships,
+// when the producer knows it.
+type OrderPlaced struct {
+	OrderID     uuid.UUID   `json:"order_id"`
+	CustomerID  uuid.UUID   `json:"customer_id"`
+	TotalPrice  float64     `json:"total_price"`
+	Timestamp   time.Time   `json:"timestamp"`
+	Items       []OrderItem `json:"items"`
+	Destination *Location   `json:"destination,omitempty"`
+}
+
+// OrderPlacedType names OrderPlaced events in the outbox's type column.
+const OrderPlacedType = "OrderPlaced"
+
+// OrderStatusChanged is published once an order has moved from one status
+// to another, by the saga or an operator. Timestamp is when the order was
+// updated, in UTC.
+type OrderStatusChanged struct {
+	OrderID    uuid.UUID `json:"order_id"`
+	CustomerID uuid.UUID `json:"customer_id"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// ReserveInventory asks the inventory service to reserve stock for an order.
+// It carries the same payload as OrderPlaced.
+type ReserveInventory OrderPlaced
+
+// InventoryEventType identifies the outcome reported in an InventoryEvent.
+type InventoryEventType string
+
+const (
+	InventoryReserved          InventoryEventType = "inventory.reserved"
+	InventoryReservationFailed InventoryEventType = "inventory.reservation_failed"
+	// InventoryBackorderAllocated reports that stock arrived for a
+	// backordered line of an order that was reserved earlier.
+	InventoryBackorderAllocated InventoryEventType = "inventory.backorder_allocated"
+)
+
+// InventoryEvent reports the result of an inventory reservation. Timestamp
+// is when the reservation was decided, in UTC. A reservation that oversold
+// backorderable products is Backordered, with Items listing the lines
+// waiting for stock; a backorder allocation lists the allocated line.
+type InventoryEvent struct {
+	Type        InventoryEventType `json:"type"`
+	OrderID     uuid.UUID          `json:"order_id"`
+	Reason      string             `json:"reason,omitempty"`
+	Backordered bool               `json:"backordered,omitempty"`
+	Items       []OrderItem        `json:"items,omitempty"`
+	Timestamp   time.Time          `json:"timestamp"`
+}
+
+// ReleaseInventory asks the inventory service to free the stock reserved
+// for an order, such as one whose payment failed. Releasing an order that
+// holds no stock is a no-op. Timestamp is when the release was requested,
+// in UTC.
+type ReleaseInventory struct {
+	OrderID   uuid.UUID `json:"order_id"`
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// PaymentEventType identifies the outcome reported in a PaymentEvent.
+type PaymentEventType string
+
+const (
+	PaymentSucceeded PaymentEventType = "payment.succeeded"
+	PaymentFailed    PaymentEventType = "payment.failed"
+)
+
+// PaymentEvent reports the result of paying for an order. Amount is in the
+// minor units of Currency. Timestamp is when the payment was decided, in
+// UTC.
+type PaymentEvent struct {
+	Type      PaymentEventType `json:"type"`
+	OrderID   uuid.UUID        `json:"order_id"`
+	PaymentID uuid.UUID        `json:"payment_id"`
+	Amount    int64            `json:"amount"`
+	Currency  string           `json:"currency"`
+	Reason    string           `json:"reason,omitempty"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+// ShippingEventType identifies the update reported in a ShippingEvent.
+type ShippingEventType string
+
+const (
+	ShippingDelivered ShippingEventType = "shipping.delivered"
+	// ShippingException reports a shipment the carrier couldn't deliver as
+	// planned, such as a wrong address or a damaged parcel.
+	ShippingException ShippingEventType = "shipping.exception"
+)
+
+// ShippingEvent reports a carrier's tracking update for an order's
+// shipment. Reason describes an exception. Timestamp is when the carrier
+// recorded the update, in UTC.
+type ShippingEvent struct {
+	Type           ShippingEventType `json:"type"`
+	OrderID        uuid.UUID         `json:"order_id"`
+	ShipmentID     uuid.UUID         `json:"shipment_id"`
+	Carrier        string            `json:"carrier"`
+	TrackingNumber string            `json:"tracking_number"`
+	Reason         string            `json:"reason,omitempty"`
+	Timestamp      time.Time         `json:"timestamp"`
+}
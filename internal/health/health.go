@@ -0,0 +1,150 @@
+// Package health implements liveness and readiness probes with pluggable
+// dependency checks.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check verifies that a dependency is reachable.
+type Check func(ctx context.Context) error
+
+// Probe aggregates dependency checks for readiness and tracks shutdown state.
+type Probe struct {
+	mu           sync.RWMutex
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+// NewProbe creates an empty Probe.
+func NewProbe() *Probe {
+	return &Probe{checks: make(map[string]Check)}
+}
+
+// AddCheck registers a named readiness check.
+func (p *Probe) AddCheck(name string, check Check) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.checks[name] = check
+}
+
+// SetShuttingDown marks the process as draining; readiness fails from then on
+// so load balancers stop routing new traffic before the server closes.
+func (p *Probe) SetShuttingDown() {
+	p.shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether SetShuttingDown has been called.
+func (p *Probe) ShuttingDown() bool {
+	return p.shuttingDown.Load()
+}
+
+// Result is the outcome of a readiness evaluation.
+type Result struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Ready runs every check concurrently and reports per-dependency status.
+func (p *Probe) Ready(ctx context.Context) Result {
+	if p.ShuttingDown() {
+		return Result{Ready: false, Checks: map[string]string{"shutdown": "in progress"}}
+	}
+
+	p.mu.RLock()
+	names := make([]string, 0, len(p.checks))
+	for name := range p.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = p.checks[name]
+	}
+	p.mu.RUnlock()
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			errs[i] = check(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	result := Result{Ready: true, Checks: make(map[string]string, len(names))}
+	for i, name := range names {
+		if errs[i] != nil {
+			result.Ready = false
+			result.Checks[name] = errs[i].Error()
+			continue
+		}
+		result.Checks[name] = "ok"
+	}
+	return result
+}
+
+// LivenessHandler reports that the process is running. It does not check
+// dependencies. Services without a gin router serve it with net/http.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+// ReadinessHandler runs the checks of p, for at most timeout, and answers
+// 503 Service Unavailable unless every one passes.
+func (p *Probe) ReadinessHandler(timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		result := p.Ready(ctx)
+		status := http.StatusOK
+		if !result.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, result)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// DatabaseCheck pings db.
+func DatabaseCheck(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// KafkaCheck succeeds if at least one of brokers accepts a TCP connection.
+func KafkaCheck(brokers []string, timeout time.Duration) Check {
+	return func(ctx context.Context) error {
+		dialer := net.Dialer{Timeout: timeout}
+		var lastErr error
+		for _, broker := range brokers {
+			conn, err := dialer.DialContext(ctx, "tcp", broker)
+			if err == nil {
+				conn.Close()
+				return nil
+			}
+			lastErr = err
+		}
+		return fmt.Errorf("no kafka broker reachable: %w", lastErr)
+	}
+}
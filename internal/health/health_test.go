@@ -0,0 +1,78 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbe_Ready(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("all checks pass", func(t *testing.T) {
+		probe := health.NewProbe()
+		probe.AddCheck("database", func(context.Context) error { return nil })
+
+		result := probe.Ready(ctx)
+
+		assert.True(t, result.Ready)
+		assert.Equal(t, "ok", result.Checks["database"])
+	})
+
+	t.Run("failing check is reported", func(t *testing.T) {
+		probe := health.NewProbe()
+		probe.AddCheck("database", func(context.Context) error { return nil })
+		probe.AddCheck("kafka", func(context.Context) error { return errors.New("connection refused") })
+
+		result := probe.Ready(ctx)
+
+		assert.False(t, result.Ready)
+		assert.Equal(t, "ok", result.Checks["database"])
+		assert.Equal(t, "connection refused", result.Checks["kafka"])
+	})
+
+	t.Run("not ready while shutting down", func(t *testing.T) {
+		probe := health.NewProbe()
+		probe.AddCheck("database", func(context.Context) error { return nil })
+		probe.SetShuttingDown()
+
+		result := probe.Ready(ctx)
+
+		assert.False(t, result.Ready)
+	})
+}
+
+func TestReadinessHandler(t *testing.T) {
+	probe := health.NewProbe()
+	probe.AddCheck("database", func(context.Context) error { return nil })
+	handler := probe.ReadinessHandler(time.Second)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// A check still running when the timeout expires fails.
+	probe = health.NewProbe()
+	probe.AddCheck("kafka", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	rec = httptest.NewRecorder()
+	probe.ReadinessHandler(10*time.Millisecond).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var result health.Result
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, context.DeadlineExceeded.Error(), result.Checks["kafka"])
+
+	rec = httptest.NewRecorder()
+	health.LivenessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status": "ok"}`, rec.Body.String())
+}
@@ -0,0 +1,5 @@
+// Package inventorypb holds the generated code of the inventory service's
+// gRPC query API, defined in inventory.proto.
+package inventorypb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative inventory.proto
@@ -0,0 +1,109 @@
+// Package backorder allocates arriving stock to the inventory service's
+// backorders, in the order they were placed, and reports each allocation.
+package backorder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/rs/zerolog/log"
+)
+
+// batchSize bounds the pending backorders considered per pass.
+const batchSize = 500
+
+// Store is the part of stock.PostgresStore the allocator uses.
+type Store interface {
+	PendingBackorders(ctx context.Context, limit int) ([]stock.Backorder, error)
+	AllocateBackorder(ctx context.Context, b stock.Backorder) (stock.Backorder, bool, error)
+}
+
+// Allocator reserves stock for pending backorders as it becomes free and
+// publishes an inventory.backorder_allocated event for each.
+type Allocator struct {
+	store     Store
+	publisher broker.EventPublisher
+}
+
+// NewAllocator creates an Allocator publishing allocations with publisher.
+func NewAllocator(store Store, publisher broker.EventPublisher) *Allocator {
+	return &Allocator{store: store, publisher: publisher}
+}
+
+// Run allocates backorders every interval until ctx is cancelled.
+func (a *Allocator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := a.Allocate(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to allocate backorders")
+			} else if n > 0 {
+				log.Info().Int("allocated", n).Msg("Allocated backorders")
+			}
+		}
+	}
+}
+
+// Allocate makes one pass over the pending backorders and returns how many
+// were allocated. Once a backorder of a product cannot be allocated, later
+// backorders of that product wait for the next pass, so stock goes to the
+// earliest orders first.
+func (a *Allocator) Allocate(ctx context.Context) (int, error) {
+	pending, err := a.store.PendingBackorders(ctx, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	waiting := map[uuid.UUID]bool{}
+	allocated := 0
+	for _, b := range pending {
+		if waiting[b.ProductID] {
+			continue
+		}
+		done, ok, err := a.store.AllocateBackorder(ctx, b)
+		if err != nil {
+			return allocated, err
+		}
+		if !ok {
+			waiting[b.ProductID] = true
+			continue
+		}
+		allocated++
+		if err := a.report(ctx, done); err != nil {
+			return allocated, err
+		}
+	}
+	return allocated, nil
+}
+
+// report publishes the allocation of b.
+func (a *Allocator) report(ctx context.Context, b stock.Backorder) error {
+	value, err := json.Marshal(events.InventoryEvent{
+		Type:      events.InventoryBackorderAllocated,
+		OrderID:   b.OrderID,
+		Items:     []events.OrderItem{{ProductID: b.ProductID, Quantity: b.Quantity}},
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal backorder allocation for order %s: %w", b.OrderID, err)
+	}
+	if err := a.publisher.PublishMessage(ctx, []byte(b.OrderID.String()), value); err != nil {
+		return fmt.Errorf("failed to publish backorder allocation for order %s: %w", b.OrderID, err)
+	}
+	log.Ctx(ctx).Info().
+		Str("order_id", b.OrderID.String()).
+		Str("product_id", b.ProductID.String()).
+		Str("warehouse_id", b.WarehouseID).
+		Int("quantity", b.Quantity).
+		Msg("Backorder allocated")
+	return nil
+}
@@ -0,0 +1,75 @@
+package backorder_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/backorder"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore allocates backorders from free stock per product.
+type memoryStore struct {
+	pending []stock.Backorder
+	free    map[uuid.UUID]int
+	tried   []int64
+}
+
+func (s *memoryStore) PendingBackorders(context.Context, int) ([]stock.Backorder, error) {
+	return s.pending, nil
+}
+
+func (s *memoryStore) AllocateBackorder(_ context.Context, b stock.Backorder) (stock.Backorder, bool, error) {
+	s.tried = append(s.tried, b.ID)
+	if s.free[b.ProductID] < b.Quantity {
+		return stock.Backorder{}, false, nil
+	}
+	s.free[b.ProductID] -= b.Quantity
+	b.Status, b.WarehouseID = stock.BackorderAllocated, "paris"
+	return b, true, nil
+}
+
+// recordingPublisher records the messages published with it.
+type recordingPublisher struct {
+	values [][]byte
+}
+
+func (p *recordingPublisher) PublishMessage(_ context.Context, _, value []byte) error {
+	p.values = append(p.values, value)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func TestAllocator_Allocate(t *testing.T) {
+	mug, lamp := uuid.New(), uuid.New()
+	orders := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	store := &memoryStore{
+		pending: []stock.Backorder{
+			{ID: 1, OrderID: orders[0], ProductID: mug, Quantity: 2},
+			{ID: 2, OrderID: orders[1], ProductID: lamp, Quantity: 5},
+			{ID: 3, OrderID: orders[2], ProductID: lamp, Quantity: 1},
+			{ID: 4, OrderID: orders[3], ProductID: mug, Quantity: 1},
+		},
+		free: map[uuid.UUID]int{mug: 3, lamp: 1},
+	}
+	publisher := &recordingPublisher{}
+
+	n, err := backorder.NewAllocator(store, publisher).Allocate(context.Background())
+	require.NoError(t, err)
+
+	// The single free lamp waits for the earlier backorder of five.
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []int64{1, 2, 4}, store.tried)
+	require.Len(t, publisher.values, 2)
+	var event events.InventoryEvent
+	require.NoError(t, json.Unmarshal(publisher.values[0], &event))
+	assert.Equal(t, events.InventoryBackorderAllocated, event.Type)
+	assert.Equal(t, orders[0], event.OrderID)
+	assert.Equal(t, []events.OrderItem{{ProductID: mug, Quantity: 2}}, event.Items)
+}
@@ -1,49 +1,272 @@
 package config
 
 import (
-	"errors"
+	"context"
 	"os"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/amqpbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/configloader"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/dlq"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/natsbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/pubsubbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/secrets"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/snssqs"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/rs/zerolog"
+	"github.com/segmentio/kafka-go"
 )
 
 type Config struct {
-	KafkaBrokers []string
-	KafkaTopic   string
-	KafkaGroupID string
+	// Environment is the profile selected by APP_ENV, or empty for none.
+	Environment string
+
+	// MessageBroker selects the broker carrying events: kafka, nats,
+	// rabbitmq, sns-sqs or pubsub. The topic and group settings below apply
+	// to each.
+	MessageBroker string `key:"broker" env:"MESSAGE_BROKER" default:"kafka"`
+	// NATS locates the NATS servers when MessageBroker is nats.
+	NATS natsbroker.Config `key:"nats"`
+	// RabbitMQ locates the RabbitMQ server when MessageBroker is rabbitmq.
+	RabbitMQ amqpbroker.Config `key:"rabbitmq"`
+	// SNSSQS selects the AWS region and resources when MessageBroker is
+	// sns-sqs.
+	SNSSQS snssqs.Config `key:"sns_sqs"`
+	// PubSub selects the Google Cloud project when MessageBroker is pubsub.
+	PubSub pubsubbroker.Config `key:"pubsub"`
+
+	KafkaBrokers []string `key:"kafka.brokers" env:"KAFKA_BROKERS"`
+	// KafkaTopic is the input topic. When unset it follows FlowMode.
+	KafkaTopic   string `key:"kafka.topic" env:"KAFKA_TOPIC"`
+	KafkaGroupID string `key:"kafka.group_id" env:"KAFKA_GROUP_ID" default:"inventory-service-group"`
+	// KafkaAuth configures SASL and TLS for every Kafka client.
+	KafkaAuth kafkaauth.Config `key:"kafka"`
+	// KafkaRequiredAcks is none, one or all; see kafka.RequiredAcks.
+	KafkaRequiredAcks kafka.RequiredAcks `key:"kafka.required_acks" env:"KAFKA_REQUIRED_ACKS" default:"one"`
+
+	// ConsumerBatchSize is how many messages the consumer handles before
+	// committing their offsets together; ConsumerBatchLinger is the longest a
+	// partial batch waits before being committed.
+	ConsumerBatchSize   int           `key:"kafka.consumer.batch_size" env:"KAFKA_CONSUMER_BATCH_SIZE" default:"100"`
+	ConsumerBatchLinger time.Duration `key:"kafka.consumer.batch_linger" env:"KAFKA_CONSUMER_BATCH_LINGER" default:"100ms"`
+	// ConsumeRetry sets how the consumers retry messages their handler
+	// fails on before dead-lettering them.
+	ConsumeRetry dlq.RetryConfig `key:"kafka.consume_retry"`
+
+	// FlowMode decides whether the service reacts to OrderPlaced events
+	// (choreography) or to ReserveInventory commands (orchestration).
+	FlowMode events.FlowMode `key:"saga.mode" env:"SAGA_MODE" default:"choreography"`
+	// EventsTopic is where reservation outcomes are published.
+	EventsTopic string `key:"kafka.events_topic" env:"INVENTORY_EVENTS_TOPIC" default:"inventory.events"`
+
+	// DatabaseURL is the inventory database, migrated with
+	// cmd/inventoryservice/migrate. It must not be the order service's
+	// database, since both track their schema version in schema_migrations.
+	DatabaseURL string `key:"database.url" env:"INVENTORY_DATABASE_URL" secret:"true"`
+	// Stock selects how reservations are spread over the warehouses. Stock
+	// is only tracked when DatabaseURL is set; otherwise every reservation
+	// succeeds.
+	Stock stock.Config `key:"stock"`
+
+	// MetricsPort is the port serving Prometheus metrics at /metrics, the
+	// liveness and readiness probes at /healthz and /readyz, and the admin
+	// endpoints.
+	MetricsPort int `key:"metrics.port" env:"METRICS_PORT" default:"9091"`
+	// GRPCPort is the port serving the gRPC query API of stock availability
+	// and reservations, and the gRPC health service. It is only served when
+	// DatabaseURL is set.
+	GRPCPort int `key:"grpc.port" env:"GRPC_PORT" default:"9092"`
+	// LagPollInterval is how often consumer lag is measured.
+	LagPollInterval time.Duration `key:"metrics.lag_poll_interval" env:"LAG_POLL_INTERVAL" default:"15s"`
+
+	// ShutdownTimeout bounds how long shutdown waits for the message in
+	// flight to be processed and the pending batch committed. A Kafka
+	// consumer gives up on a message still in flight at the deadline and
+	// leaves it uncommitted, so it is redelivered after the restart.
+	ShutdownTimeout time.Duration `key:"shutdown.timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"`
+
+	// LogLevel is the initial zerolog level; it can be changed at runtime
+	// through the admin log-level endpoint.
+	LogLevel string `key:"log.level" env:"LOG_LEVEL" default:"info"`
+	// LogFormat is console for human-readable lines or json for one JSON
+	// object per line; the staging and prod profiles default to json.
+	LogFormat string `key:"log.format" env:"LOG_FORMAT" default:"console"`
+	// AdminToken guards the admin endpoints. They are disabled when empty.
+	AdminToken string `key:"admin.token" env:"ADMIN_TOKEN" secret:"true"`
+
+	// Tracing configures OpenTelemetry export; see the tracing package.
+	Tracing tracing.Config `key:"tracing"`
+
+	// PprofEnabled mounts net/http/pprof and expvar on PprofAddr, which
+	// should be bound to an internal-only interface.
+	PprofEnabled bool   `key:"pprof.enabled" env:"PPROF_ENABLED" default:"false"`
+	PprofAddr    string `key:"pprof.addr" env:"PPROF_ADDR" default:"127.0.0.1:6061"`
 }
 
+// profiles holds the environment-specific defaults selected by APP_ENV. Dev
+// favours verbosity and fast feedback; staging and prod favour durability.
+var profiles = configloader.Profiles{
+	"dev": {
+		"log.level":           "debug",
+		"kafka.required_acks": "one",
+	},
+	"staging": {
+		"log.format":           "json",
+		"log.level":            "info",
+		"kafka.required_acks":  "all",
+		"tracing.sample_ratio": "0.5",
+	},
+	"prod": {
+		"log.format":           "json",
+		"log.level":            "info",
+		"kafka.required_acks":  "all",
+		"tracing.sample_ratio": "0.1",
+	},
+}
+
+// LoadConfig reads the inventory service configuration from the file named by
+// CONFIG_FILE, if any, on top of the APP_ENV profile defaults, with environment
+// variables taking precedence. Every invalid setting is reported in the
+// returned error.
 func LoadConfig() (*Config, error) {
-	kafkaBrokersStr := os.Getenv("KAFKA_BROKERS")
-	if kafkaBrokersStr == "" {
-		return nil, errors.New("KAFKA_BROKERS environment variable is not set")
+	cfg := &Config{Environment: os.Getenv(configloader.ProfileEnv)}
+	v := configloader.NewValidator(cfg)
+	profile := configloader.WithProfile(profiles, cfg.Environment)
+	if err := v.Merge(configloader.Load(cfg, os.Getenv(configloader.FileEnv), profile)); err != nil {
+		return nil, err
 	}
-	kafkaBrokers := splitAndTrim(kafkaBrokersStr, ",")
 
-	kafkaTopic := os.Getenv("KAFKA_TOPIC")
-	if kafkaTopic == "" {
-		return nil, errors.New("KAFKA_TOPIC environment variable is not set")
+	// Secrets may be references to Vault or AWS Secrets Manager.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	resolver, err := secrets.NewResolverFromEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.Merge(configloader.ResolveSecrets(ctx, cfg, resolver.Resolve)); err != nil {
+		return nil, err
 	}
 
-	kafkaGroupID := os.Getenv("KAFKA_GROUP_ID")
-	if kafkaGroupID == "" {
-		kafkaGroupID = "inventory-service-group"
+	v.OneOf(&cfg.MessageBroker, broker.Kinds...)
+	switch cfg.MessageBroker {
+	case broker.Kafka:
+		v.Required(&cfg.KafkaBrokers)
+		v.Brokers(&cfg.KafkaBrokers)
+		if cfg.KafkaAuth.SASLMechanism != "" {
+			cfg.KafkaAuth.SASLMechanism = strings.ToUpper(cfg.KafkaAuth.SASLMechanism)
+			v.OneOf(&cfg.KafkaAuth.SASLMechanism, kafkaauth.MechanismPlain, kafkaauth.MechanismSCRAMSHA256, kafkaauth.MechanismSCRAMSHA512)
+			v.Required(&cfg.KafkaAuth.SASLUsername)
+			v.Required(&cfg.KafkaAuth.SASLPassword)
+		}
+	case broker.NATS:
+		v.Required(&cfg.NATS.URL)
+		if cfg.NATS.StreamReplicas < 1 || cfg.NATS.StreamReplicas > 5 {
+			v.Addf(&cfg.NATS.StreamReplicas, "must be between 1 and 5, got %d", cfg.NATS.StreamReplicas)
+		}
+		v.Positive(&cfg.NATS.StreamMaxAge)
+		v.Positive(&cfg.NATS.AckWait)
+	case broker.RabbitMQ:
+		v.Required(&cfg.RabbitMQ.URL)
+		v.URL(&cfg.RabbitMQ.URL, "amqp", "amqps")
+		v.Required(&cfg.RabbitMQ.Exchange)
+		v.Required(&cfg.RabbitMQ.DeadLetterExchange)
+		if cfg.RabbitMQ.DeadLetterExchange == cfg.RabbitMQ.Exchange {
+			v.Addf(&cfg.RabbitMQ.DeadLetterExchange, "must differ from RABBITMQ_EXCHANGE (%s)", cfg.RabbitMQ.Exchange)
+		}
+		if cfg.RabbitMQ.Prefetch < 1 {
+			v.Addf(&cfg.RabbitMQ.Prefetch, "must be at least 1, got %d", cfg.RabbitMQ.Prefetch)
+		}
+	case broker.SNSSQS:
+		v.URL(&cfg.SNSSQS.Endpoint, "http", "https")
+		if cfg.SNSSQS.VisibilityTimeout < time.Second || cfg.SNSSQS.VisibilityTimeout > 12*time.Hour {
+			v.Addf(&cfg.SNSSQS.VisibilityTimeout, "must be between 1s and 12h, got %s", cfg.SNSSQS.VisibilityTimeout)
+		}
+		if cfg.SNSSQS.WaitTime < 0 || cfg.SNSSQS.WaitTime > 20*time.Second {
+			v.Addf(&cfg.SNSSQS.WaitTime, "must be between 0s and 20s, got %s", cfg.SNSSQS.WaitTime)
+		}
+		if cfg.SNSSQS.MaxMessages < 1 || cfg.SNSSQS.MaxMessages > 10 {
+			v.Addf(&cfg.SNSSQS.MaxMessages, "must be between 1 and 10, got %d", cfg.SNSSQS.MaxMessages)
+		}
+		if cfg.SNSSQS.MaxReceiveCount < 1 || cfg.SNSSQS.MaxReceiveCount > 1000 {
+			v.Addf(&cfg.SNSSQS.MaxReceiveCount, "must be between 1 and 1000, got %d", cfg.SNSSQS.MaxReceiveCount)
+		}
+	case broker.PubSub:
+		v.Required(&cfg.PubSub.ProjectID)
+		if cfg.PubSub.AckDeadline < 10*time.Second || cfg.PubSub.AckDeadline > 10*time.Minute {
+			v.Addf(&cfg.PubSub.AckDeadline, "must be between 10s and 10m, got %s", cfg.PubSub.AckDeadline)
+		}
+		if cfg.PubSub.MaxDeliveryAttempts < 5 || cfg.PubSub.MaxDeliveryAttempts > 100 {
+			v.Addf(&cfg.PubSub.MaxDeliveryAttempts, "must be between 5 and 100, got %d", cfg.PubSub.MaxDeliveryAttempts)
+		}
+		if cfg.PubSub.MaxOutstandingMessages < 1 {
+			v.Addf(&cfg.PubSub.MaxOutstandingMessages, "must be at least 1, got %d", cfg.PubSub.MaxOutstandingMessages)
+		}
+	}
+	v.Required(&cfg.KafkaGroupID)
+	v.Required(&cfg.EventsTopic)
+	if cfg.ConsumerBatchSize < 1 {
+		v.Addf(&cfg.ConsumerBatchSize, "must be at least 1, got %d", cfg.ConsumerBatchSize)
+	}
+	if cfg.ConsumerBatchLinger < 0 {
+		v.Addf(&cfg.ConsumerBatchLinger, "must not be negative, got %s", cfg.ConsumerBatchLinger)
+	}
+	if cfg.ConsumeRetry.MaxAttempts < 1 {
+		v.Addf(&cfg.ConsumeRetry.MaxAttempts, "must be at least 1, got %d", cfg.ConsumeRetry.MaxAttempts)
+	}
+	v.Positive(&cfg.ConsumeRetry.InitialBackoff)
+	if cfg.ConsumeRetry.MaxBackoff < cfg.ConsumeRetry.InitialBackoff {
+		v.Addf(&cfg.ConsumeRetry.MaxBackoff, "must be at least KAFKA_CONSUME_INITIAL_BACKOFF (%s), got %s", cfg.ConsumeRetry.InitialBackoff, cfg.ConsumeRetry.MaxBackoff)
 	}
 
-	return &Config{
-		KafkaBrokers: kafkaBrokers,
-		KafkaTopic:   kafkaTopic,
-		KafkaGroupID: kafkaGroupID,
-	}, nil
-}
+	if flowMode, ok := events.ParseFlowMode(string(cfg.FlowMode)); ok {
+		cfg.FlowMode = flowMode
+	} else {
+		v.Addf(&cfg.FlowMode, "must be choreography or orchestration, got %q", cfg.FlowMode)
+	}
 
-func splitAndTrim(s, sep string) []string {
-	var result []string
-	parts := strings.Split(s, sep)
-	for _, p := range parts {
-		trimmed := strings.TrimSpace(p)
-		if trimmed != "" {
-			result = append(result, trimmed)
+	// The input topic follows the flow mode unless explicitly overridden.
+	if cfg.KafkaTopic == "" {
+		cfg.KafkaTopic = events.TopicOrdersPlaced
+		if cfg.FlowMode == events.FlowModeOrchestration {
+			cfg.KafkaTopic = events.TopicInventoryCommands
 		}
 	}
-	return result
+
+	if cfg.DatabaseURL != "" {
+		v.URL(&cfg.DatabaseURL, "postgres", "postgresql")
+	}
+	if !slices.Contains(stock.Strategies, string(cfg.Stock.Strategy)) {
+		v.Addf(&cfg.Stock.Strategy, "must be one of %s, got %q", strings.Join(stock.Strategies, ", "), cfg.Stock.Strategy)
+	}
+
+	v.Positive(&cfg.Stock.BackorderInterval)
+	v.Positive(&cfg.Stock.SnapshotInterval)
+	if cfg.Stock.SnapshotRetention < 0 {
+		v.Addf(&cfg.Stock.SnapshotRetention, "must not be negative, got %s", cfg.Stock.SnapshotRetention)
+	}
+	v.Port(&cfg.MetricsPort)
+	v.Port(&cfg.GRPCPort)
+	v.Positive(&cfg.LagPollInterval)
+	v.Positive(&cfg.ShutdownTimeout)
+
+	if _, err := zerolog.ParseLevel(cfg.LogLevel); err != nil {
+		v.Addf(&cfg.LogLevel, "%v", err)
+	}
+	v.OneOf(&cfg.LogFormat, logging.Formats...)
+	v.Ratio(&cfg.Tracing.SampleRatio)
+	if cfg.PprofEnabled {
+		v.HostPort(&cfg.PprofAddr)
+	}
+
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
@@ -0,0 +1,171 @@
+// Package grpcserver serves the inventory service's gRPC query API, defined
+// in internal/inventorypb, from the stock store.
+package grpcserver
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventorypb"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxProducts bounds the products a single request may query.
+const maxProducts = 1000
+
+// Store is the part of stock.PostgresStore the query API reads.
+type Store interface {
+	StockLevels(ctx context.Context, productIDs []uuid.UUID) ([]stock.Level, error)
+	Reservations(ctx context.Context, orderID uuid.UUID) ([]stock.Reservation, error)
+	BackorderableProducts(ctx context.Context) ([]uuid.UUID, error)
+}
+
+// Server implements inventorypb.InventoryServiceServer.
+type Server struct {
+	inventorypb.UnimplementedInventoryServiceServer
+	store Store
+}
+
+var _ inventorypb.InventoryServiceServer = (*Server)(nil)
+
+// NewServer creates a Server answering from store.
+func NewServer(store Store) *Server {
+	return &Server{store: store}
+}
+
+// Register adds the query API to s.
+func (s *Server) Register(srv *grpc.Server) {
+	inventorypb.RegisterInventoryServiceServer(srv, s)
+}
+
+// CheckAvailability reports, for each product, whether a single warehouse
+// has the requested quantity free, as reserving it would need, or whether
+// the product would be backordered instead.
+func (s *Server) CheckAvailability(ctx context.Context, req *inventorypb.CheckAvailabilityRequest) (*inventorypb.CheckAvailabilityResponse, error) {
+	var productIDs []uuid.UUID
+	requested := map[uuid.UUID]int32{}
+	for _, item := range req.GetItems() {
+		productID, err := uuid.Parse(item.GetProductId())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid product ID %q", item.GetProductId())
+		}
+		if item.GetQuantity() <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "quantity of product %s must be positive", productID)
+		}
+		if _, ok := requested[productID]; !ok {
+			productIDs = append(productIDs, productID)
+		}
+		requested[productID] += item.GetQuantity()
+	}
+	if len(productIDs) == 0 || len(productIDs) > maxProducts {
+		return nil, status.Errorf(codes.InvalidArgument, "between 1 and %d products must be checked", maxProducts)
+	}
+
+	levels, err := s.store.StockLevels(ctx, productIDs)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	backorderable, err := s.store.BackorderableProducts(ctx)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	resp := &inventorypb.CheckAvailabilityResponse{Available: true}
+	for _, productID := range productIDs {
+		item := &inventorypb.ItemAvailability{
+			ProductId:     productID.String(),
+			Requested:     requested[productID],
+			Backorderable: slices.Contains(backorderable, productID),
+		}
+		for _, level := range levels {
+			if level.ProductID != productID {
+				continue
+			}
+			free := int32(max(level.Free(), 0))
+			item.Free += free
+			item.Available = item.Available || free >= item.Requested
+		}
+		resp.Available = resp.Available && (item.Available || item.Backorderable)
+		resp.Items = append(resp.Items, item)
+	}
+	return resp, nil
+}
+
+// GetStock returns the stock levels of the requested products.
+func (s *Server) GetStock(ctx context.Context, req *inventorypb.GetStockRequest) (*inventorypb.GetStockResponse, error) {
+	if len(req.GetProductIds()) == 0 || len(req.GetProductIds()) > maxProducts {
+		return nil, status.Errorf(codes.InvalidArgument, "between 1 and %d product IDs must be given", maxProducts)
+	}
+	var productIDs []uuid.UUID
+	for _, id := range req.GetProductIds() {
+		productID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid product ID %q", id)
+		}
+		if !slices.Contains(productIDs, productID) {
+			productIDs = append(productIDs, productID)
+		}
+	}
+
+	levels, err := s.store.StockLevels(ctx, productIDs)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	resp := &inventorypb.GetStockResponse{}
+	for _, level := range levels {
+		resp.Levels = append(resp.Levels, &inventorypb.StockLevel{
+			WarehouseId: level.WarehouseID,
+			ProductId:   level.ProductID.String(),
+			Available:   int32(level.Available),
+			Reserved:    int32(level.Reserved),
+		})
+	}
+	return resp, nil
+}
+
+// GetReservation returns the reservation lines of an order.
+func (s *Server) GetReservation(ctx context.Context, req *inventorypb.GetReservationRequest) (*inventorypb.GetReservationResponse, error) {
+	orderID, err := uuid.Parse(req.GetOrderId())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid order ID %q", req.GetOrderId())
+	}
+	reservations, err := s.store.Reservations(ctx, orderID)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if len(reservations) == 0 {
+		return nil, status.Errorf(codes.NotFound, "order %s holds no reservation", orderID)
+	}
+	resp := &inventorypb.GetReservationResponse{OrderId: orderID.String()}
+	for _, r := range reservations {
+		resp.Lines = append(resp.Lines, &inventorypb.ReservationLine{
+			ProductId:   r.ProductID.String(),
+			WarehouseId: r.WarehouseID,
+			Quantity:    int32(r.Quantity),
+			Status:      reservationStatus(r.Status),
+		})
+	}
+	return resp, nil
+}
+
+// reservationStatus maps a stored reservation status to the API's.
+func reservationStatus(s string) inventorypb.ReservationStatus {
+	switch s {
+	case stock.ReservedStatus:
+		return inventorypb.ReservationStatus_RESERVATION_STATUS_RESERVED
+	case stock.ReleasedStatus:
+		return inventorypb.ReservationStatus_RESERVATION_STATUS_RELEASED
+	default:
+		return inventorypb.ReservationStatus_RESERVATION_STATUS_UNSPECIFIED
+	}
+}
+
+// internalError logs err and hides it from the caller.
+func internalError(ctx context.Context, err error) error {
+	log.Ctx(ctx).Error().Err(err).Msg("Inventory query failed")
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,148 @@
+package grpcserver_test
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventorypb"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/grpcserver"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// memoryStore serves fixed stock levels, reservations and backorderable
+// products.
+type memoryStore struct {
+	levels        []stock.Level
+	reservations  []stock.Reservation
+	backorderable []uuid.UUID
+}
+
+func (s *memoryStore) StockLevels(_ context.Context, productIDs []uuid.UUID) ([]stock.Level, error) {
+	var levels []stock.Level
+	for _, l := range s.levels {
+		if slices.Contains(productIDs, l.ProductID) {
+			levels = append(levels, l)
+		}
+	}
+	return levels, nil
+}
+
+func (s *memoryStore) Reservations(_ context.Context, orderID uuid.UUID) ([]stock.Reservation, error) {
+	var reservations []stock.Reservation
+	for _, r := range s.reservations {
+		if r.OrderID == orderID {
+			reservations = append(reservations, r)
+		}
+	}
+	return reservations, nil
+}
+
+func (s *memoryStore) BackorderableProducts(context.Context) ([]uuid.UUID, error) {
+	return s.backorderable, nil
+}
+
+// newClient serves store over an in-memory connection.
+func newClient(t *testing.T, store grpcserver.Store) inventorypb.InventoryServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	grpcserver.NewServer(store).Register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return inventorypb.NewInventoryServiceClient(conn)
+}
+
+func TestServer_CheckAvailability(t *testing.T) {
+	mug, lamp, chair, sofa := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	client := newClient(t, &memoryStore{levels: []stock.Level{
+		{WarehouseID: "paris", ProductID: mug, Available: 10, Reserved: 4},
+		{WarehouseID: "lyon", ProductID: mug, Available: 5},
+		{WarehouseID: "paris", ProductID: lamp, Available: 3, Reserved: 1},
+		{WarehouseID: "lyon", ProductID: lamp, Available: 2},
+	}, backorderable: []uuid.UUID{sofa}})
+	ctx := context.Background()
+
+	resp, err := client.CheckAvailability(ctx, &inventorypb.CheckAvailabilityRequest{Items: []*inventorypb.Item{
+		{ProductId: mug.String(), Quantity: 4},
+		{ProductId: mug.String(), Quantity: 2},
+		{ProductId: lamp.String(), Quantity: 3},
+	}})
+	require.NoError(t, err)
+	assert.False(t, resp.GetAvailable())
+	require.Len(t, resp.GetItems(), 2)
+	assert.Equal(t, mug.String(), resp.GetItems()[0].GetProductId())
+	assert.Equal(t, int32(6), resp.GetItems()[0].GetRequested())
+	assert.Equal(t, int32(11), resp.GetItems()[0].GetFree())
+	assert.True(t, resp.GetItems()[0].GetAvailable())
+	// Four lamps are free, but no warehouse holds three of them.
+	assert.Equal(t, int32(4), resp.GetItems()[1].GetFree())
+	assert.False(t, resp.GetItems()[1].GetAvailable())
+
+	resp, err = client.CheckAvailability(ctx, &inventorypb.CheckAvailabilityRequest{Items: []*inventorypb.Item{{ProductId: chair.String(), Quantity: 1}}})
+	require.NoError(t, err)
+	assert.False(t, resp.GetAvailable())
+	assert.Zero(t, resp.GetItems()[0].GetFree())
+
+	// Sofas are out of stock, but would be backordered.
+	resp, err = client.CheckAvailability(ctx, &inventorypb.CheckAvailabilityRequest{Items: []*inventorypb.Item{{ProductId: sofa.String(), Quantity: 2}}})
+	require.NoError(t, err)
+	assert.True(t, resp.GetAvailable())
+	assert.False(t, resp.GetItems()[0].GetAvailable())
+	assert.True(t, resp.GetItems()[0].GetBackorderable())
+
+	for _, items := range [][]*inventorypb.Item{nil, {{ProductId: "nope", Quantity: 1}}, {{ProductId: mug.String()}}} {
+		_, err = client.CheckAvailability(ctx, &inventorypb.CheckAvailabilityRequest{Items: items})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestServer_GetStock(t *testing.T) {
+	mug := uuid.New()
+	client := newClient(t, &memoryStore{levels: []stock.Level{{WarehouseID: "paris", ProductID: mug, Available: 10, Reserved: 4}}})
+
+	resp, err := client.GetStock(context.Background(), &inventorypb.GetStockRequest{ProductIds: []string{mug.String(), mug.String()}})
+	require.NoError(t, err)
+	require.Len(t, resp.GetLevels(), 1)
+	assert.Equal(t, "paris", resp.GetLevels()[0].GetWarehouseId())
+	assert.Equal(t, int32(10), resp.GetLevels()[0].GetAvailable())
+	assert.Equal(t, int32(4), resp.GetLevels()[0].GetReserved())
+
+	_, err = client.GetStock(context.Background(), &inventorypb.GetStockRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_GetReservation(t *testing.T) {
+	orderID, mug := uuid.New(), uuid.New()
+	client := newClient(t, &memoryStore{reservations: []stock.Reservation{
+		{OrderID: orderID, ProductID: mug, WarehouseID: "paris", Quantity: 2, Status: stock.ReservedStatus},
+	}})
+	ctx := context.Background()
+
+	resp, err := client.GetReservation(ctx, &inventorypb.GetReservationRequest{OrderId: orderID.String()})
+	require.NoError(t, err)
+	require.Len(t, resp.GetLines(), 1)
+	assert.Equal(t, mug.String(), resp.GetLines()[0].GetProductId())
+	assert.Equal(t, int32(2), resp.GetLines()[0].GetQuantity())
+	assert.Equal(t, inventorypb.ReservationStatus_RESERVATION_STATUS_RESERVED, resp.GetLines()[0].GetStatus())
+
+	_, err = client.GetReservation(ctx, &inventorypb.GetReservationRequest{OrderId: uuid.NewString()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.GetReservation(ctx, &inventorypb.GetReservationRequest{OrderId: "nope"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
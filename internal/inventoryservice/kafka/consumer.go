@@ -2,20 +2,47 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"errors"
+	"sync"
 	"time"
 
-	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/service"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/dlq"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/metrics"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/rs/zerolog/log"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/codes"
 )
 
+// messageReader is the part of *kafka.Reader used by Consumer.
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Config() kafka.ReaderConfig
+	Close() error
+}
+
 type Consumer struct {
-	reader *kafka.Reader
+	reader       messageReader
+	batchSize    int
+	batchLinger  time.Duration
+	drainTimeout time.Duration
+	retry        dlq.RetryConfig
+	deadLetters  dlq.Writer
 }
 
+// finalCommitTimeout bounds the commit of the messages handled before the
+// drain deadline cut one short.
+const finalCommitTimeout = 5 * time.Second
+
+var _ broker.EventSubscriber = (*Consumer)(nil)
+
 // NewConsumer creates a new Kafka consumer.
-func NewConsumer(brokers []string, topic, groupID string) *Consumer {
+func NewConsumer(brokers []string, topic, groupID string, opts ...Option) *Consumer {
+	o := buildOptions(opts)
 	reader := kafka.NewReader(kafka.ReaderConfig{
 		Brokers:        brokers,
 		Topic:          topic,
@@ -23,53 +50,213 @@ func NewConsumer(brokers []string, topic, groupID string) *Consumer {
 		MinBytes:       10e3,            // 10KB
 		MaxBytes:       10e6,            // 10MB
 		MaxWait:        1 * time.Second, // Maximum amount of time to wait for new data to come to a partition
-		CommitInterval: 1 * time.Second, // Periodically commit offsets
+		CommitInterval: 0,               // Commit synchronously, when Consumer commits a batch
+		IsolationLevel: kafka.ReadCommitted,
 		Logger:         kafka.LoggerFunc(log.Printf),
 		ErrorLogger:    kafka.LoggerFunc(log.Printf),
+		Dialer:         o.auth.Dialer(dialTimeout),
 	})
-	return &Consumer{reader: reader}
+	return &Consumer{
+		reader:       reader,
+		batchSize:    max(o.batchSize, 1),
+		batchLinger:  o.batchLinger,
+		drainTimeout: o.drainTimeout,
+		retry:        o.consumeRetry,
+		deadLetters:  dlq.NewWriter(brokers, o.auth.Transport()),
+	}
 }
 
-// StartConsuming begins consuming messages from Kafka.
-func (c *Consumer) StartConsuming(ctx context.Context) {
-	log.Printf("Starting Kafka consumer for topic %s, group %s...", c.reader.Config().Topic, c.reader.Config().GroupID)
+// Consume passes messages from Kafka to handler until ctx is cancelled.
+// Messages are handled one at a time as they arrive; their offsets are
+// committed in batches (see WithBatching), so after a crash up to one batch
+// is handled again. A message the handler fails on holds the batch back: the
+// messages before it are committed at once, and it is retried and, once the
+// attempts set by WithConsumeRetry are used up, dead-lettered (see package
+// dlq) before the consumer moves past it. Cancelling ctx stops fetching but
+// lets the message in flight finish and the pending batch be committed,
+// within the drain timeout (see WithDrainTimeout), so Consume returns only
+// once that work is done or abandoned. A failing message is not retried
+// after ctx is cancelled; it is left uncommitted, to be delivered again.
+func (c *Consumer) Consume(ctx context.Context, handler broker.MessageHandler) {
+	c.consume(ctx, func(ctx context.Context, msg kafka.Message) error {
+		return handler(ctx, msg.Key, msg.Value)
+	})
+}
+
+// consume runs the loop of Consume, handling each message with handle.
+func (c *Consumer) consume(ctx context.Context, handle func(ctx context.Context, msg kafka.Message) error) {
+	processCtx, stopDrain := drainContext(ctx, c.drainTimeout)
+	defer stopDrain()
+	log.Info().
+		Str("topic", c.reader.Config().Topic).
+		Str("group_id", c.reader.Config().GroupID).
+		Int("batch_size", c.batchSize).
+		Dur("batch_linger", c.batchLinger).
+		Msg("Starting Kafka consumer")
+
+	var batch []kafka.Message
+	var lingerUntil time.Time
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Kafka consumer context cancelled. Shutting down.")
+			c.commit(processCtx, batch)
+			log.Info().Msg("Kafka consumer context cancelled. Shutting down.")
 			return
 		default:
-			msg, err := c.reader.FetchMessage(ctx) // Fetch one message at a time
-			if err != nil {
-				if ctx.Err() != nil { // Check if context was cancelled
-					return // Context cancelled, gracefully exit
-				}
-				log.Printf("Error fetching message: %v", err)
-				time.Sleep(time.Second) // Small backoff before retrying
-				continue
+		}
+
+		// While a batch is open, wait for more messages only until it is
+		// due to be committed.
+		fetchCtx, cancel := ctx, context.CancelFunc(func() {})
+		if len(batch) > 0 {
+			fetchCtx, cancel = context.WithDeadline(ctx, lingerUntil)
+		}
+		msg, err := c.reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			c.commit(processCtx, batch)
+			batch = nil
+			if ctx.Err() != nil { // Check if context was cancelled
+				return // Context cancelled, gracefully exit
 			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				continue // The batch lingered long enough
+			}
+			log.Error().Err(err).Str("topic", c.reader.Config().Topic).Msg("Error fetching message")
+			time.Sleep(time.Second) // Small backoff before retrying
+			continue
+		}
 
-			// Simulate processing the message
-			var event service.OrderPlacedEvent // Reusing the event struct from order service
-			if err := json.Unmarshal(msg.Value, &event); err != nil {
-				log.Printf("Error unmarshaling message from topic %s, partition %d, offset %d: %v",
-					msg.Topic, msg.Partition, msg.Offset, err)
-			} else {
-				log.Printf("Inventory Service: Received OrderPlaced event | OrderID: %s, CustomerID: %s, TotalPrice: %.2f",
-					event.OrderID, event.CustomerID, event.TotalPrice)
+		// A replay addressed to another consumer group is skipped, but its
+		// offset is committed with the batch.
+		if group := replayGroup(msg); group == "" || group == c.reader.Config().GroupID {
+			failed := func() {
+				// Commit up to the failing message, which is not
+				// committed until it is handled or dead-lettered.
+				c.commit(processCtx, batch)
+				batch = nil
 			}
+			if !c.process(ctx, processCtx, msg, handle, failed) {
+				// Shutdown or the drain deadline cut the message short:
+				// commit what was handled before it and leave this one
+				// to be redelivered.
+				commitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalCommitTimeout)
+				c.commit(commitCtx, batch)
+				cancel()
+				log.Ctx(logging.MessageContext(ctx, msg)).Warn().
+					Dur("drain_timeout", c.drainTimeout).
+					Msg("Shutting down before the message was handled; leaving it uncommitted for redelivery")
+				return
+			}
+		}
+		if len(batch) == 0 {
+			lingerUntil = time.Now().Add(c.batchLinger)
+		}
+		batch = append(batch, msg)
+		if len(batch) >= c.batchSize {
+			c.commit(processCtx, batch)
+			batch = nil
+		}
+	}
+}
+
+// process handles a single message, retrying it and dead-lettering it if it
+// keeps failing (see dlq.Process), and records its outcome. failed is called
+// when the first attempt fails. Retries stop once ctx is cancelled, and an
+// attempt is cut short when processCtx is, at the drain deadline; process
+// then returns false and the message must not be committed.
+func (c *Consumer) process(ctx, processCtx context.Context, msg kafka.Message, handle func(ctx context.Context, msg kafka.Message) error, failed func()) bool {
+	metrics.KafkaMessagesConsumedTotal.WithLabelValues(msg.Topic).Inc()
+	start := time.Now()
+	processingStatus := "success"
 
-			// Commit the offset only after successful processing
-			if err := c.reader.CommitMessages(ctx, msg); err != nil {
-				log.Printf("Error committing offset for message from topic %s, partition %d, offset %d: %v",
-					msg.Topic, msg.Partition, msg.Offset, err)
+	msgCtx, span := tracing.StartConsumerSpan(logging.MessageContext(processCtx, msg), tracerName, msg)
+	defer span.End()
+	retryCtx, stopRetries := context.WithCancel(msgCtx)
+	defer stopRetries()
+	stop := context.AfterFunc(ctx, stopRetries)
+	defer stop()
+	attempts := 0
+	deadLettered, err := dlq.Process(retryCtx, msg, c.retry, c.deadLetters, func(context.Context) error {
+		err := handle(msgCtx, msg)
+		if err != nil {
+			span.RecordError(err)
+			if attempts++; attempts == 1 {
+				failed()
 			}
 		}
+		return err
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, "abandoned at shutdown")
+		return false
+	}
+	if deadLettered {
+		processingStatus = "failure"
+		span.SetStatus(codes.Error, "dead-lettered")
+		metrics.KafkaMessagesDeadLetteredTotal.WithLabelValues(msg.Topic).Inc()
+	}
+	metrics.KafkaMessageProcessingDuration.WithLabelValues(msg.Topic, processingStatus).Observe(time.Since(start).Seconds())
+	return true
+}
+
+// drainContext returns a context for handling and committing messages that
+// outlives ctx by timeout: it is cancelled timeout after ctx is, or never if
+// timeout is 0. The returned function releases its resources.
+func drainContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	drainCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if timeout <= 0 {
+		return drainCtx, cancel
+	}
+	var timer *time.Timer
+	var mu sync.Mutex
+	stop := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		timer = time.AfterFunc(timeout, cancel)
+	})
+	return drainCtx, func() {
+		stop()
+		mu.Lock()
+		if timer != nil {
+			timer.Stop()
+		}
+		mu.Unlock()
+		cancel()
 	}
 }
 
-// Close closes the Kafka consumer connection.
+// replayGroup returns the consumer group a replayed message is addressed
+// to, or "" if it is meant for every group.
+func replayGroup(msg kafka.Message) string {
+	return tracing.HeaderCarrier{Headers: &msg.Headers}.Get(events.HeaderReplayGroup)
+}
+
+// commit commits the offsets of a batch of handled messages. The reader has
+// no commit interval, so the offsets are stored with the group coordinator
+// by the time commit returns, which lets shutdown commit the pending batch
+// before the reader is closed.
+func (c *Consumer) commit(ctx context.Context, batch []kafka.Message) {
+	if len(batch) == 0 {
+		return
+	}
+	topic := batch[0].Topic
+	metrics.KafkaCommitBatchSize.WithLabelValues(topic).Observe(float64(len(batch)))
+	if err := c.reader.CommitMessages(ctx, batch...); err != nil {
+		metrics.KafkaCommitFailuresTotal.WithLabelValues(topic).Inc()
+		log.Error().Err(err).
+			Str("topic", topic).
+			Int("messages", len(batch)).
+			Msg("Error committing offsets")
+	}
+}
+
+// Close closes the Kafka consumer connection and its dead-letter writer.
 func (c *Consumer) Close() error {
-	log.Println("Closing Kafka consumer...")
-	return c.reader.Close()
+	log.Info().Msg("Closing Kafka consumer...")
+	err := c.reader.Close()
+	if w, ok := c.deadLetters.(*kafka.Writer); ok {
+		err = errors.Join(err, w.Close())
+	}
+	return err
 }
@@ -0,0 +1,377 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/dlq"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReader serves the messages sent on msgs and records committed batches.
+type fakeReader struct {
+	msgs        chan kafka.Message
+	commitErr   error
+	commitDelay time.Duration
+
+	mu      sync.Mutex
+	commits [][]int64
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-r.msgs:
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	offsets := make([]int64, len(msgs))
+	for i, msg := range msgs {
+		offsets[i] = msg.Offset
+	}
+	time.Sleep(r.commitDelay)
+	if r.commitErr != nil {
+		return r.commitErr
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commits = append(r.commits, offsets)
+	return nil
+}
+
+func (r *fakeReader) committed() [][]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]int64(nil), r.commits...)
+}
+
+func (r *fakeReader) Config() kafka.ReaderConfig {
+	return kafka.ReaderConfig{Topic: "orders.placed", GroupID: "inventory-service-group"}
+}
+func (r *fakeReader) Close() error { return nil }
+
+// deadLetterWriter records dead-lettered messages, or fails while err is set.
+type deadLetterWriter struct {
+	mu      sync.Mutex
+	err     error
+	written []kafka.Message
+}
+
+func (w *deadLetterWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *deadLetterWriter) keys() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var keys []string
+	for _, msg := range w.written {
+		keys = append(keys, string(msg.Key))
+	}
+	return keys
+}
+
+// testConsumer is a Consumer reading from a fakeReader that records the
+// offsets of the messages it handles.
+type testConsumer struct {
+	*Consumer
+	deadLetters *deadLetterWriter
+	mu          sync.Mutex
+	handled     []int64
+}
+
+func newTestConsumer(size int, linger time.Duration) (*testConsumer, *fakeReader, *[]int64) {
+	reader := &fakeReader{msgs: make(chan kafka.Message, 10)}
+	deadLetters := &deadLetterWriter{}
+	retry := dlq.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	c := &testConsumer{
+		Consumer:    &Consumer{reader: reader, batchSize: size, batchLinger: linger, retry: retry, deadLetters: deadLetters},
+		deadLetters: deadLetters,
+	}
+	return c, reader, &c.handled
+}
+
+func (c *testConsumer) run(ctx context.Context) {
+	c.consume(ctx, func(_ context.Context, msg kafka.Message) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.handled = append(c.handled, msg.Offset)
+		return nil
+	})
+}
+
+func TestConsumer_CommitsFullBatches(t *testing.T) {
+	c, reader, handled := newTestConsumer(2, time.Hour)
+	for offset := range int64(5) {
+		reader.msgs <- kafka.Message{Topic: "orders.placed", Offset: offset}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.run(ctx)
+	}()
+
+	require.Eventually(t, func() bool { return len(reader.committed()) == 2 }, time.Second, time.Millisecond)
+	// The fifth message waits for its batch to fill or linger out; shutting
+	// down commits it.
+	cancel()
+	<-done
+
+	assert.Equal(t, [][]int64{{0, 1}, {2, 3}, {4}}, reader.committed())
+	assert.Equal(t, []int64{0, 1, 2, 3, 4}, *handled)
+}
+
+func TestConsumer_CommitsLingeringBatch(t *testing.T) {
+	c, reader, _ := newTestConsumer(10, 20*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.run(ctx)
+
+	for offset := range int64(3) {
+		reader.msgs <- kafka.Message{Topic: "orders.placed", Offset: offset}
+	}
+
+	// Nothing more arrives, so the open batch is committed after lingering.
+	var offsets []int64
+	require.Eventually(t, func() bool {
+		offsets = nil
+		for _, batch := range reader.committed() {
+			offsets = append(offsets, batch...)
+		}
+		return len(offsets) == 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []int64{0, 1, 2}, offsets)
+}
+
+func TestConsumer_SkipsReplaysForOtherGroups(t *testing.T) {
+	c, reader, handled := newTestConsumer(3, time.Hour)
+	for offset, group := range []string{"", "search-indexer-group", "inventory-service-group"} {
+		msg := kafka.Message{Topic: "orders.placed", Offset: int64(offset)}
+		if group != "" {
+			msg.Headers = []kafka.Header{{Key: events.HeaderReplayGroup, Value: []byte(group)}}
+		}
+		reader.msgs <- msg
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.run(ctx)
+	}()
+
+	require.Eventually(t, func() bool { return len(reader.committed()) == 1 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	// The replay for another group is committed without being handled.
+	assert.Equal(t, [][]int64{{0, 1, 2}}, reader.committed())
+	assert.Equal(t, []int64{0, 2}, *handled)
+}
+
+func TestConsumer_AbandonsMessageAtDrainDeadline(t *testing.T) {
+	c, reader, _ := newTestConsumer(10, time.Hour)
+	c.drainTimeout = 20 * time.Millisecond
+	for offset := range int64(2) {
+		reader.msgs <- kafka.Message{Topic: "orders.placed", Offset: offset}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.consume(ctx, func(ctx context.Context, msg kafka.Message) error {
+			if msg.Offset == 0 {
+				return nil
+			}
+			// The second message outlasts the drain deadline.
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+
+	<-started
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("consumer did not return after the drain deadline")
+	}
+
+	// The message handled before shutdown is committed; the abandoned one
+	// is left to be redelivered.
+	assert.Equal(t, [][]int64{{0}}, reader.committed())
+}
+
+func TestConsumer_FinishesMessageWithinDrainDeadline(t *testing.T) {
+	c, reader, _ := newTestConsumer(10, time.Hour)
+	c.drainTimeout = time.Second
+	reader.msgs <- kafka.Message{Topic: "orders.placed", Offset: 0}
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.consume(ctx, func(ctx context.Context, _ kafka.Message) error {
+			close(started)
+			// Shutdown begins while the message is in flight.
+			time.Sleep(20 * time.Millisecond)
+			return ctx.Err()
+		})
+	}()
+
+	<-started
+	cancel()
+	<-done
+
+	assert.Equal(t, [][]int64{{0}}, reader.committed())
+}
+
+func TestConsumer_CommitsPendingBatchBeforeReturning(t *testing.T) {
+	c, reader, _ := newTestConsumer(10, time.Hour)
+	c.drainTimeout = time.Second
+	// A slow commit must still complete before Consume returns.
+	reader.commitDelay = 50 * time.Millisecond
+	for offset := range int64(3) {
+		reader.msgs <- kafka.Message{Topic: "orders.placed", Offset: offset}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.handled) == 3
+	}, time.Second, time.Millisecond)
+	assert.Empty(t, reader.committed(), "Expected the batch to stay open until shutdown")
+	cancel()
+	<-done
+
+	assert.Equal(t, [][]int64{{0, 1, 2}}, reader.committed())
+}
+
+func TestConsumer_CountsFailedCommits(t *testing.T) {
+	c, reader, handled := newTestConsumer(2, time.Hour)
+	reader.commitErr = errors.New("kafka: not coordinator for group")
+	failures := metrics.KafkaCommitFailuresTotal.WithLabelValues("orders.failed-commit")
+	before := testutil.ToFloat64(failures)
+	for offset := range int64(2) {
+		reader.msgs <- kafka.Message{Topic: "orders.failed-commit", Offset: offset}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.run(ctx)
+	}()
+
+	require.Eventually(t, func() bool { return testutil.ToFloat64(failures) == before+1 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, []int64{0, 1}, *handled)
+	assert.Empty(t, reader.committed())
+}
+
+func TestConsumer_HoldsBatchAtFailingMessage(t *testing.T) {
+	c, reader, _ := newTestConsumer(10, time.Hour)
+	for offset := range int64(4) {
+		reader.msgs <- kafka.Message{Topic: "orders.placed", Offset: offset, Key: []byte(fmt.Sprint("order-", offset))}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	attempts := map[int64]int{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.consume(ctx, func(_ context.Context, msg kafka.Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts[msg.Offset]++
+			switch {
+			case msg.Offset == 1 && attempts[1] == 1:
+				return errors.New("db down")
+			case msg.Offset == 2:
+				return errors.New("invalid event")
+			}
+			return nil
+		})
+	}()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts[3] == 1
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	// Each failure commits the messages before it; the failing message is
+	// committed once handled or dead-lettered.
+	assert.Equal(t, [][]int64{{0}, {1}, {2, 3}}, reader.committed())
+	assert.Equal(t, map[int64]int{0: 1, 1: 2, 2: 3, 3: 1}, attempts)
+	assert.Equal(t, []string{"order-2"}, c.deadLetters.keys())
+}
+
+func TestConsumer_StopsAtMessageItCannotDeadLetter(t *testing.T) {
+	c, reader, _ := newTestConsumer(10, time.Hour)
+	c.deadLetters.err = errors.New("kafka: leader not available")
+	for offset := range int64(3) {
+		reader.msgs <- kafka.Message{Topic: "orders.placed", Offset: offset}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	failing := make(chan struct{}, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.consume(ctx, func(_ context.Context, msg kafka.Message) error {
+			c.mu.Lock()
+			c.handled = append(c.handled, msg.Offset)
+			c.mu.Unlock()
+			if msg.Offset == 1 {
+				failing <- struct{}{}
+				return errors.New("db down")
+			}
+			return nil
+		})
+	}()
+
+	for range 3 {
+		<-failing
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("consumer did not stop retrying at shutdown")
+	}
+
+	// The consumer never moved past the message, which is left to be
+	// delivered again.
+	assert.Equal(t, [][]int64{{0}}, reader.committed())
+	assert.Equal(t, []int64{0, 1, 1, 1}, c.handled)
+}
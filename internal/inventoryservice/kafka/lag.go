@@ -0,0 +1,180 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/metrics"
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// PartitionLag is the consumer group's lag on a single partition.
+type PartitionLag struct {
+	Partition       int   `json:"partition"`
+	CommittedOffset int64 `json:"committed_offset"`
+	LatestOffset    int64 `json:"latest_offset"`
+	Lag             int64 `json:"lag"`
+}
+
+// LagReport is the most recent lag measurement for a topic and group.
+type LagReport struct {
+	Topic      string         `json:"topic"`
+	GroupID    string         `json:"group_id"`
+	TotalLag   int64          `json:"total_lag"`
+	Partitions []PartitionLag `json:"partitions"`
+	MeasuredAt time.Time      `json:"measured_at"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// LagMonitor periodically compares the consumer group's committed offsets
+// with each partition's latest offset and exports the difference.
+type LagMonitor struct {
+	client  *kafka.Client
+	topic   string
+	groupID string
+
+	mu     sync.RWMutex
+	report LagReport
+}
+
+// NewLagMonitor creates a LagMonitor for groupID on topic.
+func NewLagMonitor(brokers []string, topic, groupID string, opts ...Option) *LagMonitor {
+	o := buildOptions(opts)
+	return &LagMonitor{
+		client: &kafka.Client{
+			Addr:      kafka.TCP(brokers...),
+			Timeout:   10 * time.Second,
+			Transport: o.auth.Transport(),
+		},
+		topic:   topic,
+		groupID: groupID,
+		report:  LagReport{Topic: topic, GroupID: groupID},
+	}
+}
+
+// Run measures lag every interval until ctx is cancelled.
+func (m *LagMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Report returns the latest lag measurement.
+func (m *LagMonitor) Report() LagReport {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.report
+}
+
+// ServeHTTP writes the latest lag measurement as JSON.
+func (m *LagMonitor) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(m.Report())
+}
+
+func (m *LagMonitor) refresh(ctx context.Context) {
+	partitions, err := m.measure(ctx)
+	report := LagReport{Topic: m.topic, GroupID: m.groupID, MeasuredAt: time.Now()}
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn().Err(err).Str("topic", m.topic).Msg("Failed to measure consumer lag")
+		report.Error = err.Error()
+	} else {
+		report.Partitions = partitions
+		for _, p := range partitions {
+			report.TotalLag += p.Lag
+			metrics.KafkaConsumerLag.WithLabelValues(m.topic, m.groupID, strconv.Itoa(p.Partition)).Set(float64(p.Lag))
+		}
+	}
+
+	m.mu.Lock()
+	m.report = report
+	m.mu.Unlock()
+}
+
+func (m *LagMonitor) measure(ctx context.Context) ([]PartitionLag, error) {
+	meta, err := m.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{m.topic}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+	}
+	var partitionIDs []int
+	for _, t := range meta.Topics {
+		if t.Name != m.topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, fmt.Errorf("failed to fetch metadata for topic %s: %w", m.topic, t.Error)
+		}
+		for _, p := range t.Partitions {
+			partitionIDs = append(partitionIDs, p.ID)
+		}
+	}
+
+	committed, err := m.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: m.groupID,
+		Topics:  map[string][]int{m.topic: partitionIDs},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch committed offsets: %w", err)
+	}
+	committedOffsets := make(map[int]int64, len(partitionIDs))
+	for _, p := range committed.Topics[m.topic] {
+		committedOffsets[p.Partition] = p.CommittedOffset
+	}
+
+	requests := make([]kafka.OffsetRequest, len(partitionIDs))
+	for i, id := range partitionIDs {
+		requests[i] = kafka.LastOffsetOf(id)
+	}
+	latest, err := m.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{m.topic: requests},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list latest offsets: %w", err)
+	}
+	latestOffsets := make(map[int]int64, len(partitionIDs))
+	for _, p := range latest.Topics[m.topic] {
+		latestOffsets[p.Partition] = p.LastOffset
+	}
+
+	return computeLag(partitionIDs, committedOffsets, latestOffsets), nil
+}
+
+// computeLag pairs committed and latest offsets per partition. A partition the
+// group has never committed on (offset -1) lags by everything retained in it.
+func computeLag(partitionIDs []int, committed, latest map[int]int64) []PartitionLag {
+	lags := make([]PartitionLag, 0, len(partitionIDs))
+	for _, id := range partitionIDs {
+		c, ok := committed[id]
+		if !ok {
+			c = -1
+		}
+		l := latest[id]
+		lag := l - c
+		if c < 0 {
+			lag = l
+		}
+		if lag < 0 {
+			lag = 0
+		}
+		lags = append(lags, PartitionLag{Partition: id, CommittedOffset: c, LatestOffset: l, Lag: lag})
+	}
+	sort.Slice(lags, func(i, j int) bool { return lags[i].Partition < lags[j].Partition })
+	return lags
+}
@@ -0,0 +1,21 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeLag(t *testing.T) {
+	lags := computeLag(
+		[]int{1, 0, 2},
+		map[int]int64{0: 90, 1: -1},
+		map[int]int64{0: 100, 1: 25, 2: 7},
+	)
+
+	assert.Equal(t, []PartitionLag{
+		{Partition: 0, CommittedOffset: 90, LatestOffset: 100, Lag: 10},
+		{Partition: 1, CommittedOffset: -1, LatestOffset: 25, Lag: 25},
+		{Partition: 2, CommittedOffset: -1, LatestOffset: 7, Lag: 7},
+	}, lags)
+}
@@ -0,0 +1,77 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/dlq"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/segmentio/kafka-go"
+)
+
+// dialTimeout bounds connection setup, including the SASL handshake.
+const dialTimeout = 10 * time.Second
+
+// Option configures the Kafka clients created by this package.
+type Option func(*options)
+
+type options struct {
+	auth         *kafkaauth.Auth
+	requiredAcks kafka.RequiredAcks
+	batchSize    int
+	batchLinger  time.Duration
+	drainTimeout time.Duration
+	consumeRetry dlq.RetryConfig
+}
+
+// WithAuth connects to the brokers with the given SASL and TLS settings.
+func WithAuth(auth *kafkaauth.Auth) Option {
+	return func(o *options) {
+		o.auth = auth
+	}
+}
+
+// WithRequiredAcks sets how many replicas must acknowledge a write before a
+// producer considers it successful. The default is kafka.RequireOne.
+func WithRequiredAcks(acks kafka.RequiredAcks) Option {
+	return func(o *options) {
+		o.requiredAcks = acks
+	}
+}
+
+// WithBatching makes a consumer commit offsets once per batch of up to size
+// messages instead of after every message. A batch is committed early when
+// linger has passed since its first message without it filling up. The
+// default, a size of 1, commits every message.
+func WithBatching(size int, linger time.Duration) Option {
+	return func(o *options) {
+		o.batchSize = size
+		o.batchLinger = linger
+	}
+}
+
+// WithDrainTimeout bounds how long a consumer keeps working once its context
+// is cancelled: the message in flight must be handled and the pending batch
+// committed within timeout. A message whose handling is cut short is left
+// uncommitted, so it is delivered again after the restart. The default, 0,
+// waits for the message in flight however long it takes.
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.drainTimeout = timeout
+	}
+}
+
+// WithConsumeRetry sets how often a consumer tries a message its handler
+// fails on before dead-lettering it. The default is dlq.DefaultRetry.
+func WithConsumeRetry(cfg dlq.RetryConfig) Option {
+	return func(o *options) {
+		o.consumeRetry = cfg
+	}
+}
+
+func buildOptions(opts []Option) options {
+	o := options{requiredAcks: kafka.RequireOne, batchSize: 1, consumeRetry: dlq.DefaultRetry}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/metrics"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/kafka"
+
+// Producer publishes inventory outcome events.
+type Producer struct {
+	writer *kafka.Writer
+}
+
+// NewProducer creates a new Kafka producer for the given topic.
+func NewProducer(brokers []string, topic string, opts ...Option) *Producer {
+	o := buildOptions(opts)
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.LeastBytes{},
+		RequiredAcks: o.requiredAcks,
+		MaxAttempts:  3,
+		WriteTimeout: 5 * time.Second,
+		BatchTimeout: 1 * time.Second,
+		BatchSize:    100,
+		Logger:       kafka.LoggerFunc(log.Printf),
+		ErrorLogger:  kafka.LoggerFunc(log.Printf),
+		Transport:    o.auth.Transport(),
+	}
+	return &Producer{writer: writer}
+}
+
+var _ broker.EventPublisher = (*Producer)(nil)
+
+// PublishMessage sends a key-value message to the Kafka topic.
+func (p *Producer) PublishMessage(ctx context.Context, key, value []byte) error {
+	msg := kafka.Message{
+		Key:   key,
+		Value: value,
+		Time:  time.Now(),
+	}
+	logging.InjectKafkaHeader(ctx, &msg)
+	ctx, span := tracing.StartProducerSpan(ctx, tracerName, p.writer.Topic, &msg)
+	defer span.End()
+
+	metrics.KafkaPublishAttemptsTotal.WithLabelValues(p.writer.Topic).Inc()
+	start := time.Now()
+	err := p.writer.WriteMessages(ctx, msg)
+	metrics.KafkaPublishDuration.WithLabelValues(p.writer.Topic).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.KafkaPublishFailuresTotal.WithLabelValues(p.writer.Topic).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+		return fmt.Errorf("failed to write message to Kafka: %w", err)
+	}
+	return nil
+}
+
+// Close closes the Kafka producer connection.
+func (p *Producer) Close() error {
+	log.Info().Msg("Closing Kafka producer...")
+	return p.writer.Close()
+}
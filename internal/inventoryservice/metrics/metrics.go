@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	KafkaPublishAttemptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_publish_attempts_total",
+		Help: "Total number of Kafka publish attempts, by topic.",
+	}, []string{"topic"})
+
+	KafkaPublishFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_publish_failures_total",
+		Help: "Total number of failed Kafka publishes, by topic.",
+	}, []string{"topic"})
+
+	KafkaPublishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_publish_duration_seconds",
+		Help:    "Duration of Kafka publish calls in seconds, by topic.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	KafkaMessagesConsumedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_consumed_total",
+		Help: "Total number of Kafka messages fetched, by topic.",
+	}, []string{"topic"})
+
+	KafkaMessageProcessingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_message_processing_duration_seconds",
+		Help:    "Duration of Kafka message handling in seconds, by topic and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic", "status"})
+
+	KafkaCommitFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_commit_failures_total",
+		Help: "Total number of failed Kafka offset commits, by topic.",
+	}, []string{"topic"})
+
+	KafkaMessagesDeadLetteredTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_dead_lettered_total",
+		Help: "Total number of Kafka messages written to a dead-letter topic after their handler kept failing, by topic.",
+	}, []string{"topic"})
+
+	KafkaCommitBatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_commit_batch_size",
+		Help:    "Number of messages whose offsets were committed together, by topic.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	}, []string{"topic"})
+
+	KafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_lag",
+		Help: "Difference between the latest offset and the consumer group's committed offset, by topic, group and partition.",
+	}, []string{"topic", "group", "partition"})
+)
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS update_reservations_updated_at ON reservations;
+DROP TRIGGER IF EXISTS update_stock_levels_updated_at ON stock_levels;
+
+DROP INDEX IF EXISTS idx_reservations_product_id;
+DROP TABLE IF EXISTS reservations;
+DROP TABLE IF EXISTS stock_levels;
+
+DROP FUNCTION IF EXISTS update_updated_at_column();
@@ -0,0 +1,44 @@
+-- Function and trigger to automatically update updated_at
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Stock on hand per product. reserved counts units held for orders that have
+-- not shipped yet, so available - reserved is what can still be sold.
+CREATE TABLE IF NOT EXISTS stock_levels (
+    product_id UUID PRIMARY KEY,
+    available INT NOT NULL DEFAULT 0 CHECK (available >= 0),
+    reserved INT NOT NULL DEFAULT 0 CHECK (reserved >= 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK (reserved <= available)
+);
+
+-- Units reserved for each order line, keyed by the order ID carried in the
+-- reservation request so redelivered messages do not reserve twice.
+CREATE TABLE IF NOT EXISTS reservations (
+    order_id UUID NOT NULL,
+    product_id UUID NOT NULL REFERENCES stock_levels(product_id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    status VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (order_id, product_id)
+);
+
+-- Index for faster lookup of reservations by product
+CREATE INDEX IF NOT EXISTS idx_reservations_product_id ON reservations(product_id);
+
+CREATE OR REPLACE TRIGGER update_stock_levels_updated_at
+BEFORE UPDATE ON stock_levels
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE OR REPLACE TRIGGER update_reservations_updated_at
+BEFORE UPDATE ON reservations
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,25 @@
+-- Stock is summed back into one level per product.
+DROP TRIGGER IF EXISTS update_warehouses_updated_at ON warehouses;
+
+ALTER TABLE reservations DROP CONSTRAINT IF EXISTS reservations_stock_level_fkey;
+ALTER TABLE reservations DROP COLUMN IF EXISTS warehouse_id;
+
+DROP INDEX IF EXISTS idx_stock_levels_product_id;
+
+CREATE TEMPORARY TABLE merged_stock_levels AS
+    SELECT product_id, SUM(available)::INT AS available, SUM(reserved)::INT AS reserved,
+        MIN(created_at) AS created_at, MAX(updated_at) AS updated_at
+    FROM stock_levels
+    GROUP BY product_id;
+DELETE FROM stock_levels;
+ALTER TABLE stock_levels DROP CONSTRAINT IF EXISTS stock_levels_pkey;
+ALTER TABLE stock_levels DROP COLUMN IF EXISTS warehouse_id;
+INSERT INTO stock_levels (product_id, available, reserved, created_at, updated_at)
+    SELECT product_id, available, reserved, created_at, updated_at FROM merged_stock_levels;
+DROP TABLE merged_stock_levels;
+ALTER TABLE stock_levels ADD PRIMARY KEY (product_id);
+
+ALTER TABLE reservations ADD CONSTRAINT reservations_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES stock_levels(product_id);
+
+DROP TABLE IF EXISTS warehouses;
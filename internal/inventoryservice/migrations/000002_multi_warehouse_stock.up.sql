@@ -0,0 +1,36 @@
+-- Stock is held per warehouse. Orders are reserved from one warehouse per
+-- line, picked by the reservation strategy; latitude and longitude place the
+-- warehouse for the nearest strategy, and priority ranks warehouses when an
+-- order has no destination.
+CREATE TABLE IF NOT EXISTS warehouses (
+    id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    latitude DOUBLE PRECISION NOT NULL DEFAULT 0,
+    longitude DOUBLE PRECISION NOT NULL DEFAULT 0,
+    priority INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Existing stock moves to a default warehouse.
+INSERT INTO warehouses (id, name) VALUES ('default', 'Default warehouse') ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE reservations DROP CONSTRAINT IF EXISTS reservations_product_id_fkey;
+
+ALTER TABLE stock_levels ADD COLUMN IF NOT EXISTS warehouse_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES warehouses(id);
+ALTER TABLE stock_levels ALTER COLUMN warehouse_id DROP DEFAULT;
+ALTER TABLE stock_levels DROP CONSTRAINT IF EXISTS stock_levels_pkey;
+ALTER TABLE stock_levels ADD PRIMARY KEY (warehouse_id, product_id);
+
+-- Finding the warehouses holding a product.
+CREATE INDEX IF NOT EXISTS idx_stock_levels_product_id ON stock_levels(product_id);
+
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS warehouse_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE reservations ALTER COLUMN warehouse_id DROP DEFAULT;
+ALTER TABLE reservations ADD CONSTRAINT reservations_stock_level_fkey
+    FOREIGN KEY (warehouse_id, product_id) REFERENCES stock_levels(warehouse_id, product_id);
+
+CREATE OR REPLACE TRIGGER update_warehouses_updated_at
+BEFORE UPDATE ON warehouses
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
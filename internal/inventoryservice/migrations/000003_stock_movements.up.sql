@@ -0,0 +1,40 @@
+-- Every change to a stock level, for audits and shrinkage investigations.
+-- kind is reservation, release, adjustment or receipt; quantity is the
+-- change to available (adjustments and receipts) or reserved (reservations
+-- and releases). Rows are never updated or deleted.
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    warehouse_id VARCHAR(64) NOT NULL,
+    product_id UUID NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('reservation', 'release', 'adjustment', 'receipt')),
+    quantity INT NOT NULL,
+    order_id UUID,
+    actor VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    available_before INT NOT NULL,
+    available_after INT NOT NULL,
+    reserved_before INT NOT NULL,
+    reserved_after INT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Movements are listed per product and per order, newest first.
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product_id ON stock_movements(product_id, id);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_order_id ON stock_movements(order_id) WHERE order_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION reject_stock_movement_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER stock_movements_append_only
+BEFORE UPDATE OR DELETE ON stock_movements
+FOR EACH ROW
+EXECUTE FUNCTION reject_stock_movement_changes();
+
+CREATE OR REPLACE TRIGGER stock_movements_no_truncate
+BEFORE TRUNCATE ON stock_movements
+FOR EACH STATEMENT
+EXECUTE FUNCTION reject_stock_movement_changes();
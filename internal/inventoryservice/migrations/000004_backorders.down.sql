@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS backorders;
+DROP TABLE IF EXISTS backorderable_products;
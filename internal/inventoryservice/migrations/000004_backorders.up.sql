@@ -0,0 +1,27 @@
+-- Products that may be oversold: order lines beyond their free stock are
+-- backordered instead of failing the reservation.
+CREATE TABLE IF NOT EXISTS backorderable_products (
+    product_id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Order lines waiting for stock, allocated first come, first served. The
+-- destination is kept for the reservation strategy; warehouse_id is set
+-- once the line is allocated and moved to reservations. Releasing the
+-- order cancels its pending backorders.
+CREATE TABLE IF NOT EXISTS backorders (
+    id BIGSERIAL PRIMARY KEY,
+    order_id UUID NOT NULL,
+    product_id UUID NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    destination_latitude DOUBLE PRECISION,
+    destination_longitude DOUBLE PRECISION,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'allocated', 'cancelled')),
+    warehouse_id VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    allocated_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (order_id, product_id)
+);
+
+-- Finding pending backorders in arrival order.
+CREATE INDEX IF NOT EXISTS idx_backorders_pending ON backorders(id) WHERE status = 'pending';
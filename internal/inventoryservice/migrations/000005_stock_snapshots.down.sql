@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_stock_movements_created_at;
+DROP TABLE IF EXISTS stock_snapshot_levels;
+DROP TABLE IF EXISTS stock_snapshots;
@@ -0,0 +1,21 @@
+-- Periodic copies of every stock level. Stock as of a time is the latest
+-- snapshot taken before it, brought forward by the stock movements recorded
+-- between the two.
+CREATE TABLE IF NOT EXISTS stock_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    taken_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_snapshots_taken_at ON stock_snapshots(taken_at);
+
+CREATE TABLE IF NOT EXISTS stock_snapshot_levels (
+    snapshot_id BIGINT NOT NULL REFERENCES stock_snapshots(id) ON DELETE CASCADE,
+    warehouse_id VARCHAR(64) NOT NULL,
+    product_id UUID NOT NULL,
+    available INT NOT NULL,
+    reserved INT NOT NULL,
+    PRIMARY KEY (snapshot_id, warehouse_id, product_id)
+);
+
+-- Finding the last movement of each stock level before a time.
+CREATE INDEX IF NOT EXISTS idx_stock_movements_created_at ON stock_movements(created_at);
@@ -0,0 +1,125 @@
+// Package reservation handles the inventory service's reservation and
+// release requests, whichever message broker delivers them.
+package reservation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/rs/zerolog/log"
+)
+
+// Reserver reserves stock for an order and returns the lines backordered.
+// It fails with stock.ErrInsufficientStock when the order cannot be
+// reserved; *stock.PostgresStore satisfies it. Reserving an order again
+// must reserve nothing more and return the same backordered lines, as a
+// request is delivered again when its outcome could not be published.
+type Reserver interface {
+	Reserve(ctx context.Context, order events.OrderPlaced) ([]events.OrderItem, error)
+}
+
+// Option configures Handler.
+type Option func(*options)
+
+type options struct {
+	reserver Reserver
+}
+
+// WithReserver reserves stock with r. Without it stock is not tracked and
+// every reservation succeeds.
+func WithReserver(r Reserver) Option {
+	return func(o *options) { o.reserver = r }
+}
+
+// Handler returns a handler that decodes reservation requests and publishes
+// their outcome with publisher. OrderPlaced events (choreography) and
+// ReserveInventory commands (orchestration) share the same payload.
+//
+// Store and publish failures are returned rather than logged, so the
+// consumer retries the request or dead-letters it: once stock is reserved,
+// the order must still be told.
+func Handler(publisher broker.EventPublisher, opts ...Option) broker.MessageHandler {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(ctx context.Context, _, value []byte) error {
+		var event events.OrderPlaced
+		if err := json.Unmarshal(value, &event); err != nil {
+			return fmt.Errorf("failed to unmarshal reservation request: %w", err)
+		}
+		log.Ctx(ctx).Info().
+			Str("customer_id", event.CustomerID.String()).
+			Float64("total_price", event.TotalPrice).
+			Msg("Inventory Service: Received reservation request")
+
+		outcome := events.InventoryEvent{
+			Type:    events.InventoryReserved,
+			OrderID: event.OrderID,
+		}
+		if o.reserver != nil {
+			backordered, err := o.reserver.Reserve(ctx, event)
+			switch {
+			case errors.Is(err, stock.ErrInsufficientStock):
+				log.Ctx(ctx).Info().Err(err).Msg("Inventory Service: Reservation failed")
+				outcome.Type, outcome.Reason = events.InventoryReservationFailed, err.Error()
+			case err != nil:
+				return fmt.Errorf("failed to reserve stock for order %s: %w", event.OrderID, err)
+			case len(backordered) > 0:
+				log.Ctx(ctx).Info().Int("lines", len(backordered)).Msg("Inventory Service: Reserved with backordered lines")
+				outcome.Backordered, outcome.Items = true, backordered
+			}
+		}
+		return reportReservation(ctx, publisher, outcome)
+	}
+}
+
+// reportReservation publishes the reservation outcome for an order.
+func reportReservation(ctx context.Context, publisher broker.EventPublisher, outcome events.InventoryEvent) error {
+	outcome.Timestamp = time.Now().UTC()
+	value, err := json.Marshal(outcome)
+	if err != nil {
+		return fmt.Errorf("failed to marshal inventory event for order %s: %w", outcome.OrderID, err)
+	}
+	if err := publisher.PublishMessage(ctx, []byte(outcome.OrderID.String()), value); err != nil {
+		return fmt.Errorf("failed to publish inventory event for order %s: %w", outcome.OrderID, err)
+	}
+	return nil
+}
+
+// Releaser frees the stock reserved for an order. It fails with
+// stock.ErrNoReservation when the order holds none; *stock.PostgresStore
+// satisfies it.
+type Releaser interface {
+	Release(ctx context.Context, orderID uuid.UUID, actor, reason string) error
+}
+
+// ReleaseHandler returns a handler that decodes ReleaseInventory commands
+// and frees the stock of their order with releaser. Orders holding no stock,
+// because their reservation failed or was already released, are skipped.
+func ReleaseHandler(releaser Releaser) broker.MessageHandler {
+	return func(ctx context.Context, _, value []byte) error {
+		var command events.ReleaseInventory
+		if err := json.Unmarshal(value, &command); err != nil {
+			return fmt.Errorf("failed to unmarshal release request: %w", err)
+		}
+
+		err := releaser.Release(ctx, command.OrderID, stock.SystemActor, command.Reason)
+		if errors.Is(err, stock.ErrNoReservation) {
+			log.Ctx(ctx).Info().Str("order_id", command.OrderID.String()).Msg("Inventory Service: Nothing to release")
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to release stock of order %s: %w", command.OrderID, err)
+		}
+		log.Ctx(ctx).Info().Str("order_id", command.OrderID.String()).Str("reason", command.Reason).Msg("Inventory Service: Released stock")
+		return nil
+	}
+}
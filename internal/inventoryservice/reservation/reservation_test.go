@@ -0,0 +1,197 @@
+package reservation_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/reservation"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher records the messages published with it, or fails
+// while err is set.
+type recordingPublisher struct {
+	err          error
+	keys, values [][]byte
+}
+
+func (p *recordingPublisher) PublishMessage(_ context.Context, key, value []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.keys = append(p.keys, key)
+	p.values = append(p.values, value)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func TestHandler(t *testing.T) {
+	publisher := &recordingPublisher{}
+	handle := reservation.Handler(publisher)
+
+	t.Run("reports the reservation", func(t *testing.T) {
+		orderID := uuid.New()
+		request, err := json.Marshal(events.OrderPlaced{OrderID: orderID, CustomerID: uuid.New(), TotalPrice: 19.98})
+		require.NoError(t, err)
+
+		require.NoError(t, handle(context.Background(), []byte(orderID.String()), request))
+
+		require.Len(t, publisher.values, 1)
+		assert.Equal(t, orderID.String(), string(publisher.keys[0]))
+		var outcome events.InventoryEvent
+		require.NoError(t, json.Unmarshal(publisher.values[0], &outcome))
+		assert.Equal(t, events.InventoryReserved, outcome.Type)
+		assert.Equal(t, orderID, outcome.OrderID)
+	})
+
+	t.Run("malformed request", func(t *testing.T) {
+		err := handle(context.Background(), nil, []byte("{"))
+		assert.ErrorContains(t, err, "failed to unmarshal reservation request")
+	})
+}
+
+// reserverFunc adapts a function to reservation.Reserver.
+type reserverFunc func(ctx context.Context, order events.OrderPlaced) ([]events.OrderItem, error)
+
+func (f reserverFunc) Reserve(ctx context.Context, order events.OrderPlaced) ([]events.OrderItem, error) {
+	return f(ctx, order)
+}
+
+func TestHandler_WithReserver(t *testing.T) {
+	request := func(t *testing.T) (uuid.UUID, []byte) {
+		orderID := uuid.New()
+		value, err := json.Marshal(events.OrderPlaced{OrderID: orderID, Items: []events.OrderItem{{ProductID: uuid.New(), Quantity: 3}}})
+		require.NoError(t, err)
+		return orderID, value
+	}
+
+	t.Run("reports insufficient stock", func(t *testing.T) {
+		publisher := &recordingPublisher{}
+		handle := reservation.Handler(publisher, reservation.WithReserver(reserverFunc(func(_ context.Context, order events.OrderPlaced) ([]events.OrderItem, error) {
+			return nil, fmt.Errorf("%w: product %s needs 3", stock.ErrInsufficientStock, order.Items[0].ProductID)
+		})))
+		orderID, value := request(t)
+
+		require.NoError(t, handle(context.Background(), nil, value))
+
+		require.Len(t, publisher.values, 1)
+		var outcome events.InventoryEvent
+		require.NoError(t, json.Unmarshal(publisher.values[0], &outcome))
+		assert.Equal(t, events.InventoryReservationFailed, outcome.Type)
+		assert.Equal(t, orderID, outcome.OrderID)
+		assert.Contains(t, outcome.Reason, "insufficient stock")
+	})
+
+	t.Run("reports backordered lines", func(t *testing.T) {
+		publisher := &recordingPublisher{}
+		handle := reservation.Handler(publisher, reservation.WithReserver(reserverFunc(func(_ context.Context, order events.OrderPlaced) ([]events.OrderItem, error) {
+			return order.Items, nil
+		})))
+		orderID, value := request(t)
+
+		require.NoError(t, handle(context.Background(), nil, value))
+
+		require.Len(t, publisher.values, 1)
+		var outcome events.InventoryEvent
+		require.NoError(t, json.Unmarshal(publisher.values[0], &outcome))
+		assert.Equal(t, events.InventoryReserved, outcome.Type)
+		assert.Equal(t, orderID, outcome.OrderID)
+		assert.True(t, outcome.Backordered)
+		require.Len(t, outcome.Items, 1)
+		assert.Equal(t, 3, outcome.Items[0].Quantity)
+	})
+
+	t.Run("store failure publishes nothing", func(t *testing.T) {
+		publisher := &recordingPublisher{}
+		handle := reservation.Handler(publisher, reservation.WithReserver(reserverFunc(func(context.Context, events.OrderPlaced) ([]events.OrderItem, error) {
+			return nil, errors.New("connection refused")
+		})))
+		_, value := request(t)
+
+		err := handle(context.Background(), nil, value)
+		assert.ErrorContains(t, err, "connection refused")
+		assert.Empty(t, publisher.values)
+	})
+
+	t.Run("publish failure after reserving is returned for redelivery", func(t *testing.T) {
+		publisher := &recordingPublisher{err: errors.New("broker unavailable")}
+		reserved := map[uuid.UUID][]events.OrderItem{}
+		handle := reservation.Handler(publisher, reservation.WithReserver(reserverFunc(func(_ context.Context, order events.OrderPlaced) ([]events.OrderItem, error) {
+			// Like stock.PostgresStore, reserving an order again is a no-op.
+			if backordered, ok := reserved[order.OrderID]; ok {
+				return backordered, nil
+			}
+			reserved[order.OrderID] = order.Items
+			return order.Items, nil
+		})))
+		orderID, value := request(t)
+
+		err := handle(context.Background(), nil, value)
+		assert.ErrorContains(t, err, "broker unavailable")
+		assert.Len(t, reserved, 1)
+
+		// The redelivered request reports the reservation already made.
+		publisher.err = nil
+		require.NoError(t, handle(context.Background(), nil, value))
+
+		require.Len(t, publisher.values, 1)
+		var outcome events.InventoryEvent
+		require.NoError(t, json.Unmarshal(publisher.values[0], &outcome))
+		assert.Equal(t, events.InventoryReserved, outcome.Type)
+		assert.Equal(t, orderID, outcome.OrderID)
+		assert.True(t, outcome.Backordered)
+		assert.Len(t, reserved, 1)
+	})
+}
+
+// releaserFunc adapts a function to reservation.Releaser.
+type releaserFunc func(ctx context.Context, orderID uuid.UUID, actor, reason string) error
+
+func (f releaserFunc) Release(ctx context.Context, orderID uuid.UUID, actor, reason string) error {
+	return f(ctx, orderID, actor, reason)
+}
+
+func TestReleaseHandler(t *testing.T) {
+	command := func(t *testing.T, orderID uuid.UUID) []byte {
+		value, err := json.Marshal(events.ReleaseInventory{OrderID: orderID, Reason: "payment failed"})
+		require.NoError(t, err)
+		return value
+	}
+
+	t.Run("releases the order's stock", func(t *testing.T) {
+		var released []string
+		handle := reservation.ReleaseHandler(releaserFunc(func(_ context.Context, orderID uuid.UUID, actor, reason string) error {
+			released = append(released, orderID.String()+" "+actor+" "+reason)
+			return nil
+		}))
+		orderID := uuid.New()
+
+		require.NoError(t, handle(context.Background(), nil, command(t, orderID)))
+		assert.Equal(t, []string{orderID.String() + " " + stock.SystemActor + " payment failed"}, released)
+	})
+
+	t.Run("order without stock is skipped", func(t *testing.T) {
+		handle := reservation.ReleaseHandler(releaserFunc(func(_ context.Context, orderID uuid.UUID, _, _ string) error {
+			return fmt.Errorf("%w: %s", stock.ErrNoReservation, orderID)
+		}))
+
+		assert.NoError(t, handle(context.Background(), nil, command(t, uuid.New())))
+	})
+
+	t.Run("store failure is returned", func(t *testing.T) {
+		handle := reservation.ReleaseHandler(releaserFunc(func(context.Context, uuid.UUID, string, string) error {
+			return errors.New("connection refused")
+		}))
+
+		assert.ErrorContains(t, handle(context.Background(), nil, command(t, uuid.New())), "connection refused")
+		assert.ErrorContains(t, handle(context.Background(), nil, []byte("{")), "failed to unmarshal release request")
+	})
+}
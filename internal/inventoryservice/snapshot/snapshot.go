@@ -0,0 +1,67 @@
+// Package snapshot periodically snapshots the inventory service's stock
+// levels, so stock as of a past time can be reconstructed, and prunes old
+// snapshots.
+package snapshot
+
+import (
+	"context"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/rs/zerolog/log"
+)
+
+// Store is the part of stock.PostgresStore the snapshotter uses.
+type Store interface {
+	TakeSnapshot(ctx context.Context, minInterval time.Duration) (stock.Snapshot, bool, error)
+	PruneSnapshots(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// Snapshotter takes a snapshot every interval and deletes snapshots older
+// than retention, keeping them all when retention is 0.
+type Snapshotter struct {
+	store     Store
+	interval  time.Duration
+	retention time.Duration
+}
+
+// NewSnapshotter creates a Snapshotter.
+func NewSnapshotter(store Store, interval, retention time.Duration) *Snapshotter {
+	return &Snapshotter{store: store, interval: interval, retention: retention}
+}
+
+// Run takes a snapshot now and then every interval until ctx is cancelled.
+// Instances sharing the database skip a snapshot when another took one
+// within the last half interval.
+func (s *Snapshotter) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick takes a snapshot and prunes expired ones.
+func (s *Snapshotter) tick(ctx context.Context) {
+	snapshot, taken, err := s.store.TakeSnapshot(ctx, s.interval/2)
+	switch {
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to snapshot stock levels")
+	case taken:
+		log.Info().Int64("snapshot_id", snapshot.ID).Int("levels", snapshot.Levels).Msg("Stock levels snapshotted")
+	}
+	if s.retention <= 0 {
+		return
+	}
+	pruned, err := s.store.PruneSnapshots(ctx, time.Now().Add(-s.retention))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to prune stock snapshots")
+	} else if pruned > 0 {
+		log.Info().Int("pruned", pruned).Msg("Pruned stock snapshots")
+	}
+}
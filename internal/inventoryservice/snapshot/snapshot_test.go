@@ -0,0 +1,46 @@
+package snapshot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingStore records the snapshot and prune calls made to it.
+type recordingStore struct {
+	minIntervals []time.Duration
+	cutoffs      []time.Time
+}
+
+func (s *recordingStore) TakeSnapshot(_ context.Context, minInterval time.Duration) (stock.Snapshot, bool, error) {
+	s.minIntervals = append(s.minIntervals, minInterval)
+	return stock.Snapshot{ID: int64(len(s.minIntervals))}, true, nil
+}
+
+func (s *recordingStore) PruneSnapshots(_ context.Context, cutoff time.Time) (int, error) {
+	s.cutoffs = append(s.cutoffs, cutoff)
+	return 0, nil
+}
+
+func TestSnapshotter_Tick(t *testing.T) {
+	store := &recordingStore{}
+	NewSnapshotter(store, time.Hour, 0).tick(context.Background())
+	assert.Equal(t, []time.Duration{30 * time.Minute}, store.minIntervals)
+	assert.Empty(t, store.cutoffs, "a retention of 0 keeps every snapshot")
+
+	NewSnapshotter(store, time.Hour, 48*time.Hour).tick(context.Background())
+	if assert.Len(t, store.cutoffs, 1) {
+		assert.WithinDuration(t, time.Now().Add(-48*time.Hour), store.cutoffs[0], time.Minute)
+	}
+}
+
+func TestSnapshotter_RunSnapshotsAtStart(t *testing.T) {
+	store := &recordingStore{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	NewSnapshotter(store, time.Hour, 0).Run(ctx)
+	assert.Len(t, store.minIntervals, 1)
+}
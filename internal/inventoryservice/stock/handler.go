@@ -0,0 +1,480 @@
+package stock
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Store is the part of PostgresStore used by the admin endpoints.
+type Store interface {
+	ListWarehouses(ctx context.Context) ([]Warehouse, error)
+	SaveWarehouse(ctx context.Context, w Warehouse) error
+	ProductStock(ctx context.Context, productID uuid.UUID) ([]Level, error)
+	AdjustStock(ctx context.Context, warehouseID string, productID uuid.UUID, adj Adjustment) (Level, error)
+	Release(ctx context.Context, orderID uuid.UUID, actor, reason string) error
+	Movements(ctx context.Context, filter MovementFilter) ([]Movement, error)
+	Import(ctx context.Context, rows []ImportRow, actor string) (ImportResult, error)
+	SetBackorderable(ctx context.Context, productID uuid.UUID, backorderable bool) error
+	BackorderableProducts(ctx context.Context) ([]uuid.UUID, error)
+	PendingBackorders(ctx context.Context, limit int) ([]Backorder, error)
+	TakeSnapshot(ctx context.Context, minInterval time.Duration) (Snapshot, bool, error)
+	Snapshots(ctx context.Context, limit int) ([]Snapshot, error)
+	StockAsOf(ctx context.Context, at time.Time, filter LevelFilter) ([]Level, error)
+}
+
+// Limits on bulk imports.
+const (
+	maxImportBytes = 32 << 20
+	maxImportRows  = 100_000
+)
+
+// Limits on the number of movements, backorders or snapshots listed per
+// request.
+const (
+	defaultMovementLimit = 100
+	maxMovementLimit     = 1000
+)
+
+// defaultActor is recorded for admin changes made without an X-Actor
+// header.
+const defaultActor = "admin"
+
+// Handler serves the stock admin endpoints. Requests must carry
+// "Authorization: Bearer <adminToken>"; every request is refused when
+// adminToken is empty. Changes are recorded in the audit log as made by the
+// actor named in the X-Actor header, or by "admin".
+type Handler struct {
+	store      Store
+	adminToken string
+}
+
+// NewHandler creates a Handler for store.
+func NewHandler(store Store, adminToken string) *Handler {
+	return &Handler{store: store, adminToken: adminToken}
+}
+
+// adjustmentRequest changes a warehouse's stock of a product by Delta units.
+// Kind is adjustment, the default, or receipt for units received, which
+// must be positive.
+type adjustmentRequest struct {
+	Delta  int          `json:"delta"`
+	Kind   MovementKind `json:"kind"`
+	Reason string       `json:"reason"`
+}
+
+// releaseRequest gives the reason reserved stock is released.
+type releaseRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Register adds the endpoints to mux:
+//
+//	GET  /admin/warehouses                                list warehouses
+//	PUT  /admin/warehouses/{id}                           create or update a warehouse
+//	GET  /admin/stock/{product_id}                        stock of a product per warehouse
+//	POST /admin/warehouses/{id}/stock/{product_id}        adjust stock by {"delta": n}
+//	POST /admin/reservations/{order_id}/release           release an order's reserved stock
+//	GET  /admin/movements                                 the stock audit log, newest first
+//	POST /inventory/import                                set or adjust stock in bulk
+//	GET  /admin/backorderable-products                    products that may be backordered
+//	PUT  /admin/backorderable-products/{product_id}       allow backordering a product
+//	DELETE /admin/backorderable-products/{product_id}     stop backordering a product
+//	GET  /admin/backorders                                pending backorders, oldest first
+//	GET  /admin/stock-snapshots                           snapshots of stock levels, newest first
+//	POST /admin/stock-snapshots                           snapshot stock levels now
+//	GET  /admin/stock?at=<RFC 3339>                       stock levels as of a time
+//
+// The audit log is filtered by the query parameters warehouse_id,
+// product_id, order_id, kind, actor, from and to (RFC 3339), and paged with
+// limit and before, the ID of the last movement of the previous page.
+// Stock as of a time can be narrowed with warehouse_id and product_id.
+//
+// Imports take a CSV body (Content-Type text/csv) with a header row naming
+// the warehouse_id, product_id, quantity and optional mode and reason
+// columns, or a JSON array of such objects. Rows without a mode use the
+// mode query parameter, set by default. All rows are applied in one
+// transaction; if any is rejected, none is, and the response reports the
+// errors per row.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.Handle("GET /admin/warehouses", h.authorized(h.listWarehouses))
+	mux.Handle("PUT /admin/warehouses/{id}", h.authorized(h.saveWarehouse))
+	mux.Handle("GET /admin/stock/{product_id}", h.authorized(h.productStock))
+	mux.Handle("POST /admin/warehouses/{id}/stock/{product_id}", h.authorized(h.adjustStock))
+	mux.Handle("POST /admin/reservations/{order_id}/release", h.authorized(h.release))
+	mux.Handle("GET /admin/movements", h.authorized(h.movements))
+	mux.Handle("POST /inventory/import", h.authorized(h.importStock))
+	mux.Handle("GET /admin/backorderable-products", h.authorized(h.backorderableProducts))
+	mux.Handle("PUT /admin/backorderable-products/{product_id}", h.authorized(h.setBackorderable(true)))
+	mux.Handle("DELETE /admin/backorderable-products/{product_id}", h.authorized(h.setBackorderable(false)))
+	mux.Handle("GET /admin/backorders", h.authorized(h.pendingBackorders))
+	mux.Handle("GET /admin/stock-snapshots", h.authorized(h.snapshots))
+	mux.Handle("POST /admin/stock-snapshots", h.authorized(h.takeSnapshot))
+	mux.Handle("GET /admin/stock", h.authorized(h.stockAsOf))
+}
+
+func (h *Handler) authorized(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if h.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (h *Handler) listWarehouses(w http.ResponseWriter, r *http.Request) {
+	warehouses, err := h.store.ListWarehouses(r.Context())
+	if err != nil {
+		writeError(r, w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, warehouses)
+}
+
+func (h *Handler) saveWarehouse(w http.ResponseWriter, r *http.Request) {
+	var warehouse Warehouse
+	if err := json.NewDecoder(r.Body).Decode(&warehouse); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+		return
+	}
+	warehouse.ID = r.PathValue("id")
+	switch {
+	case len(warehouse.ID) > 64:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "warehouse ID must be at most 64 characters"})
+		return
+	case warehouse.Name == "":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "name is required"})
+		return
+	case warehouse.Latitude < -90 || warehouse.Latitude > 90 || warehouse.Longitude < -180 || warehouse.Longitude > 180:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "latitude or longitude out of range"})
+		return
+	}
+	if err := h.store.SaveWarehouse(r.Context(), warehouse); err != nil {
+		writeError(r, w, err)
+		return
+	}
+	log.Ctx(r.Context()).Info().Str("warehouse_id", warehouse.ID).Msg("Warehouse saved")
+	writeJSON(w, http.StatusOK, warehouse)
+}
+
+func (h *Handler) productStock(w http.ResponseWriter, r *http.Request) {
+	productID, err := uuid.Parse(r.PathValue("product_id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid product ID"})
+		return
+	}
+	levels, err := h.store.ProductStock(r.Context(), productID)
+	if err != nil {
+		writeError(r, w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, levels)
+}
+
+func (h *Handler) adjustStock(w http.ResponseWriter, r *http.Request) {
+	productID, err := uuid.Parse(r.PathValue("product_id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid product ID"})
+		return
+	}
+	var req adjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Delta == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "delta must be a non-zero integer"})
+		return
+	}
+	switch req.Kind {
+	case "":
+		req.Kind = MovementAdjustment
+	case MovementAdjustment:
+	case MovementReceipt:
+		if req.Delta < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "a receipt must add stock"})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "kind must be adjustment or receipt"})
+		return
+	}
+	warehouseID := r.PathValue("id")
+	adj := Adjustment{Kind: req.Kind, Delta: req.Delta, Actor: actor(r), Reason: req.Reason}
+	level, err := h.store.AdjustStock(r.Context(), warehouseID, productID, adj)
+	if err != nil {
+		writeError(r, w, err)
+		return
+	}
+	log.Ctx(r.Context()).Info().
+		Str("warehouse_id", warehouseID).
+		Str("product_id", productID.String()).
+		Str("kind", string(adj.Kind)).
+		Str("actor", adj.Actor).
+		Int("delta", req.Delta).
+		Int("available", level.Available).
+		Msg("Stock adjusted")
+	writeJSON(w, http.StatusOK, level)
+}
+
+func (h *Handler) release(w http.ResponseWriter, r *http.Request) {
+	orderID, err := uuid.Parse(r.PathValue("order_id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid order ID"})
+		return
+	}
+	var req releaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+		return
+	}
+	if err := h.store.Release(r.Context(), orderID, actor(r), req.Reason); err != nil {
+		writeError(r, w, err)
+		return
+	}
+	log.Ctx(r.Context()).Info().Str("order_id", orderID.String()).Str("actor", actor(r)).Msg("Reservation released")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) movements(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseMovementFilter(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	movements, err := h.store.Movements(r.Context(), filter)
+	if err != nil {
+		writeError(r, w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, movements)
+}
+
+func (h *Handler) importStock(w http.ResponseWriter, r *http.Request) {
+	mode := ImportMode(cmp.Or(r.URL.Query().Get("mode"), string(ImportSet)))
+	if mode != ImportSet && mode != ImportAdjust {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "mode must be set or adjust"})
+		return
+	}
+	parse := ParseImportJSON
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		parse = ParseImportCSV
+	}
+	rows, rowErrs, err := parse(http.MaxBytesReader(w, r.Body, maxImportBytes), mode)
+	if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("an import is at most %d bytes", maxImportBytes)})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(rows)+len(rowErrs) > maxImportRows {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("an import holds at most %d rows", maxImportRows)})
+		return
+	}
+	if len(rowErrs) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, ImportResult{Errors: rowErrs})
+		return
+	}
+	if len(rows) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "the import holds no rows"})
+		return
+	}
+	result, err := h.store.Import(r.Context(), rows, actor(r))
+	if err != nil {
+		writeError(r, w, err)
+		return
+	}
+	if len(result.Errors) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+	log.Ctx(r.Context()).Info().Int("rows", result.Applied).Str("actor", actor(r)).Msg("Stock imported")
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) backorderableProducts(w http.ResponseWriter, r *http.Request) {
+	products, err := h.store.BackorderableProducts(r.Context())
+	if err != nil {
+		writeError(r, w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, products)
+}
+
+func (h *Handler) setBackorderable(backorderable bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := uuid.Parse(r.PathValue("product_id"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid product ID"})
+			return
+		}
+		if err := h.store.SetBackorderable(r.Context(), productID, backorderable); err != nil {
+			writeError(r, w, err)
+			return
+		}
+		log.Ctx(r.Context()).Info().
+			Str("product_id", productID.String()).
+			Bool("backorderable", backorderable).
+			Str("actor", actor(r)).
+			Msg("Product backordering updated")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *Handler) pendingBackorders(w http.ResponseWriter, r *http.Request) {
+	limit, ok := listLimit(w, r)
+	if !ok {
+		return
+	}
+	backorders, err := h.store.PendingBackorders(r.Context(), limit)
+	if err != nil {
+		writeError(r, w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, backorders)
+}
+
+func (h *Handler) snapshots(w http.ResponseWriter, r *http.Request) {
+	limit, ok := listLimit(w, r)
+	if !ok {
+		return
+	}
+	snapshots, err := h.store.Snapshots(r.Context(), limit)
+	if err != nil {
+		writeError(r, w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshots)
+}
+
+func (h *Handler) takeSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, _, err := h.store.TakeSnapshot(r.Context(), 0)
+	if err != nil {
+		writeError(r, w, err)
+		return
+	}
+	log.Ctx(r.Context()).Info().Int64("snapshot_id", snapshot.ID).Str("actor", actor(r)).Msg("Stock levels snapshotted")
+	writeJSON(w, http.StatusCreated, snapshot)
+}
+
+func (h *Handler) stockAsOf(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	at, err := time.Parse(time.RFC3339, q.Get("at"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "at must be an RFC 3339 time"})
+		return
+	}
+	filter := LevelFilter{WarehouseID: q.Get("warehouse_id")}
+	if v := q.Get("product_id"); v != "" {
+		if filter.ProductID, err = uuid.Parse(v); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid product_id"})
+			return
+		}
+	}
+	levels, err := h.store.StockAsOf(r.Context(), at, filter)
+	if err != nil {
+		writeError(r, w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, levels)
+}
+
+// listLimit reads the limit query parameter of a listing, writing an error
+// response and returning false if it is invalid.
+func listLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return defaultMovementLimit, true
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 || limit > maxMovementLimit {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d", maxMovementLimit)})
+		return 0, false
+	}
+	return limit, true
+}
+
+// parseMovementFilter reads a MovementFilter from the query parameters of
+// GET /admin/movements.
+func parseMovementFilter(q url.Values) (MovementFilter, error) {
+	filter := MovementFilter{
+		WarehouseID: q.Get("warehouse_id"),
+		Kind:        MovementKind(q.Get("kind")),
+		Actor:       q.Get("actor"),
+		Limit:       defaultMovementLimit,
+	}
+	var err error
+	if v := q.Get("product_id"); v != "" {
+		if filter.ProductID, err = uuid.Parse(v); err != nil {
+			return MovementFilter{}, errors.New("invalid product_id")
+		}
+	}
+	if v := q.Get("order_id"); v != "" {
+		if filter.OrderID, err = uuid.Parse(v); err != nil {
+			return MovementFilter{}, errors.New("invalid order_id")
+		}
+	}
+	switch filter.Kind {
+	case "", MovementReservation, MovementRelease, MovementAdjustment, MovementReceipt:
+	default:
+		return MovementFilter{}, errors.New("kind must be reservation, release, adjustment or receipt")
+	}
+	if v := q.Get("from"); v != "" {
+		if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return MovementFilter{}, errors.New("from must be an RFC 3339 time")
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return MovementFilter{}, errors.New("to must be an RFC 3339 time")
+		}
+	}
+	if v := q.Get("before"); v != "" {
+		if filter.Before, err = strconv.ParseInt(v, 10, 64); err != nil || filter.Before < 1 {
+			return MovementFilter{}, errors.New("before must be a movement ID")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 || filter.Limit > maxMovementLimit {
+			return MovementFilter{}, fmt.Errorf("limit must be between 1 and %d", maxMovementLimit)
+		}
+	}
+	return filter, nil
+}
+
+// actor returns who made an admin request.
+func actor(r *http.Request) string {
+	return cmp.Or(strings.TrimSpace(r.Header.Get("X-Actor")), defaultActor)
+}
+
+// writeError maps store errors to responses, logging unexpected ones.
+func writeError(r *http.Request, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownWarehouse), errors.Is(err, ErrNoReservation):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrBelowReserved):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		log.Ctx(r.Context()).Error().Err(err).Msg("Stock admin request failed")
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
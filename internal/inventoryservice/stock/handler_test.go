@@ -0,0 +1,330 @@
+package stock_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore keeps warehouses, stock levels and movements in memory.
+type memoryStore struct {
+	warehouses []stock.Warehouse
+	levels     map[string]stock.Level
+	movements  []stock.Movement
+	filter     stock.MovementFilter
+	backorders map[uuid.UUID]bool
+	asOf       time.Time
+	asOfFilter stock.LevelFilter
+}
+
+func (s *memoryStore) ListWarehouses(context.Context) ([]stock.Warehouse, error) {
+	return s.warehouses, nil
+}
+
+func (s *memoryStore) SaveWarehouse(_ context.Context, w stock.Warehouse) error {
+	s.warehouses = append(s.warehouses, w)
+	return nil
+}
+
+func (s *memoryStore) ProductStock(_ context.Context, productID uuid.UUID) ([]stock.Level, error) {
+	var levels []stock.Level
+	for _, l := range s.levels {
+		if l.ProductID == productID {
+			levels = append(levels, l)
+		}
+	}
+	return levels, nil
+}
+
+func (s *memoryStore) AdjustStock(_ context.Context, warehouseID string, productID uuid.UUID, adj stock.Adjustment) (stock.Level, error) {
+	if warehouseID != "paris" {
+		return stock.Level{}, fmt.Errorf("%w: %s", stock.ErrUnknownWarehouse, warehouseID)
+	}
+	key := warehouseID + "/" + productID.String()
+	level := s.levels[key]
+	level.WarehouseID, level.ProductID = warehouseID, productID
+	if level.Available+adj.Delta < level.Reserved {
+		return stock.Level{}, stock.ErrBelowReserved
+	}
+	s.movements = append(s.movements, stock.Movement{
+		ID: int64(len(s.movements) + 1), WarehouseID: warehouseID, ProductID: productID,
+		Kind: adj.Kind, Quantity: adj.Delta, Actor: adj.Actor, Reason: adj.Reason,
+		AvailableBefore: level.Available, AvailableAfter: level.Available + adj.Delta,
+	})
+	level.Available += adj.Delta
+	s.levels[key] = level
+	return level, nil
+}
+
+func (s *memoryStore) Release(_ context.Context, orderID uuid.UUID, actor, reason string) error {
+	return fmt.Errorf("%w: %s", stock.ErrNoReservation, orderID)
+}
+
+func (s *memoryStore) Import(ctx context.Context, rows []stock.ImportRow, actor string) (stock.ImportResult, error) {
+	var result stock.ImportResult
+	for _, row := range rows {
+		if row.WarehouseID != "paris" {
+			result.Errors = append(result.Errors, stock.RowError{Row: row.Row, Error: stock.ErrUnknownWarehouse.Error()})
+		}
+	}
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+	for _, row := range rows {
+		level := s.levels["paris/"+row.ProductID.String()]
+		delta := row.Quantity
+		if row.Mode == stock.ImportSet {
+			delta -= level.Available
+		}
+		if _, err := s.AdjustStock(ctx, row.WarehouseID, row.ProductID, stock.Adjustment{Kind: stock.MovementAdjustment, Delta: delta, Actor: actor}); err != nil {
+			return stock.ImportResult{}, err
+		}
+		result.Applied++
+	}
+	return result, nil
+}
+
+func (s *memoryStore) SetBackorderable(_ context.Context, productID uuid.UUID, backorderable bool) error {
+	if s.backorders == nil {
+		s.backorders = map[uuid.UUID]bool{}
+	}
+	if backorderable {
+		s.backorders[productID] = true
+	} else {
+		delete(s.backorders, productID)
+	}
+	return nil
+}
+
+func (s *memoryStore) BackorderableProducts(context.Context) ([]uuid.UUID, error) {
+	products := []uuid.UUID{}
+	for productID := range s.backorders {
+		products = append(products, productID)
+	}
+	return products, nil
+}
+
+func (s *memoryStore) PendingBackorders(_ context.Context, limit int) ([]stock.Backorder, error) {
+	return []stock.Backorder{{ID: 1, ProductID: uuid.Nil, Quantity: limit, Status: stock.BackorderPending}}, nil
+}
+
+func (s *memoryStore) TakeSnapshot(_ context.Context, minInterval time.Duration) (stock.Snapshot, bool, error) {
+	return stock.Snapshot{ID: 3, Levels: len(s.levels)}, minInterval == 0, nil
+}
+
+func (s *memoryStore) Snapshots(context.Context, int) ([]stock.Snapshot, error) {
+	return []stock.Snapshot{{ID: 3, Levels: len(s.levels)}}, nil
+}
+
+func (s *memoryStore) StockAsOf(_ context.Context, at time.Time, filter stock.LevelFilter) ([]stock.Level, error) {
+	s.asOf, s.asOfFilter = at, filter
+	return []stock.Level{}, nil
+}
+
+func (s *memoryStore) Movements(_ context.Context, filter stock.MovementFilter) ([]stock.Movement, error) {
+	s.filter = filter
+	return s.movements, nil
+}
+
+func newTestServer(store *memoryStore) *http.ServeMux {
+	mux := http.NewServeMux()
+	stock.NewHandler(store, "secret").Register(mux)
+	return mux
+}
+
+func do(mux http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_Stock(t *testing.T) {
+	store := &memoryStore{levels: map[string]stock.Level{}}
+	mux := newTestServer(store)
+	productID := uuid.New()
+	adjust := "/admin/warehouses/paris/stock/" + productID.String()
+
+	rec := do(mux, http.MethodPost, adjust, `{"delta": 12}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var level stock.Level
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &level))
+	assert.Equal(t, 12, level.Available)
+
+	assert.Equal(t, http.StatusConflict, do(mux, http.MethodPost, adjust, `{"delta": -13}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(mux, http.MethodPost, "/admin/warehouses/rome/stock/"+productID.String(), `{"delta": 1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(mux, http.MethodPost, adjust, `{"delta": 0}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(mux, http.MethodPost, "/admin/warehouses/paris/stock/nope", `{"delta": 1}`).Code)
+
+	rec = do(mux, http.MethodGet, "/admin/stock/"+productID.String(), "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var levels []stock.Level
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &levels))
+	assert.Equal(t, []stock.Level{{WarehouseID: "paris", ProductID: productID, Available: 12}}, levels)
+}
+
+func TestHandler_AuditLog(t *testing.T) {
+	store := &memoryStore{levels: map[string]stock.Level{}}
+	mux := newTestServer(store)
+	productID := uuid.New()
+	adjust := "/admin/warehouses/paris/stock/" + productID.String()
+
+	req := httptest.NewRequest(http.MethodPost, adjust, strings.NewReader(`{"delta": 20, "kind": "receipt", "reason": "PO-1042"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Actor", "alice")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, http.StatusOK, do(mux, http.MethodPost, adjust, `{"delta": -2, "reason": "cycle count"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(mux, http.MethodPost, adjust, `{"delta": -1, "kind": "receipt"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(mux, http.MethodPost, adjust, `{"delta": 1, "kind": "reservation"}`).Code)
+
+	require.Len(t, store.movements, 2)
+	assert.Equal(t, stock.Movement{
+		ID: 1, WarehouseID: "paris", ProductID: productID, Kind: stock.MovementReceipt, Quantity: 20,
+		Actor: "alice", Reason: "PO-1042", AvailableAfter: 20,
+	}, store.movements[0])
+	assert.Equal(t, stock.MovementAdjustment, store.movements[1].Kind)
+	assert.Equal(t, "admin", store.movements[1].Actor)
+
+	rec = do(mux, http.MethodGet, "/admin/movements?product_id="+productID.String()+"&kind=receipt&from=2026-01-01T00:00:00Z&before=10&limit=5", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var movements []stock.Movement
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &movements))
+	assert.Len(t, movements, 2)
+	assert.Equal(t, productID, store.filter.ProductID)
+	assert.Equal(t, stock.MovementReceipt, store.filter.Kind)
+	assert.Equal(t, int64(10), store.filter.Before)
+	assert.Equal(t, 5, store.filter.Limit)
+	assert.False(t, store.filter.From.IsZero())
+
+	require.Equal(t, http.StatusOK, do(mux, http.MethodGet, "/admin/movements", "").Code)
+	assert.Equal(t, 100, store.filter.Limit)
+	for _, query := range []string{"kind=theft", "limit=0", "limit=5000", "from=yesterday", "order_id=nope", "before=-1"} {
+		assert.Equal(t, http.StatusBadRequest, do(mux, http.MethodGet, "/admin/movements?"+query, "").Code, query)
+	}
+
+	assert.Equal(t, http.StatusNotFound, do(mux, http.MethodPost, "/admin/reservations/"+uuid.NewString()+"/release", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(mux, http.MethodPost, "/admin/reservations/nope/release", "").Code)
+}
+
+func TestHandler_Warehouses(t *testing.T) {
+	store := &memoryStore{}
+	mux := newTestServer(store)
+
+	rec := do(mux, http.MethodPut, "/admin/warehouses/paris", `{"name": "Paris", "latitude": 48.86, "longitude": 2.35}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []stock.Warehouse{{ID: "paris", Name: "Paris", Latitude: 48.86, Longitude: 2.35}}, store.warehouses)
+
+	assert.Equal(t, http.StatusBadRequest, do(mux, http.MethodPut, "/admin/warehouses/nowhere", `{"name": "Nowhere", "latitude": 91}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(mux, http.MethodPut, "/admin/warehouses/unnamed", `{}`).Code)
+
+	rec = do(mux, http.MethodGet, "/admin/warehouses", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"id": "paris", "name": "Paris", "latitude": 48.86, "longitude": 2.35, "priority": 0}]`, rec.Body.String())
+}
+
+func TestHandler_RequiresAdminToken(t *testing.T) {
+	mux := newTestServer(&memoryStore{})
+	req := httptest.NewRequest(http.MethodGet, "/admin/warehouses", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestHandler_Import(t *testing.T) {
+	store := &memoryStore{levels: map[string]stock.Level{}}
+	mux := newTestServer(store)
+	first, second := uuid.New(), uuid.New()
+
+	post := func(contentType, query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/inventory/import"+query, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	csv := "warehouse_id,product_id,quantity,mode\nparis," + first.String() + ",40,\nparis," + second.String() + ",5,adjust\n"
+	rec := post("text/csv; charset=utf-8", "", csv)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"applied": 2}`, rec.Body.String())
+	assert.Equal(t, 40, store.levels["paris/"+first.String()].Available)
+	assert.Equal(t, 5, store.levels["paris/"+second.String()].Available)
+
+	rec = post("application/json", "?mode=adjust", `[{"warehouse_id": "paris", "product_id": "`+first.String()+`", "quantity": -10}]`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 30, store.levels["paris/"+first.String()].Available)
+
+	// Rows rejected by the store are reported, and nothing is applied.
+	rec = post("application/json", "", `[{"warehouse_id": "paris", "product_id": "`+first.String()+`", "quantity": 1},
+		{"warehouse_id": "rome", "product_id": "`+first.String()+`", "quantity": 1}]`)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.JSONEq(t, `{"applied": 0, "errors": [{"row": 2, "error": "unknown warehouse"}]}`, rec.Body.String())
+	assert.Equal(t, 30, store.levels["paris/"+first.String()].Available)
+
+	// Malformed rows are reported before the store is called.
+	rec = post("text/csv", "", "warehouse_id,product_id,quantity\nparis,nope,1\nparis,"+first.String()+",-1\n")
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.JSONEq(t, `{"applied": 0, "errors": [{"row": 1, "error": "invalid product_id"}, {"row": 2, "error": "quantity to set must not be negative"}]}`, rec.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, post("text/csv", "", "warehouse_id,product_id\n").Code)
+	assert.Equal(t, http.StatusBadRequest, post("application/json", "?mode=replace", "[]").Code)
+	assert.Equal(t, http.StatusBadRequest, post("application/json", "", "[]").Code)
+}
+
+func TestHandler_Backorders(t *testing.T) {
+	store := &memoryStore{}
+	mux := newTestServer(store)
+	productID := uuid.New()
+	path := "/admin/backorderable-products/" + productID.String()
+
+	assert.Equal(t, http.StatusNoContent, do(mux, http.MethodPut, path, "").Code)
+	rec := do(mux, http.MethodGet, "/admin/backorderable-products", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `["`+productID.String()+`"]`, rec.Body.String())
+
+	assert.Equal(t, http.StatusNoContent, do(mux, http.MethodDelete, path, "").Code)
+	assert.Empty(t, store.backorders)
+	assert.Equal(t, http.StatusBadRequest, do(mux, http.MethodPut, "/admin/backorderable-products/nope", "").Code)
+
+	rec = do(mux, http.MethodGet, "/admin/backorders?limit=7", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var backorders []stock.Backorder
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &backorders))
+	require.Len(t, backorders, 1)
+	assert.Equal(t, 7, backorders[0].Quantity)
+	assert.Equal(t, http.StatusBadRequest, do(mux, http.MethodGet, "/admin/backorders?limit=0", "").Code)
+}
+
+func TestHandler_Snapshots(t *testing.T) {
+	store := &memoryStore{levels: map[string]stock.Level{}}
+	mux := newTestServer(store)
+	productID := uuid.New()
+
+	rec := do(mux, http.MethodPost, "/admin/stock-snapshots", "")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"id": 3, "taken_at": "0001-01-01T00:00:00Z", "levels": 0}`, rec.Body.String())
+	assert.Equal(t, http.StatusOK, do(mux, http.MethodGet, "/admin/stock-snapshots?limit=10", "").Code)
+
+	rec = do(mux, http.MethodGet, "/admin/stock?at=2026-09-30T23:59:59Z&warehouse_id=paris&product_id="+productID.String(), "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+	assert.Equal(t, time.Date(2026, 9, 30, 23, 59, 59, 0, time.UTC), store.asOf)
+	assert.Equal(t, stock.LevelFilter{WarehouseID: "paris", ProductID: productID}, store.asOfFilter)
+
+	assert.Equal(t, http.StatusBadRequest, do(mux, http.MethodGet, "/admin/stock", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(mux, http.MethodGet, "/admin/stock?at=2026-09-30T23:59:59Z&product_id=nope", "").Code)
+}
@@ -0,0 +1,164 @@
+package stock
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ImportMode says how an import row changes stock.
+type ImportMode string
+
+const (
+	// ImportSet replaces the available stock with the row's quantity.
+	ImportSet ImportMode = "set"
+	// ImportAdjust adds the row's quantity, which may be negative.
+	ImportAdjust ImportMode = "adjust"
+)
+
+// ImportRow is a line of a bulk import. Row is its 1-based position among
+// the data rows of the upload, used in error reports.
+type ImportRow struct {
+	Row         int        `json:"-"`
+	WarehouseID string     `json:"warehouse_id"`
+	ProductID   uuid.UUID  `json:"product_id"`
+	Quantity    int        `json:"quantity"`
+	Mode        ImportMode `json:"mode"`
+	Reason      string     `json:"reason"`
+}
+
+// RowError reports why an import row was rejected.
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportResult is the outcome of a bulk import: the number of rows applied,
+// or the errors of the rejected rows when nothing was applied.
+type ImportResult struct {
+	Applied int        `json:"applied"`
+	Errors  []RowError `json:"errors,omitempty"`
+}
+
+// csvColumns are the columns of a CSV import; the header names them, in any
+// order. mode and reason are optional.
+var csvColumns = []string{"warehouse_id", "product_id", "quantity", "mode", "reason"}
+
+// ParseImportCSV reads import rows from CSV with a header row. Rows without
+// a mode get defaultMode. Malformed rows are reported as row errors; err is
+// only set when the upload cannot be read at all.
+func ParseImportCSV(r io.Reader, defaultMode ImportMode) (rows []ImportRow, rowErrs []RowError, err error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(csvColumns, name) {
+			return nil, nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		index[name] = i
+	}
+	for _, name := range csvColumns[:3] {
+		if _, ok := index[name]; !ok {
+			return nil, nil, fmt.Errorf("CSV header lacks the %s column", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := index[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErrs = append(rowErrs, RowError{Row: n, Error: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		row := ImportRow{
+			Row:         n,
+			WarehouseID: field(record, "warehouse_id"),
+			Mode:        ImportMode(field(record, "mode")),
+			Reason:      field(record, "reason"),
+		}
+		if row.ProductID, err = uuid.Parse(field(record, "product_id")); err != nil {
+			rowErrs = append(rowErrs, RowError{Row: n, Error: "invalid product_id"})
+			continue
+		}
+		if row.Quantity, err = strconv.Atoi(field(record, "quantity")); err != nil {
+			rowErrs = append(rowErrs, RowError{Row: n, Error: "quantity must be an integer"})
+			continue
+		}
+		if rowErr := row.normalize(defaultMode); rowErr != nil {
+			rowErrs = append(rowErrs, *rowErr)
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrs, nil
+}
+
+// ParseImportJSON reads import rows from a JSON array of objects with the
+// fields of ImportRow. Rows without a mode get defaultMode.
+func ParseImportJSON(r io.Reader, defaultMode ImportMode) (rows []ImportRow, rowErrs []RowError, err error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode JSON rows: %w", err)
+	}
+	for i, msg := range raw {
+		row := ImportRow{Row: i + 1}
+		if err := json.Unmarshal(msg, &row); err != nil {
+			rowErrs = append(rowErrs, RowError{Row: row.Row, Error: "invalid row"})
+			continue
+		}
+		if rowErr := row.normalize(defaultMode); rowErr != nil {
+			rowErrs = append(rowErrs, *rowErr)
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrs, nil
+}
+
+// normalize applies defaultMode and checks the row's fields.
+func (row *ImportRow) normalize(defaultMode ImportMode) *RowError {
+	if row.Mode == "" {
+		row.Mode = defaultMode
+	}
+	var msg string
+	switch {
+	case row.WarehouseID == "":
+		msg = "warehouse_id is required"
+	case row.ProductID == uuid.Nil:
+		msg = "product_id is required"
+	case row.Mode != ImportSet && row.Mode != ImportAdjust:
+		msg = "mode must be set or adjust"
+	case row.Mode == ImportSet && row.Quantity < 0:
+		msg = "quantity to set must not be negative"
+	case row.Mode == ImportAdjust && row.Quantity == 0:
+		msg = "quantity to adjust by must not be zero"
+	default:
+		return nil
+	}
+	return &RowError{Row: row.Row, Error: msg}
+}
@@ -0,0 +1,53 @@
+package stock_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	productID := uuid.New()
+	input := "product_id, quantity, warehouse_id, reason\n" +
+		productID.String() + ",12,paris,annual count\n" +
+		productID.String() + ",twelve,paris\n" +
+		"\"unterminated,1,paris\n"
+
+	rows, rowErrs, err := stock.ParseImportCSV(strings.NewReader(input), stock.ImportSet)
+	require.NoError(t, err)
+	assert.Equal(t, []stock.ImportRow{{
+		Row: 1, WarehouseID: "paris", ProductID: productID, Quantity: 12, Mode: stock.ImportSet, Reason: "annual count",
+	}}, rows)
+	require.Len(t, rowErrs, 2)
+	assert.Equal(t, stock.RowError{Row: 2, Error: "quantity must be an integer"}, rowErrs[0])
+	assert.Equal(t, 3, rowErrs[1].Row)
+
+	_, _, err = stock.ParseImportCSV(strings.NewReader("warehouse_id,product_id,quantity,bin\n"), stock.ImportSet)
+	assert.Error(t, err)
+}
+
+func TestParseImportJSON(t *testing.T) {
+	productID := uuid.New()
+	input := `[
+		{"warehouse_id": "paris", "product_id": "` + productID.String() + `", "quantity": -3, "mode": "adjust"},
+		{"warehouse_id": "paris", "product_id": "` + productID.String() + `", "quantity": 0, "mode": "adjust"},
+		{"warehouse_id": "", "product_id": "` + productID.String() + `", "quantity": 1},
+		{"warehouse_id": "paris", "product_id": 7}
+	]`
+
+	rows, rowErrs, err := stock.ParseImportJSON(strings.NewReader(input), stock.ImportSet)
+	require.NoError(t, err)
+	assert.Equal(t, []stock.ImportRow{{Row: 1, WarehouseID: "paris", ProductID: productID, Quantity: -3, Mode: stock.ImportAdjust}}, rows)
+	assert.Equal(t, []stock.RowError{
+		{Row: 2, Error: "quantity to adjust by must not be zero"},
+		{Row: 3, Error: "warehouse_id is required"},
+		{Row: 4, Error: "invalid row"},
+	}, rowErrs)
+
+	_, _, err = stock.ParseImportJSON(strings.NewReader(`{"rows": []}`), stock.ImportSet)
+	assert.Error(t, err)
+}
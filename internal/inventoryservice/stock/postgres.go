@@ -0,0 +1,723 @@
+package stock
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/lib/pq"
+)
+
+// PostgreSQL error codes mapped to the package's errors.
+const (
+	foreignKeyViolation = "23503"
+	checkViolation      = "23514"
+)
+
+// PostgresStore keeps warehouses, stock levels and reservations in the
+// inventory database.
+type PostgresStore struct {
+	db       *sql.DB
+	strategy Strategy
+}
+
+// NewPostgresStore creates a PostgresStore reserving stock with strategy.
+func NewPostgresStore(db *sql.DB, strategy Strategy) *PostgresStore {
+	return &PostgresStore{db: db, strategy: strategy}
+}
+
+// ListWarehouses returns every warehouse by priority, then ID.
+func (s *PostgresStore) ListWarehouses(ctx context.Context) ([]Warehouse, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, latitude, longitude, priority FROM warehouses ORDER BY priority, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list warehouses: %w", err)
+	}
+	defer rows.Close()
+
+	warehouses := []Warehouse{}
+	for rows.Next() {
+		var w Warehouse
+		if err := rows.Scan(&w.ID, &w.Name, &w.Latitude, &w.Longitude, &w.Priority); err != nil {
+			return nil, fmt.Errorf("failed to scan warehouse: %w", err)
+		}
+		warehouses = append(warehouses, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over warehouses: %w", err)
+	}
+	return warehouses, nil
+}
+
+// SaveWarehouse creates w or updates the warehouse with its ID.
+func (s *PostgresStore) SaveWarehouse(ctx context.Context, w Warehouse) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO warehouses (id, name, latitude, longitude, priority)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name, latitude = EXCLUDED.latitude,
+			longitude = EXCLUDED.longitude, priority = EXCLUDED.priority`,
+		w.ID, w.Name, w.Latitude, w.Longitude, w.Priority)
+	if err != nil {
+		return fmt.Errorf("failed to save warehouse %s: %w", w.ID, err)
+	}
+	return nil
+}
+
+// ProductStock returns the stock of productID in each warehouse holding it.
+func (s *PostgresStore) ProductStock(ctx context.Context, productID uuid.UUID) ([]Level, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT warehouse_id, product_id, available, reserved
+		FROM stock_levels WHERE product_id = $1
+		ORDER BY warehouse_id`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock of product %s: %w", productID, err)
+	}
+	defer rows.Close()
+
+	levels := []Level{}
+	for rows.Next() {
+		var l Level
+		if err := rows.Scan(&l.WarehouseID, &l.ProductID, &l.Available, &l.Reserved); err != nil {
+			return nil, fmt.Errorf("failed to scan stock level: %w", err)
+		}
+		levels = append(levels, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over stock levels: %w", err)
+	}
+	return levels, nil
+}
+
+// StockLevels returns the stock of productIDs in each warehouse holding
+// them, by product, then warehouse.
+func (s *PostgresStore) StockLevels(ctx context.Context, productIDs []uuid.UUID) ([]Level, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT warehouse_id, product_id, available, reserved
+		FROM stock_levels WHERE product_id = ANY($1)
+		ORDER BY product_id, warehouse_id`, pq.Array(productIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock levels: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []Level{}
+	for rows.Next() {
+		var l Level
+		if err := rows.Scan(&l.WarehouseID, &l.ProductID, &l.Available, &l.Reserved); err != nil {
+			return nil, fmt.Errorf("failed to scan stock level: %w", err)
+		}
+		levels = append(levels, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over stock levels: %w", err)
+	}
+	return levels, nil
+}
+
+// Reservations returns the reservations of orderID, by product.
+func (s *PostgresStore) Reservations(ctx context.Context, orderID uuid.UUID) ([]Reservation, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT order_id, product_id, warehouse_id, quantity, status
+		FROM reservations WHERE order_id = $1
+		ORDER BY product_id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reservations of order %s: %w", orderID, err)
+	}
+	defer rows.Close()
+
+	reservations := []Reservation{}
+	for rows.Next() {
+		var r Reservation
+		if err := rows.Scan(&r.OrderID, &r.ProductID, &r.WarehouseID, &r.Quantity, &r.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan reservation: %w", err)
+		}
+		reservations = append(reservations, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over reservations: %w", err)
+	}
+	return reservations, nil
+}
+
+// AdjustStock adds adj.Delta, which may be negative for adjustments, to the
+// available stock of productID in warehouseID, records the movement and
+// returns the new level. It fails with ErrUnknownWarehouse or
+// ErrBelowReserved.
+func (s *PostgresStore) AdjustStock(ctx context.Context, warehouseID string, productID uuid.UUID, adj Adjustment) (Level, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Level{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	level, err := adjustStock(ctx, tx, warehouseID, productID, adj)
+	if err != nil {
+		return Level{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Level{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return level, nil
+}
+
+// Import applies rows in one transaction, by product so that stock levels
+// are locked in the order reservations lock them, recording each change as
+// an adjustment made by actor. If any row fails with ErrUnknownWarehouse or
+// ErrBelowReserved, nothing is applied and the result reports every failed
+// row.
+func (s *PostgresStore) Import(ctx context.Context, rows []ImportRow, actor string) (ImportResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows = slices.Clone(rows)
+	slices.SortStableFunc(rows, func(a, b ImportRow) int {
+		return cmp.Or(cmp.Compare(a.ProductID.String(), b.ProductID.String()), cmp.Compare(a.WarehouseID, b.WarehouseID))
+	})
+	var result ImportResult
+	for _, row := range rows {
+		// A savepoint per row keeps the transaction usable after a row
+		// fails, so the remaining rows are still checked.
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT import_row"); err != nil {
+			return ImportResult{}, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		err := importRow(ctx, tx, row, actor)
+		if errors.Is(err, ErrUnknownWarehouse) || errors.Is(err, ErrBelowReserved) {
+			result.Errors = append(result.Errors, RowError{Row: row.Row, Error: err.Error()})
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_row"); err != nil {
+				return ImportResult{}, fmt.Errorf("failed to roll back to savepoint: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("failed to import row %d: %w", row.Row, err)
+		}
+		result.Applied++
+	}
+	if len(result.Errors) > 0 {
+		slices.SortFunc(result.Errors, func(a, b RowError) int { return cmp.Compare(a.Row, b.Row) })
+		return ImportResult{Errors: result.Errors}, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return ImportResult{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result, nil
+}
+
+// importRow applies an import row within tx.
+func importRow(ctx context.Context, tx *sql.Tx, row ImportRow, actor string) error {
+	delta := row.Quantity
+	if row.Mode == ImportSet {
+		var available int
+		err := tx.QueryRowContext(ctx, `
+			SELECT available FROM stock_levels
+			WHERE warehouse_id = $1 AND product_id = $2
+			FOR UPDATE`, row.WarehouseID, row.ProductID).Scan(&available)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to get stock of product %s in warehouse %s: %w", row.ProductID, row.WarehouseID, err)
+		}
+		delta = row.Quantity - available
+		if delta == 0 && err == nil {
+			return nil
+		}
+	}
+	_, err := adjustStock(ctx, tx, row.WarehouseID, row.ProductID, Adjustment{
+		Kind:   MovementAdjustment,
+		Delta:  delta,
+		Actor:  actor,
+		Reason: cmp.Or(row.Reason, "bulk import"),
+	})
+	return err
+}
+
+// adjustStock applies adj within tx; see AdjustStock.
+func adjustStock(ctx context.Context, tx *sql.Tx, warehouseID string, productID uuid.UUID, adj Adjustment) (Level, error) {
+	level := Level{WarehouseID: warehouseID, ProductID: productID}
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO stock_levels (warehouse_id, product_id, available)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (warehouse_id, product_id) DO UPDATE SET available = stock_levels.available + EXCLUDED.available
+		RETURNING available, reserved`, warehouseID, productID, adj.Delta).Scan(&level.Available, &level.Reserved)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case foreignKeyViolation:
+			return Level{}, fmt.Errorf("%w: %s", ErrUnknownWarehouse, warehouseID)
+		case checkViolation:
+			return Level{}, ErrBelowReserved
+		}
+	}
+	if err != nil {
+		return Level{}, fmt.Errorf("failed to adjust stock of product %s in warehouse %s: %w", productID, warehouseID, err)
+	}
+	if err := recordMovement(ctx, tx, Movement{
+		WarehouseID:     warehouseID,
+		ProductID:       productID,
+		Kind:            adj.Kind,
+		Quantity:        adj.Delta,
+		Actor:           adj.Actor,
+		Reason:          adj.Reason,
+		AvailableBefore: level.Available - adj.Delta,
+		AvailableAfter:  level.Available,
+		ReservedBefore:  level.Reserved,
+		ReservedAfter:   level.Reserved,
+	}); err != nil {
+		return Level{}, err
+	}
+	return level, nil
+}
+
+// Reserve reserves the order's items, each line from the warehouse its
+// strategy picks, in one transaction, and returns the lines backordered.
+// A line of a backorderable product that no warehouse can reserve is
+// backordered rather than failing the order. Reserving an order again is a
+// no-op returning its pending backorders, so redelivered requests do not
+// reserve twice. It fails with ErrInsufficientStock, reserving nothing, if
+// another line cannot be reserved.
+func (s *PostgresStore) Reserve(ctx context.Context, order events.OrderPlaced) ([]events.OrderItem, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var reserved bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM reservations WHERE order_id = $1)
+			OR EXISTS (SELECT 1 FROM backorders WHERE order_id = $1)`, order.OrderID).Scan(&reserved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check reservations of order %s: %w", order.OrderID, err)
+	}
+	if reserved {
+		return pendingBackorders(ctx, tx, order.OrderID)
+	}
+
+	var backordered []events.OrderItem
+	for _, line := range orderLines(order.Items) {
+		warehouseID, err := s.pickWarehouse(ctx, tx, line, order.Destination)
+		if errors.Is(err, ErrInsufficientStock) {
+			ok, backorderErr := backorder(ctx, tx, order, line)
+			if backorderErr != nil {
+				return nil, backorderErr
+			}
+			if ok {
+				backordered = append(backordered, line)
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := reserveLine(ctx, tx, order.OrderID, line, warehouseID, "order placed"); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return backordered, nil
+}
+
+// reserveLine reserves line for orderID in warehouseID and records the
+// reservation for reason.
+func reserveLine(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, line events.OrderItem, warehouseID, reason string) error {
+	var available, reserved int
+	if err := tx.QueryRowContext(ctx, `
+		UPDATE stock_levels SET reserved = reserved + $3
+		WHERE warehouse_id = $1 AND product_id = $2
+		RETURNING available, reserved`, warehouseID, line.ProductID, line.Quantity).Scan(&available, &reserved); err != nil {
+		return fmt.Errorf("failed to reserve product %s in warehouse %s: %w", line.ProductID, warehouseID, err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO reservations (order_id, product_id, warehouse_id, quantity, status)
+		VALUES ($1, $2, $3, $4, $5)`, orderID, line.ProductID, warehouseID, line.Quantity, ReservedStatus); err != nil {
+		return fmt.Errorf("failed to record reservation of product %s: %w", line.ProductID, err)
+	}
+	return recordMovement(ctx, tx, Movement{
+		WarehouseID:     warehouseID,
+		ProductID:       line.ProductID,
+		Kind:            MovementReservation,
+		Quantity:        line.Quantity,
+		OrderID:         &orderID,
+		Actor:           SystemActor,
+		Reason:          reason,
+		AvailableBefore: available,
+		AvailableAfter:  available,
+		ReservedBefore:  reserved - line.Quantity,
+		ReservedAfter:   reserved,
+	})
+}
+
+// backorder queues line of order if its product is backorderable, and
+// reports whether it did.
+func backorder(ctx context.Context, tx *sql.Tx, order events.OrderPlaced, line events.OrderItem) (bool, error) {
+	var latitude, longitude *float64
+	if order.Destination != nil {
+		latitude, longitude = &order.Destination.Latitude, &order.Destination.Longitude
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO backorders (order_id, product_id, quantity, destination_latitude, destination_longitude)
+		SELECT $1, $2, $3, $4, $5
+		WHERE EXISTS (SELECT 1 FROM backorderable_products WHERE product_id = $2)`,
+		order.OrderID, line.ProductID, line.Quantity, latitude, longitude)
+	if err != nil {
+		return false, fmt.Errorf("failed to backorder product %s: %w", line.ProductID, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to backorder product %s: %w", line.ProductID, err)
+	}
+	return n > 0, nil
+}
+
+// pendingBackorders returns the lines of orderID still waiting for stock.
+func pendingBackorders(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) ([]events.OrderItem, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT product_id, quantity FROM backorders
+		WHERE order_id = $1 AND status = $2
+		ORDER BY product_id`, orderID, BackorderPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backorders of order %s: %w", orderID, err)
+	}
+	defer rows.Close()
+
+	var lines []events.OrderItem
+	for rows.Next() {
+		var line events.OrderItem
+		if err := rows.Scan(&line.ProductID, &line.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan backorder: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over backorders: %w", err)
+	}
+	return lines, nil
+}
+
+// SetBackorderable allows or stops overselling productID into backorders.
+// Stopping it leaves the product's pending backorders queued.
+func (s *PostgresStore) SetBackorderable(ctx context.Context, productID uuid.UUID, backorderable bool) error {
+	query := `INSERT INTO backorderable_products (product_id) VALUES ($1) ON CONFLICT (product_id) DO NOTHING`
+	if !backorderable {
+		query = `DELETE FROM backorderable_products WHERE product_id = $1`
+	}
+	if _, err := s.db.ExecContext(ctx, query, productID); err != nil {
+		return fmt.Errorf("failed to update backordering of product %s: %w", productID, err)
+	}
+	return nil
+}
+
+// BackorderableProducts returns the products that may be backordered.
+func (s *PostgresStore) BackorderableProducts(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT product_id FROM backorderable_products ORDER BY product_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backorderable products: %w", err)
+	}
+	defer rows.Close()
+
+	products := []uuid.UUID{}
+	for rows.Next() {
+		var productID uuid.UUID
+		if err := rows.Scan(&productID); err != nil {
+			return nil, fmt.Errorf("failed to scan backorderable product: %w", err)
+		}
+		products = append(products, productID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over backorderable products: %w", err)
+	}
+	return products, nil
+}
+
+// PendingBackorders returns up to limit backorders waiting for stock, in
+// arrival order.
+func (s *PostgresStore) PendingBackorders(ctx context.Context, limit int) ([]Backorder, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, order_id, product_id, quantity, destination_latitude, destination_longitude,
+			status, COALESCE(warehouse_id, ''), created_at
+		FROM backorders WHERE status = $1
+		ORDER BY id LIMIT $2`, BackorderPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backorders: %w", err)
+	}
+	defer rows.Close()
+
+	backorders := []Backorder{}
+	for rows.Next() {
+		var b Backorder
+		var latitude, longitude sql.NullFloat64
+		if err := rows.Scan(&b.ID, &b.OrderID, &b.ProductID, &b.Quantity, &latitude, &longitude,
+			&b.Status, &b.WarehouseID, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan backorder: %w", err)
+		}
+		if latitude.Valid && longitude.Valid {
+			b.Destination = &events.Location{Latitude: latitude.Float64, Longitude: longitude.Float64}
+		}
+		backorders = append(backorders, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over backorders: %w", err)
+	}
+	return backorders, nil
+}
+
+// AllocateBackorder reserves stock for a pending backorder from the
+// warehouse the strategy picks, and returns it allocated. It returns false
+// if no warehouse has enough free stock yet, or if the backorder is no
+// longer pending.
+func (s *PostgresStore) AllocateBackorder(ctx context.Context, b Backorder) (Backorder, bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Backorder{}, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED lets another instance allocating the same backorder win.
+	var pending bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT TRUE FROM backorders WHERE id = $1 AND status = $2
+		FOR UPDATE SKIP LOCKED`, b.ID, BackorderPending).Scan(&pending)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Backorder{}, false, nil
+	}
+	if err != nil {
+		return Backorder{}, false, fmt.Errorf("failed to lock backorder %d: %w", b.ID, err)
+	}
+
+	line := events.OrderItem{ProductID: b.ProductID, Quantity: b.Quantity}
+	warehouseID, err := s.pickWarehouse(ctx, tx, line, b.Destination)
+	if errors.Is(err, ErrInsufficientStock) {
+		return Backorder{}, false, nil
+	}
+	if err != nil {
+		return Backorder{}, false, err
+	}
+	if err := reserveLine(ctx, tx, b.OrderID, line, warehouseID, "backorder allocated"); err != nil {
+		return Backorder{}, false, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE backorders SET status = $2, warehouse_id = $3, allocated_at = NOW()
+		WHERE id = $1`, b.ID, BackorderAllocated, warehouseID); err != nil {
+		return Backorder{}, false, fmt.Errorf("failed to mark backorder %d allocated: %w", b.ID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return Backorder{}, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	b.Status, b.WarehouseID = BackorderAllocated, warehouseID
+	return b, true, nil
+}
+
+// Release cancels the pending backorders of orderID, frees the stock
+// reserved for it and records a release for each line, made by actor for
+// reason. It fails with ErrNoReservation if the order holds neither.
+func (s *PostgresStore) Release(ctx context.Context, orderID uuid.UUID, actor, reason string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Cancel the pending backorders first. Locking them waits for an
+	// allocation in progress to commit its reservation, which the update of
+	// the reservations below then sees and releases; allocations starting
+	// later find the backorders cancelled.
+	if _, err := tx.ExecContext(ctx, `
+		SELECT id FROM backorders WHERE order_id = $1 AND status = $2
+		FOR UPDATE`, orderID, BackorderPending); err != nil {
+		return fmt.Errorf("failed to lock backorders of order %s: %w", orderID, err)
+	}
+	result, err := tx.ExecContext(ctx, `
+		UPDATE backorders SET status = $2 WHERE order_id = $1 AND status = $3`, orderID, BackorderCancelled, BackorderPending)
+	if err != nil {
+		return fmt.Errorf("failed to cancel backorders of order %s: %w", orderID, err)
+	}
+	cancelled, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to cancel backorders of order %s: %w", orderID, err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE reservations SET status = $2
+		WHERE order_id = $1 AND status = $3
+		RETURNING warehouse_id, product_id, quantity`, orderID, ReleasedStatus, ReservedStatus)
+	if err != nil {
+		return fmt.Errorf("failed to release reservations of order %s: %w", orderID, err)
+	}
+	var lines []Movement
+	for rows.Next() {
+		m := Movement{Kind: MovementRelease, OrderID: &orderID, Actor: actor, Reason: reason}
+		if err := rows.Scan(&m.WarehouseID, &m.ProductID, &m.Quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan reservation: %w", err)
+		}
+		lines = append(lines, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over reservations: %w", err)
+	}
+	if len(lines) == 0 && cancelled == 0 {
+		return fmt.Errorf("%w: %s", ErrNoReservation, orderID)
+	}
+
+	// Lock stock levels in the order reservations do.
+	slices.SortFunc(lines, func(a, b Movement) int {
+		return cmp.Or(cmp.Compare(a.ProductID.String(), b.ProductID.String()), cmp.Compare(a.WarehouseID, b.WarehouseID))
+	})
+	for _, m := range lines {
+		if err := tx.QueryRowContext(ctx, `
+			UPDATE stock_levels SET reserved = reserved - $3
+			WHERE warehouse_id = $1 AND product_id = $2
+			RETURNING available, reserved`, m.WarehouseID, m.ProductID, m.Quantity).Scan(&m.AvailableAfter, &m.ReservedAfter); err != nil {
+			return fmt.Errorf("failed to release product %s in warehouse %s: %w", m.ProductID, m.WarehouseID, err)
+		}
+		m.Quantity = -m.Quantity
+		m.AvailableBefore = m.AvailableAfter
+		m.ReservedBefore = m.ReservedAfter - m.Quantity
+		if err := recordMovement(ctx, tx, m); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Movements returns the movements matching filter, newest first.
+func (s *PostgresStore) Movements(ctx context.Context, filter MovementFilter) ([]Movement, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.WarehouseID != "" {
+		add("warehouse_id = $%d", filter.WarehouseID)
+	}
+	if filter.ProductID != uuid.Nil {
+		add("product_id = $%d", filter.ProductID)
+	}
+	if filter.OrderID != uuid.Nil {
+		add("order_id = $%d", filter.OrderID)
+	}
+	if filter.Kind != "" {
+		add("kind = $%d", string(filter.Kind))
+	}
+	if filter.Actor != "" {
+		add("actor = $%d", filter.Actor)
+	}
+	if !filter.From.IsZero() {
+		add("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("created_at < $%d", filter.To)
+	}
+	if filter.Before > 0 {
+		add("id < $%d", filter.Before)
+	}
+	query := `
+		SELECT id, warehouse_id, product_id, kind, quantity, order_id, actor, reason,
+			available_before, available_after, reserved_before, reserved_after, created_at
+		FROM stock_movements`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stock movements: %w", err)
+	}
+	defer rows.Close()
+
+	movements := []Movement{}
+	for rows.Next() {
+		var m Movement
+		var orderID uuid.NullUUID
+		if err := rows.Scan(&m.ID, &m.WarehouseID, &m.ProductID, &m.Kind, &m.Quantity, &orderID, &m.Actor, &m.Reason,
+			&m.AvailableBefore, &m.AvailableAfter, &m.ReservedBefore, &m.ReservedAfter, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan stock movement: %w", err)
+		}
+		if orderID.Valid {
+			m.OrderID = &orderID.UUID
+		}
+		movements = append(movements, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over stock movements: %w", err)
+	}
+	return movements, nil
+}
+
+// recordMovement appends m to the stock audit log.
+func recordMovement(ctx context.Context, tx *sql.Tx, m Movement) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO stock_movements (warehouse_id, product_id, kind, quantity, order_id, actor, reason,
+			available_before, available_after, reserved_before, reserved_after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		m.WarehouseID, m.ProductID, string(m.Kind), m.Quantity, m.OrderID, m.Actor, m.Reason,
+		m.AvailableBefore, m.AvailableAfter, m.ReservedBefore, m.ReservedAfter)
+	if err != nil {
+		return fmt.Errorf("failed to record %s of product %s: %w", m.Kind, m.ProductID, err)
+	}
+	return nil
+}
+
+// pickWarehouse locks the stock levels of line's product and returns the
+// warehouse the strategy picks to reserve it from.
+func (s *PostgresStore) pickWarehouse(ctx context.Context, tx *sql.Tx, line events.OrderItem, destination *events.Location) (string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT w.id, w.name, w.latitude, w.longitude, w.priority, s.available - s.reserved
+		FROM stock_levels s JOIN warehouses w ON w.id = s.warehouse_id
+		WHERE s.product_id = $1
+		FOR UPDATE OF s`, line.ProductID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get stock of product %s: %w", line.ProductID, err)
+	}
+	defer rows.Close()
+
+	var candidates []Candidate
+	for rows.Next() {
+		var c Candidate
+		if err := rows.Scan(&c.ID, &c.Name, &c.Latitude, &c.Longitude, &c.Priority, &c.Free); err != nil {
+			return "", fmt.Errorf("failed to scan stock level: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("error iterating over stock levels: %w", err)
+	}
+	warehouseID, ok := s.strategy.Pick(candidates, line.Quantity, destination)
+	if !ok {
+		return "", fmt.Errorf("%w: product %s needs %d", ErrInsufficientStock, line.ProductID, line.Quantity)
+	}
+	return warehouseID, nil
+}
+
+// orderLines merges the items of the same product and sorts them by product
+// ID, so concurrent reservations lock stock levels in the same order.
+func orderLines(items []events.OrderItem) []events.OrderItem {
+	var lines []events.OrderItem
+	for _, item := range items {
+		i := slices.IndexFunc(lines, func(line events.OrderItem) bool { return line.ProductID == item.ProductID })
+		if i < 0 {
+			lines = append(lines, events.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity})
+			continue
+		}
+		lines[i].Quantity += item.Quantity
+	}
+	slices.SortFunc(lines, func(a, b events.OrderItem) int { return cmp.Compare(a.ProductID.String(), b.ProductID.String()) })
+	return lines
+}
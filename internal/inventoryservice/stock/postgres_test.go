@@ -0,0 +1,99 @@
+package stock_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/migrations"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/migration"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDB connects to the inventory database at INVENTORY_DATABASE_URL
+// and migrates it, or skips the test when the variable is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	databaseURL := os.Getenv("INVENTORY_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("INVENTORY_DATABASE_URL is not set")
+	}
+	_, err := migration.Up(migrations.FS, databaseURL)
+	require.NoError(t, err)
+	db, err := sql.Open("postgres", databaseURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// waitingForLocks returns the number of statements of the test database
+// waiting for a lock.
+func waitingForLocks(t *testing.T, db *sql.DB) int {
+	var n int
+	require.NoError(t, db.QueryRow(`
+		SELECT count(*) FROM pg_stat_activity
+		WHERE datname = current_database() AND wait_event_type = 'Lock'`).Scan(&n))
+	return n
+}
+
+func TestPostgresStore_ReleaseDuringBackorderAllocation(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	store := stock.NewPostgresStore(db, stock.StrategyPriority)
+	productID, orderID := uuid.New(), uuid.New()
+
+	// The order's only line is backordered, then stock arrives for it.
+	require.NoError(t, store.SetBackorderable(ctx, productID, true))
+	backordered, err := store.Reserve(ctx, events.OrderPlaced{OrderID: orderID, Items: []events.OrderItem{{ProductID: productID, Quantity: 1}}})
+	require.NoError(t, err)
+	require.Len(t, backordered, 1)
+	_, err = store.AdjustStock(ctx, "default", productID, stock.Adjustment{Kind: stock.MovementReceipt, Delta: 1, Actor: "test", Reason: "restock"})
+	require.NoError(t, err)
+	pending, err := store.PendingBackorders(ctx, 1000)
+	require.NoError(t, err)
+	var backorder stock.Backorder
+	for _, b := range pending {
+		if b.OrderID == orderID {
+			backorder = b
+		}
+	}
+	require.Equal(t, orderID, backorder.OrderID)
+
+	// Holding the stock level stops the allocation after it has locked the
+	// backorder and before it reserves.
+	hold, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer hold.Rollback()
+	_, err = hold.ExecContext(ctx, `SELECT 1 FROM stock_levels WHERE product_id = $1 FOR UPDATE`, productID)
+	require.NoError(t, err)
+	baseline := waitingForLocks(t, db)
+
+	allocated := make(chan bool, 1)
+	go func() {
+		_, ok, err := store.AllocateBackorder(ctx, backorder)
+		assert.NoError(t, err)
+		allocated <- ok
+	}()
+	require.Eventually(t, func() bool { return waitingForLocks(t, db) == baseline+1 }, 5*time.Second, 10*time.Millisecond)
+
+	released := make(chan error, 1)
+	go func() { released <- store.Release(ctx, orderID, "test", "order cancelled") }()
+	require.Eventually(t, func() bool { return waitingForLocks(t, db) == baseline+2 }, 5*time.Second, 10*time.Millisecond)
+
+	// The allocation commits its reservation while the release waits.
+	require.NoError(t, hold.Commit())
+	assert.True(t, <-allocated)
+	require.NoError(t, <-released)
+
+	reservations, err := store.Reservations(ctx, orderID)
+	require.NoError(t, err)
+	require.Len(t, reservations, 1)
+	assert.Equal(t, stock.ReleasedStatus, reservations[0].Status, "Expected the reservation allocated during the release to be released")
+}
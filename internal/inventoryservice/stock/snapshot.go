@@ -0,0 +1,165 @@
+package stock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// snapshotLockID is the advisory lock serializing snapshots across
+// instances.
+const snapshotLockID = 0x736e6170 // "snap"
+
+// Snapshot is a copy of every stock level taken at TakenAt.
+type Snapshot struct {
+	ID      int64     `json:"id"`
+	TakenAt time.Time `json:"taken_at"`
+	Levels  int       `json:"levels"`
+}
+
+// LevelFilter selects stock levels. Zero fields match every level.
+type LevelFilter struct {
+	WarehouseID string
+	ProductID   uuid.UUID
+}
+
+// TakeSnapshot copies every stock level into a new snapshot, unless
+// another snapshot was taken less than minInterval ago, in which case it
+// returns false. Instances sharing the database thus take one snapshot
+// per interval between them.
+func (s *PostgresStore) TakeSnapshot(ctx context.Context, minInterval time.Duration) (Snapshot, bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, snapshotLockID); err != nil {
+		return Snapshot{}, false, fmt.Errorf("failed to lock snapshots: %w", err)
+	}
+	var recent bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM stock_snapshots WHERE taken_at > NOW() - make_interval(secs => $1))`,
+		minInterval.Seconds()).Scan(&recent)
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("failed to check recent snapshots: %w", err)
+	}
+	if recent {
+		return Snapshot{}, false, nil
+	}
+
+	var snapshot Snapshot
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO stock_snapshots DEFAULT VALUES RETURNING id, taken_at`).Scan(&snapshot.ID, &snapshot.TakenAt); err != nil {
+		return Snapshot{}, false, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO stock_snapshot_levels (snapshot_id, warehouse_id, product_id, available, reserved)
+		SELECT $1, warehouse_id, product_id, available, reserved FROM stock_levels`, snapshot.ID)
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("failed to copy stock levels into snapshot %d: %w", snapshot.ID, err)
+	}
+	levels, err := result.RowsAffected()
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("failed to copy stock levels into snapshot %d: %w", snapshot.ID, err)
+	}
+	snapshot.Levels = int(levels)
+	if err := tx.Commit(); err != nil {
+		return Snapshot{}, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return snapshot, true, nil
+}
+
+// Snapshots returns up to limit snapshots, newest first.
+func (s *PostgresStore) Snapshots(ctx context.Context, limit int) ([]Snapshot, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.id, s.taken_at, (SELECT COUNT(*) FROM stock_snapshot_levels l WHERE l.snapshot_id = s.id)
+		FROM stock_snapshots s
+		ORDER BY s.taken_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []Snapshot{}
+	for rows.Next() {
+		var snapshot Snapshot
+		if err := rows.Scan(&snapshot.ID, &snapshot.TakenAt, &snapshot.Levels); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+// PruneSnapshots deletes the snapshots taken before cutoff, except the
+// latest of them, which stock as of later times still starts from. It
+// returns the number deleted.
+func (s *PostgresStore) PruneSnapshots(ctx context.Context, cutoff time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM stock_snapshots
+		WHERE taken_at < $1
+			AND id <> (SELECT id FROM stock_snapshots WHERE taken_at < $1 ORDER BY taken_at DESC LIMIT 1)`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune snapshots: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune snapshots: %w", err)
+	}
+	return int(n), nil
+}
+
+// StockAsOf returns the stock levels matching filter as they stood at at:
+// those of the latest snapshot taken at or before at, updated by the last
+// movement of each level recorded after the snapshot and up to at. Levels
+// that did not exist at at are left out.
+func (s *PostgresStore) StockAsOf(ctx context.Context, at time.Time, filter LevelFilter) ([]Level, error) {
+	productID := uuid.NullUUID{UUID: filter.ProductID, Valid: filter.ProductID != uuid.Nil}
+	rows, err := s.db.QueryContext(ctx, `
+		WITH snapshot AS (
+			SELECT id, taken_at FROM stock_snapshots
+			WHERE taken_at <= $1
+			ORDER BY taken_at DESC LIMIT 1
+		),
+		base AS (
+			SELECT l.warehouse_id, l.product_id, l.available, l.reserved
+			FROM stock_snapshot_levels l JOIN snapshot s ON s.id = l.snapshot_id
+			WHERE ($2 = '' OR l.warehouse_id = $2) AND ($3::uuid IS NULL OR l.product_id = $3)
+		),
+		moved AS (
+			SELECT DISTINCT ON (warehouse_id, product_id)
+				warehouse_id, product_id, available_after AS available, reserved_after AS reserved
+			FROM stock_movements
+			WHERE created_at <= $1
+				AND created_at > COALESCE((SELECT taken_at FROM snapshot), '-infinity')
+				AND ($2 = '' OR warehouse_id = $2) AND ($3::uuid IS NULL OR product_id = $3)
+			ORDER BY warehouse_id, product_id, id DESC
+		)
+		SELECT warehouse_id, product_id,
+			COALESCE(m.available, b.available), COALESCE(m.reserved, b.reserved)
+		FROM base b FULL JOIN moved m USING (warehouse_id, product_id)
+		ORDER BY product_id, warehouse_id`, at, filter.WarehouseID, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock as of %s: %w", at.Format(time.RFC3339), err)
+	}
+	defer rows.Close()
+
+	levels := []Level{}
+	for rows.Next() {
+		var l Level
+		if err := rows.Scan(&l.WarehouseID, &l.ProductID, &l.Available, &l.Reserved); err != nil {
+			return nil, fmt.Errorf("failed to scan stock level: %w", err)
+		}
+		levels = append(levels, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over stock levels: %w", err)
+	}
+	return levels, nil
+}
@@ -0,0 +1,228 @@
+// Package stock tracks the inventory service's stock per warehouse and
+// reserves it for orders, picking a warehouse for each order line with a
+// reservation strategy.
+package stock
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+)
+
+var (
+	// ErrInsufficientStock is returned when no single warehouse has enough
+	// free stock for an order line.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrUnknownWarehouse is returned when stock is adjusted in a warehouse
+	// that does not exist.
+	ErrUnknownWarehouse = errors.New("unknown warehouse")
+	// ErrBelowReserved is returned when an adjustment would leave a
+	// warehouse with less stock of a product than is reserved, or below zero.
+	ErrBelowReserved = errors.New("stock would drop below the reserved quantity")
+	// ErrNoReservation is returned when releasing an order that holds no
+	// reservation.
+	ErrNoReservation = errors.New("order holds no reservation")
+)
+
+// SystemActor is the actor recorded for stock changes the service makes on
+// its own, such as reserving stock for an order.
+const SystemActor = "inventory-service"
+
+// Config selects how stock is reserved.
+type Config struct {
+	// Strategy picks the warehouse each order line is reserved from.
+	Strategy Strategy `key:"reservation_strategy" env:"STOCK_RESERVATION_STRATEGY" default:"priority"`
+	// BackorderInterval is how often stock is allocated to pending
+	// backorders.
+	BackorderInterval time.Duration `key:"backorder_interval" env:"STOCK_BACKORDER_INTERVAL" default:"30s"`
+	// SnapshotInterval is how often stock levels are snapshotted, bounding
+	// how many movements a stock-as-of query replays.
+	SnapshotInterval time.Duration `key:"snapshot_interval" env:"STOCK_SNAPSHOT_INTERVAL" default:"24h"`
+	// SnapshotRetention is how long snapshots are kept; 0 keeps them all.
+	SnapshotRetention time.Duration `key:"snapshot_retention" env:"STOCK_SNAPSHOT_RETENTION" default:"0s"`
+}
+
+// Warehouse is a location holding stock. Priority ranks warehouses, lowest
+// first, where the strategy has no better way to choose.
+type Warehouse struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Priority  int     `json:"priority"`
+}
+
+// Level is the stock of a product in a warehouse. Reserved counts the units
+// held for orders, so Available - Reserved can still be sold.
+type Level struct {
+	WarehouseID string    `json:"warehouse_id"`
+	ProductID   uuid.UUID `json:"product_id"`
+	Available   int       `json:"available"`
+	Reserved    int       `json:"reserved"`
+}
+
+// Free returns the units that can still be reserved.
+func (l Level) Free() int {
+	return l.Available - l.Reserved
+}
+
+// Statuses of reservations: held for an order, or released.
+const (
+	ReservedStatus = "reserved"
+	ReleasedStatus = "released"
+)
+
+// Reservation is the stock of a product reserved for an order in a
+// warehouse, with status ReservedStatus while the units are held.
+type Reservation struct {
+	OrderID     uuid.UUID `json:"order_id"`
+	ProductID   uuid.UUID `json:"product_id"`
+	WarehouseID string    `json:"warehouse_id"`
+	Quantity    int       `json:"quantity"`
+	Status      string    `json:"status"`
+}
+
+// Statuses of backorders: waiting for stock, allocated it, or cancelled
+// by releasing the order.
+const (
+	BackorderPending   = "pending"
+	BackorderAllocated = "allocated"
+	BackorderCancelled = "cancelled"
+)
+
+// Backorder is an order line of a backorderable product that could not be
+// reserved when the order was placed. Pending backorders are allocated
+// stock in arrival order as it comes in.
+type Backorder struct {
+	ID          int64            `json:"id"`
+	OrderID     uuid.UUID        `json:"order_id"`
+	ProductID   uuid.UUID        `json:"product_id"`
+	Quantity    int              `json:"quantity"`
+	Destination *events.Location `json:"destination,omitempty"`
+	Status      string           `json:"status"`
+	WarehouseID string           `json:"warehouse_id,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// MovementKind is the kind of change a Movement records.
+type MovementKind string
+
+const (
+	// MovementReservation holds units for an order.
+	MovementReservation MovementKind = "reservation"
+	// MovementRelease frees the units held for an order.
+	MovementRelease MovementKind = "release"
+	// MovementAdjustment corrects the stock on hand, for example after a
+	// count.
+	MovementAdjustment MovementKind = "adjustment"
+	// MovementReceipt adds units received into a warehouse.
+	MovementReceipt MovementKind = "receipt"
+)
+
+// Movement is an entry of the stock audit log: one change to the stock of
+// a product in a warehouse. Quantity is the change to Available for
+// adjustments and receipts, and to Reserved for reservations and releases.
+type Movement struct {
+	ID              int64        `json:"id"`
+	WarehouseID     string       `json:"warehouse_id"`
+	ProductID       uuid.UUID    `json:"product_id"`
+	Kind            MovementKind `json:"kind"`
+	Quantity        int          `json:"quantity"`
+	OrderID         *uuid.UUID   `json:"order_id,omitempty"`
+	Actor           string       `json:"actor"`
+	Reason          string       `json:"reason"`
+	AvailableBefore int          `json:"available_before"`
+	AvailableAfter  int          `json:"available_after"`
+	ReservedBefore  int          `json:"reserved_before"`
+	ReservedAfter   int          `json:"reserved_after"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
+// MovementFilter selects movements. Zero fields match every movement;
+// Before pages through the log by returning movements with smaller IDs.
+type MovementFilter struct {
+	WarehouseID string
+	ProductID   uuid.UUID
+	OrderID     uuid.UUID
+	Kind        MovementKind
+	Actor       string
+	From, To    time.Time
+	Before      int64
+	Limit       int
+}
+
+// Adjustment is a manual change of Delta units, or a receipt of Delta
+// units, to the stock on hand, made by Actor for Reason.
+type Adjustment struct {
+	Kind   MovementKind
+	Delta  int
+	Actor  string
+	Reason string
+}
+
+// Strategy picks the warehouse an order line is reserved from.
+type Strategy string
+
+const (
+	// StrategyPriority reserves from the warehouse with the lowest priority
+	// that has enough stock.
+	StrategyPriority Strategy = "priority"
+	// StrategyNearest reserves from the warehouse closest to the order's
+	// destination that has enough stock. Orders without a destination are
+	// reserved by warehouse priority; the order service does not set one
+	// yet, as shipping addresses carry no coordinates.
+	StrategyNearest Strategy = "nearest"
+	// StrategyMostStocked reserves from the warehouse with the most free
+	// stock, spreading reservations over the network.
+	StrategyMostStocked Strategy = "most-stocked"
+)
+
+// Strategies lists the valid strategies.
+var Strategies = []string{string(StrategyPriority), string(StrategyNearest), string(StrategyMostStocked)}
+
+// Candidate is a warehouse's free stock of a product.
+type Candidate struct {
+	Warehouse
+	Free int
+}
+
+// Pick returns the ID of the warehouse among candidates to reserve quantity
+// units from, or false if none has that many free. Ties are broken by
+// priority, then by ID.
+func (s Strategy) Pick(candidates []Candidate, quantity int, destination *events.Location) (string, bool) {
+	eligible := slices.DeleteFunc(slices.Clone(candidates), func(c Candidate) bool { return c.Free < quantity })
+	if len(eligible) == 0 {
+		return "", false
+	}
+	slices.SortFunc(eligible, func(a, b Candidate) int {
+		switch {
+		case s == StrategyMostStocked:
+			if c := cmp.Compare(b.Free, a.Free); c != 0 {
+				return c
+			}
+		case s == StrategyNearest && destination != nil:
+			if c := cmp.Compare(distance(a.Warehouse, *destination), distance(b.Warehouse, *destination)); c != 0 {
+				return c
+			}
+		}
+		return cmp.Or(cmp.Compare(a.Priority, b.Priority), cmp.Compare(a.ID, b.ID))
+	})
+	return eligible[0].ID, true
+}
+
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0
+
+// distance returns the great-circle distance in kilometres between w and to.
+func distance(w Warehouse, to events.Location) float64 {
+	lat1, lat2 := w.Latitude*math.Pi/180, to.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (to.Longitude - w.Longitude) * math.Pi / 180
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
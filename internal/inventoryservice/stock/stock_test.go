@@ -0,0 +1,56 @@
+package stock_test
+
+import (
+	"testing"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/stock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrategy_Pick(t *testing.T) {
+	candidates := []stock.Candidate{
+		{Warehouse: stock.Warehouse{ID: "london", Latitude: 51.5, Longitude: -0.13, Priority: 1}, Free: 4},
+		{Warehouse: stock.Warehouse{ID: "paris", Latitude: 48.86, Longitude: 2.35, Priority: 0}, Free: 10},
+		{Warehouse: stock.Warehouse{ID: "berlin", Latitude: 52.52, Longitude: 13.4, Priority: 2}, Free: 25},
+	}
+	amsterdam := &events.Location{Latitude: 52.37, Longitude: 4.9}
+	dublin := &events.Location{Latitude: 53.35, Longitude: -6.26}
+
+	tests := []struct {
+		name        string
+		strategy    stock.Strategy
+		quantity    int
+		destination *events.Location
+		want        string
+		wantOK      bool
+	}{
+		{"nearest", stock.StrategyNearest, 2, dublin, "london", true},
+		{"nearest with enough stock", stock.StrategyNearest, 5, dublin, "paris", true},
+		{"nearest to another destination", stock.StrategyNearest, 2, amsterdam, "london", true},
+		{"nearest without destination uses priority", stock.StrategyNearest, 2, nil, "paris", true},
+		{"most stocked", stock.StrategyMostStocked, 2, dublin, "berlin", true},
+		{"priority ignores the destination", stock.StrategyPriority, 2, dublin, "paris", true},
+		{"priority with enough stock", stock.StrategyPriority, 11, nil, "berlin", true},
+		{"nothing has enough", stock.StrategyMostStocked, 26, nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.strategy.Pick(candidates, tt.quantity, tt.destination)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestStrategy_Pick_Ties(t *testing.T) {
+	candidates := []stock.Candidate{
+		{Warehouse: stock.Warehouse{ID: "b"}, Free: 5},
+		{Warehouse: stock.Warehouse{ID: "a"}, Free: 5},
+	}
+	got, ok := stock.StrategyMostStocked.Pick(candidates, 1, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "a", got)
+	// Candidates are not reordered.
+	assert.Equal(t, "b", candidates[0].ID)
+}
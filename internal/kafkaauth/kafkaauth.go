@@ -0,0 +1,145 @@
+// Package kafkaauth builds the SASL and TLS settings used by every Kafka
+// client in the services, as required by managed clusters such as MSK or
+// Confluent Cloud.
+package kafkaauth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"github.com/twmb/franz-go/pkg/kgo"
+	kgosasl "github.com/twmb/franz-go/pkg/sasl"
+	kgoplain "github.com/twmb/franz-go/pkg/sasl/plain"
+	kgoscram "github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// Supported SASL mechanisms.
+const (
+	MechanismPlain       = "PLAIN"
+	MechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	MechanismSCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+// Config selects how clients authenticate to the brokers. The zero value
+// connects in plaintext without authentication.
+type Config struct {
+	// SASLMechanism is empty, PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512.
+	SASLMechanism string `key:"sasl.mechanism" env:"KAFKA_SASL_MECHANISM"`
+	SASLUsername  string `key:"sasl.username" env:"KAFKA_SASL_USERNAME"`
+	SASLPassword  string `key:"sasl.password" env:"KAFKA_SASL_PASSWORD" secret:"true"`
+
+	TLSEnabled bool `key:"tls.enabled" env:"KAFKA_TLS_ENABLED" default:"false"`
+	// TLSCAFile is a PEM bundle trusted in addition to the system roots.
+	TLSCAFile string `key:"tls.ca_file" env:"KAFKA_TLS_CA_FILE"`
+	// TLSInsecureSkipVerify disables certificate verification; for local
+	// testing only.
+	TLSInsecureSkipVerify bool `key:"tls.insecure_skip_verify" env:"KAFKA_TLS_INSECURE_SKIP_VERIFY" default:"false"`
+}
+
+// Auth holds the SASL mechanism and TLS configuration built from a Config. A
+// nil *Auth is valid and means plaintext without authentication.
+type Auth struct {
+	mechanism sasl.Mechanism
+	tls       *tls.Config
+	// kgoMechanism is mechanism for franz-go clients.
+	kgoMechanism kgosasl.Mechanism
+}
+
+// New builds the Auth described by cfg. It returns nil when cfg enables
+// neither SASL nor TLS.
+func New(cfg Config) (*Auth, error) {
+	auth := &Auth{}
+
+	switch strings.ToUpper(cfg.SASLMechanism) {
+	case "":
+	case MechanismPlain:
+		auth.mechanism = plain.Mechanism{Username: cfg.SASLUsername, Password: cfg.SASLPassword}
+		auth.kgoMechanism = kgoplain.Auth{User: cfg.SASLUsername, Pass: cfg.SASLPassword}.AsMechanism()
+	case MechanismSCRAMSHA256, MechanismSCRAMSHA512:
+		algo := scram.SHA256
+		kgoAuth := kgoscram.Auth{User: cfg.SASLUsername, Pass: cfg.SASLPassword}
+		auth.kgoMechanism = kgoAuth.AsSha256Mechanism()
+		if strings.ToUpper(cfg.SASLMechanism) == MechanismSCRAMSHA512 {
+			algo = scram.SHA512
+			auth.kgoMechanism = kgoAuth.AsSha512Mechanism()
+		}
+		mechanism, err := scram.Mechanism(algo, cfg.SASLUsername, cfg.SASLPassword)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SCRAM mechanism: %w", err)
+		}
+		auth.mechanism = mechanism
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism %q", cfg.SASLMechanism)
+	}
+
+	if cfg.TLSEnabled {
+		tlsConfig := &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+		}
+		if cfg.TLSCAFile != "" {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			pem, err := os.ReadFile(cfg.TLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read Kafka CA bundle: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in Kafka CA bundle %s", cfg.TLSCAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		auth.tls = tlsConfig
+	}
+
+	if auth.mechanism == nil && auth.tls == nil {
+		return nil, nil
+	}
+	return auth, nil
+}
+
+// Dialer returns a dialer for kafka.Reader and other connection-based APIs.
+func (a *Auth) Dialer(timeout time.Duration) *kafka.Dialer {
+	dialer := &kafka.Dialer{Timeout: timeout, DualStack: true}
+	if a != nil {
+		dialer.SASLMechanism = a.mechanism
+		dialer.TLS = a.tls
+	}
+	return dialer
+}
+
+// Transport returns a transport for kafka.Writer and kafka.Client, or nil to
+// use kafka-go's default when no authentication is configured.
+func (a *Auth) Transport() kafka.RoundTripper {
+	if a == nil {
+		return nil
+	}
+	return &kafka.Transport{SASL: a.mechanism, TLS: a.tls}
+}
+
+// ClientOpts returns the options connecting a franz-go client with the same
+// settings, for the transactional producer kafka-go doesn't provide. It
+// returns nil when no authentication is configured.
+func (a *Auth) ClientOpts() []kgo.Opt {
+	if a == nil {
+		return nil
+	}
+	var opts []kgo.Opt
+	if a.kgoMechanism != nil {
+		opts = append(opts, kgo.SASL(a.kgoMechanism))
+	}
+	if a.tls != nil {
+		opts = append(opts, kgo.DialTLSConfig(a.tls.Clone()))
+	}
+	return opts
+}
@@ -0,0 +1,60 @@
+package kafkaauth_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("plaintext", func(t *testing.T) {
+		auth, err := kafkaauth.New(kafkaauth.Config{})
+		require.NoError(t, err)
+		assert.Nil(t, auth)
+		assert.Nil(t, auth.Transport())
+		assert.Nil(t, auth.Dialer(time.Second).SASLMechanism)
+		assert.Empty(t, auth.ClientOpts())
+	})
+
+	mechanisms := map[string]string{
+		"PLAIN":         "PLAIN",
+		"scram-sha-256": "SCRAM-SHA-256",
+		"SCRAM-SHA-512": "SCRAM-SHA-512",
+	}
+	for configured, want := range mechanisms {
+		t.Run(configured, func(t *testing.T) {
+			auth, err := kafkaauth.New(kafkaauth.Config{SASLMechanism: configured, SASLUsername: "user", SASLPassword: "pass"})
+			require.NoError(t, err)
+			dialer := auth.Dialer(time.Second)
+			require.NotNil(t, dialer.SASLMechanism)
+			assert.Equal(t, want, dialer.SASLMechanism.Name())
+			assert.Nil(t, dialer.TLS)
+			assert.Len(t, auth.ClientOpts(), 1, "SASL only")
+		})
+	}
+
+	t.Run("unsupported mechanism", func(t *testing.T) {
+		_, err := kafkaauth.New(kafkaauth.Config{SASLMechanism: "GSSAPI"})
+		assert.ErrorContains(t, err, "unsupported SASL mechanism")
+	})
+
+	t.Run("tls with system roots", func(t *testing.T) {
+		auth, err := kafkaauth.New(kafkaauth.Config{TLSEnabled: true})
+		require.NoError(t, err)
+		require.NotNil(t, auth.Dialer(time.Second).TLS)
+		assert.NotNil(t, auth.Transport())
+		assert.Len(t, auth.ClientOpts(), 1, "TLS only")
+	})
+
+	t.Run("invalid CA bundle", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+		_, err := kafkaauth.New(kafkaauth.Config{TLSEnabled: true, TLSCAFile: caFile})
+		assert.ErrorContains(t, err, "no certificates found")
+	})
+}
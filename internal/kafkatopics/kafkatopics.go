@@ -0,0 +1,313 @@
+// Package kafkatopics declares the Kafka topics the services rely on and
+// provisions them with kafka-go's admin client, so every environment gets
+// the same partitions, retention and cleanup policy.
+package kafkatopics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/segmentio/kafka-go"
+)
+
+// Cleanup policies accepted by Kafka.
+const (
+	CleanupDelete        = "delete"
+	CleanupCompact       = "compact"
+	CleanupCompactDelete = "compact,delete"
+)
+
+// Config holds the topic settings that vary between environments.
+type Config struct {
+	Partitions        int           `key:"partitions" env:"KAFKA_TOPIC_PARTITIONS" default:"3"`
+	ReplicationFactor int           `key:"replication_factor" env:"KAFKA_TOPIC_REPLICATION_FACTOR" default:"1"`
+	Retention         time.Duration `key:"retention" env:"KAFKA_TOPIC_RETENTION" default:"168h"`
+}
+
+// Spec describes a topic's desired layout.
+type Spec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	// Retention is how long messages are kept; -1 keeps them forever.
+	Retention     time.Duration
+	CleanupPolicy string
+}
+
+// Specs returns the topics the services need, sized by cfg.
+func Specs(cfg Config) []Spec {
+	spec := func(name string) Spec {
+		return Spec{
+			Name:              name,
+			Partitions:        cfg.Partitions,
+			ReplicationFactor: cfg.ReplicationFactor,
+			Retention:         cfg.Retention,
+			CleanupPolicy:     CleanupDelete,
+		}
+	}
+	consumed := []string{
+		events.TopicOrdersPlaced,
+		events.TopicInventoryCommands,
+		events.TopicInventoryEvents,
+		events.TopicInventoryReleases,
+		events.TopicPaymentEvents,
+		events.TopicShippingEvents,
+	}
+	var specs []Spec
+	for _, topic := range consumed {
+		specs = append(specs, spec(topic))
+	}
+	specs = append(specs,
+		// Published for notifiers outside the services.
+		spec(events.TopicOrderStatusChanged),
+		// Only the latest record of each relay matters, so the topic is
+		// compacted instead of expiring.
+		Spec{
+			Name:              events.TopicOutboxProgress,
+			Partitions:        1,
+			ReplicationFactor: cfg.ReplicationFactor,
+			Retention:         -1,
+			CleanupPolicy:     CleanupCompact,
+		},
+	)
+	// Consumers write the messages they cannot handle to dead-letter topics.
+	for _, topic := range consumed {
+		specs = append(specs, spec(events.DeadLetterTopic(topic)))
+	}
+	return specs
+}
+
+// State is a topic as it currently exists on the cluster.
+type State struct {
+	Spec
+	Exists bool
+}
+
+// Diff lists the differences between want and have in a human-readable form.
+// It is empty when the topic matches its spec.
+func Diff(want Spec, have State) []string {
+	if !have.Exists {
+		return []string{"missing"}
+	}
+	var diffs []string
+	if have.Partitions != want.Partitions {
+		diffs = append(diffs, fmt.Sprintf("partitions %d, want %d", have.Partitions, want.Partitions))
+	}
+	if have.ReplicationFactor != want.ReplicationFactor {
+		diffs = append(diffs, fmt.Sprintf("replication factor %d, want %d", have.ReplicationFactor, want.ReplicationFactor))
+	}
+	if have.Retention != want.Retention {
+		diffs = append(diffs, fmt.Sprintf("retention %s, want %s", FormatRetention(have.Retention), FormatRetention(want.Retention)))
+	}
+	if have.CleanupPolicy != want.CleanupPolicy {
+		diffs = append(diffs, fmt.Sprintf("cleanup policy %s, want %s", have.CleanupPolicy, want.CleanupPolicy))
+	}
+	return diffs
+}
+
+// FormatRetention renders a retention period, spelling out infinite retention.
+func FormatRetention(d time.Duration) string {
+	if d < 0 {
+		return "forever"
+	}
+	return d.String()
+}
+
+// Admin inspects and changes topics on a cluster.
+type Admin struct {
+	client *kafka.Client
+}
+
+// NewAdmin creates an Admin for the cluster at brokers.
+func NewAdmin(brokers []string, auth *kafkaauth.Auth) *Admin {
+	return &Admin{client: &kafka.Client{
+		Addr:      kafka.TCP(brokers...),
+		Timeout:   30 * time.Second,
+		Transport: auth.Transport(),
+	}}
+}
+
+// Describe returns the current state of the named topics, in the given order.
+func (a *Admin) Describe(ctx context.Context, names []string) ([]State, error) {
+	meta, err := a.client.Metadata(ctx, &kafka.MetadataRequest{Topics: names})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch topic metadata: %w", err)
+	}
+	found := make(map[string]kafka.Topic, len(meta.Topics))
+	for _, t := range meta.Topics {
+		if errors.Is(t.Error, kafka.UnknownTopicOrPartition) {
+			continue
+		}
+		if t.Error != nil {
+			return nil, fmt.Errorf("failed to describe topic %s: %w", t.Name, t.Error)
+		}
+		found[t.Name] = t
+	}
+
+	states := make([]State, len(names))
+	var resources []kafka.DescribeConfigRequestResource
+	for i, name := range names {
+		states[i].Name = name
+		t, ok := found[name]
+		if !ok {
+			continue
+		}
+		states[i].Exists = true
+		states[i].Partitions = len(t.Partitions)
+		if len(t.Partitions) > 0 {
+			states[i].ReplicationFactor = len(t.Partitions[0].Replicas)
+		}
+		resources = append(resources, kafka.DescribeConfigRequestResource{
+			ResourceType: kafka.ResourceTypeTopic,
+			ResourceName: name,
+			ConfigNames:  []string{"retention.ms", "cleanup.policy"},
+		})
+	}
+	if len(resources) == 0 {
+		return states, nil
+	}
+
+	configs, err := a.client.DescribeConfigs(ctx, &kafka.DescribeConfigsRequest{Resources: resources})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe topic configs: %w", err)
+	}
+	byName := make(map[string]*State, len(states))
+	for i := range states {
+		byName[states[i].Name] = &states[i]
+	}
+	for _, res := range configs.Resources {
+		if res.Error != nil {
+			return nil, fmt.Errorf("failed to describe config of topic %s: %w", res.ResourceName, res.Error)
+		}
+		state := byName[res.ResourceName]
+		if state == nil {
+			continue
+		}
+		for _, entry := range res.ConfigEntries {
+			switch entry.ConfigName {
+			case "retention.ms":
+				ms, err := strconv.ParseInt(entry.ConfigValue, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("topic %s has invalid retention.ms %q", res.ResourceName, entry.ConfigValue)
+				}
+				state.Retention = retentionFromMillis(ms)
+			case "cleanup.policy":
+				state.CleanupPolicy = entry.ConfigValue
+			}
+		}
+	}
+	return states, nil
+}
+
+// Create creates the given topics. Topics that already exist are reported as
+// errors by the broker.
+func (a *Admin) Create(ctx context.Context, specs []Spec) error {
+	topics := make([]kafka.TopicConfig, len(specs))
+	for i, spec := range specs {
+		topics[i] = kafka.TopicConfig{
+			Topic:             spec.Name,
+			NumPartitions:     spec.Partitions,
+			ReplicationFactor: spec.ReplicationFactor,
+			ConfigEntries: []kafka.ConfigEntry{
+				{ConfigName: "retention.ms", ConfigValue: retentionMillis(spec.Retention)},
+				{ConfigName: "cleanup.policy", ConfigValue: spec.CleanupPolicy},
+			},
+		}
+	}
+	resp, err := a.client.CreateTopics(ctx, &kafka.CreateTopicsRequest{Topics: topics})
+	if err != nil {
+		return fmt.Errorf("failed to create topics: %w", err)
+	}
+	return topicErrors("create", resp.Errors)
+}
+
+// Alter brings an existing topic in line with want: it adds partitions and
+// updates retention and cleanup policy. Kafka cannot remove partitions, and
+// changing the replication factor requires a partition reassignment, so both
+// are reported as errors instead.
+func (a *Admin) Alter(ctx context.Context, want Spec, have State) error {
+	if !have.Exists {
+		return fmt.Errorf("topic %s does not exist", want.Name)
+	}
+	if want.Partitions < have.Partitions {
+		return fmt.Errorf("topic %s has %d partitions; Kafka cannot reduce them to %d", want.Name, have.Partitions, want.Partitions)
+	}
+	if want.ReplicationFactor != have.ReplicationFactor {
+		return fmt.Errorf("topic %s has replication factor %d; changing it to %d requires a partition reassignment", want.Name, have.ReplicationFactor, want.ReplicationFactor)
+	}
+
+	if want.Partitions > have.Partitions {
+		resp, err := a.client.CreatePartitions(ctx, &kafka.CreatePartitionsRequest{
+			Topics: []kafka.TopicPartitionsConfig{{Name: want.Name, Count: int32(want.Partitions)}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add partitions to topic %s: %w", want.Name, err)
+		}
+		if err := topicErrors("add partitions to", resp.Errors); err != nil {
+			return err
+		}
+	}
+
+	var configs []kafka.IncrementalAlterConfigsRequestConfig
+	if want.Retention != have.Retention {
+		configs = append(configs, kafka.IncrementalAlterConfigsRequestConfig{Name: "retention.ms", Value: retentionMillis(want.Retention)})
+	}
+	if want.CleanupPolicy != have.CleanupPolicy {
+		configs = append(configs, kafka.IncrementalAlterConfigsRequestConfig{Name: "cleanup.policy", Value: want.CleanupPolicy})
+	}
+	if len(configs) == 0 {
+		return nil
+	}
+	resp, err := a.client.IncrementalAlterConfigs(ctx, &kafka.IncrementalAlterConfigsRequest{
+		Resources: []kafka.IncrementalAlterConfigsRequestResource{{
+			ResourceType: kafka.ResourceTypeTopic,
+			ResourceName: want.Name,
+			Configs:      configs,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to alter config of topic %s: %w", want.Name, err)
+	}
+	for _, res := range resp.Resources {
+		if res.Error != nil {
+			return fmt.Errorf("failed to alter config of topic %s: %w", res.ResourceName, res.Error)
+		}
+	}
+	return nil
+}
+
+// topicErrors combines the per-topic errors of an admin response.
+func topicErrors(action string, errs map[string]error) error {
+	names := make([]string, 0, len(errs))
+	for name, err := range errs {
+		if err != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var joined []error
+	for _, name := range names {
+		joined = append(joined, fmt.Errorf("failed to %s topic %s: %w", action, name, errs[name]))
+	}
+	return errors.Join(joined...)
+}
+
+func retentionMillis(d time.Duration) string {
+	if d < 0 {
+		return "-1"
+	}
+	return strconv.FormatInt(d.Milliseconds(), 10)
+}
+
+func retentionFromMillis(ms int64) time.Duration {
+	if ms < 0 {
+		return -1
+	}
+	return time.Duration(ms) * time.Millisecond
+}
@@ -0,0 +1,50 @@
+package kafkatopics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkatopics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecs(t *testing.T) {
+	specs := kafkatopics.Specs(kafkatopics.Config{Partitions: 6, ReplicationFactor: 3, Retention: 72 * time.Hour})
+
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.Name
+		if spec.Name == events.TopicOutboxProgress {
+			assert.Equal(t, kafkatopics.Spec{
+				Name: events.TopicOutboxProgress, Partitions: 1, ReplicationFactor: 3, Retention: -1, CleanupPolicy: kafkatopics.CleanupCompact,
+			}, spec)
+			continue
+		}
+		assert.Equal(t, 6, spec.Partitions)
+		assert.Equal(t, 3, spec.ReplicationFactor)
+		assert.Equal(t, 72*time.Hour, spec.Retention)
+		assert.Equal(t, kafkatopics.CleanupDelete, spec.CleanupPolicy)
+	}
+	assert.Equal(t, []string{
+		events.TopicOrdersPlaced, events.TopicInventoryCommands, events.TopicInventoryEvents, events.TopicInventoryReleases, events.TopicPaymentEvents, events.TopicShippingEvents,
+		events.TopicOrderStatusChanged, events.TopicOutboxProgress,
+		"orders.placed.dlq", "inventory.commands.dlq", "inventory.events.dlq", "inventory.releases.dlq", "payment.events.dlq", "shipping.events.dlq",
+	}, names)
+}
+
+func TestDiff(t *testing.T) {
+	want := kafkatopics.Spec{Name: "orders.placed", Partitions: 6, ReplicationFactor: 3, Retention: 168 * time.Hour, CleanupPolicy: kafkatopics.CleanupDelete}
+
+	assert.Equal(t, []string{"missing"}, kafkatopics.Diff(want, kafkatopics.State{}))
+	assert.Empty(t, kafkatopics.Diff(want, kafkatopics.State{Spec: want, Exists: true}))
+
+	have := kafkatopics.State{Exists: true, Spec: kafkatopics.Spec{
+		Name: "orders.placed", Partitions: 3, ReplicationFactor: 3, Retention: -1, CleanupPolicy: kafkatopics.CleanupCompact,
+	}}
+	assert.Equal(t, []string{
+		"partitions 3, want 6",
+		"retention forever, want 168h0m0s",
+		"cleanup policy compact, want delete",
+	}, kafkatopics.Diff(want, have))
+}
@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// InjectKafkaHeader adds the correlation ID from ctx, if any, to msg.
+func InjectKafkaHeader(ctx context.Context, msg *kafka.Message) {
+	if id := CorrelationID(ctx); id != "" {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: CorrelationIDKafkaHeader, Value: []byte(id)})
+	}
+}
+
+// MessageContext returns a context whose logger carries the message's
+// correlation ID (generating one if the producer didn't set it), topic,
+// partition, offset and key. Message keys are order IDs throughout the system.
+func MessageContext(ctx context.Context, msg kafka.Message) context.Context {
+	id := ""
+	for _, h := range msg.Headers {
+		if h.Key == CorrelationIDKafkaHeader {
+			id = string(h.Value)
+			break
+		}
+	}
+	if id == "" {
+		id = NewCorrelationID()
+	}
+	return WithCorrelationID(ctx, id, map[string]any{
+		"topic":     msg.Topic,
+		"partition": msg.Partition,
+		"offset":    msg.Offset,
+		"order_id":  string(msg.Key),
+	})
+}
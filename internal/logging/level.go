@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// SetLevel parses level and makes it the global zerolog level.
+func SetLevel(level string) error {
+	parsed, err := zerolog.ParseLevel(strings.ToLower(level))
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(parsed)
+	return nil
+}
+
+type levelPayload struct {
+	Level string `json:"level"`
+}
+
+// LevelHandler reports (GET) and changes (PUT) the global log level at
+// runtime. Requests must carry "Authorization: Bearer <adminToken>"; the
+// handler refuses every request when adminToken is empty.
+func LevelHandler(adminToken string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, adminToken) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, levelPayload{Level: zerolog.GlobalLevel().String()})
+		case http.MethodPut:
+			var req levelPayload
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request payload"})
+				return
+			}
+			previous := zerolog.GlobalLevel()
+			if err := SetLevel(req.Level); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown log level"})
+				return
+			}
+			log.Warn().Str("from", previous.String()).Str("to", zerolog.GlobalLevel().String()).Msg("Log level changed at runtime")
+			writeJSON(w, http.StatusOK, levelPayload{Level: zerolog.GlobalLevel().String()})
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	})
+}
+
+func authorized(r *http.Request, adminToken string) bool {
+	if adminToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
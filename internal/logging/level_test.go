@@ -0,0 +1,45 @@
+package logging_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelHandler(t *testing.T) {
+	original := zerolog.GlobalLevel()
+	t.Cleanup(func() { zerolog.SetGlobalLevel(original) })
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	handler := logging.LevelHandler("secret")
+
+	t.Run("rejects missing token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/log-level", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("changes level", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(`{"level":"debug"}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, zerolog.DebugLevel, zerolog.GlobalLevel())
+	})
+
+	t.Run("rejects unknown level", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(`{"level":"loud"}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,54 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCorrelationID(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := zerolog.New(&buf).WithContext(context.Background())
+
+	ctx = logging.WithCorrelationID(ctx, "abc-123", map[string]any{"topic": "orders.placed"})
+	log.Ctx(ctx).Info().Msg("hello")
+
+	assert.Equal(t, "abc-123", logging.CorrelationID(ctx))
+
+	var entry map[string]any
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry)) {
+		assert.Equal(t, "abc-123", entry[logging.CorrelationIDField])
+		assert.Equal(t, "orders.placed", entry["topic"])
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.NewLogger(&buf, "inventoryservice", logging.FormatJSON)
+	assert.NoError(t, err)
+	logger.Info().Str("topic", "orders.placed").Msg("hello")
+
+	var entry map[string]any
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry)) {
+		assert.Equal(t, "inventoryservice", entry["service"])
+		assert.Equal(t, "orders.placed", entry["topic"])
+		assert.Equal(t, "info", entry["level"])
+		assert.Contains(t, entry, "time")
+	}
+
+	buf.Reset()
+	logger, err = logging.NewLogger(&buf, "inventoryservice", logging.FormatConsole)
+	assert.NoError(t, err)
+	logger.Info().Msg("hello")
+	assert.Contains(t, buf.String(), "hello")
+	assert.False(t, json.Valid(buf.Bytes()))
+
+	_, err = logging.NewLogger(&buf, "inventoryservice", "xml")
+	assert.ErrorContains(t, err, `unknown log format "xml"`)
+}
@@ -0,0 +1,266 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// Exit codes returned by Command.Run, so deployment scripts can tell failures
+// apart.
+const (
+	ExitOK    = 0
+	ExitError = 1
+	ExitUsage = 2
+	ExitDirty = 3
+)
+
+const usage = `Usage: %s <command> [argument]
+
+Commands:
+  up              apply all pending migrations (default)
+  down [N]        roll back N migrations (default 1)
+  steps N         apply N migrations, or roll back if N is negative
+  goto VERSION    migrate up or down to VERSION
+  force VERSION   set VERSION without running migrations and clear the dirty flag
+  version         print the current schema version
+  status          print the current version and every embedded migration
+  plan [VERSION]  print the migrations, with their SQL, that up (or goto VERSION)
+                  would run, without applying them
+
+Exit codes: 0 success, 1 error, 2 usage error, 3 database is dirty.`
+
+// Command is the migrate CLI shared by the services' migrate binaries.
+type Command struct {
+	// Name is the program name shown in the usage text.
+	Name string
+	// Source holds the service's embedded migrations.
+	Source fs.FS
+	// DatabaseURL loads the connection string of the service's database. It
+	// is not called for help.
+	DatabaseURL func() (string, error)
+}
+
+// Run executes the command given by args and returns the process exit code.
+func (c Command) Run(args []string) int {
+	usage := fmt.Sprintf(usage, c.Name)
+	cmd := "up" // Default to "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	if cmd == "help" || cmd == "-h" || cmd == "--help" {
+		fmt.Println(usage)
+		return ExitOK
+	}
+
+	databaseURL, err := c.DatabaseURL()
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return ExitError
+	}
+
+	m, err := New(c.Source, databaseURL)
+	if err != nil {
+		log.Printf("Failed to create migrate instance: %v", err)
+		return ExitError
+	}
+	defer m.Close()
+
+	version, dirty, err := currentVersion(m)
+	if err != nil {
+		log.Printf("Failed to read schema version: %v", err)
+		return ExitError
+	}
+
+	switch cmd {
+	case "version":
+		fmt.Printf("%d%s\n", version, dirtySuffix(dirty))
+		if dirty {
+			return ExitDirty
+		}
+		return ExitOK
+	case "status":
+		return c.status(version, dirty)
+	case "plan":
+		return c.plan(args, version, dirty, usage)
+	case "force":
+		target, ok := versionArg(args, usage)
+		if !ok {
+			return ExitUsage
+		}
+		if err := m.Force(int(target)); err != nil {
+			log.Printf("Failed to force version: %v", err)
+			return ExitError
+		}
+		log.Printf("Forced schema version to %d.", target)
+		return ExitOK
+	}
+
+	// Every remaining command runs migrations, which must not start from a
+	// half-applied state.
+	if dirty {
+		log.Printf("Database is dirty at version %d: a migration failed part-way. Repair the schema, then run 'force %d' (or the previous version).", version, version)
+		return ExitDirty
+	}
+
+	switch cmd {
+	case "up":
+		log.Println("Running database migrations UP...")
+		err = m.Up()
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Printf("Invalid number of migrations %q: must be a positive integer\n\n%s", args[1], usage)
+				return ExitUsage
+			}
+		}
+		log.Printf("Rolling back %d migration(s)...", n)
+		err = m.Steps(-n)
+	case "steps":
+		if len(args) < 2 {
+			log.Printf("steps requires N\n\n%s", usage)
+			return ExitUsage
+		}
+		n, convErr := strconv.Atoi(args[1])
+		if convErr != nil || n == 0 {
+			log.Printf("Invalid number of steps %q: must be a non-zero integer\n\n%s", args[1], usage)
+			return ExitUsage
+		}
+		log.Printf("Running %d migration step(s)...", n)
+		err = m.Steps(n)
+	case "goto":
+		target, ok := versionArg(args, usage)
+		if !ok {
+			return ExitUsage
+		}
+		log.Printf("Migrating to version %d...", target)
+		err = m.Migrate(target)
+	default:
+		log.Printf("Unknown command: %s\n\n%s", cmd, usage)
+		return ExitUsage
+	}
+
+	if errors.Is(err, migrate.ErrNoChange) {
+		log.Println("No migrations to apply.")
+		return ExitOK
+	}
+	if err != nil {
+		log.Printf("Migration failed: %v", err)
+		if _, dirty, verr := currentVersion(m); verr == nil && dirty {
+			return ExitDirty
+		}
+		return ExitError
+	}
+
+	version, _, _ = currentVersion(m)
+	log.Printf("Database migrated successfully, schema version is now %d.", version)
+	return ExitOK
+}
+
+// currentVersion returns the applied schema version, treating a database with
+// no migrations applied as version 0.
+func currentVersion(m *migrate.Migrate) (uint, bool, error) {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// status prints the applied version and the state of each embedded migration.
+func (c Command) status(current uint, dirty bool) int {
+	versions, err := Versions(c.Source)
+	if err != nil {
+		log.Printf("Failed to list migrations: %v", err)
+		return ExitError
+	}
+
+	fmt.Printf("Current version: %d%s\n", current, dirtySuffix(dirty))
+	for _, v := range versions {
+		state := "pending"
+		switch {
+		case v == current && dirty:
+			state = "dirty"
+		case v <= current:
+			state = "applied"
+		}
+		fmt.Printf("  %06d  %s\n", v, state)
+	}
+
+	if dirty {
+		return ExitDirty
+	}
+	return ExitOK
+}
+
+// plan prints the migrations that would run to reach the VERSION argument,
+// or the latest embedded version.
+func (c Command) plan(args []string, current uint, dirty bool, usage string) int {
+	var target uint
+	if len(args) > 1 {
+		v, ok := versionArg(args, usage)
+		if !ok {
+			return ExitUsage
+		}
+		target = v
+	} else {
+		versions, err := Versions(c.Source)
+		if err != nil {
+			log.Printf("Failed to list migrations: %v", err)
+			return ExitError
+		}
+		target = versions[len(versions)-1]
+	}
+
+	steps, err := Plan(c.Source, current, target)
+	if err != nil {
+		log.Printf("Failed to plan migrations: %v", err)
+		return ExitError
+	}
+
+	fmt.Printf("-- Current version: %d%s, target version: %d\n", current, dirtySuffix(dirty), target)
+	if len(steps) == 0 {
+		fmt.Println("-- No migrations to apply.")
+	}
+	for _, step := range steps {
+		direction := "up"
+		if !step.Up {
+			direction = "down"
+		}
+		fmt.Printf("\n-- %06d %s (%s)\n%s\n", step.Version, step.Name, direction, strings.TrimRight(step.SQL, "\n"))
+	}
+
+	if dirty {
+		log.Printf("Database is dirty at version %d: repair the schema and run 'force' before applying this plan.", current)
+		return ExitDirty
+	}
+	return ExitOK
+}
+
+// versionArg parses the VERSION argument of force and goto.
+func versionArg(args []string, usage string) (uint, bool) {
+	if len(args) < 2 {
+		log.Printf("%s requires VERSION\n\n%s", args[0], usage)
+		return 0, false
+	}
+	v, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		log.Printf("Invalid version %q: must be a migration number such as 2\n\n%s", args[1], usage)
+		return 0, false
+	}
+	return uint(v), true
+}
+
+func dirtySuffix(dirty bool) string {
+	if dirty {
+		return " (dirty)"
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+// Package migration applies a service's embedded schema migrations with
+// golang-migrate. Each service embeds its own up/down SQL files and passes
+// them as an fs.FS; Command provides the migrate CLI they share.
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// New creates a migrate instance that reads the migrations in source and
+// applies them to the database at databaseURL. Close it when done.
+func New(source fs.FS, databaseURL string) (*migrate.Migrate, error) {
+	driver, err := iofs.New(source, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", driver, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+	return m, nil
+}
+
+// Up applies every pending migration in source and returns the resulting
+// schema version. It is safe to run from several replicas at once:
+// golang-migrate serialises them with a Postgres advisory lock.
+func Up(source fs.FS, databaseURL string) (uint, error) {
+	m, err := New(source, databaseURL)
+	if err != nil {
+		return 0, err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return 0, fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	version, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if dirty {
+		return version, fmt.Errorf("schema version %d is dirty", version)
+	}
+	return version, nil
+}
+
+// Versions lists the versions of the migrations in source in ascending order.
+func Versions(source fs.FS) ([]uint, error) {
+	driver, err := iofs.New(source, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+	defer driver.Close()
+
+	version, err := driver.First()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+	versions := []uint{version}
+	for {
+		version, err = driver.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return versions, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+		}
+		versions = append(versions, version)
+	}
+}
+
+// Step is one migration that would run to move between two versions.
+type Step struct {
+	Version uint
+	// Name is the part of the file name after the version, e.g. init_orders_table.
+	Name string
+	// Up is false when the step rolls the migration back.
+	Up  bool
+	SQL string
+}
+
+// Plan returns the migrations in source that would run to move the schema
+// from version from to version to, in the order they would run, without
+// touching a database. Version 0 means no migrations applied.
+func Plan(source fs.FS, from, to uint) ([]Step, error) {
+	versions, err := Versions(source)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range []uint{from, to} {
+		if v != 0 && !slices.Contains(versions, v) {
+			return nil, fmt.Errorf("migration version %d does not exist", v)
+		}
+	}
+
+	driver, err := iofs.New(source, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+	defer driver.Close()
+
+	var steps []Step
+	if to >= from {
+		for _, v := range versions {
+			if v > from && v <= to {
+				steps = append(steps, Step{Version: v, Up: true})
+			}
+		}
+	} else {
+		for i := len(versions) - 1; i >= 0; i-- {
+			if v := versions[i]; v > to && v <= from {
+				steps = append(steps, Step{Version: v})
+			}
+		}
+	}
+
+	for i := range steps {
+		read := driver.ReadDown
+		if steps[i].Up {
+			read = driver.ReadUp
+		}
+		r, name, err := read(steps[i].Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %d: %w", steps[i].Version, err)
+		}
+		sql, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %d: %w", steps[i].Version, err)
+		}
+		steps[i].Name = name
+		steps[i].SQL = string(sql)
+	}
+	return steps, nil
+}
@@ -0,0 +1,89 @@
+package migration_test
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	inventorymigrations "github.com/jonamarkin/e-commerce-order-processing/internal/inventoryservice/migrations"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/migration"
+	"github.com/jonamarkin/e-commerce-order-processing/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sources = map[string]fs.FS{
+	"orderservice":     migrations.FS,
+	"inventoryservice": inventorymigrations.FS,
+}
+
+func TestEmbeddedMigrationsArePaired(t *testing.T) {
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			files, err := fs.Glob(source, "*.sql")
+			require.NoError(t, err)
+			require.NotEmpty(t, files)
+
+			embedded := make(map[string]bool, len(files))
+			for _, f := range files {
+				embedded[f] = true
+			}
+			for _, f := range files {
+				if up, ok := strings.CutSuffix(f, ".up.sql"); ok {
+					assert.True(t, embedded[up+".down.sql"], "%s has no down migration", f)
+				} else if down, ok := strings.CutSuffix(f, ".down.sql"); ok {
+					assert.True(t, embedded[down+".up.sql"], "%s has no up migration", f)
+				} else {
+					t.Errorf("%s is not an up or down migration", f)
+				}
+			}
+		})
+	}
+}
+
+func TestVersions(t *testing.T) {
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			versions, err := migration.Versions(source)
+			require.NoError(t, err)
+
+			require.NotEmpty(t, versions)
+			assert.Equal(t, uint(1), versions[0])
+			assert.IsIncreasing(t, versions)
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	source := fstest.MapFS{
+		"000001_create_a.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+		"000001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"000002_create_b.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"000002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"000003_create_c.up.sql":   {Data: []byte("CREATE TABLE c ();")},
+		"000003_create_c.down.sql": {Data: []byte("DROP TABLE c;")},
+	}
+
+	steps, err := migration.Plan(source, 1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []migration.Step{
+		{Version: 2, Name: "create_b", Up: true, SQL: "CREATE TABLE b ();"},
+		{Version: 3, Name: "create_c", Up: true, SQL: "CREATE TABLE c ();"},
+	}, steps)
+
+	steps, err = migration.Plan(source, 3, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []migration.Step{
+		{Version: 3, Name: "create_c", SQL: "DROP TABLE c;"},
+		{Version: 2, Name: "create_b", SQL: "DROP TABLE b;"},
+		{Version: 1, Name: "create_a", SQL: "DROP TABLE a;"},
+	}, steps)
+
+	steps, err = migration.Plan(source, 2, 2)
+	require.NoError(t, err)
+	assert.Empty(t, steps)
+
+	_, err = migration.Plan(source, 0, 4)
+	assert.ErrorContains(t, err, "version 4 does not exist")
+}
@@ -0,0 +1,122 @@
+// Package natsbroker carries the services' events over NATS JetStream, for
+// deployments that can't run Kafka. Each topic is stored in its own stream,
+// whose subject is the topic name, and each consumer group is a durable pull
+// consumer of that stream.
+package natsbroker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog/log"
+)
+
+// KeyHeader carries the message key, an order ID, since NATS messages have
+// none of their own.
+const KeyHeader = "key"
+
+// setupTimeout bounds creating a stream or consumer.
+const setupTimeout = 10 * time.Second
+
+// Config locates the NATS servers and sizes the streams created on them.
+type Config struct {
+	// URL is a NATS server URL, or a comma-separated list of them.
+	URL string `key:"url" env:"NATS_URL" default:"nats://localhost:4222"`
+	// CredsFile is a credentials file (JWT and NKey seed) used to
+	// authenticate, as required by Synadia Cloud and secured clusters.
+	CredsFile string `key:"creds_file" env:"NATS_CREDS_FILE"`
+	// StreamReplicas is how many servers store each stream.
+	StreamReplicas int `key:"stream.replicas" env:"NATS_STREAM_REPLICAS" default:"1"`
+	// StreamMaxAge is how long streams keep messages.
+	StreamMaxAge time.Duration `key:"stream.max_age" env:"NATS_STREAM_MAX_AGE" default:"168h"`
+	// AckWait is how long a message may be processed before JetStream
+	// delivers it again.
+	AckWait time.Duration `key:"ack_wait" env:"NATS_ACK_WAIT" default:"30s"`
+}
+
+// Client is a connection to NATS shared by the publishers and subscribers
+// created from it.
+type Client struct {
+	conn *nats.Conn
+	js   jetstream.JetStream
+	cfg  Config
+}
+
+// Connect connects to the servers of cfg as the client named name. Lost
+// connections are re-established indefinitely.
+func Connect(cfg Config, name string) (*Client, error) {
+	opts := []nats.Option{
+		nats.Name(name),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			log.Warn().Err(err).Msg("Disconnected from NATS")
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			log.Info().Str("url", conn.ConnectedUrlRedacted()).Msg("Reconnected to NATS")
+		}),
+	}
+	if cfg.CredsFile != "" {
+		opts = append(opts, nats.UserCredentials(cfg.CredsFile))
+	}
+	conn, err := nats.Connect(cfg.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+	return &Client{conn: conn, js: js, cfg: cfg}, nil
+}
+
+// Check reports whether the client is connected, for health probes.
+func (c *Client) Check(context.Context) error {
+	if status := c.conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("nats connection is %s", strings.ToLower(status.String()))
+	}
+	return nil
+}
+
+// Close drains the connection, letting pending publishes and acknowledgements
+// complete, and closes it. Close publishers and subscribers first.
+func (c *Client) Close() error {
+	log.Info().Msg("Closing NATS connection...")
+	return c.conn.Drain()
+}
+
+// ensureStream creates the stream storing topic, or updates its settings.
+func (c *Client) ensureStream(ctx context.Context, topic string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, setupTimeout)
+	defer cancel()
+	name := StreamName(topic)
+	_, err := c.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:      name,
+		Subjects:  []string{topic},
+		Retention: jetstream.LimitsPolicy,
+		Storage:   jetstream.FileStorage,
+		Replicas:  c.cfg.StreamReplicas,
+		MaxAge:    c.cfg.StreamMaxAge,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create stream %s for topic %s: %w", name, topic, err)
+	}
+	return name, nil
+}
+
+// StreamName returns the name of the stream storing topic: the topic in
+// upper case, with the characters stream names can't contain replaced by
+// underscores. orders.placed is stored in ORDERS_PLACED.
+func StreamName(topic string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', '/', '\\', ' ', '\t':
+			return '_'
+		}
+		return r
+	}, strings.ToUpper(topic))
+}
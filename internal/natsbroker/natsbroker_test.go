@@ -0,0 +1,66 @@
+package natsbroker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// fakeJetStream records published messages and fails them with err.
+type fakeJetStream struct {
+	msgs []*nats.Msg
+	err  error
+}
+
+func (f *fakeJetStream) PublishMsg(_ context.Context, msg *nats.Msg, _ ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	f.msgs = append(f.msgs, msg)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &jetstream.PubAck{Stream: StreamName(msg.Subject), Sequence: uint64(len(f.msgs))}, nil
+}
+
+func TestStreamName(t *testing.T) {
+	assert.Equal(t, "ORDERS_PLACED", StreamName("orders.placed"))
+	assert.Equal(t, "INVENTORY_COMMANDS", StreamName("inventory.commands"))
+	assert.Equal(t, "ORDERS-DLQ", StreamName("orders-dlq"))
+}
+
+func TestPublisher_PublishMessage(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Run("carries the key, correlation ID and trace context", func(t *testing.T) {
+		js := &fakeJetStream{}
+		p := &Publisher{js: js, topic: "orders.placed"}
+		ctx := logging.WithCorrelationID(context.Background(), "corr-1", nil)
+
+		require.NoError(t, p.PublishMessage(ctx, []byte("order-1"), []byte(`{"order_id":"order-1"}`)))
+
+		require.Len(t, js.msgs, 1)
+		msg := js.msgs[0]
+		assert.Equal(t, "orders.placed", msg.Subject)
+		assert.Equal(t, `{"order_id":"order-1"}`, string(msg.Data))
+		assert.Equal(t, "order-1", msg.Header.Get(KeyHeader))
+		assert.Equal(t, "corr-1", msg.Header.Get(logging.CorrelationIDKafkaHeader))
+		assert.NotEmpty(t, propagation.HeaderCarrier(msg.Header).Get("traceparent"))
+	})
+
+	t.Run("publish failure", func(t *testing.T) {
+		errNoResponders := errors.New("no responders available for request")
+		p := &Publisher{js: &fakeJetStream{err: errNoResponders}, topic: "orders.placed"}
+
+		err := p.PublishMessage(context.Background(), []byte("order-1"), []byte("{}"))
+		assert.ErrorIs(t, err, errNoResponders)
+		assert.ErrorContains(t, err, "failed to publish message to NATS")
+	})
+}
@@ -0,0 +1,86 @@
+package natsbroker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const tracerName = "github.com/jonamarkin/e-commerce-order-processing/internal/natsbroker"
+
+// Option configures a Publisher.
+type Option func(*Publisher)
+
+// WithCircuitBreaker makes a publisher fail fast with a
+// circuitbreaker.OpenError while breaker is open.
+func WithCircuitBreaker(breaker *circuitbreaker.Breaker) Option {
+	return func(p *Publisher) {
+		p.breaker = breaker
+	}
+}
+
+// msgPublisher is the part of jetstream.JetStream a Publisher uses.
+type msgPublisher interface {
+	PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
+}
+
+// Publisher publishes messages to the stream of one topic.
+type Publisher struct {
+	js      msgPublisher
+	topic   string
+	breaker *circuitbreaker.Breaker
+}
+
+var _ broker.EventPublisher = (*Publisher)(nil)
+
+// Publisher returns a publisher for topic, creating its stream if needed.
+func (c *Client) Publisher(ctx context.Context, topic string, opts ...Option) (*Publisher, error) {
+	if _, err := c.ensureStream(ctx, topic); err != nil {
+		return nil, err
+	}
+	p := &Publisher{js: c.js, topic: topic}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// PublishMessage publishes a key-value message to the topic and waits for
+// JetStream to store it.
+func (p *Publisher) PublishMessage(ctx context.Context, key, value []byte) error {
+	msg := nats.NewMsg(p.topic)
+	msg.Data = value
+	msg.Header.Set(KeyHeader, string(key))
+	if id := logging.CorrelationID(ctx); id != "" {
+		msg.Header.Set(logging.CorrelationIDKafkaHeader, id)
+	}
+	ctx, span := tracing.StartPublishSpan(ctx, tracerName, "nats", p.topic, propagation.HeaderCarrier(msg.Header))
+	defer span.End()
+
+	if err := p.breaker.Allow(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "circuit breaker open")
+		return err
+	}
+	_, err := p.js.PublishMsg(ctx, msg)
+	p.breaker.Done(err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+		return fmt.Errorf("failed to publish message to NATS: %w", err)
+	}
+	return nil
+}
+
+// Close does nothing: the connection belongs to the Client.
+func (p *Publisher) Close() error {
+	return nil
+}
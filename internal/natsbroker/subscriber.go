@@ -0,0 +1,132 @@
+package natsbroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Subscriber consumes the stream of one topic as a member of a consumer
+// group, through a durable consumer named after the group.
+type Subscriber struct {
+	consumer jetstream.Consumer
+	topic    string
+	group    string
+}
+
+var _ broker.EventSubscriber = (*Subscriber)(nil)
+
+// Subscriber returns a subscriber to topic for the consumer group groupID,
+// creating the stream and its durable consumer if needed. A new group starts
+// from the oldest message the stream keeps.
+func (c *Client) Subscriber(ctx context.Context, topic, groupID string) (*Subscriber, error) {
+	stream, err := c.ensureStream(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, setupTimeout)
+	defer cancel()
+	consumer, err := c.js.CreateOrUpdateConsumer(ctx, stream, jetstream.ConsumerConfig{
+		Durable:       groupID,
+		FilterSubject: topic,
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       c.cfg.AckWait,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer %s on stream %s: %w", groupID, stream, err)
+	}
+	return &Subscriber{consumer: consumer, topic: topic, group: groupID}, nil
+}
+
+// Consume fetches messages and passes them to handler until ctx is cancelled.
+// Every message is acknowledged once handled, whether or not handler failed.
+// Cancelling ctx stops fetching but lets the message in flight finish and be
+// acknowledged, so Consume returns only once that work is done.
+func (s *Subscriber) Consume(ctx context.Context, handler broker.MessageHandler) {
+	processCtx := context.WithoutCancel(ctx)
+	log.Info().Str("topic", s.topic).Str("group_id", s.group).Msg("Starting NATS consumer")
+	for {
+		iter, err := s.consumer.Messages()
+		if err != nil {
+			log.Error().Err(err).Str("topic", s.topic).Msg("Error subscribing to messages")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		stop := context.AfterFunc(ctx, iter.Stop)
+		s.consume(ctx, processCtx, iter, handler)
+		stop()
+		iter.Stop()
+		if ctx.Err() != nil {
+			log.Info().Str("topic", s.topic).Msg("NATS consumer context cancelled. Shutting down.")
+			return
+		}
+	}
+}
+
+// consume handles the messages of iter until it is stopped or fails.
+func (s *Subscriber) consume(ctx, processCtx context.Context, iter jetstream.MessagesContext, handler broker.MessageHandler) {
+	for {
+		msg, err := iter.Next()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+				return
+			}
+			log.Error().Err(err).Str("topic", s.topic).Msg("Error fetching message")
+			continue
+		}
+
+		key := msg.Headers().Get(KeyHeader)
+		msgCtx, span := tracing.StartProcessSpan(s.messageContext(processCtx, msg), tracerName, "nats", s.topic,
+			propagation.HeaderCarrier(msg.Headers()),
+			attribute.String("messaging.consumer.group.name", s.group))
+		if err := handler(msgCtx, []byte(key), msg.Data()); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "handler failed")
+			log.Ctx(msgCtx).Error().Err(err).Msg("Failed to handle message")
+		}
+		span.End()
+
+		if err := msg.Ack(); err != nil {
+			log.Ctx(msgCtx).Error().Err(err).Msg("Error acknowledging message")
+		}
+	}
+}
+
+// messageContext returns a context whose logger carries the message's
+// correlation ID (generating one if the publisher didn't set it), topic,
+// stream sequence and key.
+func (s *Subscriber) messageContext(ctx context.Context, msg jetstream.Msg) context.Context {
+	id := msg.Headers().Get(logging.CorrelationIDKafkaHeader)
+	if id == "" {
+		id = logging.NewCorrelationID()
+	}
+	fields := map[string]any{
+		"topic":    s.topic,
+		"order_id": msg.Headers().Get(KeyHeader),
+	}
+	if meta, err := msg.Metadata(); err == nil {
+		fields["stream_sequence"] = meta.Sequence.Stream
+	}
+	return logging.WithCorrelationID(ctx, id, fields)
+}
+
+// Close does nothing: Consume stops when its context is cancelled and the
+// connection belongs to the Client.
+func (s *Subscriber) Close() error {
+	return nil
+}
@@ -0,0 +1,84 @@
+// Package address verifies shipping addresses with an external address
+// verification service, which knows which addresses exist and how the
+// postal service writes them.
+package address
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+)
+
+// HTTPClient verifies addresses with the address verification service's
+// REST API.
+type HTTPClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewHTTPClient creates a new HTTPClient for the address verification
+// service at baseURL.
+func NewHTTPClient(baseURL string, timeout time.Duration) *HTTPClient {
+	return &HTTPClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+type problem struct {
+	Field      string `json:"field"`
+	Constraint string `json:"constraint"`
+	Message    string `json:"message"`
+}
+
+type verifyResponse struct {
+	Deliverable bool           `json:"deliverable"`
+	Address     domain.Address `json:"address"`
+	Problems    []problem      `json:"problems"`
+}
+
+// Verify asks the service whether addr is deliverable. It returns the
+// address as the service normalized it, or a *domain.InvalidAddressError
+// with the problems the service found.
+func (c *HTTPClient) Verify(ctx context.Context, addr domain.Address) (domain.Address, error) {
+	body, err := json.Marshal(addr)
+	if err != nil {
+		return domain.Address{}, fmt.Errorf("failed to encode address: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v1/addresses/verify", bytes.NewReader(body))
+	if err != nil {
+		return domain.Address{}, fmt.Errorf("failed to build address verification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return domain.Address{}, fmt.Errorf("failed to call address verification service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return domain.Address{}, fmt.Errorf("address verification service returned unexpected status %d", resp.StatusCode)
+	}
+
+	var result verifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return domain.Address{}, fmt.Errorf("failed to decode address verification response: %w", err)
+	}
+	if !result.Deliverable {
+		invalid := &domain.InvalidAddressError{}
+		for _, p := range result.Problems {
+			invalid.Fields = append(invalid.Fields, domain.AddressFieldError{Field: p.Field, Constraint: p.Constraint, Message: p.Message})
+		}
+		if len(invalid.Fields) == 0 {
+			invalid.Fields = []domain.AddressFieldError{{Constraint: "deliverable", Message: "is not deliverable"}}
+		}
+		return domain.Address{}, invalid
+	}
+	return result.Address.Normalize(), nil
+}
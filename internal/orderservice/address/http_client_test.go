@@ -0,0 +1,61 @@
+package address_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/address"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient_Verify(t *testing.T) {
+	input := domain.Address{Line1: "1600 Amphitheatre Pkwy", City: "Mountain View", PostalCode: "94043", Country: "US"}
+
+	t.Run("deliverable address is returned as the service writes it", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/api/v1/addresses/verify", r.URL.Path)
+			var got domain.Address
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+			assert.Equal(t, input, got)
+			_, _ = w.Write([]byte(`{"deliverable":true,"address":{"line1":"1600 Amphitheatre Pkwy","city":"Mountain View","region":"CA","postal_code":"94043-1351","country":"us"}}`))
+		}))
+		defer server.Close()
+
+		got, err := address.NewHTTPClient(server.URL+"/", time.Second).Verify(context.Background(), input)
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.Address{Line1: "1600 Amphitheatre Pkwy", City: "Mountain View", Region: "CA", PostalCode: "94043-1351", Country: "US"}, got)
+	})
+
+	t.Run("undeliverable address is rejected with the service's problems", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"deliverable":false,"problems":[{"field":"line1","constraint":"deliverable","message":"street not found"}]}`))
+		}))
+		defer server.Close()
+
+		_, err := address.NewHTTPClient(server.URL, time.Second).Verify(context.Background(), input)
+
+		var invalid *domain.InvalidAddressError
+		require.ErrorAs(t, err, &invalid)
+		assert.Equal(t, []domain.AddressFieldError{{Field: "line1", Constraint: "deliverable", Message: "street not found"}}, invalid.Fields)
+	})
+
+	t.Run("service errors are not address problems", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		_, err := address.NewHTTPClient(server.URL, time.Second).Verify(context.Background(), input)
+
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, domain.ErrInvalidAddress)
+	})
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompressionConfig controls compression of API responses.
+type CompressionConfig struct {
+	Enabled bool `key:"enabled" env:"HTTP_COMPRESSION_ENABLED" default:"true"`
+	// MinSize is the smallest body, in bytes, worth compressing; smaller
+	// responses are sent as they are.
+	MinSize int `key:"min_size" env:"HTTP_COMPRESSION_MIN_SIZE" default:"1024"`
+	// Level is a compress/flate level: -1 (default) or 1 (fastest) to 9
+	// (smallest).
+	Level int `key:"level" env:"HTTP_COMPRESSION_LEVEL" default:"-1"`
+}
+
+// compressor is implemented by *gzip.Writer and *flate.Writer.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// CompressionMiddleware compresses JSON, NDJSON, CSV and other text responses
+// with gzip or deflate, whichever the client prefers in Accept-Encoding.
+// Bodies are buffered until they reach cfg.MinSize, so small responses are
+// left alone. Responses that are flushed before that (streamed exports) are
+// compressed regardless, since their final size is unknown.
+func CompressionMiddleware(cfg CompressionConfig) gin.HandlerFunc {
+	if !cfg.Enabled {
+		return func(c *gin.Context) { c.Next() }
+	}
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() any {
+			w, _ := gzip.NewWriterLevel(io.Discard, cfg.Level)
+			return w
+		}},
+		"deflate": {New: func() any {
+			w, _ := flate.NewWriter(io.Discard, cfg.Level)
+			return w
+		}},
+	}
+
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, pool: pools[encoding], minSize: cfg.MinSize}
+		c.Writer = w
+		defer func() {
+			w.finish()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// negotiateEncoding returns "gzip" or "deflate" according to an
+// Accept-Encoding header, or "" if the client accepts neither. gzip wins ties.
+func negotiateEncoding(header string) string {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		weights[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{"gzip", "deflate"} {
+		q, ok := weights[encoding]
+		if !ok {
+			q, ok = weights["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether a response with the given Content-Type is
+// text that benefits from compression.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") ||
+		mediaType == "application/javascript"
+}
+
+// compressWriter buffers the start of a response until it knows whether to
+// compress it, then either compresses or passes the body through.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	pool     *sync.Pool
+	minSize  int
+
+	decided bool
+	buf     []byte
+	cw      compressor
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.minSize {
+			return len(p), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.cw != nil {
+		return w.cw.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow sends the headers immediately, so the body that follows is
+// not compressed.
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decided = true
+		w.ResponseWriter.WriteHeaderNow()
+		w.writeBuffered(w.ResponseWriter)
+	}
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	if w.cw != nil {
+		_ = w.cw.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide starts compressing if the response is compressible and writes out
+// what has been buffered so far.
+func (w *compressWriter) decide() error {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	status := w.ResponseWriter.Status()
+	if len(w.buf) > 0 && header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) &&
+		status != http.StatusNoContent && status != http.StatusNotModified {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.cw = w.pool.Get().(compressor)
+		w.cw.Reset(w.ResponseWriter)
+		return w.writeBuffered(w.cw)
+	}
+	return w.writeBuffered(w.ResponseWriter)
+}
+
+func (w *compressWriter) writeBuffered(dst io.Writer) error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := dst.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// finish writes a response that stayed below the size threshold as it is and
+// completes a compressed one.
+func (w *compressWriter) finish() {
+	if !w.decided {
+		w.decided = true
+		_ = w.writeBuffered(w.ResponseWriter)
+		return
+	}
+	if w.cw != nil {
+		_ = w.cw.Close()
+		w.cw.Reset(io.Discard)
+		w.pool.Put(w.cw)
+		w.cw = nil
+	}
+}
@@ -0,0 +1,129 @@
+package api_test
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	large := strings.Repeat("x", 2048)
+	router := gin.New()
+	router.Use(api.CompressionMiddleware(api.CompressionConfig{Enabled: true, MinSize: 1024, Level: -1}))
+	router.GET("/large", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": large})
+	})
+	router.GET("/small", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": "x"})
+	})
+	router.GET("/binary", func(c *gin.Context) {
+		c.Data(http.StatusOK, "image/png", []byte(large))
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/csv")
+		_, _ = c.Writer.WriteString("id\n1\n")
+		c.Writer.Flush()
+		_, _ = c.Writer.WriteString("2\n")
+	})
+	router.GET("/empty", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("gzips large JSON", func(t *testing.T) {
+		w := get("/large", "gzip, deflate")
+
+		require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+		r, err := gzip.NewReader(w.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data":"`+large+`"}`, string(body))
+	})
+
+	t.Run("deflates when preferred", func(t *testing.T) {
+		w := get("/large", "gzip;q=0.5, deflate")
+
+		require.Equal(t, "deflate", w.Header().Get("Content-Encoding"))
+		body, err := io.ReadAll(flate.NewReader(w.Body))
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data":"`+large+`"}`, string(body))
+	})
+
+	t.Run("leaves small responses alone", func(t *testing.T) {
+		w := get("/small", "gzip")
+
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.JSONEq(t, `{"data":"x"}`, w.Body.String())
+	})
+
+	t.Run("leaves binary responses alone", func(t *testing.T) {
+		w := get("/binary", "gzip")
+
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, w.Body.String())
+	})
+
+	t.Run("respects clients that refuse compression", func(t *testing.T) {
+		for _, acceptEncoding := range []string{"", "identity", "gzip;q=0", "br"} {
+			w := get("/large", acceptEncoding)
+
+			assert.Empty(t, w.Header().Get("Content-Encoding"), acceptEncoding)
+			assert.JSONEq(t, `{"data":"`+large+`"}`, w.Body.String(), acceptEncoding)
+		}
+	})
+
+	t.Run("compresses flushed streams", func(t *testing.T) {
+		w := get("/stream", "gzip")
+
+		require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		r, err := gzip.NewReader(w.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "id\n1\n2\n", string(body))
+	})
+
+	t.Run("keeps empty responses empty", func(t *testing.T) {
+		w := get("/empty", "gzip")
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Empty(t, w.Body.String())
+	})
+}
+
+func TestCompressionMiddleware_Disabled(t *testing.T) {
+	router := gin.New()
+	router.Use(api.CompressionMiddleware(api.CompressionConfig{Enabled: false, MinSize: 0}))
+	router.GET("/large", func(c *gin.Context) {
+		c.String(http.StatusOK, strings.Repeat("x", 2048))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/large", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Len(t, w.Body.String(), 2048)
+}
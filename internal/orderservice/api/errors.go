@@ -0,0 +1,65 @@
+package api
+
+// Error codes of ErrorResponse. Clients branch on them, so a code keeps its
+// meaning once published; messages are for people and may change.
+const (
+	// Request problems (400, 413).
+	CodeMalformedRequest         = "MALFORMED_REQUEST"
+	CodeUnknownField             = "UNKNOWN_FIELD"
+	CodeValidationFailed         = "VALIDATION_FAILED"
+	CodePayloadTooLarge          = "PAYLOAD_TOO_LARGE"
+	CodeInvalidOrderID           = "INVALID_ORDER_ID"
+	CodeInvalidQuery             = "INVALID_QUERY"
+	CodeUnknownStatus            = "UNKNOWN_STATUS"
+	CodeOrderItemsRequired       = "ORDER_ITEMS_REQUIRED"
+	CodeItemQuantityInvalid      = "ITEM_QTY_INVALID"
+	CodeItemPriceInvalid         = "ITEM_PRICE_INVALID"
+	CodeExternalReferenceInvalid = "EXTERNAL_REFERENCE_INVALID"
+
+	// Access (401).
+	CodeUnauthorized = "UNAUTHORIZED"
+
+	// Order state (404, 409, 422, 429).
+	CodeOrderNotFound           = "ORDER_NOT_FOUND"
+	CodeCustomerNotFound        = "CUSTOMER_NOT_FOUND"
+	CodeProductsNotSellable     = "PRODUCTS_NOT_SELLABLE"
+	CodeOutOfStock              = "OUT_OF_STOCK"
+	CodeAddressInvalid          = "ADDRESS_INVALID"
+	CodeDuplicateOrder          = "DUPLICATE_ORDER"
+	CodeExternalReferenceExists = "EXTERNAL_REFERENCE_EXISTS"
+	CodeInvalidStatusTransition = "INVALID_STATUS_TRANSITION"
+	CodeOrderLocked             = "ORDER_LOCKED"
+	CodeQuotaExceeded           = "QUOTA_EXCEEDED"
+
+	// Service problems (500, 503, 504); retrying may help.
+	CodeInternal           = "INTERNAL_ERROR"
+	CodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+	CodeDatabaseTimeout    = "DATABASE_TIMEOUT"
+	CodePublishTimeout     = "PUBLISH_TIMEOUT"
+)
+
+// ErrorResponse @Description Error response. Code identifies the error for programs, Message describes it for people, and Details, when present, lists the individual problems.
+type ErrorResponse struct {
+	Code    string        `json:"code" example:"ORDER_NOT_FOUND"`
+	Message string        `json:"message" example:"Order not found"`
+	Details []ErrorDetail `json:"details,omitempty"`
+}
+
+// ErrorDetail @Description One problem reported by an error response, such as an invalid field, with the constraint it breaks and the value sent.
+type ErrorDetail struct {
+	// Field is the JSON path of the offending field or query parameter, if
+	// the problem concerns one.
+	Field string `json:"field,omitempty" example:"items[0].quantity"`
+	// Constraint is the rule the field breaks: a binding rule such as
+	// required or gt=0, type for a value of the wrong JSON type, format for
+	// a malformed value such as a UUID, or unknown for an unexpected field.
+	Constraint string `json:"constraint,omitempty" example:"gt=0"`
+	// Value is the value sent, when it is known and not too large to echo.
+	Value   any    `json:"value,omitempty" swaggertype:"string" example:"-1"`
+	Message string `json:"message" example:"must be greater than 0"`
+}
+
+// newErrorResponse builds an ErrorResponse.
+func newErrorResponse(code, message string, details ...ErrorDetail) ErrorResponse {
+	return ErrorResponse{Code: code, Message: message, Details: details}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+)
+
+// orderETag identifies the version of order a response shows. Every change
+// to an order sets updated_at, so it changes whenever the order does. The tag
+// is weak because compression changes the bytes sent.
+func orderETag(order *domain.Order) string {
+	return `W/"` + strconv.FormatInt(order.UpdatedAt.UnixMicro(), 36) + "-" + string(order.Status) + `"`
+}
+
+// notModified sets the ETag of the response to etag and, if the request's
+// If-None-Match header lists it, answers 304 Not Modified and reports true.
+// Clients are asked to revalidate cached orders before using them. The
+// response varies with the headers renderOrder and the localization
+// negotiate on, so caches keep the 304 apart from other representations.
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	vary(c, "Accept", "Accept-Language")
+	if !etagListed(c.GetHeader("If-None-Match"), etag) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	c.Writer.WriteHeaderNow()
+	return true
+}
+
+// etagListed reports whether an If-None-Match header lists etag, comparing
+// tags weakly as RFC 9110 requires.
+func etagListed(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
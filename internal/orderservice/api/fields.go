@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// orderResponseFields maps the JSON keys of OrderResponse to its fields, in
+// the order they are encoded.
+var (
+	orderResponseFields     = map[string]int{}
+	orderResponseFieldNames []string
+)
+
+func init() {
+	t := reflect.TypeFor[OrderResponse]()
+	for i := range t.NumField() {
+		if name := jsonFieldName(t.Field(i)); name != "" {
+			orderResponseFields[name] = i
+			orderResponseFieldNames = append(orderResponseFieldNames, name)
+		}
+	}
+}
+
+// orderFields is a sparse fieldset: the keys of OrderResponse a client asked
+// for with ?fields=. A nil orderFields selects every field.
+type orderFields []string
+
+// parseOrderFields reads the fields query parameter, a comma-separated list
+// of OrderResponse keys such as id,status,total_price. On an unknown key it
+// answers 400 and reports false.
+func parseOrderFields(c *gin.Context) (orderFields, bool) {
+	v := c.Query("fields")
+	if v == "" {
+		return nil, true
+	}
+	var fields orderFields
+	for name := range strings.SplitSeq(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || slices.Contains(fields, name) {
+			continue
+		}
+		if _, ok := orderResponseFields[name]; !ok {
+			c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidQuery,
+				fmt.Sprintf("Unknown field %q in fields; available: %s", name, strings.Join(orderResponseFieldNames, ", ")),
+				ErrorDetail{Field: "fields", Constraint: "unknown", Value: name, Message: "is not a field of an order"}))
+			return nil, false
+		}
+		fields = append(fields, name)
+	}
+	return fields, true
+}
+
+// shape returns resp with only the selected fields, or resp itself when
+// every field is selected. Fields left out of resp when empty, such as
+// external_reference, stay out even when selected.
+func (f orderFields) shape(resp OrderResponse) any {
+	if f == nil {
+		return resp
+	}
+	v := reflect.ValueOf(resp)
+	shaped := make(shapedOrder, len(f))
+	for _, name := range f {
+		fv := v.Field(orderResponseFields[name])
+		if fv.Kind() == reflect.Pointer && fv.IsNil() {
+			continue
+		}
+		shaped[name] = fv.Interface()
+	}
+	return shaped
+}
+
+// shapedOrder is an OrderResponse reduced to some of its fields, keyed by
+// their JSON names.
+type shapedOrder map[string]any
+
+// MarshalXML encodes the order the way OrderResponse is encoded, with its
+// fields in the same order.
+func (o shapedOrder) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{Name: xml.Name{Local: "order"}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	t := reflect.TypeFor[OrderResponse]()
+	for _, name := range orderResponseFieldNames {
+		v, ok := o[name]
+		if !ok {
+			continue
+		}
+		tag, _, _ := strings.Cut(t.Field(orderResponseFields[name]).Tag.Get("xml"), ",")
+		if err := encodeXMLField(e, tag, v); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// encodeXMLField encodes v as the element named by tag, which may nest
+// elements as in items>item.
+func encodeXMLField(e *xml.Encoder, tag string, v any) error {
+	outer, inner, nested := strings.Cut(tag, ">")
+	if !nested {
+		return e.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: tag}})
+	}
+	start := xml.StartElement{Name: xml.Name{Local: outer}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: inner}}); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// shapedOrderList is an OrderListResponse of shaped orders.
+type shapedOrderList struct {
+	XMLName xml.Name `json:"-" xml:"orders"`
+	Orders  []any    `json:"orders" xml:"order"`
+	Limit   int      `json:"limit" xml:"limit,attr"`
+	Offset  int      `json:"offset" xml:"offset,attr"`
+}
@@ -1,20 +1,54 @@
 package api
 
 import (
+	"encoding/json"
+	"encoding/xml"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/catalog"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/export"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/service"
+	"github.com/rs/zerolog/log"
 )
 
 // CreateOrderRequest @Description Request payload for creating a new order.
 type CreateOrderRequest struct {
 	CustomerID uuid.UUID         `json:"customer_id" binding:"required" example:"a1b2c3d4-e5f6-7890-1234-567890abcdef"`
-	Items      []CreateOrderItem `json:"items" binding:"required,min=1"`
+	Items      []CreateOrderItem `json:"items" binding:"required,min=1,dive"`
+	// ExternalReference identifies the order in the system it is imported
+	// from. An order with a reference already used in that system is
+	// rejected, so imports can safely be retried.
+	ExternalReference *ExternalReferenceBody `json:"external_reference,omitempty"`
+	// ShippingAddress is where the order is delivered. It is normalized
+	// before it is stored, and rejected if it is incomplete or, with address
+	// verification enabled, undeliverable.
+	ShippingAddress *AddressBody `json:"shipping_address,omitempty"`
+}
+
+// AddressBody @Description A postal address. Country is an ISO 3166-1 alpha-2 code.
+type AddressBody struct {
+	Name       string `json:"name,omitempty" xml:"name,omitempty" example:"Ada Lovelace"`
+	Line1      string `json:"line1" xml:"line1" example:"12 St James's Square"`
+	Line2      string `json:"line2,omitempty" xml:"line2,omitempty" example:"Flat 3"`
+	City       string `json:"city" xml:"city" example:"London"`
+	Region     string `json:"region,omitempty" xml:"region,omitempty" example:"Greater London"`
+	PostalCode string `json:"postal_code" xml:"postal_code" example:"SW1Y 4LB"`
+	Country    string `json:"country" xml:"country" example:"GB"`
+}
+
+// ExternalReferenceBody @Description The number of an order in another system, such as a marketplace or ERP.
+type ExternalReferenceBody struct {
+	Source    string `json:"source" xml:"source" binding:"required" example:"shopify"`
+	Reference string `json:"reference" xml:"reference" binding:"required" example:"#1001"`
 }
 
 // CreateOrderItem @Description An item within an order creation request.
@@ -26,23 +60,34 @@ type CreateOrderItem struct {
 
 // OrderResponse @Description Response structure for a single order.
 type OrderResponse struct {
-	ID         uuid.UUID           `json:"id" example:"a1b2c3d4-e5f6-7890-1234-567890abcdef"`
-	CustomerID uuid.UUID           `json:"customer_id" example:"a1b2c3d4-e5f6-7890-1234-567890abcdef"`
-	Items      []OrderItemResponse `json:"items"`
-	Status     string              `json:"status" example:"pending"` // Changed to string for JSON serialization
-	TotalPrice float64             `json:"total_price" example:"199.98"`
-	CreatedAt  time.Time           `json:"created_at" example:"2023-10-27T10:00:00Z"`
-	UpdatedAt  time.Time           `json:"updated_at" example:"2023-10-27T10:00:00Z"`
+	XMLName    xml.Name            `json:"-" xml:"order" swaggerignore:"true"`
+	ID         uuid.UUID           `json:"id" xml:"id" example:"a1b2c3d4-e5f6-7890-1234-567890abcdef"`
+	CustomerID uuid.UUID           `json:"customer_id" xml:"customer_id" example:"a1b2c3d4-e5f6-7890-1234-567890abcdef"`
+	Items      []OrderItemResponse `json:"items" xml:"items>item"`
+	Status     string              `json:"status" xml:"status" example:"pending"` // Changed to string for JSON serialization
+	TotalPrice float64             `json:"total_price" xml:"total_price" example:"199.98"`
+	CreatedAt  time.Time           `json:"created_at" xml:"created_at" example:"2023-10-27T10:00:00Z"`
+	UpdatedAt  time.Time           `json:"updated_at" xml:"updated_at" example:"2023-10-27T10:00:00Z"`
+	// ExternalReference is only set for orders imported from another system.
+	ExternalReference *ExternalReferenceBody `json:"external_reference,omitempty" xml:"external_reference,omitempty"`
+	// ShippingAddress is only set for orders placed with one.
+	ShippingAddress *AddressBody `json:"shipping_address,omitempty" xml:"shipping_address,omitempty"`
+	// DuplicateOf is only set when creating an order that repeats a recent
+	// identical order, with duplicate detection in flag mode.
+	DuplicateOf *uuid.UUID `json:"duplicate_of,omitempty" xml:"duplicate_of,omitempty" example:"a1b2c3d4-e5f6-7890-1234-567890abcdef"`
+	Links       OrderLinks `json:"links" xml:"links"`
 }
 
 // OrderItemResponse @Description An item within an order response.
 type OrderItemResponse struct {
-	ProductID uuid.UUID `json:"product_id" example:"fedcba98-7654-3210-fedc-ba9876543210"`
-	Quantity  int       `json:"quantity" example:"1"`
-	UnitPrice float64   `json:"unit_price" example:"99.99"`
+	ProductID uuid.UUID `json:"product_id" xml:"product_id" example:"fedcba98-7654-3210-fedc-ba9876543210"`
+	Quantity  int       `json:"quantity" xml:"quantity" example:"1"`
+	UnitPrice float64   `json:"unit_price" xml:"unit_price" example:"99.99"`
 }
 
-// NewOrderResponse converts a domain.Order to an OrderResponse.
+// NewOrderResponse converts a domain.Order to an OrderResponse. Timestamps
+// are in UTC, so they serialize as RFC 3339 with a Z suffix. The order is
+// not linked to its events, which only a Handler WithOrderEvents does.
 func NewOrderResponse(order *domain.Order) OrderResponse {
 	items := make([]OrderItemResponse, len(order.Items))
 	for i, item := range order.Items {
@@ -52,32 +97,161 @@ func NewOrderResponse(order *domain.Order) OrderResponse {
 			UnitPrice: item.UnitPrice,
 		}
 	}
-	return OrderResponse{
+	resp := OrderResponse{
 		ID:         order.ID,
 		CustomerID: order.CustomerID,
 		Items:      items,
 		Status:     string(order.Status), // Convert domain.OrderStatus back to string for JSON
 		TotalPrice: order.TotalPrice,
-		CreatedAt:  order.CreatedAt,
-		UpdatedAt:  order.UpdatedAt,
+		CreatedAt:  order.CreatedAt.UTC(),
+		UpdatedAt:  order.UpdatedAt.UTC(),
+		Links:      newOrderLinks(order),
+	}
+	if !order.ExternalReference.IsZero() {
+		resp.ExternalReference = &ExternalReferenceBody{
+			Source:    order.ExternalReference.Source,
+			Reference: order.ExternalReference.Reference,
+		}
+	}
+	if a := order.ShippingAddress; a != nil {
+		resp.ShippingAddress = &AddressBody{
+			Name:       a.Name,
+			Line1:      a.Line1,
+			Line2:      a.Line2,
+			City:       a.City,
+			Region:     a.Region,
+			PostalCode: a.PostalCode,
+			Country:    a.Country,
+		}
+	}
+	if order.DuplicateOf != uuid.Nil {
+		resp.DuplicateOf = &order.DuplicateOf
+	}
+	return resp
+}
+
+// OrderListResponse @Description A page of orders.
+type OrderListResponse struct {
+	XMLName xml.Name        `json:"-" xml:"orders" swaggerignore:"true"`
+	Orders  []OrderResponse `json:"orders" xml:"order"`
+	Limit   int             `json:"limit" xml:"limit,attr" example:"50"`
+	Offset  int             `json:"offset" xml:"offset,attr" example:"0"`
+}
+
+// OrderItemsResponse @Description The items of an order.
+type OrderItemsResponse struct {
+	XMLName xml.Name            `json:"-" xml:"items" swaggerignore:"true"`
+	Items   []OrderItemResponse `json:"items" xml:"item"`
+}
+
+// OrderEventsResponse @Description The events of an order's stream, oldest first. Each has the order_id, version, type, occurred_at and data of the event.
+type OrderEventsResponse struct {
+	Events []json.RawMessage `json:"events" swaggertype:"array,object"`
+}
+
+// OrderStatsResponse @Description Order counts and totals, overall and per status.
+type OrderStatsResponse struct {
+	Orders     int                    `json:"orders" example:"42"`
+	Items      int                    `json:"items" example:"97"`
+	TotalPrice float64                `json:"total_price" example:"4210.5"`
+	ByStatus   []OrderStatusStatsItem `json:"by_status"`
+}
+
+// OrderStatusStatsItem @Description Order count and totals for one status.
+type OrderStatusStatsItem struct {
+	Status     string  `json:"status" example:"completed"`
+	Orders     int     `json:"orders" example:"30"`
+	Items      int     `json:"items" example:"71"`
+	TotalPrice float64 `json:"total_price" example:"3150.25"`
+}
+
+// NewOrderStatsResponse totals stats into an OrderStatsResponse.
+func NewOrderStatsResponse(stats []repository.OrderStatusStats) OrderStatsResponse {
+	resp := OrderStatsResponse{ByStatus: make([]OrderStatusStatsItem, len(stats))}
+	for i, s := range stats {
+		resp.ByStatus[i] = OrderStatusStatsItem{
+			Status:     string(s.Status),
+			Orders:     s.Orders,
+			Items:      s.Items,
+			TotalPrice: s.TotalPrice,
+		}
+		resp.Orders += s.Orders
+		resp.Items += s.Items
+		resp.TotalPrice += s.TotalPrice
 	}
+	return resp
+}
+
+// UpdateOrderStatusRequest @Description Request payload for changing an order's status.
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" binding:"required" example:"cancelled"`
 }
 
-// ErrorResponse @Description Generic error response.
-type ErrorResponse struct {
-	Error string `json:"error" example:"Invalid request payload"`
+// UnsellableProductsResponse @Description Error response listing products rejected by the catalog, or found out of stock by the inventory service.
+type UnsellableProductsResponse struct {
+	ErrorResponse
+	ProductIDs []uuid.UUID `json:"product_ids"`
+}
+
+// DuplicateOrderResponse @Description Error response for an order that repeats an existing order: a recent identical order, or an order with the same external reference.
+type DuplicateOrderResponse struct {
+	ErrorResponse
+	// OrderID is the earlier order the request repeats.
+	OrderID uuid.UUID `json:"order_id" example:"a1b2c3d4-e5f6-7890-1234-567890abcdef"`
+}
+
+// InvalidTransitionResponse @Description Error response for a status change the order's current status does not allow.
+type InvalidTransitionResponse struct {
+	ErrorResponse
+	Status          string `json:"status" example:"cancelled"`
+	RequestedStatus string `json:"requested_status" example:"completed"`
+}
+
+// QuotaExceededResponse @Description Error response for a customer that placed as many orders as its quota allows.
+type QuotaExceededResponse struct {
+	ErrorResponse
+	// Window is the quota period that is used up: minute or day.
+	Window            string `json:"window" example:"minute"`
+	Limit             int    `json:"limit" example:"30"`
+	RetryAfterSeconds int    `json:"retry_after_seconds" example:"42"`
 }
 
 // Handler holds the dependencies for our API handlers.
 type Handler struct {
 	orderService service.OrderService
+	// orderEvents is set when orders have event streams to link to.
+	orderEvents bool
+}
+
+// HandlerOption configures optional behaviour of a Handler.
+type HandlerOption func(*Handler)
+
+// WithOrderEvents links orders to their events. Set it when the order
+// service stores orders as events; otherwise every order has none.
+func WithOrderEvents() HandlerOption {
+	return func(h *Handler) {
+		h.orderEvents = true
+	}
 }
 
 // NewHandler creates a new Handler with the given OrderService.
-func NewHandler(orderService service.OrderService) *Handler {
-	return &Handler{
+func NewHandler(orderService service.OrderService, opts ...HandlerOption) *Handler {
+	h := &Handler{
 		orderService: orderService,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// orderResponse converts order to the OrderResponse the handler serves.
+func (h *Handler) orderResponse(order *domain.Order) OrderResponse {
+	resp := NewOrderResponse(order)
+	if h.orderEvents {
+		resp.Links.Events = eventsLink(resp.Links.Self.Href)
+	}
+	return resp
 }
 
 // HealthCheck godoc
@@ -91,48 +265,33 @@ func (h *Handler) HealthCheck(c *gin.Context) {
 	c.String(http.StatusOK, "OK")
 }
 
+// catalogRetryAfter is how long clients are asked to wait before retrying
+// an order the catalog service could not check.
+const catalogRetryAfter = 5 * time.Second
+
 // CreateOrder
 // @Summary Create a new order
 // @Description Create a new customer order with provided items.
 // @Tags orders
 // @Accept json
-// @Produce json
+// @Produce json,xml
 // @Param order body CreateOrderRequest true "Order creation request"
 // @Success 201 {object} OrderResponse "Order created successfully"
-// @Failure 400 {object} ErrorResponse "Invalid request payload or validation error"
+// @Failure 400 {object} ErrorResponse "Malformed payload, unknown field or invalid field value"
+// @Failure 413 {object} ErrorResponse "Request body too large"
+// @Failure 409 {object} DuplicateOrderResponse "Same items ordered by the same customer moments ago, or external reference already used"
+// @Failure 422 {object} UnsellableProductsResponse "Unknown customer, unknown/unsellable products, out-of-stock products or invalid/undeliverable shipping address"
+// @Failure 429 {object} QuotaExceededResponse "Customer over its order quota; retry after Retry-After seconds"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Dependency unavailable or service overloaded; retry after Retry-After seconds"
+// @Failure 504 {object} ErrorResponse "Database or Kafka operation timed out"
 // @Router /orders [post]
 func (h *Handler) CreateOrder(c *gin.Context) {
 	var req CreateOrderRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request payload"})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	// Basic validation for request data
-	if req.CustomerID == uuid.Nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Customer ID is required"})
-		return
-	}
-	if len(req.Items) == 0 {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Order must contain at least one item"})
-		return
-	}
-	for _, item := range req.Items {
-		if item.ProductID == uuid.Nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Product ID is required for all items"})
-			return
-		}
-		if item.Quantity <= 0 {
-			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Item quantity must be positive"})
-			return
-		}
-		if item.UnitPrice <= 0 {
-			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Item unit price must be positive"})
-			return
-		}
-	}
-
 	items := make([]domain.OrderItem, len(req.Items))
 	for i, itemReq := range req.Items {
 		items[i] = domain.OrderItem{
@@ -142,53 +301,653 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 		}
 	}
 
-	order, err := h.orderService.CreateOrder(c.Request.Context(), req.CustomerID, items)
+	var ref domain.ExternalReference
+	if req.ExternalReference != nil {
+		ref = domain.ExternalReference{
+			Source:    req.ExternalReference.Source,
+			Reference: req.ExternalReference.Reference,
+		}
+	}
+
+	var address *domain.Address
+	if a := req.ShippingAddress; a != nil {
+		address = &domain.Address{
+			Name:       a.Name,
+			Line1:      a.Line1,
+			Line2:      a.Line2,
+			City:       a.City,
+			Region:     a.Region,
+			PostalCode: a.PostalCode,
+			Country:    a.Country,
+		}
+	}
+
+	order, err := h.orderService.CreateOrder(c.Request.Context(), req.CustomerID, items, ref, address)
 	if err != nil {
 		// Specific error handling for domain/service errors
-		if errors.Is(err, domain.ErrNoOrderItems) ||
-			errors.Is(err, domain.ErrInvalidOrderItemQuantity) ||
-			errors.Is(err, domain.ErrInvalidOrderItemUnitPrice) {
-			c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		if code, ok := invalidOrderCode(err); ok {
+			c.JSON(http.StatusBadRequest, newErrorResponse(code, err.Error()))
+			return
+		}
+		if errors.Is(err, domain.ErrCustomerNotFound) {
+			c.JSON(http.StatusUnprocessableEntity, newErrorResponse(CodeCustomerNotFound, "Customer not found"))
+			return
+		}
+		var addressErr *domain.InvalidAddressError
+		if errors.As(err, &addressErr) {
+			details := make([]ErrorDetail, len(addressErr.Fields))
+			for i, f := range addressErr.Fields {
+				field := "shipping_address"
+				if f.Field != "" {
+					field += "." + f.Field
+				}
+				details[i] = ErrorDetail{Field: field, Constraint: f.Constraint, Message: f.Message}
+			}
+			c.JSON(http.StatusUnprocessableEntity, newErrorResponse(CodeAddressInvalid, "Invalid shipping address", details...))
+			return
+		}
+		var unsellableErr *domain.UnsellableProductsError
+		if errors.As(err, &unsellableErr) {
+			c.JSON(http.StatusUnprocessableEntity, UnsellableProductsResponse{
+				ErrorResponse: newErrorResponse(CodeProductsNotSellable, domain.ErrProductNotSellable.Error()),
+				ProductIDs:    unsellableErr.ProductIDs,
+			})
+			return
+		}
+		var outOfStockErr *domain.OutOfStockError
+		if errors.As(err, &outOfStockErr) {
+			c.JSON(http.StatusUnprocessableEntity, UnsellableProductsResponse{
+				ErrorResponse: newErrorResponse(CodeOutOfStock, domain.ErrOutOfStock.Error()),
+				ProductIDs:    outOfStockErr.ProductIDs,
+			})
+			return
+		}
+		var duplicateErr *domain.DuplicateOrderError
+		if errors.As(err, &duplicateErr) {
+			c.JSON(http.StatusConflict, DuplicateOrderResponse{
+				ErrorResponse: newErrorResponse(CodeDuplicateOrder, domain.ErrDuplicateOrder.Error()),
+				OrderID:       duplicateErr.ExistingOrderID,
+			})
+			return
+		}
+		var conflictErr *domain.ExternalReferenceConflictError
+		if errors.As(err, &conflictErr) {
+			c.JSON(http.StatusConflict, DuplicateOrderResponse{
+				ErrorResponse: newErrorResponse(CodeExternalReferenceExists, domain.ErrExternalReferenceExists.Error()),
+				OrderID:       conflictErr.ExistingOrderID,
+			})
+			return
+		}
+		var quotaErr *domain.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			seconds := retryAfterSeconds(quotaErr.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusTooManyRequests, QuotaExceededResponse{
+				ErrorResponse:     newErrorResponse(CodeQuotaExceeded, domain.ErrQuotaExceeded.Error()),
+				Window:            quotaErr.Window,
+				Limit:             quotaErr.Limit,
+				RetryAfterSeconds: seconds,
+			})
 			return
 		}
-		//Print the error to the console for debugging
-		c.Error(err) // Log the error using Gin's error logging
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create order"})
+		if errors.Is(err, catalog.ErrUnavailable) {
+			c.Error(err)
+			serviceUnavailable(c, catalogRetryAfter)
+			return
+		}
+		internalError(c, err, "Failed to create order")
 		return
 	}
 
-	c.JSON(http.StatusCreated, NewOrderResponse(order))
+	renderOrder(c, http.StatusCreated, h.orderResponse(order))
 }
 
 // GetOrderByID
 // @Summary Get order by ID
-// @Description Get a single order's details by its unique ID.
+// @Description Get a single order's details by its unique ID. The response carries an ETag; send it back in If-None-Match to get 304 Not Modified while the order is unchanged.
 // @Tags orders
 // @Accept json
-// @Produce json
+// @Produce json,xml
 // @Param id path string true "Order ID" Format(uuid)
+// @Param fields query string false "Comma-separated order fields to return, such as id,status,total_price (default: all)"
+// @Param If-None-Match header string false "ETag of a previously retrieved version of the order"
 // @Success 200 {object} OrderResponse "Order retrieved successfully"
+// @Header 200 {string} ETag "Version of the order"
+// @Success 304 "Order unchanged since the version in If-None-Match"
 // @Failure 400 {object} ErrorResponse "Invalid order ID format"
 // @Failure 404 {object} ErrorResponse "Order not found"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Dependency unavailable or service overloaded; retry after Retry-After seconds"
+// @Failure 504 {object} ErrorResponse "Database or Kafka operation timed out"
 // @Router /orders/{id} [get]
 func (h *Handler) GetOrderByID(c *gin.Context) {
 	idStr := c.Param("id")
 	orderID, err := uuid.Parse(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid order ID format"})
+		c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidOrderID, "Invalid order ID format"))
+		return
+	}
+	fields, ok := parseOrderFields(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.orderService.GetOrderByID(c.Request.Context(), orderID)
+	if err != nil {
+		if errors.Is(err, domain.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, newErrorResponse(CodeOrderNotFound, "Order not found"))
+			return
+		}
+		internalError(c, err, "Failed to get order")
+		return
+	}
+
+	if notModified(c, orderETag(order)) {
+		return
+	}
+	renderOrder(c, http.StatusOK, fields.shape(h.orderResponse(order)))
+}
+
+// GetOrderItems
+// @Summary Get the items of an order
+// @Description Get the items of a single order.
+// @Tags orders
+// @Produce json,xml
+// @Param id path string true "Order ID" Format(uuid)
+// @Success 200 {object} OrderItemsResponse "Items retrieved successfully"
+// @Failure 400 {object} ErrorResponse "Invalid order ID format"
+// @Failure 404 {object} ErrorResponse "Order not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Dependency unavailable or service overloaded; retry after Retry-After seconds"
+// @Failure 504 {object} ErrorResponse "Database or Kafka operation timed out"
+// @Router /orders/{id}/items [get]
+func (h *Handler) GetOrderItems(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidOrderID, "Invalid order ID format"))
 		return
 	}
 
 	order, err := h.orderService.GetOrderByID(c.Request.Context(), orderID)
 	if err != nil {
 		if errors.Is(err, domain.ErrOrderNotFound) {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Order not found"})
+			c.JSON(http.StatusNotFound, newErrorResponse(CodeOrderNotFound, "Order not found"))
+			return
+		}
+		internalError(c, err, "Failed to get order")
+		return
+	}
+
+	renderOrder(c, http.StatusOK, OrderItemsResponse{Items: NewOrderResponse(order).Items})
+}
+
+// GetOrderEvents
+// @Summary Get the events of an order
+// @Description Get the events recorded for an order, oldest first. Orders only have events while event sourcing is enabled, and orders stored before it was have none until their first status change.
+// @Tags orders
+// @Produce json
+// @Param id path string true "Order ID" Format(uuid)
+// @Success 200 {object} OrderEventsResponse "Events retrieved successfully"
+// @Failure 400 {object} ErrorResponse "Invalid order ID format"
+// @Failure 404 {object} ErrorResponse "Order not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Dependency unavailable or service overloaded; retry after Retry-After seconds"
+// @Failure 504 {object} ErrorResponse "Database or Kafka operation timed out"
+// @Router /orders/{id}/events [get]
+func (h *Handler) GetOrderEvents(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidOrderID, "Invalid order ID format"))
+		return
+	}
+
+	stored, err := h.orderService.OrderEvents(c.Request.Context(), orderID)
+	if err != nil {
+		if errors.Is(err, domain.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, newErrorResponse(CodeOrderNotFound, "Order not found"))
+			return
+		}
+		internalError(c, err, "Failed to get order events")
+		return
+	}
+
+	resp := OrderEventsResponse{Events: make([]json.RawMessage, len(stored))}
+	for i, event := range stored {
+		resp.Events[i] = event.Value
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetOrderByExternalReference
+// @Summary Get order by external reference
+// @Description Get the order imported from another system with the given reference.
+// @Tags orders
+// @Produce json,xml
+// @Param source query string true "System the order was imported from" example(shopify)
+// @Param reference query string true "Order number in that system" example(#1001)
+// @Param fields query string false "Comma-separated order fields to return, such as id,status,total_price (default: all)"
+// @Success 200 {object} OrderResponse "Order retrieved successfully"
+// @Failure 400 {object} ErrorResponse "Missing or invalid source or reference"
+// @Failure 404 {object} ErrorResponse "Order not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Dependency unavailable or service overloaded; retry after Retry-After seconds"
+// @Failure 504 {object} ErrorResponse "Database or Kafka operation timed out"
+// @Router /orders/by-reference [get]
+func (h *Handler) GetOrderByExternalReference(c *gin.Context) {
+	ref := domain.ExternalReference{
+		Source:    c.Query("source"),
+		Reference: c.Query("reference"),
+	}
+	if ref.Source == "" || ref.Reference == "" {
+		c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidQuery, "source and reference are required"))
+		return
+	}
+	fields, ok := parseOrderFields(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.orderService.GetOrderByExternalReference(c.Request.Context(), ref)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidExternalReference) {
+			c.JSON(http.StatusBadRequest, newErrorResponse(CodeExternalReferenceInvalid, err.Error()))
+			return
+		}
+		if errors.Is(err, domain.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, newErrorResponse(CodeOrderNotFound, "Order not found"))
+			return
+		}
+		internalError(c, err, "Failed to get order")
+		return
+	}
+
+	renderOrder(c, http.StatusOK, fields.shape(h.orderResponse(order)))
+}
+
+// Paging limits for ListOrders.
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// ListOrders
+// @Summary List orders
+// @Description List orders matching the given filters, newest first unless sort says otherwise.
+// @Tags orders
+// @Produce json,xml
+// @Param status query string false "Order status" Enums(pending, processing, completed, cancelled, failed)
+// @Param customer_id query string false "Customer ID" Format(uuid)
+// @Param created_from query string false "Only orders created at or after this time (RFC 3339)"
+// @Param created_to query string false "Only orders created before this time (RFC 3339)"
+// @Param limit query int false "Maximum number of orders to return (default 50, max 500)"
+// @Param offset query int false "Number of orders to skip"
+// @Param sort query string false "Comma-separated sort keys, each a field with an optional :asc or :desc; sortable fields are created_at, updated_at, total_price and status (default created_at:desc)" example(created_at:desc,total_price:asc)
+// @Param fields query string false "Comma-separated order fields to return, such as id,status,total_price (default: all)"
+// @Success 200 {object} OrderListResponse "Orders retrieved successfully"
+// @Failure 400 {object} ErrorResponse "Invalid filter"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Dependency unavailable or service overloaded; retry after Retry-After seconds"
+// @Failure 504 {object} ErrorResponse "Database or Kafka operation timed out"
+// @Router /orders [get]
+func (h *Handler) ListOrders(c *gin.Context) {
+	filter, err := parseOrderFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidQuery, err.Error()))
+		return
+	}
+	filter.Limit = defaultListLimit
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidQuery, fmt.Sprintf("limit must be between 1 and %d", maxListLimit)))
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := c.Query("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidQuery, "offset must be a non-negative integer"))
+			return
+		}
+		filter.Offset = offset
+	}
+	if filter.Sort, err = repository.ParseSort(c.Query("sort")); err != nil {
+		c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidQuery, err.Error()))
+		return
+	}
+	fields, ok := parseOrderFields(c)
+	if !ok {
+		return
+	}
+
+	orders, err := h.orderService.ListOrders(c.Request.Context(), filter)
+	if err != nil {
+		internalError(c, err, "Failed to list orders")
+		return
+	}
+
+	if fields != nil {
+		shaped := shapedOrderList{Orders: make([]any, len(orders)), Limit: filter.Limit, Offset: filter.Offset}
+		for i, order := range orders {
+			shaped.Orders[i] = fields.shape(h.orderResponse(order))
+		}
+		renderOrder(c, http.StatusOK, shaped)
+		return
+	}
+	resp := OrderListResponse{Orders: make([]OrderResponse, len(orders)), Limit: filter.Limit, Offset: filter.Offset}
+	for i, order := range orders {
+		resp.Orders[i] = h.orderResponse(order)
+	}
+	renderOrder(c, http.StatusOK, resp)
+}
+
+// exportFlushEvery is how many orders ExportOrders encodes between flushes
+// to the client.
+const exportFlushEvery = 100
+
+// ExportOrders
+// @Summary Export orders
+// @Description Stream every order matching the filters, oldest first, as CSV (one row per order) or newline-delimited JSON (one object per order, including items). Orders are read from the database in batches while the response is written, so exports of any size use constant memory. Column order is stable. Requires the admin token.
+// @Tags admin
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Security AdminToken
+// @Param format query string false "Export format; ndjson is the same as jsonl" Enums(csv, jsonl, ndjson) default(csv)
+// @Param status query string false "Order status" Enums(pending, processing, completed, cancelled, failed)
+// @Param customer_id query string false "Customer ID" Format(uuid)
+// @Param created_from query string false "Only orders created at or after this time (RFC 3339)"
+// @Param created_to query string false "Only orders created before this time (RFC 3339)"
+// @Success 200 {string} string "Exported orders"
+// @Failure 400 {object} ErrorResponse "Invalid filter or format"
+// @Failure 401 {object} ErrorResponse "Missing or invalid admin token"
+// @Router /orders/export [get]
+func (h *Handler) ExportOrders(c *gin.Context) {
+	format, ok := export.ParseFormat(c.Query("format"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidQuery, "format must be csv, jsonl or ndjson"))
+		return
+	}
+	filter, err := parseOrderFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidQuery, err.Error()))
+		return
+	}
+
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="orders-%s.%s"`, time.Now().UTC().Format("20060102T150405Z"), format))
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(c.Writer, format)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	count := 0
+	err = h.orderService.StreamOrders(c.Request.Context(), filter, func(order *domain.Order) error {
+		if err := w.Write(order); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		// The status line has been sent; the truncated body is all the
+		// client gets, so make sure the failure is logged.
+		c.Error(err)
+		log.Ctx(c.Request.Context()).Error().Err(err).Int("exported", count).Msg("Order export aborted")
+	}
+}
+
+// OrderStats
+// @Summary Order statistics
+// @Description Count the orders matching the filters and total their items and prices, overall and per status. Reads the order_summaries read model, so it does not scan the orders themselves. Requires the admin token.
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Param status query string false "Order status" Enums(pending, processing, completed, cancelled, failed)
+// @Param customer_id query string false "Customer ID" Format(uuid)
+// @Param created_from query string false "Only orders created at or after this time (RFC 3339)"
+// @Param created_to query string false "Only orders created before this time (RFC 3339)"
+// @Success 200 {object} OrderStatsResponse "Order statistics"
+// @Failure 400 {object} ErrorResponse "Invalid filter"
+// @Failure 401 {object} ErrorResponse "Missing or invalid admin token"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Dependency unavailable or service overloaded; retry after Retry-After seconds"
+// @Failure 504 {object} ErrorResponse "Database or Kafka operation timed out"
+// @Router /orders/stats [get]
+func (h *Handler) OrderStats(c *gin.Context) {
+	filter, err := parseOrderFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidQuery, err.Error()))
+		return
+	}
+
+	stats, err := h.orderService.OrderStats(c.Request.Context(), filter)
+	if err != nil {
+		internalError(c, err, "Failed to get order statistics")
+		return
+	}
+	c.JSON(http.StatusOK, NewOrderStatsResponse(stats))
+}
+
+// UpdateOrderStatus
+// @Summary Change order status
+// @Description Move an order to a new status, subject to the allowed status transitions. Requires the admin token.
+// @Tags admin
+// @Accept json
+// @Produce json,xml
+// @Security AdminToken
+// @Param id path string true "Order ID" Format(uuid)
+// @Param request body UpdateOrderStatusRequest true "New status"
+// @Success 200 {object} OrderResponse "Order status updated"
+// @Failure 400 {object} ErrorResponse "Invalid order ID, malformed payload or unknown status"
+// @Failure 413 {object} ErrorResponse "Request body too large"
+// @Failure 401 {object} ErrorResponse "Missing or invalid admin token"
+// @Failure 404 {object} ErrorResponse "Order not found"
+// @Failure 409 {object} InvalidTransitionResponse "Status transition not allowed, or order locked by another change"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Dependency unavailable or service overloaded; retry after Retry-After seconds"
+// @Failure 504 {object} ErrorResponse "Database or Kafka operation timed out"
+// @Router /orders/{id}/status [put]
+func (h *Handler) UpdateOrderStatus(c *gin.Context) {
+	orderID, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+	var req UpdateOrderStatusRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	status, ok := domain.ParseOrderStatus(req.Status)
+	if !ok {
+		c.JSON(http.StatusBadRequest, newErrorResponse(CodeUnknownStatus, "Unknown order status"))
+		return
+	}
+	h.updateOrderStatus(c, orderID, status)
+}
+
+// CancelOrder
+// @Summary Cancel an order
+// @Description Cancel a pending or processing order. Requires the admin token.
+// @Tags admin
+// @Produce json,xml
+// @Security AdminToken
+// @Param id path string true "Order ID" Format(uuid)
+// @Success 200 {object} OrderResponse "Order cancelled"
+// @Failure 400 {object} ErrorResponse "Invalid order ID format"
+// @Failure 401 {object} ErrorResponse "Missing or invalid admin token"
+// @Failure 404 {object} ErrorResponse "Order not found"
+// @Failure 409 {object} InvalidTransitionResponse "Order can no longer be cancelled, or order locked by another change"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Dependency unavailable or service overloaded; retry after Retry-After seconds"
+// @Failure 504 {object} ErrorResponse "Database or Kafka operation timed out"
+// @Router /orders/{id}/cancel [post]
+func (h *Handler) CancelOrder(c *gin.Context) {
+	orderID, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+	h.updateOrderStatus(c, orderID, domain.OrderStatusCancelled)
+}
+
+func (h *Handler) updateOrderStatus(c *gin.Context, orderID uuid.UUID, status domain.OrderStatus) {
+	order, err := h.orderService.UpdateOrderStatus(c.Request.Context(), orderID, status)
+	if err != nil {
+		if errors.Is(err, domain.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, newErrorResponse(CodeOrderNotFound, "Order not found"))
+			return
+		}
+		if errors.Is(err, domain.ErrOrderLocked) {
+			c.JSON(http.StatusConflict, newErrorResponse(CodeOrderLocked, "Order is being changed by another request, please retry"))
+			return
+		}
+		var transitionErr *domain.InvalidTransitionError
+		if errors.As(err, &transitionErr) {
+			c.JSON(http.StatusConflict, InvalidTransitionResponse{
+				ErrorResponse:   newErrorResponse(CodeInvalidStatusTransition, transitionErr.Error()),
+				Status:          string(transitionErr.From),
+				RequestedStatus: string(transitionErr.To),
+			})
+			return
+		}
+		internalError(c, err, "Failed to update order status")
+		return
+	}
+	renderOrder(c, http.StatusOK, h.orderResponse(order))
+}
+
+// ResendOrderPlaced
+// @Summary Resend the order placed event
+// @Description Publish the order's orders.placed event again. Requires the admin token.
+// @Tags admin
+// @Produce json,xml
+// @Security AdminToken
+// @Param id path string true "Order ID" Format(uuid)
+// @Success 202 {object} OrderResponse "Event published"
+// @Failure 400 {object} ErrorResponse "Invalid order ID format"
+// @Failure 401 {object} ErrorResponse "Missing or invalid admin token"
+// @Failure 404 {object} ErrorResponse "Order not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Dependency unavailable or service overloaded; retry after Retry-After seconds"
+// @Failure 504 {object} ErrorResponse "Database or Kafka operation timed out"
+// @Router /orders/{id}/resend-event [post]
+func (h *Handler) ResendOrderPlaced(c *gin.Context) {
+	orderID, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+	order, err := h.orderService.ResendOrderPlaced(c.Request.Context(), orderID)
+	if err != nil {
+		if errors.Is(err, domain.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, newErrorResponse(CodeOrderNotFound, "Order not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to get order"})
+		internalError(c, err, "Failed to resend order event")
 		return
 	}
+	renderOrder(c, http.StatusAccepted, h.orderResponse(order))
+}
+
+// internalError answers a request that failed for reasons outside the
+// client's control: 503 with Retry-After if a circuit breaker rejected the
+// call, 504 if the database or Kafka did not answer in time, 500 with message
+// otherwise.
+func internalError(c *gin.Context, err error, message string) {
+	if retryAfter, ok := circuitbreaker.RetryAfter(err); ok {
+		serviceUnavailable(c, retryAfter)
+		return
+	}
+	if errors.Is(err, service.ErrDatabaseTimeout) || errors.Is(err, service.ErrPublishTimeout) {
+		c.Error(err)
+		c.JSON(http.StatusGatewayTimeout, timeoutResponse(err))
+		return
+	}
+	c.Error(err) // Log the error using Gin's error logging
+	c.JSON(http.StatusInternalServerError, newErrorResponse(CodeInternal, message))
+}
+
+// timeoutResponse describes a timeout error of the order service.
+func timeoutResponse(err error) ErrorResponse {
+	if errors.Is(err, service.ErrPublishTimeout) {
+		return newErrorResponse(CodePublishTimeout, "Publishing the order event timed out")
+	}
+	return newErrorResponse(CodeDatabaseTimeout, "Database operation timed out")
+}
+
+// invalidOrderCode returns the error code of the domain errors rejecting the
+// contents of a new order.
+func invalidOrderCode(err error) (string, bool) {
+	switch {
+	case errors.Is(err, domain.ErrNoOrderItems):
+		return CodeOrderItemsRequired, true
+	case errors.Is(err, domain.ErrInvalidOrderItemQuantity):
+		return CodeItemQuantityInvalid, true
+	case errors.Is(err, domain.ErrInvalidOrderItemUnitPrice):
+		return CodeItemPriceInvalid, true
+	case errors.Is(err, domain.ErrInvalidExternalReference):
+		return CodeExternalReferenceInvalid, true
+	}
+	return "", false
+}
 
-	c.JSON(http.StatusOK, NewOrderResponse(order))
+// parseOrderID reads the :id path parameter, answering 400 if it is not a UUID.
+func parseOrderID(c *gin.Context) (uuid.UUID, bool) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, newErrorResponse(CodeInvalidOrderID, "Invalid order ID format"))
+		return uuid.Nil, false
+	}
+	return orderID, true
+}
+
+// parseOrderFilter reads the status, customer_id, created_from and created_to
+// query parameters.
+func parseOrderFilter(c *gin.Context) (repository.OrderFilter, error) {
+	var filter repository.OrderFilter
+	if v := c.Query("status"); v != "" {
+		status, ok := domain.ParseOrderStatus(v)
+		if !ok {
+			return filter, fmt.Errorf("unknown order status %q", v)
+		}
+		filter.Status = status
+	}
+	if v := c.Query("customer_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return filter, errors.New("customer_id must be a UUID")
+		}
+		filter.CustomerID = id
+	}
+	var err error
+	if filter.CreatedFrom, err = parseTimeQuery(c, "created_from"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedTo, err = parseTimeQuery(c, "created_to"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// parseTimeQuery parses an optional RFC 3339 query parameter, in any offset,
+// into UTC.
+func parseTimeQuery(c *gin.Context, param string) (time.Time, error) {
+	v := c.Query(param)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", param)
+	}
+	return t.UTC(), nil
 }
@@ -0,0 +1,779 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/api"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/catalog"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamingService serves a fixed set of orders from StreamOrders. Other
+// methods are not used by these tests.
+type streamingService struct {
+	service.OrderService
+	orders []*domain.Order
+	filter repository.OrderFilter
+}
+
+func (s *streamingService) StreamOrders(_ context.Context, filter repository.OrderFilter, fn func(*domain.Order) error) error {
+	s.filter = filter
+	for _, order := range s.orders {
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestHandler_ExportOrders(t *testing.T) {
+	order := &domain.Order{
+		ID:         uuid.New(),
+		CustomerID: uuid.New(),
+		Status:     domain.OrderStatusCompleted,
+		TotalPrice: 12.5,
+		Items:      []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 12.5}},
+		CreatedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		UpdatedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	svc := &streamingService{orders: []*domain.Order{order}}
+	router := gin.New()
+	handler := api.NewHandler(svc)
+	router.GET("/orders/export", handler.ExportOrders)
+	router.GET("/orders/:id", handler.GetOrderByID)
+
+	t.Run("csv", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/export?status=completed&created_from=2024-01-01T00:00:00Z", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), ".csv")
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		if assert.Len(t, lines, 2) {
+			assert.True(t, strings.HasPrefix(lines[0], "order_id,customer_id,status"))
+			assert.True(t, strings.HasPrefix(lines[1], order.ID.String()+","))
+		}
+		assert.Equal(t, domain.OrderStatusCompleted, svc.filter.Status)
+		assert.Equal(t, 2024, svc.filter.CreatedFrom.Year())
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/export?format=jsonl", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), `"order_id":"`+order.ID.String()+`"`)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/export?format=xml", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/export?created_to=yesterday", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "created_to")
+	})
+}
+
+// statsService serves fixed statistics from OrderStats.
+type statsService struct {
+	service.OrderService
+	stats  []repository.OrderStatusStats
+	filter repository.OrderFilter
+}
+
+func (s *statsService) OrderStats(_ context.Context, filter repository.OrderFilter) ([]repository.OrderStatusStats, error) {
+	s.filter = filter
+	return s.stats, nil
+}
+
+func TestHandler_OrderStats(t *testing.T) {
+	svc := &statsService{stats: []repository.OrderStatusStats{
+		{Status: domain.OrderStatusCompleted, Orders: 2, Items: 5, TotalPrice: 40},
+		{Status: domain.OrderStatusPending, Orders: 1, Items: 1, TotalPrice: 2.5},
+	}}
+	router := gin.New()
+	router.GET("/orders/stats", api.NewHandler(svc).OrderStats)
+
+	customerID := uuid.New()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/stats?customer_id="+customerID.String(), nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"orders": 3, "items": 6, "total_price": 42.5,
+		"by_status": [
+			{"status": "completed", "orders": 2, "items": 5, "total_price": 40},
+			{"status": "pending", "orders": 1, "items": 1, "total_price": 2.5}
+		]
+	}`, w.Body.String())
+	assert.Equal(t, customerID, svc.filter.CustomerID)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/stats?status=unknown", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// overQuotaService rejects every order as over the customer's quota.
+type overQuotaService struct {
+	service.OrderService
+}
+
+func (overQuotaService) CreateOrder(context.Context, uuid.UUID, []domain.OrderItem, domain.ExternalReference, *domain.Address) (*domain.Order, error) {
+	return nil, fmt.Errorf("service: %w", &domain.QuotaExceededError{Window: "minute", Limit: 30, RetryAfter: 41500 * time.Millisecond})
+}
+
+func TestHandler_CreateOrder_QuotaExceeded(t *testing.T) {
+	router := gin.New()
+	router.POST("/orders", api.NewHandler(overQuotaService{}).CreateOrder)
+
+	body := `{"customer_id":"` + uuid.NewString() + `","items":[{"product_id":"` + uuid.NewString() + `","quantity":1,"unit_price":9.99}]}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "42", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"code":"QUOTA_EXCEEDED","message":"customer order quota exceeded","window":"minute","limit":30,"retry_after_seconds":42}`, w.Body.String())
+}
+
+type outOfStockService struct {
+	service.OrderService
+	productID uuid.UUID
+}
+
+func (s outOfStockService) CreateOrder(context.Context, uuid.UUID, []domain.OrderItem, domain.ExternalReference, *domain.Address) (*domain.Order, error) {
+	return nil, fmt.Errorf("service: %w", &domain.OutOfStockError{ProductIDs: []uuid.UUID{s.productID}})
+}
+
+func TestHandler_CreateOrder_OutOfStock(t *testing.T) {
+	productID := uuid.New()
+	router := gin.New()
+	router.POST("/orders", api.NewHandler(outOfStockService{productID: productID}).CreateOrder)
+
+	body := `{"customer_id":"` + uuid.NewString() + `","items":[{"product_id":"` + productID.String() + `","quantity":1,"unit_price":9.99}]}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"code":"OUT_OF_STOCK","message":"product is out of stock","product_ids":["`+productID.String()+`"]}`, w.Body.String())
+}
+
+// catalogDownService fails every order because the catalog can't be asked.
+type catalogDownService struct {
+	service.OrderService
+}
+
+func (catalogDownService) CreateOrder(context.Context, uuid.UUID, []domain.OrderItem, domain.ExternalReference, *domain.Address) (*domain.Order, error) {
+	return nil, fmt.Errorf("service: product catalog validation failed: %w: unexpected status 502", catalog.ErrUnavailable)
+}
+
+func TestHandler_CreateOrder_CatalogUnavailable(t *testing.T) {
+	router := gin.New()
+	router.POST("/orders", api.NewHandler(catalogDownService{}).CreateOrder)
+
+	body := `{"customer_id":"` + uuid.NewString() + `","items":[{"product_id":"` + uuid.NewString() + `","quantity":1,"unit_price":9.99}]}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":"SERVICE_UNAVAILABLE"`)
+}
+
+type invalidAddressService struct {
+	service.OrderService
+}
+
+func (invalidAddressService) CreateOrder(context.Context, uuid.UUID, []domain.OrderItem, domain.ExternalReference, *domain.Address) (*domain.Order, error) {
+	return nil, fmt.Errorf("service: %w", &domain.InvalidAddressError{Fields: []domain.AddressFieldError{
+		{Field: "postal_code", Constraint: "format", Message: "is not a valid postal code for US"},
+		{Constraint: "deliverable", Message: "is not deliverable"},
+	}})
+}
+
+func TestHandler_CreateOrder_InvalidAddress(t *testing.T) {
+	router := gin.New()
+	router.POST("/orders", api.NewHandler(invalidAddressService{}).CreateOrder)
+
+	body := `{"customer_id":"` + uuid.NewString() + `","items":[{"product_id":"` + uuid.NewString() + `","quantity":1,"unit_price":9.99}],` +
+		`"shipping_address":{"line1":"1 Main St","city":"Springfield","postal_code":"ABC","country":"US"}}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"code":"ADDRESS_INVALID","message":"Invalid shipping address","details":[`+
+		`{"field":"shipping_address.postal_code","constraint":"format","message":"is not a valid postal code for US"},`+
+		`{"field":"shipping_address","constraint":"deliverable","message":"is not deliverable"}]}`, w.Body.String())
+}
+
+func TestHandler_CreateOrder_InvalidPayload(t *testing.T) {
+	router := gin.New()
+	router.Use(api.BodyLimitMiddleware(512))
+	router.POST("/orders", api.NewHandler(overQuotaService{}).CreateOrder)
+
+	customerID, productID := uuid.NewString(), uuid.NewString()
+	item := `{"product_id":"` + productID + `","quantity":1,"unit_price":9.99}`
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		code    string
+		message string
+	}{
+		{"empty body", ``, http.StatusBadRequest, api.CodeMalformedRequest, "Request body is empty"},
+		{"malformed", `{"customer_id":`, http.StatusBadRequest, api.CodeMalformedRequest, "Malformed JSON: unexpected end of request body"},
+		{"unknown field", `{"customer_id":"` + customerID + `","items":[` + item + `],"coupon":"FREE"}`, http.StatusBadRequest, api.CodeUnknownField, `Unknown field "coupon"`},
+		{"unknown item field", `{"customer_id":"` + customerID + `","items":[{"product_id":"` + productID + `","qty":1,"unit_price":9.99}]}`, http.StatusBadRequest, api.CodeUnknownField, `Unknown field "qty"`},
+		{"wrong type", `{"customer_id":"` + customerID + `","items":[{"product_id":"` + productID + `","quantity":"1","unit_price":9.99}]}`, http.StatusBadRequest, api.CodeValidationFailed, "items[0].quantity must be an integer"},
+		{"invalid uuid", `{"customer_id":"` + customerID + `","items":[{"product_id":"42","quantity":1,"unit_price":9.99}]}`, http.StatusBadRequest, api.CodeValidationFailed, "items[0].product_id is invalid: invalid UUID length: 2"},
+		{"trailing data", `{"customer_id":"` + customerID + `","items":[` + item + `]}{}`, http.StatusBadRequest, api.CodeMalformedRequest, "Request body must contain a single JSON object"},
+		{"missing customer", `{"items":[` + item + `]}`, http.StatusBadRequest, api.CodeValidationFailed, "customer_id is required"},
+		{"no items", `{"customer_id":"` + customerID + `","items":[]}`, http.StatusBadRequest, api.CodeValidationFailed, "items must contain at least 1 element(s)"},
+		{"invalid item", `{"customer_id":"` + customerID + `","items":[` + item + `,{"product_id":"` + productID + `","quantity":1,"unit_price":-1}]}`, http.StatusBadRequest, api.CodeValidationFailed, "items[1].unit_price must be greater than 0"},
+		{"too large", `{"customer_id":"` + customerID + `","items":[` + strings.Repeat(item+",", 10) + item + `]}`, http.StatusRequestEntityTooLarge, api.CodePayloadTooLarge, "Request body exceeds 512 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.status, w.Code)
+			var resp api.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.code, resp.Code)
+			assert.Equal(t, tt.message, resp.Message)
+		})
+	}
+
+	t.Run("every invalid field is detailed", func(t *testing.T) {
+		body := `{"items":[{"product_id":"` + productID + `","quantity":0,"unit_price":9.99}]}`
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{
+			"code": "VALIDATION_FAILED",
+			"message": "customer_id is required; items[0].quantity is required",
+			"details": [
+				{"field": "customer_id", "constraint": "required", "message": "is required"},
+				{"field": "items[0].quantity", "constraint": "required", "message": "is required"}
+			]
+		}`, w.Body.String())
+	})
+
+	t.Run("details carry the constraint and value", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			body   string
+			detail api.ErrorDetail
+		}{
+			{"rule", `{"customer_id":"` + customerID + `","items":[{"product_id":"` + productID + `","quantity":1,"unit_price":-1}]}`,
+				api.ErrorDetail{Field: "items[0].unit_price", Constraint: "gt=0", Value: -1.0, Message: "must be greater than 0"}},
+			{"type", `{"customer_id":"` + customerID + `","items":[{"product_id":"` + productID + `","quantity":"two","unit_price":9.99}]}`,
+				api.ErrorDetail{Field: "items[0].quantity", Constraint: "type", Value: "two", Message: "must be an integer"}},
+			{"format", `{"customer_id":"` + customerID + `","items":[{"product_id":"not-a-uuid","quantity":1,"unit_price":9.99}]}`,
+				api.ErrorDetail{Field: "items[0].product_id", Constraint: "format", Value: "not-a-uuid", Message: "is invalid: invalid UUID length: 10"}},
+			{"unknown", `{"customer_id":"` + customerID + `","items":[` + item + `],"coupon":"FREE"}`,
+				api.ErrorDetail{Field: "coupon", Constraint: "unknown", Message: "is not a field of this request"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(tt.body)))
+
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				var resp api.ErrorResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, []api.ErrorDetail{tt.detail}, resp.Details)
+			})
+		}
+	})
+}
+
+// duplicateService treats every order as a repeat of existing: rejected, or
+// created and flagged when flag is set.
+type duplicateService struct {
+	service.OrderService
+	existing uuid.UUID
+	flag     bool
+}
+
+func (s duplicateService) CreateOrder(_ context.Context, customerID uuid.UUID, items []domain.OrderItem, _ domain.ExternalReference, _ *domain.Address) (*domain.Order, error) {
+	if !s.flag {
+		return nil, fmt.Errorf("service: %w", &domain.DuplicateOrderError{ExistingOrderID: s.existing})
+	}
+	order, err := domain.NewOrder(customerID, items)
+	if err != nil {
+		return nil, err
+	}
+	order.DuplicateOf = s.existing
+	return order, nil
+}
+
+func TestHandler_CreateOrder_Duplicate(t *testing.T) {
+	existing := uuid.New()
+	body := `{"customer_id":"` + uuid.NewString() + `","items":[{"product_id":"` + uuid.NewString() + `","quantity":1,"unit_price":9.99}]}`
+	serve := func(svc service.OrderService) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.POST("/orders", api.NewHandler(svc).CreateOrder)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body)))
+		return w
+	}
+
+	w := serve(duplicateService{existing: existing})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"code":"DUPLICATE_ORDER","message":"duplicate order","order_id":"`+existing.String()+`"}`, w.Body.String())
+
+	w = serve(duplicateService{existing: existing, flag: true})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"duplicate_of":"`+existing.String()+`"`)
+}
+
+// importService holds the orders imported so far, by external reference.
+type importService struct {
+	service.OrderService
+	orders map[domain.ExternalReference]*domain.Order
+}
+
+func (s importService) CreateOrder(_ context.Context, customerID uuid.UUID, items []domain.OrderItem, ref domain.ExternalReference, _ *domain.Address) (*domain.Order, error) {
+	if existing, ok := s.orders[ref]; ok {
+		return nil, fmt.Errorf("service: %w", &domain.ExternalReferenceConflictError{ExistingOrderID: existing.ID})
+	}
+	order, err := domain.NewOrder(customerID, items)
+	if err != nil {
+		return nil, err
+	}
+	order.ExternalReference = ref
+	s.orders[ref] = order
+	return order, nil
+}
+
+func (s importService) GetOrderByExternalReference(_ context.Context, ref domain.ExternalReference) (*domain.Order, error) {
+	if order, ok := s.orders[ref]; ok {
+		return order, nil
+	}
+	return nil, domain.ErrOrderNotFound
+}
+
+func TestHandler_ExternalReference(t *testing.T) {
+	handler := api.NewHandler(importService{orders: map[domain.ExternalReference]*domain.Order{}})
+	router := gin.New()
+	router.POST("/orders", handler.CreateOrder)
+	router.GET("/orders/by-reference", handler.GetOrderByExternalReference)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	body := `{"customer_id":"` + uuid.NewString() + `","items":[{"product_id":"` + uuid.NewString() + `","quantity":1,"unit_price":9.99}],` +
+		`"external_reference":{"source":"shopify","reference":"#1001"}}`
+	w := serve(http.MethodPost, "/orders", body)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created api.OrderResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, &api.ExternalReferenceBody{Source: "shopify", Reference: "#1001"}, created.ExternalReference)
+
+	w = serve(http.MethodPost, "/orders", body)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"code":"EXTERNAL_REFERENCE_EXISTS","message":"external reference already used","order_id":"`+created.ID.String()+`"}`, w.Body.String())
+
+	w = serve(http.MethodGet, "/orders/by-reference?source=shopify&reference=%231001", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"`+created.ID.String()+`"`)
+
+	w = serve(http.MethodGet, "/orders/by-reference?source=shopify&reference=%231002", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(http.MethodGet, "/orders/by-reference?source=shopify", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// cancelledService rejects every status change of an already cancelled order.
+type cancelledService struct {
+	service.OrderService
+}
+
+func (cancelledService) UpdateOrderStatus(_ context.Context, orderID uuid.UUID, status domain.OrderStatus) (*domain.Order, error) {
+	return nil, fmt.Errorf("service: cannot update order %s: %w", orderID,
+		&domain.InvalidTransitionError{From: domain.OrderStatusCancelled, To: status})
+}
+
+func TestHandler_UpdateOrderStatus_InvalidTransition(t *testing.T) {
+	router := gin.New()
+	router.PUT("/orders/:id/status", api.NewHandler(cancelledService{}).UpdateOrderStatus)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/orders/"+uuid.NewString()+"/status", strings.NewReader(`{"status":"completed"}`)))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{
+		"code": "INVALID_STATUS_TRANSITION",
+		"message": "invalid order status transition from cancelled to completed",
+		"status": "cancelled",
+		"requested_status": "completed"
+	}`, w.Body.String())
+}
+
+// storedService serves a single order from GetOrderByID.
+type storedService struct {
+	service.OrderService
+	order *domain.Order
+}
+
+func (s storedService) GetOrderByID(_ context.Context, orderID uuid.UUID) (*domain.Order, error) {
+	if orderID != s.order.ID {
+		return nil, domain.ErrOrderNotFound
+	}
+	return s.order, nil
+}
+
+func (s storedService) ListOrders(context.Context, repository.OrderFilter) ([]*domain.Order, error) {
+	return []*domain.Order{s.order}, nil
+}
+
+func TestHandler_GetOrderByID_ETag(t *testing.T) {
+	order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 9.99}})
+	require.NoError(t, err)
+	router := gin.New()
+	router.GET("/orders/:id", api.NewHandler(storedService{order: order}).GetOrderByID)
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/orders/"+order.ID.String(), nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	for _, header := range []string{etag, strings.TrimPrefix(etag, "W/"), `"other", ` + etag, "*"} {
+		w = get(header)
+		assert.Equal(t, http.StatusNotModified, w.Code, header)
+		assert.Empty(t, w.Body.String(), header)
+		assert.Equal(t, etag, w.Header().Get("ETag"), header)
+	}
+
+	w = get(`"other"`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	require.NoError(t, order.TransitionTo(domain.OrderStatusProcessing))
+	w = get(etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), `"status":"processing"`)
+}
+
+func TestHandler_GetOrderByID_NotModifiedVary(t *testing.T) {
+	order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 9.99}})
+	require.NoError(t, err)
+	router := gin.New()
+	router.Use(api.LocalizationMiddleware())
+	router.GET("/orders/:id", api.NewHandler(storedService{order: order}).GetOrderByID)
+	get := func(accept, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/orders/"+order.ID.String(), nil)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("application/xml", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.ElementsMatch(t, []string{"Accept", "Accept-Language"}, w.Header().Values("Vary"))
+
+	// A cache must not answer a JSON client with the XML it revalidated.
+	w = get("application/xml", w.Header().Get("ETag"))
+	require.Equal(t, http.StatusNotModified, w.Code)
+	assert.ElementsMatch(t, []string{"Accept", "Accept-Language"}, w.Header().Values("Vary"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+}
+
+func TestHandler_SparseFieldsets(t *testing.T) {
+	order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 2, UnitPrice: 5}})
+	require.NoError(t, err)
+	handler := api.NewHandler(storedService{order: order})
+	router := gin.New()
+	router.GET("/orders", handler.ListOrders)
+	router.GET("/orders/:id", handler.GetOrderByID)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	w := get("/orders/" + order.ID.String() + "?fields=id,status,total_price")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":"`+order.ID.String()+`","status":"pending","total_price":10}`, w.Body.String())
+
+	w = get("/orders?fields=status,%20external_reference,status")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"orders":[{"status":"pending"}],"limit":50,"offset":0}`, w.Body.String())
+
+	w = get("/orders/" + order.ID.String())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"items":[`)
+
+	w = get("/orders/" + order.ID.String() + "?fields=id,secret")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp api.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, api.CodeInvalidQuery, resp.Code)
+	assert.Equal(t, []api.ErrorDetail{{Field: "fields", Constraint: "unknown", Value: "secret", Message: "is not a field of an order"}}, resp.Details)
+}
+
+// listingService records the filter of the last ListOrders call.
+type listingService struct {
+	service.OrderService
+	filter repository.OrderFilter
+}
+
+func (s *listingService) ListOrders(_ context.Context, filter repository.OrderFilter) ([]*domain.Order, error) {
+	s.filter = filter
+	return nil, nil
+}
+
+func TestHandler_ListOrders_Sort(t *testing.T) {
+	svc := &listingService{}
+	router := gin.New()
+	router.GET("/orders", api.NewHandler(svc).ListOrders)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	w := get("/orders?sort=created_at:desc,total_price")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []repository.SortKey{
+		{Field: repository.SortByCreatedAt, Descending: true},
+		{Field: repository.SortByTotalPrice},
+	}, svc.filter.Sort)
+
+	w = get("/orders")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, svc.filter.Sort)
+
+	for _, sort := range []string{"customer_id", "total_price:up", "status,status:desc", "created_at;drop table orders"} {
+		w = get("/orders?sort=" + url.QueryEscape(sort))
+		assert.Equal(t, http.StatusBadRequest, w.Code, sort)
+		assert.Contains(t, w.Body.String(), api.CodeInvalidQuery, sort)
+	}
+}
+
+func TestNewOrderResponse_Links(t *testing.T) {
+	order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 9.99}})
+	require.NoError(t, err)
+	self := "/api/v1/orders/" + order.ID.String()
+
+	links := api.NewOrderResponse(order).Links
+	assert.Equal(t, api.Link{Href: self, Method: http.MethodGet}, links.Self)
+	assert.Equal(t, &api.Link{Href: self + "/cancel", Method: http.MethodPost}, links.Cancel)
+	assert.Equal(t, api.Link{Href: self + "/items", Method: http.MethodGet}, links.Items)
+	assert.Nil(t, links.Events, "orders are linked to their events by handlers WithOrderEvents")
+	assert.Equal(t, api.Link{Href: "/api/v1/orders?customer_id=" + order.CustomerID.String(), Method: http.MethodGet}, links.CustomerOrders)
+
+	require.NoError(t, order.TransitionTo(domain.OrderStatusCancelled))
+	links = api.NewOrderResponse(order).Links
+	assert.Nil(t, links.Cancel, "a cancelled order cannot be cancelled again")
+}
+
+func TestHandler_OrderEventsLink(t *testing.T) {
+	order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 9.99}})
+	require.NoError(t, err)
+	get := func(handler *api.Handler) api.OrderResponse {
+		router := gin.New()
+		router.GET("/orders/:id", handler.GetOrderByID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/"+order.ID.String(), nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp api.OrderResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	// Without event streams the events endpoint has nothing to offer.
+	assert.Nil(t, get(api.NewHandler(storedService{order: order})).Links.Events)
+
+	resp := get(api.NewHandler(storedService{order: order}, api.WithOrderEvents()))
+	assert.Equal(t, &api.Link{Href: "/api/v1/orders/" + order.ID.String() + "/events", Method: http.MethodGet}, resp.Links.Events)
+}
+
+func TestHandler_GetOrderItems(t *testing.T) {
+	order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 2, UnitPrice: 5}})
+	require.NoError(t, err)
+	router := gin.New()
+	router.GET("/orders/:id/items", api.NewHandler(storedService{order: order}).GetOrderItems)
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	expected := []api.OrderItemResponse{{ProductID: order.Items[0].ProductID, Quantity: 2, UnitPrice: 5}}
+
+	w := get("/orders/"+order.ID.String()+"/items", "application/json")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp api.OrderItemsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, expected, resp.Items)
+
+	w = get("/orders/"+order.ID.String()+"/items", "application/xml")
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp = api.OrderItemsResponse{}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, expected, resp.Items)
+
+	w = get("/orders/"+uuid.NewString()+"/items", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), api.CodeOrderNotFound)
+
+	w = get("/orders/not-a-uuid/items", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// eventsService serves fixed events from OrderEvents for one order.
+type eventsService struct {
+	service.OrderService
+	orderID uuid.UUID
+	events  []repository.StoredEvent
+}
+
+func (s eventsService) OrderEvents(_ context.Context, orderID uuid.UUID) ([]repository.StoredEvent, error) {
+	if orderID != s.orderID {
+		return nil, fmt.Errorf("service: failed to get order %s for its events: %w", orderID, domain.ErrOrderNotFound)
+	}
+	return s.events, nil
+}
+
+func TestHandler_GetOrderEvents(t *testing.T) {
+	orderID := uuid.New()
+	svc := eventsService{orderID: orderID, events: []repository.StoredEvent{
+		{Type: "order_created", Value: []byte(`{"order_id":"` + orderID.String() + `","version":1,"type":"order_created","data":{}}`)},
+		{Type: "order_status_changed", Value: []byte(`{"order_id":"` + orderID.String() + `","version":2,"type":"order_status_changed","data":{"from":"pending","to":"processing"}}`)},
+	}}
+	router := gin.New()
+	router.GET("/orders/:id/events", api.NewHandler(svc).GetOrderEvents)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	w := get("/orders/" + orderID.String() + "/events")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Events []struct {
+			Version int    `json:"version"`
+			Type    string `json:"type"`
+		} `json:"events"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Events, 2) {
+		assert.Equal(t, 1, resp.Events[0].Version)
+		assert.Equal(t, "order_status_changed", resp.Events[1].Type)
+	}
+
+	// An order without events, such as one that is not event-sourced.
+	router = gin.New()
+	router.GET("/orders/:id/events", api.NewHandler(eventsService{orderID: orderID}).GetOrderEvents)
+	w = get("/orders/" + orderID.String() + "/events")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"events":[]}`, w.Body.String())
+
+	w = get("/orders/" + uuid.NewString() + "/events")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), api.CodeOrderNotFound)
+
+	w = get("/orders/not-a-uuid/events")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_XML(t *testing.T) {
+	order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 2, UnitPrice: 5}})
+	require.NoError(t, err)
+	handler := api.NewHandler(storedService{order: order})
+	router := gin.New()
+	router.GET("/orders", handler.ListOrders)
+	router.GET("/orders/:id", handler.GetOrderByID)
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/orders/"+order.ID.String(), "application/xml")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/xml")
+	assert.Contains(t, w.Header().Values("Vary"), "Accept")
+	var resp api.OrderResponse
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, order.ID, resp.ID)
+	assert.Equal(t, "pending", resp.Status)
+	assert.Equal(t, []api.OrderItemResponse{{ProductID: order.Items[0].ProductID, Quantity: 2, UnitPrice: 5}}, resp.Items)
+	assert.Equal(t, "/api/v1/orders/"+order.ID.String(), resp.Links.Self.Href)
+
+	w = get("/orders?fields=id,status", "text/xml")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `<orders limit="50" offset="0"><order><id>`+order.ID.String()+`</id><status>pending</status></order></orders>`, w.Body.String())
+
+	for _, accept := range []string{"", "*/*", "application/json, application/xml", "text/html"} {
+		w = get("/orders/"+order.ID.String(), accept)
+		assert.Equal(t, http.StatusOK, w.Code, accept)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json", accept)
+	}
+}
+
+// slowService times out on every read.
+type slowService struct {
+	service.OrderService
+}
+
+func (slowService) GetOrderByID(context.Context, uuid.UUID) (*domain.Order, error) {
+	return nil, fmt.Errorf("service: failed to get order by ID: %w: %w", service.ErrDatabaseTimeout, context.DeadlineExceeded)
+}
+
+func TestHandler_Timeout(t *testing.T) {
+	router := gin.New()
+	router.GET("/orders/:id", api.NewHandler(slowService{}).GetOrderByID)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/"+uuid.NewString(), nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.JSONEq(t, `{"code":"DATABASE_TIMEOUT","message":"Database operation timed out"}`, w.Body.String())
+}
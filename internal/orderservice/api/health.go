@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/buildinfo"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/health"
+)
+
+// readinessTimeout bounds how long dependency checks may take per probe.
+const readinessTimeout = 2 * time.Second
+
+// HealthHandler serves the Kubernetes liveness and readiness probes.
+type HealthHandler struct {
+	probe *health.Probe
+}
+
+// NewHealthHandler creates a new HealthHandler backed by probe.
+func NewHealthHandler(probe *health.Probe) *HealthHandler {
+	return &HealthHandler{probe: probe}
+}
+
+// Liveness godoc
+// @Summary Liveness probe
+// @Description Reports whether the process is running. Does not check dependencies.
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string "Alive"
+// @Router /healthz [get]
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness godoc
+// @Summary Readiness probe
+// @Description Reports whether the service can accept traffic: database and Kafka must be reachable and the service must not be shutting down.
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Result "Ready"
+// @Failure 503 {object} health.Result "Not ready"
+// @Router /readyz [get]
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	result := h.probe.Ready(ctx)
+	status := http.StatusOK
+	if !result.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, result)
+}
+
+// VersionHandler serves the build of the running service and the optional
+// features enabled, so deployments can be checked at a glance.
+// @Summary Build information
+// @Description Reports the version, git commit, build time and Go version of the running service, and which optional features its configuration enables.
+// @Tags health
+// @Produce json
+// @Success 200 {object} buildinfo.Info "Build information"
+// @Router /version [get]
+func VersionHandler(info buildinfo.Info) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, info)
+	}
+}
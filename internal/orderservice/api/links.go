@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+)
+
+// apiBasePath is where the order service mounts the API (see @BasePath).
+const apiBasePath = "/api/v1"
+
+// Link @Description A related resource, or an action available on the resource, and the HTTP method to use.
+type Link struct {
+	Href   string `json:"href" xml:"href,attr" example:"/api/v1/orders/a1b2c3d4-e5f6-7890-1234-567890abcdef"`
+	Method string `json:"method" xml:"method,attr" example:"GET"`
+}
+
+// OrderLinks @Description Links from an order: the order itself, cancelling it while it can still be cancelled (requires the admin token), its items, its events when the service stores orders as events, and the customer's other orders.
+type OrderLinks struct {
+	Self           Link  `json:"self" xml:"self"`
+	Cancel         *Link `json:"cancel,omitempty" xml:"cancel,omitempty"`
+	Items          Link  `json:"items" xml:"items"`
+	Events         *Link `json:"events,omitempty" xml:"events,omitempty"`
+	CustomerOrders Link  `json:"customer_orders" xml:"customer_orders"`
+}
+
+// newOrderLinks returns the links of order. Actions the order's status does
+// not allow are left out.
+func newOrderLinks(order *domain.Order) OrderLinks {
+	self := apiBasePath + "/orders/" + order.ID.String()
+	links := OrderLinks{
+		Self:           Link{Href: self, Method: http.MethodGet},
+		Items:          Link{Href: self + "/items", Method: http.MethodGet},
+		CustomerOrders: Link{Href: apiBasePath + "/orders?" + url.Values{"customer_id": {order.CustomerID.String()}}.Encode(), Method: http.MethodGet},
+	}
+	if order.CanTransitionTo(domain.OrderStatusCancelled) {
+		links.Cancel = &Link{Href: self + "/cancel", Method: http.MethodPost}
+	}
+	return links
+}
+
+// eventsLink returns the link to the events of the order at self.
+func eventsLink(self string) *Link {
+	return &Link{Href: self + "/events", Method: http.MethodGet}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/backpressure"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// LoadSheddingConfig bounds the requests the API works on at once.
+type LoadSheddingConfig struct {
+	// MaxInFlight caps the requests served concurrently. Zero disables the
+	// cap and the queue.
+	MaxInFlight int `key:"max_in_flight" env:"HTTP_MAX_IN_FLIGHT" default:"256"`
+	// MaxQueue caps the requests waiting for a slot; further requests are
+	// rejected at once.
+	MaxQueue int `key:"max_queue" env:"HTTP_MAX_QUEUE" default:"256"`
+	// QueueTimeout is how long a request may wait for a slot.
+	QueueTimeout time.Duration `key:"queue_timeout" env:"HTTP_QUEUE_TIMEOUT" default:"1s"`
+	// RetryAfter is the Retry-After sent with requests rejected for load.
+	RetryAfter time.Duration `key:"retry_after" env:"HTTP_SHED_RETRY_AFTER" default:"1s"`
+}
+
+// LoadSheddingMiddleware answers 503 with a Retry-After header, without
+// serving the request, while any of breakers is open or when the service is
+// saturated: cfg.MaxInFlight requests are being served and the request
+// either finds cfg.MaxQueue others waiting or waits longer than
+// cfg.QueueTimeout for a slot. Clients get a fast answer they can retry
+// instead of piling onto a dependency that is down.
+func LoadSheddingMiddleware(cfg LoadSheddingConfig, breakers ...*circuitbreaker.Breaker) gin.HandlerFunc {
+	var slots chan struct{}
+	if cfg.MaxInFlight > 0 {
+		slots = make(chan struct{}, cfg.MaxInFlight)
+	}
+	var waiting atomic.Int64
+
+	return func(c *gin.Context) {
+		for _, breaker := range breakers {
+			if err := breaker.Check(); err != nil {
+				retryAfter, _ := circuitbreaker.RetryAfter(err)
+				shed(c, "circuit_open", retryAfter)
+				return
+			}
+		}
+		if slots == nil {
+			c.Next()
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			if waiting.Add(1) > int64(cfg.MaxQueue) {
+				waiting.Add(-1)
+				shed(c, "queue_full", cfg.RetryAfter)
+				return
+			}
+			timer := time.NewTimer(cfg.QueueTimeout)
+			select {
+			case slots <- struct{}{}:
+				timer.Stop()
+				waiting.Add(-1)
+			case <-timer.C:
+				waiting.Add(-1)
+				shed(c, "queue_timeout", cfg.RetryAfter)
+				return
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				waiting.Add(-1)
+				c.Abort()
+				return
+			}
+		}
+		defer func() { <-slots }()
+		c.Next()
+	}
+}
+
+// BackpressureMiddleware rejects requests with 503 and a Retry-After header
+// while monitor reports that the event pipeline or the database cannot keep
+// up. It guards the routes that create work, such as POST /orders.
+func BackpressureMiddleware(monitor *backpressure.Monitor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if reason, overloaded := monitor.Check(); overloaded {
+			log.Ctx(c.Request.Context()).Debug().Str("reason", reason).Msg("Rejecting request: backpressure")
+			shed(c, reason, monitor.RetryAfter())
+			return
+		}
+		c.Next()
+	}
+}
+
+// shed rejects the request with 503 and a Retry-After header.
+func shed(c *gin.Context, reason string, retryAfter time.Duration) {
+	metrics.HTTPRequestsShedTotal.WithLabelValues(reason).Inc()
+	serviceUnavailable(c, retryAfter)
+}
+
+// serviceUnavailable answers 503 asking the client to retry after
+// retryAfter.
+func serviceUnavailable(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, newErrorResponse(CodeServiceUnavailable, "Service temporarily unavailable, please retry later"))
+}
+
+// retryAfterSeconds rounds d up to the whole seconds of a Retry-After header.
+func retryAfterSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
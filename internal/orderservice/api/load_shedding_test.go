@@ -0,0 +1,142 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/api"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/backpressure"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSheddingMiddleware_OpenBreaker(t *testing.T) {
+	breaker := circuitbreaker.New("database", circuitbreaker.Config{FailureThreshold: 1, OpenTimeout: 90 * time.Second})
+	router := gin.New()
+	router.Use(api.LoadSheddingMiddleware(api.LoadSheddingConfig{}, breaker))
+	served := 0
+	router.GET("/orders", func(c *gin.Context) {
+		served++
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	require.NoError(t, breaker.Allow())
+	breaker.Done(errors.New("connection refused"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "90", w.Header().Get("Retry-After"))
+	assert.Equal(t, 1, served)
+}
+
+func TestLoadSheddingMiddleware_Queue(t *testing.T) {
+	cfg := api.LoadSheddingConfig{MaxInFlight: 1, MaxQueue: 1, QueueTimeout: 200 * time.Millisecond, RetryAfter: 2 * time.Second}
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	router := gin.New()
+	router.Use(api.LoadSheddingMiddleware(cfg))
+	router.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fast", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// Occupy the only slot.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serve("/slow")
+	}()
+	<-started
+
+	t.Run("queued requests time out", func(t *testing.T) {
+		w := serve("/fast")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("requests beyond the queue are rejected at once", func(t *testing.T) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve("/slow") // waits in the queue
+		}()
+		require.Eventually(t, func() bool {
+			start := time.Now()
+			w := serve("/fast")
+			return w.Code == http.StatusServiceUnavailable && time.Since(start) < cfg.QueueTimeout
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, http.StatusOK, serve("/fast").Code)
+}
+
+// unavailableService fails every lookup as if the database breaker were open.
+type unavailableService struct {
+	service.OrderService
+}
+
+func (unavailableService) GetOrderByID(context.Context, uuid.UUID) (*domain.Order, error) {
+	return nil, &circuitbreaker.OpenError{Name: "database", RetryAfter: 1500 * time.Millisecond}
+}
+
+func TestHandler_OpenBreakerIsServiceUnavailable(t *testing.T) {
+	router := gin.New()
+	router.GET("/orders/:id", api.NewHandler(unavailableService{}).GetOrderByID)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/"+uuid.NewString(), nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+}
+
+type fixedBacklog int
+
+func (b fixedBacklog) Backlog() (int, time.Duration) { return int(b), 0 }
+
+func TestBackpressureMiddleware(t *testing.T) {
+	newRouter := func(backlog int) *gin.Engine {
+		monitor := backpressure.NewMonitor(backpressure.Config{MaxOutboxBacklog: 10, RetryAfter: 5 * time.Second}, fixedBacklog(backlog))
+		router := gin.New()
+		router.POST("/orders", api.BackpressureMiddleware(monitor), func(c *gin.Context) {
+			c.Status(http.StatusCreated)
+		})
+		return router
+	}
+
+	w := httptest.NewRecorder()
+	newRouter(10).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = httptest.NewRecorder()
+	newRouter(11).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LocalizationMiddleware translates the message of error responses into the
+// language the client prefers in Accept-Language, when errorMessages has it,
+// so storefronts can show API errors to their users. The code and details
+// are left as they are; English clients, and clients asking for a language
+// without a catalog, get the original message.
+func LocalizationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Language")
+		lang := negotiateLanguage(c.GetHeader("Accept-Language"))
+		if lang == "" {
+			c.Next()
+			return
+		}
+
+		w := &localizeWriter{ResponseWriter: c.Writer, messages: errorMessages[lang], lang: lang}
+		c.Writer = w
+		defer func() {
+			w.finish()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// negotiateLanguage returns the language of errorMessages the client prefers
+// in an Accept-Language header, or "" for English or no supported language.
+// Regional variants such as de-AT match their base language.
+func negotiateLanguage(header string) string {
+	best, bestWeight := "", 0.0
+	for part := range strings.SplitSeq(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if _, ok := errorMessages[base]; !ok && base != "en" {
+			continue
+		}
+		if weight > bestWeight {
+			best, bestWeight = base, weight
+		}
+	}
+	if best == "en" {
+		return ""
+	}
+	return best
+}
+
+// localizeWriter holds back JSON error responses so their message can be
+// translated once complete. Other responses pass through.
+type localizeWriter struct {
+	gin.ResponseWriter
+	messages map[string]string
+	lang     string
+
+	decided   bool
+	buffering bool
+	buf       bytes.Buffer
+}
+
+func (w *localizeWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.buffering = w.ResponseWriter.Status() >= http.StatusBadRequest &&
+			strings.HasPrefix(w.ResponseWriter.Header().Get("Content-Type"), "application/json")
+	}
+	if w.buffering {
+		return w.buf.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *localizeWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// finish writes the held back error response with its message translated.
+func (w *localizeWriter) finish() {
+	if !w.buffering {
+		return
+	}
+	body := w.buf.Bytes()
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(body, &resp); err == nil {
+		var code string
+		_ = json.Unmarshal(resp["code"], &code)
+		if message, ok := w.messages[code]; ok {
+			resp["message"], _ = json.Marshal(message)
+			if translated, err := json.Marshal(resp); err == nil {
+				body = translated
+				w.ResponseWriter.Header().Set("Content-Language", w.lang)
+			}
+		}
+	}
+	_, _ = w.ResponseWriter.Write(body)
+}
+
+// errorMessages translates the message of each error code, by language.
+var errorMessages = map[string]map[string]string{
+	"de": {
+		CodeMalformedRequest:         "Die Anfrage konnte nicht gelesen werden.",
+		CodeUnknownField:             "Die Anfrage enthält ein unbekanntes Feld.",
+		CodeValidationFailed:         "Einige Felder sind ungültig.",
+		CodePayloadTooLarge:          "Die Anfrage ist zu groß.",
+		CodeInvalidOrderID:           "Die Bestell-ID ist ungültig.",
+		CodeInvalidQuery:             "Die Abfrageparameter sind ungültig.",
+		CodeUnknownStatus:            "Unbekannter Bestellstatus.",
+		CodeOrderItemsRequired:       "Eine Bestellung muss mindestens einen Artikel enthalten.",
+		CodeItemQuantityInvalid:      "Die Menge jedes Artikels muss größer als 0 sein.",
+		CodeItemPriceInvalid:         "Der Preis jedes Artikels muss größer als 0 sein.",
+		CodeExternalReferenceInvalid: "Die externe Referenz ist ungültig.",
+		CodeUnauthorized:             "Authentifizierung erforderlich.",
+		CodeOrderNotFound:            "Bestellung nicht gefunden.",
+		CodeCustomerNotFound:         "Kunde nicht gefunden.",
+		CodeProductsNotSellable:      "Einige Produkte können nicht verkauft werden.",
+		CodeOutOfStock:               "Einige Produkte sind nicht vorrätig.",
+		CodeAddressInvalid:           "Die Lieferadresse ist ungültig oder nicht zustellbar.",
+		CodeDuplicateOrder:           "Diese Bestellung wurde gerade bereits aufgegeben.",
+		CodeExternalReferenceExists:  "Für diese externe Referenz existiert bereits eine Bestellung.",
+		CodeInvalidStatusTransition:  "Der Status der Bestellung kann nicht auf diese Weise geändert werden.",
+		CodeOrderLocked:              "Die Bestellung wird gerade geändert. Bitte versuchen Sie es erneut.",
+		CodeQuotaExceeded:            "Zu viele Bestellungen. Bitte versuchen Sie es später erneut.",
+		CodeInternal:                 "Ein interner Fehler ist aufgetreten.",
+		CodeServiceUnavailable:       "Der Dienst ist vorübergehend nicht verfügbar. Bitte versuchen Sie es später erneut.",
+		CodeDatabaseTimeout:          "Die Anfrage hat zu lange gedauert. Bitte versuchen Sie es erneut.",
+		CodePublishTimeout:           "Die Anfrage hat zu lange gedauert. Bitte versuchen Sie es erneut.",
+	},
+	"es": {
+		CodeMalformedRequest:         "No se pudo leer la solicitud.",
+		CodeUnknownField:             "La solicitud contiene un campo desconocido.",
+		CodeValidationFailed:         "Algunos campos no son válidos.",
+		CodePayloadTooLarge:          "La solicitud es demasiado grande.",
+		CodeInvalidOrderID:           "El identificador del pedido no es válido.",
+		CodeInvalidQuery:             "Los parámetros de la consulta no son válidos.",
+		CodeUnknownStatus:            "Estado de pedido desconocido.",
+		CodeOrderItemsRequired:       "Un pedido debe contener al menos un artículo.",
+		CodeItemQuantityInvalid:      "La cantidad de cada artículo debe ser mayor que 0.",
+		CodeItemPriceInvalid:         "El precio de cada artículo debe ser mayor que 0.",
+		CodeExternalReferenceInvalid: "La referencia externa no es válida.",
+		CodeUnauthorized:             "Se requiere autenticación.",
+		CodeOrderNotFound:            "Pedido no encontrado.",
+		CodeCustomerNotFound:         "Cliente no encontrado.",
+		CodeProductsNotSellable:      "Algunos productos no se pueden vender.",
+		CodeOutOfStock:               "Algunos productos están agotados.",
+		CodeAddressInvalid:           "La dirección de envío no es válida o no admite entregas.",
+		CodeDuplicateOrder:           "Este pedido ya se acaba de realizar.",
+		CodeExternalReferenceExists:  "Ya existe un pedido con esta referencia externa.",
+		CodeInvalidStatusTransition:  "El estado del pedido no se puede cambiar de esta forma.",
+		CodeOrderLocked:              "El pedido se está modificando. Vuelva a intentarlo.",
+		CodeQuotaExceeded:            "Demasiados pedidos. Vuelva a intentarlo más tarde.",
+		CodeInternal:                 "Se ha producido un error interno.",
+		CodeServiceUnavailable:       "El servicio no está disponible temporalmente. Vuelva a intentarlo más tarde.",
+		CodeDatabaseTimeout:          "La solicitud ha tardado demasiado. Vuelva a intentarlo.",
+		CodePublishTimeout:           "La solicitud ha tardado demasiado. Vuelva a intentarlo.",
+	},
+	"fr": {
+		CodeMalformedRequest:         "La requête n'a pas pu être lue.",
+		CodeUnknownField:             "La requête contient un champ inconnu.",
+		CodeValidationFailed:         "Certains champs ne sont pas valides.",
+		CodePayloadTooLarge:          "La requête est trop volumineuse.",
+		CodeInvalidOrderID:           "L'identifiant de commande n'est pas valide.",
+		CodeInvalidQuery:             "Les paramètres de la requête ne sont pas valides.",
+		CodeUnknownStatus:            "Statut de commande inconnu.",
+		CodeOrderItemsRequired:       "Une commande doit contenir au moins un article.",
+		CodeItemQuantityInvalid:      "La quantité de chaque article doit être supérieure à 0.",
+		CodeItemPriceInvalid:         "Le prix de chaque article doit être supérieur à 0.",
+		CodeExternalReferenceInvalid: "La référence externe n'est pas valide.",
+		CodeUnauthorized:             "Authentification requise.",
+		CodeOrderNotFound:            "Commande introuvable.",
+		CodeCustomerNotFound:         "Client introuvable.",
+		CodeProductsNotSellable:      "Certains produits ne peuvent pas être vendus.",
+		CodeOutOfStock:               "Certains produits sont en rupture de stock.",
+		CodeAddressInvalid:           "L'adresse de livraison n'est pas valide ou ne peut pas être desservie.",
+		CodeDuplicateOrder:           "Cette commande vient déjà d'être passée.",
+		CodeExternalReferenceExists:  "Une commande existe déjà pour cette référence externe.",
+		CodeInvalidStatusTransition:  "Le statut de la commande ne peut pas être modifié de cette façon.",
+		CodeOrderLocked:              "La commande est en cours de modification. Veuillez réessayer.",
+		CodeQuotaExceeded:            "Trop de commandes. Veuillez réessayer plus tard.",
+		CodeInternal:                 "Une erreur interne s'est produite.",
+		CodeServiceUnavailable:       "Le service est temporairement indisponible. Veuillez réessayer plus tard.",
+		CodeDatabaseTimeout:          "La requête a pris trop de temps. Veuillez réessayer.",
+		CodePublishTimeout:           "La requête a pris trop de temps. Veuillez réessayer.",
+	},
+}
@@ -0,0 +1,80 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalizationMiddleware(t *testing.T) {
+	router := gin.New()
+	router.Use(api.CompressionMiddleware(api.CompressionConfig{Enabled: true, MinSize: 1024, Level: -1}))
+	router.Use(api.LocalizationMiddleware())
+	router.POST("/orders", api.NewHandler(overQuotaService{}).CreateOrder)
+	router.GET("/ok", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "hello"})
+	})
+	serve := func(method, target, body, acceptLanguage string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	invalid := `{"items":[]}`
+
+	tests := []struct {
+		acceptLanguage string
+		language       string
+		message        string
+	}{
+		{"", "", "customer_id is required; items must contain at least 1 element(s)"},
+		{"de-DE,de;q=0.9,en;q=0.8", "de", "Einige Felder sind ungültig."},
+		{"fr-CH, en;q=0.5", "fr", "Certains champs ne sont pas valides."},
+		{"ja, es;q=0.7, en;q=0.3", "es", "Algunos campos no son válidos."},
+		{"en-US,en;q=0.9,de;q=0.8", "", "customer_id is required; items must contain at least 1 element(s)"},
+		{"ja", "", "customer_id is required; items must contain at least 1 element(s)"},
+		{"de;q=0", "", "customer_id is required; items must contain at least 1 element(s)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptLanguage, func(t *testing.T) {
+			w := serve(http.MethodPost, "/orders", invalid, tt.acceptLanguage)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, tt.language, w.Header().Get("Content-Language"))
+			assert.Contains(t, w.Header().Values("Vary"), "Accept-Language")
+			assert.JSONEq(t, `{
+				"code": "VALIDATION_FAILED",
+				"message": "`+tt.message+`",
+				"details": [
+					{"field": "customer_id", "constraint": "required", "message": "is required"},
+					{"field": "items", "constraint": "min=1", "message": "must contain at least 1 element(s)"}
+				]
+			}`, w.Body.String())
+		})
+	}
+
+	t.Run("extra fields are kept", func(t *testing.T) {
+		body := `{"customer_id":"` + uuid.NewString() + `","items":[{"product_id":"` + uuid.NewString() + `","quantity":1,"unit_price":9.99}]}`
+		w := serve(http.MethodPost, "/orders", body, "fr")
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.JSONEq(t, `{"code":"QUOTA_EXCEEDED","message":"Trop de commandes. Veuillez réessayer plus tard.","window":"minute","limit":30,"retry_after_seconds":42}`, w.Body.String())
+	})
+
+	t.Run("successful responses are untouched", func(t *testing.T) {
+		w := serve(http.MethodGet, "/ok", "", "de")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Language"))
+		assert.JSONEq(t, `{"message":"hello"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/errorreporting"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// MetricsMiddleware records request count, duration and in-flight requests.
+// Requests are labelled by their route template (e.g. /api/v1/orders/:id)
+// rather than the raw path to keep label cardinality bounded.
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.HTTPRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// CorrelationIDMiddleware reads the X-Correlation-ID request header (or
+// generates one), echoes it in the response and attaches a logger carrying it
+// to the request context so log.Ctx picks it up downstream.
+func CorrelationIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(logging.CorrelationIDHeader)
+		if id == "" {
+			id = logging.NewCorrelationID()
+		}
+		c.Header(logging.CorrelationIDHeader, id)
+
+		ctx := logging.WithCorrelationID(c.Request.Context(), id, map[string]any{
+			"method": c.Request.Method,
+			"path":   c.Request.URL.Path,
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// ErrorReportingMiddleware reports panics and 5xx responses to the configured
+// error reporting backend, tagged with the request method, route, correlation
+// ID and order ID when available. Panics are answered with a 500. 503s are
+// deliberate (shed load, open circuit breakers) and are not reported.
+func ErrorReportingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				errorreporting.CapturePanic(c.Request.Context(), recovered, requestTags(c))
+				log.Ctx(c.Request.Context()).Error().Interface("panic", recovered).Msg("Recovered from panic")
+				c.AbortWithStatusJSON(http.StatusInternalServerError, newErrorResponse(CodeInternal, "Internal server error"))
+			}
+		}()
+
+		c.Next()
+
+		if c.Writer.Status() < http.StatusInternalServerError || c.Writer.Status() == http.StatusServiceUnavailable {
+			return
+		}
+		err := c.Errors.Last()
+		if err == nil {
+			errorreporting.CaptureError(c.Request.Context(), fmt.Errorf("%s %s returned %d", c.Request.Method, c.FullPath(), c.Writer.Status()), requestTags(c))
+			return
+		}
+		errorreporting.CaptureError(c.Request.Context(), err.Err, requestTags(c))
+	}
+}
+
+// requestTags collects the request attributes attached to error reports.
+func requestTags(c *gin.Context) map[string]string {
+	tags := map[string]string{
+		"http.method": c.Request.Method,
+		"http.route":  c.FullPath(),
+		"http.status": strconv.Itoa(c.Writer.Status()),
+	}
+	if id := logging.CorrelationID(c.Request.Context()); id != "" {
+		tags[logging.CorrelationIDField] = id
+	}
+	if orderID := c.Param("id"); orderID != "" {
+		tags["order_id"] = orderID
+	}
+	return tags
+}
+
+// AdminAuthMiddleware only lets through requests carrying
+// "Authorization: Bearer <adminToken>". Every request is refused when
+// adminToken is empty.
+func AdminAuthMiddleware(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, newErrorResponse(CodeUnauthorized, "unauthorized"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,95 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestCorrelationIDMiddleware(t *testing.T) {
+	router := gin.New()
+	router.Use(api.CorrelationIDMiddleware())
+	var seen string
+	router.GET("/ping", func(c *gin.Context) {
+		seen = logging.CorrelationID(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	t.Run("propagates incoming ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set(logging.CorrelationIDHeader, "req-123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "req-123", w.Header().Get(logging.CorrelationIDHeader))
+		assert.Equal(t, "req-123", seen)
+	})
+
+	t.Run("generates ID when missing", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.NotEmpty(t, w.Header().Get(logging.CorrelationIDHeader))
+		assert.Equal(t, w.Header().Get(logging.CorrelationIDHeader), seen)
+	})
+}
+
+func TestErrorReportingMiddleware_RecoversPanics(t *testing.T) {
+	router := gin.New()
+	router.Use(api.ErrorReportingMiddleware())
+	router.GET("/boom", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	newRouter := func(token string) *gin.Engine {
+		router := gin.New()
+		router.Use(api.AdminAuthMiddleware(token))
+		router.POST("/admin", func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+		return router
+	}
+	request := func(authorization string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/admin", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name          string
+		adminToken    string
+		authorization string
+		want          int
+	}{
+		{name: "valid token", adminToken: "secret", authorization: "Bearer secret", want: http.StatusNoContent},
+		{name: "wrong token", adminToken: "secret", authorization: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "missing token", adminToken: "secret", want: http.StatusUnauthorized},
+		{name: "admin endpoints disabled", adminToken: "", authorization: "Bearer ", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newRouter(tt.adminToken).ServeHTTP(w, request(tt.authorization))
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// renderOrder writes an order response as XML to clients that accept
+// application/xml or text/xml but not JSON, such as older ERP systems, and as
+// JSON otherwise. Error responses are always JSON.
+func renderOrder(c *gin.Context, code int, data any) {
+	vary(c, "Accept")
+	switch c.NegotiateFormat(gin.MIMEJSON, gin.MIMEXML, gin.MIMEXML2) {
+	case gin.MIMEXML, gin.MIMEXML2:
+		c.XML(code, data)
+	default:
+		c.JSON(code, data)
+	}
+}
+
+// vary adds headers to the Vary header of the response, except those it
+// already lists.
+func vary(c *gin.Context, headers ...string) {
+	listed := map[string]bool{}
+	for _, value := range c.Writer.Header().Values("Vary") {
+		for _, header := range strings.Split(value, ",") {
+			listed[strings.ToLower(strings.TrimSpace(header))] = true
+		}
+	}
+	for _, header := range headers {
+		if !listed[strings.ToLower(header)] {
+			c.Writer.Header().Add("Vary", header)
+			listed[strings.ToLower(header)] = true
+		}
+	}
+}
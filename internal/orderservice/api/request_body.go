@@ -0,0 +1,310 @@
+package api
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// BodyLimitMiddleware fails reading request bodies larger than maxBytes, so
+// oversized payloads are rejected without being buffered. Zero disables the
+// limit.
+func BodyLimitMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes > 0 && c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
+
+// validate checks the binding tags of request payloads. Fields are named
+// after their JSON keys so errors point at what the client sent.
+var validate = func() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(jsonFieldName)
+	return v
+}()
+
+// jsonFieldName returns the JSON key of f, or "" if f is not encoded.
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
+
+// bindJSON decodes the request body into req, which must hold a single JSON
+// value without fields req does not have, and validates it. On failure it
+// answers 413 for an oversized body and 400 detailing the offending fields
+// otherwise.
+func bindJSON(c *gin.Context, req any) bool {
+	// The body is kept to find the values encoding/json rejects without
+	// saying where they are.
+	body, err := io.ReadAll(c.Request.Body)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, newErrorResponse(CodePayloadTooLarge,
+			fmt.Sprintf("Request body exceeds %d bytes", maxBytesErr.Limit)))
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, newErrorResponse(CodeMalformedRequest, "Failed to read request body"))
+		return false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(req)
+	if err == nil {
+		if _, tokenErr := dec.Token(); tokenErr != io.EOF {
+			err = errTrailingData
+		}
+	}
+	if err == nil {
+		err = validate.Struct(req)
+	}
+	if err == nil {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, bindingError(err, body, reflect.TypeOf(req)))
+	return false
+}
+
+var errTrailingData = errors.New("request body must contain a single JSON object")
+
+// bindingError describes why body was rejected as a reqType. Problems with
+// specific fields are listed in the details with the constraint they broke
+// and, where it helps, the value sent.
+func bindingError(err error, body []byte, reqType reflect.Type) ErrorResponse {
+	var (
+		syntaxErr     *json.SyntaxError
+		typeErr       *json.UnmarshalTypeError
+		validationErr validator.ValidationErrors
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return newErrorResponse(CodeMalformedRequest, "Request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return newErrorResponse(CodeMalformedRequest, "Malformed JSON: unexpected end of request body")
+	case errors.Is(err, errTrailingData):
+		return newErrorResponse(CodeMalformedRequest, "Request body must contain a single JSON object")
+	case errors.As(err, &syntaxErr):
+		return newErrorResponse(CodeMalformedRequest, fmt.Sprintf("Malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		issue := "must be " + jsonTypeName(typeErr.Type)
+		if typeErr.Field == "" {
+			return newErrorResponse(CodeMalformedRequest, "Request body "+issue)
+		}
+		detail := ErrorDetail{
+			Field:      jsonPath(typeErr.Field),
+			Constraint: "type",
+			Value:      scalar(lookupValue(body, typeErr.Field)),
+			Message:    issue,
+		}
+		return newErrorResponse(CodeValidationFailed, detail.Field+" "+issue, detail)
+	case errors.As(err, &validationErr):
+		details := make([]ErrorDetail, len(validationErr))
+		msgs := make([]string, len(validationErr))
+		for i, fe := range validationErr {
+			details[i] = fieldErrorDetail(fe)
+			msgs[i] = details[i].Field + " " + details[i].Message
+		}
+		return newErrorResponse(CodeValidationFailed, strings.Join(msgs, "; "), details...)
+	}
+	// encoding/json reports unknown fields, and values rejected by types
+	// such as uuid.UUID, as plain errors without a path.
+	if quoted, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field, _ := strconv.Unquote(quoted)
+		return newErrorResponse(CodeUnknownField, "Unknown field "+quoted,
+			ErrorDetail{Field: field, Constraint: "unknown", Message: "is not a field of this request"})
+	}
+	if detail, ok := locateTextError(body, reqType); ok {
+		return newErrorResponse(CodeValidationFailed, detail.Field+" "+detail.Message, detail)
+	}
+	return newErrorResponse(CodeMalformedRequest, "Invalid request payload: "+err.Error())
+}
+
+// fieldErrorDetail describes the binding rule fe reports, such as "must be
+// greater than 0". The value is left out for missing fields.
+func fieldErrorDetail(fe validator.FieldError) ErrorDetail {
+	_, field, _ := strings.Cut(fe.Namespace(), ".")
+	detail := ErrorDetail{Field: field, Constraint: fe.Tag()}
+	if fe.Param() != "" {
+		detail.Constraint += "=" + fe.Param()
+	}
+	if fe.Tag() != "required" {
+		detail.Value = scalar(fe.Value())
+	}
+
+	switch fe.Tag() {
+	case "required":
+		detail.Message = "is required"
+	case "min":
+		if fe.Kind() == reflect.Slice {
+			detail.Message = fmt.Sprintf("must contain at least %s element(s)", fe.Param())
+		} else {
+			detail.Message = "must be at least " + fe.Param()
+		}
+	case "max":
+		if fe.Kind() == reflect.Slice {
+			detail.Message = fmt.Sprintf("must contain at most %s element(s)", fe.Param())
+		} else {
+			detail.Message = "must be at most " + fe.Param()
+		}
+	case "gt":
+		detail.Message = "must be greater than " + fe.Param()
+	default:
+		detail.Message = fmt.Sprintf("failed the %q rule", fe.Tag())
+	}
+	return detail
+}
+
+// scalar returns v if it is a boolean, number or string, and nil otherwise,
+// so error details never echo whole objects or arrays.
+func scalar(v any) any {
+	if v == nil {
+		return nil
+	}
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return v
+	}
+	return nil
+}
+
+// lookupValue returns the value at field, a path of encoding/json such as
+// items.0.quantity, in body.
+func lookupValue(body []byte, field string) any {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil
+	}
+	for _, key := range strings.Split(field, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// locateTextError finds the string in body that a field of reqType parsing
+// text, such as a uuid.UUID, rejected.
+func locateTextError(body []byte, reqType reflect.Type) (ErrorDetail, bool) {
+	var tree any
+	if err := json.Unmarshal(body, &tree); err != nil {
+		return ErrorDetail{}, false
+	}
+	return findTextError(tree, reqType, "")
+}
+
+func findTextError(v any, t reflect.Type, path string) (ErrorDetail, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if s, ok := v.(string); ok && reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		err := reflect.New(t).Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		if err == nil {
+			return ErrorDetail{}, false
+		}
+		return ErrorDetail{Field: path, Constraint: "format", Value: s, Message: "is invalid: " + err.Error()}, true
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, _ := v.(map[string]any)
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name := jsonFieldName(f)
+			fv, present := obj[name]
+			if !f.IsExported() || name == "" || !present {
+				continue
+			}
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			if detail, ok := findTextError(fv, f.Type, fieldPath); ok {
+				return detail, true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		arr, _ := v.([]any)
+		for i, ev := range arr {
+			if detail, ok := findTextError(ev, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); ok {
+				return detail, true
+			}
+		}
+	}
+	return ErrorDetail{}, false
+}
+
+// jsonPath rewrites a field path of encoding/json, such as items.0.quantity,
+// the way binding errors write it: items[0].quantity.
+func jsonPath(field string) string {
+	parts := strings.Split(field, ".")
+	var b strings.Builder
+	for i, part := range parts {
+		if _, err := strconv.Atoi(part); err == nil && i > 0 {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// jsonTypeName names the JSON type that decodes into t.
+func jsonTypeName(t reflect.Type) string {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return "a string"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.String()
+}
@@ -0,0 +1,140 @@
+// Package backfill regenerates order events from the orders table and
+// republishes them, to bootstrap new consumers or recover from consumer data
+// loss. Regenerated messages carry the header replayed=true.
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/service"
+	"github.com/segmentio/kafka-go"
+)
+
+// OrderStreamer reads orders, oldest first. Both repository.OrderRepository
+// and service.OrderService satisfy it.
+type OrderStreamer interface {
+	StreamOrders(ctx context.Context, filter repository.OrderFilter, fn func(*domain.Order) error) error
+}
+
+// MessageWriter publishes messages; *kafka.Writer satisfies it.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// payloads maps each topic that can be backfilled to the message the order
+// service publishes on it for an order.
+var payloads = map[string]func(*domain.Order) any{
+	events.TopicOrdersPlaced: func(order *domain.Order) any {
+		return service.NewOrderPlacedEvent(order)
+	},
+	events.TopicInventoryCommands: func(order *domain.Order) any {
+		return events.ReserveInventory(service.NewOrderPlacedEvent(order))
+	},
+}
+
+// Topics returns the topics that can be backfilled.
+func Topics() []string {
+	topics := make([]string, 0, len(payloads))
+	for topic := range payloads {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// DefaultBatchSize is the number of messages published per write when
+// Options.BatchSize is zero.
+const DefaultBatchSize = 100
+
+// Options controls a backfill.
+type Options struct {
+	// Topic is the topic to regenerate events for.
+	Topic string
+	// Filter selects the orders, typically by creation time range.
+	Filter repository.OrderFilter
+	// BatchSize is the number of messages published per write.
+	BatchSize int
+	// DryRun counts the matching orders without publishing anything.
+	DryRun bool
+}
+
+// Summary reports what a backfill did.
+type Summary struct {
+	Orders    int
+	Published int
+}
+
+// Backfiller republishes events for existing orders.
+type Backfiller struct {
+	orders OrderStreamer
+	writer MessageWriter
+	opts   Options
+}
+
+// New creates a Backfiller reading from orders and publishing with writer.
+func New(orders OrderStreamer, writer MessageWriter, opts Options) *Backfiller {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	return &Backfiller{orders: orders, writer: writer, opts: opts}
+}
+
+// Run streams the matching orders and publishes one event per order, in
+// order creation order. The correlation ID in ctx, if any, is attached to
+// every message so a backfill can be traced as a whole.
+func (b *Backfiller) Run(ctx context.Context) (Summary, error) {
+	var summary Summary
+	payload, ok := payloads[b.opts.Topic]
+	if !ok {
+		return summary, fmt.Errorf("cannot backfill topic %q: expected one of %s", b.opts.Topic, strings.Join(Topics(), ", "))
+	}
+
+	batch := make([]kafka.Message, 0, b.opts.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if !b.opts.DryRun {
+			if err := b.writer.WriteMessages(ctx, batch...); err != nil {
+				return fmt.Errorf("failed to publish backfilled events: %w", err)
+			}
+			summary.Published += len(batch)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err := b.orders.StreamOrders(ctx, b.opts.Filter, func(order *domain.Order) error {
+		value, err := json.Marshal(payload(order))
+		if err != nil {
+			return fmt.Errorf("failed to marshal event for order %s: %w", order.ID, err)
+		}
+		msg := kafka.Message{
+			Topic:   b.opts.Topic,
+			Key:     []byte(order.ID.String()),
+			Value:   value,
+			Time:    time.Now(),
+			Headers: []kafka.Header{{Key: events.HeaderReplayed, Value: []byte("true")}},
+		}
+		logging.InjectKafkaHeader(ctx, &msg)
+		batch = append(batch, msg)
+		summary.Orders++
+		if len(batch) >= b.opts.BatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+	return summary, flush()
+}
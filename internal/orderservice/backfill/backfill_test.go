@@ -0,0 +1,110 @@
+package backfill_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/backfill"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type orderStream struct {
+	orders []*domain.Order
+	filter repository.OrderFilter
+}
+
+func (s *orderStream) StreamOrders(_ context.Context, filter repository.OrderFilter, fn func(*domain.Order) error) error {
+	s.filter = filter
+	for _, order := range s.orders {
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type recordingWriter struct {
+	writes [][]kafka.Message
+}
+
+func (w *recordingWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.writes = append(w.writes, append([]kafka.Message(nil), msgs...))
+	return nil
+}
+
+func newOrders(n int) []*domain.Order {
+	orders := make([]*domain.Order, n)
+	for i := range orders {
+		orders[i] = &domain.Order{
+			ID:         uuid.New(),
+			CustomerID: uuid.New(),
+			TotalPrice: 20,
+			Status:     domain.OrderStatusCompleted,
+			CreatedAt:  time.Date(2025, 3, 1, i, 0, 0, 0, time.UTC),
+			Items:      []domain.OrderItem{{ProductID: uuid.New(), Quantity: 2, UnitPrice: 10}},
+		}
+	}
+	return orders
+}
+
+func TestBackfiller_Run(t *testing.T) {
+	stream := &orderStream{orders: newOrders(5)}
+	writer := &recordingWriter{}
+	filter := repository.OrderFilter{CreatedFrom: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)}
+	ctx := logging.WithCorrelationID(context.Background(), "backfill-1", nil)
+
+	summary, err := backfill.New(stream, writer, backfill.Options{
+		Topic:     events.TopicOrdersPlaced,
+		Filter:    filter,
+		BatchSize: 2,
+	}).Run(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, backfill.Summary{Orders: 5, Published: 5}, summary)
+	assert.Equal(t, filter, stream.filter)
+	require.Len(t, writer.writes, 3, "5 messages in batches of 2")
+
+	var published []kafka.Message
+	for _, batch := range writer.writes {
+		published = append(published, batch...)
+	}
+	for i, msg := range published {
+		order := stream.orders[i]
+		assert.Equal(t, events.TopicOrdersPlaced, msg.Topic)
+		assert.Equal(t, order.ID.String(), string(msg.Key))
+		assert.Contains(t, msg.Headers, kafka.Header{Key: events.HeaderReplayed, Value: []byte("true")})
+		assert.Contains(t, msg.Headers, kafka.Header{Key: logging.CorrelationIDKafkaHeader, Value: []byte("backfill-1")})
+
+		var event events.OrderPlaced
+		require.NoError(t, json.Unmarshal(msg.Value, &event))
+		assert.Equal(t, order.ID, event.OrderID)
+		assert.Equal(t, order.CreatedAt, event.Timestamp)
+		assert.Len(t, event.Items, 1)
+	}
+}
+
+func TestBackfiller_Run_DryRun(t *testing.T) {
+	writer := &recordingWriter{}
+	summary, err := backfill.New(&orderStream{orders: newOrders(3)}, writer, backfill.Options{
+		Topic:  events.TopicInventoryCommands,
+		DryRun: true,
+	}).Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, backfill.Summary{Orders: 3}, summary)
+	assert.Empty(t, writer.writes)
+}
+
+func TestBackfiller_Run_UnknownTopic(t *testing.T) {
+	_, err := backfill.New(&orderStream{}, &recordingWriter{}, backfill.Options{Topic: events.TopicInventoryEvents}).Run(context.Background())
+	assert.ErrorContains(t, err, "cannot backfill topic")
+}
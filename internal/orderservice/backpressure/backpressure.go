@@ -0,0 +1,111 @@
+// Package backpressure decides when the order service should stop accepting
+// new orders because the rest of the pipeline cannot keep up: events pile up
+// in the outbox faster than the relay publishes them, or the database slows
+// down. Rejecting orders early, with a Retry-After, keeps the backlog from
+// growing without bound.
+package backpressure
+
+import (
+	"sync"
+	"time"
+)
+
+// Config holds the thresholds beyond which new orders are rejected. A zero
+// threshold is not checked.
+type Config struct {
+	Enabled bool `key:"enabled" env:"BACKPRESSURE_ENABLED" default:"true"`
+	// MaxOutboxBacklog is the number of unpublished outbox messages.
+	MaxOutboxBacklog int `key:"max_outbox_backlog" env:"BACKPRESSURE_MAX_OUTBOX_BACKLOG" default:"10000"`
+	// MaxOutboxAge is the age of the oldest unpublished outbox message.
+	MaxOutboxAge time.Duration `key:"max_outbox_age" env:"BACKPRESSURE_MAX_OUTBOX_AGE" default:"2m"`
+	// MaxDBLatency is the moving average duration of database statements.
+	MaxDBLatency time.Duration `key:"max_db_latency" env:"BACKPRESSURE_MAX_DB_LATENCY" default:"500ms"`
+	// RetryAfter is the Retry-After sent with rejected orders.
+	RetryAfter time.Duration `key:"retry_after" env:"BACKPRESSURE_RETRY_AFTER" default:"5s"`
+}
+
+// Reasons reported by Monitor.Check.
+const (
+	ReasonOutboxBacklog = "outbox_backlog"
+	ReasonOutboxAge     = "outbox_age"
+	ReasonDBLatency     = "db_latency"
+)
+
+// latencyWeight is the weight of each new observation in the moving average.
+const latencyWeight = 0.2
+
+// latencyWindow is how long a latency average stays valid without new
+// observations. Rejected orders make no queries, so without it a slow period
+// could keep orders out after the database has recovered.
+const latencyWindow = 10 * time.Second
+
+// OutboxBacklog reports the outbox backlog; *outbox.Relay implements it.
+type OutboxBacklog interface {
+	Backlog() (count int, oldest time.Duration)
+}
+
+// Monitor tracks the signals that trigger backpressure. It is safe for
+// concurrent use.
+type Monitor struct {
+	cfg    Config
+	outbox OutboxBacklog
+	now    func() time.Time
+
+	mu             sync.Mutex
+	dbLatency      float64 // exponential moving average, in seconds
+	dbLatencyAt    time.Time
+	dbLatencyValid bool
+}
+
+// NewMonitor creates a Monitor. outbox may be nil when events are not
+// published through the outbox.
+func NewMonitor(cfg Config, outbox OutboxBacklog) *Monitor {
+	return &Monitor{cfg: cfg, outbox: outbox, now: time.Now}
+}
+
+// ObserveDBLatency records the duration of a database statement.
+func (m *Monitor) ObserveDBLatency(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if !m.dbLatencyValid || now.Sub(m.dbLatencyAt) > latencyWindow {
+		m.dbLatency = d.Seconds()
+	} else {
+		m.dbLatency += latencyWeight * (d.Seconds() - m.dbLatency)
+	}
+	m.dbLatencyAt = now
+	m.dbLatencyValid = true
+}
+
+// DBLatency returns the moving average database latency, or zero if no
+// statement ran recently.
+func (m *Monitor) DBLatency() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.dbLatencyValid || m.now().Sub(m.dbLatencyAt) > latencyWindow {
+		return 0
+	}
+	return time.Duration(m.dbLatency * float64(time.Second))
+}
+
+// Check reports whether new orders should be rejected, and why.
+func (m *Monitor) Check() (reason string, overloaded bool) {
+	if m.outbox != nil {
+		count, oldest := m.outbox.Backlog()
+		if m.cfg.MaxOutboxBacklog > 0 && count > m.cfg.MaxOutboxBacklog {
+			return ReasonOutboxBacklog, true
+		}
+		if m.cfg.MaxOutboxAge > 0 && oldest > m.cfg.MaxOutboxAge {
+			return ReasonOutboxAge, true
+		}
+	}
+	if m.cfg.MaxDBLatency > 0 && m.DBLatency() > m.cfg.MaxDBLatency {
+		return ReasonDBLatency, true
+	}
+	return "", false
+}
+
+// RetryAfter returns how long rejected clients should wait before retrying.
+func (m *Monitor) RetryAfter() time.Duration {
+	return m.cfg.RetryAfter
+}
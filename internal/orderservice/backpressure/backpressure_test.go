@@ -0,0 +1,72 @@
+package backpressure
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedBacklog struct {
+	count  int
+	oldest time.Duration
+}
+
+func (b *fixedBacklog) Backlog() (int, time.Duration) { return b.count, b.oldest }
+
+func TestMonitor_Check(t *testing.T) {
+	cfg := Config{MaxOutboxBacklog: 100, MaxOutboxAge: time.Minute, MaxDBLatency: 200 * time.Millisecond, RetryAfter: 5 * time.Second}
+
+	tests := []struct {
+		name       string
+		backlog    fixedBacklog
+		dbLatency  time.Duration
+		wantReason string
+	}{
+		{name: "healthy", backlog: fixedBacklog{count: 100, oldest: time.Minute}, dbLatency: 200 * time.Millisecond},
+		{name: "outbox backlog", backlog: fixedBacklog{count: 101}, wantReason: ReasonOutboxBacklog},
+		{name: "outbox age", backlog: fixedBacklog{count: 1, oldest: 2 * time.Minute}, wantReason: ReasonOutboxAge},
+		{name: "slow database", dbLatency: time.Second, wantReason: ReasonDBLatency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMonitor(cfg, &tt.backlog)
+			if tt.dbLatency > 0 {
+				m.ObserveDBLatency(tt.dbLatency)
+			}
+
+			reason, overloaded := m.Check()
+
+			assert.Equal(t, tt.wantReason, reason)
+			assert.Equal(t, tt.wantReason != "", overloaded)
+		})
+	}
+}
+
+func TestMonitor_DBLatency(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMonitor(Config{MaxDBLatency: 200 * time.Millisecond}, nil)
+	m.now = func() time.Time { return now }
+
+	m.ObserveDBLatency(time.Second)
+	assert.Equal(t, time.Second, m.DBLatency())
+
+	// Fast statements pull the average down gradually.
+	for range 10 {
+		m.ObserveDBLatency(10 * time.Millisecond)
+	}
+	assert.Less(t, m.DBLatency(), 200*time.Millisecond)
+	_, overloaded := m.Check()
+	assert.False(t, overloaded)
+
+	// A stale average is forgotten, so rejecting every order cannot keep
+	// the service rejecting after the database recovers.
+	m.ObserveDBLatency(5 * time.Second)
+	m.ObserveDBLatency(5 * time.Second)
+	_, overloaded = m.Check()
+	assert.True(t, overloaded)
+	now = now.Add(latencyWindow + time.Second)
+	assert.Zero(t, m.DBLatency())
+	_, overloaded = m.Check()
+	assert.False(t, overloaded)
+}
@@ -0,0 +1,122 @@
+// Package cache keeps recently read orders in memory so repeated lookups of
+// the same order skip the database.
+//
+// The cache is local to each order service instance. The service updates it
+// on every order mutation it performs, but changes made by other instances
+// are only seen once the entry expires, so TTL bounds how stale a read can
+// be.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+)
+
+// Config configures the order cache.
+type Config struct {
+	Enabled bool `key:"enabled" env:"ORDER_CACHE_ENABLED" default:"false"`
+	// Size is the maximum number of orders kept; the least recently used
+	// are evicted first.
+	Size int `key:"size" env:"ORDER_CACHE_SIZE" default:"10000"`
+	// TTL is how long an entry is served from the cache.
+	TTL time.Duration `key:"ttl" env:"ORDER_CACHE_TTL" default:"30s"`
+	// StaleTTL is how long past TTL an entry is kept to be served when the
+	// database cannot be reached. Zero never serves stale entries.
+	StaleTTL time.Duration `key:"stale_ttl" env:"ORDER_CACHE_STALE_TTL" default:"5m"`
+}
+
+type entry struct {
+	order    *domain.Order
+	storedAt time.Time
+}
+
+// OrderCache is an LRU cache of orders with a TTL. It is safe for concurrent
+// use. Orders are copied in and out, so callers may modify what they get.
+type OrderCache struct {
+	cfg Config
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]*list.Element
+	lru     *list.List // of *entry, most recently used first
+}
+
+// New creates an empty OrderCache.
+func New(cfg Config) *OrderCache {
+	if cfg.Size < 1 {
+		cfg.Size = 1
+	}
+	return &OrderCache{
+		cfg:     cfg,
+		now:     time.Now,
+		entries: make(map[uuid.UUID]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Get returns the cached order with id. fresh is false for entries past their
+// TTL, which should only be served if the order cannot be loaded.
+func (c *OrderCache) Get(id uuid.UUID) (order *domain.Order, fresh, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil, false, false
+	}
+	e := elem.Value.(*entry)
+	age := c.now().Sub(e.storedAt)
+	if age >= c.cfg.TTL+c.cfg.StaleTTL {
+		c.remove(elem)
+		return nil, false, false
+	}
+	c.lru.MoveToFront(elem)
+	return clone(e.order), age < c.cfg.TTL, true
+}
+
+// Set stores order, replacing any entry for the same ID.
+func (c *OrderCache) Set(order *domain.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &entry{order: clone(order), storedAt: c.now()}
+	if elem, ok := c.entries[order.ID]; ok {
+		elem.Value = e
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[order.ID] = c.lru.PushFront(e)
+	for c.lru.Len() > c.cfg.Size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Invalidate removes the entry for id, if any.
+func (c *OrderCache) Invalidate(id uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[id]; ok {
+		c.remove(elem)
+	}
+}
+
+// Len returns the number of entries, including stale ones.
+func (c *OrderCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *OrderCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).order.ID)
+}
+
+func clone(order *domain.Order) *domain.Order {
+	cp := *order
+	cp.Items = append([]domain.OrderItem(nil), order.Items...)
+	cp.DuplicateOf = uuid.Nil // only reported when the order is created
+	return &cp
+}
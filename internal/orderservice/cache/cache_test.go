@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOrder() *domain.Order {
+	return &domain.Order{
+		ID:     uuid.New(),
+		Status: domain.OrderStatusPending,
+		Items:  []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 10}},
+	}
+}
+
+func TestOrderCache_Expiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New(Config{Size: 10, TTL: time.Minute, StaleTTL: time.Hour})
+	c.now = func() time.Time { return now }
+	order := newOrder()
+	c.Set(order)
+
+	got, fresh, ok := c.Get(order.ID)
+	require.True(t, ok)
+	assert.True(t, fresh)
+	assert.Equal(t, order, got)
+
+	now = now.Add(time.Minute)
+	_, fresh, ok = c.Get(order.ID)
+	assert.True(t, ok)
+	assert.False(t, fresh, "entries past their TTL are stale")
+
+	now = now.Add(time.Hour)
+	_, _, ok = c.Get(order.ID)
+	assert.False(t, ok, "entries past the stale TTL are dropped")
+	assert.Zero(t, c.Len())
+}
+
+func TestOrderCache_Invalidate(t *testing.T) {
+	c := New(Config{Size: 10, TTL: time.Minute})
+	order := newOrder()
+	c.Set(order)
+
+	c.Invalidate(order.ID)
+
+	_, _, ok := c.Get(order.ID)
+	assert.False(t, ok)
+}
+
+func TestOrderCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(Config{Size: 2, TTL: time.Minute})
+	first, second, third := newOrder(), newOrder(), newOrder()
+	c.Set(first)
+	c.Set(second)
+	c.Get(first.ID)
+	c.Set(third)
+
+	_, _, ok := c.Get(second.ID)
+	assert.False(t, ok)
+	_, _, ok = c.Get(first.ID)
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestOrderCache_CopiesOrders(t *testing.T) {
+	c := New(Config{Size: 10, TTL: time.Minute})
+	order := newOrder()
+	c.Set(order)
+	order.Status = domain.OrderStatusCancelled
+	order.Items[0].Quantity = 5
+
+	got, _, _ := c.Get(order.ID)
+	assert.Equal(t, domain.OrderStatusPending, got.Status)
+	assert.Equal(t, 1, got.Items[0].Quantity)
+
+	got.Status = domain.OrderStatusCompleted
+	again, _, _ := c.Get(order.ID)
+	assert.Equal(t, domain.OrderStatusPending, again.Status)
+}
+
+func TestOrderCache_DropsDuplicateFlag(t *testing.T) {
+	c := New(Config{Size: 10, TTL: time.Minute})
+	order := newOrder()
+	order.DuplicateOf = uuid.New()
+	c.Set(order)
+
+	got, _, _ := c.Get(order.ID)
+	assert.Equal(t, uuid.Nil, got.DuplicateOf, "the flag is not stored, so later reads must not report it")
+}
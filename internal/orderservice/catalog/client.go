@@ -0,0 +1,45 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnavailable is matched by the errors of a catalog service that could
+// not be reached or answered with an error. The order may be retried later.
+var ErrUnavailable = errors.New("catalog service unavailable")
+
+// Client looks up products in the product catalog.
+type Client interface {
+	// UnsellableProducts returns the subset of productIDs that either don't
+	// exist in the catalog or can't currently be sold.
+	UnsellableProducts(ctx context.Context, productIDs []uuid.UUID) ([]uuid.UUID, error)
+}
+
+// StubClient is an in-memory Client used when no catalog service is configured.
+// Every product is sellable unless it was explicitly marked otherwise.
+type StubClient struct {
+	unsellable map[uuid.UUID]struct{}
+}
+
+// NewStubClient creates a StubClient that rejects the given product IDs.
+func NewStubClient(unsellable ...uuid.UUID) *StubClient {
+	s := &StubClient{unsellable: make(map[uuid.UUID]struct{}, len(unsellable))}
+	for _, id := range unsellable {
+		s.unsellable[id] = struct{}{}
+	}
+	return s
+}
+
+// UnsellableProducts returns the product IDs the stub was told to reject.
+func (s *StubClient) UnsellableProducts(_ context.Context, productIDs []uuid.UUID) ([]uuid.UUID, error) {
+	var rejected []uuid.UUID
+	for _, id := range productIDs {
+		if _, ok := s.unsellable[id]; ok {
+			rejected = append(rejected, id)
+		}
+	}
+	return rejected, nil
+}
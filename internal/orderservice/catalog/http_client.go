@@ -0,0 +1,120 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HTTPClient talks to the catalog service's REST API.
+type HTTPClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewHTTPClient creates a new HTTPClient for the catalog service at baseURL.
+func NewHTTPClient(baseURL string, timeout time.Duration) *HTTPClient {
+	return &HTTPClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+type productResponse struct {
+	ID       uuid.UUID `json:"id"`
+	Sellable bool      `json:"sellable"`
+}
+
+// maxConcurrentLookups bounds the product lookups of one order that are in
+// flight at once.
+const maxConcurrentLookups = 8
+
+// UnsellableProducts fetches the products from the catalog, up to
+// maxConcurrentLookups at a time, and returns the ones that are missing or
+// flagged as not sellable, in the order of productIDs. The first failed
+// lookup cancels the others.
+func (c *HTTPClient) UnsellableProducts(ctx context.Context, productIDs []uuid.UUID) ([]uuid.UUID, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sellable := make([]bool, len(productIDs))
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	slots := make(chan struct{}, maxConcurrentLookups)
+	for i, id := range productIDs {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			ok, err := c.isSellable(ctx, id)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			sellable[i] = ok
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var rejected []uuid.UUID
+	for i, id := range productIDs {
+		if !sellable[i] {
+			rejected = append(rejected, id)
+		}
+	}
+	return rejected, nil
+}
+
+func (c *HTTPClient) isSellable(ctx context.Context, productID uuid.UUID) (bool, error) {
+	url := fmt.Sprintf("%s/api/v1/products/%s", c.baseURL, productID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to build catalog request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var product productResponse
+		if err := json.NewDecoder(resp.Body).Decode(&product); err != nil {
+			return false, fmt.Errorf("failed to decode catalog response: %w", err)
+		}
+		return product.Sellable, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: unexpected status %d", ErrUnavailable, resp.StatusCode)
+	}
+}
@@ -0,0 +1,111 @@
+package catalog_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/catalog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient_UnsellableProducts(t *testing.T) {
+	sellable, unsellable, missing := uuid.New(), uuid.New(), uuid.New()
+	products := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/products/")
+		switch id {
+		case sellable.String():
+			fmt.Fprintf(w, `{"id":%q,"sellable":true}`, id)
+		case unsellable.String():
+			fmt.Fprintf(w, `{"id":%q,"sellable":false}`, id)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	t.Run("missing and unsellable products are rejected", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(products))
+		defer server.Close()
+
+		rejected, err := catalog.NewHTTPClient(server.URL+"/", time.Second).
+			UnsellableProducts(context.Background(), []uuid.UUID{missing, sellable, unsellable})
+
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{missing, unsellable}, rejected)
+	})
+
+	t.Run("sellable products are accepted", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(products))
+		defer server.Close()
+
+		rejected, err := catalog.NewHTTPClient(server.URL, time.Second).
+			UnsellableProducts(context.Background(), []uuid.UUID{sellable})
+
+		require.NoError(t, err)
+		assert.Empty(t, rejected)
+	})
+
+	t.Run("products are looked up concurrently", func(t *testing.T) {
+		var mu sync.Mutex
+		var inFlight, most int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			inFlight++
+			most = max(most, inFlight)
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			products(w, r)
+		}))
+		defer server.Close()
+		ids := make([]uuid.UUID, 20)
+		for i := range ids {
+			ids[i] = sellable
+		}
+
+		rejected, err := catalog.NewHTTPClient(server.URL, time.Second).UnsellableProducts(context.Background(), ids)
+
+		require.NoError(t, err)
+		assert.Empty(t, rejected)
+		assert.Greater(t, most, 1)
+		assert.LessOrEqual(t, most, 8)
+	})
+
+	t.Run("service errors are reported as unavailable", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, unsellable.String()) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			products(w, r)
+		}))
+		defer server.Close()
+
+		rejected, err := catalog.NewHTTPClient(server.URL, time.Second).
+			UnsellableProducts(context.Background(), []uuid.UUID{sellable, unsellable, missing})
+
+		assert.ErrorIs(t, err, catalog.ErrUnavailable)
+		assert.ErrorContains(t, err, "unexpected status 503")
+		assert.Nil(t, rejected)
+	})
+
+	t.Run("unreachable service is reported as unavailable", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(products))
+		server.Close()
+
+		_, err := catalog.NewHTTPClient(server.URL, time.Second).
+			UnsellableProducts(context.Background(), []uuid.UUID{sellable})
+
+		assert.ErrorIs(t, err, catalog.ErrUnavailable)
+	})
+}
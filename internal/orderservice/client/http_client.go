@@ -0,0 +1,223 @@
+// Package client is a Go client for the order service's REST API, used by
+// operator tooling.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/api"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/export"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+)
+
+// APIError is returned when the order service answers with a non-2xx status.
+// Code is the error code of the response, such as api.CodeOrderNotFound, or
+// empty if the response carried none.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    []api.ErrorDetail
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("order service returned %d: %s", e.StatusCode, e.Message)
+}
+
+// HTTPClient talks to the order service's REST API.
+type HTTPClient struct {
+	baseURL    string
+	adminToken string
+	httpClient *http.Client
+}
+
+// NewHTTPClient creates a new HTTPClient for the order service at baseURL.
+// adminToken is sent with every request and is required by the operator
+// endpoints.
+func NewHTTPClient(baseURL, adminToken string, timeout time.Duration) *HTTPClient {
+	return &HTTPClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		adminToken: adminToken,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// GetOrder fetches a single order.
+func (c *HTTPClient) GetOrder(ctx context.Context, id uuid.UUID) (*api.OrderResponse, error) {
+	var order api.OrderResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/orders/"+id.String(), nil, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ListOrders fetches the orders matching filter.
+func (c *HTTPClient) ListOrders(ctx context.Context, filter repository.OrderFilter) ([]api.OrderResponse, error) {
+	query := filterQuery(filter)
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		query.Set("offset", strconv.Itoa(filter.Offset))
+	}
+	if len(filter.Sort) > 0 {
+		query.Set("sort", repository.FormatSort(filter.Sort))
+	}
+
+	var page api.OrderListResponse
+	if err := c.do(ctx, http.MethodGet, withQuery("/api/v1/orders", query), nil, &page); err != nil {
+		return nil, err
+	}
+	return page.Orders, nil
+}
+
+// ExportOrders streams the export of the orders matching filter to w.
+func (c *HTTPClient) ExportOrders(ctx context.Context, filter repository.OrderFilter, format export.Format, w io.Writer) error {
+	query := filterQuery(filter)
+	query.Set("format", string(format))
+	req, err := c.newRequest(ctx, http.MethodGet, withQuery("/api/v1/orders/export", query), nil)
+	if err != nil {
+		return err
+	}
+	// Exports can take far longer than an ordinary request; rely on ctx alone.
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call order service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download export: %w", err)
+	}
+	return nil
+}
+
+// UpdateOrderStatus moves an order to status.
+func (c *HTTPClient) UpdateOrderStatus(ctx context.Context, id uuid.UUID, status domain.OrderStatus) (*api.OrderResponse, error) {
+	var order api.OrderResponse
+	body := api.UpdateOrderStatusRequest{Status: string(status)}
+	if err := c.do(ctx, http.MethodPut, "/api/v1/orders/"+id.String()+"/status", body, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// CancelOrder cancels an order.
+func (c *HTTPClient) CancelOrder(ctx context.Context, id uuid.UUID) (*api.OrderResponse, error) {
+	var order api.OrderResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/orders/"+id.String()+"/cancel", nil, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ResendOrderPlaced republishes the order's OrderPlaced event.
+func (c *HTTPClient) ResendOrderPlaced(ctx context.Context, id uuid.UUID) (*api.OrderResponse, error) {
+	var order api.OrderResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/orders/"+id.String()+"/resend-event", nil, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// do sends a JSON request and decodes a successful response into out.
+func (c *HTTPClient) do(ctx context.Context, method, path string, body, out any) error {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call order service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode order service response: %w", err)
+	}
+	return nil
+}
+
+// newRequest builds a request carrying body as JSON and the admin token.
+func (c *HTTPClient) newRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	var reqBody io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build order service request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
+	return req, nil
+}
+
+// checkResponse turns a non-2xx response into an *APIError.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	var errResp api.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Message == "" {
+		errResp.Message = http.StatusText(resp.StatusCode)
+	}
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Code:       errResp.Code,
+		Message:    errResp.Message,
+		Details:    errResp.Details,
+	}
+}
+
+// filterQuery encodes the filter fields shared by list and export.
+func filterQuery(filter repository.OrderFilter) url.Values {
+	query := url.Values{}
+	if filter.Status != "" {
+		query.Set("status", string(filter.Status))
+	}
+	if filter.CustomerID != uuid.Nil {
+		query.Set("customer_id", filter.CustomerID.String())
+	}
+	if !filter.CreatedFrom.IsZero() {
+		query.Set("created_from", filter.CreatedFrom.Format(time.RFC3339))
+	}
+	if !filter.CreatedTo.IsZero() {
+		query.Set("created_to", filter.CreatedTo.Format(time.RFC3339))
+	}
+	return query
+}
+
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,90 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/api"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/client"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/export"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient(t *testing.T) {
+	orderID := uuid.New()
+	customerID := uuid.New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/orders", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "cancelled", r.URL.Query().Get("status"))
+		assert.Equal(t, customerID.String(), r.URL.Query().Get("customer_id"))
+		assert.Equal(t, "10", r.URL.Query().Get("limit"))
+		_ = json.NewEncoder(w).Encode(api.OrderListResponse{Orders: []api.OrderResponse{{ID: orderID}}, Limit: 10})
+	})
+	mux.HandleFunc("PUT /api/v1/orders/{id}/status", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var req api.UpdateOrderStatusRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		_ = json.NewEncoder(w).Encode(api.OrderResponse{ID: orderID, Status: req.Status})
+	})
+	mux.HandleFunc("POST /api/v1/orders/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(w).Encode(api.ErrorResponse{Code: api.CodeInvalidStatusTransition, Message: "invalid order status transition"})
+	})
+	mux.HandleFunc("GET /api/v1/orders/export", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "jsonl", r.URL.Query().Get("format"))
+		assert.Equal(t, "2024-01-01T00:00:00Z", r.URL.Query().Get("created_from"))
+		_, _ = w.Write([]byte("{}\n{}\n"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := client.NewHTTPClient(server.URL+"/", "secret", time.Second)
+	ctx := context.Background()
+
+	t.Run("list passes the filter as query parameters", func(t *testing.T) {
+		orders, err := c.ListOrders(ctx, repository.OrderFilter{Status: domain.OrderStatusCancelled, CustomerID: customerID, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, orderID, orders[0].ID)
+	})
+
+	t.Run("export streams the response body", func(t *testing.T) {
+		var buf bytes.Buffer
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		err := c.ExportOrders(ctx, repository.OrderFilter{CreatedFrom: from}, export.FormatJSONL, &buf)
+		require.NoError(t, err)
+		assert.Equal(t, "{}\n{}\n", buf.String())
+	})
+
+	t.Run("status update sends the admin token", func(t *testing.T) {
+		order, err := c.UpdateOrderStatus(ctx, orderID, domain.OrderStatusProcessing)
+		require.NoError(t, err)
+		assert.Equal(t, "processing", order.Status)
+	})
+
+	t.Run("error responses become APIErrors", func(t *testing.T) {
+		_, err := c.CancelOrder(ctx, orderID)
+		var apiErr *client.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+		assert.Equal(t, api.CodeInvalidStatusTransition, apiErr.Code)
+		assert.Equal(t, "invalid order status transition", apiErr.Message)
+	})
+
+	t.Run("unknown routes report the status text", func(t *testing.T) {
+		_, err := c.ResendOrderPlaced(ctx, orderID)
+		var apiErr *client.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	})
+}
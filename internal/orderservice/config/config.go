@@ -1,58 +1,542 @@
 package config
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"os"
-	"strconv"
 	"strings"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/amqpbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/configloader"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/dlq"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/errorreporting"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkatopics"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/natsbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/api"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/backpressure"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/cache"
+	orderkafka "github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/kafka"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/outbox"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/projection"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/quota"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/server"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/service"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/pubsubbroker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/redisclient"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/secrets"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/snssqs"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/rs/zerolog"
+	"github.com/segmentio/kafka-go"
 )
 
 type Config struct {
-	ServerPort   int
-	DatabaseURL  string
-	KafkaBrokers []string
+	// Environment is the profile selected by APP_ENV, or empty for none.
+	Environment string
+
+	ServerPort int `key:"server.port" env:"SERVER_PORT" default:"8080"`
+	// ShutdownTimeout bounds graceful shutdown: finishing HTTP requests,
+	// draining Kafka consumers and flushing producers. ShutdownDrainDelay is
+	// how long the service keeps serving after failing readiness, giving load
+	// balancers time to stop routing to it.
+	ShutdownTimeout    time.Duration `key:"shutdown.timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"`
+	ShutdownDrainDelay time.Duration `key:"shutdown.drain_delay" env:"SHUTDOWN_DRAIN_DELAY" default:"0s"`
+	// TLS serves the API over HTTPS; see server.TLSConfig.
+	TLS server.TLSConfig `key:"server.tls"`
+	// Compression compresses large API responses; see api.CompressionConfig.
+	Compression api.CompressionConfig `key:"server.compression"`
+	// LoadShedding rejects requests with 503 when the service is saturated;
+	// see api.LoadSheddingConfig.
+	LoadShedding api.LoadSheddingConfig `key:"server.load_shedding"`
+	// MaxBodyBytes caps the size of request bodies. Zero disables the cap.
+	MaxBodyBytes int64  `key:"server.max_body_bytes" env:"HTTP_MAX_BODY_BYTES" default:"1048576"`
+	DatabaseURL  string `key:"database.url" env:"DATABASE_URL" secret:"true"`
+	// SlowQueryThreshold is the duration above which database statements are
+	// logged as slow. Zero disables slow-query logging.
+	SlowQueryThreshold time.Duration `key:"database.slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD" default:"200ms"`
+	// DBMaxOpenConns caps connections to the database, DBMaxIdleConns caps
+	// the connections kept open while idle, and DBConnMaxLifetime recycles
+	// connections after the given age (zero keeps them forever).
+	DBMaxOpenConns    int           `key:"database.max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"25"`
+	DBMaxIdleConns    int           `key:"database.max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"10"`
+	DBConnMaxLifetime time.Duration `key:"database.conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"30m"`
+	// Transactions sets the isolation level of the transactions writing
+	// orders and how often they are retried; see repository.TxConfig.
+	Transactions repository.TxConfig `key:"database.transactions"`
+	// MigrateOnStart applies the embedded schema migrations before the
+	// service starts serving.
+	MigrateOnStart bool `key:"database.migrate_on_start" env:"MIGRATE_ON_START" default:"false"`
+	// EventSourcing stores orders as streams of events, with the order
+	// tables kept as projections; see repository.EventSourcingConfig.
+	EventSourcing repository.EventSourcingConfig `key:"database.event_sourcing"`
+	// Projections runs the worker keeping the order read models up to date
+	// from the event streams and serves lists, exports and stats from them.
+	Projections projection.Config `key:"database.projections"`
+
+	// MessageBroker selects the broker carrying events: kafka, nats,
+	// rabbitmq, sns-sqs or pubsub. The consumer group ID applies to each.
+	MessageBroker string `key:"broker" env:"MESSAGE_BROKER" default:"kafka"`
+	// NATS locates the NATS servers when MessageBroker is nats.
+	NATS natsbroker.Config `key:"nats"`
+	// RabbitMQ locates the RabbitMQ server when MessageBroker is rabbitmq.
+	RabbitMQ amqpbroker.Config `key:"rabbitmq"`
+	// SNSSQS selects the AWS region and resources when MessageBroker is
+	// sns-sqs.
+	SNSSQS snssqs.Config `key:"sns_sqs"`
+	// PubSub selects the Google Cloud project when MessageBroker is pubsub.
+	PubSub pubsubbroker.Config `key:"pubsub"`
+
+	KafkaBrokers []string `key:"kafka.brokers" env:"KAFKA_BROKERS"`
+	KafkaGroupID string   `key:"kafka.group_id" env:"ORDER_SERVICE_KAFKA_GROUP_ID" default:"order-service-group"`
+	// KafkaAuth configures SASL and TLS for every Kafka client.
+	KafkaAuth kafkaauth.Config `key:"kafka"`
+	// KafkaRequiredAcks is none, one or all; see kafka.RequiredAcks.
+	KafkaRequiredAcks kafka.RequiredAcks `key:"kafka.required_acks" env:"KAFKA_REQUIRED_ACKS" default:"one"`
+	// KafkaPublishRetry sets how producers retry failed writes.
+	KafkaPublishRetry orderkafka.RetryConfig `key:"kafka.publish_retry"`
+	// KafkaConsumeRetry sets how consumers retry messages their handler
+	// fails on before dead-lettering them.
+	KafkaConsumeRetry dlq.RetryConfig `key:"kafka.consume_retry"`
+	// KafkaTopics sizes the topics provisioned by "ordersctl topics".
+	KafkaTopics kafkatopics.Config `key:"kafka.topics"`
+
+	// Backpressure sets when POST /orders is rejected because the outbox
+	// or the database falls behind.
+	Backpressure backpressure.Config `key:"backpressure"`
+
+	// Timeouts bounds the database calls and Kafka publishes of the order
+	// service; see service.TimeoutConfig.
+	Timeouts service.TimeoutConfig `key:"timeouts"`
+
+	// OrderLocks serializes multi-step mutations of the same order across
+	// instances with PostgreSQL advisory locks.
+	OrderLocks repository.OrderLockConfig `key:"order_locks"`
+
+	// DuplicateOrders detects orders repeating a recent identical order of
+	// the same customer; see service.DuplicateConfig.
+	DuplicateOrders service.DuplicateConfig `key:"duplicates"`
+
+	// CustomerQuota caps the orders each customer may place per minute and
+	// per day; the counters are kept in Redis.
+	CustomerQuota quota.Config `key:"quota"`
+	// Redis is used by features that share state between instances.
+	Redis redisclient.Config `key:"redis"`
+
+	// OrderCache configures the in-memory cache behind GetOrderByID.
+	OrderCache cache.Config `key:"cache"`
+
+	// CircuitBreaker configures the breakers guarding the database and Kafka.
+	CircuitBreaker circuitbreaker.Config `key:"circuit_breaker"`
+
+	// Outbox configures the transactional outbox and its relay, which
+	// publish events after the request that produced them has returned.
+	Outbox outbox.Config `key:"outbox"`
+
+	// FlowMode selects event choreography or saga orchestration for the
+	// cross-service order flow.
+	FlowMode events.FlowMode `key:"saga.mode" env:"SAGA_MODE" default:"choreography"`
+
+	// PaymentEvents consumes payment.events, moving paid orders to
+	// processing and orders whose payment failed to failed. Enable it once
+	// a payment service publishes there.
+	PaymentEvents bool `key:"payment.events_enabled" env:"PAYMENT_EVENTS_ENABLED" default:"false"`
+
+	// ShippingEvents consumes shipping.events, completing delivered orders
+	// and flagging shipping exceptions. Enable it once a shipping service
+	// publishes there.
+	ShippingEvents bool `key:"shipping.events_enabled" env:"SHIPPING_EVENTS_ENABLED" default:"false"`
+
+	// StatusEvents publishes an OrderStatusChanged event on
+	// orders.status_changed for every order status change, for a
+	// notification service to tell customers.
+	StatusEvents bool `key:"order.status_events_enabled" env:"ORDER_STATUS_EVENTS_ENABLED" default:"false"`
+
+	// CatalogServiceURL is the base URL of the product catalog. When empty,
+	// a stub catalog that accepts every product is used.
+	CatalogServiceURL string        `key:"catalog.url" env:"CATALOG_SERVICE_URL"`
+	CatalogTimeout    time.Duration `key:"catalog.timeout" env:"CATALOG_TIMEOUT" default:"2s"`
+
+	// InventoryServiceAddr is the host:port of the inventory service's gRPC
+	// API. When set, orders for products that are out of stock are rejected
+	// at creation; when empty, stock is only checked when it is reserved.
+	InventoryServiceAddr string        `key:"inventory.addr" env:"INVENTORY_SERVICE_ADDR"`
+	InventoryTimeout     time.Duration `key:"inventory.timeout" env:"INVENTORY_TIMEOUT" default:"500ms"`
+
+	// CustomerValidator selects how customer IDs are checked at order
+	// creation: "none", "database" (local customers table) or "http".
+	CustomerValidator  string        `key:"customer.validator" env:"CUSTOMER_VALIDATOR" default:"none"`
+	CustomerServiceURL string        `key:"customer.url" env:"CUSTOMER_SERVICE_URL"`
+	CustomerTimeout    time.Duration `key:"customer.timeout" env:"CUSTOMER_TIMEOUT" default:"2s"`
+
+	// AddressVerifier selects how shipping addresses are checked at order
+	// creation beyond their format: "none" or "http" (address verification
+	// service at AddressServiceURL).
+	AddressVerifier   string        `key:"address.verifier" env:"ADDRESS_VERIFIER" default:"none"`
+	AddressServiceURL string        `key:"address.url" env:"ADDRESS_SERVICE_URL"`
+	AddressTimeout    time.Duration `key:"address.timeout" env:"ADDRESS_TIMEOUT" default:"2s"`
+
+	// LogLevel is the initial zerolog level; it can be changed at runtime
+	// through the admin log-level endpoint.
+	LogLevel string `key:"log.level" env:"LOG_LEVEL" default:"info"`
+	// LogFormat is console for human-readable lines or json for one JSON
+	// object per line; the staging and prod profiles default to json.
+	LogFormat string `key:"log.format" env:"LOG_FORMAT" default:"console"`
+	// AdminToken guards the admin endpoints. They are disabled when empty.
+	AdminToken string `key:"admin.token" env:"ADMIN_TOKEN" secret:"true"`
+
+	// Tracing configures OpenTelemetry export; see the tracing package.
+	Tracing tracing.Config `key:"tracing"`
+
+	// ErrorReporting configures the optional Sentry integration; reporting
+	// is disabled when the DSN is empty.
+	ErrorReporting errorreporting.Config `key:"sentry"`
+
+	// PprofEnabled mounts net/http/pprof and expvar on PprofAddr, which
+	// should be bound to an internal-only interface.
+	PprofEnabled bool   `key:"pprof.enabled" env:"PPROF_ENABLED" default:"false"`
+	PprofAddr    string `key:"pprof.addr" env:"PPROF_ADDR" default:"127.0.0.1:6060"`
 }
 
+// profiles holds the environment-specific defaults selected by APP_ENV. Dev
+// favours verbosity and fast feedback; staging and prod favour durability.
+var profiles = configloader.Profiles{
+	"dev": {
+		"log.level":           "debug",
+		"kafka.required_acks": "one",
+		"sentry.environment":  "development",
+	},
+	"staging": {
+		"log.format":                      "json",
+		"log.level":                       "info",
+		"kafka.required_acks":             "all",
+		"kafka.topics.replication_factor": "3",
+		"sentry.environment":              "staging",
+		"tracing.sample_ratio":            "0.5",
+	},
+	"prod": {
+		"log.format":                      "json",
+		"log.level":                       "info",
+		"kafka.required_acks":             "all",
+		"kafka.topics.partitions":         "6",
+		"kafka.topics.replication_factor": "3",
+		"sentry.environment":              "production",
+		"tracing.sample_ratio":            "0.1",
+		"sentry.sample_rate":              "0.25",
+	},
+}
+
+// LoadConfig reads the order service configuration from the file named by
+// CONFIG_FILE, if any, on top of the APP_ENV profile defaults, with environment
+// variables taking precedence. Every invalid setting is reported in the
+// returned error.
 func LoadConfig() (*Config, error) {
-	//Server Port
-	portStr := os.Getenv("SERVER_PORT")
-	if portStr == "" {
-		portStr = "8080" // Default port
+	cfg := &Config{Environment: os.Getenv(configloader.ProfileEnv)}
+	v := configloader.NewValidator(cfg)
+	profile := configloader.WithProfile(profiles, cfg.Environment)
+	if err := v.Merge(configloader.Load(cfg, os.Getenv(configloader.FileEnv), profile)); err != nil {
+		return nil, err
 	}
-	port, err := strconv.Atoi(portStr)
+
+	// Secrets may be references to Vault or AWS Secrets Manager.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	resolver, err := secrets.NewResolverFromEnv(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("invalid SERVER_PORT: %w", err)
+		return nil, err
+	}
+	if err := v.Merge(configloader.ResolveSecrets(ctx, cfg, resolver.Resolve)); err != nil {
+		return nil, err
 	}
 
-	//Database URL
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		return nil, errors.New("DATABASE_URL environment variable is not set")
+	v.Port(&cfg.ServerPort)
+	v.Positive(&cfg.ShutdownTimeout)
+	if cfg.ShutdownDrainDelay < 0 {
+		v.Addf(&cfg.ShutdownDrainDelay, "must not be negative, got %s", cfg.ShutdownDrainDelay)
+	}
+	if cfg.TLS.Enabled {
+		if len(cfg.TLS.AutocertDomains) == 0 {
+			v.Required(&cfg.TLS.CertFile)
+			v.Required(&cfg.TLS.KeyFile)
+		}
+		if cfg.TLS.RedirectPort != 0 {
+			v.Port(&cfg.TLS.RedirectPort)
+		}
+	}
+	if cfg.MaxBodyBytes < 0 {
+		v.Addf(&cfg.MaxBodyBytes, "must not be negative, got %d", cfg.MaxBodyBytes)
+	}
+	if cfg.Compression.MinSize < 0 {
+		v.Addf(&cfg.Compression.MinSize, "must not be negative, got %d", cfg.Compression.MinSize)
+	}
+	if cfg.Compression.Level < -1 || cfg.Compression.Level == 0 || cfg.Compression.Level > 9 {
+		v.Addf(&cfg.Compression.Level, "must be -1 (default) or between 1 and 9, got %d", cfg.Compression.Level)
+	}
+	if cfg.LoadShedding.MaxInFlight < 0 {
+		v.Addf(&cfg.LoadShedding.MaxInFlight, "must not be negative, got %d", cfg.LoadShedding.MaxInFlight)
+	}
+	if cfg.LoadShedding.MaxQueue < 0 {
+		v.Addf(&cfg.LoadShedding.MaxQueue, "must not be negative, got %d", cfg.LoadShedding.MaxQueue)
+	}
+	v.Positive(&cfg.LoadShedding.QueueTimeout)
+	v.Positive(&cfg.LoadShedding.RetryAfter)
+	v.Required(&cfg.DatabaseURL)
+	v.URL(&cfg.DatabaseURL, "postgres", "postgresql")
+	if cfg.SlowQueryThreshold < 0 {
+		v.Addf(&cfg.SlowQueryThreshold, "must not be negative, got %s", cfg.SlowQueryThreshold)
+	}
+	if cfg.DBMaxOpenConns < 1 {
+		v.Addf(&cfg.DBMaxOpenConns, "must be at least 1, got %d", cfg.DBMaxOpenConns)
+	}
+	if cfg.DBMaxIdleConns < 0 || cfg.DBMaxIdleConns > cfg.DBMaxOpenConns {
+		v.Addf(&cfg.DBMaxIdleConns, "must be between 0 and DB_MAX_OPEN_CONNS (%d), got %d", cfg.DBMaxOpenConns, cfg.DBMaxIdleConns)
+	}
+	if cfg.DBConnMaxLifetime < 0 {
+		v.Addf(&cfg.DBConnMaxLifetime, "must not be negative, got %s", cfg.DBConnMaxLifetime)
 	}
 
-	//Kafka Brokers
-	kafkaBrokersStr := os.Getenv("KAFKA_BROKERS")
-	if kafkaBrokersStr == "" {
-		return nil, errors.New("KAFKA_BROKERS environment variable is not set")
+	v.OneOf(&cfg.MessageBroker, broker.Kinds...)
+	switch cfg.MessageBroker {
+	case broker.Kafka:
+		v.Required(&cfg.KafkaBrokers)
+		v.Brokers(&cfg.KafkaBrokers)
+		if cfg.KafkaAuth.SASLMechanism != "" {
+			cfg.KafkaAuth.SASLMechanism = strings.ToUpper(cfg.KafkaAuth.SASLMechanism)
+			v.OneOf(&cfg.KafkaAuth.SASLMechanism, kafkaauth.MechanismPlain, kafkaauth.MechanismSCRAMSHA256, kafkaauth.MechanismSCRAMSHA512)
+			v.Required(&cfg.KafkaAuth.SASLUsername)
+			v.Required(&cfg.KafkaAuth.SASLPassword)
+		}
+	case broker.NATS:
+		v.Required(&cfg.NATS.URL)
+		if cfg.NATS.StreamReplicas < 1 || cfg.NATS.StreamReplicas > 5 {
+			v.Addf(&cfg.NATS.StreamReplicas, "must be between 1 and 5, got %d", cfg.NATS.StreamReplicas)
+		}
+		v.Positive(&cfg.NATS.StreamMaxAge)
+		v.Positive(&cfg.NATS.AckWait)
+	case broker.RabbitMQ:
+		v.Required(&cfg.RabbitMQ.URL)
+		v.URL(&cfg.RabbitMQ.URL, "amqp", "amqps")
+		v.Required(&cfg.RabbitMQ.Exchange)
+		v.Required(&cfg.RabbitMQ.DeadLetterExchange)
+		if cfg.RabbitMQ.DeadLetterExchange == cfg.RabbitMQ.Exchange {
+			v.Addf(&cfg.RabbitMQ.DeadLetterExchange, "must differ from RABBITMQ_EXCHANGE (%s)", cfg.RabbitMQ.Exchange)
+		}
+		if cfg.RabbitMQ.Prefetch < 1 {
+			v.Addf(&cfg.RabbitMQ.Prefetch, "must be at least 1, got %d", cfg.RabbitMQ.Prefetch)
+		}
+	case broker.SNSSQS:
+		v.URL(&cfg.SNSSQS.Endpoint, "http", "https")
+		if cfg.SNSSQS.VisibilityTimeout < time.Second || cfg.SNSSQS.VisibilityTimeout > 12*time.Hour {
+			v.Addf(&cfg.SNSSQS.VisibilityTimeout, "must be between 1s and 12h, got %s", cfg.SNSSQS.VisibilityTimeout)
+		}
+		if cfg.SNSSQS.WaitTime < 0 || cfg.SNSSQS.WaitTime > 20*time.Second {
+			v.Addf(&cfg.SNSSQS.WaitTime, "must be between 0s and 20s, got %s", cfg.SNSSQS.WaitTime)
+		}
+		if cfg.SNSSQS.MaxMessages < 1 || cfg.SNSSQS.MaxMessages > 10 {
+			v.Addf(&cfg.SNSSQS.MaxMessages, "must be between 1 and 10, got %d", cfg.SNSSQS.MaxMessages)
+		}
+		if cfg.SNSSQS.MaxReceiveCount < 1 || cfg.SNSSQS.MaxReceiveCount > 1000 {
+			v.Addf(&cfg.SNSSQS.MaxReceiveCount, "must be between 1 and 1000, got %d", cfg.SNSSQS.MaxReceiveCount)
+		}
+	case broker.PubSub:
+		v.Required(&cfg.PubSub.ProjectID)
+		if cfg.PubSub.AckDeadline < 10*time.Second || cfg.PubSub.AckDeadline > 10*time.Minute {
+			v.Addf(&cfg.PubSub.AckDeadline, "must be between 10s and 10m, got %s", cfg.PubSub.AckDeadline)
+		}
+		if cfg.PubSub.MaxDeliveryAttempts < 5 || cfg.PubSub.MaxDeliveryAttempts > 100 {
+			v.Addf(&cfg.PubSub.MaxDeliveryAttempts, "must be between 5 and 100, got %d", cfg.PubSub.MaxDeliveryAttempts)
+		}
+		if cfg.PubSub.MaxOutstandingMessages < 1 {
+			v.Addf(&cfg.PubSub.MaxOutstandingMessages, "must be at least 1, got %d", cfg.PubSub.MaxOutstandingMessages)
+		}
 	}
-	kafkaBrokers := splitAndTrim(kafkaBrokersStr, ",")
+	v.Required(&cfg.KafkaGroupID)
 
-	return &Config{
-		ServerPort:   port,
-		DatabaseURL:  dbURL,
-		KafkaBrokers: kafkaBrokers,
-	}, nil
-}
+	if cfg.KafkaPublishRetry.MaxAttempts < 1 {
+		v.Addf(&cfg.KafkaPublishRetry.MaxAttempts, "must be at least 1, got %d", cfg.KafkaPublishRetry.MaxAttempts)
+	}
+	v.Positive(&cfg.KafkaPublishRetry.InitialBackoff)
+	if cfg.KafkaPublishRetry.MaxBackoff < cfg.KafkaPublishRetry.InitialBackoff {
+		v.Addf(&cfg.KafkaPublishRetry.MaxBackoff, "must be at least KAFKA_PUBLISH_INITIAL_BACKOFF (%s), got %s", cfg.KafkaPublishRetry.InitialBackoff, cfg.KafkaPublishRetry.MaxBackoff)
+	}
+	if cfg.KafkaPublishRetry.MaxElapsed < 0 {
+		v.Addf(&cfg.KafkaPublishRetry.MaxElapsed, "must not be negative, got %s", cfg.KafkaPublishRetry.MaxElapsed)
+	}
+
+	if cfg.KafkaConsumeRetry.MaxAttempts < 1 {
+		v.Addf(&cfg.KafkaConsumeRetry.MaxAttempts, "must be at least 1, got %d", cfg.KafkaConsumeRetry.MaxAttempts)
+	}
+	v.Positive(&cfg.KafkaConsumeRetry.InitialBackoff)
+	if cfg.KafkaConsumeRetry.MaxBackoff < cfg.KafkaConsumeRetry.InitialBackoff {
+		v.Addf(&cfg.KafkaConsumeRetry.MaxBackoff, "must be at least KAFKA_CONSUME_INITIAL_BACKOFF (%s), got %s", cfg.KafkaConsumeRetry.InitialBackoff, cfg.KafkaConsumeRetry.MaxBackoff)
+	}
+
+	if cfg.KafkaTopics.Partitions < 1 {
+		v.Addf(&cfg.KafkaTopics.Partitions, "must be at least 1, got %d", cfg.KafkaTopics.Partitions)
+	}
+	if cfg.KafkaTopics.ReplicationFactor < 1 {
+		v.Addf(&cfg.KafkaTopics.ReplicationFactor, "must be at least 1, got %d", cfg.KafkaTopics.ReplicationFactor)
+	}
+	v.Positive(&cfg.KafkaTopics.Retention)
+
+	if cfg.Backpressure.MaxOutboxBacklog < 0 {
+		v.Addf(&cfg.Backpressure.MaxOutboxBacklog, "must not be negative, got %d", cfg.Backpressure.MaxOutboxBacklog)
+	}
+	if cfg.Backpressure.MaxOutboxAge < 0 {
+		v.Addf(&cfg.Backpressure.MaxOutboxAge, "must not be negative, got %s", cfg.Backpressure.MaxOutboxAge)
+	}
+	if cfg.Backpressure.MaxDBLatency < 0 {
+		v.Addf(&cfg.Backpressure.MaxDBLatency, "must not be negative, got %s", cfg.Backpressure.MaxDBLatency)
+	}
+	v.Positive(&cfg.Backpressure.RetryAfter)
+
+	v.OneOf(&cfg.Transactions.Isolation, repository.IsolationReadCommitted, repository.IsolationRepeatableRead, repository.IsolationSerializable)
+	if cfg.Transactions.MaxRetries < 0 {
+		v.Addf(&cfg.Transactions.MaxRetries, "must not be negative, got %d", cfg.Transactions.MaxRetries)
+	}
+	if cfg.EventSourcing.SnapshotEvery < 0 {
+		v.Addf(&cfg.EventSourcing.SnapshotEvery, "must not be negative, got %d", cfg.EventSourcing.SnapshotEvery)
+	}
+	if cfg.Projections.Enabled {
+		if !cfg.EventSourcing.Enabled {
+			v.Addf(&cfg.Projections.Enabled, "requires event sourcing")
+		}
+		if cfg.Projections.BatchSize < 1 {
+			v.Addf(&cfg.Projections.BatchSize, "must be at least 1, got %d", cfg.Projections.BatchSize)
+		}
+		v.Positive(&cfg.Projections.PollInterval)
+	}
+
+	if cfg.Timeouts.DB < 0 {
+		v.Addf(&cfg.Timeouts.DB, "must not be negative, got %s", cfg.Timeouts.DB)
+	}
+	if cfg.Timeouts.Publish < 0 {
+		v.Addf(&cfg.Timeouts.Publish, "must not be negative, got %s", cfg.Timeouts.Publish)
+	}
+
+	if cfg.OrderLocks.Enabled {
+		v.Positive(&cfg.OrderLocks.Timeout)
+	}
+
+	if cfg.DuplicateOrders.Enabled {
+		v.Positive(&cfg.DuplicateOrders.Window)
+		v.OneOf(&cfg.DuplicateOrders.Action, service.DuplicateActionReject, service.DuplicateActionFlag)
+	}
+
+	if cfg.CustomerQuota.Enabled {
+		if cfg.CustomerQuota.OrdersPerMinute < 0 {
+			v.Addf(&cfg.CustomerQuota.OrdersPerMinute, "must not be negative, got %d", cfg.CustomerQuota.OrdersPerMinute)
+		}
+		if cfg.CustomerQuota.OrdersPerDay < 0 {
+			v.Addf(&cfg.CustomerQuota.OrdersPerDay, "must not be negative, got %d", cfg.CustomerQuota.OrdersPerDay)
+		}
+		v.HostPort(&cfg.Redis.Addr)
+		v.Positive(&cfg.Redis.Timeout)
+	}
 
-func splitAndTrim(s, sep string) []string {
-	var result []string
-	parts := strings.Split(s, sep)
-	for _, part := range parts {
-		trimmed := strings.TrimSpace(part)
-		if trimmed != "" {
-			result = append(result, trimmed)
+	if cfg.OrderCache.Enabled {
+		if cfg.OrderCache.Size < 1 {
+			v.Addf(&cfg.OrderCache.Size, "must be at least 1, got %d", cfg.OrderCache.Size)
+		}
+		v.Positive(&cfg.OrderCache.TTL)
+		if cfg.OrderCache.StaleTTL < 0 {
+			v.Addf(&cfg.OrderCache.StaleTTL, "must not be negative, got %s", cfg.OrderCache.StaleTTL)
 		}
 	}
-	return result
+
+	if cfg.CircuitBreaker.FailureThreshold < 1 {
+		v.Addf(&cfg.CircuitBreaker.FailureThreshold, "must be at least 1, got %d", cfg.CircuitBreaker.FailureThreshold)
+	}
+	v.Positive(&cfg.CircuitBreaker.OpenTimeout)
+
+	if cfg.Outbox.Workers < 1 {
+		v.Addf(&cfg.Outbox.Workers, "must be at least 1, got %d", cfg.Outbox.Workers)
+	}
+	if cfg.Outbox.BatchSize < 1 {
+		v.Addf(&cfg.Outbox.BatchSize, "must be at least 1, got %d", cfg.Outbox.BatchSize)
+	}
+	v.Positive(&cfg.Outbox.PollInterval)
+	v.Positive(&cfg.Outbox.Lease)
+	v.Positive(&cfg.Outbox.MaxBackoff)
+	v.OneOf(&cfg.Outbox.Relay, outbox.RelayInternal, outbox.RelayDebezium)
+	if cfg.Outbox.KafkaTransactionalID != "" && (cfg.MessageBroker != broker.Kafka || !cfg.Outbox.Enabled || cfg.Outbox.Relay != outbox.RelayInternal) {
+		v.Addf(&cfg.Outbox.KafkaTransactionalID, "requires the outbox, its internal relay and the kafka broker")
+	}
+
+	if flowMode, ok := events.ParseFlowMode(string(cfg.FlowMode)); ok {
+		cfg.FlowMode = flowMode
+	} else {
+		v.Addf(&cfg.FlowMode, "must be choreography or orchestration, got %q", cfg.FlowMode)
+	}
+
+	v.URL(&cfg.CatalogServiceURL, "http", "https")
+	v.Positive(&cfg.CatalogTimeout)
+
+	v.HostPort(&cfg.InventoryServiceAddr)
+	v.Positive(&cfg.InventoryTimeout)
+
+	v.OneOf(&cfg.CustomerValidator, "none", "database", "http")
+	if cfg.CustomerValidator == "http" {
+		v.Required(&cfg.CustomerServiceURL)
+	}
+	v.URL(&cfg.CustomerServiceURL, "http", "https")
+	v.Positive(&cfg.CustomerTimeout)
+
+	v.OneOf(&cfg.AddressVerifier, "none", "http")
+	if cfg.AddressVerifier == "http" {
+		v.Required(&cfg.AddressServiceURL)
+	}
+	v.URL(&cfg.AddressServiceURL, "http", "https")
+	v.Positive(&cfg.AddressTimeout)
+
+	if _, err := zerolog.ParseLevel(cfg.LogLevel); err != nil {
+		v.Addf(&cfg.LogLevel, "%v", err)
+	}
+	v.OneOf(&cfg.LogFormat, logging.Formats...)
+	v.Ratio(&cfg.Tracing.SampleRatio)
+	v.Ratio(&cfg.ErrorReporting.SampleRate)
+	if cfg.PprofEnabled {
+		v.HostPort(&cfg.PprofAddr)
+	}
+
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Features reports which optional features the configuration enables, as
+// shown by GET /version.
+func (c *Config) Features() map[string]bool {
+	return map[string]bool{
+		"tls":                  c.TLS.Enabled,
+		"compression":          c.Compression.Enabled,
+		"load_shedding":        c.LoadShedding.MaxInFlight > 0,
+		"backpressure":         c.Backpressure.Enabled,
+		"circuit_breaker":      c.CircuitBreaker.Enabled,
+		"outbox":               c.Outbox.Enabled,
+		"event_sourcing":       c.EventSourcing.Enabled,
+		"projections":          c.Projections.Enabled,
+		"saga_orchestration":   c.FlowMode == events.FlowModeOrchestration,
+		"payment_events":       c.PaymentEvents,
+		"shipping_events":      c.ShippingEvents,
+		"status_events":        c.StatusEvents,
+		"order_locks":          c.OrderLocks.Enabled,
+		"duplicate_detection":  c.DuplicateOrders.Enabled,
+		"customer_quota":       c.CustomerQuota.Enabled,
+		"order_cache":          c.OrderCache.Enabled,
+		"catalog":              c.CatalogServiceURL != "",
+		"inventory_check":      c.InventoryServiceAddr != "",
+		"customer_validation":  c.CustomerValidator != "none",
+		"address_verification": c.AddressVerifier != "none",
+		"tracing":              c.Tracing.Enabled,
+		"error_reporting":      c.ErrorReporting.DSN != "",
+		"pprof":                c.PprofEnabled,
+		"admin_api":            c.AdminToken != "",
+	}
 }
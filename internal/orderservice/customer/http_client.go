@@ -0,0 +1,49 @@
+package customer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HTTPClient checks customers against the customer service's REST API.
+type HTTPClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewHTTPClient creates a new HTTPClient for the customer service at baseURL.
+func NewHTTPClient(baseURL string, timeout time.Duration) *HTTPClient {
+	return &HTTPClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// CustomerExists reports whether the customer service knows about customerID.
+func (c *HTTPClient) CustomerExists(ctx context.Context, customerID uuid.UUID) (bool, error) {
+	url := fmt.Sprintf("%s/api/v1/customers/%s", c.baseURL, customerID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to build customer request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to call customer service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("customer service returned unexpected status %d", resp.StatusCode)
+	}
+}
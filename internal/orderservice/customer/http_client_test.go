@@ -0,0 +1,54 @@
+package customer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/customer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient_CustomerExists(t *testing.T) {
+	customerID := uuid.New()
+	serve := func(status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodHead, r.Method)
+			assert.Equal(t, "/api/v1/customers/"+customerID.String(), r.URL.Path)
+			w.WriteHeader(status)
+		}))
+	}
+
+	t.Run("known customer exists", func(t *testing.T) {
+		server := serve(http.StatusOK)
+		defer server.Close()
+
+		exists, err := customer.NewHTTPClient(server.URL+"/", time.Second).CustomerExists(context.Background(), customerID)
+
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("unknown customer does not exist", func(t *testing.T) {
+		server := serve(http.StatusNotFound)
+		defer server.Close()
+
+		exists, err := customer.NewHTTPClient(server.URL, time.Second).CustomerExists(context.Background(), customerID)
+
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("unexpected status is an error", func(t *testing.T) {
+		server := serve(http.StatusInternalServerError)
+		defer server.Close()
+
+		_, err := customer.NewHTTPClient(server.URL, time.Second).CustomerExists(context.Background(), customerID)
+
+		assert.ErrorContains(t, err, "unexpected status 500")
+	})
+}
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxAddressFieldLength bounds each field of an Address.
+const MaxAddressFieldLength = 200
+
+// Address is the postal address an order ships to. Country is an ISO 3166-1
+// alpha-2 code.
+type Address struct {
+	Name       string `json:"name,omitempty"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+// postalCodeFormats are the postal code formats of countries whose codes
+// can be checked without an address verification service.
+var postalCodeFormats = map[string]*regexp.Regexp{
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] \d[A-Z]\d$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^\d{4} [A-Z]{2}$`),
+}
+
+// Normalize returns a with surrounding and repeated whitespace removed from
+// every field and the country and postal code upper-cased.
+func (a Address) Normalize() Address {
+	clean := func(s string) string { return strings.Join(strings.Fields(s), " ") }
+	return Address{
+		Name:       clean(a.Name),
+		Line1:      clean(a.Line1),
+		Line2:      clean(a.Line2),
+		City:       clean(a.City),
+		Region:     clean(a.Region),
+		PostalCode: strings.ToUpper(clean(a.PostalCode)),
+		Country:    strings.ToUpper(clean(a.Country)),
+	}
+}
+
+// Validate checks a normalized address: the street, city, postal code and
+// country are required, the country must be a two-letter code and, where
+// its format is known, the postal code must match it. It returns an
+// *InvalidAddressError listing every problem.
+func (a Address) Validate() error {
+	var problems []AddressFieldError
+	check := func(field, value string, required bool) {
+		switch {
+		case required && value == "":
+			problems = append(problems, AddressFieldError{Field: field, Constraint: "required", Message: "is required"})
+		case len(value) > MaxAddressFieldLength:
+			problems = append(problems, AddressFieldError{Field: field, Constraint: "max", Message: "is too long"})
+		}
+	}
+	check("name", a.Name, false)
+	check("line1", a.Line1, true)
+	check("line2", a.Line2, false)
+	check("city", a.City, true)
+	check("region", a.Region, false)
+	check("postal_code", a.PostalCode, true)
+	check("country", a.Country, true)
+
+	if a.Country != "" && !isCountryCode(a.Country) {
+		problems = append(problems, AddressFieldError{Field: "country", Constraint: "format", Message: "must be an ISO 3166-1 alpha-2 country code"})
+	} else if format, ok := postalCodeFormats[a.Country]; ok && a.PostalCode != "" && !format.MatchString(a.PostalCode) {
+		problems = append(problems, AddressFieldError{Field: "postal_code", Constraint: "format", Message: "is not a valid postal code for " + a.Country})
+	}
+
+	if len(problems) > 0 {
+		return &InvalidAddressError{Fields: problems}
+	}
+	return nil
+}
+
+func isCountryCode(s string) bool {
+	if len(s) != 2 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package domain_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+)
+
+func TestAddress_Normalize(t *testing.T) {
+	got := domain.Address{
+		Name:       "  Ada   Lovelace ",
+		Line1:      "12 St James's\tSquare",
+		City:       " London",
+		PostalCode: "sw1y  4lb",
+		Country:    " gb ",
+	}.Normalize()
+
+	want := domain.Address{Name: "Ada Lovelace", Line1: "12 St James's Square", City: "London", PostalCode: "SW1Y 4LB", Country: "GB"}
+	if got != want {
+		t.Errorf("Normalize() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAddress_Validate(t *testing.T) {
+	valid := domain.Address{Line1: "12 St James's Square", City: "London", PostalCode: "SW1Y 4LB", Country: "GB"}
+
+	tests := []struct {
+		name   string
+		modify func(*domain.Address)
+		want   []string // field.constraint of each problem
+	}{
+		{"valid address", func(*domain.Address) {}, nil},
+		{"unknown postal code format is accepted", func(a *domain.Address) { a.Country, a.PostalCode = "JP", "100-0001" }, nil},
+		{"missing fields", func(a *domain.Address) { a.Line1, a.City = "", "" }, []string{"line1.required", "city.required"}},
+		{"field too long", func(a *domain.Address) { a.Line2 = strings.Repeat("x", domain.MaxAddressFieldLength+1) }, []string{"line2.max"}},
+		{"country is not a code", func(a *domain.Address) { a.Country = "UK1" }, []string{"country.format"}},
+		{"postal code does not match the country", func(a *domain.Address) { a.Country, a.PostalCode = "US", "SW1Y 4LB" }, []string{"postal_code.format"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := valid
+			tt.modify(&addr)
+
+			err := addr.Validate()
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, domain.ErrInvalidAddress) {
+				t.Fatalf("Validate() error = %v, want ErrInvalidAddress", err)
+			}
+			var invalid *domain.InvalidAddressError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Validate() error = %T, want *InvalidAddressError", err)
+			}
+			var got []string
+			for _, f := range invalid.Fields {
+				got = append(got, f.Field+"."+f.Constraint)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Validate() problems = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
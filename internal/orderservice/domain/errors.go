@@ -1,6 +1,13 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 var (
 	ErrInvalidOrderItemQuantity     = errors.New("invalid order item quantity")
@@ -8,4 +15,140 @@ var (
 	ErrNoOrderItems                 = errors.New("no order items provided")
 	ErrOrderNotFound                = errors.New("order not found")
 	ErrInvalidOrderStatusTransition = errors.New("invalid order status transition")
+	ErrProductNotSellable           = errors.New("product does not exist or is not sellable")
+	ErrOutOfStock                   = errors.New("product is out of stock")
+	ErrCustomerNotFound             = errors.New("customer not found")
+	ErrQuotaExceeded                = errors.New("customer order quota exceeded")
+	ErrDuplicateOrder               = errors.New("duplicate order")
+	ErrInvalidExternalReference     = errors.New("invalid external reference")
+	ErrExternalReferenceExists      = errors.New("external reference already used")
+	ErrOrderLocked                  = errors.New("order is being changed by another operation")
+	ErrInvalidAddress               = errors.New("invalid shipping address")
 )
+
+// UnsellableProductsError lists the product IDs rejected by the catalog.
+// It matches ErrProductNotSellable via errors.Is.
+type UnsellableProductsError struct {
+	ProductIDs []uuid.UUID
+}
+
+func (e *UnsellableProductsError) Error() string {
+	ids := make([]string, len(e.ProductIDs))
+	for i, id := range e.ProductIDs {
+		ids[i] = id.String()
+	}
+	return fmt.Sprintf("%s: %s", ErrProductNotSellable, strings.Join(ids, ", "))
+}
+
+func (e *UnsellableProductsError) Unwrap() error {
+	return ErrProductNotSellable
+}
+
+// OutOfStockError lists the product IDs the inventory service has too
+// little stock of. It matches ErrOutOfStock via errors.Is.
+type OutOfStockError struct {
+	ProductIDs []uuid.UUID
+}
+
+func (e *OutOfStockError) Error() string {
+	ids := make([]string, len(e.ProductIDs))
+	for i, id := range e.ProductIDs {
+		ids[i] = id.String()
+	}
+	return fmt.Sprintf("%s: %s", ErrOutOfStock, strings.Join(ids, ", "))
+}
+
+func (e *OutOfStockError) Unwrap() error {
+	return ErrOutOfStock
+}
+
+// AddressFieldError is a problem with one field of an Address. Field is the
+// field's JSON name and Constraint the rule it breaks, such as required or
+// format.
+type AddressFieldError struct {
+	Field      string
+	Constraint string
+	Message    string
+}
+
+// InvalidAddressError lists the problems found with a shipping address, by
+// validation or by an address verification service. It matches
+// ErrInvalidAddress via errors.Is.
+type InvalidAddressError struct {
+	Fields []AddressFieldError
+}
+
+func (e *InvalidAddressError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		problems[i] = f.Field + " " + f.Message
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidAddress, strings.Join(problems, "; "))
+}
+
+func (e *InvalidAddressError) Unwrap() error {
+	return ErrInvalidAddress
+}
+
+// QuotaExceededError reports that a customer has placed as many orders as
+// its quota allows in the current window. It matches ErrQuotaExceeded via
+// errors.Is.
+type QuotaExceededError struct {
+	// Window is the quota period, e.g. "minute" or "day".
+	Window string
+	Limit  int
+	// RetryAfter is the time left until the window ends.
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s: %d orders per %s", ErrQuotaExceeded, e.Limit, e.Window)
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// DuplicateOrderError reports that an order repeats a recent order of the
+// same customer. It matches ErrDuplicateOrder via errors.Is.
+type DuplicateOrderError struct {
+	ExistingOrderID uuid.UUID
+}
+
+func (e *DuplicateOrderError) Error() string {
+	return fmt.Sprintf("%s of order %s", ErrDuplicateOrder, e.ExistingOrderID)
+}
+
+func (e *DuplicateOrderError) Unwrap() error {
+	return ErrDuplicateOrder
+}
+
+// InvalidTransitionError reports a status change the order's current status
+// does not allow. It matches ErrInvalidOrderStatusTransition via errors.Is.
+type InvalidTransitionError struct {
+	From OrderStatus
+	To   OrderStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("%s from %s to %s", ErrInvalidOrderStatusTransition, e.From, e.To)
+}
+
+func (e *InvalidTransitionError) Unwrap() error {
+	return ErrInvalidOrderStatusTransition
+}
+
+// ExternalReferenceConflictError reports that another order already has the
+// external reference of a new order. It matches ErrExternalReferenceExists
+// via errors.Is.
+type ExternalReferenceConflictError struct {
+	ExistingOrderID uuid.UUID
+}
+
+func (e *ExternalReferenceConflictError) Error() string {
+	return fmt.Sprintf("%s by order %s", ErrExternalReferenceExists, e.ExistingOrderID)
+}
+
+func (e *ExternalReferenceConflictError) Unwrap() error {
+	return ErrExternalReferenceExists
+}
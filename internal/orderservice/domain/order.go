@@ -1,7 +1,13 @@
 package domain
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
@@ -14,6 +20,32 @@ type Order struct {
 	TotalPrice float64   `json:"total_price"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+	// ExternalReference is the order's number in the system it was imported
+	// from, if any.
+	ExternalReference ExternalReference `json:"external_reference"`
+	// ShippingAddress is where the order ships, if it was given.
+	ShippingAddress *Address `json:"shipping_address,omitempty"`
+	// DuplicateOf is set by the service on a new order accepted although it
+	// repeats a recent order of the same customer. It is not stored.
+	DuplicateOf uuid.UUID `json:"-"`
+	// Version is the number of events in the order's stream when orders are
+	// event sourced, and zero otherwise.
+	Version int `json:"-"`
+}
+
+// Now returns the current time as orders record it: in UTC, truncated to the
+// microsecond precision PostgreSQL stores, so a timestamp reads back from the
+// database exactly as it was written.
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// NormalizeTimestamps converts the order's timestamps to UTC. Timestamps
+// entering the domain from elsewhere, such as rows read in the database
+// session's time zone, must be normalized.
+func (o *Order) NormalizeTimestamps() {
+	o.CreatedAt = o.CreatedAt.UTC()
+	o.UpdatedAt = o.UpdatedAt.UTC()
 }
 
 type OrderItem struct {
@@ -22,6 +54,41 @@ type OrderItem struct {
 	UnitPrice float64   `json:"unit_price"`
 }
 
+// ExternalReference identifies an order in another system, such as a
+// marketplace or ERP order number. A reference is unique within its Source.
+// The zero value means the order has no external reference.
+type ExternalReference struct {
+	Source    string `json:"source"`
+	Reference string `json:"reference"`
+}
+
+// Maximum lengths of the ExternalReference fields.
+const (
+	MaxExternalSourceLength    = 100
+	MaxExternalReferenceLength = 255
+)
+
+// IsZero reports whether r is empty.
+func (r ExternalReference) IsZero() bool {
+	return r == ExternalReference{}
+}
+
+// Validate checks that r is empty or has both a source and a reference
+// within the length limits.
+func (r ExternalReference) Validate() error {
+	if r.IsZero() {
+		return nil
+	}
+	if r.Source == "" || r.Reference == "" {
+		return fmt.Errorf("%w: source and reference are both required", ErrInvalidExternalReference)
+	}
+	if utf8.RuneCountInString(r.Source) > MaxExternalSourceLength || utf8.RuneCountInString(r.Reference) > MaxExternalReferenceLength {
+		return fmt.Errorf("%w: source is limited to %d and reference to %d characters",
+			ErrInvalidExternalReference, MaxExternalSourceLength, MaxExternalReferenceLength)
+	}
+	return nil
+}
+
 type OrderStatus string
 
 const (
@@ -32,6 +99,16 @@ const (
 	OrderStatusFailed     OrderStatus = "failed"
 )
 
+// ParseOrderStatus validates s and returns it as an OrderStatus.
+func ParseOrderStatus(s string) (OrderStatus, bool) {
+	switch status := OrderStatus(s); status {
+	case OrderStatusPending, OrderStatusProcessing, OrderStatusCompleted, OrderStatusCancelled, OrderStatusFailed:
+		return status, true
+	default:
+		return "", false
+	}
+}
+
 func NewOrder(customerID uuid.UUID, items []OrderItem) (*Order, error) {
 	if len(items) == 0 {
 		return nil, ErrNoOrderItems
@@ -49,7 +126,7 @@ func NewOrder(customerID uuid.UUID, items []OrderItem) (*Order, error) {
 		totalPrice += float64(item.Quantity) * item.UnitPrice
 	}
 
-	now := time.Now()
+	now := Now()
 	order := &Order{
 		ID:         uuid.New(),
 		CustomerID: customerID,
@@ -62,3 +139,55 @@ func NewOrder(customerID uuid.UUID, items []OrderItem) (*Order, error) {
 
 	return order, nil
 }
+
+// ItemFingerprint identifies the order's set of items, regardless of their
+// order: two orders with the same products, quantities and unit prices have
+// the same fingerprint.
+func (o *Order) ItemFingerprint() string {
+	lines := make([]string, len(o.Items))
+	for i, item := range o.Items {
+		lines[i] = fmt.Sprintf("%s:%d:%.2f", item.ProductID, item.Quantity, item.UnitPrice)
+	}
+	slices.Sort(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// allowedTransitions lists the statuses each status may move to.
+var allowedTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:    {OrderStatusProcessing, OrderStatusCancelled, OrderStatusFailed},
+	OrderStatusProcessing: {OrderStatusCompleted, OrderStatusCancelled, OrderStatusFailed},
+}
+
+// CanTransitionTo reports whether the order may move from its current status to next.
+func (o *Order) CanTransitionTo(next OrderStatus) bool {
+	for _, s := range allowedTransitions[o.Status] {
+		if s == next {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedPreviousStatuses returns the statuses an order may move to next
+// from.
+func AllowedPreviousStatuses(next OrderStatus) []OrderStatus {
+	var from []OrderStatus
+	for status, allowed := range allowedTransitions {
+		if slices.Contains(allowed, next) {
+			from = append(from, status)
+		}
+	}
+	slices.Sort(from)
+	return from
+}
+
+// TransitionTo moves the order to next, enforcing the allowed status transitions.
+func (o *Order) TransitionTo(next OrderStatus) error {
+	if !o.CanTransitionTo(next) {
+		return ErrInvalidOrderStatusTransition
+	}
+	o.Status = next
+	o.UpdatedAt = Now()
+	return nil
+}
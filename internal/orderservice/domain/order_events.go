@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrderEventType names the kind of change an OrderEvent records.
+type OrderEventType string
+
+const (
+	OrderCreatedEvent       OrderEventType = "order_created"
+	OrderStatusChangedEvent OrderEventType = "order_status_changed"
+)
+
+// OrderEventData is the payload of an OrderEvent.
+type OrderEventData interface {
+	EventType() OrderEventType
+}
+
+// OrderCreated starts an order's event stream with the order's state. It
+// normally describes a new pending order, but also adopts orders stored
+// before their stream began, in whatever status they had reached.
+type OrderCreated struct {
+	CustomerID        uuid.UUID         `json:"customer_id"`
+	Items             []OrderItem       `json:"items"`
+	Status            OrderStatus       `json:"status"`
+	TotalPrice        float64           `json:"total_price"`
+	ExternalReference ExternalReference `json:"external_reference"`
+	ShippingAddress   *Address          `json:"shipping_address,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+}
+
+func (*OrderCreated) EventType() OrderEventType { return OrderCreatedEvent }
+
+// OrderStatusChanged moves an order from one status to another.
+type OrderStatusChanged struct {
+	From OrderStatus `json:"from"`
+	To   OrderStatus `json:"to"`
+}
+
+func (*OrderStatusChanged) EventType() OrderEventType { return OrderStatusChangedEvent }
+
+// OrderEvent is a change to an order. An order's events are numbered from 1
+// in the order they happened, and replaying them rebuilds the order.
+type OrderEvent struct {
+	OrderID    uuid.UUID
+	Version    int
+	OccurredAt time.Time
+	Data       OrderEventData
+}
+
+// CreatedEvent returns the event starting o's stream, recording its current
+// state.
+func (o *Order) CreatedEvent() OrderEvent {
+	return OrderEvent{
+		OrderID:    o.ID,
+		Version:    1,
+		OccurredAt: o.UpdatedAt,
+		Data: &OrderCreated{
+			CustomerID:        o.CustomerID,
+			Items:             o.Items,
+			Status:            o.Status,
+			TotalPrice:        o.TotalPrice,
+			ExternalReference: o.ExternalReference,
+			ShippingAddress:   o.ShippingAddress,
+			CreatedAt:         o.CreatedAt,
+		},
+	}
+}
+
+// Record applies a new event with data, which happened at at, to o and
+// returns it.
+func (o *Order) Record(data OrderEventData, at time.Time) (OrderEvent, error) {
+	event := OrderEvent{OrderID: o.ID, Version: o.Version + 1, OccurredAt: at, Data: data}
+	if err := o.Apply(event); err != nil {
+		return OrderEvent{}, err
+	}
+	return event, nil
+}
+
+// Apply changes o as event describes. Events must be applied in version
+// order. Status changes were validated when recorded and are not checked
+// again, so history always replays.
+func (o *Order) Apply(event OrderEvent) error {
+	if event.Version != o.Version+1 {
+		return fmt.Errorf("event %d of order %s applied at version %d", event.Version, event.OrderID, o.Version)
+	}
+	switch data := event.Data.(type) {
+	case *OrderCreated:
+		*o = Order{
+			ID:                event.OrderID,
+			CustomerID:        data.CustomerID,
+			Items:             data.Items,
+			Status:            data.Status,
+			TotalPrice:        data.TotalPrice,
+			ExternalReference: data.ExternalReference,
+			ShippingAddress:   data.ShippingAddress,
+			CreatedAt:         data.CreatedAt.UTC(),
+		}
+	case *OrderStatusChanged:
+		o.Status = data.To
+	default:
+		return fmt.Errorf("unknown event %T of order %s", event.Data, event.OrderID)
+	}
+	o.Version = event.Version
+	o.UpdatedAt = event.OccurredAt.UTC()
+	return nil
+}
+
+// RebuildOrder replays events onto snapshot, a copy of the order at the
+// snapshot's version, or onto an empty order if snapshot is nil. It returns
+// nil if there is neither a snapshot nor events.
+func RebuildOrder(snapshot *Order, events []OrderEvent) (*Order, error) {
+	if snapshot == nil && len(events) == 0 {
+		return nil, nil
+	}
+	order := &Order{}
+	if snapshot != nil {
+		*order = *snapshot
+	}
+	for _, event := range events {
+		if err := order.Apply(event); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
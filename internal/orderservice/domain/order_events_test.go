@@ -0,0 +1,56 @@
+package domain_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+)
+
+func TestRebuildOrder(t *testing.T) {
+	order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 2, UnitPrice: 5}})
+	if err != nil {
+		t.Fatalf("NewOrder() error = %v", err)
+	}
+	order.ExternalReference = domain.ExternalReference{Source: "shopify", Reference: "#1001"}
+	created := order.CreatedEvent()
+	order.Version = created.Version
+
+	processingAt := order.CreatedAt.Add(time.Minute)
+	processing, err := order.Record(&domain.OrderStatusChanged{From: domain.OrderStatusPending, To: domain.OrderStatusProcessing}, processingAt)
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if processing.Version != 2 || order.Version != 2 || order.Status != domain.OrderStatusProcessing || !order.UpdatedAt.Equal(processingAt) {
+		t.Fatalf("Record() = event %d, order at version %d in status %s updated at %v", processing.Version, order.Version, order.Status, order.UpdatedAt)
+	}
+	snapshot := *order
+	completed, err := order.Record(&domain.OrderStatusChanged{From: domain.OrderStatusProcessing, To: domain.OrderStatusCompleted}, processingAt.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	rebuilt, err := domain.RebuildOrder(nil, []domain.OrderEvent{created, processing, completed})
+	if err != nil {
+		t.Fatalf("RebuildOrder() error = %v", err)
+	}
+	if !reflect.DeepEqual(rebuilt, order) {
+		t.Errorf("RebuildOrder() = %+v, want %+v", rebuilt, order)
+	}
+	fromSnapshot, err := domain.RebuildOrder(&snapshot, []domain.OrderEvent{completed})
+	if err != nil {
+		t.Fatalf("RebuildOrder() from snapshot error = %v", err)
+	}
+	if !reflect.DeepEqual(fromSnapshot, order) {
+		t.Errorf("RebuildOrder() from snapshot = %+v, want %+v", fromSnapshot, order)
+	}
+
+	if _, err := domain.RebuildOrder(nil, []domain.OrderEvent{created, completed}); err == nil {
+		t.Error("RebuildOrder() with a missing event should fail")
+	}
+	if empty, err := domain.RebuildOrder(nil, nil); empty != nil || err != nil {
+		t.Errorf("RebuildOrder(nil, nil) = %v, %v, want nil", empty, err)
+	}
+}
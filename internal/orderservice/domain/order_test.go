@@ -1,7 +1,11 @@
 package domain_test // Use package_test for black-box testing
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
@@ -113,3 +117,130 @@ func TestNewOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestOrder_TransitionTo(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    domain.OrderStatus
+		to      domain.OrderStatus
+		wantErr error
+	}{
+		{name: "pending to processing", from: domain.OrderStatusPending, to: domain.OrderStatusProcessing},
+		{name: "pending to failed", from: domain.OrderStatusPending, to: domain.OrderStatusFailed},
+		{name: "processing to completed", from: domain.OrderStatusProcessing, to: domain.OrderStatusCompleted},
+		{name: "pending to completed", from: domain.OrderStatusPending, to: domain.OrderStatusCompleted, wantErr: domain.ErrInvalidOrderStatusTransition},
+		{name: "completed to cancelled", from: domain.OrderStatusCompleted, to: domain.OrderStatusCancelled, wantErr: domain.ErrInvalidOrderStatusTransition},
+		{name: "failed to processing", from: domain.OrderStatusFailed, to: domain.OrderStatusProcessing, wantErr: domain.ErrInvalidOrderStatusTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &domain.Order{Status: tt.from}
+			err := order.TransitionTo(tt.to)
+			if err != tt.wantErr {
+				t.Fatalf("TransitionTo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := tt.to
+			if tt.wantErr != nil {
+				want = tt.from
+			}
+			if order.Status != want {
+				t.Errorf("TransitionTo() status = %v, want %v", order.Status, want)
+			}
+		})
+	}
+}
+
+func TestOrder_TimestampsAreUTC(t *testing.T) {
+	order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 1}})
+	if err != nil {
+		t.Fatalf("NewOrder() error = %v", err)
+	}
+	if order.CreatedAt.Location() != time.UTC || !order.UpdatedAt.Equal(order.CreatedAt) {
+		t.Errorf("NewOrder() timestamps = %v, %v, want equal UTC times", order.CreatedAt, order.UpdatedAt)
+	}
+	if order.CreatedAt.Nanosecond()%1000 != 0 {
+		t.Errorf("NewOrder() created at %v, want microsecond precision", order.CreatedAt)
+	}
+
+	if err := order.TransitionTo(domain.OrderStatusProcessing); err != nil {
+		t.Fatalf("TransitionTo() error = %v", err)
+	}
+	if order.UpdatedAt.Location() != time.UTC || order.UpdatedAt.Before(order.CreatedAt) {
+		t.Errorf("TransitionTo() updated at %v, want a UTC time after %v", order.UpdatedAt, order.CreatedAt)
+	}
+
+	lagos := time.FixedZone("WAT", 3600)
+	local := &domain.Order{
+		CreatedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, lagos),
+		UpdatedAt: time.Date(2024, 3, 1, 11, 0, 0, 0, lagos),
+	}
+	local.NormalizeTimestamps()
+	if want := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC); local.CreatedAt != want {
+		t.Errorf("NormalizeTimestamps() created at %v, want %v", local.CreatedAt, want)
+	}
+	if want := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC); local.UpdatedAt != want {
+		t.Errorf("NormalizeTimestamps() updated at %v, want %v", local.UpdatedAt, want)
+	}
+}
+
+func TestAllowedPreviousStatuses(t *testing.T) {
+	got := domain.AllowedPreviousStatuses(domain.OrderStatusCancelled)
+	want := []domain.OrderStatus{domain.OrderStatusPending, domain.OrderStatusProcessing}
+	if !slices.Equal(got, want) {
+		t.Errorf("AllowedPreviousStatuses(cancelled) = %v, want %v", got, want)
+	}
+	if got := domain.AllowedPreviousStatuses(domain.OrderStatusPending); len(got) != 0 {
+		t.Errorf("AllowedPreviousStatuses(pending) = %v, want none", got)
+	}
+}
+
+func TestParseOrderStatus(t *testing.T) {
+	if status, ok := domain.ParseOrderStatus("cancelled"); !ok || status != domain.OrderStatusCancelled {
+		t.Errorf("ParseOrderStatus(cancelled) = %v, %v", status, ok)
+	}
+	if _, ok := domain.ParseOrderStatus("shipped"); ok {
+		t.Error("ParseOrderStatus(shipped) should be rejected")
+	}
+}
+
+func TestOrder_ItemFingerprint(t *testing.T) {
+	a := domain.OrderItem{ProductID: uuid.New(), Quantity: 1, UnitPrice: 9.99}
+	b := domain.OrderItem{ProductID: uuid.New(), Quantity: 2, UnitPrice: 5}
+	order := &domain.Order{Items: []domain.OrderItem{a, b}}
+
+	if got, want := (&domain.Order{Items: []domain.OrderItem{b, a}}).ItemFingerprint(), order.ItemFingerprint(); got != want {
+		t.Errorf("fingerprint depends on item order: %s != %s", got, want)
+	}
+	changed := b
+	changed.Quantity = 3
+	if (&domain.Order{Items: []domain.OrderItem{a, changed}}).ItemFingerprint() == order.ItemFingerprint() {
+		t.Error("orders with different quantities have the same fingerprint")
+	}
+	if (&domain.Order{Items: []domain.OrderItem{a}}).ItemFingerprint() == order.ItemFingerprint() {
+		t.Error("orders with different items have the same fingerprint")
+	}
+}
+
+func TestExternalReference_Validate(t *testing.T) {
+	valid := []domain.ExternalReference{
+		{},
+		{Source: "shopify", Reference: "#1001"},
+		{Source: strings.Repeat("é", domain.MaxExternalSourceLength), Reference: "1"},
+	}
+	for _, ref := range valid {
+		if err := ref.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", ref, err)
+		}
+	}
+	invalid := []domain.ExternalReference{
+		{Source: "shopify"},
+		{Reference: "#1001"},
+		{Source: "shopify", Reference: strings.Repeat("1", domain.MaxExternalReferenceLength+1)},
+	}
+	for _, ref := range invalid {
+		if err := ref.Validate(); !errors.Is(err, domain.ErrInvalidExternalReference) {
+			t.Errorf("Validate(%+v) = %v, want ErrInvalidExternalReference", ref, err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Package export encodes orders for bulk exports such as finance
+// reconciliation.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+)
+
+// Format selects the export encoding.
+type Format string
+
+const (
+	// FormatCSV writes one row per order with the columns in Columns.
+	FormatCSV Format = "csv"
+	// FormatJSONL writes one JSON object per line, including the items.
+	FormatJSONL Format = "jsonl"
+)
+
+// ParseFormat validates s and returns it as a Format. An empty string
+// selects FormatCSV, and ndjson is another name for FormatJSONL.
+func ParseFormat(s string) (Format, bool) {
+	switch Format(s) {
+	case "", FormatCSV:
+		return FormatCSV, true
+	case FormatJSONL, "ndjson":
+		return FormatJSONL, true
+	default:
+		return "", false
+	}
+}
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	if f == FormatJSONL {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// Columns is the CSV header. The order is part of the export contract:
+// append new columns at the end so existing reconciliation jobs keep working.
+var Columns = []string{"order_id", "customer_id", "status", "total_price", "item_count", "item_quantity", "created_at", "updated_at"}
+
+// Record is the JSON Lines representation of an order. Its field order
+// matches Columns.
+type Record struct {
+	OrderID      string       `json:"order_id"`
+	CustomerID   string       `json:"customer_id"`
+	Status       string       `json:"status"`
+	TotalPrice   string       `json:"total_price"`
+	ItemCount    int          `json:"item_count"`
+	ItemQuantity int          `json:"item_quantity"`
+	CreatedAt    string       `json:"created_at"`
+	UpdatedAt    string       `json:"updated_at"`
+	Items        []RecordItem `json:"items"`
+}
+
+// RecordItem is an order item within a Record.
+type RecordItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice string `json:"unit_price"`
+}
+
+// NewRecord converts order to its export representation. Amounts are
+// formatted with two decimals and times in UTC, so exports are
+// byte-for-byte reproducible.
+func NewRecord(order *domain.Order) Record {
+	r := Record{
+		OrderID:    order.ID.String(),
+		CustomerID: order.CustomerID.String(),
+		Status:     string(order.Status),
+		TotalPrice: formatAmount(order.TotalPrice),
+		ItemCount:  len(order.Items),
+		CreatedAt:  formatTime(order.CreatedAt),
+		UpdatedAt:  formatTime(order.UpdatedAt),
+		Items:      make([]RecordItem, len(order.Items)),
+	}
+	for i, item := range order.Items {
+		r.ItemQuantity += item.Quantity
+		r.Items[i] = RecordItem{
+			ProductID: item.ProductID.String(),
+			Quantity:  item.Quantity,
+			UnitPrice: formatAmount(item.UnitPrice),
+		}
+	}
+	return r
+}
+
+// row returns the record's CSV fields in Columns order.
+func (r Record) row() []string {
+	return []string{r.OrderID, r.CustomerID, r.Status, r.TotalPrice, strconv.Itoa(r.ItemCount), strconv.Itoa(r.ItemQuantity), r.CreatedAt, r.UpdatedAt}
+}
+
+// Writer encodes orders one at a time.
+type Writer interface {
+	Write(order *domain.Order) error
+	// Flush writes any buffered data to the underlying writer.
+	Flush() error
+}
+
+// NewWriter returns a Writer encoding orders to w in format. The CSV header is
+// written with the first order, or by Flush if there were none.
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.w.Write(Columns)
+}
+
+func (c *csvWriter) Write(order *domain.Order) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.w.Write(NewRecord(order).row())
+}
+
+func (c *csvWriter) Flush() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Write(order *domain.Order) error {
+	return j.enc.Encode(NewRecord(order))
+}
+
+func (j *jsonlWriter) Flush() error {
+	return nil
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
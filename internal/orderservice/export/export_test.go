@@ -0,0 +1,81 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/export"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOrder() *domain.Order {
+	created := time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+	return &domain.Order{
+		ID:         uuid.MustParse("11111111-1111-1111-1111-111111111111"),
+		CustomerID: uuid.MustParse("22222222-2222-2222-2222-222222222222"),
+		Status:     domain.OrderStatusCompleted,
+		TotalPrice: 25,
+		Items: []domain.OrderItem{
+			{ProductID: uuid.MustParse("33333333-3333-3333-3333-333333333333"), Quantity: 2, UnitPrice: 10},
+			{ProductID: uuid.MustParse("44444444-4444-4444-4444-444444444444"), Quantity: 1, UnitPrice: 5},
+		},
+		CreatedAt: created,
+		UpdatedAt: created.Add(time.Minute),
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := export.NewWriter(&buf, export.FormatCSV)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(testOrder()))
+	require.NoError(t, w.Flush())
+
+	want := "order_id,customer_id,status,total_price,item_count,item_quantity,created_at,updated_at\n" +
+		"11111111-1111-1111-1111-111111111111,22222222-2222-2222-2222-222222222222,completed,25.00,2,3,2024-03-01T11:30:00Z,2024-03-01T11:31:00Z\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestCSVWriter_EmptyExportHasHeader(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := export.NewWriter(&buf, export.FormatCSV)
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, strings.Join(export.Columns, ",")+"\n", buf.String())
+}
+
+func TestJSONLWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := export.NewWriter(&buf, export.FormatJSONL)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(testOrder()))
+	require.NoError(t, w.Write(testOrder()))
+	require.NoError(t, w.Flush())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], `{"order_id":"11111111-1111-1111-1111-111111111111","customer_id":`), "fields keep the column order")
+
+	var record export.Record
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "25.00", record.TotalPrice)
+	assert.Len(t, record.Items, 2)
+	assert.Equal(t, "10.00", record.Items[0].UnitPrice)
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]export.Format{"": export.FormatCSV, "csv": export.FormatCSV, "jsonl": export.FormatJSONL, "ndjson": export.FormatJSONL} {
+		got, ok := export.ParseFormat(in)
+		assert.True(t, ok, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, ok := export.ParseFormat("xlsx")
+	assert.False(t, ok)
+}
@@ -0,0 +1,72 @@
+// Package inventory checks stock with the inventory service's gRPC query API
+// before an order is placed, so orders for products that are out of stock
+// can be rejected while the customer is still waiting.
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventorypb"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// GRPCClient asks the inventory service whether order items are in stock.
+type GRPCClient struct {
+	conn    *grpc.ClientConn
+	client  inventorypb.InventoryServiceClient
+	timeout time.Duration
+}
+
+// NewGRPCClient creates a GRPCClient for the inventory service at addr, a
+// host:port address. Calls fail after timeout. The connection is made
+// lazily, on the first call.
+func NewGRPCClient(addr string, timeout time.Duration) (*GRPCClient, error) {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inventory client for %s: %w", addr, err)
+	}
+	return &GRPCClient{conn: conn, client: inventorypb.NewInventoryServiceClient(conn), timeout: timeout}, nil
+}
+
+// Close closes the connection to the inventory service.
+func (c *GRPCClient) Close() error {
+	return c.conn.Close()
+}
+
+// OutOfStockProducts returns the products of items that no warehouse has
+// enough free stock of and that can't be backordered. The inventory service
+// sums the quantities of items of the same product.
+func (c *GRPCClient) OutOfStockProducts(ctx context.Context, items []domain.OrderItem) ([]uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req := &inventorypb.CheckAvailabilityRequest{Items: make([]*inventorypb.Item, len(items))}
+	for i, item := range items {
+		req.Items[i] = &inventorypb.Item{ProductId: item.ProductID.String(), Quantity: int32(item.Quantity)}
+	}
+	resp, err := c.client.CheckAvailability(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check availability: %w", err)
+	}
+	if resp.GetAvailable() {
+		return nil, nil
+	}
+
+	var outOfStock []uuid.UUID
+	for _, item := range resp.GetItems() {
+		if item.GetAvailable() || item.GetBackorderable() {
+			continue
+		}
+		productID, err := uuid.Parse(item.GetProductId())
+		if err != nil {
+			return nil, fmt.Errorf("inventory service returned invalid product ID %q: %w", item.GetProductId(), err)
+		}
+		outOfStock = append(outOfStock, productID)
+	}
+	return outOfStock, nil
+}
@@ -0,0 +1,83 @@
+package inventory_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/inventorypb"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/inventory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeInventory answers CheckAvailability with resp, or fails with err.
+type fakeInventory struct {
+	inventorypb.UnimplementedInventoryServiceServer
+	resp *inventorypb.CheckAvailabilityResponse
+	err  error
+	got  *inventorypb.CheckAvailabilityRequest
+}
+
+func (f *fakeInventory) CheckAvailability(_ context.Context, req *inventorypb.CheckAvailabilityRequest) (*inventorypb.CheckAvailabilityResponse, error) {
+	f.got = req
+	return f.resp, f.err
+}
+
+// newClient serves fake on a local port and returns a client for it.
+func newClient(t *testing.T, fake *fakeInventory) *inventory.GRPCClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	inventorypb.RegisterInventoryServiceServer(srv, fake)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	client, err := inventory.NewGRPCClient(lis.Addr().String(), time.Second)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestGRPCClient_OutOfStockProducts(t *testing.T) {
+	mug, lamp, sofa := uuid.New(), uuid.New(), uuid.New()
+	items := []domain.OrderItem{{ProductID: mug, Quantity: 2}, {ProductID: lamp, Quantity: 1}, {ProductID: sofa, Quantity: 1}}
+	ctx := context.Background()
+
+	t.Run("products neither available nor backorderable are out of stock", func(t *testing.T) {
+		fake := &fakeInventory{resp: &inventorypb.CheckAvailabilityResponse{Items: []*inventorypb.ItemAvailability{
+			{ProductId: mug.String(), Requested: 2, Free: 5, Available: true},
+			{ProductId: lamp.String(), Requested: 1},
+			{ProductId: sofa.String(), Requested: 1, Backorderable: true},
+		}}}
+		outOfStock, err := newClient(t, fake).OutOfStockProducts(ctx, items)
+
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{lamp}, outOfStock)
+		require.Len(t, fake.got.GetItems(), 3)
+		assert.Equal(t, mug.String(), fake.got.GetItems()[0].GetProductId())
+		assert.Equal(t, int32(2), fake.got.GetItems()[0].GetQuantity())
+	})
+
+	t.Run("available order", func(t *testing.T) {
+		fake := &fakeInventory{resp: &inventorypb.CheckAvailabilityResponse{Available: true}}
+		outOfStock, err := newClient(t, fake).OutOfStockProducts(ctx, items)
+
+		require.NoError(t, err)
+		assert.Empty(t, outOfStock)
+	})
+
+	t.Run("inventory service failure", func(t *testing.T) {
+		fake := &fakeInventory{err: status.Error(codes.Internal, "internal error")}
+		_, err := newClient(t, fake).OutOfStockProducts(ctx, items)
+
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+}
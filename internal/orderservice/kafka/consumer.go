@@ -0,0 +1,145 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/dlq"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// MessageHandler processes a single message. A message the handler keeps
+// failing on is dead-lettered before its offset is committed; see Consume.
+type MessageHandler = broker.MessageHandler
+
+// messageReader is the part of *kafka.Reader used by Consumer.
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Config() kafka.ReaderConfig
+	Close() error
+}
+
+// Consumer reads messages from a Kafka topic as part of a consumer group.
+type Consumer struct {
+	reader      messageReader
+	retry       dlq.RetryConfig
+	deadLetters dlq.Writer
+}
+
+// NewConsumer creates a new Kafka consumer.
+func NewConsumer(brokers []string, topic, groupID string, opts ...Option) *Consumer {
+	o := buildOptions(opts)
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        brokers,
+		Topic:          topic,
+		GroupID:        groupID,
+		MinBytes:       10e3, // 10KB
+		MaxBytes:       10e6, // 10MB
+		MaxWait:        1 * time.Second,
+		CommitInterval: 0, // Commit synchronously, so commit failures are reported
+		IsolationLevel: kafka.ReadCommitted,
+		Logger:         kafka.LoggerFunc(log.Printf),
+		ErrorLogger:    kafka.LoggerFunc(log.Printf),
+		Dialer:         o.auth.Dialer(dialTimeout),
+	})
+	return &Consumer{reader: reader, retry: o.consumeRetry, deadLetters: dlq.NewWriter(brokers, o.auth.Transport())}
+}
+
+var _ broker.EventSubscriber = (*Consumer)(nil)
+
+// Consume fetches messages and passes them to handler until ctx is cancelled.
+// A message the handler fails on is retried with backoff and, once the
+// attempts set by WithConsumeRetry are used up, written to the topic's
+// dead-letter topic (see package dlq). Its offset is only committed once it
+// was handled or dead-lettered, so no message is lost to a transient
+// failure. Cancelling ctx stops fetching but lets the attempt in flight
+// finish; a message that has not been handled or dead-lettered by then is
+// left uncommitted, to be delivered again. Consume returns only once that
+// work is done.
+func (c *Consumer) Consume(ctx context.Context, handler MessageHandler) {
+	cfg := c.reader.Config()
+	processCtx := context.WithoutCancel(ctx)
+	log.Info().Str("topic", cfg.Topic).Str("group_id", cfg.GroupID).Msg("Starting Kafka consumer")
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Info().Str("topic", cfg.Topic).Msg("Kafka consumer context cancelled. Shutting down.")
+				return
+			}
+			log.Error().Err(err).Str("topic", cfg.Topic).Msg("Error fetching message")
+			time.Sleep(time.Second)
+			continue
+		}
+
+		if group := replayGroup(msg); group != "" && group != cfg.GroupID {
+			// A replay addressed to another consumer group.
+			if err := c.reader.CommitMessages(processCtx, msg); err != nil {
+				metrics.KafkaCommitFailuresTotal.WithLabelValues(msg.Topic).Inc()
+				log.Error().Err(err).Str("topic", msg.Topic).Msg("Error committing offset")
+			}
+			continue
+		}
+
+		metrics.KafkaMessagesConsumedTotal.WithLabelValues(msg.Topic).Inc()
+		start := time.Now()
+		processingStatus := "success"
+
+		msgCtx, span := tracing.StartConsumerSpan(logging.MessageContext(processCtx, msg), tracerName, msg)
+		// Retries and dead-lettering stop at shutdown, unlike the attempt in flight.
+		retryCtx, stopRetries := context.WithCancel(msgCtx)
+		stop := context.AfterFunc(ctx, stopRetries)
+		deadLettered, err := dlq.Process(retryCtx, msg, c.retry, c.deadLetters, func(context.Context) error {
+			err := handler(msgCtx, msg.Key, msg.Value)
+			if err != nil {
+				span.RecordError(err)
+			}
+			return err
+		})
+		stop()
+		stopRetries()
+		if err != nil || deadLettered {
+			processingStatus = "failure"
+			span.SetStatus(codes.Error, "handler failed")
+		}
+		if deadLettered {
+			metrics.KafkaMessagesDeadLetteredTotal.WithLabelValues(msg.Topic).Inc()
+		}
+		span.End()
+		metrics.KafkaMessageProcessingDuration.WithLabelValues(msg.Topic, processingStatus).Observe(time.Since(start).Seconds())
+		if err != nil {
+			log.Ctx(msgCtx).Warn().Msg("Shutting down before the message was handled; leaving it uncommitted for redelivery")
+			return
+		}
+
+		if err := c.reader.CommitMessages(processCtx, msg); err != nil {
+			metrics.KafkaCommitFailuresTotal.WithLabelValues(msg.Topic).Inc()
+			log.Ctx(msgCtx).Error().Err(err).Msg("Error committing offset")
+		}
+	}
+}
+
+// replayGroup returns the consumer group a replayed message is addressed
+// to, or "" if it is meant for every group.
+func replayGroup(msg kafka.Message) string {
+	return tracing.HeaderCarrier{Headers: &msg.Headers}.Get(events.HeaderReplayGroup)
+}
+
+// Close closes the Kafka consumer connection and its dead-letter writer.
+func (c *Consumer) Close() error {
+	log.Info().Msg("Closing Kafka consumer...")
+	err := c.reader.Close()
+	if w, ok := c.deadLetters.(*kafka.Writer); ok {
+		err = errors.Join(err, w.Close())
+	}
+	return err
+}
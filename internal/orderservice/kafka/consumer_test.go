@@ -0,0 +1,158 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/dlq"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReader serves the messages sent on msgs and records committed offsets.
+type fakeReader struct {
+	msgs chan kafka.Message
+
+	mu      sync.Mutex
+	commits []int64
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-r.msgs:
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msg := range msgs {
+		r.commits = append(r.commits, msg.Offset)
+	}
+	return nil
+}
+
+func (r *fakeReader) committed() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int64(nil), r.commits...)
+}
+
+func (r *fakeReader) Config() kafka.ReaderConfig {
+	return kafka.ReaderConfig{Topic: events.TopicInventoryEvents, GroupID: "order-service-group"}
+}
+func (r *fakeReader) Close() error { return nil }
+
+// deadLetterWriter records dead-lettered messages, or fails while err is set.
+type deadLetterWriter struct {
+	mu      sync.Mutex
+	err     error
+	written []kafka.Message
+}
+
+func (w *deadLetterWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *deadLetterWriter) deadLettered() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]kafka.Message(nil), w.written...)
+}
+
+func newTestConsumer() (*Consumer, *fakeReader, *deadLetterWriter) {
+	reader := &fakeReader{msgs: make(chan kafka.Message, 10)}
+	writer := &deadLetterWriter{}
+	retry := dlq.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	return &Consumer{reader: reader, retry: retry, deadLetters: writer}, reader, writer
+}
+
+func TestConsumer_RetriesAndDeadLettersFailingMessages(t *testing.T) {
+	c, reader, writer := newTestConsumer()
+	deadLetteredTotal := metrics.KafkaMessagesDeadLetteredTotal.WithLabelValues(events.TopicInventoryEvents)
+	before := testutil.ToFloat64(deadLetteredTotal)
+	reader.msgs <- kafka.Message{Topic: events.TopicInventoryEvents, Offset: 0, Key: []byte("flaky")}
+	reader.msgs <- kafka.Message{Topic: events.TopicInventoryEvents, Offset: 1, Key: []byte("poison")}
+	reader.msgs <- kafka.Message{Topic: events.TopicInventoryEvents, Offset: 2, Key: []byte("ok")}
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Consume(ctx, func(_ context.Context, key, _ []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts[string(key)]++
+			switch {
+			case string(key) == "flaky" && attempts["flaky"] == 1:
+				return errors.New("db down")
+			case string(key) == "poison":
+				return errors.New("invalid event")
+			}
+			return nil
+		})
+	}()
+
+	require.Eventually(t, func() bool { return len(reader.committed()) == 3 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, []int64{0, 1, 2}, reader.committed())
+	assert.Equal(t, map[string]int{"flaky": 2, "poison": 3, "ok": 1}, attempts)
+	deadLettered := writer.deadLettered()
+	require.Len(t, deadLettered, 1)
+	assert.Equal(t, "inventory.events.dlq", deadLettered[0].Topic)
+	assert.Equal(t, []byte("poison"), deadLettered[0].Key)
+	assert.Equal(t, before+1, testutil.ToFloat64(deadLetteredTotal))
+}
+
+func TestConsumer_LeavesMessageUncommittedUntilDeadLettered(t *testing.T) {
+	c, reader, writer := newTestConsumer()
+	writer.err = errors.New("kafka: leader not available")
+	reader.msgs <- kafka.Message{Topic: events.TopicInventoryEvents, Offset: 0}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handled := make(chan struct{}, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Consume(ctx, func(context.Context, []byte, []byte) error {
+			handled <- struct{}{}
+			return errors.New("db down")
+		})
+	}()
+
+	for range 3 {
+		<-handled
+	}
+	// The dead-letter topic is unavailable, so the message is held back
+	// until shutdown and then left to be delivered again.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("consumer did not stop retrying at shutdown")
+	}
+
+	assert.Empty(t, reader.committed())
+	assert.Empty(t, writer.deadLettered())
+}
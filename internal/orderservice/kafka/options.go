@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/dlq"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/kafkaauth"
+	"github.com/segmentio/kafka-go"
+)
+
+// dialTimeout bounds connection setup, including the SASL handshake.
+const dialTimeout = 10 * time.Second
+
+// Option configures the Kafka clients created by this package.
+type Option func(*options)
+
+type options struct {
+	auth         *kafkaauth.Auth
+	requiredAcks kafka.RequiredAcks
+	breaker      *circuitbreaker.Breaker
+	retry        RetryConfig
+	consumeRetry dlq.RetryConfig
+}
+
+// WithAuth connects to the brokers with the given SASL and TLS settings.
+func WithAuth(auth *kafkaauth.Auth) Option {
+	return func(o *options) {
+		o.auth = auth
+	}
+}
+
+// WithRequiredAcks sets how many replicas must acknowledge a write before a
+// producer considers it successful. The default is kafka.RequireOne.
+func WithRequiredAcks(acks kafka.RequiredAcks) Option {
+	return func(o *options) {
+		o.requiredAcks = acks
+	}
+}
+
+// WithCircuitBreaker makes a producer fail fast with a
+// circuitbreaker.OpenError while breaker is open.
+func WithCircuitBreaker(breaker *circuitbreaker.Breaker) Option {
+	return func(o *options) {
+		o.breaker = breaker
+	}
+}
+
+// WithConsumeRetry sets how often a consumer tries a message its handler
+// fails on before dead-lettering it. The default is dlq.DefaultRetry.
+func WithConsumeRetry(cfg dlq.RetryConfig) Option {
+	return func(o *options) {
+		o.consumeRetry = cfg
+	}
+}
+
+func buildOptions(opts []Option) options {
+	o := options{requiredAcks: kafka.RequireOne, retry: defaultRetry, consumeRetry: dlq.DefaultRetry}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
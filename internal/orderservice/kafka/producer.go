@@ -5,50 +5,110 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
 	"github.com/rs/zerolog/log"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/codes"
 )
 
-type KafkaProducer interface {
-	PublishMessage(ctx context.Context, key, value []byte) error
+const tracerName = "github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/kafka"
+
+// messageWriter is the part of *kafka.Writer a Producer uses.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
 	Close() error
 }
 
 type Producer struct {
-	writer *kafka.Writer
+	writer  messageWriter
+	topic   string
+	breaker *circuitbreaker.Breaker
+	retry   RetryConfig
 }
 
-func NewProducer(brokers []string, topic string) *Producer {
+func NewProducer(brokers []string, topic string, opts ...Option) *Producer {
+	o := buildOptions(opts)
 	writer := &kafka.Writer{
 		Addr:         kafka.TCP(brokers...),
 		Topic:        topic,
 		Balancer:     &kafka.LeastBytes{},
-		RequiredAcks: kafka.RequiredAcks(1),
-		MaxAttempts:  3,
+		RequiredAcks: o.requiredAcks,
+		// PublishMessage retries failed writes itself, as RetryConfig says.
+		MaxAttempts:  1,
 		WriteTimeout: 5 * time.Second,
 		BatchTimeout: 1 * time.Second,
 		BatchSize:    100,
 		Logger:       kafka.LoggerFunc(log.Printf),
 		ErrorLogger:  kafka.LoggerFunc(log.Printf),
+		Transport:    o.auth.Transport(),
 	}
-	return &Producer{writer: writer}
+	return &Producer{writer: writer, topic: topic, breaker: o.breaker, retry: o.retry}
 }
 
-// PublishMessage sends a key-value message to the Kafka topic.
+var _ broker.EventPublisher = (*Producer)(nil)
+
+// PublishMessage sends a key-value message to the Kafka topic. Writes that
+// fail with a retryable error are retried following the producer's
+// RetryConfig; the circuit breaker sees the outcome of the whole call.
 func (p *Producer) PublishMessage(ctx context.Context, key, value []byte) error {
 	msg := kafka.Message{
 		Key:   key,
 		Value: value,
 		Time:  time.Now(),
 	}
+	logging.InjectKafkaHeader(ctx, &msg)
+	ctx, span := tracing.StartProducerSpan(ctx, tracerName, p.topic, &msg)
+	defer span.End()
 
-	err := p.writer.WriteMessages(ctx, msg)
+	if err := p.breaker.Allow(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "circuit breaker open")
+		return err
+	}
+	start := time.Now()
+	err := p.write(ctx, msg, start)
+	p.breaker.Done(err)
+	metrics.KafkaPublishDuration.WithLabelValues(p.topic).Observe(time.Since(start).Seconds())
 	if err != nil {
+		metrics.KafkaPublishFailuresTotal.WithLabelValues(p.topic).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
 		return fmt.Errorf("failed to write message to Kafka: %w", err)
 	}
 	return nil
 }
 
+// write writes msg, retrying while the error is retryable, attempts remain
+// and the next attempt would start within MaxElapsed of start.
+func (p *Producer) write(ctx context.Context, msg kafka.Message, start time.Time) error {
+	for attempt := 1; ; attempt++ {
+		metrics.KafkaPublishAttemptsTotal.WithLabelValues(p.topic).Inc()
+		err := p.writer.WriteMessages(ctx, msg)
+		if err == nil || attempt >= p.retry.MaxAttempts || !isRetryable(err) {
+			return err
+		}
+		delay := p.retry.backoff(attempt)
+		if p.retry.MaxElapsed > 0 && time.Since(start)+delay > p.retry.MaxElapsed {
+			return err
+		}
+		metrics.KafkaPublishRetriesTotal.WithLabelValues(p.topic).Inc()
+		log.Ctx(ctx).Debug().Err(err).
+			Str("topic", p.topic).
+			Int("attempt", attempt).
+			Dur("backoff", delay).
+			Msg("Kafka: retrying publish")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
 // Close closes the Kafka producer connection.
 func (p *Producer) Close() error {
 	log.Info().Msg("Closing Kafka producer...")
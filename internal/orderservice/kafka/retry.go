@@ -0,0 +1,84 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// RetryConfig is the retry policy of PublishMessage. Failed writes that may
+// succeed later are retried with exponential backoff and full jitter until
+// MaxAttempts writes were made or MaxElapsed has passed.
+type RetryConfig struct {
+	MaxAttempts    int           `key:"max_attempts" env:"KAFKA_PUBLISH_MAX_ATTEMPTS" default:"5"`
+	InitialBackoff time.Duration `key:"initial_backoff" env:"KAFKA_PUBLISH_INITIAL_BACKOFF" default:"100ms"`
+	MaxBackoff     time.Duration `key:"max_backoff" env:"KAFKA_PUBLISH_MAX_BACKOFF" default:"2s"`
+	// MaxElapsed bounds the time spent on a message, retries included. Zero
+	// leaves it unbounded.
+	MaxElapsed time.Duration `key:"max_elapsed" env:"KAFKA_PUBLISH_MAX_ELAPSED" default:"10s"`
+}
+
+// defaultRetry is used by producers created without WithRetry.
+var defaultRetry = RetryConfig{
+	MaxAttempts:    5,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	MaxElapsed:     10 * time.Second,
+}
+
+// WithRetry sets the retry policy of a producer.
+func WithRetry(cfg RetryConfig) Option {
+	return func(o *options) {
+		o.retry = cfg
+	}
+}
+
+// backoff returns a random delay before the retry following attempt:
+// between zero and InitialBackoff doubled attempt-1 times, capped at
+// MaxBackoff.
+func (c RetryConfig) backoff(attempt int) time.Duration {
+	d := c.InitialBackoff
+	for i := 1; i < attempt && d < c.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, c.MaxBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d + 1)
+}
+
+// isRetryable reports whether a failed write may succeed if made again:
+// errors Kafka marks as temporary, timeouts and dropped connections.
+// Cancelled requests and errors about the message itself, such as its size,
+// are final.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) {
+		for _, err := range writeErrs {
+			if err != nil && !isRetryable(err) {
+				return false
+			}
+		}
+		return writeErrs.Count() > 0
+	}
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) {
+		return kafkaErr.Temporary()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
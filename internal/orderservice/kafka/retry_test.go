@@ -0,0 +1,119 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWriter fails the first len(errs) writes with errs, in order.
+type fakeWriter struct {
+	errs   []error
+	writes int
+}
+
+func (w *fakeWriter) WriteMessages(context.Context, ...kafka.Message) error {
+	w.writes++
+	if w.writes <= len(w.errs) {
+		return w.errs[w.writes-1]
+	}
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func newTestProducer(w messageWriter, cfg RetryConfig) *Producer {
+	return &Producer{writer: w, topic: "orders.placed", retry: cfg}
+}
+
+var fastRetry = RetryConfig{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     2 * time.Millisecond,
+	MaxElapsed:     time.Second,
+}
+
+func TestPublishMessage_RetriesRetryableErrors(t *testing.T) {
+	w := &fakeWriter{errs: []error{kafka.LeaderNotAvailable, io.EOF}}
+	p := newTestProducer(w, fastRetry)
+
+	require.NoError(t, p.PublishMessage(context.Background(), []byte("k"), []byte("v")))
+	assert.Equal(t, 3, w.writes)
+}
+
+func TestPublishMessage_StopsAfterMaxAttempts(t *testing.T) {
+	w := &fakeWriter{errs: []error{kafka.LeaderNotAvailable, kafka.LeaderNotAvailable, kafka.LeaderNotAvailable, nil}}
+	p := newTestProducer(w, fastRetry)
+
+	err := p.PublishMessage(context.Background(), []byte("k"), []byte("v"))
+	assert.ErrorIs(t, err, kafka.LeaderNotAvailable)
+	assert.Equal(t, 3, w.writes)
+}
+
+func TestPublishMessage_DoesNotRetryFinalErrors(t *testing.T) {
+	w := &fakeWriter{errs: []error{kafka.MessageSizeTooLarge}}
+	p := newTestProducer(w, fastRetry)
+
+	err := p.PublishMessage(context.Background(), []byte("k"), []byte("v"))
+	assert.ErrorIs(t, err, kafka.MessageSizeTooLarge)
+	assert.Equal(t, 1, w.writes)
+}
+
+func TestPublishMessage_StopsAtMaxElapsed(t *testing.T) {
+	w := &fakeWriter{errs: []error{io.EOF, io.EOF}}
+	cfg := fastRetry
+	cfg.MaxElapsed = time.Nanosecond
+	p := newTestProducer(w, cfg)
+
+	err := p.PublishMessage(context.Background(), []byte("k"), []byte("v"))
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 1, w.writes)
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"temporary kafka error", kafka.NotLeaderForPartition, true},
+		{"final kafka error", kafka.MessageSizeTooLarge, false},
+		{"connection reset", fmt.Errorf("write: %w", syscall.ECONNRESET), true},
+		{"connection closed", io.EOF, true},
+		{"cancelled", context.Canceled, false},
+		{"deadline exceeded", context.DeadlineExceeded, false},
+		{"retryable write errors", kafka.WriteErrors{kafka.LeaderNotAvailable, nil}, true},
+		{"mixed write errors", kafka.WriteErrors{kafka.LeaderNotAvailable, kafka.MessageSizeTooLarge}, false},
+		{"unknown error", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRetryable(tt.err))
+		})
+	}
+}
+
+func TestRetryConfigBackoff(t *testing.T) {
+	cfg := RetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, ceiling := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		for range 20 {
+			d := cfg.backoff(attempt)
+			assert.GreaterOrEqual(t, d, time.Duration(0))
+			assert.LessOrEqual(t, d, ceiling, "attempt %d", attempt)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/outbox"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/rs/zerolog/log"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// recoverTimeout bounds Recover, which the relay calls with a context
+	// that is never cancelled.
+	recoverTimeout = 30 * time.Second
+	// progressPartition is the partition of events.TopicOutboxProgress
+	// every progress record is written to.
+	progressPartition = 0
+	// progressWindow is how many offsets before the end of the progress
+	// partition Recover first looks for the last progress record.
+	progressWindow = 16
+	// recoveryKeySuffix is appended to the transactional ID to key the
+	// empty record Recover commits.
+	recoveryKeySuffix = ".recovery"
+)
+
+// txnClient is the part of *kgo.Client a TransactionalProducer uses.
+type txnClient interface {
+	ProducerID(ctx context.Context) (int64, int16, error)
+	BeginTransaction() error
+	ProduceSync(ctx context.Context, rs ...*kgo.Record) kgo.ProduceResults
+	AbortBufferedRecords(ctx context.Context) error
+	EndTransaction(ctx context.Context, commit kgo.TransactionEndTry) error
+	Close()
+}
+
+// TransactionalProducer publishes outbox batches in Kafka transactions for
+// the outbox relay. kafka-go can't produce transactionally, so it uses
+// franz-go. Each transaction ends with a progress record listing the
+// batch's outbox IDs, written to events.TopicOutboxProgress under the
+// transactional ID, from which Recover learns what the last committed
+// transaction published.
+type TransactionalProducer struct {
+	id        string
+	newClient func() (txnClient, error)
+	// readProgress returns the committed records of the progress partition
+	// from offset from up to and including offset until.
+	readProgress func(ctx context.Context, from, until int64) ([]*kgo.Record, error)
+	client       txnClient
+}
+
+var _ outbox.Transactor = (*TransactionalProducer)(nil)
+
+// NewTransactionalProducer creates a producer using transactionalID. It
+// connects on the first call to Recover.
+func NewTransactionalProducer(brokers []string, transactionalID string, opts ...Option) *TransactionalProducer {
+	o := buildOptions(opts)
+	clientOpts := append([]kgo.Opt{kgo.SeedBrokers(brokers...), kgo.DialTimeout(dialTimeout)}, o.auth.ClientOpts()...)
+	return &TransactionalProducer{
+		id: transactionalID,
+		newClient: func() (txnClient, error) {
+			return kgo.NewClient(append(slices.Clone(clientOpts),
+				kgo.TransactionalID(transactionalID),
+				kgo.RecordPartitioner(progressPartitioner{kgo.StickyKeyPartitioner(nil)}),
+			)...)
+		},
+		readProgress: func(ctx context.Context, from, until int64) ([]*kgo.Record, error) {
+			return readProgress(ctx, clientOpts, from, until)
+		},
+	}
+}
+
+// Recover connects with the transactional ID, which aborts the transaction
+// left open by its previous holder and fences that holder, and returns the
+// outbox IDs recorded by the last committed transaction.
+func (p *TransactionalProducer) Recover(ctx context.Context) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, recoverTimeout)
+	defer cancel()
+
+	if p.client != nil {
+		p.client.Close()
+		p.client = nil
+	}
+	client, err := p.newClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+	p.client = client
+	if _, _, err := client.ProducerID(ctx); err != nil {
+		return nil, fmt.Errorf("failed to initialize transactional ID %s: %w", p.id, err)
+	}
+
+	// The offset of an empty record committed now bounds the committed
+	// history: every earlier progress record is readable once it is.
+	if err := client.BeginTransaction(); err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	result := client.ProduceSync(ctx, &kgo.Record{Topic: events.TopicOutboxProgress, Key: []byte(p.id + recoveryKeySuffix)})
+	end, err := result.First()
+	if err != nil {
+		return nil, p.abort(ctx, fmt.Errorf("failed to write to %s: %w", events.TopicOutboxProgress, err))
+	}
+	if err := client.EndTransaction(ctx, kgo.TryCommit); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Only failed commits leave records after the last progress record, so
+	// it is nearly always just before end.
+	for window := int64(progressWindow); ; window *= 2 {
+		from := max(end.Offset-window, 0)
+		records, err := p.readProgress(ctx, from, end.Offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range slices.Backward(records) {
+			if string(record.Key) != p.id {
+				continue
+			}
+			var ids []int64
+			if err := json.Unmarshal(record.Value, &ids); err != nil {
+				return nil, fmt.Errorf("failed to decode progress record at offset %d: %w", record.Offset, err)
+			}
+			return ids, nil
+		}
+		if from == 0 {
+			return nil, nil
+		}
+	}
+}
+
+// PublishBatch publishes msgs and a progress record listing their IDs in one
+// transaction. Messages are partitioned by key, so each order's events keep
+// their order.
+func (p *TransactionalProducer) PublishBatch(ctx context.Context, msgs []repository.OutboxMessage) (err error) {
+	if p.client == nil {
+		return errors.New("transactional producer has not recovered its transactional ID")
+	}
+	records := make([]*kgo.Record, len(msgs))
+	spans := make([]trace.Span, len(msgs))
+	ids := make([]int64, len(msgs))
+	for i, msg := range msgs {
+		records[i], spans[i] = p.record(ctx, msg)
+		ids[i] = msg.ID
+	}
+	defer func() {
+		for _, span := range spans {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "transaction failed")
+			}
+			span.End()
+		}
+	}()
+	progress, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("failed to encode progress record: %w", err)
+	}
+
+	if err := p.client.BeginTransaction(); err != nil {
+		return fenced(fmt.Errorf("failed to begin transaction: %w", err))
+	}
+	if err := p.client.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		return p.abort(ctx, fmt.Errorf("failed to write messages to Kafka: %w", err))
+	}
+	// Written last, the progress record is only committed along with every
+	// message of its batch.
+	record := &kgo.Record{Topic: events.TopicOutboxProgress, Key: []byte(p.id), Value: progress}
+	if err := p.client.ProduceSync(ctx, record).FirstErr(); err != nil {
+		return p.abort(ctx, fmt.Errorf("failed to write to %s: %w", events.TopicOutboxProgress, err))
+	}
+	if err := p.client.EndTransaction(ctx, kgo.TryCommit); err != nil {
+		return fenced(fmt.Errorf("failed to commit transaction: %w", err))
+	}
+	return nil
+}
+
+// record returns the record carrying msg and the span tracing its publish.
+func (p *TransactionalProducer) record(ctx context.Context, msg repository.OutboxMessage) (*kgo.Record, trace.Span) {
+	carrier := propagation.MapCarrier{}
+	if msg.CorrelationID != "" {
+		carrier[logging.CorrelationIDKafkaHeader] = msg.CorrelationID
+	}
+	_, span := tracing.StartPublishSpan(ctx, tracerName, "kafka", msg.Topic, carrier)
+	keys := carrier.Keys()
+	sort.Strings(keys)
+	headers := make([]kgo.RecordHeader, len(keys))
+	for i, key := range keys {
+		headers[i] = kgo.RecordHeader{Key: key, Value: []byte(carrier[key])}
+	}
+	return &kgo.Record{Topic: msg.Topic, Key: msg.Key, Value: msg.Value, Headers: headers, Timestamp: time.Now()}, span
+}
+
+// abort aborts the transaction in progress after cause and returns cause.
+func (p *TransactionalProducer) abort(ctx context.Context, cause error) error {
+	if err := p.client.AbortBufferedRecords(ctx); err != nil {
+		log.Error().Err(err).Msg("Kafka: failed to abort buffered records")
+	}
+	if err := p.client.EndTransaction(ctx, kgo.TryAbort); err != nil {
+		log.Error().Err(err).Msg("Kafka: failed to abort transaction")
+	}
+	return fenced(cause)
+}
+
+// fenced wraps err with outbox.ErrFenced if another producer took the
+// transactional ID over.
+func fenced(err error) error {
+	if errors.Is(err, kerr.ProducerFenced) || errors.Is(err, kerr.InvalidProducerEpoch) {
+		return fmt.Errorf("%w: %w", outbox.ErrFenced, err)
+	}
+	return err
+}
+
+// Close closes the Kafka client. A transaction in progress is aborted by
+// the next producer recovering the transactional ID.
+func (p *TransactionalProducer) Close() error {
+	log.Info().Msg("Closing Kafka transactional producer...")
+	if p.client != nil {
+		p.client.Close()
+	}
+	return nil
+}
+
+// readProgress reads the committed records of the progress partition from
+// offset from up to and including offset until, which must be committed.
+func readProgress(ctx context.Context, clientOpts []kgo.Opt, from, until int64) ([]*kgo.Record, error) {
+	client, err := kgo.NewClient(append(slices.Clone(clientOpts),
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{
+			events.TopicOutboxProgress: {progressPartition: kgo.NewOffset().At(from)},
+		}),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+	)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+	defer client.Close()
+
+	var records []*kgo.Record
+	for {
+		fetches := client.PollFetches(ctx)
+		if errs := fetches.Errors(); len(errs) > 0 {
+			return nil, fmt.Errorf("failed to read %s: %w", events.TopicOutboxProgress, errs[0].Err)
+		}
+		for _, record := range fetches.Records() {
+			if record.Offset > until {
+				return records, nil
+			}
+			records = append(records, record)
+			if record.Offset == until {
+				return records, nil
+			}
+		}
+	}
+}
+
+// progressPartitioner writes every progress record to progressPartition,
+// whatever the topic's partition count, and partitions other records with
+// the wrapped partitioner.
+type progressPartitioner struct {
+	kgo.Partitioner
+}
+
+func (p progressPartitioner) ForTopic(topic string) kgo.TopicPartitioner {
+	if topic == events.TopicOutboxProgress {
+		return fixedPartition(progressPartition)
+	}
+	return p.Partitioner.ForTopic(topic)
+}
+
+// fixedPartition is a kgo.TopicPartitioner choosing the same partition for
+// every record.
+type fixedPartition int
+
+func (fixedPartition) RequiresConsistency(*kgo.Record) bool { return true }
+
+func (f fixedPartition) Partition(*kgo.Record, int) int { return int(f) }
@@ -0,0 +1,186 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/outbox"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// fakeTxnClient keeps the records of committed transactions in committed.
+// Produces fail with produceErr and commits with commitErr.
+type fakeTxnClient struct {
+	produceErr error
+	commitErr  error
+
+	inTxn     bool
+	txn       []*kgo.Record
+	committed []*kgo.Record
+	aborted   int
+	closed    bool
+	// offset is the next offset of the progress partition.
+	offset int64
+}
+
+func (c *fakeTxnClient) ProducerID(context.Context) (int64, int16, error) { return 1, 0, nil }
+
+func (c *fakeTxnClient) BeginTransaction() error {
+	c.inTxn = true
+	return nil
+}
+
+func (c *fakeTxnClient) ProduceSync(_ context.Context, rs ...*kgo.Record) kgo.ProduceResults {
+	results := make(kgo.ProduceResults, len(rs))
+	for i, r := range rs {
+		results[i] = kgo.ProduceResult{Record: r, Err: c.produceErr}
+		if c.produceErr != nil {
+			continue
+		}
+		if r.Topic == events.TopicOutboxProgress {
+			r.Offset = c.offset
+			c.offset++
+		}
+		c.txn = append(c.txn, r)
+	}
+	return results
+}
+
+func (c *fakeTxnClient) AbortBufferedRecords(context.Context) error { return nil }
+
+func (c *fakeTxnClient) EndTransaction(_ context.Context, commit kgo.TransactionEndTry) error {
+	c.inTxn = false
+	txn := c.txn
+	c.txn = nil
+	if commit == kgo.TryAbort {
+		c.aborted++
+		return nil
+	}
+	if c.commitErr != nil {
+		return c.commitErr
+	}
+	c.committed = append(c.committed, txn...)
+	return nil
+}
+
+func (c *fakeTxnClient) Close() { c.closed = true }
+
+// progress returns the committed records of the progress partition.
+func (c *fakeTxnClient) progress() []*kgo.Record {
+	var records []*kgo.Record
+	for _, r := range c.committed {
+		if r.Topic == events.TopicOutboxProgress {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+func newTestTransactionalProducer(client *fakeTxnClient) *TransactionalProducer {
+	return &TransactionalProducer{
+		id:        "order-service-outbox",
+		newClient: func() (txnClient, error) { return client, nil },
+		readProgress: func(_ context.Context, from, until int64) ([]*kgo.Record, error) {
+			var records []*kgo.Record
+			for _, r := range client.progress() {
+				if r.Offset >= from && r.Offset <= until {
+					records = append(records, r)
+				}
+			}
+			return records, nil
+		},
+	}
+}
+
+func TestTransactionalProducer_PublishBatch(t *testing.T) {
+	client := &fakeTxnClient{}
+	p := newTestTransactionalProducer(client)
+	ids, err := p.Recover(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	msgs := []repository.OutboxMessage{
+		{ID: 7, Topic: events.TopicOrdersPlaced, Key: []byte("order-1"), Value: []byte(`{"order_id":"order-1"}`), CorrelationID: "corr-1"},
+		{ID: 9, Topic: events.TopicOrdersPlaced, Key: []byte("order-2"), Value: []byte(`{"order_id":"order-2"}`)},
+	}
+	require.NoError(t, p.PublishBatch(context.Background(), msgs))
+
+	// The recovery record, then the batch followed by its progress record.
+	require.Len(t, client.committed, 4)
+	published := client.committed[1:3]
+	assert.Equal(t, "order-1", string(published[0].Key))
+	assert.Equal(t, `{"order_id":"order-1"}`, string(published[0].Value))
+	require.Len(t, published[0].Headers, 1)
+	assert.Equal(t, kgo.RecordHeader{Key: logging.CorrelationIDKafkaHeader, Value: []byte("corr-1")}, published[0].Headers[0])
+	assert.Equal(t, "order-2", string(published[1].Key))
+	progress := client.committed[3]
+	assert.Equal(t, "order-service-outbox", string(progress.Key))
+	assert.JSONEq(t, `[7, 9]`, string(progress.Value))
+
+	// The next holder of the transactional ID learns the batch was published.
+	ids, err = newTestTransactionalProducer(client).Recover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []int64{7, 9}, ids)
+}
+
+func TestTransactionalProducer_Recover_SearchesEarlierRecords(t *testing.T) {
+	client := &fakeTxnClient{}
+	client.committed = append(client.committed, &kgo.Record{Topic: events.TopicOutboxProgress, Key: []byte("order-service-outbox"), Value: []byte(`[1]`)})
+	for i := range 40 {
+		client.committed = append(client.committed, &kgo.Record{Topic: events.TopicOutboxProgress, Key: []byte(fmt.Sprintf("other-relay-%d", i)), Value: []byte(`[2]`), Offset: int64(i + 1)})
+	}
+	client.offset = 41
+
+	ids, err := newTestTransactionalProducer(client).Recover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, ids)
+}
+
+func TestTransactionalProducer_PublishBatch_Failures(t *testing.T) {
+	msgs := []repository.OutboxMessage{{ID: 1, Topic: events.TopicOrdersPlaced, Key: []byte("order-1"), Value: []byte(`{}`)}}
+
+	t.Run("failed writes abort the transaction", func(t *testing.T) {
+		client := &fakeTxnClient{}
+		p := newTestTransactionalProducer(client)
+		_, err := p.Recover(context.Background())
+		require.NoError(t, err)
+
+		client.produceErr = errors.New("not enough replicas")
+		err = p.PublishBatch(context.Background(), msgs)
+		require.ErrorContains(t, err, "not enough replicas")
+		assert.NotErrorIs(t, err, outbox.ErrFenced)
+		assert.Equal(t, 1, client.aborted)
+		assert.Len(t, client.committed, 1, "only the recovery record is committed")
+	})
+
+	t.Run("fenced producers report ErrFenced", func(t *testing.T) {
+		client := &fakeTxnClient{}
+		p := newTestTransactionalProducer(client)
+		_, err := p.Recover(context.Background())
+		require.NoError(t, err)
+
+		client.commitErr = kerr.ProducerFenced
+		err = p.PublishBatch(context.Background(), msgs)
+		assert.ErrorIs(t, err, outbox.ErrFenced)
+	})
+
+	t.Run("publishing needs a recovered ID", func(t *testing.T) {
+		p := newTestTransactionalProducer(&fakeTxnClient{})
+		assert.Error(t, p.PublishBatch(context.Background(), msgs))
+	})
+}
+
+func TestProgressPartitioner(t *testing.T) {
+	partitioner := progressPartitioner{kgo.StickyKeyPartitioner(nil)}
+	record := &kgo.Record{Key: []byte("order-service-outbox")}
+	assert.Equal(t, progressPartition, partitioner.ForTopic(events.TopicOutboxProgress).Partition(record, 6))
+	assert.True(t, partitioner.ForTopic(events.TopicOrdersPlaced).RequiresConsistency(record), "records are partitioned by key")
+}
@@ -27,4 +27,180 @@ var (
 		Help:    "Duration of order retrieval calls in seconds.",
 		Buckets: prometheus.DefBuckets,
 	}, []string{"status"})
+
+	OrderStatusTransitionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_status_transitions_total",
+		Help: "Total number of order status transitions, by target status and result.",
+	}, []string{"to", "result"})
+
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests in seconds, by method, route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	KafkaPublishAttemptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_publish_attempts_total",
+		Help: "Total number of Kafka write attempts, retries included, by topic.",
+	}, []string{"topic"})
+
+	KafkaPublishRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_publish_retries_total",
+		Help: "Total number of Kafka publish attempts made after a retryable failure, by topic.",
+	}, []string{"topic"})
+
+	KafkaPublishFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_publish_failures_total",
+		Help: "Total number of failed Kafka publishes, by topic.",
+	}, []string{"topic"})
+
+	KafkaPublishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_publish_duration_seconds",
+		Help:    "Duration of Kafka publish calls in seconds, by topic.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	KafkaMessagesConsumedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_consumed_total",
+		Help: "Total number of Kafka messages fetched, by topic.",
+	}, []string{"topic"})
+
+	KafkaMessageProcessingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_message_processing_duration_seconds",
+		Help:    "Duration of Kafka message handling in seconds, by topic and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic", "status"})
+
+	KafkaCommitFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_commit_failures_total",
+		Help: "Total number of failed Kafka offset commits, by topic.",
+	}, []string{"topic"})
+
+	KafkaMessagesDeadLetteredTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_dead_lettered_total",
+		Help: "Total number of Kafka messages written to a dead-letter topic after their handler kept failing, by topic.",
+	}, []string{"topic"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Duration of database statements in seconds, by query and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"query", "status"})
+
+	DBSlowQueriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_slow_queries_total",
+		Help: "Total number of database statements exceeding the slow-query threshold, by query.",
+	}, []string{"query"})
+
+	HTTPRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "Number of HTTP requests currently being served.",
+	})
+
+	OutboxPublishedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_published_total",
+		Help: "Total number of outbox messages published, by topic.",
+	}, []string{"topic"})
+
+	OutboxPublishFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_publish_failures_total",
+		Help: "Total number of failed outbox publish attempts, by topic.",
+	}, []string{"topic"})
+
+	OutboxDeliveryLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "outbox_delivery_latency_seconds",
+		Help:    "Time from storing an outbox message to publishing it, in seconds, by topic.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"topic"})
+
+	OutboxBacklog = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_backlog",
+		Help: "Number of outbox messages not yet published.",
+	})
+
+	OutboxOldestMessageAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "outbox_oldest_message_age_seconds",
+		Help: "Age of the oldest unpublished outbox message in seconds.",
+	})
+
+	ProjectionEventsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "projection_events_total",
+		Help: "Total number of order events applied to the read models.",
+	})
+
+	ProjectionLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "projection_lag_seconds",
+		Help: "Time from recording the last order event applied to the read models to applying it, in seconds; 0 once caught up.",
+	})
+
+	CircuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "circuit_breaker_state",
+		Help: "State of each dependency's circuit breaker: 0 closed, 1 half-open, 2 open.",
+	}, []string{"dependency"})
+
+	HTTPRequestsShedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_shed_total",
+		Help: "Total number of HTTP requests rejected with 503 without being served, by reason.",
+	}, []string{"reason"})
+
+	OrderCacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_cache_requests_total",
+		Help: "Total number of order lookups through the cache, by result: hit, miss or stale (served after the database failed).",
+	}, []string{"result"})
+
+	OrderCacheInvalidationsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_cache_invalidations_total",
+		Help: "Total number of cached orders dropped because a change to them had an unknown outcome.",
+	})
+
+	CustomerQuotaRejectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "customer_quota_rejections_total",
+		Help: "Total number of orders rejected because the customer exceeded a quota, by window.",
+	}, []string{"window"})
+
+	CustomerQuotaErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "customer_quota_errors_total",
+		Help: "Total number of orders accepted without a quota check because the quota store failed.",
+	})
+
+	OutOfStockRejectionsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "out_of_stock_rejections_total",
+		Help: "Total number of orders rejected because the inventory service had too little stock.",
+	})
+
+	InventoryCheckErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "inventory_check_errors_total",
+		Help: "Total number of orders accepted without a stock check because the inventory service failed.",
+	})
+
+	ShippingExceptionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shipping_exceptions_total",
+		Help: "Total number of shipping exceptions reported for orders, by carrier.",
+	}, []string{"carrier"})
+
+	DuplicateOrdersTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "duplicate_orders_total",
+		Help: "Total number of new orders repeating a recent identical order of the same customer, by action: reject or flag.",
+	}, []string{"action"})
+
+	DBTransactionRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_transaction_retries_total",
+		Help: "Total number of transactions run again after a serialization failure or deadlock, by operation.",
+	}, []string{"operation"})
+
+	OrderLockWaitDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "order_lock_wait_seconds",
+		Help:    "Time spent waiting for the lock of an order before mutating it.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	OrderLockTimeoutsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_lock_timeouts_total",
+		Help: "Total number of order mutations rejected because the order stayed locked by another mutation.",
+	})
 )
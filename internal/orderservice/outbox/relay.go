@@ -0,0 +1,279 @@
+// Package outbox publishes the events stored in the transactional outbox.
+// The order service writes events in the same transaction as the orders they
+// describe; the Relay publishes them to the message broker with a bounded pool of workers,
+// retrying with backoff until they are delivered. Delivery is at least once:
+// a relay that fails to record a publish publishes the message again, unless
+// it publishes to Kafka in transactions with a Transactor.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// Config configures the outbox relay.
+type Config struct {
+	// Enabled stores events in the outbox instead of publishing them while
+	// handling the request.
+	Enabled      bool          `key:"enabled" env:"OUTBOX_ENABLED" default:"true"`
+	Workers      int           `key:"workers" env:"OUTBOX_WORKERS" default:"4"`
+	BatchSize    int           `key:"batch_size" env:"OUTBOX_BATCH_SIZE" default:"100"`
+	PollInterval time.Duration `key:"poll_interval" env:"OUTBOX_POLL_INTERVAL" default:"500ms"`
+	// Lease is how long a claimed batch stays hidden from other relays.
+	Lease      time.Duration `key:"lease" env:"OUTBOX_LEASE" default:"30s"`
+	MaxBackoff time.Duration `key:"max_backoff" env:"OUTBOX_MAX_BACKOFF" default:"5m"`
+	// KafkaTransactionalID, when set, makes the relay publish each batch in
+	// a Kafka transaction with this ID. Every relay of an outbox uses the
+	// same ID, and only the one that started last publishes.
+	KafkaTransactionalID string `key:"kafka_transactional_id" env:"OUTBOX_KAFKA_TRANSACTIONAL_ID"`
+	// Relay selects what publishes the outbox: RelayInternal or
+	// RelayDebezium.
+	Relay string `key:"relay" env:"OUTBOX_RELAY" default:"internal"`
+}
+
+// Outbox publishers accepted by Config.Relay.
+const (
+	// RelayInternal publishes the outbox with the service's Relay.
+	RelayInternal = "internal"
+	// RelayDebezium leaves publishing to a Debezium connector with the
+	// outbox event router, which reads the messages from the write-ahead
+	// log; they are deleted as soon as they are inserted.
+	RelayDebezium = "debezium"
+)
+
+// errEarlierFailed defers a message whose predecessor with the same key
+// failed, so messages for an order are never published out of order.
+var errEarlierFailed = errors.New("an earlier message with the same key failed")
+
+// Relay publishes outbox messages to the message broker.
+type Relay struct {
+	store     repository.OutboxRepository
+	producers map[string]broker.EventPublisher
+	cfg       Config
+	wake      chan struct{}
+
+	// tx, when set, publishes whole batches instead of producers. The
+	// fields below it are only used by RelayBatch.
+	tx Transactor
+	// recovered is whether the relay holds the transactional ID.
+	recovered bool
+	// fencedAt is when another relay took the transactional ID over.
+	fencedAt time.Time
+	// unmarked holds the IDs of a committed batch not yet marked published.
+	unmarked []int64
+
+	// backlog and oldest hold the last measured backlog; oldest is a
+	// time.Duration.
+	backlog atomic.Int64
+	oldest  atomic.Int64
+}
+
+// Option configures a Relay.
+type Option func(*Relay)
+
+// WithTransactor makes the relay publish each batch with tx in a single
+// transaction, ignoring the producers and Workers.
+func WithTransactor(tx Transactor) Option {
+	return func(r *Relay) {
+		r.tx = tx
+	}
+}
+
+// NewRelay creates a Relay that publishes the messages in store with the
+// producer for each message's topic.
+func NewRelay(store repository.OutboxRepository, producers map[string]broker.EventPublisher, cfg Config, opts ...Option) *Relay {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 1
+	}
+	r := &Relay{
+		store:     store,
+		producers: producers,
+		cfg:       cfg,
+		wake:      make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Notify wakes the relay before its next poll. The order service calls it
+// after committing new messages so they are published without waiting for
+// the poll interval. It never blocks.
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes messages until ctx is cancelled. The batch in progress when
+// ctx is cancelled is finished first, so Run returns only once its results
+// are recorded.
+func (r *Relay) Run(ctx context.Context) {
+	log.Info().Int("workers", r.cfg.Workers).Int("batch_size", r.cfg.BatchSize).Bool("transactional", r.tx != nil).Msg("Starting outbox relay")
+	workCtx := context.WithoutCancel(ctx)
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		// Drain full batches back to back; wait for the next poll or a
+		// notification once the outbox is caught up.
+		for ctx.Err() == nil {
+			n, err := r.RelayBatch(workCtx)
+			if err != nil {
+				log.Error().Err(err).Msg("Outbox relay failed")
+				break
+			}
+			if n < r.cfg.BatchSize {
+				break
+			}
+		}
+		r.observeBacklog(workCtx)
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Outbox relay stopped")
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// RelayBatch claims one batch of due messages and publishes it, returning how
+// many messages were claimed. Messages are spread over the workers by key, so
+// the messages of one order are published in order by a single worker. It
+// must not be called concurrently.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	if r.tx != nil {
+		return r.relayTransaction(ctx)
+	}
+	msgs, err := r.store.ClaimOutbox(ctx, r.cfg.BatchSize, r.cfg.Lease)
+	if err != nil {
+		return 0, err
+	}
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	shards := make([][]repository.OutboxMessage, r.cfg.Workers)
+	for _, msg := range msgs {
+		h := fnv.New32a()
+		h.Write(msg.Key)
+		i := int(h.Sum32() % uint32(r.cfg.Workers))
+		shards[i] = append(shards[i], msg)
+	}
+
+	var mu sync.Mutex
+	var published []int64
+	var wg sync.WaitGroup
+	for _, shard := range shards {
+		if len(shard) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids := r.publishShard(ctx, shard)
+			mu.Lock()
+			published = append(published, ids...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return len(msgs), r.store.MarkOutboxPublished(ctx, published)
+}
+
+// publishShard publishes msgs in order and returns the IDs of the ones that
+// were published. Failed messages are scheduled for a retry.
+func (r *Relay) publishShard(ctx context.Context, msgs []repository.OutboxMessage) []int64 {
+	var published []int64
+	failedKeys := make(map[string]bool)
+	for _, msg := range msgs {
+		err := errEarlierFailed
+		if !failedKeys[string(msg.Key)] {
+			err = r.publish(ctx, msg)
+		}
+		if err == nil {
+			published = append(published, msg.ID)
+			metrics.OutboxPublishedTotal.WithLabelValues(msg.Topic).Inc()
+			metrics.OutboxDeliveryLatency.WithLabelValues(msg.Topic).Observe(time.Since(msg.CreatedAt).Seconds())
+			continue
+		}
+
+		failedKeys[string(msg.Key)] = true
+		r.retryLater(ctx, msg, err)
+	}
+	return published
+}
+
+// retryLater schedules another attempt to publish msg after a publish failed
+// with err.
+func (r *Relay) retryLater(ctx context.Context, msg repository.OutboxMessage, err error) {
+	metrics.OutboxPublishFailuresTotal.WithLabelValues(msg.Topic).Inc()
+	retryAt := time.Now().Add(r.backoff(msg.Attempts))
+	log.Warn().Err(err).
+		Int64("outbox_id", msg.ID).
+		Str("topic", msg.Topic).
+		Str("order_id", string(msg.Key)).
+		Int("attempts", msg.Attempts).
+		Time("retry_at", retryAt).
+		Msg("Outbox relay: publish failed, will retry")
+	if err := r.store.MarkOutboxFailed(ctx, msg.ID, err, retryAt); err != nil {
+		// The lease expires and the message is claimed again.
+		log.Error().Err(err).Int64("outbox_id", msg.ID).Msg("Outbox relay: failed to record publish failure")
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, msg repository.OutboxMessage) error {
+	producer, ok := r.producers[msg.Topic]
+	if !ok {
+		return errors.New("no producer for topic " + msg.Topic)
+	}
+	if msg.CorrelationID != "" {
+		ctx = logging.WithCorrelationID(ctx, msg.CorrelationID, nil)
+	}
+	return producer.PublishMessage(ctx, msg.Key, msg.Value)
+}
+
+// backoff returns the delay before the attempt after attempts: one second,
+// doubling with each attempt, capped at MaxBackoff.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := time.Second
+	for i := 1; i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, r.cfg.MaxBackoff)
+}
+
+// Backlog returns the number of unpublished messages and the age of the
+// oldest one, as last measured by Run.
+func (r *Relay) Backlog() (int, time.Duration) {
+	return int(r.backlog.Load()), time.Duration(r.oldest.Load())
+}
+
+func (r *Relay) observeBacklog(ctx context.Context) {
+	count, age, err := r.store.OutboxBacklog(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Outbox relay: failed to measure backlog")
+		return
+	}
+	r.backlog.Store(int64(count))
+	r.oldest.Store(int64(age))
+	metrics.OutboxBacklog.Set(float64(count))
+	metrics.OutboxOldestMessageAge.Set(age.Seconds())
+}
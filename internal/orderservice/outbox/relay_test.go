@@ -0,0 +1,184 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/outbox"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an in-memory outbox.
+type memoryStore struct {
+	mu        sync.Mutex
+	pending   []repository.OutboxMessage
+	published []int64
+	failed    map[int64]time.Time
+}
+
+func newMemoryStore(msgs ...repository.OutboxMessage) *memoryStore {
+	return &memoryStore{pending: msgs, failed: map[int64]time.Time{}}
+}
+
+func (s *memoryStore) ClaimOutbox(_ context.Context, limit int, _ time.Duration) ([]repository.OutboxMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(limit, len(s.pending))
+	claimed := s.pending[:n]
+	s.pending = s.pending[n:]
+	for i := range claimed {
+		claimed[i].Attempts++
+	}
+	return claimed, nil
+}
+
+func (s *memoryStore) MarkOutboxPublished(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.published = append(s.published, ids...)
+	return nil
+}
+
+func (s *memoryStore) MarkOutboxFailed(_ context.Context, id int64, _ error, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed[id] = retryAt
+	return nil
+}
+
+func (s *memoryStore) OutboxBacklog(context.Context) (int, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending), 0, nil
+}
+
+// recordingProducer records published keys and correlation IDs, failing for
+// the keys in fail.
+type recordingProducer struct {
+	mu             sync.Mutex
+	keys           []string
+	correlationIDs []string
+	fail           map[string]bool
+}
+
+func (p *recordingProducer) PublishMessage(ctx context.Context, key, _ []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail[string(key)] {
+		return errors.New("broker unavailable")
+	}
+	p.keys = append(p.keys, string(key))
+	p.correlationIDs = append(p.correlationIDs, logging.CorrelationID(ctx))
+	return nil
+}
+
+func (p *recordingProducer) Close() error { return nil }
+
+func message(id int64, topic, key string) repository.OutboxMessage {
+	return repository.OutboxMessage{ID: id, Topic: topic, Key: []byte(key), Value: []byte(`{}`), CreatedAt: time.Now()}
+}
+
+func TestRelay_RelayBatch(t *testing.T) {
+	msgs := []repository.OutboxMessage{
+		message(1, "orders.placed", "a"),
+		message(2, "orders.placed", "b"),
+		message(3, "orders.placed", "a"),
+		message(4, "orders.placed", "c"),
+	}
+	msgs[0].CorrelationID = "req-1"
+	store := newMemoryStore(msgs...)
+	producer := &recordingProducer{}
+	relay := outbox.NewRelay(store, map[string]broker.EventPublisher{"orders.placed": producer}, outbox.Config{Workers: 3, BatchSize: 10})
+
+	n, err := relay.RelayBatch(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, n)
+	assert.ElementsMatch(t, []int64{1, 2, 3, 4}, store.published)
+	assert.Empty(t, store.failed)
+	assert.ElementsMatch(t, []string{"a", "b", "a", "c"}, producer.keys)
+
+	// Messages with the same key keep their order.
+	var aCorrelationIDs []string
+	for i, key := range producer.keys {
+		if key == "a" {
+			aCorrelationIDs = append(aCorrelationIDs, producer.correlationIDs[i])
+		}
+	}
+	assert.Equal(t, []string{"req-1", ""}, aCorrelationIDs)
+}
+
+func TestRelay_RelayBatch_Failures(t *testing.T) {
+	store := newMemoryStore(
+		message(1, "orders.placed", "a"),
+		message(2, "orders.placed", "b"),
+		message(3, "orders.placed", "a"),
+		message(4, "unknown.topic", "c"),
+	)
+	producer := &recordingProducer{fail: map[string]bool{"a": true}}
+	relay := outbox.NewRelay(store, map[string]broker.EventPublisher{"orders.placed": producer}, outbox.Config{
+		Workers:    2,
+		BatchSize:  10,
+		MaxBackoff: time.Minute,
+	})
+
+	before := time.Now()
+	_, err := relay.RelayBatch(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []int64{2}, store.published)
+	assert.Equal(t, []string{"b"}, producer.keys)
+	// Message 3 is deferred behind message 1 without being attempted.
+	require.Len(t, store.failed, 3)
+	for _, id := range []int64{1, 3, 4} {
+		assert.WithinDuration(t, before.Add(time.Second), store.failed[id], 500*time.Millisecond)
+	}
+}
+
+func TestRelay_Run(t *testing.T) {
+	store := newMemoryStore()
+	for i := int64(1); i <= 25; i++ {
+		store.pending = append(store.pending, message(i, "orders.placed", "order"))
+	}
+	producer := &recordingProducer{}
+	relay := outbox.NewRelay(store, map[string]broker.EventPublisher{"orders.placed": producer}, outbox.Config{
+		Workers:      2,
+		BatchSize:    10,
+		PollInterval: time.Hour,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		relay.Run(ctx)
+	}()
+
+	// Full batches are drained without waiting for the poll interval, and
+	// Notify wakes the relay for messages added later.
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.published) == 25
+	}, time.Second, 10*time.Millisecond)
+
+	store.mu.Lock()
+	store.pending = append(store.pending, message(26, "orders.placed", "order"))
+	store.mu.Unlock()
+	relay.Notify()
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.published) == 26
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
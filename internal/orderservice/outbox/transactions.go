@@ -0,0 +1,105 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrFenced reports that another relay recovered the transactional ID, so
+// the transaction in progress was aborted and this relay must stand by.
+var ErrFenced = errors.New("transactional ID taken over by another relay")
+
+// Transactor publishes batches of outbox messages atomically. Each
+// transaction also records the IDs of the messages it carries, so a relay
+// that crashes after committing a batch but before marking it published
+// doesn't publish it again: the relay recovering the transactional ID marks
+// it instead.
+type Transactor interface {
+	// Recover takes the transactional ID over, aborting the transaction
+	// another relay left open and fencing that relay, and returns the IDs of
+	// the messages the last committed transaction published.
+	Recover(ctx context.Context) ([]int64, error)
+	// PublishBatch publishes msgs in one transaction: consumers reading
+	// committed messages see all of them or none. It fails with ErrFenced
+	// once another relay has recovered the transactional ID.
+	PublishBatch(ctx context.Context, msgs []repository.OutboxMessage) error
+}
+
+// relayTransaction claims one batch and publishes it in a transaction. The
+// relay first recovers the transactional ID unless it holds it, and marks the
+// last committed batch published before claiming another, so at most one
+// committed batch is ever unmarked.
+func (r *Relay) relayTransaction(ctx context.Context) (int, error) {
+	if !r.recovered {
+		if r.standingBy() {
+			return 0, nil
+		}
+		ids, err := r.tx.Recover(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to recover transactional producer: %w", err)
+		}
+		r.recovered, r.fencedAt = true, time.Time{}
+		r.unmarked = ids
+		log.Info().Int("committed_messages", len(ids)).Msg("Outbox relay: transactional producer recovered")
+	}
+	if len(r.unmarked) > 0 {
+		if err := r.store.MarkOutboxPublished(ctx, r.unmarked); err != nil {
+			return 0, err
+		}
+		r.unmarked = nil
+	}
+
+	msgs, err := r.store.ClaimOutbox(ctx, r.cfg.BatchSize, r.cfg.Lease)
+	if err != nil {
+		return 0, err
+	}
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+	if err := r.tx.PublishBatch(ctx, msgs); err != nil {
+		// Whether a failed commit took effect is unknown until the
+		// transactional ID is recovered, which marks the batch if it did.
+		r.recovered = false
+		if errors.Is(err, ErrFenced) {
+			// The leased messages are claimed by the active relay once
+			// their lease expires.
+			r.fencedAt = time.Now()
+			log.Warn().Err(err).Msg("Outbox relay: another relay publishes the outbox, standing by")
+			return len(msgs), nil
+		}
+		for _, msg := range msgs {
+			r.retryLater(ctx, msg, err)
+		}
+		return len(msgs), nil
+	}
+
+	ids := make([]int64, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.ID
+		metrics.OutboxPublishedTotal.WithLabelValues(msg.Topic).Inc()
+		metrics.OutboxDeliveryLatency.WithLabelValues(msg.Topic).Observe(time.Since(msg.CreatedAt).Seconds())
+	}
+	r.unmarked = ids
+	if err := r.store.MarkOutboxPublished(ctx, ids); err != nil {
+		return len(msgs), err
+	}
+	r.unmarked = nil
+	return len(msgs), nil
+}
+
+// standingBy reports whether a fenced relay should leave publishing to the
+// relay that fenced it. It takes over once fenced for Lease while the oldest
+// message has waited longer than Lease, suggesting the other relay stopped.
+func (r *Relay) standingBy() bool {
+	if r.fencedAt.IsZero() {
+		return false
+	}
+	_, oldest := r.Backlog()
+	return time.Since(r.fencedAt) < r.cfg.Lease || oldest < r.cfg.Lease
+}
@@ -0,0 +1,105 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/outbox"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTransactor records the batches it publishes. Recover returns committed
+// and PublishBatch fails with the next error of errs.
+type fakeTransactor struct {
+	committed []int64
+	errs      []error
+	recovered int
+	batches   [][]int64
+}
+
+func (tx *fakeTransactor) Recover(context.Context) ([]int64, error) {
+	tx.recovered++
+	return tx.committed, nil
+}
+
+func (tx *fakeTransactor) PublishBatch(_ context.Context, msgs []repository.OutboxMessage) error {
+	if len(tx.errs) > 0 {
+		err := tx.errs[0]
+		tx.errs = tx.errs[1:]
+		return err
+	}
+	ids := make([]int64, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.ID
+	}
+	tx.batches = append(tx.batches, ids)
+	tx.committed = ids
+	return nil
+}
+
+func TestRelay_RelayBatch_Transactions(t *testing.T) {
+	store := newMemoryStore(
+		message(3, "orders.placed", "a"),
+		message(4, "orders.placed", "b"),
+		message(5, "orders.placed", "a"),
+	)
+	// Messages 1 and 2 were committed by a relay that crashed before
+	// marking them published.
+	tx := &fakeTransactor{committed: []int64{1, 2}}
+	relay := outbox.NewRelay(store, nil, outbox.Config{Workers: 4, BatchSize: 2, MaxBackoff: time.Minute}, outbox.WithTransactor(tx))
+
+	n, err := relay.RelayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []int64{1, 2, 3, 4}, store.published)
+	assert.Equal(t, [][]int64{{3, 4}}, tx.batches)
+
+	n, err = relay.RelayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, store.published)
+	assert.Equal(t, 1, tx.recovered)
+}
+
+func TestRelay_RelayBatch_TransactionFailures(t *testing.T) {
+	t.Run("the batch is retried and the ID recovered", func(t *testing.T) {
+		store := newMemoryStore(message(1, "orders.placed", "a"), message(2, "orders.placed", "b"))
+		tx := &fakeTransactor{errs: []error{errors.New("commit timed out")}}
+		relay := outbox.NewRelay(store, nil, outbox.Config{BatchSize: 10, MaxBackoff: time.Minute}, outbox.WithTransactor(tx))
+
+		before := time.Now()
+		_, err := relay.RelayBatch(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, store.published)
+		require.Len(t, store.failed, 2)
+		for _, id := range []int64{1, 2} {
+			assert.WithinDuration(t, before.Add(time.Second), store.failed[id], 500*time.Millisecond)
+		}
+
+		_, err = relay.RelayBatch(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, tx.recovered, "the outcome of the failed commit is recovered before the next batch")
+	})
+
+	t.Run("a fenced relay stands by", func(t *testing.T) {
+		store := newMemoryStore(message(1, "orders.placed", "a"), message(2, "orders.placed", "b"))
+		tx := &fakeTransactor{errs: []error{fmt.Errorf("%w: producer fenced", outbox.ErrFenced)}}
+		relay := outbox.NewRelay(store, nil, outbox.Config{BatchSize: 1, Lease: time.Minute}, outbox.WithTransactor(tx))
+
+		n, err := relay.RelayBatch(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Empty(t, store.failed, "the active relay publishes the message once its lease expires")
+
+		n, err = relay.RelayBatch(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, n)
+		assert.Equal(t, 1, tx.recovered)
+		assert.Len(t, store.pending, 1)
+	})
+}
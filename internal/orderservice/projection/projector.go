@@ -0,0 +1,85 @@
+// Package projection keeps the order read models up to date. The Projector
+// applies the events appended to the order streams by the event-sourced
+// repository to order_list_view and order_status_dashboard, which serve
+// listing, exports and statistics in place of the tables written with each
+// order. The read models are eventually consistent: they trail the streams by
+// up to the poll interval.
+package projection
+
+import (
+	"context"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// Config configures the projection worker.
+type Config struct {
+	// Enabled runs the worker and serves the queries over many orders from
+	// its read models. It needs event sourcing and migration 000010.
+	Enabled      bool          `key:"enabled" env:"PROJECTIONS_ENABLED" default:"false"`
+	BatchSize    int           `key:"batch_size" env:"PROJECTIONS_BATCH_SIZE" default:"500"`
+	PollInterval time.Duration `key:"poll_interval" env:"PROJECTIONS_POLL_INTERVAL" default:"500ms"`
+}
+
+// Projector applies order events to the read models.
+type Projector struct {
+	store repository.ProjectionRepository
+	cfg   Config
+}
+
+// NewProjector creates a Projector that applies the events with store.
+func NewProjector(store repository.ProjectionRepository, cfg Config) *Projector {
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 1
+	}
+	return &Projector{store: store, cfg: cfg}
+}
+
+// Run applies events until ctx is cancelled. Workers in several replicas
+// take turns, so each event is applied once.
+func (p *Projector) Run(ctx context.Context) {
+	log.Info().Int("batch_size", p.cfg.BatchSize).Dur("poll_interval", p.cfg.PollInterval).Msg("Starting projection worker")
+	ticker := time.NewTicker(p.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		// Apply full batches back to back until caught up.
+		for ctx.Err() == nil {
+			n, err := p.ProjectBatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Error().Err(err).Msg("Projection worker failed")
+				}
+				break
+			}
+			if n < p.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Projection worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProjectBatch applies one batch of events and returns its size. It must not
+// be called concurrently.
+func (p *Projector) ProjectBatch(ctx context.Context) (int, error) {
+	n, recordedAt, err := p.store.ProjectOrderEvents(ctx, p.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	metrics.ProjectionEventsTotal.Add(float64(n))
+	if n < p.cfg.BatchSize {
+		metrics.ProjectionLag.Set(0)
+	} else {
+		metrics.ProjectionLag.Set(time.Since(recordedAt).Seconds())
+	}
+	return n, nil
+}
@@ -0,0 +1,65 @@
+package projection_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/projection"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore has pending events to apply. ProjectOrderEvents fails with err
+// when set.
+type fakeStore struct {
+	pending int
+	err     error
+	calls   []int
+}
+
+func (s *fakeStore) ProjectOrderEvents(_ context.Context, limit int) (int, time.Time, error) {
+	s.calls = append(s.calls, limit)
+	if s.err != nil {
+		return 0, time.Time{}, s.err
+	}
+	n := min(limit, s.pending)
+	s.pending -= n
+	return n, time.Now(), nil
+}
+
+func TestProjector_ProjectBatch(t *testing.T) {
+	store := &fakeStore{pending: 5}
+	projector := projection.NewProjector(store, projection.Config{BatchSize: 3, PollInterval: time.Second})
+
+	n, err := projector.ProjectBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = projector.ProjectBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	store.err = errors.New("connection refused")
+	_, err = projector.ProjectBatch(context.Background())
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestProjector_Run_CatchesUp(t *testing.T) {
+	store := &fakeStore{pending: 7}
+	projector := projection.NewProjector(store, projection.Config{BatchSize: 3, PollInterval: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		projector.Run(ctx)
+	}()
+	require.Eventually(t, func() bool { return len(store.calls) > 0 }, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	// Full batches are applied back to back without waiting for the poll.
+	assert.Equal(t, []int{3, 3, 3}, store.calls)
+	assert.Zero(t, store.pending)
+}
@@ -0,0 +1,104 @@
+// Package quota limits how many orders each customer may place per minute and
+// per day, to contain abusive or broken integrations. The counters live in
+// Redis, so the limits hold across every order service instance.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// Config sets the per-customer order quotas.
+type Config struct {
+	Enabled bool `key:"enabled" env:"CUSTOMER_QUOTA_ENABLED" default:"false"`
+	// OrdersPerMinute and OrdersPerDay cap the orders a customer may place
+	// in each clock minute and each UTC day. Zero disables that limit.
+	OrdersPerMinute int `key:"orders_per_minute" env:"CUSTOMER_QUOTA_ORDERS_PER_MINUTE" default:"30"`
+	OrdersPerDay    int `key:"orders_per_day" env:"CUSTOMER_QUOTA_ORDERS_PER_DAY" default:"1000"`
+}
+
+// Quota windows, as reported in domain.QuotaExceededError.
+const (
+	WindowMinute = "minute"
+	WindowDay    = "day"
+)
+
+type window struct {
+	name   string
+	length time.Duration
+	limit  int
+}
+
+// reserveScript counts an order in every window's counter (KEYS), unless one
+// of them has already reached its limit. ARGV holds, for each key, the limit
+// and the counter's expiry in milliseconds. It returns the 1-based index of
+// the first exhausted window, or 0 if the order was counted.
+var reserveScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	if tonumber(redis.call('GET', key) or '0') >= tonumber(ARGV[2*i-1]) then
+		return i
+	end
+end
+for i, key in ipairs(KEYS) do
+	if redis.call('INCR', key) == 1 then
+		redis.call('PEXPIRE', key, ARGV[2*i])
+	end
+end
+return 0
+`)
+
+// RedisLimiter enforces the quotas with one fixed-window counter per customer
+// and window.
+type RedisLimiter struct {
+	client  redis.Scripter
+	windows []window
+	now     func() time.Time
+}
+
+// NewRedisLimiter creates a RedisLimiter enforcing the limits in cfg.
+func NewRedisLimiter(client redis.Scripter, cfg Config) *RedisLimiter {
+	l := &RedisLimiter{client: client, now: time.Now}
+	if cfg.OrdersPerMinute > 0 {
+		l.windows = append(l.windows, window{name: WindowMinute, length: time.Minute, limit: cfg.OrdersPerMinute})
+	}
+	if cfg.OrdersPerDay > 0 {
+		l.windows = append(l.windows, window{name: WindowDay, length: 24 * time.Hour, limit: cfg.OrdersPerDay})
+	}
+	return l
+}
+
+// Reserve counts an order for customerID. If the customer has used up one
+// of its quotas it returns a *domain.QuotaExceededError instead, and the
+// order is not counted.
+func (l *RedisLimiter) Reserve(ctx context.Context, customerID uuid.UUID) error {
+	if len(l.windows) == 0 {
+		return nil
+	}
+	now := l.now()
+	keys := make([]string, len(l.windows))
+	args := make([]any, 0, 2*len(l.windows))
+	for i, w := range l.windows {
+		start := now.Truncate(w.length)
+		keys[i] = fmt.Sprintf("order_quota:%s:%s:%d", customerID, w.name, start.Unix())
+		args = append(args, w.limit, w.length.Milliseconds())
+	}
+
+	exhausted, err := reserveScript.Run(ctx, l.client, keys, args...).Int()
+	if err != nil {
+		return fmt.Errorf("failed to reserve order quota: %w", err)
+	}
+	if exhausted == 0 {
+		return nil
+	}
+	w := l.windows[exhausted-1]
+	return &domain.QuotaExceededError{
+		Window:     w.name,
+		Limit:      w.limit,
+		RetryAfter: now.Truncate(w.length).Add(w.length).Sub(now),
+	}
+}
@@ -0,0 +1,90 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(t *testing.T, cfg Config) (*RedisLimiter, *miniredis.Miniredis, *time.Time) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	now := time.Date(2024, 1, 1, 10, 0, 15, 0, time.UTC)
+	l := NewRedisLimiter(client, cfg)
+	l.now = func() time.Time { return now }
+	return l, server, &now
+}
+
+func TestRedisLimiter_PerMinute(t *testing.T) {
+	l, _, now := newTestLimiter(t, Config{OrdersPerMinute: 2, OrdersPerDay: 100})
+	ctx := context.Background()
+	customerID := uuid.New()
+
+	require.NoError(t, l.Reserve(ctx, customerID))
+	require.NoError(t, l.Reserve(ctx, customerID))
+	err := l.Reserve(ctx, customerID)
+	var exceeded *domain.QuotaExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.ErrorIs(t, err, domain.ErrQuotaExceeded)
+	assert.Equal(t, WindowMinute, exceeded.Window)
+	assert.Equal(t, 2, exceeded.Limit)
+	assert.Equal(t, 45*time.Second, exceeded.RetryAfter)
+
+	// Other customers have their own quota.
+	assert.NoError(t, l.Reserve(ctx, uuid.New()))
+
+	// The next minute starts afresh.
+	*now = now.Add(45 * time.Second)
+	assert.NoError(t, l.Reserve(ctx, customerID))
+}
+
+func TestRedisLimiter_PerDay(t *testing.T) {
+	l, _, now := newTestLimiter(t, Config{OrdersPerMinute: 2, OrdersPerDay: 3})
+	ctx := context.Background()
+	customerID := uuid.New()
+
+	require.NoError(t, l.Reserve(ctx, customerID))
+	require.NoError(t, l.Reserve(ctx, customerID))
+	// Rejected orders do not use up the daily quota.
+	require.Error(t, l.Reserve(ctx, customerID))
+	*now = now.Add(time.Minute)
+	require.NoError(t, l.Reserve(ctx, customerID))
+
+	err := l.Reserve(ctx, customerID)
+	var exceeded *domain.QuotaExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, WindowDay, exceeded.Window)
+	assert.Equal(t, 13*time.Hour+58*time.Minute+45*time.Second, exceeded.RetryAfter)
+}
+
+func TestRedisLimiter_CountersExpire(t *testing.T) {
+	l, server, _ := newTestLimiter(t, Config{OrdersPerMinute: 5})
+	require.NoError(t, l.Reserve(context.Background(), uuid.New()))
+
+	keys := server.Keys()
+	require.Len(t, keys, 1)
+	assert.Equal(t, time.Minute, server.TTL(keys[0]))
+}
+
+func TestRedisLimiter_Unavailable(t *testing.T) {
+	l, server, _ := newTestLimiter(t, Config{OrdersPerMinute: 5})
+	server.Close()
+
+	err := l.Reserve(context.Background(), uuid.New())
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, domain.ErrQuotaExceeded)
+}
+
+func TestRedisLimiter_NoLimits(t *testing.T) {
+	l, server, _ := newTestLimiter(t, Config{})
+	assert.NoError(t, l.Reserve(context.Background(), uuid.New()))
+	assert.Empty(t, server.Keys())
+}
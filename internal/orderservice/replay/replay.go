@@ -0,0 +1,183 @@
+// Package replay republishes stored events, from the outbox or from the order
+// event streams, to rebuild read models and to seed new services. Replayed
+// messages carry the header replayed=true and, when the replay is addressed
+// to one consumer group, replay_group naming it.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/segmentio/kafka-go"
+	"golang.org/x/time/rate"
+)
+
+// Source selects where the replayed events are read from.
+type Source string
+
+const (
+	// SourceOutbox replays the messages the outbox relay published.
+	SourceOutbox Source = "outbox"
+	// SourceEvents replays the order event streams of the event-sourced
+	// repository.
+	SourceEvents Source = "events"
+)
+
+// ParseSource validates s and returns it as a Source.
+func ParseSource(s string) (Source, bool) {
+	switch Source(s) {
+	case SourceOutbox, SourceEvents:
+		return Source(s), true
+	default:
+		return "", false
+	}
+}
+
+// EventStore reads stored events, oldest first.
+// repository.ReplayRepository satisfies it.
+type EventStore interface {
+	StreamOutbox(ctx context.Context, filter repository.ReplayFilter, fn func(repository.StoredEvent) error) error
+	StreamOrderEvents(ctx context.Context, filter repository.ReplayFilter, fn func(repository.StoredEvent) error) error
+}
+
+// MessageWriter publishes messages; *kafka.Writer satisfies it.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// DefaultBatchSize is the number of messages published per write when
+// Options.BatchSize is zero.
+const DefaultBatchSize = 100
+
+// Options controls a replay.
+type Options struct {
+	// Source is where events are read from.
+	Source Source
+	// Filter selects the events by topic, type, order and time range.
+	Filter repository.ReplayFilter
+	// Target is the topic to publish to. When empty, outbox messages go back
+	// to the topic they were published to; order events need a target.
+	Target string
+	// Group addresses the replay to one consumer group, so that consumers in
+	// other groups of the target topic skip the messages.
+	Group string
+	// Rate caps the messages published per second. Zero does not limit.
+	Rate float64
+	// BatchSize is the number of messages published per write.
+	BatchSize int
+	// DryRun counts the matching events without publishing anything.
+	DryRun bool
+}
+
+// Summary reports what a replay did.
+type Summary struct {
+	Events    int
+	Published int
+}
+
+// Replayer republishes stored events.
+type Replayer struct {
+	store   EventStore
+	writer  MessageWriter
+	opts    Options
+	limiter *rate.Limiter
+}
+
+// New creates a Replayer reading from store and publishing with writer.
+func New(store EventStore, writer MessageWriter, opts Options) *Replayer {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if opts.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.Rate), 1)
+	}
+	return &Replayer{store: store, writer: writer, opts: opts, limiter: limiter}
+}
+
+// Run streams the matching events and publishes them in the order they were
+// stored. The correlation ID in ctx, if any, is attached to every message so
+// a replay can be traced as a whole.
+func (r *Replayer) Run(ctx context.Context) (Summary, error) {
+	var summary Summary
+	stream := r.store.StreamOutbox
+	replayedFrom := ""
+	switch r.opts.Source {
+	case SourceOutbox:
+	case SourceEvents:
+		if r.opts.Target == "" {
+			return summary, errors.New("replaying order events requires a target topic")
+		}
+		stream, replayedFrom = r.store.StreamOrderEvents, "order_events"
+	default:
+		return summary, fmt.Errorf("cannot replay from %q: expected %s or %s", r.opts.Source, SourceOutbox, SourceEvents)
+	}
+
+	batch := make([]kafka.Message, 0, r.opts.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if !r.opts.DryRun {
+			if err := r.writer.WriteMessages(ctx, batch...); err != nil {
+				return fmt.Errorf("failed to publish replayed events: %w", err)
+			}
+			summary.Published += len(batch)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err := stream(ctx, r.opts.Filter, func(event repository.StoredEvent) error {
+		summary.Events++
+		if r.opts.DryRun {
+			return nil
+		}
+		// Waiting per message spreads the batches out at the configured rate.
+		if err := r.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		batch = append(batch, r.message(ctx, event, replayedFrom))
+		if len(batch) >= r.opts.BatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+	return summary, flush()
+}
+
+// message builds the message replaying event. replayedFrom names the store
+// the event was read from, or is empty for outbox messages, which name the
+// topic they were first published to.
+func (r *Replayer) message(ctx context.Context, event repository.StoredEvent, replayedFrom string) kafka.Message {
+	if replayedFrom == "" {
+		replayedFrom = event.Topic
+	}
+	topic := r.opts.Target
+	if topic == "" {
+		topic = event.Topic
+	}
+	msg := kafka.Message{
+		Topic: topic,
+		Key:   event.Key,
+		Value: event.Value,
+		Time:  time.Now(),
+		Headers: []kafka.Header{
+			{Key: events.HeaderReplayed, Value: []byte("true")},
+			{Key: events.HeaderReplayedFrom, Value: []byte(replayedFrom)},
+		},
+	}
+	if r.opts.Group != "" {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: events.HeaderReplayGroup, Value: []byte(r.opts.Group)})
+	}
+	logging.InjectKafkaHeader(ctx, &msg)
+	return msg
+}
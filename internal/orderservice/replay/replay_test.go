@@ -0,0 +1,160 @@
+package replay_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/replay"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventStore streams outbox and order events, recording the filter.
+type eventStore struct {
+	outbox, orderEvents []repository.StoredEvent
+	filter              repository.ReplayFilter
+}
+
+func (s *eventStore) StreamOutbox(_ context.Context, filter repository.ReplayFilter, fn func(repository.StoredEvent) error) error {
+	return s.stream(s.outbox, filter, fn)
+}
+
+func (s *eventStore) StreamOrderEvents(_ context.Context, filter repository.ReplayFilter, fn func(repository.StoredEvent) error) error {
+	return s.stream(s.orderEvents, filter, fn)
+}
+
+func (s *eventStore) stream(stored []repository.StoredEvent, filter repository.ReplayFilter, fn func(repository.StoredEvent) error) error {
+	s.filter = filter
+	for _, event := range stored {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type recordingWriter struct {
+	writes [][]kafka.Message
+}
+
+func (w *recordingWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.writes = append(w.writes, append([]kafka.Message(nil), msgs...))
+	return nil
+}
+
+func newStoredEvents(n int, topic string) []repository.StoredEvent {
+	stored := make([]repository.StoredEvent, n)
+	for i := range stored {
+		stored[i] = repository.StoredEvent{
+			Topic:    topic,
+			Key:      []byte(uuid.NewString()),
+			Type:     events.OrderPlacedType,
+			Value:    []byte(fmt.Sprintf(`{"n":%d}`, i)),
+			StoredAt: time.Date(2025, 3, 1, i, 0, 0, 0, time.UTC),
+		}
+	}
+	return stored
+}
+
+func header(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestReplayer_Run_Outbox(t *testing.T) {
+	store := &eventStore{outbox: newStoredEvents(5, events.TopicOrdersPlaced)}
+	writer := &recordingWriter{}
+	filter := repository.ReplayFilter{Type: events.OrderPlacedType, From: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)}
+	ctx := logging.WithCorrelationID(context.Background(), "replay-1", nil)
+
+	summary, err := replay.New(store, writer, replay.Options{Source: replay.SourceOutbox, Filter: filter, BatchSize: 2}).Run(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, replay.Summary{Events: 5, Published: 5}, summary)
+	assert.Equal(t, filter, store.filter)
+	require.Len(t, writer.writes, 3)
+	assert.Len(t, writer.writes[0], 2)
+	assert.Len(t, writer.writes[2], 1)
+
+	msg := writer.writes[0][0]
+	// Without a target, outbox messages go back to their own topic.
+	assert.Equal(t, events.TopicOrdersPlaced, msg.Topic)
+	assert.Equal(t, store.outbox[0].Key, msg.Key)
+	assert.Equal(t, store.outbox[0].Value, msg.Value)
+	assert.Equal(t, "true", header(msg, events.HeaderReplayed))
+	assert.Equal(t, events.TopicOrdersPlaced, header(msg, events.HeaderReplayedFrom))
+	assert.Equal(t, "replay-1", header(msg, logging.CorrelationIDKafkaHeader))
+	assert.Empty(t, header(msg, events.HeaderReplayGroup))
+}
+
+func TestReplayer_Run_EventsToGroup(t *testing.T) {
+	store := &eventStore{orderEvents: newStoredEvents(3, "")}
+	writer := &recordingWriter{}
+
+	summary, err := replay.New(store, writer, replay.Options{
+		Source: replay.SourceEvents,
+		Target: "orders.history",
+		Group:  "order-history-group",
+	}).Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, replay.Summary{Events: 3, Published: 3}, summary)
+	require.Len(t, writer.writes, 1)
+	for _, msg := range writer.writes[0] {
+		assert.Equal(t, "orders.history", msg.Topic)
+		assert.Equal(t, "order_events", header(msg, events.HeaderReplayedFrom))
+		assert.Equal(t, "order-history-group", header(msg, events.HeaderReplayGroup))
+	}
+}
+
+func TestReplayer_Run_EventsNeedTarget(t *testing.T) {
+	store := &eventStore{orderEvents: newStoredEvents(1, "")}
+	_, err := replay.New(store, &recordingWriter{}, replay.Options{Source: replay.SourceEvents}).Run(context.Background())
+	assert.ErrorContains(t, err, "requires a target topic")
+
+	_, err = replay.New(store, &recordingWriter{}, replay.Options{Source: "wal"}).Run(context.Background())
+	assert.ErrorContains(t, err, `cannot replay from "wal"`)
+}
+
+func TestReplayer_Run_DryRun(t *testing.T) {
+	store := &eventStore{outbox: newStoredEvents(4, events.TopicOrdersPlaced)}
+	writer := &recordingWriter{}
+
+	summary, err := replay.New(store, writer, replay.Options{Source: replay.SourceOutbox, DryRun: true}).Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, replay.Summary{Events: 4}, summary)
+	assert.Empty(t, writer.writes)
+}
+
+func TestReplayer_Run_RateLimited(t *testing.T) {
+	store := &eventStore{outbox: newStoredEvents(3, events.TopicOrdersPlaced)}
+	writer := &recordingWriter{}
+
+	start := time.Now()
+	summary, err := replay.New(store, writer, replay.Options{Source: replay.SourceOutbox, Rate: 20}).Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, summary.Published)
+	// The first message is sent at once and each of the others 50ms later.
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestReplayer_Run_StopsWhenCancelled(t *testing.T) {
+	store := &eventStore{outbox: newStoredEvents(3, events.TopicOrdersPlaced)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	summary, err := replay.New(store, &recordingWriter{}, replay.Options{Source: replay.SourceOutbox, Rate: 1}).Run(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, summary.Published)
+}
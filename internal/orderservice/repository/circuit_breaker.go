@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+)
+
+// CircuitBreakerRepository guards an OrderRepository with a circuit breaker,
+// so that while the database is failing calls return a
+// circuitbreaker.OpenError at once instead of waiting for their timeout.
+type CircuitBreakerRepository struct {
+	repo    OrderRepository
+	breaker *circuitbreaker.Breaker
+}
+
+// NewCircuitBreakerRepository wraps repo with breaker.
+func NewCircuitBreakerRepository(repo OrderRepository, breaker *circuitbreaker.Breaker) *CircuitBreakerRepository {
+	return &CircuitBreakerRepository{repo: repo, breaker: breaker}
+}
+
+// isDatabaseFailure reports whether err means the database is unhealthy.
+// Missing orders, taken external references and rejected status transitions
+// are answers, not failures.
+func isDatabaseFailure(err error) bool {
+	return !errors.Is(err, domain.ErrOrderNotFound) &&
+		!errors.Is(err, domain.ErrExternalReferenceExists) &&
+		!errors.Is(err, domain.ErrInvalidOrderStatusTransition)
+}
+
+func (r *CircuitBreakerRepository) CreateOrder(ctx context.Context, order *domain.Order) error {
+	return r.breaker.Do(func() error {
+		return r.repo.CreateOrder(ctx, order)
+	}, isDatabaseFailure)
+}
+
+func (r *CircuitBreakerRepository) CreateOrderWithOutbox(ctx context.Context, order *domain.Order, msgs ...OutboxMessage) error {
+	return r.breaker.Do(func() error {
+		return r.repo.CreateOrderWithOutbox(ctx, order, msgs...)
+	}, isDatabaseFailure)
+}
+
+func (r *CircuitBreakerRepository) EnqueueOutbox(ctx context.Context, msgs ...OutboxMessage) error {
+	return r.breaker.Do(func() error {
+		return r.repo.EnqueueOutbox(ctx, msgs...)
+	}, isDatabaseFailure)
+}
+
+func (r *CircuitBreakerRepository) GetOrderByID(ctx context.Context, id uuid.UUID) (order *domain.Order, err error) {
+	err = r.breaker.Do(func() error {
+		order, err = r.repo.GetOrderByID(ctx, id)
+		return err
+	}, isDatabaseFailure)
+	return order, err
+}
+
+func (r *CircuitBreakerRepository) GetOrderByExternalReference(ctx context.Context, ref domain.ExternalReference) (order *domain.Order, err error) {
+	err = r.breaker.Do(func() error {
+		order, err = r.repo.GetOrderByExternalReference(ctx, ref)
+		return err
+	}, isDatabaseFailure)
+	return order, err
+}
+
+func (r *CircuitBreakerRepository) FindDuplicateOrder(ctx context.Context, order *domain.Order, since time.Time) (id uuid.UUID, err error) {
+	err = r.breaker.Do(func() error {
+		id, err = r.repo.FindDuplicateOrder(ctx, order, since)
+		return err
+	}, isDatabaseFailure)
+	return id, err
+}
+
+func (r *CircuitBreakerRepository) UpdateOrderStatus(ctx context.Context, id uuid.UUID, status domain.OrderStatus, updatedAt time.Time) error {
+	return r.breaker.Do(func() error {
+		return r.repo.UpdateOrderStatus(ctx, id, status, updatedAt)
+	}, isDatabaseFailure)
+}
+
+func (r *CircuitBreakerRepository) ListOrders(ctx context.Context, filter OrderFilter) (orders []*domain.Order, err error) {
+	err = r.breaker.Do(func() error {
+		orders, err = r.repo.ListOrders(ctx, filter)
+		return err
+	}, isDatabaseFailure)
+	return orders, err
+}
+
+func (r *CircuitBreakerRepository) OrderStats(ctx context.Context, filter OrderFilter) (stats []OrderStatusStats, err error) {
+	err = r.breaker.Do(func() error {
+		stats, err = r.repo.OrderStats(ctx, filter)
+		return err
+	}, isDatabaseFailure)
+	return stats, err
+}
+
+// StreamOrders does not count errors returned by fn, such as a client that
+// went away mid-export, against the database.
+func (r *CircuitBreakerRepository) StreamOrders(ctx context.Context, filter OrderFilter, fn func(*domain.Order) error) error {
+	var fnErr error
+	return r.breaker.Do(func() error {
+		return r.repo.StreamOrders(ctx, filter, func(order *domain.Order) error {
+			fnErr = fn(order)
+			return fnErr
+		})
+	}, func(err error) bool {
+		return isDatabaseFailure(err) && (fnErr == nil || !errors.Is(err, fnErr))
+	})
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// PostgresCustomerRepository reads the local customers table.
+type PostgresCustomerRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresCustomerRepository creates a new instance of PostgresCustomerRepository.
+func NewPostgresCustomerRepository(db *sql.DB) *PostgresCustomerRepository {
+	return &PostgresCustomerRepository{db: db}
+}
+
+// CustomerExists reports whether a customer with the given ID is present in the customers table.
+func (r *PostgresCustomerRepository) CustomerExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check customer existence: %w", err)
+	}
+	return exists, nil
+}
+
+// CreateCustomer inserts a customer with the given ID and email.
+func (r *PostgresCustomerRepository) CreateCustomer(ctx context.Context, id uuid.UUID, email string) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO customers (id, email) VALUES ($1, $2)`, id, email)
+	if err != nil {
+		return fmt.Errorf("failed to insert customer: %w", err)
+	}
+	return nil
+}
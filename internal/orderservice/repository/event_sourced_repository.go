@@ -0,0 +1,318 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+)
+
+// EventSourcingConfig enables the event-sourced persistence of orders.
+type EventSourcingConfig struct {
+	// Enabled stores orders as streams of events in order_events. It needs
+	// migration 000009.
+	Enabled bool `key:"enabled" env:"EVENT_SOURCING_ENABLED" default:"false"`
+	// SnapshotEvery is how many events are appended to an order's stream
+	// between snapshots of its state. Zero disables snapshots.
+	SnapshotEvery int `key:"snapshot_every" env:"EVENT_SOURCING_SNAPSHOT_EVERY" default:"10"`
+}
+
+const (
+	insertOrderEventSQL = `
+		INSERT INTO order_events (order_id, version, type, data, occurred_at)
+		VALUES ($1, $2, $3, $4, $5)`
+	selectOrderSnapshotSQL = `
+		SELECT version, state FROM order_snapshots WHERE order_id = $1`
+	selectOrderEventsSQL = `
+		SELECT version, type, data, occurred_at
+		FROM order_events
+		WHERE order_id = $1 AND version > $2
+		ORDER BY version`
+	upsertOrderSnapshotSQL = `
+		INSERT INTO order_snapshots (order_id, version, state)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (order_id) DO UPDATE SET version = EXCLUDED.version, state = EXCLUDED.state, created_at = NOW()`
+	// updateOrderProjectionSQL applies a status change already validated
+	// against the order's stream to orders and order_summaries.
+	updateOrderProjectionSQL = `
+		WITH updated AS (
+			UPDATE orders SET status = $1, updated_at = $2 WHERE id = $3
+			RETURNING id
+		)
+		UPDATE order_summaries s
+		SET status = $1, updated_at = $2
+		FROM updated u
+		WHERE s.order_id = u.id`
+)
+
+// orderEventsKeyConstraint is the primary key of order_events, violated when
+// two writers append the same version of a stream.
+const orderEventsKeyConstraint = "order_events_pkey"
+
+// queryer runs queries on a database or within a transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// EventSourcedOrderRepository stores each order as a stream of domain events
+// in order_events, from which GetOrderByID rebuilds it, starting from its
+// latest snapshot. Every write also updates orders, order_items and
+// order_summaries in the same transaction, as projections serving the
+// queries over many orders, which are inherited from PostgresOrderRepository.
+//
+// Orders stored before event sourcing was enabled have no stream: they are
+// read from the projections, and their stream starts with an OrderCreated
+// event recording their state on their first status change.
+type EventSourcedOrderRepository struct {
+	*PostgresOrderRepository
+	snapshotEvery int
+}
+
+var _ OrderRepository = (*EventSourcedOrderRepository)(nil)
+
+// NewEventSourcedOrderRepository creates an event-sourced repository that
+// runs its statements and transactions with orders.
+func NewEventSourcedOrderRepository(orders *PostgresOrderRepository, cfg EventSourcingConfig) *EventSourcedOrderRepository {
+	return &EventSourcedOrderRepository{PostgresOrderRepository: orders, snapshotEvery: max(cfg.SnapshotEvery, 0)}
+}
+
+// CreateOrder starts the stream of a new order.
+func (r *EventSourcedOrderRepository) CreateOrder(ctx context.Context, order *domain.Order) error {
+	return r.CreateOrderWithOutbox(ctx, order)
+}
+
+// CreateOrderWithOutbox starts the stream of a new order with an OrderCreated
+// event, saves its projections and adds msgs to the outbox, in one
+// transaction.
+func (r *EventSourcedOrderRepository) CreateOrderWithOutbox(ctx context.Context, order *domain.Order, msgs ...OutboxMessage) (err error) {
+	ctx, span := startSpan(ctx, "CreateOrder", order.ID)
+	defer func() { endSpan(span, err) }()
+
+	created := order.CreatedEvent()
+	err = r.inTx(ctx, "create_order", order.ID, func(tx *sql.Tx) error {
+		if err := r.insertOrder(ctx, tx, order, msgs); err != nil {
+			return err
+		}
+		return r.appendEvents(ctx, tx, created)
+	})
+	if err != nil {
+		return err
+	}
+	order.Version = created.Version
+	return nil
+}
+
+// GetOrderByID rebuilds an order from its snapshot and events, or reads it
+// from the projections if it has no stream yet.
+func (r *EventSourcedOrderRepository) GetOrderByID(ctx context.Context, id uuid.UUID) (_ *domain.Order, err error) {
+	ctx, span := startSpan(ctx, "GetOrderByID", id)
+	defer func() { endSpan(span, err) }()
+
+	order, err := r.loadOrder(ctx, r.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return r.PostgresOrderRepository.GetOrderByID(ctx, id)
+	}
+	return order, nil
+}
+
+// GetOrderByExternalReference finds the order with the given external
+// reference in the projections and rebuilds it from its stream.
+func (r *EventSourcedOrderRepository) GetOrderByExternalReference(ctx context.Context, ref domain.ExternalReference) (*domain.Order, error) {
+	order, err := r.PostgresOrderRepository.GetOrderByExternalReference(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetOrderByID(ctx, order.ID)
+}
+
+// UpdateOrderStatus appends an OrderStatusChanged event to the order's stream
+// if its current status allows moving to status, and updates its
+// projections. Concurrent updates append the same version; the transaction
+// losing the race is run again against the new state of the stream.
+func (r *EventSourcedOrderRepository) UpdateOrderStatus(ctx context.Context, id uuid.UUID, status domain.OrderStatus, updatedAt time.Time) (err error) {
+	ctx, span := startSpan(ctx, "UpdateOrderStatus", id)
+	defer func() { endSpan(span, err) }()
+
+	return r.inTx(ctx, "update_order_status", id, func(tx *sql.Tx) error {
+		order, err := r.loadOrder(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		var events []domain.OrderEvent
+		if order == nil {
+			// Adopt an order stored before its stream began.
+			if order, err = r.selectOrder(ctx, tx, id); err != nil {
+				return err
+			}
+			created := order.CreatedEvent()
+			events = append(events, created)
+			order.Version = created.Version
+		}
+		if !order.CanTransitionTo(status) {
+			return &domain.InvalidTransitionError{From: order.Status, To: status}
+		}
+		changed, err := order.Record(&domain.OrderStatusChanged{From: order.Status, To: status}, updatedAt)
+		if err != nil {
+			return err
+		}
+		events = append(events, changed)
+		if err := r.appendEvents(ctx, tx, events...); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		_, err = tx.ExecContext(ctx, updateOrderProjectionSQL, order.Status, order.UpdatedAt, id)
+		r.observeQuery(ctx, "update_order_projection", id, start, err)
+		if err != nil {
+			return fmt.Errorf("failed to update order status: %w", err)
+		}
+		return r.snapshot(ctx, tx, order, len(events))
+	})
+}
+
+// loadOrder rebuilds the order with ID id from its latest snapshot and the
+// events after it. It returns nil if the order has no stream.
+func (r *EventSourcedOrderRepository) loadOrder(ctx context.Context, q queryer, id uuid.UUID) (*domain.Order, error) {
+	start := time.Now()
+	var version int
+	var state []byte
+	err := q.QueryRowContext(ctx, selectOrderSnapshotSQL, id).Scan(&version, &state)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	r.observeQuery(ctx, "select_order_snapshot", id, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order snapshot: %w", err)
+	}
+	var snapshot *domain.Order
+	if state != nil {
+		snapshot = &domain.Order{}
+		if err := json.Unmarshal(state, snapshot); err != nil {
+			return nil, fmt.Errorf("failed to decode snapshot of order %s: %w", id, err)
+		}
+		snapshot.Version = version
+		snapshot.NormalizeTimestamps()
+	}
+
+	start = time.Now()
+	rows, err := q.QueryContext(ctx, selectOrderEventsSQL, id, version)
+	if err != nil {
+		r.observeQuery(ctx, "select_order_events", id, start, err)
+		return nil, fmt.Errorf("failed to get order events: %w", err)
+	}
+	defer rows.Close()
+	events, err := scanOrderEvents(rows, id)
+	r.observeQuery(ctx, "select_order_events", id, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order events: %w", err)
+	}
+	return domain.RebuildOrder(snapshot, events)
+}
+
+// scanOrderEvents reads the events of the order with ID id from rows
+// selected with selectOrderEventsSQL.
+func scanOrderEvents(rows *sql.Rows, id uuid.UUID) ([]domain.OrderEvent, error) {
+	var events []domain.OrderEvent
+	for rows.Next() {
+		event := domain.OrderEvent{OrderID: id}
+		var eventType string
+		var data []byte
+		if err := rows.Scan(&event.Version, &eventType, &data, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan order event: %w", err)
+		}
+		var err error
+		if event.Data, err = decodeOrderEvent(eventType, data); err != nil {
+			return nil, fmt.Errorf("failed to decode event %d: %w", event.Version, err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// decodeOrderEvent decodes the data of an event of type eventType stored in
+// order_events.
+func decodeOrderEvent(eventType string, data []byte) (domain.OrderEventData, error) {
+	var event domain.OrderEventData
+	switch domain.OrderEventType(eventType) {
+	case domain.OrderCreatedEvent:
+		event = &domain.OrderCreated{}
+	case domain.OrderStatusChangedEvent:
+		event = &domain.OrderStatusChanged{}
+	default:
+		return nil, fmt.Errorf("unknown event type %q", eventType)
+	}
+	if err := json.Unmarshal(data, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// selectOrder reads the order with ID id from the projections within tx.
+func (r *EventSourcedOrderRepository) selectOrder(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*domain.Order, error) {
+	start := time.Now()
+	rows, err := tx.QueryContext(ctx, selectOrderSQL, id)
+	if err != nil {
+		r.observeQuery(ctx, "select_order", id, start, err)
+		return nil, fmt.Errorf("failed to get order by ID: %w", err)
+	}
+	defer rows.Close()
+	order, err := scanOrder(rows)
+	r.observeQuery(ctx, "select_order", id, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order by ID: %w", err)
+	}
+	if order == nil {
+		return nil, domain.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// appendEvents appends events to their order's stream within tx.
+func (r *EventSourcedOrderRepository) appendEvents(ctx context.Context, tx *sql.Tx, events ...domain.OrderEvent) error {
+	insert, err := r.prepared(ctx, insertOrderEventSQL)
+	if err != nil {
+		return err
+	}
+	insert = tx.StmtContext(ctx, insert)
+	for _, event := range events {
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			return fmt.Errorf("failed to encode order event: %w", err)
+		}
+		start := time.Now()
+		_, err = insert.ExecContext(ctx, event.OrderID, event.Version, string(event.Data.EventType()), data, event.OccurredAt)
+		r.observeQuery(ctx, "insert_order_event", event.OrderID, start, err)
+		if err != nil {
+			return fmt.Errorf("failed to append order event: %w", err)
+		}
+	}
+	return nil
+}
+
+// snapshot saves order's state within tx if appending the last appended
+// events to its stream reached a multiple of the snapshot interval.
+func (r *EventSourcedOrderRepository) snapshot(ctx context.Context, tx *sql.Tx, order *domain.Order, appended int) error {
+	if r.snapshotEvery == 0 || order.Version/r.snapshotEvery == (order.Version-appended)/r.snapshotEvery {
+		return nil
+	}
+	state, err := json.Marshal(order)
+	if err != nil {
+		return fmt.Errorf("failed to encode order snapshot: %w", err)
+	}
+	start := time.Now()
+	_, err = tx.ExecContext(ctx, upsertOrderSnapshotSQL, order.ID, order.Version, state)
+	r.observeQuery(ctx, "upsert_order_snapshot", order.ID, start, err)
+	if err != nil {
+		return fmt.Errorf("failed to save order snapshot: %w", err)
+	}
+	return nil
+}
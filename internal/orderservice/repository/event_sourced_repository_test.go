@@ -0,0 +1,113 @@
+package repository_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventSourcedOrderRepository(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Database not available for integration tests")
+	}
+	orders := repository.NewPostgresOrderRepository(testDB)
+	repo := repository.NewEventSourcedOrderRepository(orders, repository.EventSourcingConfig{Enabled: true, SnapshotEvery: 2})
+	ctx := context.Background()
+
+	order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 2, UnitPrice: 4.5}})
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateOrder(ctx, order))
+	assert.Equal(t, 1, order.Version)
+
+	updatedAt := order.UpdatedAt.Add(time.Minute)
+	require.NoError(t, repo.UpdateOrderStatus(ctx, order.ID, domain.OrderStatusProcessing, updatedAt))
+	err = repo.UpdateOrderStatus(ctx, order.ID, domain.OrderStatusPending, updatedAt)
+	assert.ErrorIs(t, err, domain.ErrInvalidOrderStatusTransition)
+	require.NoError(t, repo.UpdateOrderStatus(ctx, order.ID, domain.OrderStatusCompleted, updatedAt.Add(time.Minute)))
+
+	// The order is rebuilt from the snapshot at version 2 and the event after it.
+	var snapshotVersion int
+	require.NoError(t, testDB.QueryRow("SELECT version FROM order_snapshots WHERE order_id = $1", order.ID).Scan(&snapshotVersion))
+	assert.Equal(t, 2, snapshotVersion)
+	rebuilt, err := repo.GetOrderByID(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, rebuilt.Version)
+	assert.Equal(t, domain.OrderStatusCompleted, rebuilt.Status)
+	assert.Equal(t, order.Items[0].ProductID, rebuilt.Items[0].ProductID)
+	assert.WithinDuration(t, updatedAt.Add(time.Minute), rebuilt.UpdatedAt, time.Millisecond)
+
+	// The projections follow the stream.
+	projected, err := orders.GetOrderByID(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrderStatusCompleted, projected.Status)
+
+	_, err = repo.GetOrderByID(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrOrderNotFound)
+}
+
+func TestEventSourcedOrderRepository_AdoptsExistingOrders(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Database not available for integration tests")
+	}
+	orders := repository.NewPostgresOrderRepository(testDB)
+	repo := repository.NewEventSourcedOrderRepository(orders, repository.EventSourcingConfig{Enabled: true})
+	ctx := context.Background()
+
+	order, _ := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 1.0}})
+	require.NoError(t, orders.CreateOrder(ctx, order))
+	require.NoError(t, orders.UpdateOrderStatus(ctx, order.ID, domain.OrderStatusProcessing, domain.Now()))
+
+	// Without a stream the order is read from the projections.
+	existing, err := repo.GetOrderByID(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OrderStatusProcessing, existing.Status)
+
+	require.NoError(t, repo.UpdateOrderStatus(ctx, order.ID, domain.OrderStatusCompleted, domain.Now()))
+	adopted, err := repo.GetOrderByID(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, adopted.Version)
+	assert.Equal(t, domain.OrderStatusCompleted, adopted.Status)
+}
+
+func TestEventSourcedOrderRepository_ConcurrentAppends(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Database not available for integration tests")
+	}
+	orders := repository.NewPostgresOrderRepository(testDB, repository.WithTransactions(repository.TxConfig{MaxRetries: 5}))
+	repo := repository.NewEventSourcedOrderRepository(orders, repository.EventSourcingConfig{Enabled: true})
+	ctx := context.Background()
+
+	order, _ := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 1.0}})
+	require.NoError(t, repo.CreateOrder(ctx, order))
+
+	// Racing transitions append the same version; the loser is retried
+	// against the new stream and rejected.
+	targets := []domain.OrderStatus{domain.OrderStatusCancelled, domain.OrderStatusFailed}
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, status := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = repo.UpdateOrderStatus(ctx, order.ID, status, domain.Now())
+		}()
+	}
+	wg.Wait()
+
+	applied := 0
+	for _, err := range errs {
+		if err == nil {
+			applied++
+			continue
+		}
+		assert.ErrorIs(t, err, domain.ErrInvalidOrderStatusTransition)
+	}
+	assert.Equal(t, 1, applied)
+}
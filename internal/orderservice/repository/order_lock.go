@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// OrderLockConfig configures the per-order locks taken around multi-step
+// order mutations.
+type OrderLockConfig struct {
+	Enabled bool `key:"enabled" env:"ORDER_LOCKS_ENABLED" default:"false"`
+	// Timeout is how long a mutation waits for the lock of its order.
+	Timeout time.Duration `key:"timeout" env:"ORDER_LOCK_TIMEOUT" default:"5s"`
+}
+
+// PostgresOrderLocker serializes mutations of the same order across service
+// instances with PostgreSQL advisory locks. Each held lock pins a database
+// connection, so DB_MAX_OPEN_CONNS must leave room for the mutations
+// themselves.
+type PostgresOrderLocker struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewPostgresOrderLocker creates a PostgresOrderLocker that waits up to
+// timeout for a lock.
+func NewPostgresOrderLocker(db *sql.DB, timeout time.Duration) *PostgresOrderLocker {
+	return &PostgresOrderLocker{db: db, timeout: timeout}
+}
+
+// Lock blocks until it holds the lock of orderID and returns the function
+// releasing it. It fails with domain.ErrOrderLocked if the lock is not
+// acquired within the timeout.
+func (l *PostgresOrderLocker) Lock(ctx context.Context, orderID uuid.UUID) (unlock func(), err error) {
+	ctx, span := startSpan(ctx, "LockOrder", orderID)
+	defer func() { endSpan(span, err) }()
+
+	// Session-level advisory locks belong to a connection, so the same one
+	// must be used to release the lock.
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for order lock: %w", err)
+	}
+
+	key := advisoryLockKey(orderID)
+	lockCtx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+	start := time.Now()
+	_, err = conn.ExecContext(lockCtx, "SELECT pg_advisory_lock($1)", key)
+	metrics.OrderLockWaitDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		discard(conn)
+		if errors.Is(lockCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			metrics.OrderLockTimeoutsTotal.Inc()
+			return nil, domain.ErrOrderLocked
+		}
+		return nil, fmt.Errorf("failed to lock order: %w", err)
+	}
+
+	return func() {
+		// The caller's context may be done by now; releasing must not fail
+		// because of it.
+		unlockCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), l.timeout)
+		defer cancel()
+		if _, err := conn.ExecContext(unlockCtx, "SELECT pg_advisory_unlock($1)", key); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("order_id", orderID.String()).Msg("Failed to release order lock, closing its connection")
+			discard(conn)
+			return
+		}
+		if err := conn.Close(); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("Failed to return order lock connection")
+		}
+	}, nil
+}
+
+// advisoryLockKey maps an order ID to an advisory lock key. Distinct orders
+// rarely share a key, and when they do they are merely serialized.
+func advisoryLockKey(id uuid.UUID) int64 {
+	return int64(binary.BigEndian.Uint64(id[:8]))
+}
+
+// discard closes conn without returning it to the pool, which ends its
+// session and with it any advisory lock the session may hold.
+func discard(conn *sql.Conn) {
+	_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	_ = conn.Close()
+}
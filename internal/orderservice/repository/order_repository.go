@@ -2,16 +2,215 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
 )
 
+// OrderFilter narrows the orders returned by ListOrders. Zero-valued fields
+// are ignored.
+type OrderFilter struct {
+	Status     domain.OrderStatus
+	CustomerID uuid.UUID
+	// CreatedFrom and CreatedTo bound the creation time; CreatedTo is exclusive.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// Sort orders the results of ListOrders; empty means newest first.
+	// StreamOrders ignores it.
+	Sort   []SortKey
+	Limit  int
+	Offset int
+}
+
+// SortField is a column orders can be listed by.
+type SortField string
+
+const (
+	SortByCreatedAt  SortField = "created_at"
+	SortByUpdatedAt  SortField = "updated_at"
+	SortByTotalPrice SortField = "total_price"
+	SortByStatus     SortField = "status"
+)
+
+// ParseSortField validates s and returns it as a SortField.
+func ParseSortField(s string) (SortField, bool) {
+	switch field := SortField(s); field {
+	case SortByCreatedAt, SortByUpdatedAt, SortByTotalPrice, SortByStatus:
+		return field, true
+	default:
+		return "", false
+	}
+}
+
+// SortKey sorts orders by Field, ascending unless Descending is set.
+type SortKey struct {
+	Field      SortField
+	Descending bool
+}
+
+// ParseSort parses sort keys written as created_at:desc,total_price:asc. The
+// direction defaults to ascending.
+func ParseSort(s string) ([]SortKey, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var keys []SortKey
+	for term := range strings.SplitSeq(s, ",") {
+		name, direction, _ := strings.Cut(strings.TrimSpace(term), ":")
+		field, ok := ParseSortField(name)
+		if !ok {
+			return nil, fmt.Errorf("cannot sort by %q; sortable fields are created_at, updated_at, total_price and status", name)
+		}
+		if slices.ContainsFunc(keys, func(k SortKey) bool { return k.Field == field }) {
+			return nil, fmt.Errorf("sort lists %s more than once", name)
+		}
+		key := SortKey{Field: field}
+		switch direction {
+		case "", "asc":
+		case "desc":
+			key.Descending = true
+		default:
+			return nil, fmt.Errorf("sort direction of %s must be asc or desc", name)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// FormatSort writes keys the way ParseSort reads them.
+func FormatSort(keys []SortKey) string {
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		terms[i] = string(key.Field)
+		if key.Descending {
+			terms[i] += ":desc"
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+// OrderStatusStats aggregates the orders in one status.
+type OrderStatusStats struct {
+	Status domain.OrderStatus
+	Orders int
+	// Items is the number of units ordered across the orders.
+	Items      int
+	TotalPrice float64
+}
+
+// OutboxMessage is an event waiting in the transactional outbox to be
+// published to Kafka by the outbox relay.
+type OutboxMessage struct {
+	ID int64
+	// Topic and Key are stored as the aggregatetype and aggregateid expected
+	// by Debezium's outbox event router.
+	Topic string
+	Key   []byte
+	// Type names the event, such as events.OrderPlacedType.
+	Type  string
+	Value []byte
+	// CorrelationID is restored on the context the message is published with.
+	CorrelationID string
+	// Attempts counts publish attempts, including the one in progress.
+	Attempts  int
+	CreatedAt time.Time
+}
+
 type OrderRepository interface {
-	// CreateOrder saves a new order to the repository.
+	// CreateOrder saves a new order to the repository. It fails with
+	// domain.ErrExternalReferenceExists if another order has the same
+	// external reference.
 	CreateOrder(ctx context.Context, order *domain.Order) error
+	// CreateOrderWithOutbox saves a new order and adds msgs to the outbox in
+	// the same transaction, so the events are published if and only if the
+	// order is stored.
+	CreateOrderWithOutbox(ctx context.Context, order *domain.Order, msgs ...OutboxMessage) error
+	// EnqueueOutbox adds msgs to the outbox.
+	EnqueueOutbox(ctx context.Context, msgs ...OutboxMessage) error
 	// GetOrderByID retrieves an order by its ID.
 	GetOrderByID(ctx context.Context, id uuid.UUID) (*domain.Order, error)
-	// UpdateOrderStatus updates the status of an existing order.
-	UpdateOrderStatus(ctx context.Context, id uuid.UUID, status domain.OrderStatus) error
+	// GetOrderByExternalReference retrieves the order with the given external
+	// reference, or returns domain.ErrOrderNotFound.
+	GetOrderByExternalReference(ctx context.Context, ref domain.ExternalReference) (*domain.Order, error)
+	// FindDuplicateOrder returns the ID of the newest order created at or
+	// after since by the same customer as order, with the same set of items,
+	// or uuid.Nil if there is none.
+	FindDuplicateOrder(ctx context.Context, order *domain.Order, since time.Time) (uuid.UUID, error)
+	// UpdateOrderStatus updates the status of an existing order, and its
+	// update time to updatedAt, if its current status allows moving to
+	// status, and fails with a *domain.InvalidTransitionError otherwise.
+	UpdateOrderStatus(ctx context.Context, id uuid.UUID, status domain.OrderStatus, updatedAt time.Time) error
+	// ListOrders returns the orders matching filter, newest first.
+	ListOrders(ctx context.Context, filter OrderFilter) ([]*domain.Order, error)
+	// StreamOrders calls fn for each order matching filter, oldest first,
+	// without loading them all into memory. Iteration stops at the first
+	// error returned by fn.
+	StreamOrders(ctx context.Context, filter OrderFilter, fn func(*domain.Order) error) error
+	// OrderStats aggregates the orders matching filter by status. Limit and
+	// Offset are ignored.
+	OrderStats(ctx context.Context, filter OrderFilter) ([]OrderStatusStats, error)
+}
+
+// OutboxRepository is used by the outbox relay to claim and settle pending
+// messages. Several relays may share one outbox.
+type OutboxRepository interface {
+	// ClaimOutbox leases up to limit due messages, oldest first, hiding them
+	// from other relays until lease expires, and counts the attempt.
+	ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]OutboxMessage, error)
+	// MarkOutboxPublished records that the messages were published.
+	MarkOutboxPublished(ctx context.Context, ids []int64) error
+	// MarkOutboxFailed records a failed attempt and when to retry.
+	MarkOutboxFailed(ctx context.Context, id int64, cause error, retryAt time.Time) error
+	// OutboxBacklog returns the number of unpublished messages and the age of
+	// the oldest one.
+	OutboxBacklog(ctx context.Context) (int, time.Duration, error)
+}
+
+// ReplayFilter selects the stored events to replay. Zero fields match every
+// event.
+type ReplayFilter struct {
+	// Topic matches outbox messages for Topic. Order events have no topic.
+	Topic string
+	// Type matches the event type, such as events.OrderPlacedType for outbox
+	// messages or domain.OrderCreatedEvent for order events.
+	Type    string
+	OrderID uuid.UUID
+	// From and To bound when the event was stored: at or after From and
+	// before To.
+	From, To time.Time
+}
+
+// StoredEvent is an event read back from the outbox or from order_events.
+type StoredEvent struct {
+	// Topic is the topic an outbox message was published to; empty for
+	// order events.
+	Topic    string
+	Key      []byte
+	Type     string
+	Value    []byte
+	StoredAt time.Time
+}
+
+// ReplayRepository reads stored events back, oldest first, so they can be
+// published again.
+type ReplayRepository interface {
+	// StreamOutbox hands fn the published outbox messages matching filter.
+	StreamOutbox(ctx context.Context, filter ReplayFilter, fn func(StoredEvent) error) error
+	// StreamOrderEvents hands fn the order events matching filter, in commit
+	// order. Each value is a JSON object with the event's order_id,
+	// version, type, occurred_at and data.
+	StreamOrderEvents(ctx context.Context, filter ReplayFilter, fn func(StoredEvent) error) error
+}
+
+// ProjectionRepository is used by the projection worker to keep the order
+// read models in step with the order event streams.
+type ProjectionRepository interface {
+	// ProjectOrderEvents applies up to limit events, in commit order, that
+	// were not yet applied to the read models. It returns how many it
+	// applied and when the last one was recorded.
+	ProjectOrderEvents(ctx context.Context, limit int) (int, time.Time, error)
 }
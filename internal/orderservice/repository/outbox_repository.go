@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostgresOutboxRepository reads and settles the outbox table on behalf of
+// the outbox relay.
+type PostgresOutboxRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresOutboxRepository creates a new instance of PostgresOutboxRepository.
+func NewPostgresOutboxRepository(db *sql.DB) *PostgresOutboxRepository {
+	return &PostgresOutboxRepository{db: db}
+}
+
+// ClaimOutbox leases up to limit due messages, oldest first. SKIP LOCKED lets
+// relays in several replicas claim disjoint batches; a relay that dies
+// mid-batch loses its lease and the messages are claimed again.
+func (r *PostgresOutboxRepository) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]OutboxMessage, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE outbox
+		SET attempts = attempts + 1, next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, aggregatetype, aggregateid, type, payload, correlation_id, attempts, created_at`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []OutboxMessage
+	for rows.Next() {
+		var msg OutboxMessage
+		var key string
+		var correlationID sql.NullString
+		if err := rows.Scan(&msg.ID, &msg.Topic, &key, &msg.Type, &msg.Value, &correlationID, &msg.Attempts, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		msg.Key = []byte(key)
+		msg.CorrelationID = correlationID.String
+		msgs = append(msgs, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over outbox messages: %w", err)
+	}
+	// RETURNING does not preserve the subquery's order.
+	slices.SortFunc(msgs, func(a, b OutboxMessage) int { return cmp.Compare(a.ID, b.ID) })
+	return msgs, nil
+}
+
+// MarkOutboxPublished records that the messages with the given IDs were published.
+func (r *PostgresOutboxRepository) MarkOutboxPublished(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE outbox SET published_at = NOW(), last_error = NULL
+		WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox messages published: %w", err)
+	}
+	return nil
+}
+
+// MarkOutboxFailed records cause and schedules the next attempt at retryAt.
+func (r *PostgresOutboxRepository) MarkOutboxFailed(ctx context.Context, id int64, cause error, retryAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE outbox SET last_error = $2, next_attempt_at = $3
+		WHERE id = $1`, id, cause.Error(), retryAt)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox failure: %w", err)
+	}
+	return nil
+}
+
+// OutboxBacklog returns the number of unpublished messages and the age of the
+// oldest one.
+func (r *PostgresOutboxRepository) OutboxBacklog(ctx context.Context) (int, time.Duration, error) {
+	var count int
+	var oldest sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), MIN(created_at) FROM outbox
+		WHERE published_at IS NULL`).Scan(&count, &oldest)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to measure outbox backlog: %w", err)
+	}
+	if !oldest.Valid {
+		return count, 0, nil
+	}
+	return count, time.Since(oldest.Time), nil
+}
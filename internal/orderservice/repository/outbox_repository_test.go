@@ -0,0 +1,89 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresOutboxRepository(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Database not available for integration tests")
+	}
+
+	orders := repository.NewPostgresOrderRepository(testDB)
+	outbox := repository.NewPostgresOutboxRepository(testDB)
+	ctx := context.Background()
+	_, err := testDB.Exec("DELETE FROM outbox")
+	require.NoError(t, err)
+
+	order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 5.0}})
+	require.NoError(t, err)
+	msg := repository.OutboxMessage{Topic: "orders.placed", Key: []byte(order.ID.String()), Type: "OrderPlaced", Value: []byte(`{"ok":true}`), CorrelationID: "req-1"}
+	require.NoError(t, orders.CreateOrderWithOutbox(ctx, order, msg))
+
+	// A failed order insert leaves no outbox message behind.
+	err = orders.CreateOrderWithOutbox(ctx, order, msg)
+	require.Error(t, err)
+
+	count, _, err := outbox.OutboxBacklog(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	claimed, err := outbox.ClaimOutbox(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, msg.Topic, claimed[0].Topic)
+	assert.Equal(t, msg.Key, claimed[0].Key)
+	assert.Equal(t, msg.Type, claimed[0].Type)
+	assert.JSONEq(t, string(msg.Value), string(claimed[0].Value))
+	assert.Equal(t, "req-1", claimed[0].CorrelationID)
+	assert.Equal(t, 1, claimed[0].Attempts)
+
+	// Leased messages are hidden from other relays.
+	again, err := outbox.ClaimOutbox(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+
+	// A failure makes the message due again at retryAt.
+	require.NoError(t, outbox.MarkOutboxFailed(ctx, claimed[0].ID, errors.New("broker down"), time.Now().Add(-time.Second)))
+	retried, err := outbox.ClaimOutbox(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, retried, 1)
+	assert.Equal(t, 2, retried[0].Attempts)
+
+	require.NoError(t, outbox.MarkOutboxPublished(ctx, []int64{retried[0].ID}))
+	count, age, err := outbox.OutboxBacklog(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	assert.Zero(t, age)
+}
+
+func TestPostgresOrderRepository_CapturedOutbox(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Database not available for integration tests")
+	}
+
+	orders := repository.NewPostgresOrderRepository(testDB, repository.WithCapturedOutbox())
+	outbox := repository.NewPostgresOutboxRepository(testDB)
+	ctx := context.Background()
+	_, err := testDB.Exec("DELETE FROM outbox")
+	require.NoError(t, err)
+
+	// The message only reaches the write-ahead log, for Debezium to publish.
+	order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 5.0}})
+	require.NoError(t, err)
+	msg := repository.OutboxMessage{Topic: "orders.placed", Key: []byte(order.ID.String()), Type: "OrderPlaced", Value: []byte(`{"ok":true}`)}
+	require.NoError(t, orders.CreateOrderWithOutbox(ctx, order, msg))
+
+	count, _, err := outbox.OutboxBacklog(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
@@ -3,120 +3,868 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
-	_ "github.com/lib/pq"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository")
+
+// startSpan starts a client span for a database operation on an order.
+func startSpan(ctx context.Context, operation string, orderID uuid.UUID) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "PostgresOrderRepository."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", operation),
+			attribute.String("order_id", orderID.String()),
+		),
+	)
+}
+
+// endSpan records err on span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// defaultSlowQueryThreshold is used when no threshold is configured.
+const defaultSlowQueryThreshold = 200 * time.Millisecond
+
+// Hot-path statements, prepared once per repository and reused.
+const (
+	insertOrderSQL = `
+		INSERT INTO orders (id, customer_id, status, total_price, external_source, external_reference, shipping_address,
+			created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	insertOrderItemSQL = `
+		INSERT INTO order_items (id, order_id, product_id, quantity, unit_price, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	insertOrderSummarySQL = `
+		INSERT INTO order_summaries (order_id, customer_id, status, item_count, total_quantity, total_price, item_fingerprint,
+			external_source, external_reference, shipping_address, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+	// selectDuplicateOrderSQL finds the newest order of a customer with a
+	// given item fingerprint created at or after a point in time.
+	selectDuplicateOrderSQL = `
+		SELECT order_id
+		FROM order_summaries
+		WHERE customer_id = $1 AND item_fingerprint = $2 AND created_at >= $3
+		ORDER BY created_at DESC
+		LIMIT 1`
+	insertOutboxSQL = `
+		INSERT INTO outbox (aggregatetype, aggregateid, type, payload, correlation_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+	// selectOrderSQL returns one row per item, or a single row with NULL item
+	// columns for an order without items.
+	selectOrderSQL = selectOrderColumns + `
+		WHERE o.id = $1
+		ORDER BY i.created_at, i.id`
+)
+
+// selectOrderColumns selects an order joined with its items, in the column
+// order read by scanOrder.
+const selectOrderColumns = `
+		SELECT o.id, o.customer_id, o.status, o.total_price,
+			COALESCE(o.external_source, ''), COALESCE(o.external_reference, ''), o.shipping_address, o.created_at, o.updated_at,
+			i.product_id, i.quantity, i.unit_price
+		FROM orders o
+		LEFT JOIN order_items i ON i.order_id = o.id`
+
+// externalReferenceConstraint is the unique constraint on the orders'
+// external references.
+const externalReferenceConstraint = "orders_external_reference_key"
+
+// nullIfEmpty stores empty strings as NULL.
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// addressColumn reads and writes an order's optional shipping address as
+// JSONB, NULL standing for no address.
+type addressColumn struct {
+	address **domain.Address
+}
+
+// Value encodes the address, or returns NULL if there is none.
+func (c addressColumn) Value() (driver.Value, error) {
+	if *c.address == nil {
+		return nil, nil
+	}
+	return json.Marshal(*c.address)
+}
+
+// Scan decodes the address, or clears it if src is NULL.
+func (c addressColumn) Scan(src any) error {
+	*c.address = nil
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into a shipping address", src)
+	}
+	address := &domain.Address{}
+	if err := json.Unmarshal(data, address); err != nil {
+		return fmt.Errorf("failed to decode shipping address: %w", err)
+	}
+	*c.address = address
+	return nil
+}
+
 type PostgresOrderRepository struct {
 	db *sql.DB
+
+	stmtMu sync.Mutex
+	stmts  map[string]*sql.Stmt
+	// slowQueryThreshold holds a time.Duration; it is atomic so it can be
+	// changed by a configuration reload while queries are running.
+	slowQueryThreshold atomic.Int64
+	// latencyObserver, if set, is told the duration of every statement.
+	latencyObserver func(time.Duration)
+	// isolation and maxTxRetries configure the transactions run by inTx.
+	isolation    sql.IsolationLevel
+	maxTxRetries int
+	// captureOutbox deletes outbox messages in the transaction inserting
+	// them; see WithCapturedOutbox.
+	captureOutbox bool
+}
+
+// Option configures a PostgresOrderRepository.
+type Option func(*PostgresOrderRepository)
+
+// WithSlowQueryThreshold sets the duration above which a statement is logged
+// as slow. A zero or negative threshold disables slow-query logging.
+func WithSlowQueryThreshold(d time.Duration) Option {
+	return func(r *PostgresOrderRepository) {
+		r.SetSlowQueryThreshold(d)
+	}
+}
+
+// WithLatencyObserver calls fn with the duration of every statement, for
+// example to apply backpressure when the database slows down.
+func WithLatencyObserver(fn func(time.Duration)) Option {
+	return func(r *PostgresOrderRepository) {
+		r.latencyObserver = fn
+	}
+}
+
+// WithCapturedOutbox deletes each outbox message in the transaction that
+// inserts it. A change data capture connector such as Debezium publishes the
+// messages from the insert in the write-ahead log, so the outbox stays empty
+// and the relay is not needed.
+func WithCapturedOutbox() Option {
+	return func(r *PostgresOrderRepository) {
+		r.captureOutbox = true
+	}
 }
 
 // NewPostgresOrderRepository creates a new instance of PostgresOrderRepository.
-func NewPostgresOrderRepository(db *sql.DB) *PostgresOrderRepository {
-	return &PostgresOrderRepository{db: db}
+func NewPostgresOrderRepository(db *sql.DB, opts ...Option) *PostgresOrderRepository {
+	r := &PostgresOrderRepository{db: db, stmts: make(map[string]*sql.Stmt)}
+	r.SetSlowQueryThreshold(defaultSlowQueryThreshold)
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// SetSlowQueryThreshold changes the slow-query threshold at runtime.
+func (r *PostgresOrderRepository) SetSlowQueryThreshold(d time.Duration) {
+	r.slowQueryThreshold.Store(int64(d))
+}
+
+// prepared returns the prepared statement for query, preparing it on first
+// use. database/sql re-prepares it transparently on each pooled connection.
+// A failed preparation is retried on the next call.
+func (r *PostgresOrderRepository) prepared(ctx context.Context, query string) (*sql.Stmt, error) {
+	r.stmtMu.Lock()
+	defer r.stmtMu.Unlock()
+	if stmt, ok := r.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	r.stmts[query] = stmt
+	return stmt, nil
+}
+
+// Close releases the prepared statements. The database itself is owned by
+// the caller and left open.
+func (r *PostgresOrderRepository) Close() error {
+	r.stmtMu.Lock()
+	defer r.stmtMu.Unlock()
+	var errs []error
+	for query, stmt := range r.stmts {
+		errs = append(errs, stmt.Close())
+		delete(r.stmts, query)
+	}
+	return errors.Join(errs...)
+}
+
+// observeQuery records the duration of a single statement and logs it if it
+// exceeded the slow-query threshold.
+func (r *PostgresOrderRepository) observeQuery(ctx context.Context, query string, orderID uuid.UUID, start time.Time, err error) {
+	elapsed := time.Since(start)
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	metrics.DBQueryDuration.WithLabelValues(query, status).Observe(elapsed.Seconds())
+	if r.latencyObserver != nil {
+		r.latencyObserver(elapsed)
+	}
+
+	threshold := time.Duration(r.slowQueryThreshold.Load())
+	if threshold > 0 && elapsed > threshold {
+		metrics.DBSlowQueriesTotal.WithLabelValues(query).Inc()
+		log.Ctx(ctx).Warn().
+			Str("query", query).
+			Str("order_id", orderID.String()).
+			Dur("duration", elapsed).
+			Dur("threshold", threshold).
+			Msg("Repository: slow query")
+	}
 }
 
 // CreateOrder saves a new order and its items to the PostgreSQL database.
 func (r *PostgresOrderRepository) CreateOrder(ctx context.Context, order *domain.Order) error {
-	tx, err := r.db.BeginTx(ctx, nil) // Start a transaction for atomicity
+	return r.CreateOrderWithOutbox(ctx, order)
+}
+
+// CreateOrderWithOutbox saves a new order, its items and its summary, and adds
+// msgs to the outbox, in one transaction.
+func (r *PostgresOrderRepository) CreateOrderWithOutbox(ctx context.Context, order *domain.Order, msgs ...OutboxMessage) (err error) {
+	ctx, span := startSpan(ctx, "CreateOrder", order.ID)
+	defer func() { endSpan(span, err) }()
+
+	return r.inTx(ctx, "create_order", order.ID, func(tx *sql.Tx) error {
+		return r.insertOrder(ctx, tx, order, msgs)
+	})
+}
+
+// insertOrder saves a new order, its items and its summary, and adds msgs to
+// the outbox, within tx.
+func (r *PostgresOrderRepository) insertOrder(ctx context.Context, tx *sql.Tx, order *domain.Order, msgs []OutboxMessage) error {
+	insertOrder, err := r.prepared(ctx, insertOrderSQL)
+	if err != nil {
+		return err
+	}
+	insertItem, err := r.prepared(ctx, insertOrderItemSQL)
+	if err != nil {
+		return err
+	}
+	insertSummary, err := r.prepared(ctx, insertOrderSummarySQL)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
 
 	// Insert the order
-	orderSQL := `
-		INSERT INTO orders (id, customer_id, status, total_price, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err = tx.ExecContext(ctx, orderSQL, order.ID, order.CustomerID, order.Status, order.TotalPrice, order.CreatedAt, order.UpdatedAt)
+	start := time.Now()
+	ref := order.ExternalReference
+	address := addressColumn{&order.ShippingAddress}
+	_, err = tx.StmtContext(ctx, insertOrder).ExecContext(ctx, order.ID, order.CustomerID, order.Status, order.TotalPrice,
+		nullIfEmpty(ref.Source), nullIfEmpty(ref.Reference), address, order.CreatedAt, order.UpdatedAt)
+	r.observeQuery(ctx, "insert_order", order.ID, start, err)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == externalReferenceConstraint {
+		return fmt.Errorf("failed to insert order: %w", domain.ErrExternalReferenceExists)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to insert order: %w", err)
 	}
 
 	// Insert each order item
-	orderItemSQL := `
-		INSERT INTO order_items (id, order_id, product_id, quantity, unit_price, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	insertItem = tx.StmtContext(ctx, insertItem)
 	for _, item := range order.Items {
 		itemID := uuid.New() // Generate a new UUID for the order item
-		_, err = tx.ExecContext(ctx, orderItemSQL, itemID, order.ID, item.ProductID, item.Quantity, item.UnitPrice, time.Now(), time.Now())
+		start = time.Now()
+		_, err = insertItem.ExecContext(ctx, itemID, order.ID, item.ProductID, item.Quantity, item.UnitPrice, order.CreatedAt, order.UpdatedAt)
+		r.observeQuery(ctx, "insert_order_item", order.ID, start, err)
 		if err != nil {
 			return fmt.Errorf("failed to insert order item: %w", err)
 		}
 	}
 
-	return tx.Commit() // Commit the transaction
+	// Insert the order's row in the order_summaries read model
+	quantity := 0
+	for _, item := range order.Items {
+		quantity += item.Quantity
+	}
+	start = time.Now()
+	_, err = tx.StmtContext(ctx, insertSummary).ExecContext(ctx, order.ID, order.CustomerID, order.Status, len(order.Items), quantity, order.TotalPrice, order.ItemFingerprint(),
+		nullIfEmpty(ref.Source), nullIfEmpty(ref.Reference), address, order.CreatedAt, order.UpdatedAt)
+	r.observeQuery(ctx, "insert_order_summary", order.ID, start, err)
+	if err != nil {
+		return fmt.Errorf("failed to insert order summary: %w", err)
+	}
+
+	return r.insertOutbox(ctx, tx, order.ID, msgs)
+}
+
+// EnqueueOutbox adds msgs to the outbox outside of any order transaction.
+func (r *PostgresOrderRepository) EnqueueOutbox(ctx context.Context, msgs ...OutboxMessage) (err error) {
+	var orderID uuid.UUID
+	if len(msgs) > 0 {
+		orderID, _ = uuid.ParseBytes(msgs[0].Key)
+	}
+	ctx, span := startSpan(ctx, "EnqueueOutbox", orderID)
+	defer func() { endSpan(span, err) }()
+
+	return r.inTx(ctx, "enqueue_outbox", orderID, func(tx *sql.Tx) error {
+		return r.insertOutbox(ctx, tx, orderID, msgs)
+	})
+}
+
+// insertOutbox adds msgs to the outbox within tx.
+func (r *PostgresOrderRepository) insertOutbox(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, msgs []OutboxMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	insert, err := r.prepared(ctx, insertOutboxSQL)
+	if err != nil {
+		return err
+	}
+	insert = tx.StmtContext(ctx, insert)
+	for _, msg := range msgs {
+		start := time.Now()
+		var id int64
+		err := insert.QueryRowContext(ctx, msg.Topic, string(msg.Key), msg.Type, msg.Value, sql.NullString{String: msg.CorrelationID, Valid: msg.CorrelationID != ""}).Scan(&id)
+		r.observeQuery(ctx, "insert_outbox", orderID, start, err)
+		if err != nil {
+			return fmt.Errorf("failed to insert outbox message: %w", err)
+		}
+		if !r.captureOutbox {
+			continue
+		}
+		start = time.Now()
+		_, err = tx.ExecContext(ctx, "DELETE FROM outbox WHERE id = $1", id)
+		r.observeQuery(ctx, "delete_outbox", orderID, start, err)
+		if err != nil {
+			return fmt.Errorf("failed to delete captured outbox message: %w", err)
+		}
+	}
+	return nil
 }
 
 // GetOrderByID retrieves an order by its ID from the PostgreSQL database.
-func (r *PostgresOrderRepository) GetOrderByID(ctx context.Context, id uuid.UUID) (*domain.Order, error) {
-	order := &domain.Order{}
-	orderSQL := `
-		SELECT id, customer_id, status, total_price, created_at, updated_at
-		FROM orders
-		WHERE id = $1`
-	err := r.db.QueryRowContext(ctx, orderSQL, id).Scan(
-		&order.ID,
-		&order.CustomerID,
-		&order.Status,
-		&order.TotalPrice,
-		&order.CreatedAt,
-		&order.UpdatedAt,
+func (r *PostgresOrderRepository) GetOrderByID(ctx context.Context, id uuid.UUID) (_ *domain.Order, err error) {
+	ctx, span := startSpan(ctx, "GetOrderByID", id)
+	defer func() { endSpan(span, err) }()
+
+	selectOrder, err := r.prepared(ctx, selectOrderSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	// The order and its items are fetched in a single round trip.
+	start := time.Now()
+	rows, err := selectOrder.QueryContext(ctx, id)
+	if err != nil {
+		r.observeQuery(ctx, "select_order", id, start, err)
+		return nil, fmt.Errorf("failed to get order by ID: %w", err)
+	}
+	defer rows.Close()
+
+	order, err := scanOrder(rows)
+	r.observeQuery(ctx, "select_order", id, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order by ID: %w", err)
+	}
+	if order == nil {
+		return nil, domain.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// GetOrderByExternalReference retrieves the order with the given external
+// reference.
+func (r *PostgresOrderRepository) GetOrderByExternalReference(ctx context.Context, ref domain.ExternalReference) (_ *domain.Order, err error) {
+	ctx, span := tracer.Start(ctx, "PostgresOrderRepository.GetOrderByExternalReference",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", "GetOrderByExternalReference"),
+			attribute.String("external_source", ref.Source),
+		),
+	)
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	rows, err := r.db.QueryContext(ctx, selectOrderColumns+`
+		WHERE o.external_source = $1 AND o.external_reference = $2
+		ORDER BY i.created_at, i.id`, ref.Source, ref.Reference)
+	if err != nil {
+		r.observeQuery(ctx, "select_order_by_external_reference", uuid.Nil, start, err)
+		return nil, fmt.Errorf("failed to get order by external reference: %w", err)
+	}
+	defer rows.Close()
+
+	order, err := scanOrder(rows)
+	r.observeQuery(ctx, "select_order_by_external_reference", uuid.Nil, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order by external reference: %w", err)
+	}
+	if order == nil {
+		return nil, domain.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// scanOrder reads a single order from rows selected with selectOrderColumns,
+// one row per item. It returns nil if there are no rows.
+func scanOrder(rows *sql.Rows) (*domain.Order, error) {
+	var order *domain.Order
+	for rows.Next() {
+		if order == nil {
+			order = &domain.Order{}
+		}
+		var productID uuid.NullUUID
+		var quantity sql.NullInt64
+		var unitPrice sql.NullFloat64
+		if err := rows.Scan(&order.ID, &order.CustomerID, &order.Status, &order.TotalPrice,
+			&order.ExternalReference.Source, &order.ExternalReference.Reference, addressColumn{&order.ShippingAddress},
+			&order.CreatedAt, &order.UpdatedAt, &productID, &quantity, &unitPrice); err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		if productID.Valid {
+			order.Items = append(order.Items, domain.OrderItem{
+				ProductID: productID.UUID,
+				Quantity:  int(quantity.Int64),
+				UnitPrice: unitPrice.Float64,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if order != nil {
+		order.NormalizeTimestamps()
+	}
+	return order, nil
+}
+
+// FindDuplicateOrder returns the ID of the newest order created at or after
+// since by the same customer as order, with the same items, or uuid.Nil if
+// there is none.
+func (r *PostgresOrderRepository) FindDuplicateOrder(ctx context.Context, order *domain.Order, since time.Time) (_ uuid.UUID, err error) {
+	ctx, span := startSpan(ctx, "FindDuplicateOrder", order.ID)
+	defer func() { endSpan(span, err) }()
+
+	selectDuplicate, err := r.prepared(ctx, selectDuplicateOrderSQL)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	start := time.Now()
+	var id uuid.UUID
+	err = selectDuplicate.QueryRowContext(ctx, order.CustomerID, order.ItemFingerprint(), since).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	r.observeQuery(ctx, "select_duplicate_order", order.ID, start, err)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to find duplicate order: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateOrderStatus updates the status of an existing order, and of its
+// summary, in the PostgreSQL database, recording updatedAt as the time of the
+// change. The update is conditional on the order's current status, so illegal
+// transitions fail with a *domain.InvalidTransitionError even when updates
+// race.
+func (r *PostgresOrderRepository) UpdateOrderStatus(ctx context.Context, id uuid.UUID, status domain.OrderStatus, updatedAt time.Time) (err error) {
+	ctx, span := startSpan(ctx, "UpdateOrderStatus", id)
+	defer func() { endSpan(span, err) }()
+
+	previous := domain.AllowedPreviousStatuses(status)
+	allowedFrom := make([]string, len(previous))
+	for i, from := range previous {
+		allowedFrom[i] = string(from)
+	}
+
+	// A single statement updates both tables atomically, and only if the
+	// order is in a status it may leave for the new one, so concurrent
+	// updates cannot make an illegal transition. The count comes from
+	// orders, so an order without a summary is still updated.
+	var current sql.NullString
+	var updated int
+	err = r.inTx(ctx, "update_order_status", id, func(tx *sql.Tx) error {
+		start := time.Now()
+		err := tx.QueryRowContext(ctx, `
+			WITH current AS (
+				SELECT status FROM orders WHERE id = $3
+			), updated AS (
+				UPDATE orders
+				SET status = $1, updated_at = $2
+				WHERE id = $3 AND status = ANY($4)
+				RETURNING id, updated_at
+			), summarized AS (
+				UPDATE order_summaries s
+				SET status = $1, updated_at = u.updated_at
+				FROM updated u
+				WHERE s.order_id = u.id
+			)
+			SELECT (SELECT status FROM current), (SELECT COUNT(*) FROM updated)`,
+			status, updatedAt, id, pq.Array(allowedFrom)).Scan(&current, &updated)
+		r.observeQuery(ctx, "update_order_status", id, start, err)
+		if err != nil {
+			return fmt.Errorf("failed to update order status: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !current.Valid {
+		return domain.ErrOrderNotFound
+	}
+	if updated == 0 {
+		return &domain.InvalidTransitionError{From: domain.OrderStatus(current.String), To: status}
+	}
+	return nil
+}
+
+// ListOrders retrieves the orders matching filter, in filter.Sort order or
+// newest first, together with their items. Orders are filtered and paged on the indexed
+// order_summaries read model.
+func (r *PostgresOrderRepository) ListOrders(ctx context.Context, filter OrderFilter) (_ []*domain.Order, err error) {
+	ctx, span := tracer.Start(ctx, "PostgresOrderRepository.ListOrders",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", "ListOrders"),
+		),
 	)
+	defer func() { endSpan(span, err) }()
+
+	where, args := filter.conditions()
+	query := `
+		SELECT order_id, customer_id, status, total_price,
+			COALESCE(external_source, ''), COALESCE(external_reference, ''), shipping_address, created_at, updated_at
+		FROM order_summaries` + where + `
+		ORDER BY ` + filter.orderBy()
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	start := time.Now()
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, domain.ErrOrderNotFound
+		r.observeQuery(ctx, "list_orders", uuid.Nil, start, err)
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*domain.Order
+	byID := make(map[uuid.UUID]*domain.Order)
+	for rows.Next() {
+		order := &domain.Order{}
+		if err := rows.Scan(&order.ID, &order.CustomerID, &order.Status, &order.TotalPrice,
+			&order.ExternalReference.Source, &order.ExternalReference.Reference, addressColumn{&order.ShippingAddress},
+			&order.CreatedAt, &order.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
 		}
-		return nil, fmt.Errorf("failed to get order by ID: %w", err)
+		order.NormalizeTimestamps()
+		orders = append(orders, order)
+		byID[order.ID] = order
+	}
+	err = rows.Err()
+	r.observeQuery(ctx, "list_orders", uuid.Nil, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("error iterating over orders: %w", err)
+	}
+	if len(orders) == 0 {
+		return orders, nil
 	}
 
-	//Fetch order items
-	itemSQL := `
-		SELECT product_id, quantity, unit_price
+	ids := make([]uuid.UUID, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+	}
+	start = time.Now()
+	itemRows, err := r.db.QueryContext(ctx, `
+		SELECT order_id, product_id, quantity, unit_price
 		FROM order_items
-		WHERE order_id = $1`
-	rows, err := r.db.QueryContext(ctx, itemSQL, id)
+		WHERE order_id = ANY($1)`, pq.Array(ids))
 	if err != nil {
+		r.observeQuery(ctx, "list_order_items", uuid.Nil, start, err)
 		return nil, fmt.Errorf("failed to get order items: %w", err)
 	}
-	defer rows.Close()
+	defer itemRows.Close()
 
-	var items []domain.OrderItem
-	for rows.Next() {
+	for itemRows.Next() {
+		var orderID uuid.UUID
 		var item domain.OrderItem
-		if err := rows.Scan(&item.ProductID, &item.Quantity, &item.UnitPrice); err != nil {
+		if err := itemRows.Scan(&orderID, &item.ProductID, &item.Quantity, &item.UnitPrice); err != nil {
 			return nil, fmt.Errorf("failed to scan order item: %w", err)
 		}
-		items = append(items, item)
+		if order, ok := byID[orderID]; ok {
+			order.Items = append(order.Items, item)
+		}
 	}
-	if err := rows.Err(); err != nil {
+	err = itemRows.Err()
+	r.observeQuery(ctx, "list_order_items", uuid.Nil, start, err)
+	if err != nil {
 		return nil, fmt.Errorf("error iterating over order items: %w", err)
 	}
-	order.Items = items
-	return order, nil
+	return orders, nil
+}
+
+// streamBatchSize is how many orders StreamOrders reads per query.
+const streamBatchSize = 1000
+
+// StreamOrders reads the orders matching filter, oldest first, and hands each
+// one with its items to fn. Orders are read in batches of streamBatchSize,
+// each query resuming after the last order of the previous one, so memory use
+// does not grow with the number of orders and no statement stays open for
+// the whole export. Limit and Offset are honoured.
+func (r *PostgresOrderRepository) StreamOrders(ctx context.Context, filter OrderFilter, fn func(*domain.Order) error) (err error) {
+	ctx, span := tracer.Start(ctx, "PostgresOrderRepository.StreamOrders",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", "StreamOrders"),
+		),
+	)
+	defer func() { endSpan(span, err) }()
+
+	return streamInBatches(filter, func(after *domain.Order, offset, limit int) (int, *domain.Order, error) {
+		return r.streamBatch(ctx, filter, after, offset, limit, fn)
+	})
+}
 
+// streamInBatches calls batch for successive batches of at most
+// streamBatchSize orders matching filter until it returns fewer orders than
+// asked for or filter.Limit is reached. batch hands the orders after the
+// order after, or the first ones past offset when after is nil, to the
+// caller's function and returns their number and the last one.
+func streamInBatches(filter OrderFilter, batch func(after *domain.Order, offset, limit int) (int, *domain.Order, error)) error {
+	var after *domain.Order
+	offset, remaining := filter.Offset, filter.Limit
+	for {
+		limit := streamBatchSize
+		if filter.Limit > 0 {
+			limit = min(limit, remaining)
+		}
+		n, last, err := batch(after, offset, limit)
+		if err != nil || n < limit {
+			return err
+		}
+		if filter.Limit > 0 {
+			if remaining -= n; remaining == 0 {
+				return nil
+			}
+		}
+		after, offset = last, 0
+	}
 }
 
-// UpdateOrderStatus updates the status of an existing order in the PostgreSQL database.
-func (r *PostgresOrderRepository) UpdateOrderStatus(ctx context.Context, id uuid.UUID, status domain.OrderStatus) error {
-	result, err := r.db.ExecContext(ctx, `
-		UPDATE orders
-		SET status = $1, updated_at = $2
-		WHERE id = $3`, status, time.Now(), id)
+// streamBatch hands fn up to limit orders matching filter that come after
+// the order after, or the first ones past offset when after is nil. It
+// returns the number of orders and the last one.
+func (r *PostgresOrderRepository) streamBatch(ctx context.Context, filter OrderFilter, after *domain.Order, offset, limit int, fn func(*domain.Order) error) (int, *domain.Order, error) {
+	where, args := filter.conditions()
+	where, args = afterCursor(where, args, after)
+	args = append(args, limit)
+	ordersQuery := `
+			SELECT order_id AS id, customer_id, status, total_price,
+				COALESCE(external_source, '') AS external_source, COALESCE(external_reference, '') AS external_reference,
+				shipping_address, created_at, updated_at
+			FROM order_summaries` + where + `
+			ORDER BY created_at, order_id` + fmt.Sprintf(" LIMIT $%d", len(args))
+	if offset > 0 {
+		args = append(args, offset)
+		ordersQuery += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+	query := `
+		SELECT o.id, o.customer_id, o.status, o.total_price, o.external_source, o.external_reference, o.shipping_address,
+			o.created_at, o.updated_at, i.product_id, i.quantity, i.unit_price
+		FROM (` + ordersQuery + `
+		) o
+		LEFT JOIN order_items i ON i.order_id = o.id
+		ORDER BY o.created_at, o.id, i.created_at, i.id`
+
+	// Only the wait for the first rows is timed: the rest of the iteration is
+	// paced by fn, e.g. a client downloading an export.
+	start := time.Now()
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	r.observeQuery(ctx, "stream_orders", uuid.Nil, start, err)
 	if err != nil {
-		return fmt.Errorf("failed to update order status: %w", err)
+		return 0, nil, fmt.Errorf("failed to stream orders: %w", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	var current *domain.Order
+	for rows.Next() {
+		var order domain.Order
+		var productID uuid.NullUUID
+		var quantity sql.NullInt64
+		var unitPrice sql.NullFloat64
+		if err := rows.Scan(&order.ID, &order.CustomerID, &order.Status, &order.TotalPrice,
+			&order.ExternalReference.Source, &order.ExternalReference.Reference, addressColumn{&order.ShippingAddress},
+			&order.CreatedAt, &order.UpdatedAt, &productID, &quantity, &unitPrice); err != nil {
+			return count, current, fmt.Errorf("failed to scan order: %w", err)
+		}
+		order.NormalizeTimestamps()
+		if current == nil || current.ID != order.ID {
+			if current != nil {
+				if err := fn(current); err != nil {
+					return count, current, err
+				}
+			}
+			current = &order
+			count++
+		}
+		if productID.Valid {
+			current.Items = append(current.Items, domain.OrderItem{
+				ProductID: productID.UUID,
+				Quantity:  int(quantity.Int64),
+				UnitPrice: unitPrice.Float64,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return count, current, fmt.Errorf("error iterating over orders: %w", err)
 	}
+	if current != nil {
+		return count, current, fn(current)
+	}
+	return 0, nil, nil
+}
 
-	rowsAffected, err := result.RowsAffected()
+// OrderStats counts the orders matching filter, and totals their items and
+// prices, per status. It reads only the order_summaries read model.
+func (r *PostgresOrderRepository) OrderStats(ctx context.Context, filter OrderFilter) (_ []OrderStatusStats, err error) {
+	ctx, span := tracer.Start(ctx, "PostgresOrderRepository.OrderStats",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", "OrderStats"),
+		),
+	)
+	defer func() { endSpan(span, err) }()
+
+	where, args := filter.conditions()
+	start := time.Now()
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT status, COUNT(*), COALESCE(SUM(total_quantity), 0), COALESCE(SUM(total_price), 0)
+		FROM order_summaries`+where+`
+		GROUP BY status
+		ORDER BY status`, args...)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		r.observeQuery(ctx, "order_stats", uuid.Nil, start, err)
+		return nil, fmt.Errorf("failed to get order stats: %w", err)
 	}
-	if rowsAffected == 0 {
-		return domain.ErrOrderNotFound
+	defer rows.Close()
+
+	var stats []OrderStatusStats
+	for rows.Next() {
+		var s OrderStatusStats
+		if err := rows.Scan(&s.Status, &s.Orders, &s.Items, &s.TotalPrice); err != nil {
+			return nil, fmt.Errorf("failed to scan order stats: %w", err)
+		}
+		stats = append(stats, s)
 	}
-	return nil
+	err = rows.Err()
+	r.observeQuery(ctx, "order_stats", uuid.Nil, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("error iterating over order stats: %w", err)
+	}
+	return stats, nil
+}
+
+// conditions renders the filter as a WHERE clause and its arguments.
+func (f OrderFilter) conditions() (string, []any) {
+	var clauses []string
+	var args []any
+	add := func(clause string, arg any) {
+		args = append(args, arg)
+		clauses = append(clauses, fmt.Sprintf(clause, len(args)))
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.CustomerID != uuid.Nil {
+		add("customer_id = $%d", f.CustomerID)
+	}
+	if !f.CreatedFrom.IsZero() {
+		add("created_at >= $%d", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		add("created_at < $%d", f.CreatedTo)
+	}
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	return "\n\t\tWHERE " + strings.Join(clauses, " AND "), args
+}
+
+// afterCursor narrows the WHERE clause where and its arguments args to the
+// orders after the order after, by (created_at, order_id). A nil after leaves
+// them unchanged.
+func afterCursor(where string, args []any, after *domain.Order) (string, []any) {
+	if after == nil {
+		return where, args
+	}
+	args = append(args, after.CreatedAt, after.ID)
+	cursor := fmt.Sprintf("(created_at, order_id) > ($%d, $%d)", len(args)-1, len(args))
+	if where == "" {
+		return "\n\t\tWHERE " + cursor, args
+	}
+	return where + " AND " + cursor, args
+}
+
+// orderBy returns the ORDER BY list of f.Sort, breaking ties by order ID so
+// paging is stable. Fields are checked against ParseSortField, so only known
+// columns reach the query.
+func (f OrderFilter) orderBy() string {
+	if len(f.Sort) == 0 {
+		return "created_at DESC, order_id"
+	}
+	terms := make([]string, 0, len(f.Sort)+1)
+	for _, key := range f.Sort {
+		column, ok := ParseSortField(string(key.Field))
+		if !ok {
+			continue
+		}
+		term := string(column)
+		if key.Descending {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(append(terms, "order_id"), ", ")
 }
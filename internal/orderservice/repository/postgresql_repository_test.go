@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
 	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var testDB *sql.DB
@@ -76,13 +78,13 @@ func connectTestDB(dataSourceName string) (*sql.DB, error) {
 
 // clearTable clears the test tables before each test case (important for isolated tests).
 func clearTable(db *sql.DB) error {
-	_, err := db.Exec("DELETE FROM order_items; DELETE FROM orders;")
+	_, err := db.Exec("DELETE FROM outbox; DELETE FROM order_list_view; DELETE FROM order_status_dashboard; DELETE FROM order_snapshots; DELETE FROM order_events; DELETE FROM order_summaries; DELETE FROM order_items; DELETE FROM orders;")
 	return err
 }
 
 // cleanupDatabase drops tables after all tests in TestMain.
 func cleanupDatabase(db *sql.DB) error {
-	_, err := db.Exec("DROP TABLE IF EXISTS order_items; DROP TABLE IF EXISTS orders;")
+	_, err := db.Exec("DROP TABLE IF EXISTS outbox; DROP TABLE IF EXISTS projection_checkpoints; DROP TABLE IF EXISTS order_list_view; DROP TABLE IF EXISTS order_status_dashboard; DROP TABLE IF EXISTS order_snapshots; DROP TABLE IF EXISTS order_events; DROP TABLE IF EXISTS order_summaries; DROP TABLE IF EXISTS order_items; DROP TABLE IF EXISTS orders;")
 	return err
 }
 
@@ -150,6 +152,14 @@ func TestPostgresOrderRepository(t *testing.T) {
 			assert.Equal(t, originalItem.Quantity, retrievedItem.Quantity)
 			assert.InDelta(t, originalItem.UnitPrice, retrievedItem.UnitPrice, 0.001)
 		}
+
+		// Items are stamped with the order's own timestamps
+		var stamped int
+		err = testDB.QueryRowContext(ctx, `
+			SELECT count(*) FROM order_items i JOIN orders o ON o.id = i.order_id
+			WHERE i.order_id = $1 AND i.created_at = o.created_at AND i.updated_at = o.updated_at`, order.ID).Scan(&stamped)
+		assert.NoError(t, err)
+		assert.Equal(t, len(order.Items), stamped, "Expected order items to share the order's timestamps")
 	})
 
 	t.Run("Get Non-Existent Order", func(t *testing.T) {
@@ -160,6 +170,204 @@ func TestPostgresOrderRepository(t *testing.T) {
 		assert.Nil(t, order, "Expected nil order for non-existent ID")
 	})
 
+	t.Run("Get Order without items", func(t *testing.T) {
+		t.Parallel()
+		order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 3.0}})
+		require.NoError(t, err)
+		order.Items = nil
+		require.NoError(t, repo.CreateOrder(ctx, order))
+
+		retrievedOrder, err := repo.GetOrderByID(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, order.ID, retrievedOrder.ID)
+		assert.Empty(t, retrievedOrder.Items)
+	})
+
+	t.Run("List Orders by filter", func(t *testing.T) {
+		t.Parallel()
+		customerID := uuid.New() // Scopes the filter to this subtest's orders
+		items := []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 5.0}}
+
+		older, _ := domain.NewOrder(customerID, items)
+		older.CreatedAt = time.Now().Add(-time.Hour)
+		newer, _ := domain.NewOrder(customerID, items)
+		assert.NoError(t, newer.TransitionTo(domain.OrderStatusCancelled))
+		assert.NoError(t, repo.CreateOrder(ctx, older))
+		assert.NoError(t, repo.CreateOrder(ctx, newer))
+
+		orders, err := repo.ListOrders(ctx, repository.OrderFilter{CustomerID: customerID})
+		assert.NoError(t, err)
+		if assert.Len(t, orders, 2) {
+			assert.Equal(t, newer.ID, orders[0].ID, "Expected newest order first")
+			assert.Len(t, orders[1].Items, 1)
+		}
+
+		orders, err = repo.ListOrders(ctx, repository.OrderFilter{CustomerID: customerID, Status: domain.OrderStatusCancelled})
+		assert.NoError(t, err)
+		if assert.Len(t, orders, 1) {
+			assert.Equal(t, newer.ID, orders[0].ID)
+		}
+
+		orders, err = repo.ListOrders(ctx, repository.OrderFilter{CustomerID: customerID, Limit: 1, Offset: 1})
+		assert.NoError(t, err)
+		if assert.Len(t, orders, 1) {
+			assert.Equal(t, older.ID, orders[0].ID)
+		}
+
+		orders, err = repo.ListOrders(ctx, repository.OrderFilter{CustomerID: customerID,
+			Sort: []repository.SortKey{{Field: repository.SortByStatus, Descending: true}, {Field: repository.SortByCreatedAt}}})
+		assert.NoError(t, err)
+		if assert.Len(t, orders, 2) {
+			assert.Equal(t, []uuid.UUID{older.ID, newer.ID}, []uuid.UUID{orders[0].ID, orders[1].ID}, "Expected pending before cancelled")
+		}
+	})
+
+	t.Run("Stream Orders oldest first", func(t *testing.T) {
+		t.Parallel()
+		customerID := uuid.New()
+
+		first, _ := domain.NewOrder(customerID, []domain.OrderItem{
+			{ProductID: uuid.New(), Quantity: 1, UnitPrice: 5.0},
+			{ProductID: uuid.New(), Quantity: 3, UnitPrice: 2.0},
+		})
+		first.CreatedAt = time.Now().Add(-time.Hour)
+		second, _ := domain.NewOrder(customerID, []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 9.0}})
+		assert.NoError(t, repo.CreateOrder(ctx, second))
+		assert.NoError(t, repo.CreateOrder(ctx, first))
+
+		var streamed []*domain.Order
+		err := repo.StreamOrders(ctx, repository.OrderFilter{CustomerID: customerID}, func(order *domain.Order) error {
+			streamed = append(streamed, order)
+			return nil
+		})
+		assert.NoError(t, err)
+		if assert.Len(t, streamed, 2) {
+			assert.Equal(t, first.ID, streamed[0].ID)
+			assert.Len(t, streamed[0].Items, 2)
+			assert.Equal(t, second.ID, streamed[1].ID)
+			assert.Len(t, streamed[1].Items, 1)
+		}
+
+		streamed = nil
+		err = repo.StreamOrders(ctx, repository.OrderFilter{CustomerID: customerID, Limit: 1, Offset: 1}, func(order *domain.Order) error {
+			streamed = append(streamed, order)
+			return nil
+		})
+		assert.NoError(t, err)
+		if assert.Len(t, streamed, 1) {
+			assert.Equal(t, second.ID, streamed[0].ID)
+		}
+	})
+
+	t.Run("Order summaries follow writes", func(t *testing.T) {
+		t.Parallel()
+		customerID := uuid.New()
+
+		first, _ := domain.NewOrder(customerID, []domain.OrderItem{
+			{ProductID: uuid.New(), Quantity: 2, UnitPrice: 5.0},
+			{ProductID: uuid.New(), Quantity: 1, UnitPrice: 4.0},
+		})
+		second, _ := domain.NewOrder(customerID, []domain.OrderItem{{ProductID: uuid.New(), Quantity: 3, UnitPrice: 1.0}})
+		require.NoError(t, repo.CreateOrder(ctx, first))
+		require.NoError(t, repo.CreateOrder(ctx, second))
+		require.NoError(t, repo.UpdateOrderStatus(ctx, second.ID, domain.OrderStatusCancelled, domain.Now()))
+
+		stats, err := repo.OrderStats(ctx, repository.OrderFilter{CustomerID: customerID})
+		require.NoError(t, err)
+		if assert.Len(t, stats, 2) {
+			assert.Equal(t, domain.OrderStatusCancelled, stats[0].Status)
+			assert.Equal(t, 1, stats[0].Orders)
+			assert.Equal(t, 3, stats[0].Items)
+			assert.InDelta(t, 3.0, stats[0].TotalPrice, 0.001)
+			assert.Equal(t, domain.OrderStatusPending, stats[1].Status)
+			assert.Equal(t, 1, stats[1].Orders)
+			assert.Equal(t, 3, stats[1].Items)
+			assert.InDelta(t, 14.0, stats[1].TotalPrice, 0.001)
+		}
+
+		orders, err := repo.ListOrders(ctx, repository.OrderFilter{CustomerID: customerID, Status: domain.OrderStatusCancelled})
+		require.NoError(t, err)
+		if assert.Len(t, orders, 1) {
+			assert.Equal(t, second.ID, orders[0].ID)
+			assert.Len(t, orders[0].Items, 1)
+		}
+
+		assert.ErrorIs(t, repo.UpdateOrderStatus(ctx, uuid.New(), domain.OrderStatusCancelled, domain.Now()), domain.ErrOrderNotFound)
+	})
+
+	t.Run("Status updates are conditional on the current status", func(t *testing.T) {
+		t.Parallel()
+		order, _ := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 1.0}})
+		require.NoError(t, repo.CreateOrder(ctx, order))
+		updatedAt := domain.Now().Add(time.Second)
+		require.NoError(t, repo.UpdateOrderStatus(ctx, order.ID, domain.OrderStatusCancelled, updatedAt))
+
+		err := repo.UpdateOrderStatus(ctx, order.ID, domain.OrderStatusCompleted, domain.Now())
+		var transitionErr *domain.InvalidTransitionError
+		if assert.ErrorAs(t, err, &transitionErr) {
+			assert.Equal(t, domain.OrderStatusCancelled, transitionErr.From)
+			assert.Equal(t, domain.OrderStatusCompleted, transitionErr.To)
+		}
+
+		stored, err := repo.GetOrderByID(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.OrderStatusCancelled, stored.Status)
+		// Timestamps read back in UTC, exactly as written.
+		assert.Equal(t, order.CreatedAt, stored.CreatedAt)
+		assert.Equal(t, updatedAt, stored.UpdatedAt)
+	})
+
+	t.Run("Find duplicate order", func(t *testing.T) {
+		t.Parallel()
+		customerID := uuid.New()
+		a := domain.OrderItem{ProductID: uuid.New(), Quantity: 1, UnitPrice: 2.5}
+		b := domain.OrderItem{ProductID: uuid.New(), Quantity: 2, UnitPrice: 4.0}
+
+		first, _ := domain.NewOrder(customerID, []domain.OrderItem{a, b})
+		require.NoError(t, repo.CreateOrder(ctx, first))
+
+		repeat, _ := domain.NewOrder(customerID, []domain.OrderItem{b, a})
+		id, err := repo.FindDuplicateOrder(ctx, repeat, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, id)
+
+		id, err = repo.FindDuplicateOrder(ctx, repeat, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, uuid.Nil, id, "Expected orders outside the window to be ignored")
+
+		other, _ := domain.NewOrder(customerID, []domain.OrderItem{a})
+		id, err = repo.FindDuplicateOrder(ctx, other, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, uuid.Nil, id, "Expected orders with other items to be ignored")
+	})
+
+	t.Run("External reference is unique per source", func(t *testing.T) {
+		t.Parallel()
+		items := []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 1.0}}
+		ref := domain.ExternalReference{Source: "shopify", Reference: uuid.NewString()}
+
+		first, _ := domain.NewOrder(uuid.New(), items)
+		first.ExternalReference = ref
+		require.NoError(t, repo.CreateOrder(ctx, first))
+
+		found, err := repo.GetOrderByExternalReference(ctx, ref)
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, found.ID)
+		assert.Equal(t, ref, found.ExternalReference)
+		assert.Len(t, found.Items, 1)
+
+		again, _ := domain.NewOrder(uuid.New(), items)
+		again.ExternalReference = ref
+		assert.ErrorIs(t, repo.CreateOrder(ctx, again), domain.ErrExternalReferenceExists)
+
+		otherSource, _ := domain.NewOrder(uuid.New(), items)
+		otherSource.ExternalReference = domain.ExternalReference{Source: "erp", Reference: ref.Reference}
+		assert.NoError(t, repo.CreateOrder(ctx, otherSource), "Expected the same reference to be allowed in another source")
+
+		_, err = repo.GetOrderByExternalReference(ctx, domain.ExternalReference{Source: "shopify", Reference: uuid.NewString()})
+		assert.ErrorIs(t, err, domain.ErrOrderNotFound)
+	})
+
 	t.Run("Create order with duplicate ID (simulating failure)", func(t *testing.T) {
 		t.Parallel()
 		customerID := uuid.New()
@@ -180,3 +388,101 @@ func TestPostgresOrderRepository(t *testing.T) {
 		assert.Contains(t, err.Error(), "duplicate key value violates unique constraint")
 	})
 }
+
+func TestPostgresOrderRepository_PreparedStatements(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Database not available for integration tests")
+	}
+
+	repo := repository.NewPostgresOrderRepository(testDB)
+	ctx := context.Background()
+
+	// Concurrent calls share the statements prepared by the first one.
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 2.5}})
+			if err == nil {
+				err = repo.CreateOrder(ctx, order)
+			}
+			if err == nil {
+				_, err = repo.GetOrderByID(ctx, order.ID)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	// Closing releases the statements; later calls prepare them again.
+	require.NoError(t, repo.Close())
+	_, err := repo.GetOrderByID(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrOrderNotFound)
+	require.NoError(t, repo.Close())
+}
+
+func TestPostgresOrderRepository_SerializableRetries(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Database not available for integration tests")
+	}
+	repo := repository.NewPostgresOrderRepository(testDB,
+		repository.WithTransactions(repository.TxConfig{Isolation: repository.IsolationSerializable, MaxRetries: 5}))
+	ctx := context.Background()
+
+	order, _ := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 1.0}})
+	require.NoError(t, repo.CreateOrder(ctx, order))
+
+	// Racing transitions out of pending abort each other; retried, exactly
+	// one applies and the other is rejected against the new status.
+	targets := []domain.OrderStatus{domain.OrderStatusCancelled, domain.OrderStatusFailed}
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, status := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = repo.UpdateOrderStatus(ctx, order.ID, status, domain.Now())
+		}()
+	}
+	wg.Wait()
+
+	applied := 0
+	for _, err := range errs {
+		if err == nil {
+			applied++
+			continue
+		}
+		assert.ErrorIs(t, err, domain.ErrInvalidOrderStatusTransition, "Expected serialization failures to be retried")
+	}
+	assert.Equal(t, 1, applied)
+}
+
+func TestPostgresOrderLocker(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Database not available for integration tests")
+	}
+	ctx := context.Background()
+	locker := repository.NewPostgresOrderLocker(testDB, 50*time.Millisecond)
+	orderID := uuid.New()
+
+	unlock, err := locker.Lock(ctx, orderID)
+	require.NoError(t, err)
+
+	_, err = locker.Lock(ctx, orderID)
+	assert.ErrorIs(t, err, domain.ErrOrderLocked, "Expected a second lock of the same order to time out")
+
+	other, err := locker.Lock(ctx, uuid.New())
+	require.NoError(t, err, "Expected other orders to be lockable")
+	other()
+
+	unlock()
+	again, err := locker.Lock(ctx, orderID)
+	require.NoError(t, err, "Expected the lock to be free once released")
+	again()
+}
@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+)
+
+// ordersProjection is the checkpoint of the order read models.
+const ordersProjection = "orders"
+
+const (
+	// selectCheckpointSQL locks the checkpoint, so workers in several
+	// replicas take turns instead of applying the same events.
+	selectCheckpointSQL = `
+		SELECT transaction_id::text, position FROM projection_checkpoints WHERE name = $1 FOR UPDATE`
+	// selectCommittedOrderEventsSQL reads the events after a checkpoint in
+	// commit order. Events of transactions newer than the oldest one still
+	// running are left for a later batch: that transaction may yet commit
+	// events that sort before them.
+	selectCommittedOrderEventsSQL = `
+		SELECT transaction_id::text, position, order_id, version, type, data, occurred_at, recorded_at
+		FROM order_events
+		WHERE (transaction_id, position) > ($1::xid8, $2)
+			AND transaction_id < pg_snapshot_xmin(pg_current_snapshot())
+		ORDER BY transaction_id, position
+		LIMIT $3`
+	updateCheckpointSQL = `
+		UPDATE projection_checkpoints SET transaction_id = $2::xid8, position = $3, updated_at = NOW() WHERE name = $1`
+	selectOrderViewSQL = `
+		SELECT ` + orderViewColumns + `
+		FROM order_list_view
+		WHERE order_id = $1`
+	upsertOrderViewSQL = `
+		INSERT INTO order_list_view (order_id, customer_id, status, item_count, total_quantity, total_price, items,
+			external_source, external_reference, shipping_address, created_at, updated_at, version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (order_id) DO UPDATE SET
+			customer_id = EXCLUDED.customer_id, status = EXCLUDED.status, item_count = EXCLUDED.item_count,
+			total_quantity = EXCLUDED.total_quantity, total_price = EXCLUDED.total_price, items = EXCLUDED.items,
+			external_source = EXCLUDED.external_source, external_reference = EXCLUDED.external_reference,
+			shipping_address = EXCLUDED.shipping_address, created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at, version = EXCLUDED.version`
+	addToDashboardSQL = `
+		INSERT INTO order_status_dashboard (status, created_hour, orders, items, total_price)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (status, created_hour) DO UPDATE SET
+			orders = order_status_dashboard.orders + EXCLUDED.orders,
+			items = order_status_dashboard.items + EXCLUDED.items,
+			total_price = order_status_dashboard.total_price + EXCLUDED.total_price`
+)
+
+// orderViewColumns are the columns of order_list_view read by scanOrderView.
+const orderViewColumns = `order_id, customer_id, status, total_price, items,
+			COALESCE(external_source, ''), COALESCE(external_reference, ''), shipping_address, created_at, updated_at, version`
+
+// PostgresProjectionRepository applies order_events to the order read
+// models, order_list_view and order_status_dashboard, on behalf of the
+// projection worker.
+type PostgresProjectionRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresProjectionRepository creates a new instance of PostgresProjectionRepository.
+func NewPostgresProjectionRepository(db *sql.DB) *PostgresProjectionRepository {
+	return &PostgresProjectionRepository{db: db}
+}
+
+// committedEvent is an order event read past the checkpoint.
+type committedEvent struct {
+	domain.OrderEvent
+	transactionID string
+	position      int64
+	recordedAt    time.Time
+}
+
+// ProjectOrderEvents applies up to limit events past the checkpoint and
+// advances it, in one transaction, so each event is applied exactly once.
+func (r *PostgresProjectionRepository) ProjectOrderEvents(ctx context.Context, limit int) (int, time.Time, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var transactionID string
+	var position int64
+	if err := tx.QueryRowContext(ctx, selectCheckpointSQL, ordersProjection).Scan(&transactionID, &position); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to get projection checkpoint: %w", err)
+	}
+	events, err := r.committedEvents(ctx, tx, transactionID, position, limit)
+	if err != nil || len(events) == 0 {
+		return 0, time.Time{}, err
+	}
+	for _, event := range events {
+		if err := r.apply(ctx, tx, event.OrderEvent); err != nil {
+			return 0, time.Time{}, fmt.Errorf("failed to project event %d of order %s: %w", event.Version, event.OrderID, err)
+		}
+	}
+	last := events[len(events)-1]
+	if _, err := tx.ExecContext(ctx, updateCheckpointSQL, ordersProjection, last.transactionID, last.position); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to update projection checkpoint: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(events), last.recordedAt, nil
+}
+
+// committedEvents reads up to limit events after the checkpoint at
+// (transactionID, position).
+func (r *PostgresProjectionRepository) committedEvents(ctx context.Context, tx *sql.Tx, transactionID string, position int64, limit int) ([]committedEvent, error) {
+	rows, err := tx.QueryContext(ctx, selectCommittedOrderEventsSQL, transactionID, position, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []committedEvent
+	for rows.Next() {
+		var event committedEvent
+		var eventType string
+		var data []byte
+		if err := rows.Scan(&event.transactionID, &event.position, &event.OrderID, &event.Version, &eventType, &data,
+			&event.OccurredAt, &event.recordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan order event: %w", err)
+		}
+		if event.Data, err = decodeOrderEvent(eventType, data); err != nil {
+			return nil, fmt.Errorf("failed to decode event %d of order %s: %w", event.Version, event.OrderID, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over order events: %w", err)
+	}
+	return events, nil
+}
+
+// apply updates the order's row in order_list_view with event and moves the
+// order between the buckets of order_status_dashboard. Events the row already
+// reflects are skipped.
+func (r *PostgresProjectionRepository) apply(ctx context.Context, tx *sql.Tx, event domain.OrderEvent) error {
+	rows, err := tx.QueryContext(ctx, selectOrderViewSQL, event.OrderID)
+	if err != nil {
+		return err
+	}
+	view, err := scanOrderView(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	order := &domain.Order{}
+	if view != nil {
+		if event.Version <= view.Version {
+			return nil
+		}
+		*order = *view
+	}
+	if err := order.Apply(event); err != nil {
+		return err
+	}
+
+	items, err := json.Marshal(order.Items)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, upsertOrderViewSQL, order.ID, order.CustomerID, order.Status, len(order.Items),
+		totalQuantity(order.Items), order.TotalPrice, items, nullIfEmpty(order.ExternalReference.Source),
+		nullIfEmpty(order.ExternalReference.Reference), addressColumn{&order.ShippingAddress}, order.CreatedAt, order.UpdatedAt,
+		order.Version)
+	if err != nil {
+		return err
+	}
+	if view != nil {
+		if err := addToDashboard(ctx, tx, view, -1); err != nil {
+			return err
+		}
+	}
+	return addToDashboard(ctx, tx, order, 1)
+}
+
+// addToDashboard adds order, or removes it if sign is -1, to the bucket of
+// its status and creation hour.
+func addToDashboard(ctx context.Context, tx *sql.Tx, order *domain.Order, sign int) error {
+	_, err := tx.ExecContext(ctx, addToDashboardSQL, order.Status, dashboardHour(order.CreatedAt),
+		sign, sign*totalQuantity(order.Items), float64(sign)*order.TotalPrice)
+	return err
+}
+
+// dashboardHour returns the order_status_dashboard bucket of t.
+func dashboardHour(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Hour)
+}
+
+func totalQuantity(items []domain.OrderItem) int {
+	total := 0
+	for _, item := range items {
+		total += item.Quantity
+	}
+	return total
+}
+
+// scanOrderView reads the order at the current row of rows, selected with
+// orderViewColumns, or returns nil if there are no rows.
+func scanOrderView(rows *sql.Rows) (*domain.Order, error) {
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	order, err := scanOrderViewRow(rows)
+	if err != nil {
+		return nil, err
+	}
+	return order, rows.Err()
+}
+
+// scanOrderViewRow reads the order at the current row of rows.
+func scanOrderViewRow(rows *sql.Rows) (*domain.Order, error) {
+	order := &domain.Order{}
+	var items []byte
+	if err := rows.Scan(&order.ID, &order.CustomerID, &order.Status, &order.TotalPrice, &items,
+		&order.ExternalReference.Source, &order.ExternalReference.Reference, addressColumn{&order.ShippingAddress},
+		&order.CreatedAt, &order.UpdatedAt, &order.Version); err != nil {
+		return nil, fmt.Errorf("failed to scan order: %w", err)
+	}
+	if err := json.Unmarshal(items, &order.Items); err != nil {
+		return nil, fmt.Errorf("failed to decode items of order %s: %w", order.ID, err)
+	}
+	order.NormalizeTimestamps()
+	return order, nil
+}
@@ -0,0 +1,80 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresProjectionRepository(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Database not available for integration tests")
+	}
+	orders := repository.NewPostgresOrderRepository(testDB)
+	writes := repository.NewEventSourcedOrderRepository(orders, repository.EventSourcingConfig{Enabled: true})
+	reads := repository.NewReadModelOrderRepository(writes, orders)
+	projections := repository.NewPostgresProjectionRepository(testDB)
+	ctx := context.Background()
+
+	project := func() {
+		t.Helper()
+		for {
+			n, _, err := projections.ProjectOrderEvents(ctx, 100)
+			require.NoError(t, err)
+			if n == 0 {
+				return
+			}
+		}
+	}
+
+	customerID := uuid.New()
+	createdAt := time.Date(2025, 3, 1, 10, 15, 0, 0, time.UTC)
+	var placed []*domain.Order
+	for _, quantity := range []int{1, 3} {
+		order, err := domain.NewOrder(customerID, []domain.OrderItem{{ProductID: uuid.New(), Quantity: quantity, UnitPrice: 2}})
+		require.NoError(t, err)
+		order.CreatedAt, order.UpdatedAt = createdAt, createdAt
+		require.NoError(t, writes.CreateOrder(ctx, order))
+		placed = append(placed, order)
+	}
+	require.NoError(t, writes.UpdateOrderStatus(ctx, placed[1].ID, domain.OrderStatusProcessing, createdAt.Add(time.Minute)))
+	project()
+
+	listed, err := reads.ListOrders(ctx, repository.OrderFilter{CustomerID: customerID, Sort: []repository.SortKey{{Field: repository.SortByTotalPrice}}})
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, placed[0].ID, listed[0].ID)
+	assert.Equal(t, placed[0].Items, listed[0].Items)
+	assert.Equal(t, domain.OrderStatusProcessing, listed[1].Status)
+
+	var streamed []uuid.UUID
+	require.NoError(t, reads.StreamOrders(ctx, repository.OrderFilter{CustomerID: customerID}, func(order *domain.Order) error {
+		streamed = append(streamed, order.ID)
+		return nil
+	}))
+	assert.Len(t, streamed, 2)
+
+	// The hour of the orders is served by the dashboard, which moved the
+	// processing order out of pending.
+	hour := repository.OrderFilter{CreatedFrom: createdAt.Truncate(time.Hour), CreatedTo: createdAt.Truncate(time.Hour).Add(time.Hour)}
+	stats, err := reads.OrderStats(ctx, hour)
+	require.NoError(t, err)
+	assert.Equal(t, []repository.OrderStatusStats{
+		{Status: domain.OrderStatusPending, Orders: 1, Items: 1, TotalPrice: 2},
+		{Status: domain.OrderStatusProcessing, Orders: 1, Items: 3, TotalPrice: 6},
+	}, stats)
+	byCustomer, err := reads.OrderStats(ctx, repository.OrderFilter{CustomerID: customerID})
+	require.NoError(t, err)
+	assert.Equal(t, stats, byCustomer)
+
+	// Projecting again applies nothing.
+	n, _, err := projections.ProjectOrderEvents(ctx, 100)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ReadModelOrderRepository serves the queries over many orders, ListOrders,
+// StreamOrders and OrderStats, from the read models kept by the projection
+// worker, and everything else from the OrderRepository it wraps. The read
+// models lag the writes by up to the worker's poll interval, so a new order
+// or status change may take that long to show up in lists and stats.
+type ReadModelOrderRepository struct {
+	OrderRepository
+	// views runs the read model queries, with its slow-query logging and
+	// latency observer.
+	views *PostgresOrderRepository
+}
+
+var _ OrderRepository = (*ReadModelOrderRepository)(nil)
+
+// NewReadModelOrderRepository creates a repository that writes and reads
+// single orders with orders and queries the read models with views.
+func NewReadModelOrderRepository(orders OrderRepository, views *PostgresOrderRepository) *ReadModelOrderRepository {
+	return &ReadModelOrderRepository{OrderRepository: orders, views: views}
+}
+
+// startReadModelSpan starts a client span for a read model query.
+func startReadModelSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "ReadModelOrderRepository."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", operation),
+		),
+	)
+}
+
+// ListOrders reads the orders matching filter, with their items, from
+// order_list_view.
+func (r *ReadModelOrderRepository) ListOrders(ctx context.Context, filter OrderFilter) (_ []*domain.Order, err error) {
+	ctx, span := startReadModelSpan(ctx, "ListOrders")
+	defer func() { endSpan(span, err) }()
+
+	where, args := filter.conditions()
+	query := `
+		SELECT ` + orderViewColumns + `
+		FROM order_list_view` + where + `
+		ORDER BY ` + filter.orderBy()
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	start := time.Now()
+	rows, err := r.views.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.views.observeQuery(ctx, "list_order_views", uuid.Nil, start, err)
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*domain.Order
+	for rows.Next() {
+		order, err := scanOrderViewRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	err = rows.Err()
+	r.views.observeQuery(ctx, "list_order_views", uuid.Nil, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("error iterating over orders: %w", err)
+	}
+	return orders, nil
+}
+
+// StreamOrders reads the orders matching filter from order_list_view, oldest
+// first, in batches of streamBatchSize like PostgresOrderRepository.StreamOrders.
+func (r *ReadModelOrderRepository) StreamOrders(ctx context.Context, filter OrderFilter, fn func(*domain.Order) error) (err error) {
+	ctx, span := startReadModelSpan(ctx, "StreamOrders")
+	defer func() { endSpan(span, err) }()
+
+	return streamInBatches(filter, func(after *domain.Order, offset, limit int) (int, *domain.Order, error) {
+		return r.streamBatch(ctx, filter, after, offset, limit, fn)
+	})
+}
+
+func (r *ReadModelOrderRepository) streamBatch(ctx context.Context, filter OrderFilter, after *domain.Order, offset, limit int, fn func(*domain.Order) error) (int, *domain.Order, error) {
+	where, args := filter.conditions()
+	where, args = afterCursor(where, args, after)
+	args = append(args, limit)
+	query := `
+		SELECT ` + orderViewColumns + `
+		FROM order_list_view` + where + `
+		ORDER BY created_at, order_id` + fmt.Sprintf(" LIMIT $%d", len(args))
+	if offset > 0 {
+		args = append(args, offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	start := time.Now()
+	rows, err := r.views.db.QueryContext(ctx, query, args...)
+	r.views.observeQuery(ctx, "stream_order_views", uuid.Nil, start, err)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to stream orders: %w", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	var last *domain.Order
+	for rows.Next() {
+		order, err := scanOrderViewRow(rows)
+		if err != nil {
+			return count, last, err
+		}
+		if err := fn(order); err != nil {
+			return count, last, err
+		}
+		count, last = count+1, order
+	}
+	if err := rows.Err(); err != nil {
+		return count, last, fmt.Errorf("error iterating over orders: %w", err)
+	}
+	return count, last, nil
+}
+
+// OrderStats totals the orders matching filter per status. Filters on status
+// and whole hours of creation are served from order_status_dashboard without
+// reading the orders; others aggregate order_list_view.
+func (r *ReadModelOrderRepository) OrderStats(ctx context.Context, filter OrderFilter) (_ []OrderStatusStats, err error) {
+	ctx, span := startReadModelSpan(ctx, "OrderStats")
+	defer func() { endSpan(span, err) }()
+
+	name := "order_dashboard_stats"
+	where, args := dashboardConditions(filter)
+	query := `
+		SELECT status, SUM(orders), SUM(items), SUM(total_price)
+		FROM order_status_dashboard` + where + `
+		GROUP BY status
+		HAVING SUM(orders) > 0
+		ORDER BY status`
+	if !servedByDashboard(filter) {
+		name = "order_view_stats"
+		where, args = filter.conditions()
+		query = `
+		SELECT status, COUNT(*), COALESCE(SUM(total_quantity), 0), COALESCE(SUM(total_price), 0)
+		FROM order_list_view` + where + `
+		GROUP BY status
+		ORDER BY status`
+	}
+
+	start := time.Now()
+	rows, err := r.views.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.views.observeQuery(ctx, name, uuid.Nil, start, err)
+		return nil, fmt.Errorf("failed to get order stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []OrderStatusStats
+	for rows.Next() {
+		var s OrderStatusStats
+		if err := rows.Scan(&s.Status, &s.Orders, &s.Items, &s.TotalPrice); err != nil {
+			return nil, fmt.Errorf("failed to scan order stats: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	err = rows.Err()
+	r.views.observeQuery(ctx, name, uuid.Nil, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("error iterating over order stats: %w", err)
+	}
+	return stats, nil
+}
+
+// servedByDashboard reports whether order_status_dashboard holds the stats
+// of the orders matching filter: its buckets are per status and hour.
+func servedByDashboard(filter OrderFilter) bool {
+	wholeHour := func(t time.Time) bool { return t.IsZero() || t.Equal(dashboardHour(t)) }
+	return filter.CustomerID == uuid.Nil && wholeHour(filter.CreatedFrom) && wholeHour(filter.CreatedTo)
+}
+
+// dashboardConditions renders the filter as a WHERE clause over
+// order_status_dashboard and its arguments.
+func dashboardConditions(filter OrderFilter) (string, []any) {
+	where, args := OrderFilter{Status: filter.Status, CreatedFrom: filter.CreatedFrom, CreatedTo: filter.CreatedTo}.conditions()
+	return strings.ReplaceAll(where, "created_at", "created_hour"), args
+}
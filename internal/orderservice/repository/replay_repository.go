@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// replayBatchSize is how many events the replay streams read per query.
+const replayBatchSize = 500
+
+// PostgresReplayRepository reads the outbox and order_events back for
+// replaying them.
+type PostgresReplayRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresReplayRepository creates a new instance of PostgresReplayRepository.
+func NewPostgresReplayRepository(db *sql.DB) *PostgresReplayRepository {
+	return &PostgresReplayRepository{db: db}
+}
+
+var _ ReplayRepository = (*PostgresReplayRepository)(nil)
+
+// StreamOutbox hands fn the published outbox messages matching filter, by
+// ID. Unpublished messages are left to the relay. With the Debezium relay
+// the outbox keeps no messages, so there is nothing to stream.
+func (r *PostgresReplayRepository) StreamOutbox(ctx context.Context, filter ReplayFilter, fn func(StoredEvent) error) error {
+	conditions, args := filter.conditions("aggregatetype", "aggregateid", "created_at")
+	conditions = append(conditions, "published_at IS NOT NULL")
+	var after int64
+	for {
+		batchArgs := append(slices.Clip(args), after, replayBatchSize)
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT id, aggregatetype, aggregateid, type, payload, created_at
+			FROM outbox
+			WHERE `+strings.Join(conditions, " AND ")+fmt.Sprintf(` AND id > $%d
+			ORDER BY id
+			LIMIT $%d`, len(batchArgs)-1, len(batchArgs)), batchArgs...)
+		if err != nil {
+			return fmt.Errorf("failed to read outbox: %w", err)
+		}
+		n, err := scanOutboxEvents(rows, &after, fn)
+		if err != nil || n < replayBatchSize {
+			return err
+		}
+	}
+}
+
+// StreamOrderEvents hands fn the order events matching filter in the order
+// their transactions committed, the order the projection worker applies
+// them in. Order events have no topic, so a filter on one matches none.
+func (r *PostgresReplayRepository) StreamOrderEvents(ctx context.Context, filter ReplayFilter, fn func(StoredEvent) error) error {
+	if filter.Topic != "" {
+		return nil
+	}
+	conditions, args := filter.conditions("", "order_id", "recorded_at")
+	afterTransaction, afterPosition := "0", int64(0)
+	for {
+		batchArgs := append(slices.Clip(args), afterTransaction, afterPosition, replayBatchSize)
+		n := len(batchArgs)
+		cursor := fmt.Sprintf("(transaction_id, position) > ($%d::xid8, $%d)", n-2, n-1)
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT transaction_id::text, position, order_id::text, type,
+				jsonb_build_object('order_id', order_id, 'version', version, 'type', type,
+					'occurred_at', occurred_at, 'data', data),
+				recorded_at
+			FROM order_events
+			WHERE `+strings.Join(append(slices.Clip(conditions), cursor), " AND ")+fmt.Sprintf(`
+			ORDER BY transaction_id, position
+			LIMIT $%d`, n), batchArgs...)
+		if err != nil {
+			return fmt.Errorf("failed to read order events: %w", err)
+		}
+		count, err := scanOrderEventRows(rows, &afterTransaction, &afterPosition, fn)
+		if err != nil || count < replayBatchSize {
+			return err
+		}
+	}
+}
+
+// conditions returns the WHERE conditions of f on the given columns and
+// their arguments. An empty topicColumn ignores f.Topic.
+func (f ReplayFilter) conditions(topicColumn, keyColumn, timeColumn string) ([]string, []any) {
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if topicColumn != "" && f.Topic != "" {
+		add(topicColumn+" = $%d", f.Topic)
+	}
+	if f.Type != "" {
+		add("type = $%d", f.Type)
+	}
+	if f.OrderID != uuid.Nil {
+		add(keyColumn+" = $%d", f.OrderID.String())
+	}
+	if !f.From.IsZero() {
+		add(timeColumn+" >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add(timeColumn+" < $%d", f.To)
+	}
+	return conditions, args
+}
+
+// scanOutboxEvents hands fn the outbox messages in rows, recording the ID
+// of the last one in after, and returns their number.
+func scanOutboxEvents(rows *sql.Rows, after *int64, fn func(StoredEvent) error) (int, error) {
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var event StoredEvent
+		var key string
+		if err := rows.Scan(after, &event.Topic, &key, &event.Type, &event.Value, &event.StoredAt); err != nil {
+			return n, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		event.Key = []byte(key)
+		if err := fn(event); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("error iterating over outbox messages: %w", err)
+	}
+	return n, nil
+}
+
+// scanOrderEventRows hands fn the order events in rows, recording the
+// commit position of the last one, and returns their number.
+func scanOrderEventRows(rows *sql.Rows, afterTransaction *string, afterPosition *int64, fn func(StoredEvent) error) (int, error) {
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var event StoredEvent
+		var key string
+		if err := rows.Scan(afterTransaction, afterPosition, &key, &event.Type, &event.Value, &event.StoredAt); err != nil {
+			return n, fmt.Errorf("failed to scan order event: %w", err)
+		}
+		event.Key = []byte(key)
+		if err := fn(event); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("error iterating over order events: %w", err)
+	}
+	return n, nil
+}
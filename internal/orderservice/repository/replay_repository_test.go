@@ -0,0 +1,80 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresReplayRepository(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Database not available for integration tests")
+	}
+
+	orders := repository.NewPostgresOrderRepository(testDB)
+	outbox := repository.NewPostgresOutboxRepository(testDB)
+	writes := repository.NewEventSourcedOrderRepository(orders, repository.EventSourcingConfig{Enabled: true})
+	replays := repository.NewPostgresReplayRepository(testDB)
+	ctx := context.Background()
+	_, err := testDB.Exec("DELETE FROM outbox")
+	require.NoError(t, err)
+
+	collect := func(stream func(context.Context, repository.ReplayFilter, func(repository.StoredEvent) error) error, filter repository.ReplayFilter) []repository.StoredEvent {
+		t.Helper()
+		var stored []repository.StoredEvent
+		require.NoError(t, stream(ctx, filter, func(event repository.StoredEvent) error {
+			stored = append(stored, event)
+			return nil
+		}))
+		return stored
+	}
+
+	var placed []*domain.Order
+	for range 2 {
+		order, err := domain.NewOrder(uuid.New(), []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 5.0}})
+		require.NoError(t, err)
+		msg := repository.OutboxMessage{Topic: "orders.placed", Key: []byte(order.ID.String()), Type: "OrderPlaced", Value: []byte(`{"ok":true}`)}
+		require.NoError(t, writes.CreateOrderWithOutbox(ctx, order, msg))
+		placed = append(placed, order)
+	}
+	require.NoError(t, writes.UpdateOrderStatus(ctx, placed[0].ID, domain.OrderStatusProcessing, time.Now()))
+
+	// Only published outbox messages are replayed.
+	assert.Empty(t, collect(replays.StreamOutbox, repository.ReplayFilter{}))
+	claimed, err := outbox.ClaimOutbox(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, outbox.MarkOutboxPublished(ctx, []int64{claimed[0].ID, claimed[1].ID}))
+
+	stored := collect(replays.StreamOutbox, repository.ReplayFilter{Topic: "orders.placed"})
+	require.Len(t, stored, 2)
+	assert.Equal(t, []byte(placed[0].ID.String()), stored[0].Key)
+	assert.Equal(t, "OrderPlaced", stored[0].Type)
+	assert.JSONEq(t, `{"ok":true}`, string(stored[0].Value))
+	assert.Len(t, collect(replays.StreamOutbox, repository.ReplayFilter{OrderID: placed[1].ID}), 1)
+	assert.Empty(t, collect(replays.StreamOutbox, repository.ReplayFilter{To: placed[0].CreatedAt.Add(-time.Hour)}))
+
+	events := collect(replays.StreamOrderEvents, repository.ReplayFilter{OrderID: placed[0].ID})
+	require.Len(t, events, 2)
+	assert.Equal(t, string(domain.OrderCreatedEvent), events[0].Type)
+	assert.Equal(t, string(domain.OrderStatusChangedEvent), events[1].Type)
+	var envelope struct {
+		OrderID uuid.UUID       `json:"order_id"`
+		Version int             `json:"version"`
+		Data    json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(events[1].Value, &envelope))
+	assert.Equal(t, placed[0].ID, envelope.OrderID)
+	assert.Equal(t, 2, envelope.Version)
+	assert.JSONEq(t, `{"from":"pending","to":"processing"}`, string(envelope.Data))
+
+	changes := collect(replays.StreamOrderEvents, repository.ReplayFilter{Type: string(domain.OrderStatusChangedEvent), OrderID: placed[1].ID})
+	assert.Empty(t, changes)
+	assert.Empty(t, collect(replays.StreamOrderEvents, repository.ReplayFilter{Topic: "orders.placed"}))
+}
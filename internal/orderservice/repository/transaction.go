@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// Transaction isolation levels accepted by TxConfig.Isolation.
+const (
+	IsolationReadCommitted  = "read_committed"
+	IsolationRepeatableRead = "repeatable_read"
+	IsolationSerializable   = "serializable"
+)
+
+// TxConfig sets how the repository runs the transactions that write orders.
+type TxConfig struct {
+	// Isolation is read_committed, repeatable_read or serializable.
+	Isolation string `key:"isolation" env:"DB_TX_ISOLATION" default:"read_committed"`
+	// MaxRetries is how many times a transaction aborted by a serialization
+	// failure or a deadlock is run again.
+	MaxRetries int `key:"max_retries" env:"DB_TX_MAX_RETRIES" default:"3"`
+}
+
+// ParseIsolation returns the isolation level named s.
+func ParseIsolation(s string) (sql.IsolationLevel, bool) {
+	switch s {
+	case IsolationReadCommitted:
+		return sql.LevelReadCommitted, true
+	case IsolationRepeatableRead:
+		return sql.LevelRepeatableRead, true
+	case IsolationSerializable:
+		return sql.LevelSerializable, true
+	}
+	return 0, false
+}
+
+// WithTransactions runs the transactions that write orders at the isolation
+// level of cfg, and runs again those PostgreSQL aborts with a serialization
+// failure or a deadlock. Stricter levels abort more transactions.
+func WithTransactions(cfg TxConfig) Option {
+	return func(r *PostgresOrderRepository) {
+		if level, ok := ParseIsolation(cfg.Isolation); ok {
+			r.isolation = level
+		}
+		r.maxTxRetries = max(0, cfg.MaxRetries)
+	}
+}
+
+// Base and cap of the jittered backoff between transaction attempts.
+const (
+	txRetryBaseDelay = 10 * time.Millisecond
+	txRetryMaxDelay  = 200 * time.Millisecond
+)
+
+// inTx runs fn in a transaction and commits it. A transaction that
+// PostgreSQL aborts with a serialization failure or a deadlock is rolled back
+// and run again, up to the configured number of retries, so fn must be safe
+// to repeat.
+func (r *PostgresOrderRepository) inTx(ctx context.Context, operation string, orderID uuid.UUID, fn func(tx *sql.Tx) error) error {
+	for attempt := 0; ; attempt++ {
+		err := r.runTx(ctx, orderID, fn)
+		if err == nil || !isRetryableTxError(err) || attempt >= r.maxTxRetries {
+			return err
+		}
+		metrics.DBTransactionRetriesTotal.WithLabelValues(operation).Inc()
+		delay := min(txRetryMaxDelay, txRetryBaseDelay<<attempt)
+		delay = delay/2 + rand.N(delay/2+1)
+		log.Ctx(ctx).Debug().Err(err).
+			Str("operation", operation).
+			Str("order_id", orderID.String()).
+			Int("attempt", attempt+1).
+			Msg("Repository: retrying transaction")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// runTx makes one attempt at running fn in a transaction.
+func (r *PostgresOrderRepository) runTx(ctx context.Context, orderID uuid.UUID, fn func(tx *sql.Tx) error) error {
+	start := time.Now()
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: r.isolation})
+	r.observeQuery(ctx, "begin", orderID, start, err)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	start = time.Now()
+	err = tx.Commit()
+	r.observeQuery(ctx, "commit", orderID, start, err)
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// isRetryableTxError reports whether err aborted a transaction that may
+// succeed if run again: serialization_failure, deadlock_detected, or a
+// unique_violation of order_events' key by a concurrent append to a stream.
+func isRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	if pqErr.Code == "23505" {
+		return pqErr.Constraint == orderEventsKeyConstraint
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
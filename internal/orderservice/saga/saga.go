@@ -0,0 +1,176 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/service"
+	"github.com/rs/zerolog/log"
+)
+
+// Orchestrator coordinates the order flow in orchestration mode. It turns
+// OrderPlaced events into ReserveInventory commands for the inventory service.
+type Orchestrator struct {
+	commands broker.EventPublisher
+}
+
+// NewOrchestrator creates an Orchestrator that publishes commands with the given producer.
+func NewOrchestrator(commands broker.EventPublisher) *Orchestrator {
+	return &Orchestrator{commands: commands}
+}
+
+// HandleOrderPlaced issues a ReserveInventory command for the placed order.
+func (o *Orchestrator) HandleOrderPlaced(ctx context.Context, key, value []byte) error {
+	var event events.OrderPlaced
+	if err := json.Unmarshal(value, &event); err != nil {
+		return fmt.Errorf("saga: failed to unmarshal order placed event: %w", err)
+	}
+
+	command, err := json.Marshal(events.ReserveInventory(event))
+	if err != nil {
+		return fmt.Errorf("saga: failed to marshal reserve inventory command: %w", err)
+	}
+	if err := o.commands.PublishMessage(ctx, key, command); err != nil {
+		return fmt.Errorf("saga: failed to publish reserve inventory command: %w", err)
+	}
+
+	log.Ctx(ctx).Info().Str("order_id", event.OrderID.String()).Msg("Saga: reserve inventory command issued")
+	return nil
+}
+
+// InventoryEventHandler applies inventory outcomes to the order status. It is
+// used in both flow modes since the inventory service always reports on the
+// same topic.
+func InventoryEventHandler(orderService service.OrderService) broker.MessageHandler {
+	return func(ctx context.Context, _, value []byte) error {
+		var event events.InventoryEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return fmt.Errorf("saga: failed to unmarshal inventory event: %w", err)
+		}
+
+		var next domain.OrderStatus
+		switch event.Type {
+		case events.InventoryReserved:
+			next = domain.OrderStatusProcessing
+		case events.InventoryReservationFailed:
+			next = domain.OrderStatusFailed
+		case events.InventoryBackorderAllocated:
+			// The order was already reserved, with this line backordered.
+			log.Ctx(ctx).Info().Str("order_id", event.OrderID.String()).Msg("Saga: backordered stock allocated")
+			return nil
+		default:
+			log.Ctx(ctx).Warn().Str("type", string(event.Type)).Msg("Saga: ignoring unknown inventory event type")
+			return nil
+		}
+
+		return applyStatus(ctx, orderService, event.OrderID, next, string(event.Type))
+	}
+}
+
+// PaymentEventHandler applies payment outcomes to the order status: a paid
+// order is processed, and an order whose payment failed fails. The stock
+// reserved for a failed order is released by a ReleaseInventory command
+// published with releases. A failure to publish it is returned, so the
+// event is delivered again: the order is then already failed, which is not
+// an error, and the release is published again.
+func PaymentEventHandler(orderService service.OrderService, releases broker.EventPublisher) broker.MessageHandler {
+	return func(ctx context.Context, _, value []byte) error {
+		var event events.PaymentEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return fmt.Errorf("saga: failed to unmarshal payment event: %w", err)
+		}
+
+		var next domain.OrderStatus
+		switch event.Type {
+		case events.PaymentSucceeded:
+			next = domain.OrderStatusProcessing
+		case events.PaymentFailed:
+			next = domain.OrderStatusFailed
+		default:
+			log.Ctx(ctx).Warn().Str("type", string(event.Type)).Msg("Saga: ignoring unknown payment event type")
+			return nil
+		}
+
+		if err := applyStatus(ctx, orderService, event.OrderID, next, string(event.Type)); err != nil {
+			return err
+		}
+		if event.Type == events.PaymentFailed {
+			reason := "payment failed"
+			if event.Reason != "" {
+				reason += ": " + event.Reason
+			}
+			return releaseInventory(ctx, releases, event.OrderID, reason)
+		}
+		return nil
+	}
+}
+
+// releaseInventory asks the inventory service to free the stock of a
+// failed order.
+func releaseInventory(ctx context.Context, releases broker.EventPublisher, orderID uuid.UUID, reason string) error {
+	command, err := json.Marshal(events.ReleaseInventory{OrderID: orderID, Reason: reason, Timestamp: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("saga: failed to marshal release inventory command: %w", err)
+	}
+	if err := releases.PublishMessage(ctx, []byte(orderID.String()), command); err != nil {
+		return fmt.Errorf("saga: failed to publish release inventory command: %w", err)
+	}
+	log.Ctx(ctx).Info().Str("order_id", orderID.String()).Msg("Saga: release inventory command issued")
+	return nil
+}
+
+// ShippingEventHandler applies tracking updates to orders: a delivered
+// order is completed, and a shipping exception is flagged for operators in
+// the logs and the shipping_exceptions_total metric, leaving the order
+// processing while the carrier resolves it.
+func ShippingEventHandler(orderService service.OrderService) broker.MessageHandler {
+	return func(ctx context.Context, _, value []byte) error {
+		var event events.ShippingEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return fmt.Errorf("saga: failed to unmarshal shipping event: %w", err)
+		}
+
+		switch event.Type {
+		case events.ShippingDelivered:
+			return applyStatus(ctx, orderService, event.OrderID, domain.OrderStatusCompleted, string(event.Type))
+		case events.ShippingException:
+			metrics.ShippingExceptionsTotal.WithLabelValues(event.Carrier).Inc()
+			log.Ctx(ctx).Warn().
+				Str("order_id", event.OrderID.String()).
+				Str("carrier", event.Carrier).
+				Str("tracking_number", event.TrackingNumber).
+				Str("reason", event.Reason).
+				Msg("Saga: shipping exception reported")
+			return nil
+		default:
+			log.Ctx(ctx).Warn().Str("type", string(event.Type)).Msg("Saga: ignoring unknown shipping event type")
+			return nil
+		}
+	}
+}
+
+// applyStatus moves the order to next on behalf of the event. Inventory and
+// payment outcomes both move orders to processing or failed, so an order
+// already in next was moved there by the other one, or by a redelivery of
+// the event, and is left as it is.
+func applyStatus(ctx context.Context, orderService service.OrderService, orderID uuid.UUID, next domain.OrderStatus, eventType string) error {
+	_, err := orderService.UpdateOrderStatus(ctx, orderID, next)
+	var transitionErr *domain.InvalidTransitionError
+	if errors.As(err, &transitionErr) && transitionErr.From == next {
+		log.Ctx(ctx).Info().Str("order_id", orderID.String()).Str("event", eventType).Str("status", string(next)).
+			Msg("Saga: order already has the status the event leads to")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("saga: failed to apply %s to order %s: %w", eventType, orderID, err)
+	}
+	return nil
+}
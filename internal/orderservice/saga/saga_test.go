@@ -0,0 +1,164 @@
+package saga_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/saga"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusService keeps order statuses in memory and applies the domain's
+// transition rules to them.
+type statusService struct {
+	service.OrderService
+	orders map[uuid.UUID]domain.OrderStatus
+}
+
+func (s *statusService) UpdateOrderStatus(_ context.Context, orderID uuid.UUID, status domain.OrderStatus) (*domain.Order, error) {
+	order := &domain.Order{ID: orderID, Status: s.orders[orderID]}
+	if err := order.TransitionTo(status); err != nil {
+		return nil, &domain.InvalidTransitionError{From: s.orders[orderID], To: status}
+	}
+	s.orders[orderID] = status
+	return order, nil
+}
+
+// recordingPublisher records the messages published with it, or fails
+// while err is set.
+type recordingPublisher struct {
+	err    error
+	values [][]byte
+}
+
+func (p *recordingPublisher) PublishMessage(_ context.Context, _, value []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.values = append(p.values, value)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func TestPaymentEventHandler(t *testing.T) {
+	paid, declined, reserved, failed := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	svc := &statusService{orders: map[uuid.UUID]domain.OrderStatus{
+		paid:     domain.OrderStatusPending,
+		declined: domain.OrderStatusPending,
+		reserved: domain.OrderStatusProcessing,
+		failed:   domain.OrderStatusFailed,
+	}}
+	handle := saga.PaymentEventHandler(svc, &recordingPublisher{})
+	ctx := context.Background()
+	send := func(eventType events.PaymentEventType, orderID uuid.UUID) error {
+		value, err := json.Marshal(events.PaymentEvent{Type: eventType, OrderID: orderID, Amount: 1999, Currency: "USD"})
+		require.NoError(t, err)
+		return handle(ctx, []byte(orderID.String()), value)
+	}
+
+	require.NoError(t, send(events.PaymentSucceeded, paid))
+	assert.Equal(t, domain.OrderStatusProcessing, svc.orders[paid])
+	require.NoError(t, send(events.PaymentFailed, declined))
+	assert.Equal(t, domain.OrderStatusFailed, svc.orders[declined])
+
+	// The inventory reservation already moved the order to processing.
+	require.NoError(t, send(events.PaymentSucceeded, reserved))
+	assert.Equal(t, domain.OrderStatusProcessing, svc.orders[reserved])
+
+	// A failed order can't be processed, however it was paid.
+	err := send(events.PaymentSucceeded, failed)
+	assert.ErrorIs(t, err, domain.ErrInvalidOrderStatusTransition)
+	assert.Equal(t, domain.OrderStatusFailed, svc.orders[failed])
+
+	require.NoError(t, send("payment.disputed", paid))
+	assert.Error(t, handle(ctx, nil, []byte("{")))
+}
+
+func TestPaymentEventHandler_ReleasesInventoryOfFailedOrders(t *testing.T) {
+	reserved, completed, paid := uuid.New(), uuid.New(), uuid.New()
+	svc := &statusService{orders: map[uuid.UUID]domain.OrderStatus{
+		reserved:  domain.OrderStatusProcessing,
+		completed: domain.OrderStatusCompleted,
+		paid:      domain.OrderStatusPending,
+	}}
+	releases := &recordingPublisher{}
+	handle := saga.PaymentEventHandler(svc, releases)
+	ctx := context.Background()
+	send := func(orderID uuid.UUID) error {
+		value, err := json.Marshal(events.PaymentEvent{Type: events.PaymentFailed, OrderID: orderID, Reason: "card declined"})
+		require.NoError(t, err)
+		return handle(ctx, []byte(orderID.String()), value)
+	}
+
+	// The order's stock was reserved before its payment failed.
+	require.NoError(t, send(reserved))
+	assert.Equal(t, domain.OrderStatusFailed, svc.orders[reserved])
+	require.Len(t, releases.values, 1)
+	var command events.ReleaseInventory
+	require.NoError(t, json.Unmarshal(releases.values[0], &command))
+	assert.Equal(t, reserved, command.OrderID)
+	assert.Equal(t, "payment failed: card declined", command.Reason)
+
+	// An order that can't fail keeps its stock.
+	assert.Error(t, send(completed))
+	assert.Len(t, releases.values, 1)
+
+	// Successful payments release nothing.
+	value, err := json.Marshal(events.PaymentEvent{Type: events.PaymentSucceeded, OrderID: paid})
+	require.NoError(t, err)
+	require.NoError(t, handle(ctx, nil, value))
+	assert.Len(t, releases.values, 1)
+}
+
+func TestPaymentEventHandler_RedeliveryPublishesLostRelease(t *testing.T) {
+	orderID := uuid.New()
+	svc := &statusService{orders: map[uuid.UUID]domain.OrderStatus{orderID: domain.OrderStatusProcessing}}
+	releases := &recordingPublisher{err: errors.New("broker unavailable")}
+	handle := saga.PaymentEventHandler(svc, releases)
+	value, err := json.Marshal(events.PaymentEvent{Type: events.PaymentFailed, OrderID: orderID})
+	require.NoError(t, err)
+
+	// The order fails but its release is lost, so the event must be
+	// delivered again.
+	assert.ErrorContains(t, handle(context.Background(), nil, value), "broker unavailable")
+	assert.Equal(t, domain.OrderStatusFailed, svc.orders[orderID])
+	assert.Empty(t, releases.values)
+
+	releases.err = nil
+	require.NoError(t, handle(context.Background(), nil, value))
+	require.Len(t, releases.values, 1)
+	var command events.ReleaseInventory
+	require.NoError(t, json.Unmarshal(releases.values[0], &command))
+	assert.Equal(t, orderID, command.OrderID)
+}
+
+func TestShippingEventHandler(t *testing.T) {
+	delivered, delayed := uuid.New(), uuid.New()
+	svc := &statusService{orders: map[uuid.UUID]domain.OrderStatus{
+		delivered: domain.OrderStatusProcessing,
+		delayed:   domain.OrderStatusProcessing,
+	}}
+	handle := saga.ShippingEventHandler(svc)
+	ctx := context.Background()
+	send := func(eventType events.ShippingEventType, orderID uuid.UUID) error {
+		value, err := json.Marshal(events.ShippingEvent{Type: eventType, OrderID: orderID, Carrier: "dhl", TrackingNumber: "JD0123"})
+		require.NoError(t, err)
+		return handle(ctx, []byte(orderID.String()), value)
+	}
+
+	require.NoError(t, send(events.ShippingDelivered, delivered))
+	assert.Equal(t, domain.OrderStatusCompleted, svc.orders[delivered])
+	// A redelivered event finds the order completed already.
+	require.NoError(t, send(events.ShippingDelivered, delivered))
+
+	require.NoError(t, send(events.ShippingException, delayed))
+	assert.Equal(t, domain.OrderStatusProcessing, svc.orders[delayed])
+}
@@ -0,0 +1,214 @@
+// Package seed generates realistic demo data for local development: customers,
+// a product catalog and orders in a plausible mix of statuses. Orders are built
+// with the domain package, so seeded data obeys the same rules as real orders.
+package seed
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+)
+
+// CustomerWriter stores seeded customers.
+type CustomerWriter interface {
+	CreateCustomer(ctx context.Context, id uuid.UUID, email string) error
+}
+
+// OrderWriter stores seeded orders.
+type OrderWriter interface {
+	CreateOrder(ctx context.Context, order *domain.Order) error
+}
+
+// Options controls the volume and shape of the generated data.
+type Options struct {
+	Customers        int
+	Products         int
+	Orders           int
+	MaxItemsPerOrder int
+	// Days spreads order creation times over this many days before now.
+	Days int
+	// Seed makes the generated data reproducible.
+	Seed int64
+}
+
+// DefaultOptions is a small data set suitable for local development.
+var DefaultOptions = Options{Customers: 50, Products: 40, Orders: 200, MaxItemsPerOrder: 5, Days: 30, Seed: 1}
+
+// Product is a generated catalog entry. Products live in the external catalog
+// service, so they are returned to the caller rather than stored.
+type Product struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Price float64   `json:"price"`
+}
+
+// Summary reports what a Run generated.
+type Summary struct {
+	Customers      int
+	Products       []Product
+	Orders         int
+	OrdersByStatus map[domain.OrderStatus]int
+}
+
+// Seeder writes generated data through the given writers.
+type Seeder struct {
+	customers CustomerWriter
+	orders    OrderWriter
+	opts      Options
+	rng       *rand.Rand
+	now       func() time.Time
+}
+
+// New creates a Seeder.
+func New(customers CustomerWriter, orders OrderWriter, opts Options) *Seeder {
+	return &Seeder{
+		customers: customers,
+		orders:    orders,
+		opts:      opts,
+		rng:       rand.New(rand.NewSource(opts.Seed)),
+		now:       time.Now,
+	}
+}
+
+// Run generates and stores the data set.
+func (s *Seeder) Run(ctx context.Context) (Summary, error) {
+	if s.opts.Customers < 1 || s.opts.Products < 1 || s.opts.MaxItemsPerOrder < 1 {
+		return Summary{}, fmt.Errorf("customers, products and max items per order must be at least 1")
+	}
+
+	summary := Summary{OrdersByStatus: map[domain.OrderStatus]int{}}
+
+	customerIDs := make([]uuid.UUID, s.opts.Customers)
+	for i := range customerIDs {
+		customerIDs[i] = s.uuid()
+		if err := s.customers.CreateCustomer(ctx, customerIDs[i], s.email(i)); err != nil {
+			return summary, fmt.Errorf("failed to seed customer %d: %w", i+1, err)
+		}
+		summary.Customers++
+	}
+
+	summary.Products = make([]Product, s.opts.Products)
+	for i := range summary.Products {
+		summary.Products[i] = s.product()
+	}
+
+	for i := 0; i < s.opts.Orders; i++ {
+		order, err := s.order(customerIDs, summary.Products)
+		if err != nil {
+			return summary, fmt.Errorf("failed to build order %d: %w", i+1, err)
+		}
+		if err := s.orders.CreateOrder(ctx, order); err != nil {
+			return summary, fmt.Errorf("failed to seed order %d: %w", i+1, err)
+		}
+		summary.Orders++
+		summary.OrdersByStatus[order.Status]++
+	}
+	return summary, nil
+}
+
+var (
+	firstNames = []string{"amara", "kwame", "lena", "mateo", "priya", "noah", "yuki", "fatima", "liam", "zara", "omar", "sofia"}
+	lastNames  = []string{"mensah", "schmidt", "garcia", "patel", "okafor", "tanaka", "nguyen", "silva", "kowalski", "dubois"}
+	domains    = []string{"example.com", "example.org", "mail.example.net"}
+
+	adjectives = []string{"Classic", "Wireless", "Organic", "Compact", "Premium", "Ergonomic", "Vintage", "Smart"}
+	nouns      = []string{"Headphones", "Coffee Beans", "Backpack", "Desk Lamp", "Water Bottle", "Keyboard", "Sneakers", "Notebook", "Throw Blanket", "Phone Case"}
+)
+
+// statusWeights approximates the status mix of a live shop.
+var statusWeights = []struct {
+	status domain.OrderStatus
+	weight int
+}{
+	{domain.OrderStatusCompleted, 55},
+	{domain.OrderStatusProcessing, 15},
+	{domain.OrderStatusPending, 15},
+	{domain.OrderStatusCancelled, 10},
+	{domain.OrderStatusFailed, 5},
+}
+
+// statusPaths are the transitions used to reach each status from pending.
+var statusPaths = map[domain.OrderStatus][]domain.OrderStatus{
+	domain.OrderStatusPending:    nil,
+	domain.OrderStatusProcessing: {domain.OrderStatusProcessing},
+	domain.OrderStatusCompleted:  {domain.OrderStatusProcessing, domain.OrderStatusCompleted},
+	domain.OrderStatusCancelled:  {domain.OrderStatusCancelled},
+	domain.OrderStatusFailed:     {domain.OrderStatusFailed},
+}
+
+func (s *Seeder) order(customerIDs []uuid.UUID, products []Product) (*domain.Order, error) {
+	n := 1 + s.rng.Intn(s.opts.MaxItemsPerOrder)
+	picked := map[int]bool{}
+	items := make([]domain.OrderItem, 0, n)
+	for len(items) < n && len(picked) < len(products) {
+		i := s.rng.Intn(len(products))
+		if picked[i] {
+			continue
+		}
+		picked[i] = true
+		items = append(items, domain.OrderItem{
+			ProductID: products[i].ID,
+			Quantity:  1 + s.rng.Intn(3),
+			UnitPrice: products[i].Price,
+		})
+	}
+
+	order, err := domain.NewOrder(customerIDs[s.rng.Intn(len(customerIDs))], items)
+	if err != nil {
+		return nil, err
+	}
+	order.ID = s.uuid()
+
+	for _, next := range statusPaths[s.status()] {
+		if err := order.TransitionTo(next); err != nil {
+			return nil, err
+		}
+	}
+
+	createdAt := s.now().Add(-time.Duration(s.rng.Int63n(int64(max(s.opts.Days, 1)) * int64(24*time.Hour))))
+	order.CreatedAt = createdAt
+	order.UpdatedAt = createdAt
+	if order.Status != domain.OrderStatusPending {
+		order.UpdatedAt = createdAt.Add(time.Duration(1+s.rng.Intn(48)) * time.Hour)
+	}
+	return order, nil
+}
+
+func (s *Seeder) status() domain.OrderStatus {
+	total := 0
+	for _, w := range statusWeights {
+		total += w.weight
+	}
+	r := s.rng.Intn(total)
+	for _, w := range statusWeights {
+		if r < w.weight {
+			return w.status
+		}
+		r -= w.weight
+	}
+	return domain.OrderStatusPending
+}
+
+func (s *Seeder) product() Product {
+	name := adjectives[s.rng.Intn(len(adjectives))] + " " + nouns[s.rng.Intn(len(nouns))]
+	// Prices between 4.99 and ~250 with the usual .99 ending.
+	price := math.Floor(5+s.rng.Float64()*245) - 0.01
+	return Product{ID: s.uuid(), Name: name, Price: price}
+}
+
+func (s *Seeder) email(i int) string {
+	first := firstNames[s.rng.Intn(len(firstNames))]
+	last := lastNames[s.rng.Intn(len(lastNames))]
+	return fmt.Sprintf("%s.%s%d@%s", first, last, i+1, domains[s.rng.Intn(len(domains))])
+}
+
+// uuid draws a version 4 UUID from the seeded source so runs are reproducible.
+func (s *Seeder) uuid() uuid.UUID {
+	id, _ := uuid.NewRandomFromReader(s.rng)
+	return id
+}
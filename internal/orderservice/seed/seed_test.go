@@ -0,0 +1,84 @@
+package seed_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/seed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	customers map[uuid.UUID]string
+	orders    []*domain.Order
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{customers: map[uuid.UUID]string{}}
+}
+
+func (m *memoryStore) CreateCustomer(_ context.Context, id uuid.UUID, email string) error {
+	m.customers[id] = email
+	return nil
+}
+
+func (m *memoryStore) CreateOrder(_ context.Context, order *domain.Order) error {
+	m.orders = append(m.orders, order)
+	return nil
+}
+
+func TestSeeder_Run(t *testing.T) {
+	store := newMemoryStore()
+	opts := seed.Options{Customers: 10, Products: 8, Orders: 100, MaxItemsPerOrder: 4, Days: 7, Seed: 42}
+
+	summary, err := seed.New(store, store, opts).Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 10, summary.Customers)
+	assert.Len(t, summary.Products, 8)
+	assert.Equal(t, 100, summary.Orders)
+	assert.Len(t, store.customers, 10)
+	require.Len(t, store.orders, 100)
+
+	prices := map[uuid.UUID]float64{}
+	for _, p := range summary.Products {
+		prices[p.ID] = p.Price
+	}
+	statuses := 0
+	for _, count := range summary.OrdersByStatus {
+		statuses += count
+	}
+	assert.Equal(t, 100, statuses)
+
+	for _, order := range store.orders {
+		assert.Contains(t, store.customers, order.CustomerID)
+		assert.NotEmpty(t, order.Items)
+		assert.LessOrEqual(t, len(order.Items), 4)
+		var total float64
+		for _, item := range order.Items {
+			assert.Equal(t, prices[item.ProductID], item.UnitPrice, "items use catalog prices")
+			total += float64(item.Quantity) * item.UnitPrice
+		}
+		assert.InDelta(t, total, order.TotalPrice, 0.001)
+		assert.False(t, order.UpdatedAt.Before(order.CreatedAt))
+	}
+}
+
+func TestSeeder_Reproducible(t *testing.T) {
+	first, second := newMemoryStore(), newMemoryStore()
+	opts := seed.Options{Customers: 3, Products: 3, Orders: 5, MaxItemsPerOrder: 2, Days: 1, Seed: 7}
+
+	_, err := seed.New(first, first, opts).Run(context.Background())
+	require.NoError(t, err)
+	_, err = seed.New(second, second, opts).Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, first.customers, second.customers)
+	for i := range first.orders {
+		assert.Equal(t, first.orders[i].ID, second.orders[i].ID)
+		assert.Equal(t, first.orders[i].Status, second.orders[i].Status)
+	}
+}
@@ -3,7 +3,6 @@ package server
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 )
 
 // Server represents the HTTP server for the Order Service.
@@ -47,15 +47,15 @@ func (s *Server) Run() error {
 
 	// Start server in a goroutine so it doesn't block
 	go func() {
-		log.Printf("Order Service starting on port %d...", s.port)
+		log.Info().Int("port", s.port).Msg("Order Service starting")
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to listen: %v", err)
+			log.Fatal().Err(err).Msg("Server failed to listen")
 		}
 	}()
 
 	// Block until a signal is received
 	<-quit
-	log.Println("Shutting down server...")
+	log.Info().Msg("Shutting down server...")
 
 	// Create a context with a timeout for graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -63,9 +63,9 @@ func (s *Server) Run() error {
 
 	// Shut down gracefully
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		log.Fatal().Err(err).Msg("Server forced to shutdown")
 	}
 
-	log.Println("Server exiting")
+	log.Info().Msg("Server exiting")
 	return nil
 }
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSConfig controls HTTPS for the order service API. Certificates come either
+// from CertFile/KeyFile or, when AutocertDomains is set, from Let's Encrypt.
+type TLSConfig struct {
+	Enabled  bool   `key:"enabled" env:"TLS_ENABLED" default:"false"`
+	CertFile string `key:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile  string `key:"key_file" env:"TLS_KEY_FILE"`
+
+	// AutocertDomains are the host names certificates may be issued for.
+	// ACME HTTP-01 challenges are answered on RedirectPort, so it must be
+	// reachable on port 80 from the internet.
+	AutocertDomains  []string `key:"autocert_domains" env:"TLS_AUTOCERT_DOMAINS"`
+	AutocertCacheDir string   `key:"autocert_cache_dir" env:"TLS_AUTOCERT_CACHE_DIR" default:"autocert-cache"`
+	AutocertEmail    string   `key:"autocert_email" env:"TLS_AUTOCERT_EMAIL"`
+
+	// RedirectPort serves plain HTTP that redirects to HTTPS. Zero disables it.
+	RedirectPort int `key:"redirect_port" env:"TLS_REDIRECT_PORT" default:"0"`
+}
+
+// modernCipherSuites are the TLS 1.2 suites offering forward secrecy and AEAD.
+// TLS 1.3 suites are not configurable and are always enabled.
+var modernCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// ConfigureTLS prepares srv to be started with ListenAndServeTLS("", "") and
+// returns the plain HTTP redirect server, or nil if RedirectPort is zero.
+// httpsPort is the port srv listens on.
+func ConfigureTLS(srv *http.Server, cfg TLSConfig, httpsPort int) (*http.Server, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CipherSuites:     modernCipherSuites,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+	}
+
+	redirect := RedirectHandler(httpsPort)
+	if len(cfg.AutocertDomains) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+			Email:      cfg.AutocertEmail,
+		}
+		tlsConfig.GetCertificate = manager.GetCertificate
+		tlsConfig.NextProtos = manager.TLSConfig().NextProtos
+		redirect = manager.HTTPHandler(redirect)
+	} else {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	srv.TLSConfig = tlsConfig
+
+	if cfg.RedirectPort == 0 {
+		return nil, nil
+	}
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.RedirectPort),
+		Handler:           redirect,
+		ReadHeaderTimeout: 5 * time.Second,
+	}, nil
+}
+
+// RedirectHandler permanently redirects every request to the same host and
+// path over HTTPS on httpsPort.
+func RedirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
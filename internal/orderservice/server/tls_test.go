@@ -0,0 +1,96 @@
+package server_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSelfSignedCert writes a throwaway certificate and key for localhost.
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestConfigureTLS(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t)
+
+	t.Run("certificate files", func(t *testing.T) {
+		srv := &http.Server{}
+		redirect, err := server.ConfigureTLS(srv, server.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile}, 8443)
+		require.NoError(t, err)
+
+		assert.Nil(t, redirect)
+		require.NotNil(t, srv.TLSConfig)
+		assert.Equal(t, uint16(tls.VersionTLS12), srv.TLSConfig.MinVersion)
+		assert.Len(t, srv.TLSConfig.Certificates, 1)
+		assert.NotContains(t, srv.TLSConfig.CipherSuites, tls.TLS_RSA_WITH_AES_128_CBC_SHA)
+	})
+
+	t.Run("redirect server", func(t *testing.T) {
+		redirect, err := server.ConfigureTLS(&http.Server{}, server.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile, RedirectPort: 8081}, 8443)
+		require.NoError(t, err)
+		require.NotNil(t, redirect)
+		assert.Equal(t, ":8081", redirect.Addr)
+	})
+
+	t.Run("missing certificate", func(t *testing.T) {
+		_, err := server.ConfigureTLS(&http.Server{}, server.TLSConfig{Enabled: true, CertFile: "missing.pem", KeyFile: "missing.pem"}, 8443)
+		assert.ErrorContains(t, err, "failed to load TLS certificate")
+	})
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsPort int
+		target    string
+		want      string
+	}{
+		{name: "default port", httpsPort: 443, target: "http://shop.example.com/api/v1/orders?x=1", want: "https://shop.example.com/api/v1/orders?x=1"},
+		{name: "custom port", httpsPort: 8443, target: "http://shop.example.com:8080/healthz", want: "https://shop.example.com:8443/healthz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			server.RedirectHandler(tt.httpsPort).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+			assert.Equal(t, tt.want, w.Header().Get("Location"))
+		})
+	}
+}
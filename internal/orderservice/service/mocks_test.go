@@ -2,9 +2,11 @@ package service_test
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -18,16 +20,58 @@ func (m *MockOrderRepository) CreateOrder(ctx context.Context, order *domain.Ord
 	return args.Error(0)
 }
 
+func (m *MockOrderRepository) CreateOrderWithOutbox(ctx context.Context, order *domain.Order, msgs ...repository.OutboxMessage) error {
+	args := m.Called(ctx, order, msgs)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) EnqueueOutbox(ctx context.Context, msgs ...repository.OutboxMessage) error {
+	args := m.Called(ctx, msgs)
+	return args.Error(0)
+}
+
 func (m *MockOrderRepository) GetOrderByID(ctx context.Context, orderID uuid.UUID) (*domain.Order, error) {
 	args := m.Called(ctx, orderID)
 	return args.Get(0).(*domain.Order), args.Error(1)
 }
 
-func (m *MockOrderRepository) UpdateOrderStatus(ctx context.Context, id uuid.UUID, status domain.OrderStatus) error {
-	args := m.Called(ctx, id, status)
+func (m *MockOrderRepository) GetOrderByExternalReference(ctx context.Context, ref domain.ExternalReference) (*domain.Order, error) {
+	args := m.Called(ctx, ref)
+	return args.Get(0).(*domain.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) FindDuplicateOrder(ctx context.Context, order *domain.Order, since time.Time) (uuid.UUID, error) {
+	args := m.Called(ctx, order, since)
+	return args.Get(0).(uuid.UUID), args.Error(1)
+}
+
+func (m *MockOrderRepository) UpdateOrderStatus(ctx context.Context, id uuid.UUID, status domain.OrderStatus, updatedAt time.Time) error {
+	args := m.Called(ctx, id, status, updatedAt)
 	return args.Error(0)
 }
 
+func (m *MockOrderRepository) ListOrders(ctx context.Context, filter repository.OrderFilter) ([]*domain.Order, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*domain.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) StreamOrders(ctx context.Context, filter repository.OrderFilter, fn func(*domain.Order) error) error {
+	args := m.Called(ctx, filter, fn)
+	if orders, ok := args.Get(0).([]*domain.Order); ok {
+		for _, order := range orders {
+			if err := fn(order); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockOrderRepository) OrderStats(ctx context.Context, filter repository.OrderFilter) ([]repository.OrderStatusStats, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]repository.OrderStatusStats), args.Error(1)
+}
+
 type MockKafkaProducer struct {
 	mock.Mock
 }
@@ -41,3 +85,58 @@ func (m *MockKafkaProducer) Close() error {
 	args := m.Called()
 	return args.Error(0)
 }
+
+type MockCustomerValidator struct {
+	mock.Mock
+}
+
+func (m *MockCustomerValidator) CustomerExists(ctx context.Context, customerID uuid.UUID) (bool, error) {
+	args := m.Called(ctx, customerID)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockOrderQuota is a mock implementation of service.OrderQuota.
+type MockOrderQuota struct {
+	mock.Mock
+}
+
+func (m *MockOrderQuota) Reserve(ctx context.Context, customerID uuid.UUID) error {
+	args := m.Called(ctx, customerID)
+	return args.Error(0)
+}
+
+// MockAvailabilityChecker is a mock implementation of service.AvailabilityChecker.
+type MockAvailabilityChecker struct {
+	mock.Mock
+}
+
+func (m *MockAvailabilityChecker) OutOfStockProducts(ctx context.Context, items []domain.OrderItem) ([]uuid.UUID, error) {
+	args := m.Called(ctx, items)
+	productIDs, _ := args.Get(0).([]uuid.UUID)
+	return productIDs, args.Error(1)
+}
+
+// MockAddressVerifier is a mock implementation of service.AddressVerifier.
+type MockAddressVerifier struct {
+	mock.Mock
+}
+
+func (m *MockAddressVerifier) Verify(ctx context.Context, addr domain.Address) (domain.Address, error) {
+	args := m.Called(ctx, addr)
+	return args.Get(0).(domain.Address), args.Error(1)
+}
+
+// fakeOrderLocker records the order locks taken and released, or fails every
+// lock with err.
+type fakeOrderLocker struct {
+	err    error
+	events []string
+}
+
+func (l *fakeOrderLocker) Lock(_ context.Context, orderID uuid.UUID) (func(), error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	l.events = append(l.events, "lock "+orderID.String())
+	return func() { l.events = append(l.events, "unlock "+orderID.String()) }, nil
+}
@@ -3,8 +3,12 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/kafka"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/catalog"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/metrics"
 	"time"
 
@@ -12,36 +16,236 @@ import (
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/service")
+
+// recordSpanError marks span as failed with err.
+func recordSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
 type OrderService interface {
-	CreateOrder(ctx context.Context, customerID uuid.UUID, items []domain.OrderItem) (*domain.Order, error)
+	CreateOrder(ctx context.Context, customerID uuid.UUID, items []domain.OrderItem, ref domain.ExternalReference, address *domain.Address) (*domain.Order, error)
 	GetOrderByID(ctx context.Context, orderID uuid.UUID) (*domain.Order, error)
+	GetOrderByExternalReference(ctx context.Context, ref domain.ExternalReference) (*domain.Order, error)
+	UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, status domain.OrderStatus) (*domain.Order, error)
+	ListOrders(ctx context.Context, filter repository.OrderFilter) ([]*domain.Order, error)
+	StreamOrders(ctx context.Context, filter repository.OrderFilter, fn func(*domain.Order) error) error
+	OrderStats(ctx context.Context, filter repository.OrderFilter) ([]repository.OrderStatusStats, error)
+	ResendOrderPlaced(ctx context.Context, orderID uuid.UUID) (*domain.Order, error)
+	OrderEvents(ctx context.Context, orderID uuid.UUID) ([]repository.StoredEvent, error)
+}
+
+// CustomerValidator confirms that a customer ID belongs to a known customer.
+type CustomerValidator interface {
+	CustomerExists(ctx context.Context, customerID uuid.UUID) (bool, error)
+}
+
+// OrderHistory reads back the event streams of event-sourced orders.
+type OrderHistory interface {
+	StreamOrderEvents(ctx context.Context, filter repository.ReplayFilter, fn func(repository.StoredEvent) error) error
+}
+
+// OrderCache holds recently read orders for GetOrderByID. Entries past their
+// TTL are stale (fresh is false) and only served when the repository fails.
+type OrderCache interface {
+	Get(id uuid.UUID) (order *domain.Order, fresh, ok bool)
+	Set(order *domain.Order)
+	Invalidate(id uuid.UUID)
+}
+
+// Actions taken on a duplicate order; see DuplicateConfig.
+const (
+	DuplicateActionReject = "reject"
+	DuplicateActionFlag   = "flag"
+)
+
+// DuplicateConfig configures the detection of orders that repeat a recent
+// order of the same customer with the same items, such as double clicks or
+// retries by clients that send no idempotency key.
+type DuplicateConfig struct {
+	Enabled bool `key:"enabled" env:"DUPLICATE_ORDER_DETECTION_ENABLED" default:"false"`
+	// Window is how recent the earlier order must be.
+	Window time.Duration `key:"window" env:"DUPLICATE_ORDER_WINDOW" default:"30s"`
+	// Action is "reject", failing CreateOrder with a
+	// *domain.DuplicateOrderError, or "flag", creating the order with
+	// DuplicateOf set.
+	Action string `key:"action" env:"DUPLICATE_ORDER_ACTION" default:"reject"`
+}
+
+// OrderLocker serializes mutations of the same order, across instances.
+type OrderLocker interface {
+	// Lock waits for the lock of orderID and returns the function releasing
+	// it, or fails with domain.ErrOrderLocked if the wait times out.
+	Lock(ctx context.Context, orderID uuid.UUID) (unlock func(), err error)
+}
+
+// AddressVerifier checks that shipping addresses are deliverable.
+type AddressVerifier interface {
+	// Verify returns addr as the verifier normalizes it, or a
+	// *domain.InvalidAddressError listing what is wrong with it.
+	Verify(ctx context.Context, addr domain.Address) (domain.Address, error)
+}
+
+// AvailabilityChecker checks order items against the stock of the inventory
+// service.
+type AvailabilityChecker interface {
+	// OutOfStockProducts returns the products of items that can neither be
+	// reserved nor backordered now.
+	OutOfStockProducts(ctx context.Context, items []domain.OrderItem) ([]uuid.UUID, error)
+}
+
+// OrderQuota limits how many orders each customer may place.
+type OrderQuota interface {
+	// Reserve counts an order for customerID, or returns a
+	// *domain.QuotaExceededError if the customer is over quota.
+	Reserve(ctx context.Context, customerID uuid.UUID) error
 }
 
 type orderServiceImpl struct {
-	orderRepo     repository.OrderRepository
-	kafkaProducer kafka.KafkaProducer
+	orderRepo repository.OrderRepository
+	publisher broker.EventPublisher
+	catalog   catalog.Client
+	customers CustomerValidator
+	// outbox is set when events go through the transactional outbox; it is
+	// called after new outbox messages are committed.
+	outbox     func()
+	cache      OrderCache
+	quota      OrderQuota
+	duplicates DuplicateConfig
+	timeouts   TimeoutConfig
+	locks      OrderLocker
+	stock      AvailabilityChecker
+	addresses  AddressVerifier
+	history    OrderHistory
+	// statusEvents publishes OrderStatusChanged events when set.
+	statusEvents broker.EventPublisher
 }
 
-// NewOrderService creates a new instance of OrderService.
-func NewOrderService(repo repository.OrderRepository, producer kafka.KafkaProducer) OrderService {
-	return &orderServiceImpl{
-		orderRepo:     repo,
-		kafkaProducer: producer,
+// Option configures optional dependencies of the order service.
+type Option func(*orderServiceImpl)
+
+// WithCatalog makes CreateOrder reject products the catalog doesn't know or can't sell.
+func WithCatalog(client catalog.Client) Option {
+	return func(s *orderServiceImpl) {
+		s.catalog = client
+	}
+}
+
+// WithAvailabilityCheck makes CreateOrder reject orders for products that
+// are out of stock. The check is advisory: stock is only reserved once the
+// order is placed, and orders are accepted when the check fails.
+func WithAvailabilityCheck(checker AvailabilityChecker) Option {
+	return func(s *orderServiceImpl) {
+		s.stock = checker
+	}
+}
+
+// WithAddressVerifier makes CreateOrder reject shipping addresses v finds
+// undeliverable, and store them as v normalizes them.
+func WithAddressVerifier(v AddressVerifier) Option {
+	return func(s *orderServiceImpl) {
+		s.addresses = v
 	}
 }
 
-type OrderPlacedEvent struct {
-	OrderID    uuid.UUID          `json:"order_id"`
-	CustomerID uuid.UUID          `json:"customer_id"`
-	TotalPrice float64            `json:"total_price"`
-	Status     domain.OrderStatus `json:"status"`
+// WithCustomerValidator makes CreateOrder reject orders for unknown customers.
+func WithCustomerValidator(v CustomerValidator) Option {
+	return func(s *orderServiceImpl) {
+		s.customers = v
+	}
+}
+
+// WithOutbox stores events in the transactional outbox, in the same
+// transaction as the order, instead of publishing them while handling the
+// request. notify is called once they are committed, typically to wake the
+// outbox relay.
+func WithOutbox(notify func()) Option {
+	return func(s *orderServiceImpl) {
+		s.outbox = notify
+	}
+}
+
+// WithOrderCache serves GetOrderByID from cache. The service keeps the cache
+// up to date with every change it makes to an order; see orderChanged.
+func WithOrderCache(cache OrderCache) Option {
+	return func(s *orderServiceImpl) {
+		s.cache = cache
+	}
+}
+
+// WithOrderQuota makes CreateOrder reject orders from customers that are over
+// quota. Orders are accepted unchecked when the quota itself fails.
+func WithOrderQuota(quota OrderQuota) Option {
+	return func(s *orderServiceImpl) {
+		s.quota = quota
+	}
+}
+
+// WithDuplicateDetection makes CreateOrder look for a recent identical order
+// of the same customer and reject or flag the new one as cfg.Action says.
+func WithDuplicateDetection(cfg DuplicateConfig) Option {
+	return func(s *orderServiceImpl) {
+		s.duplicates = cfg
+	}
+}
+
+// WithOrderLocks makes multi-step mutations of an order, such as status
+// updates, hold the order's lock so concurrent admin actions and saga steps
+// cannot interleave.
+func WithOrderLocks(locker OrderLocker) Option {
+	return func(s *orderServiceImpl) {
+		s.locks = locker
+	}
+}
+
+// WithOrderHistory makes OrderEvents return the events of the orders'
+// streams, for services storing orders as events.
+func WithOrderHistory(history OrderHistory) Option {
+	return func(s *orderServiceImpl) {
+		s.history = history
+	}
+}
+
+// WithStatusEvents makes UpdateOrderStatus publish an OrderStatusChanged
+// event with publisher for every status change, so a notifier can tell the
+// customer. Publishing is best effort: the change stands when it fails.
+func WithStatusEvents(publisher broker.EventPublisher) Option {
+	return func(s *orderServiceImpl) {
+		s.statusEvents = publisher
+	}
+}
+
+// NewOrderService creates a new instance of OrderService.
+func NewOrderService(repo repository.OrderRepository, producer broker.EventPublisher, opts ...Option) OrderService {
+	s := &orderServiceImpl{
+		orderRepo: repo,
+		publisher: producer,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // CreateOrder handles the creation of a new order, applying business rules,
-// persisting it, and publishing an event.
-func (s *orderServiceImpl) CreateOrder(ctx context.Context, customerID uuid.UUID, items []domain.OrderItem) (*domain.Order, error) {
+// persisting it, and publishing an event. ref is the optional reference of
+// the order in the system it was imported from; creating a second order with
+// the same reference fails with a *domain.ExternalReferenceConflictError
+// naming the first one.
+func (s *orderServiceImpl) CreateOrder(ctx context.Context, customerID uuid.UUID, items []domain.OrderItem, ref domain.ExternalReference, address *domain.Address) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.CreateOrder", trace.WithAttributes(
+		attribute.String("customer_id", customerID.String()),
+		attribute.Int("order.item_count", len(items)),
+	))
+	defer span.End()
+
 	status := "success"
 	start := time.Now()
 	defer func() {
@@ -51,49 +255,99 @@ func (s *orderServiceImpl) CreateOrder(ctx context.Context, customerID uuid.UUID
 	order, err := domain.NewOrder(customerID, items)
 	if err != nil {
 		status = "failure"
+		recordSpanError(span, err)
 		log.Ctx(ctx).Error().Err(err).Msg("Service: failed to create new order domain object") // Contextual logging
 		return nil, fmt.Errorf("service: failed to create new order domain object: %w", err)
 	}
+	if err := ref.Validate(); err != nil {
+		status = "failure"
+		recordSpanError(span, err)
+		return nil, fmt.Errorf("service: %w", err)
+	}
+	order.ExternalReference = ref
 
-	err = s.orderRepo.CreateOrder(ctx, order)
+	if order.ShippingAddress, err = s.checkAddress(ctx, address); err != nil {
+		status = "failure"
+		recordSpanError(span, err)
+		log.Ctx(ctx).Warn().Err(err).Msg("Service: rejecting shipping address")
+		return nil, fmt.Errorf("service: %w", err)
+	}
+
+	if err := s.checkExternalReference(ctx, ref); err != nil {
+		status = "failure"
+		recordSpanError(span, err)
+		log.Ctx(ctx).Warn().Err(err).Str("external_source", ref.Source).Msg("Service: external reference already used")
+		return nil, fmt.Errorf("service: %w", err)
+	}
+
+	if err := s.checkDuplicate(ctx, order); err != nil {
+		status = "failure"
+		recordSpanError(span, err)
+		log.Ctx(ctx).Warn().Err(err).Str("customer_id", customerID.String()).Msg("Service: rejecting duplicate order")
+		return nil, fmt.Errorf("service: %w", err)
+	}
+
+	if err := s.validateCustomer(ctx, customerID); err != nil {
+		status = "failure"
+		recordSpanError(span, err)
+		log.Ctx(ctx).Error().Err(err).Str("customer_id", customerID.String()).Msg("Service: customer validation failed")
+		return nil, fmt.Errorf("service: customer validation failed: %w", err)
+	}
+
+	if err := s.validateProducts(ctx, order.Items); err != nil {
+		status = "failure"
+		recordSpanError(span, err)
+		log.Ctx(ctx).Error().Err(err).Msg("Service: product catalog validation failed")
+		return nil, fmt.Errorf("service: product catalog validation failed: %w", err)
+	}
+
+	if err := s.checkAvailability(ctx, order.Items); err != nil {
+		status = "failure"
+		recordSpanError(span, err)
+		log.Ctx(ctx).Warn().Err(err).Msg("Service: rejecting order for out-of-stock products")
+		return nil, fmt.Errorf("service: %w", err)
+	}
+
+	// The quota is reserved last, so orders rejected above don't use it up.
+	if err := s.reserveQuota(ctx, customerID); err != nil {
+		status = "failure"
+		recordSpanError(span, err)
+		log.Ctx(ctx).Warn().Err(err).Str("customer_id", customerID.String()).Msg("Service: customer is over quota")
+		return nil, fmt.Errorf("service: %w", err)
+	}
+
+	if s.outbox != nil {
+		err = s.createOrderWithOutbox(ctx, order)
+	} else {
+		err = s.db(ctx, func(ctx context.Context) error {
+			return s.orderRepo.CreateOrder(ctx, order)
+		})
+	}
+	if errors.Is(err, domain.ErrExternalReferenceExists) {
+		// Another request with the same reference got in first.
+		if conflict := s.checkExternalReference(ctx, ref); conflict != nil {
+			err = conflict
+		}
+	}
 	if err != nil {
 		status = "failure"
+		recordSpanError(span, err)
 		log.Ctx(ctx).Error().Err(err).Msg("Service: failed to persist order")
 		return nil, fmt.Errorf("service: failed to persist order: %w", err)
 	}
+	span.SetAttributes(attribute.String("order_id", order.ID.String()))
+	s.orderChanged(order.ID, order)
 
 	metrics.OrdersCreatedTotal.Inc()
 
-	orderPlacedEvent := struct {
-		OrderID    uuid.UUID `json:"order_id"`
-		CustomerID uuid.UUID `json:"customer_id"`
-		TotalPrice float64   `json:"total_price"`
-		Timestamp  time.Time `json:"timestamp"`
-		Items      []struct {
-			ProductID uuid.UUID `json:"product_id"`
-			Quantity  int       `json:"quantity"`
-			UnitPrice float64   `json:"unit_price"`
-		} `json:"items"`
-	}{
-		OrderID:    order.ID,
-		CustomerID: order.CustomerID,
-		TotalPrice: order.TotalPrice,
-		Timestamp:  order.CreatedAt,
-	}
-
-	for _, item := range order.Items {
-		orderPlacedEvent.Items = append(orderPlacedEvent.Items, struct {
-			ProductID uuid.UUID `json:"product_id"`
-			Quantity  int       `json:"quantity"`
-			UnitPrice float64   `json:"unit_price"`
-		}{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			UnitPrice: item.UnitPrice,
-		})
+	if s.outbox != nil {
+		log.Ctx(ctx).Info().
+			Str("order_id", order.ID.String()).
+			Msg("Order created and 'orders.placed' event queued in the outbox.")
+		return order, nil
 	}
 
-	eventValue, err := json.Marshal(orderPlacedEvent)
+	eventValue, err := json.Marshal(NewOrderPlacedEvent(order))
 	if err != nil {
 		log.Ctx(ctx).Error().Err(err).
 			Str("order_id", order.ID.String()).
@@ -101,36 +355,576 @@ func (s *orderServiceImpl) CreateOrder(ctx context.Context, customerID uuid.UUID
 		return order, nil
 	}
 
-	err = s.kafkaProducer.PublishMessage(ctx, []byte(order.ID.String()), eventValue)
+	err = s.publish(ctx, []byte(order.ID.String()), eventValue)
 	if err != nil {
+		span.RecordError(err)
 		log.Ctx(ctx).Error().Err(err).
 			Str("order_id", order.ID.String()).
-			Msg("Service: Failed to publish order placed event to Kafka")
+			Msg("Service: Failed to publish order placed event")
 		return order, nil
 	}
 
 	log.Ctx(ctx).Info().
 		Str("order_id", order.ID.String()).
-		Msg("Order created and 'orders.placed' event published to Kafka.")
+		Msg("Order created and 'orders.placed' event published.")
 	return order, nil
 }
 
+// createOrderWithOutbox persists order together with its OrderPlaced event.
+func (s *orderServiceImpl) createOrderWithOutbox(ctx context.Context, order *domain.Order) error {
+	msg, err := orderPlacedOutboxMessage(ctx, order)
+	if err != nil {
+		return err
+	}
+	err = s.db(ctx, func(ctx context.Context) error {
+		return s.orderRepo.CreateOrderWithOutbox(ctx, order, msg)
+	})
+	if err != nil {
+		return err
+	}
+	s.outbox()
+	return nil
+}
+
+// orderPlacedOutboxMessage builds the outbox message carrying the
+// OrderPlaced event of order.
+func orderPlacedOutboxMessage(ctx context.Context, order *domain.Order) (repository.OutboxMessage, error) {
+	value, err := json.Marshal(NewOrderPlacedEvent(order))
+	if err != nil {
+		return repository.OutboxMessage{}, fmt.Errorf("failed to marshal order placed event: %w", err)
+	}
+	return repository.OutboxMessage{
+		Topic:         events.TopicOrdersPlaced,
+		Key:           []byte(order.ID.String()),
+		Type:          events.OrderPlacedType,
+		Value:         value,
+		CorrelationID: logging.CorrelationID(ctx),
+	}, nil
+}
+
+// checkExternalReference fails with a *domain.ExternalReferenceConflictError
+// if an order already has ref. The lookup only gives importers the existing
+// order's ID: if it fails, the unique constraint still rejects the order.
+func (s *orderServiceImpl) checkExternalReference(ctx context.Context, ref domain.ExternalReference) error {
+	if ref.IsZero() {
+		return nil
+	}
+	var existing *domain.Order
+	err := s.db(ctx, func(ctx context.Context) (err error) {
+		existing, err = s.orderRepo.GetOrderByExternalReference(ctx, ref)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, domain.ErrOrderNotFound) {
+			log.Ctx(ctx).Warn().Err(err).Msg("Service: external reference lookup failed")
+		}
+		return nil
+	}
+	return &domain.ExternalReferenceConflictError{ExistingOrderID: existing.ID}
+}
+
+// checkDuplicate looks for an order of the same customer with the same items
+// within the duplicate window. A duplicate is rejected with a
+// *domain.DuplicateOrderError or, in flag mode, recorded in
+// order.DuplicateOf. Detection is best effort: it is skipped if the lookup
+// fails, and two identical orders submitted at the same instant may both be
+// accepted.
+func (s *orderServiceImpl) checkDuplicate(ctx context.Context, order *domain.Order) error {
+	if !s.duplicates.Enabled {
+		return nil
+	}
+	var existingID uuid.UUID
+	err := s.db(ctx, func(ctx context.Context) (err error) {
+		existingID, err = s.orderRepo.FindDuplicateOrder(ctx, order, order.CreatedAt.Add(-s.duplicates.Window))
+		return err
+	})
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Service: duplicate order check failed, accepting order")
+		return nil
+	}
+	if existingID == uuid.Nil {
+		return nil
+	}
+	metrics.DuplicateOrdersTotal.WithLabelValues(s.duplicates.Action).Inc()
+	if s.duplicates.Action == DuplicateActionFlag {
+		order.DuplicateOf = existingID
+		log.Ctx(ctx).Warn().
+			Str("order_id", order.ID.String()).
+			Str("duplicate_of", existingID.String()).
+			Msg("Service: accepting order flagged as duplicate")
+		return nil
+	}
+	return &domain.DuplicateOrderError{ExistingOrderID: existingID}
+}
+
+// reserveQuota counts the order against the customer's quota. It fails only
+// when the customer is over quota: if the quota cannot be checked, the
+// order is let through rather than failing every order while the quota
+// store is down.
+func (s *orderServiceImpl) reserveQuota(ctx context.Context, customerID uuid.UUID) error {
+	if s.quota == nil {
+		return nil
+	}
+	err := s.quota.Reserve(ctx, customerID)
+	var exceeded *domain.QuotaExceededError
+	if errors.As(err, &exceeded) {
+		metrics.CustomerQuotaRejectionsTotal.WithLabelValues(exceeded.Window).Inc()
+		return err
+	}
+	if err != nil {
+		metrics.CustomerQuotaErrorsTotal.Inc()
+		log.Ctx(ctx).Warn().Err(err).Str("customer_id", customerID.String()).Msg("Service: quota check failed, accepting order")
+	}
+	return nil
+}
+
+// checkAddress normalizes and validates the order's shipping address, if it
+// has one, and has the address verifier, if any, confirm it is deliverable.
+// It returns the address to store.
+func (s *orderServiceImpl) checkAddress(ctx context.Context, address *domain.Address) (*domain.Address, error) {
+	if address == nil {
+		return nil, nil
+	}
+	normalized := address.Normalize()
+	if err := normalized.Validate(); err != nil {
+		return nil, err
+	}
+	if s.addresses == nil {
+		return &normalized, nil
+	}
+	verified, err := s.addresses.Verify(ctx, normalized)
+	if err != nil {
+		return nil, err
+	}
+	return &verified, nil
+}
+
+// validateCustomer makes sure customerID refers to an existing customer.
+func (s *orderServiceImpl) validateCustomer(ctx context.Context, customerID uuid.UUID) error {
+	if s.customers == nil {
+		return nil
+	}
+	exists, err := s.customers.CustomerExists(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrCustomerNotFound
+	}
+	return nil
+}
+
+// validateProducts checks every distinct product in items against the catalog.
+func (s *orderServiceImpl) validateProducts(ctx context.Context, items []domain.OrderItem) error {
+	if s.catalog == nil {
+		return nil
+	}
+
+	seen := make(map[uuid.UUID]struct{}, len(items))
+	productIDs := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		if _, ok := seen[item.ProductID]; ok {
+			continue
+		}
+		seen[item.ProductID] = struct{}{}
+		productIDs = append(productIDs, item.ProductID)
+	}
+
+	rejected, err := s.catalog.UnsellableProducts(ctx, productIDs)
+	if err != nil {
+		return err
+	}
+	if len(rejected) > 0 {
+		return &domain.UnsellableProductsError{ProductIDs: rejected}
+	}
+	return nil
+}
+
+// checkAvailability rejects orders for products the inventory service is out
+// of. Like the quota, it fails only on a definite answer: if the inventory
+// service can't be asked, the order is let through and its reservation
+// decides.
+func (s *orderServiceImpl) checkAvailability(ctx context.Context, items []domain.OrderItem) error {
+	if s.stock == nil {
+		return nil
+	}
+	outOfStock, err := s.stock.OutOfStockProducts(ctx, items)
+	if err != nil {
+		metrics.InventoryCheckErrorsTotal.Inc()
+		log.Ctx(ctx).Warn().Err(err).Msg("Service: stock check failed, accepting order")
+		return nil
+	}
+	if len(outOfStock) > 0 {
+		metrics.OutOfStockRejectionsTotal.Inc()
+		return &domain.OutOfStockError{ProductIDs: outOfStock}
+	}
+	return nil
+}
+
 func (s *orderServiceImpl) GetOrderByID(ctx context.Context, orderID uuid.UUID) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetOrderByID", trace.WithAttributes(
+		attribute.String("order_id", orderID.String()),
+	))
+	defer span.End()
+
 	status := "success"
 	start := time.Now()
 	defer func() {
 		metrics.OrderRetrievalDuration.WithLabelValues(status).Observe(time.Since(start).Seconds())
 	}()
 
-	order, err := s.orderRepo.GetOrderByID(ctx, orderID)
+	var cached *domain.Order
+	if s.cache != nil {
+		order, fresh, ok := s.cache.Get(orderID)
+		if ok && fresh {
+			metrics.OrderCacheRequestsTotal.WithLabelValues("hit").Inc()
+			metrics.OrdersRetrievedTotal.Inc()
+			return order, nil
+		}
+		cached = order
+	}
+
+	order, err := s.getOrder(ctx, orderID)
+	if err != nil && cached != nil && !errors.Is(err, domain.ErrOrderNotFound) {
+		// Better an order that may be slightly out of date than none.
+		metrics.OrderCacheRequestsTotal.WithLabelValues("stale").Inc()
+		metrics.OrdersRetrievedTotal.Inc()
+		log.Ctx(ctx).Warn().Err(err).
+			Str("order_id", orderID.String()).
+			Msg("Service: failed to get order by ID, serving stale cached copy")
+		return cached, nil
+	}
 	if err != nil {
 		status = "failure"
+		recordSpanError(span, err)
 		log.Ctx(ctx).Error().Err(err).
 			Str("order_id", orderID.String()).
 			Msg("Service: failed to get order by ID")
 		return nil, fmt.Errorf("service: failed to get order by ID %s: %w", orderID, err)
 	}
+	if s.cache != nil {
+		metrics.OrderCacheRequestsTotal.WithLabelValues("miss").Inc()
+		s.cache.Set(order)
+	}
 	metrics.OrdersRetrievedTotal.Inc()
 	log.Ctx(ctx).Info().Str("order_id", orderID.String()).Msg("Order retrieved successfully")
 	return order, nil
 }
+
+// GetOrderByExternalReference returns the order imported with ref.
+func (s *orderServiceImpl) GetOrderByExternalReference(ctx context.Context, ref domain.ExternalReference) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetOrderByExternalReference", trace.WithAttributes(
+		attribute.String("external_source", ref.Source),
+	))
+	defer span.End()
+
+	if ref.IsZero() {
+		return nil, fmt.Errorf("service: %w: source and reference are both required", domain.ErrInvalidExternalReference)
+	}
+	if err := ref.Validate(); err != nil {
+		return nil, fmt.Errorf("service: %w", err)
+	}
+	var order *domain.Order
+	err := s.db(ctx, func(ctx context.Context) (err error) {
+		order, err = s.orderRepo.GetOrderByExternalReference(ctx, ref)
+		return err
+	})
+	if err != nil {
+		recordSpanError(span, err)
+		if !errors.Is(err, domain.ErrOrderNotFound) {
+			log.Ctx(ctx).Error().Err(err).Str("external_source", ref.Source).Msg("Service: failed to get order by external reference")
+		}
+		return nil, fmt.Errorf("service: failed to get order by external reference: %w", err)
+	}
+	metrics.OrdersRetrievedTotal.Inc()
+	return order, nil
+}
+
+// UpdateOrderStatus moves an order to a new status, enforcing the domain's
+// allowed transitions. Disallowed transitions fail with a
+// *domain.InvalidTransitionError, whether they are caught here or, when the
+// order changed concurrently, by the conditional update in the repository.
+func (s *orderServiceImpl) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, status domain.OrderStatus) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.UpdateOrderStatus", trace.WithAttributes(
+		attribute.String("order_id", orderID.String()),
+		attribute.String("order.status", string(status)),
+	))
+	defer span.End()
+
+	unlock, err := s.lockOrder(ctx, orderID)
+	if err != nil {
+		recordSpanError(span, err)
+		return nil, fmt.Errorf("service: failed to lock order %s for status update: %w", orderID, err)
+	}
+	defer unlock()
+
+	order, err := s.getOrder(ctx, orderID)
+	if err != nil {
+		recordSpanError(span, err)
+		return nil, fmt.Errorf("service: failed to get order %s for status update: %w", orderID, err)
+	}
+
+	from := order.Status
+	if err := order.TransitionTo(status); err != nil {
+		err = &domain.InvalidTransitionError{From: from, To: status}
+		recordSpanError(span, err)
+		metrics.OrderStatusTransitionsTotal.WithLabelValues(string(status), "rejected").Inc()
+		log.Ctx(ctx).Warn().Err(err).
+			Str("order_id", orderID.String()).
+			Str("from", string(from)).
+			Str("to", string(status)).
+			Msg("Service: rejected order status transition")
+		return nil, fmt.Errorf("service: cannot update order %s: %w", orderID, err)
+	}
+
+	err = s.db(ctx, func(ctx context.Context) error {
+		return s.orderRepo.UpdateOrderStatus(ctx, orderID, status, order.UpdatedAt)
+	})
+	if err != nil {
+		// The update may have been applied before the error, and a rejected
+		// transition means the order read above is out of date.
+		s.orderChanged(orderID, nil)
+		var transitionErr *domain.InvalidTransitionError
+		if errors.As(err, &transitionErr) {
+			recordSpanError(span, err)
+			metrics.OrderStatusTransitionsTotal.WithLabelValues(string(status), "rejected").Inc()
+			log.Ctx(ctx).Warn().Err(err).
+				Str("order_id", orderID.String()).
+				Str("from", string(from)).
+				Str("to", string(status)).
+				Msg("Service: order status changed concurrently, rejected transition")
+			return nil, fmt.Errorf("service: cannot update order %s: %w", orderID, err)
+		}
+		recordSpanError(span, err)
+		metrics.OrderStatusTransitionsTotal.WithLabelValues(string(status), "failure").Inc()
+		log.Ctx(ctx).Error().Err(err).Str("order_id", orderID.String()).Msg("Service: failed to update order status")
+		return nil, fmt.Errorf("service: failed to update status of order %s: %w", orderID, err)
+	}
+
+	s.orderChanged(orderID, order)
+	metrics.OrderStatusTransitionsTotal.WithLabelValues(string(status), "success").Inc()
+	log.Ctx(ctx).Info().
+		Str("order_id", orderID.String()).
+		Str("status", string(status)).
+		Msg("Order status updated")
+	if err := s.publishStatusChanged(ctx, order, from); err != nil {
+		span.RecordError(err)
+		log.Ctx(ctx).Error().Err(err).Str("order_id", orderID.String()).Msg("Service: Failed to publish order status changed event")
+	}
+	return order, nil
+}
+
+// publishStatusChanged publishes the OrderStatusChanged event of order,
+// which moved from from, if status events are enabled.
+func (s *orderServiceImpl) publishStatusChanged(ctx context.Context, order *domain.Order, from domain.OrderStatus) error {
+	if s.statusEvents == nil {
+		return nil
+	}
+	value, err := json.Marshal(events.OrderStatusChanged{
+		OrderID:    order.ID,
+		CustomerID: order.CustomerID,
+		From:       string(from),
+		To:         string(order.Status),
+		Timestamp:  order.UpdatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal order status changed event: %w", err)
+	}
+	return withTimeout(ctx, s.timeouts.Publish, ErrPublishTimeout, func(ctx context.Context) error {
+		return s.statusEvents.PublishMessage(ctx, []byte(order.ID.String()), value)
+	})
+}
+
+// lockOrder takes the lock of orderID, if order locks are enabled. Every
+// mutation that reads an order, changes it and acts on the change (updates
+// the cache, emits an event) must run under it.
+func (s *orderServiceImpl) lockOrder(ctx context.Context, orderID uuid.UUID) (unlock func(), err error) {
+	if s.locks == nil {
+		return func() {}, nil
+	}
+	return s.locks.Lock(ctx, orderID)
+}
+
+// getOrder loads the order with orderID from the repository.
+func (s *orderServiceImpl) getOrder(ctx context.Context, orderID uuid.UUID) (order *domain.Order, err error) {
+	err = s.db(ctx, func(ctx context.Context) error {
+		order, err = s.orderRepo.GetOrderByID(ctx, orderID)
+		return err
+	})
+	return order, err
+}
+
+// orderChanged is the hook every change the service makes to an order goes
+// through, with the order as now stored, or nil if the outcome is unknown.
+// It keeps the order cache consistent with the database; new mutations
+// (cancellations, item edits) must call it too.
+func (s *orderServiceImpl) orderChanged(orderID uuid.UUID, order *domain.Order) {
+	if s.cache == nil {
+		return
+	}
+	if order == nil {
+		s.cache.Invalidate(orderID)
+		metrics.OrderCacheInvalidationsTotal.Inc()
+		return
+	}
+	s.cache.Set(order)
+}
+
+// ListOrders returns the orders matching filter.
+func (s *orderServiceImpl) ListOrders(ctx context.Context, filter repository.OrderFilter) ([]*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.ListOrders", trace.WithAttributes(
+		attribute.String("order.status", string(filter.Status)),
+		attribute.Int("limit", filter.Limit),
+	))
+	defer span.End()
+
+	var orders []*domain.Order
+	err := s.db(ctx, func(ctx context.Context) (err error) {
+		orders, err = s.orderRepo.ListOrders(ctx, filter)
+		return err
+	})
+	if err != nil {
+		recordSpanError(span, err)
+		log.Ctx(ctx).Error().Err(err).Msg("Service: failed to list orders")
+		return nil, fmt.Errorf("service: failed to list orders: %w", err)
+	}
+	return orders, nil
+}
+
+// StreamOrders calls fn for each order matching filter, oldest first.
+func (s *orderServiceImpl) StreamOrders(ctx context.Context, filter repository.OrderFilter, fn func(*domain.Order) error) error {
+	ctx, span := tracer.Start(ctx, "OrderService.StreamOrders", trace.WithAttributes(
+		attribute.String("order.status", string(filter.Status)),
+	))
+	defer span.End()
+
+	count := 0
+	err := s.orderRepo.StreamOrders(ctx, filter, func(order *domain.Order) error {
+		count++
+		return fn(order)
+	})
+	span.SetAttributes(attribute.Int("order.count", count))
+	if err != nil {
+		recordSpanError(span, err)
+		log.Ctx(ctx).Error().Err(err).Int("streamed", count).Msg("Service: failed to stream orders")
+		return fmt.Errorf("service: failed to stream orders: %w", err)
+	}
+	return nil
+}
+
+// OrderStats aggregates the orders matching filter by status.
+func (s *orderServiceImpl) OrderStats(ctx context.Context, filter repository.OrderFilter) ([]repository.OrderStatusStats, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.OrderStats", trace.WithAttributes(
+		attribute.String("order.status", string(filter.Status)),
+	))
+	defer span.End()
+
+	var stats []repository.OrderStatusStats
+	err := s.db(ctx, func(ctx context.Context) (err error) {
+		stats, err = s.orderRepo.OrderStats(ctx, filter)
+		return err
+	})
+	if err != nil {
+		recordSpanError(span, err)
+		log.Ctx(ctx).Error().Err(err).Msg("Service: failed to get order stats")
+		return nil, fmt.Errorf("service: failed to get order stats: %w", err)
+	}
+	return stats, nil
+}
+
+// ResendOrderPlaced publishes the OrderPlaced event of an existing order
+// again, e.g. after it was lost downstream. Consumers must tolerate the
+// duplicate.
+func (s *orderServiceImpl) ResendOrderPlaced(ctx context.Context, orderID uuid.UUID) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.ResendOrderPlaced", trace.WithAttributes(
+		attribute.String("order_id", orderID.String()),
+	))
+	defer span.End()
+
+	order, err := s.getOrder(ctx, orderID)
+	if err != nil {
+		recordSpanError(span, err)
+		return nil, fmt.Errorf("service: failed to get order %s for event resend: %w", orderID, err)
+	}
+
+	if s.outbox != nil {
+		msg, err := orderPlacedOutboxMessage(ctx, order)
+		if err == nil {
+			err = s.db(ctx, func(ctx context.Context) error {
+				return s.orderRepo.EnqueueOutbox(ctx, msg)
+			})
+		}
+		if err != nil {
+			recordSpanError(span, err)
+			return nil, fmt.Errorf("service: failed to queue order placed event for order %s: %w", orderID, err)
+		}
+		s.outbox()
+		log.Ctx(ctx).Warn().Str("order_id", orderID.String()).Msg("Order placed event queued for resend")
+		return order, nil
+	}
+
+	eventValue, err := json.Marshal(NewOrderPlacedEvent(order))
+	if err != nil {
+		recordSpanError(span, err)
+		return nil, fmt.Errorf("service: failed to marshal order placed event for order %s: %w", orderID, err)
+	}
+	if err := s.publish(ctx, []byte(order.ID.String()), eventValue); err != nil {
+		recordSpanError(span, err)
+		log.Ctx(ctx).Error().Err(err).Str("order_id", orderID.String()).Msg("Service: failed to resend order placed event")
+		return nil, fmt.Errorf("service: failed to resend order placed event for order %s: %w", orderID, err)
+	}
+
+	log.Ctx(ctx).Warn().Str("order_id", orderID.String()).Msg("Order placed event resent")
+	return order, nil
+}
+
+// OrderEvents returns the events of an order's stream, oldest first. Each
+// value is a JSON object with the event's order_id, version, type,
+// occurred_at and data. Orders have no events without WithOrderHistory, and
+// orders stored before event sourcing was enabled have none until their
+// first status change.
+func (s *orderServiceImpl) OrderEvents(ctx context.Context, orderID uuid.UUID) ([]repository.StoredEvent, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.OrderEvents", trace.WithAttributes(
+		attribute.String("order_id", orderID.String()),
+	))
+	defer span.End()
+
+	if _, err := s.getOrder(ctx, orderID); err != nil {
+		recordSpanError(span, err)
+		return nil, fmt.Errorf("service: failed to get order %s for its events: %w", orderID, err)
+	}
+	stored := []repository.StoredEvent{}
+	if s.history == nil {
+		return stored, nil
+	}
+	err := s.db(ctx, func(ctx context.Context) error {
+		stored = stored[:0]
+		return s.history.StreamOrderEvents(ctx, repository.ReplayFilter{OrderID: orderID}, func(event repository.StoredEvent) error {
+			stored = append(stored, event)
+			return nil
+		})
+	})
+	if err != nil {
+		recordSpanError(span, err)
+		return nil, fmt.Errorf("service: failed to read events of order %s: %w", orderID, err)
+	}
+	return stored, nil
+}
+
+// NewOrderPlacedEvent builds the OrderPlaced event for order.
+func NewOrderPlacedEvent(order *domain.Order) events.OrderPlaced {
+	event := events.OrderPlaced{
+		OrderID:    order.ID,
+		CustomerID: order.CustomerID,
+		TotalPrice: order.TotalPrice,
+		Timestamp:  order.CreatedAt.UTC(),
+	}
+	for _, item := range order.Items {
+		event.Items = append(event.Items, events.OrderItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+		})
+	}
+	return event
+}
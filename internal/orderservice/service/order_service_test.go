@@ -2,12 +2,19 @@ package service_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/cache"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/catalog"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/domain"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/repository"
 	"github.com/jonamarkin/e-commerce-order-processing/internal/orderservice/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -29,7 +36,7 @@ func TestOrderService_CreateOrder(t *testing.T) {
 		mockRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil).Once()
 		mockProducer.On("PublishMessage", mock.Anything, mock.AnythingOfType("[]uint8"), mock.AnythingOfType("[]uint8")).Return(nil).Once()
 
-		order, err := orderService.CreateOrder(ctx, customerID, items)
+		order, err := orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{}, nil)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, order)
@@ -49,7 +56,7 @@ func TestOrderService_CreateOrder(t *testing.T) {
 
 		mockRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(errors.New("db error")).Once()
 
-		order, err := orderService.CreateOrder(ctx, customerID, items)
+		order, err := orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{}, nil)
 
 		assert.Error(t, err)
 		assert.Nil(t, order)
@@ -67,7 +74,7 @@ func TestOrderService_CreateOrder(t *testing.T) {
 		mockRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil).Once()
 		mockProducer.On("PublishMessage", mock.Anything, mock.AnythingOfType("[]uint8"), mock.AnythingOfType("[]uint8")).Return(errors.New("kafka error")).Once()
 
-		order, err := orderService.CreateOrder(ctx, customerID, items)
+		order, err := orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{}, nil)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, order)
@@ -75,6 +82,414 @@ func TestOrderService_CreateOrder(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 		mockProducer.AssertExpectations(t)
 	})
+
+	t.Run("unsellable product rejected by catalog", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		orderService := service.NewOrderService(mockRepo, mockProducer, service.WithCatalog(catalog.NewStubClient(productID)))
+
+		order, err := orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{}, nil)
+
+		assert.ErrorIs(t, err, domain.ErrProductNotSellable)
+		assert.Nil(t, order)
+		var unsellableErr *domain.UnsellableProductsError
+		if assert.ErrorAs(t, err, &unsellableErr) {
+			assert.Equal(t, []uuid.UUID{productID}, unsellableErr.ProductIDs)
+		}
+
+		mockRepo.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+		mockProducer.AssertNotCalled(t, "PublishMessage", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("unknown customer rejected", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		mockCustomers := new(MockCustomerValidator)
+		orderService := service.NewOrderService(mockRepo, mockProducer, service.WithCustomerValidator(mockCustomers))
+
+		mockCustomers.On("CustomerExists", mock.Anything, customerID).Return(false, nil).Once()
+
+		order, err := orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{}, nil)
+
+		assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+		assert.Nil(t, order)
+
+		mockCustomers.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+	})
+}
+
+func TestOrderService_CreateOrder_Outbox(t *testing.T) {
+	ctx := logging.WithCorrelationID(context.Background(), "req-1", nil)
+	items := []domain.OrderItem{{ProductID: uuid.New(), Quantity: 2, UnitPrice: 10.0}}
+
+	t.Run("event is stored with the order and not published", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		notified := 0
+		orderService := service.NewOrderService(mockRepo, mockProducer, service.WithOutbox(func() { notified++ }))
+
+		var stored []repository.OutboxMessage
+		mockRepo.On("CreateOrderWithOutbox", mock.Anything, mock.AnythingOfType("*domain.Order"), mock.Anything).
+			Run(func(args mock.Arguments) { stored = args.Get(2).([]repository.OutboxMessage) }).
+			Return(nil).Once()
+
+		order, err := orderService.CreateOrder(ctx, uuid.New(), items, domain.ExternalReference{}, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, notified)
+		if assert.Len(t, stored, 1) {
+			assert.Equal(t, events.TopicOrdersPlaced, stored[0].Topic)
+			assert.Equal(t, []byte(order.ID.String()), stored[0].Key)
+			assert.Equal(t, events.OrderPlacedType, stored[0].Type)
+			assert.Equal(t, "req-1", stored[0].CorrelationID)
+			var event events.OrderPlaced
+			assert.NoError(t, json.Unmarshal(stored[0].Value, &event))
+			assert.Equal(t, order.ID, event.OrderID)
+		}
+		mockRepo.AssertExpectations(t)
+		mockProducer.AssertNotCalled(t, "PublishMessage", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("order is not created when the transaction fails", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		notified := 0
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer), service.WithOutbox(func() { notified++ }))
+
+		mockRepo.On("CreateOrderWithOutbox", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("db error")).Once()
+
+		order, err := orderService.CreateOrder(ctx, uuid.New(), items, domain.ExternalReference{}, nil)
+
+		assert.ErrorContains(t, err, "failed to persist order")
+		assert.Nil(t, order)
+		assert.Zero(t, notified)
+	})
+}
+
+func TestOrderService_CreateOrder_Quota(t *testing.T) {
+	ctx := context.Background()
+	customerID := uuid.New()
+	items := []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 10.0}}
+
+	t.Run("customer over quota is rejected", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		quota := new(MockOrderQuota)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer), service.WithOrderQuota(quota))
+
+		quota.On("Reserve", mock.Anything, customerID).Return(&domain.QuotaExceededError{Window: "minute", Limit: 5, RetryAfter: time.Second}).Once()
+
+		order, err := orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{}, nil)
+
+		assert.Nil(t, order)
+		var exceeded *domain.QuotaExceededError
+		if assert.ErrorAs(t, err, &exceeded) {
+			assert.Equal(t, "minute", exceeded.Window)
+		}
+		mockRepo.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+		quota.AssertExpectations(t)
+	})
+
+	t.Run("order is accepted when the quota cannot be checked", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		quota := new(MockOrderQuota)
+		orderService := service.NewOrderService(mockRepo, mockProducer, service.WithOrderQuota(quota))
+
+		quota.On("Reserve", mock.Anything, customerID).Return(errors.New("redis: connection refused")).Once()
+		mockRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil).Once()
+		mockProducer.On("PublishMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+
+		order, err := orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{}, nil)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, order)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("rejected orders leave the quota untouched", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		quota := new(MockOrderQuota)
+		customers := new(MockCustomerValidator)
+		checker := new(MockAvailabilityChecker)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer), service.WithOrderQuota(quota),
+			service.WithCustomerValidator(customers), service.WithAvailabilityCheck(checker))
+
+		customers.On("CustomerExists", mock.Anything, customerID).Return(true, nil)
+		checker.On("OutOfStockProducts", mock.Anything, items).Return([]uuid.UUID{items[0].ProductID}, nil).Once()
+
+		order, err := orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{}, nil)
+
+		assert.Nil(t, order)
+		assert.ErrorIs(t, err, domain.ErrOutOfStock)
+		quota.AssertNotCalled(t, "Reserve", mock.Anything, mock.Anything)
+
+		customers.On("CustomerExists", mock.Anything, customerID).Unset()
+		customers.On("CustomerExists", mock.Anything, customerID).Return(false, nil).Once()
+
+		order, err = orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{}, nil)
+
+		assert.Nil(t, order)
+		assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+		quota.AssertNotCalled(t, "Reserve", mock.Anything, mock.Anything)
+	})
+}
+
+func TestOrderService_CreateOrder_Availability(t *testing.T) {
+	ctx := context.Background()
+	productID := uuid.New()
+	items := []domain.OrderItem{{ProductID: productID, Quantity: 3, UnitPrice: 10.0}}
+
+	t.Run("out-of-stock products are rejected", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		checker := new(MockAvailabilityChecker)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer), service.WithAvailabilityCheck(checker))
+
+		checker.On("OutOfStockProducts", mock.Anything, items).Return([]uuid.UUID{productID}, nil).Once()
+
+		order, err := orderService.CreateOrder(ctx, uuid.New(), items, domain.ExternalReference{}, nil)
+
+		assert.Nil(t, order)
+		assert.ErrorIs(t, err, domain.ErrOutOfStock)
+		var outOfStock *domain.OutOfStockError
+		if assert.ErrorAs(t, err, &outOfStock) {
+			assert.Equal(t, []uuid.UUID{productID}, outOfStock.ProductIDs)
+		}
+		mockRepo.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+		checker.AssertExpectations(t)
+	})
+
+	for name, result := range map[string]error{
+		"order in stock is accepted":                     nil,
+		"order is accepted when stock cannot be checked": errors.New("inventory: unavailable"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockOrderRepository)
+			mockProducer := new(MockKafkaProducer)
+			checker := new(MockAvailabilityChecker)
+			orderService := service.NewOrderService(mockRepo, mockProducer, service.WithAvailabilityCheck(checker))
+
+			checker.On("OutOfStockProducts", mock.Anything, items).Return(nil, result).Once()
+			mockRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil).Once()
+			mockProducer.On("PublishMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+
+			order, err := orderService.CreateOrder(ctx, uuid.New(), items, domain.ExternalReference{}, nil)
+
+			assert.NoError(t, err)
+			assert.NotNil(t, order)
+			mockRepo.AssertExpectations(t)
+			checker.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_CreateOrder_ShippingAddress(t *testing.T) {
+	ctx := context.Background()
+	items := []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 10.0}}
+	address := &domain.Address{Line1: " 1600  Amphitheatre Pkwy ", City: "Mountain View", PostalCode: "94043", Country: "us"}
+	normalized := domain.Address{Line1: "1600 Amphitheatre Pkwy", City: "Mountain View", PostalCode: "94043", Country: "US"}
+
+	t.Run("address is stored normalized", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		orderService := service.NewOrderService(mockRepo, mockProducer)
+
+		mockRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil).Once()
+		mockProducer.On("PublishMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+
+		order, err := orderService.CreateOrder(ctx, uuid.New(), items, domain.ExternalReference{}, address)
+
+		assert.NoError(t, err)
+		if assert.NotNil(t, order) && assert.NotNil(t, order.ShippingAddress) {
+			assert.Equal(t, normalized, *order.ShippingAddress)
+		}
+	})
+
+	t.Run("incomplete address is rejected", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer))
+
+		order, err := orderService.CreateOrder(ctx, uuid.New(), items, domain.ExternalReference{}, &domain.Address{Line1: "1 Main St", Country: "US"})
+
+		assert.Nil(t, order)
+		assert.ErrorIs(t, err, domain.ErrInvalidAddress)
+		mockRepo.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("verified address is stored as the verifier returns it", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		verifier := new(MockAddressVerifier)
+		orderService := service.NewOrderService(mockRepo, mockProducer, service.WithAddressVerifier(verifier))
+
+		verified := normalized
+		verified.PostalCode = "94043-1351"
+		verifier.On("Verify", mock.Anything, normalized).Return(verified, nil).Once()
+		mockRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil).Once()
+		mockProducer.On("PublishMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+
+		order, err := orderService.CreateOrder(ctx, uuid.New(), items, domain.ExternalReference{}, address)
+
+		assert.NoError(t, err)
+		if assert.NotNil(t, order) && assert.NotNil(t, order.ShippingAddress) {
+			assert.Equal(t, verified, *order.ShippingAddress)
+		}
+		verifier.AssertExpectations(t)
+	})
+
+	for name, result := range map[string]error{
+		"undeliverable address is rejected":             &domain.InvalidAddressError{Fields: []domain.AddressFieldError{{Field: "line1", Constraint: "deliverable", Message: "does not exist"}}},
+		"address is rejected when it cannot be checked": errors.New("address: unavailable"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockOrderRepository)
+			verifier := new(MockAddressVerifier)
+			orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer), service.WithAddressVerifier(verifier))
+
+			verifier.On("Verify", mock.Anything, normalized).Return(domain.Address{}, result).Once()
+
+			order, err := orderService.CreateOrder(ctx, uuid.New(), items, domain.ExternalReference{}, address)
+
+			assert.Nil(t, order)
+			assert.ErrorIs(t, err, result)
+			mockRepo.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestOrderService_CreateOrder_Duplicates(t *testing.T) {
+	ctx := context.Background()
+	customerID := uuid.New()
+	existingID := uuid.New()
+	items := []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 10.0}}
+	duplicates := service.DuplicateConfig{Enabled: true, Window: 30 * time.Second, Action: service.DuplicateActionReject}
+
+	t.Run("duplicate is rejected with the existing order", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer), service.WithDuplicateDetection(duplicates))
+
+		var since time.Time
+		mockRepo.On("FindDuplicateOrder", mock.Anything, mock.AnythingOfType("*domain.Order"), mock.Anything).
+			Run(func(args mock.Arguments) {
+				order := args.Get(1).(*domain.Order)
+				since = args.Get(2).(time.Time)
+				assert.Equal(t, 30*time.Second, order.CreatedAt.Sub(since))
+			}).
+			Return(existingID, nil).Once()
+
+		order, err := orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{}, nil)
+
+		assert.Nil(t, order)
+		var duplicateErr *domain.DuplicateOrderError
+		if assert.ErrorAs(t, err, &duplicateErr) {
+			assert.Equal(t, existingID, duplicateErr.ExistingOrderID)
+		}
+		assert.False(t, since.IsZero())
+		mockRepo.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("duplicate is flagged and created", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		flag := duplicates
+		flag.Action = service.DuplicateActionFlag
+		orderService := service.NewOrderService(mockRepo, mockProducer, service.WithDuplicateDetection(flag))
+
+		mockRepo.On("FindDuplicateOrder", mock.Anything, mock.Anything, mock.Anything).Return(existingID, nil).Once()
+		mockRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil).Once()
+		mockProducer.On("PublishMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+
+		order, err := orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{}, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, existingID, order.DuplicateOf)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("order is created when the lookup fails", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		orderService := service.NewOrderService(mockRepo, mockProducer, service.WithDuplicateDetection(duplicates))
+
+		mockRepo.On("FindDuplicateOrder", mock.Anything, mock.Anything, mock.Anything).Return(uuid.Nil, errors.New("db error")).Once()
+		mockRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*domain.Order")).Return(nil).Once()
+		mockProducer.On("PublishMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+
+		order, err := orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{}, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, uuid.Nil, order.DuplicateOf)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestOrderService_CreateOrder_ExternalReference(t *testing.T) {
+	ctx := context.Background()
+	customerID := uuid.New()
+	existingID := uuid.New()
+	items := []domain.OrderItem{{ProductID: uuid.New(), Quantity: 1, UnitPrice: 10.0}}
+	ref := domain.ExternalReference{Source: "shopify", Reference: "#1001"}
+
+	t.Run("order is created with its reference", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		orderService := service.NewOrderService(mockRepo, mockProducer)
+
+		mockRepo.On("GetOrderByExternalReference", mock.Anything, ref).Return((*domain.Order)(nil), domain.ErrOrderNotFound).Once()
+		mockRepo.On("CreateOrder", mock.Anything, mock.MatchedBy(func(order *domain.Order) bool {
+			return order.ExternalReference == ref
+		})).Return(nil).Once()
+		mockProducer.On("PublishMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+
+		order, err := orderService.CreateOrder(ctx, customerID, items, ref, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, ref, order.ExternalReference)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("used reference is rejected with the existing order", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer))
+
+		mockRepo.On("GetOrderByExternalReference", mock.Anything, ref).Return(&domain.Order{ID: existingID}, nil).Once()
+
+		order, err := orderService.CreateOrder(ctx, customerID, items, ref, nil)
+
+		assert.Nil(t, order)
+		var conflictErr *domain.ExternalReferenceConflictError
+		if assert.ErrorAs(t, err, &conflictErr) {
+			assert.Equal(t, existingID, conflictErr.ExistingOrderID)
+		}
+		mockRepo.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("reference taken concurrently is reported with the existing order", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer))
+
+		mockRepo.On("GetOrderByExternalReference", mock.Anything, ref).Return((*domain.Order)(nil), domain.ErrOrderNotFound).Once()
+		mockRepo.On("CreateOrder", mock.Anything, mock.Anything).
+			Return(fmt.Errorf("failed to insert order: %w", domain.ErrExternalReferenceExists)).Once()
+		mockRepo.On("GetOrderByExternalReference", mock.Anything, ref).Return(&domain.Order{ID: existingID}, nil).Once()
+
+		_, err := orderService.CreateOrder(ctx, customerID, items, ref, nil)
+
+		var conflictErr *domain.ExternalReferenceConflictError
+		if assert.ErrorAs(t, err, &conflictErr) {
+			assert.Equal(t, existingID, conflictErr.ExistingOrderID)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("incomplete reference is rejected", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer))
+
+		_, err := orderService.CreateOrder(ctx, customerID, items, domain.ExternalReference{Source: "shopify"}, nil)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidExternalReference)
+		mockRepo.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+	})
 }
 
 func TestOrderService_GetOrderByID(t *testing.T) {
@@ -143,3 +558,455 @@ func TestOrderService_GetOrderByID(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestOrderService_UpdateOrderStatus(t *testing.T) {
+	ctx := context.Background()
+	orderID := uuid.New()
+
+	t.Run("valid transition is persisted", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		orderService := service.NewOrderService(mockRepo, mockProducer)
+
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(&domain.Order{ID: orderID, Status: domain.OrderStatusPending}, nil).Once()
+		mockRepo.On("UpdateOrderStatus", mock.Anything, orderID, domain.OrderStatusProcessing, mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		order, err := orderService.UpdateOrderStatus(ctx, orderID, domain.OrderStatusProcessing)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.OrderStatusProcessing, order.Status)
+		// The stored update time is the one returned.
+		assert.Equal(t, order.UpdatedAt, mockRepo.Calls[1].Arguments.Get(3))
+		assert.Equal(t, time.UTC, order.UpdatedAt.Location())
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid transition is rejected", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		orderService := service.NewOrderService(mockRepo, mockProducer)
+
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(&domain.Order{ID: orderID, Status: domain.OrderStatusCompleted}, nil).Once()
+
+		order, err := orderService.UpdateOrderStatus(ctx, orderID, domain.OrderStatusProcessing)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidOrderStatusTransition)
+		var transitionErr *domain.InvalidTransitionError
+		if assert.ErrorAs(t, err, &transitionErr) {
+			assert.Equal(t, domain.OrderStatusCompleted, transitionErr.From)
+			assert.Equal(t, domain.OrderStatusProcessing, transitionErr.To)
+		}
+		assert.Nil(t, order)
+		mockRepo.AssertNotCalled(t, "UpdateOrderStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("transition rejected by the database is reported", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer))
+
+		// The order was cancelled between the read and the update.
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(&domain.Order{ID: orderID, Status: domain.OrderStatusProcessing}, nil).Once()
+		mockRepo.On("UpdateOrderStatus", mock.Anything, orderID, domain.OrderStatusCompleted, mock.AnythingOfType("time.Time")).
+			Return(&domain.InvalidTransitionError{From: domain.OrderStatusCancelled, To: domain.OrderStatusCompleted}).Once()
+
+		order, err := orderService.UpdateOrderStatus(ctx, orderID, domain.OrderStatusCompleted)
+
+		assert.Nil(t, order)
+		var transitionErr *domain.InvalidTransitionError
+		if assert.ErrorAs(t, err, &transitionErr) {
+			assert.Equal(t, domain.OrderStatusCancelled, transitionErr.From)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("status change is published when status events are enabled", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		statusEvents := new(MockKafkaProducer)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer), service.WithStatusEvents(statusEvents))
+		customerID := uuid.New()
+
+		processing := func() *domain.Order {
+			return &domain.Order{ID: orderID, CustomerID: customerID, Status: domain.OrderStatusProcessing}
+		}
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(processing(), nil).Once()
+		mockRepo.On("UpdateOrderStatus", mock.Anything, orderID, mock.Anything, mock.AnythingOfType("time.Time")).Return(nil).Twice()
+		var published events.OrderStatusChanged
+		statusEvents.On("PublishMessage", mock.Anything, []byte(orderID.String()), mock.Anything).Run(func(args mock.Arguments) {
+			assert.NoError(t, json.Unmarshal(args.Get(2).([]byte), &published))
+		}).Return(nil).Once()
+
+		order, err := orderService.UpdateOrderStatus(ctx, orderID, domain.OrderStatusFailed)
+
+		assert.NoError(t, err)
+		assert.Equal(t, events.OrderStatusChanged{
+			OrderID:    orderID,
+			CustomerID: customerID,
+			From:       "processing",
+			To:         "failed",
+			Timestamp:  order.UpdatedAt,
+		}, published)
+
+		// The change stands when the event can't be published.
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(processing(), nil).Once()
+		statusEvents.On("PublishMessage", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("broker unavailable")).Once()
+		order, err = orderService.UpdateOrderStatus(ctx, orderID, domain.OrderStatusCompleted)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.OrderStatusCompleted, order.Status)
+		mockRepo.AssertExpectations(t)
+		statusEvents.AssertExpectations(t)
+	})
+}
+
+func TestOrderService_OrderCache(t *testing.T) {
+	ctx := context.Background()
+	orderID := uuid.New()
+	pending := func() *domain.Order {
+		return &domain.Order{ID: orderID, Status: domain.OrderStatusPending}
+	}
+
+	t.Run("repeated reads are served from the cache", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer),
+			service.WithOrderCache(cache.New(cache.Config{Size: 10, TTL: time.Minute})))
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(pending(), nil).Once()
+
+		for range 3 {
+			order, err := orderService.GetOrderByID(ctx, orderID)
+			assert.NoError(t, err)
+			assert.Equal(t, domain.OrderStatusPending, order.Status)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("status updates refresh the cached order", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer),
+			service.WithOrderCache(cache.New(cache.Config{Size: 10, TTL: time.Minute})))
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(pending(), nil).Twice()
+		mockRepo.On("UpdateOrderStatus", mock.Anything, orderID, domain.OrderStatusCancelled, mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		_, err := orderService.GetOrderByID(ctx, orderID)
+		assert.NoError(t, err)
+		_, err = orderService.UpdateOrderStatus(ctx, orderID, domain.OrderStatusCancelled)
+		assert.NoError(t, err)
+		order, err := orderService.GetOrderByID(ctx, orderID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.OrderStatusCancelled, order.Status)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("failed updates invalidate the cached order", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer),
+			service.WithOrderCache(cache.New(cache.Config{Size: 10, TTL: time.Minute})))
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(pending(), nil).Times(3)
+		mockRepo.On("UpdateOrderStatus", mock.Anything, orderID, domain.OrderStatusProcessing, mock.AnythingOfType("time.Time")).Return(errors.New("connection reset")).Once()
+
+		_, err := orderService.GetOrderByID(ctx, orderID)
+		assert.NoError(t, err)
+		_, err = orderService.UpdateOrderStatus(ctx, orderID, domain.OrderStatusProcessing)
+		assert.Error(t, err)
+		_, err = orderService.GetOrderByID(ctx, orderID)
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("stale orders are served when the repository fails", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer),
+			service.WithOrderCache(cache.New(cache.Config{Size: 10, TTL: 0, StaleTTL: time.Hour})))
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(pending(), nil).Once()
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return((*domain.Order)(nil), errors.New("database is down")).Once()
+
+		_, err := orderService.GetOrderByID(ctx, orderID)
+		assert.NoError(t, err)
+		order, err := orderService.GetOrderByID(ctx, orderID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, orderID, order.ID)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestOrderService_ListOrders(t *testing.T) {
+	ctx := context.Background()
+	filter := repository.OrderFilter{Status: domain.OrderStatusPending, Limit: 10}
+
+	mockRepo := new(MockOrderRepository)
+	mockProducer := new(MockKafkaProducer)
+	orderService := service.NewOrderService(mockRepo, mockProducer)
+
+	expected := []*domain.Order{{ID: uuid.New(), Status: domain.OrderStatusPending}}
+	mockRepo.On("ListOrders", mock.Anything, filter).Return(expected, nil).Once()
+
+	orders, err := orderService.ListOrders(ctx, filter)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, orders)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOrderService_OrderStats(t *testing.T) {
+	ctx := context.Background()
+	filter := repository.OrderFilter{CustomerID: uuid.New()}
+
+	mockRepo := new(MockOrderRepository)
+	orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer))
+
+	expected := []repository.OrderStatusStats{{Status: domain.OrderStatusPending, Orders: 2, Items: 3, TotalPrice: 30}}
+	mockRepo.On("OrderStats", mock.Anything, filter).Return(expected, nil).Once()
+	stats, err := orderService.OrderStats(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, stats)
+
+	mockRepo.On("OrderStats", mock.Anything, filter).Return([]repository.OrderStatusStats(nil), errors.New("db down")).Once()
+	_, err = orderService.OrderStats(ctx, filter)
+	assert.ErrorContains(t, err, "db down")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOrderService_ResendOrderPlaced(t *testing.T) {
+	ctx := context.Background()
+	orderID := uuid.New()
+	existing := &domain.Order{
+		ID:         orderID,
+		CustomerID: uuid.New(),
+		Status:     domain.OrderStatusProcessing,
+		TotalPrice: 20.0,
+		Items:      []domain.OrderItem{{ProductID: uuid.New(), Quantity: 2, UnitPrice: 10.0}},
+	}
+
+	t.Run("event is republished", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		orderService := service.NewOrderService(mockRepo, mockProducer)
+
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(existing, nil).Once()
+		mockProducer.On("PublishMessage", mock.Anything, []byte(orderID.String()), mock.MatchedBy(func(value []byte) bool {
+			var event events.OrderPlaced
+			return json.Unmarshal(value, &event) == nil && event.OrderID == orderID && len(event.Items) == 1
+		})).Return(nil).Once()
+
+		order, err := orderService.ResendOrderPlaced(ctx, orderID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, existing, order)
+		mockProducer.AssertExpectations(t)
+	})
+
+	t.Run("publish failure is returned", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		orderService := service.NewOrderService(mockRepo, mockProducer)
+
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(existing, nil).Once()
+		mockProducer.On("PublishMessage", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("kafka error")).Once()
+
+		order, err := orderService.ResendOrderPlaced(ctx, orderID)
+
+		assert.ErrorContains(t, err, "kafka error")
+		assert.Nil(t, order)
+	})
+
+	t.Run("event is queued in the outbox", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		notified := 0
+		orderService := service.NewOrderService(mockRepo, mockProducer, service.WithOutbox(func() { notified++ }))
+
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(existing, nil).Once()
+		mockRepo.On("EnqueueOutbox", mock.Anything, mock.MatchedBy(func(msgs []repository.OutboxMessage) bool {
+			return len(msgs) == 1 && string(msgs[0].Key) == orderID.String() && msgs[0].Topic == events.TopicOrdersPlaced
+		})).Return(nil).Once()
+
+		order, err := orderService.ResendOrderPlaced(ctx, orderID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, existing, order)
+		assert.Equal(t, 1, notified)
+		mockRepo.AssertExpectations(t)
+		mockProducer.AssertNotCalled(t, "PublishMessage", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+// storedHistory serves fixed order events from StreamOrderEvents.
+type storedHistory struct {
+	events []repository.StoredEvent
+	filter repository.ReplayFilter
+}
+
+func (h *storedHistory) StreamOrderEvents(_ context.Context, filter repository.ReplayFilter, fn func(repository.StoredEvent) error) error {
+	h.filter = filter
+	for _, event := range h.events {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestOrderService_OrderEvents(t *testing.T) {
+	ctx := context.Background()
+	orderID := uuid.New()
+	existing := &domain.Order{ID: orderID, CustomerID: uuid.New(), Status: domain.OrderStatusPending}
+
+	t.Run("events of the order's stream", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		history := &storedHistory{events: []repository.StoredEvent{
+			{Type: "order_created", Value: []byte(`{"version":1}`)},
+			{Type: "order_status_changed", Value: []byte(`{"version":2}`)},
+		}}
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer), service.WithOrderHistory(history))
+
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(existing, nil).Once()
+		stored, err := orderService.OrderEvents(ctx, orderID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, history.events, stored)
+		assert.Equal(t, repository.ReplayFilter{OrderID: orderID}, history.filter)
+	})
+
+	t.Run("no events without history", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer))
+
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(existing, nil).Once()
+		stored, err := orderService.OrderEvents(ctx, orderID)
+
+		assert.NoError(t, err)
+		assert.Empty(t, stored)
+		assert.NotNil(t, stored)
+	})
+
+	t.Run("unknown order", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		history := &storedHistory{}
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer), service.WithOrderHistory(history))
+
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return((*domain.Order)(nil), domain.ErrOrderNotFound).Once()
+		_, err := orderService.OrderEvents(ctx, orderID)
+
+		assert.ErrorIs(t, err, domain.ErrOrderNotFound)
+	})
+}
+
+func TestOrderService_StreamOrders(t *testing.T) {
+	ctx := context.Background()
+	filter := repository.OrderFilter{Status: domain.OrderStatusCompleted}
+	orders := []*domain.Order{{ID: uuid.New()}, {ID: uuid.New()}}
+
+	t.Run("orders are passed through", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer))
+		mockRepo.On("StreamOrders", mock.Anything, filter, mock.Anything).Return(orders, nil).Once()
+
+		var got []*domain.Order
+		err := orderService.StreamOrders(ctx, filter, func(order *domain.Order) error {
+			got = append(got, order)
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, orders, got)
+	})
+
+	t.Run("callback error stops the stream", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer))
+		mockRepo.On("StreamOrders", mock.Anything, filter, mock.Anything).Return(orders, nil).Once()
+
+		writeErr := errors.New("client went away")
+		calls := 0
+		err := orderService.StreamOrders(ctx, filter, func(*domain.Order) error {
+			calls++
+			return writeErr
+		})
+
+		assert.ErrorIs(t, err, writeErr)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestOrderService_Timeouts(t *testing.T) {
+	ctx := context.Background()
+	orderID := uuid.New()
+	timeouts := service.TimeoutConfig{DB: 10 * time.Millisecond, Publish: 10 * time.Millisecond}
+	// waitForDeadline blocks like a dependency that stopped answering.
+	waitForDeadline := func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}
+
+	t.Run("slow database calls time out", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer), service.WithTimeouts(timeouts))
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Run(waitForDeadline).
+			Return((*domain.Order)(nil), errors.New("pq: canceling statement due to user request")).Once()
+
+		_, err := orderService.GetOrderByID(ctx, orderID)
+
+		assert.ErrorIs(t, err, service.ErrDatabaseTimeout)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("slow publishes time out", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		mockProducer := new(MockKafkaProducer)
+		orderService := service.NewOrderService(mockRepo, mockProducer, service.WithTimeouts(timeouts))
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(&domain.Order{ID: orderID}, nil).Once()
+		mockProducer.On("PublishMessage", mock.Anything, mock.Anything, mock.Anything).Run(waitForDeadline).
+			Return(context.DeadlineExceeded).Once()
+
+		_, err := orderService.ResendOrderPlaced(ctx, orderID)
+
+		assert.ErrorIs(t, err, service.ErrPublishTimeout)
+		assert.NotErrorIs(t, err, service.ErrDatabaseTimeout)
+	})
+
+	t.Run("other failures are not timeouts", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer), service.WithTimeouts(timeouts))
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return((*domain.Order)(nil), errors.New("connection reset")).Once()
+
+		_, err := orderService.GetOrderByID(ctx, orderID)
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, service.ErrDatabaseTimeout)
+	})
+}
+
+func TestOrderService_OrderLocks(t *testing.T) {
+	ctx := context.Background()
+	orderID := uuid.New()
+
+	t.Run("status updates hold the order lock", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		locker := &fakeOrderLocker{}
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer), service.WithOrderLocks(locker))
+		mockRepo.On("GetOrderByID", mock.Anything, orderID).Return(&domain.Order{ID: orderID, Status: domain.OrderStatusPending}, nil).Once()
+		mockRepo.On("UpdateOrderStatus", mock.Anything, orderID, domain.OrderStatusCancelled, mock.AnythingOfType("time.Time")).
+			Run(func(mock.Arguments) {
+				assert.Equal(t, []string{"lock " + orderID.String()}, locker.events, "the update runs under the lock")
+			}).
+			Return(nil).Once()
+
+		_, err := orderService.UpdateOrderStatus(ctx, orderID, domain.OrderStatusCancelled)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"lock " + orderID.String(), "unlock " + orderID.String()}, locker.events)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("locked orders are not changed", func(t *testing.T) {
+		mockRepo := new(MockOrderRepository)
+		orderService := service.NewOrderService(mockRepo, new(MockKafkaProducer),
+			service.WithOrderLocks(&fakeOrderLocker{err: domain.ErrOrderLocked}))
+
+		_, err := orderService.UpdateOrderStatus(ctx, orderID, domain.OrderStatusCancelled)
+
+		assert.ErrorIs(t, err, domain.ErrOrderLocked)
+		mockRepo.AssertNotCalled(t, "GetOrderByID", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned when an operation of the order service runs out of time.
+var (
+	ErrDatabaseTimeout = errors.New("database operation timed out")
+	ErrPublishTimeout  = errors.New("event publish timed out")
+)
+
+// TimeoutConfig bounds the operations the order service performs on its
+// dependencies, whatever deadline the caller passes down. A zero timeout
+// leaves the caller's deadline alone.
+type TimeoutConfig struct {
+	// DB bounds each repository call. Order streams, such as exports, are
+	// not bounded.
+	DB time.Duration `key:"db" env:"ORDER_DB_TIMEOUT" default:"5s"`
+	// Publish bounds each event published to Kafka while handling a request.
+	Publish time.Duration `key:"publish" env:"ORDER_PUBLISH_TIMEOUT" default:"5s"`
+}
+
+// WithTimeouts bounds repository calls and event publishes as cfg says.
+// Operations that run out of time fail with ErrDatabaseTimeout or
+// ErrPublishTimeout.
+func WithTimeouts(cfg TimeoutConfig) Option {
+	return func(s *orderServiceImpl) {
+		s.timeouts = cfg
+	}
+}
+
+// db runs fn, a repository call, under the DB timeout.
+func (s *orderServiceImpl) db(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withTimeout(ctx, s.timeouts.DB, ErrDatabaseTimeout, fn)
+}
+
+// publish publishes an event under the publish timeout.
+func (s *orderServiceImpl) publish(ctx context.Context, key, value []byte) error {
+	return withTimeout(ctx, s.timeouts.Publish, ErrPublishTimeout, func(ctx context.Context) error {
+		return s.publisher.PublishMessage(ctx, key, value)
+	})
+}
+
+// withTimeout runs fn with a context that expires after d. If fn fails once
+// the deadline has passed, the error is reported as timeoutErr: drivers often
+// return their own cancellation errors rather than ctx.Err().
+func withTimeout(ctx context.Context, d time.Duration, timeoutErr error, fn func(ctx context.Context) error) error {
+	if d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	err := fn(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.Is(err, timeoutErr) {
+		return fmt.Errorf("%w: %w", timeoutErr, err)
+	}
+	return err
+}
@@ -0,0 +1,10 @@
+package domain
+
+import "errors"
+
+var (
+	ErrInvalidPaymentAmount           = errors.New("payment amount must be positive")
+	ErrInvalidCurrency                = errors.New("invalid currency")
+	ErrPaymentProviderRequired        = errors.New("payment provider required")
+	ErrInvalidPaymentStatusTransition = errors.New("invalid payment status transition")
+)
@@ -0,0 +1,157 @@
+// Package domain models the payments taken for orders and the lifecycle
+// they go through with a payment provider.
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Payment is a payment of Amount, in the minor units of Currency (cents for
+// USD, yen for JPY), for an order. ProviderReference is the provider's ID
+// of the payment, known once the provider has seen it.
+type Payment struct {
+	ID                uuid.UUID     `json:"id"`
+	OrderID           uuid.UUID     `json:"order_id"`
+	Amount            int64         `json:"amount"`
+	Currency          string        `json:"currency"`
+	Status            PaymentStatus `json:"status"`
+	Provider          string        `json:"provider"`
+	ProviderReference string        `json:"provider_reference,omitempty"`
+	// FailureReason says why the payment failed, as reported by the
+	// provider.
+	FailureReason string    `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Now returns the current time as payments record it: in UTC, truncated to
+// the microsecond precision PostgreSQL stores.
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+type PaymentStatus string
+
+const (
+	// PaymentStatusRequiresAction waits for the customer or the provider,
+	// for example for 3-D Secure authentication.
+	PaymentStatusRequiresAction PaymentStatus = "requires_action"
+	// PaymentStatusAuthorized holds the amount on the customer's account.
+	PaymentStatusAuthorized PaymentStatus = "authorized"
+	// PaymentStatusCaptured has taken the amount.
+	PaymentStatusCaptured PaymentStatus = "captured"
+	PaymentStatusFailed   PaymentStatus = "failed"
+	// PaymentStatusRefunded has returned a captured amount to the customer.
+	PaymentStatusRefunded PaymentStatus = "refunded"
+)
+
+// ParsePaymentStatus validates s and returns it as a PaymentStatus.
+func ParsePaymentStatus(s string) (PaymentStatus, bool) {
+	switch status := PaymentStatus(s); status {
+	case PaymentStatusRequiresAction, PaymentStatusAuthorized, PaymentStatusCaptured, PaymentStatusFailed, PaymentStatusRefunded:
+		return status, true
+	default:
+		return "", false
+	}
+}
+
+// NewPayment creates a payment of amount minor units of currency, an ISO
+// 4217 code, for orderID through provider. The payment starts out requiring
+// action until the provider authorizes or captures it.
+func NewPayment(orderID uuid.UUID, amount int64, currency, provider string) (*Payment, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidPaymentAmount
+	}
+	currency, err := normalizeCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(provider) == "" {
+		return nil, ErrPaymentProviderRequired
+	}
+
+	now := Now()
+	return &Payment{
+		ID:        uuid.New(),
+		OrderID:   orderID,
+		Amount:    amount,
+		Currency:  currency,
+		Status:    PaymentStatusRequiresAction,
+		Provider:  provider,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// normalizeCurrency upper-cases currency and checks that it looks like an
+// ISO 4217 code.
+func normalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if len(currency) != 3 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCurrency, currency)
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return "", fmt.Errorf("%w: %q", ErrInvalidCurrency, currency)
+		}
+	}
+	return currency, nil
+}
+
+// allowedTransitions lists the statuses each status may move to. A payment
+// may be captured without a separate authorization, and only captured
+// payments can be refunded.
+var allowedTransitions = map[PaymentStatus][]PaymentStatus{
+	PaymentStatusRequiresAction: {PaymentStatusAuthorized, PaymentStatusCaptured, PaymentStatusFailed},
+	PaymentStatusAuthorized:     {PaymentStatusCaptured, PaymentStatusFailed},
+	PaymentStatusCaptured:       {PaymentStatusRefunded},
+}
+
+// CanTransitionTo reports whether the payment may move from its current
+// status to next.
+func (p *Payment) CanTransitionTo(next PaymentStatus) bool {
+	return slices.Contains(allowedTransitions[p.Status], next)
+}
+
+// AllowedPreviousStatuses returns the statuses a payment may move to next
+// from.
+func AllowedPreviousStatuses(next PaymentStatus) []PaymentStatus {
+	var from []PaymentStatus
+	for status, allowed := range allowedTransitions {
+		if slices.Contains(allowed, next) {
+			from = append(from, status)
+		}
+	}
+	slices.Sort(from)
+	return from
+}
+
+// IsFinal reports whether the payment can no longer change status.
+func (p *Payment) IsFinal() bool {
+	return len(allowedTransitions[p.Status]) == 0
+}
+
+// TransitionTo moves the payment to next, enforcing the allowed status
+// transitions.
+func (p *Payment) TransitionTo(next PaymentStatus) error {
+	if !p.CanTransitionTo(next) {
+		return ErrInvalidPaymentStatusTransition
+	}
+	p.Status = next
+	p.UpdatedAt = Now()
+	return nil
+}
+
+// Fail moves the payment to failed for reason.
+func (p *Payment) Fail(reason string) error {
+	if err := p.TransitionTo(PaymentStatusFailed); err != nil {
+		return err
+	}
+	p.FailureReason = reason
+	return nil
+}
@@ -0,0 +1,117 @@
+package domain_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/paymentservice/domain"
+)
+
+func TestNewPayment(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   int64
+		currency string
+		provider string
+		wantErr  error
+	}{
+		{name: "valid payment", amount: 1999, currency: "usd", provider: "stripe"},
+		{name: "zero amount", amount: 0, currency: "USD", provider: "stripe", wantErr: domain.ErrInvalidPaymentAmount},
+		{name: "negative amount", amount: -5, currency: "USD", provider: "stripe", wantErr: domain.ErrInvalidPaymentAmount},
+		{name: "malformed currency", amount: 100, currency: "US1", provider: "stripe", wantErr: domain.ErrInvalidCurrency},
+		{name: "currency too long", amount: 100, currency: "EURO", provider: "stripe", wantErr: domain.ErrInvalidCurrency},
+		{name: "no provider", amount: 100, currency: "EUR", provider: " ", wantErr: domain.ErrPaymentProviderRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderID := uuid.New()
+			payment, err := domain.NewPayment(orderID, tt.amount, tt.currency, tt.provider)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewPayment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if payment.OrderID != orderID || payment.Amount != tt.amount || payment.Currency != "USD" {
+				t.Errorf("NewPayment() = %+v", payment)
+			}
+			if payment.Status != domain.PaymentStatusRequiresAction {
+				t.Errorf("NewPayment() status = %v, want %v", payment.Status, domain.PaymentStatusRequiresAction)
+			}
+		})
+	}
+}
+
+func TestPayment_TransitionTo(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    domain.PaymentStatus
+		to      domain.PaymentStatus
+		wantErr error
+	}{
+		{name: "requires action to authorized", from: domain.PaymentStatusRequiresAction, to: domain.PaymentStatusAuthorized},
+		{name: "requires action to captured", from: domain.PaymentStatusRequiresAction, to: domain.PaymentStatusCaptured},
+		{name: "authorized to captured", from: domain.PaymentStatusAuthorized, to: domain.PaymentStatusCaptured},
+		{name: "authorized to failed", from: domain.PaymentStatusAuthorized, to: domain.PaymentStatusFailed},
+		{name: "captured to refunded", from: domain.PaymentStatusCaptured, to: domain.PaymentStatusRefunded},
+		{name: "authorized to refunded", from: domain.PaymentStatusAuthorized, to: domain.PaymentStatusRefunded, wantErr: domain.ErrInvalidPaymentStatusTransition},
+		{name: "captured to failed", from: domain.PaymentStatusCaptured, to: domain.PaymentStatusFailed, wantErr: domain.ErrInvalidPaymentStatusTransition},
+		{name: "failed to authorized", from: domain.PaymentStatusFailed, to: domain.PaymentStatusAuthorized, wantErr: domain.ErrInvalidPaymentStatusTransition},
+		{name: "refunded to captured", from: domain.PaymentStatusRefunded, to: domain.PaymentStatusCaptured, wantErr: domain.ErrInvalidPaymentStatusTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := &domain.Payment{Status: tt.from}
+			err := payment.TransitionTo(tt.to)
+			if err != tt.wantErr {
+				t.Fatalf("TransitionTo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := tt.to
+			if tt.wantErr != nil {
+				want = tt.from
+			}
+			if payment.Status != want {
+				t.Errorf("TransitionTo() status = %v, want %v", payment.Status, want)
+			}
+		})
+	}
+}
+
+func TestPayment_Fail(t *testing.T) {
+	payment := &domain.Payment{Status: domain.PaymentStatusRequiresAction}
+	if err := payment.Fail("card_declined"); err != nil {
+		t.Fatalf("Fail() error = %v", err)
+	}
+	if payment.FailureReason != "card_declined" || !payment.IsFinal() {
+		t.Errorf("Fail() = %+v, want a final failed payment", payment)
+	}
+
+	captured := &domain.Payment{Status: domain.PaymentStatusCaptured}
+	if err := captured.Fail("late decline"); err != domain.ErrInvalidPaymentStatusTransition {
+		t.Errorf("Fail() on a captured payment error = %v", err)
+	}
+	if captured.FailureReason != "" {
+		t.Errorf("Fail() set reason %q on a captured payment", captured.FailureReason)
+	}
+}
+
+func TestAllowedPreviousPaymentStatuses(t *testing.T) {
+	got := domain.AllowedPreviousStatuses(domain.PaymentStatusFailed)
+	want := []domain.PaymentStatus{domain.PaymentStatusAuthorized, domain.PaymentStatusRequiresAction}
+	if !slices.Equal(got, want) {
+		t.Errorf("AllowedPreviousStatuses(failed) = %v, want %v", got, want)
+	}
+}
+
+func TestParsePaymentStatus(t *testing.T) {
+	if status, ok := domain.ParsePaymentStatus("authorized"); !ok || status != domain.PaymentStatusAuthorized {
+		t.Errorf("ParsePaymentStatus(authorized) = %v, %v", status, ok)
+	}
+	if _, ok := domain.ParsePaymentStatus("pending"); ok {
+		t.Error("ParsePaymentStatus(pending) should be rejected")
+	}
+}
@@ -0,0 +1,92 @@
+package pubsubbroker
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/pubsub/v2"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const tracerName = "github.com/jonamarkin/e-commerce-order-processing/internal/pubsubbroker"
+
+// Option configures a Publisher.
+type Option func(*Publisher)
+
+// WithCircuitBreaker makes a publisher fail fast with a
+// circuitbreaker.OpenError while breaker is open.
+func WithCircuitBreaker(breaker *circuitbreaker.Breaker) Option {
+	return func(p *Publisher) {
+		p.breaker = breaker
+	}
+}
+
+// Publisher publishes messages to the Pub/Sub topic of one topic.
+type Publisher struct {
+	pub      *pubsub.Publisher
+	topic    string
+	ordering bool
+	breaker  *circuitbreaker.Breaker
+}
+
+var _ broker.EventPublisher = (*Publisher)(nil)
+
+// Publisher returns a publisher for topic, creating the Pub/Sub topic if
+// needed.
+func (c *Client) Publisher(ctx context.Context, topic string, opts ...Option) (*Publisher, error) {
+	if _, err := c.ensureTopic(ctx, topic); err != nil {
+		return nil, err
+	}
+	pub := c.client.Publisher(topic)
+	pub.EnableMessageOrdering = c.cfg.Ordering
+	p := &Publisher{pub: pub, topic: topic, ordering: c.cfg.Ordering}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// PublishMessage publishes a key-value message to the topic and waits for
+// Pub/Sub to store it. With ordering enabled the key is the ordering key.
+func (p *Publisher) PublishMessage(ctx context.Context, key, value []byte) error {
+	attrs := propagation.MapCarrier{KeyAttribute: string(key)}
+	if id := logging.CorrelationID(ctx); id != "" {
+		attrs[logging.CorrelationIDKafkaHeader] = id
+	}
+	ctx, span := tracing.StartPublishSpan(ctx, tracerName, "gcp_pubsub", p.topic, attrs)
+	defer span.End()
+
+	if err := p.breaker.Allow(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "circuit breaker open")
+		return err
+	}
+	msg := &pubsub.Message{Data: value, Attributes: attrs}
+	if p.ordering {
+		msg.OrderingKey = string(key)
+	}
+	_, err := p.pub.Publish(ctx, msg).Get(ctx)
+	p.breaker.Done(err)
+	if err != nil {
+		if msg.OrderingKey != "" {
+			// A failed publish pauses its ordering key so later messages
+			// can't overtake it; the caller retries, so resume it.
+			p.pub.ResumePublish(msg.OrderingKey)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+		return fmt.Errorf("failed to publish message to Pub/Sub: %w", err)
+	}
+	return nil
+}
+
+// Close sends the messages still batched and stops the publisher.
+func (p *Publisher) Close() error {
+	p.pub.Stop()
+	return nil
+}
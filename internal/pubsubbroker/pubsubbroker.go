@@ -0,0 +1,169 @@
+// Package pubsubbroker carries the services' events over Google Cloud
+// Pub/Sub. Every topic is a Pub/Sub topic of the same name. Each consumer
+// group has a subscription per topic, whose dead-letter topic has a
+// subscription of its own that keeps the messages the group failed to handle:
+//
+//	orders.placed --> inventory-service-group.orders.placed
+//	inventory-service-group.orders.placed.dlq --> inventory-service-group.orders.placed.dlq
+//
+// With ordering enabled, messages carry their key as ordering key, so each
+// order's events are delivered in the order they were published.
+package pubsubbroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// KeyAttribute is the message attribute carrying the message key, an order
+// ID, which is also the ordering key when ordering is enabled.
+const KeyAttribute = "key"
+
+// Config selects the Google Cloud project and sizes the subscriptions created
+// in it. The PUBSUB_EMULATOR_HOST environment variable points the client at
+// the Pub/Sub emulator instead.
+type Config struct {
+	// ProjectID is the Google Cloud project of the topics and subscriptions.
+	ProjectID string `key:"project_id" env:"PUBSUB_PROJECT_ID"`
+	// Ordering delivers the messages of each order in the order they were
+	// published. It can't be changed on existing subscriptions.
+	Ordering bool `key:"ordering" env:"PUBSUB_ORDERING" default:"true"`
+	// AckDeadline is how long a message may be processed before Pub/Sub
+	// delivers it again. The client extends it while the handler runs.
+	AckDeadline time.Duration `key:"ack_deadline" env:"PUBSUB_ACK_DEADLINE" default:"30s"`
+	// MaxDeliveryAttempts is how many times a message that is never
+	// acknowledged, for instance because its consumer crashed, is delivered
+	// before Pub/Sub forwards it to the dead-letter topic.
+	MaxDeliveryAttempts int `key:"max_delivery_attempts" env:"PUBSUB_MAX_DELIVERY_ATTEMPTS" default:"5"`
+	// MaxOutstandingMessages is how many unacknowledged messages a consumer
+	// may hold.
+	MaxOutstandingMessages int `key:"max_outstanding_messages" env:"PUBSUB_MAX_OUTSTANDING_MESSAGES" default:"100"`
+}
+
+// Client is a Pub/Sub client shared by the publishers and subscribers created
+// from it.
+type Client struct {
+	client *pubsub.Client
+	cfg    Config
+}
+
+// Connect creates a client for the project of cfg using Application Default
+// Credentials.
+func Connect(ctx context.Context, cfg Config) (*Client, error) {
+	return connect(ctx, cfg)
+}
+
+func connect(ctx context.Context, cfg Config, opts ...option.ClientOption) (*Client, error) {
+	client, err := pubsub.NewClient(ctx, cfg.ProjectID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Pub/Sub client: %w", err)
+	}
+	return &Client{client: client, cfg: cfg}, nil
+}
+
+// Check reports whether Pub/Sub can be reached with the client's
+// credentials, for health probes.
+func (c *Client) Check(ctx context.Context) error {
+	it := c.client.TopicAdminClient.ListTopics(ctx, &pubsubpb.ListTopicsRequest{
+		Project:  "projects/" + c.cfg.ProjectID,
+		PageSize: 1,
+	})
+	if _, err := it.Next(); err != nil && !errors.Is(err, iterator.Done) {
+		return fmt.Errorf("pubsub is unreachable: %w", err)
+	}
+	return nil
+}
+
+// Close closes the client. Close publishers and subscribers first.
+func (c *Client) Close() error {
+	log.Info().Msg("Closing Pub/Sub client...")
+	return c.client.Close()
+}
+
+// ensureTopic creates the topic with ID id if it doesn't exist and returns
+// its full name.
+func (c *Client) ensureTopic(ctx context.Context, id string) (string, error) {
+	name := c.topicName(id)
+	_, err := c.client.TopicAdminClient.CreateTopic(ctx, &pubsubpb.Topic{Name: name})
+	if err != nil && status.Code(err) != codes.AlreadyExists {
+		return "", fmt.Errorf("failed to create topic %s: %w", id, err)
+	}
+	return name, nil
+}
+
+// ensureSubscription creates the subscription of groupID to topic, its
+// dead-letter topic and the subscription keeping dead-lettered messages, or
+// updates the subscription's settings, and returns the subscription's ID.
+func (c *Client) ensureSubscription(ctx context.Context, topic, groupID string) (string, error) {
+	topicName, err := c.ensureTopic(ctx, topic)
+	if err != nil {
+		return "", err
+	}
+	id := SubscriptionID(topic, groupID)
+	dlq := DeadLetterTopicID(topic, groupID)
+	dlqName, err := c.ensureTopic(ctx, dlq)
+	if err != nil {
+		return "", err
+	}
+	_, err = c.client.SubscriptionAdminClient.CreateSubscription(ctx, &pubsubpb.Subscription{
+		Name:  c.subscriptionName(dlq),
+		Topic: dlqName,
+	})
+	if err != nil && status.Code(err) != codes.AlreadyExists {
+		return "", fmt.Errorf("failed to create subscription %s: %w", dlq, err)
+	}
+
+	sub := &pubsubpb.Subscription{
+		Name:                  c.subscriptionName(id),
+		Topic:                 topicName,
+		AckDeadlineSeconds:    int32(c.cfg.AckDeadline / time.Second),
+		EnableMessageOrdering: c.cfg.Ordering,
+		DeadLetterPolicy: &pubsubpb.DeadLetterPolicy{
+			DeadLetterTopic:     dlqName,
+			MaxDeliveryAttempts: int32(c.cfg.MaxDeliveryAttempts),
+		},
+	}
+	_, err = c.client.SubscriptionAdminClient.CreateSubscription(ctx, sub)
+	if status.Code(err) == codes.AlreadyExists {
+		_, err = c.client.SubscriptionAdminClient.UpdateSubscription(ctx, &pubsubpb.UpdateSubscriptionRequest{
+			Subscription: sub,
+			UpdateMask:   &fieldmaskpb.FieldMask{Paths: []string{"ack_deadline_seconds", "dead_letter_policy"}},
+		})
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create subscription %s: %w", id, err)
+	}
+	return id, nil
+}
+
+func (c *Client) topicName(id string) string {
+	return "projects/" + c.cfg.ProjectID + "/topics/" + id
+}
+
+func (c *Client) subscriptionName(id string) string {
+	return "projects/" + c.cfg.ProjectID + "/subscriptions/" + id
+}
+
+// SubscriptionID returns the ID of the subscription through which the
+// consumer group groupID receives topic.
+func SubscriptionID(topic, groupID string) string {
+	return groupID + "." + topic
+}
+
+// DeadLetterTopicID returns the ID of the topic receiving the messages the
+// consumer group groupID failed to handle from topic. A subscription with the
+// same ID keeps them.
+func DeadLetterTopicID(topic, groupID string) string {
+	return SubscriptionID(topic, groupID) + ".dlq"
+}
@@ -0,0 +1,149 @@
+package pubsubbroker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newTestClient returns a client of an in-memory Pub/Sub server.
+func newTestClient(t *testing.T) (*Client, *pstest.Server) {
+	t.Helper()
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	cfg := Config{ProjectID: "test-project", Ordering: true, AckDeadline: 30 * time.Second, MaxDeliveryAttempts: 5, MaxOutstandingMessages: 10}
+	c, err := connect(context.Background(), cfg, option.WithGRPCConn(conn))
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c, srv
+}
+
+// consumeOne runs s until handler has been called once.
+func consumeOne(t *testing.T, s *Subscriber, handler func(ctx context.Context, key, value []byte) error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	handled := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Consume(ctx, func(ctx context.Context, key, value []byte) error {
+			defer close(handled)
+			return handler(ctx, key, value)
+		})
+	}()
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message was handled")
+	}
+	cancel()
+	<-done
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, "inventory-service-group.orders.placed", SubscriptionID("orders.placed", "inventory-service-group"))
+	assert.Equal(t, "inventory-service-group.orders.placed.dlq", DeadLetterTopicID("orders.placed", "inventory-service-group"))
+}
+
+func TestClient_Subscriber_CreatesSubscriptions(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := c.Subscriber(ctx, "orders.placed", "inventory-service-group")
+	require.NoError(t, err)
+	c.cfg.AckDeadline = time.Minute
+	_, err = c.Subscriber(ctx, "orders.placed", "inventory-service-group")
+	require.NoError(t, err, "existing subscriptions should be updated")
+
+	sub, err := c.client.SubscriptionAdminClient.GetSubscription(ctx, &pubsubpb.GetSubscriptionRequest{
+		Subscription: "projects/test-project/subscriptions/inventory-service-group.orders.placed",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "projects/test-project/topics/orders.placed", sub.Topic)
+	assert.True(t, sub.EnableMessageOrdering)
+	assert.EqualValues(t, 60, sub.AckDeadlineSeconds)
+	assert.Equal(t, "projects/test-project/topics/inventory-service-group.orders.placed.dlq", sub.DeadLetterPolicy.DeadLetterTopic)
+	assert.EqualValues(t, 5, sub.DeadLetterPolicy.MaxDeliveryAttempts)
+
+	dlq, err := c.client.SubscriptionAdminClient.GetSubscription(ctx, &pubsubpb.GetSubscriptionRequest{
+		Subscription: "projects/test-project/subscriptions/inventory-service-group.orders.placed.dlq",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, sub.DeadLetterPolicy.DeadLetterTopic, dlq.Topic)
+}
+
+func TestPublishAndConsume(t *testing.T) {
+	c, srv := newTestClient(t)
+	ctx := context.Background()
+	s, err := c.Subscriber(ctx, "orders.placed", "inventory-service-group")
+	require.NoError(t, err)
+	defer s.Close()
+	p, err := c.Publisher(ctx, "orders.placed")
+	require.NoError(t, err)
+	defer p.Close()
+
+	require.NoError(t, p.PublishMessage(logging.WithCorrelationID(ctx, "corr-1", nil), []byte("order-1"), []byte(`{"order_id":"order-1"}`)))
+
+	msgs := srv.Messages()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "order-1", msgs[0].OrderingKey)
+	assert.Equal(t, "order-1", msgs[0].Attributes[KeyAttribute])
+	assert.Equal(t, "corr-1", msgs[0].Attributes[logging.CorrelationIDKafkaHeader])
+
+	var gotKey, gotValue, gotCorrelationID string
+	consumeOne(t, s, func(ctx context.Context, key, value []byte) error {
+		gotKey, gotValue, gotCorrelationID = string(key), string(value), logging.CorrelationID(ctx)
+		return nil
+	})
+	assert.Equal(t, "order-1", gotKey)
+	assert.Equal(t, `{"order_id":"order-1"}`, gotValue)
+	assert.Equal(t, "corr-1", gotCorrelationID)
+	assert.Equal(t, 1, srv.Message(msgs[0].ID).Acks)
+}
+
+func TestConsume_DeadLettersFailedMessages(t *testing.T) {
+	c, srv := newTestClient(t)
+	ctx := context.Background()
+	s, err := c.Subscriber(ctx, "orders.placed", "inventory-service-group")
+	require.NoError(t, err)
+	defer s.Close()
+	p, err := c.Publisher(ctx, "orders.placed")
+	require.NoError(t, err)
+	defer p.Close()
+	require.NoError(t, p.PublishMessage(ctx, []byte("order-1"), []byte(`{"order_id":"order-1"}`)))
+
+	consumeOne(t, s, func(context.Context, []byte, []byte) error {
+		return errors.New("insufficient stock")
+	})
+
+	var deadLettered []*pstest.Message
+	for _, msg := range srv.Messages() {
+		switch msg.Topic {
+		case "projects/test-project/topics/orders.placed":
+			assert.Equal(t, 1, msg.Acks, "the failed message should be acknowledged")
+		case "projects/test-project/topics/inventory-service-group.orders.placed.dlq":
+			deadLettered = append(deadLettered, msg)
+		}
+	}
+	require.Len(t, deadLettered, 1)
+	msg := deadLettered[0]
+	assert.Equal(t, `{"order_id":"order-1"}`, string(msg.Data))
+	assert.Equal(t, "order-1", msg.Attributes[KeyAttribute])
+	assert.Equal(t, "orders.placed", msg.Attributes[events.HeaderOriginalTopic])
+	assert.Equal(t, "insufficient stock", msg.Attributes[events.HeaderError])
+}
@@ -0,0 +1,134 @@
+package pubsubbroker
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Subscriber consumes the subscription of one consumer group to one topic.
+type Subscriber struct {
+	sub        *pubsub.Subscriber
+	deadLetter *pubsub.Publisher
+	topic      string
+	group      string
+}
+
+var _ broker.EventSubscriber = (*Subscriber)(nil)
+
+// Subscriber returns a subscriber to topic for the consumer group groupID,
+// creating its subscription and dead-letter topic if needed. The
+// subscription only receives the messages published after it was created.
+func (c *Client) Subscriber(ctx context.Context, topic, groupID string) (*Subscriber, error) {
+	id, err := c.ensureSubscription(ctx, topic, groupID)
+	if err != nil {
+		return nil, err
+	}
+	sub := c.client.Subscriber(id)
+	sub.ReceiveSettings.MaxOutstandingMessages = c.cfg.MaxOutstandingMessages
+	return &Subscriber{
+		sub:        sub,
+		deadLetter: c.client.Publisher(DeadLetterTopicID(topic, groupID)),
+		topic:      topic,
+		group:      groupID,
+	}, nil
+}
+
+// Consume passes messages to handler until ctx is cancelled. A message is
+// acknowledged once handled, or published to the dead-letter topic if
+// handler fails. Cancelling ctx stops receiving but lets the messages in
+// flight finish and be acknowledged, so Consume returns only once that work
+// is done.
+func (s *Subscriber) Consume(ctx context.Context, handler broker.MessageHandler) {
+	processCtx := context.WithoutCancel(ctx)
+	log.Info().Str("topic", s.topic).Str("subscription", s.sub.ID()).Msg("Starting Pub/Sub consumer")
+	for {
+		err := s.sub.Receive(ctx, func(_ context.Context, msg *pubsub.Message) {
+			s.process(processCtx, msg, handler)
+		})
+		if ctx.Err() != nil {
+			log.Info().Str("topic", s.topic).Msg("Pub/Sub consumer context cancelled. Shutting down.")
+			return
+		}
+		log.Error().Err(err).Str("subscription", s.sub.ID()).Msg("Pub/Sub consumer stopped, restarting")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// process handles a single message and settles it.
+func (s *Subscriber) process(ctx context.Context, msg *pubsub.Message, handler broker.MessageHandler) {
+	key := msg.Attributes[KeyAttribute]
+	msgCtx, span := tracing.StartProcessSpan(s.messageContext(ctx, msg), tracerName, "gcp_pubsub", s.topic,
+		propagation.MapCarrier(msg.Attributes),
+		attribute.String("messaging.message.id", msg.ID),
+		attribute.String("messaging.consumer.group.name", s.group))
+	defer span.End()
+
+	if err := handler(msgCtx, []byte(key), msg.Data); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "handler failed")
+		log.Ctx(msgCtx).Error().Err(err).Msg("Failed to handle message, dead-lettering it")
+		if err := s.publishDeadLetter(msgCtx, msg, err); err != nil {
+			// Nacked, the message is delivered again, and Pub/Sub
+			// dead-letters it after MaxDeliveryAttempts attempts.
+			log.Ctx(msgCtx).Error().Err(err).Msg("Error dead-lettering message")
+			msg.Nack()
+			return
+		}
+	}
+	msg.Ack()
+}
+
+// publishDeadLetter publishes a copy of msg to the dead-letter topic with the
+// attributes describing the failure that Kafka's dead-letter topics carry as
+// headers.
+func (s *Subscriber) publishDeadLetter(ctx context.Context, msg *pubsub.Message, cause error) error {
+	attrs := maps.Clone(msg.Attributes)
+	if attrs == nil {
+		attrs = map[string]string{}
+	}
+	attrs[events.HeaderOriginalTopic] = s.topic
+	attrs[events.HeaderError] = cause.Error()
+	attrs[events.HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	if _, err := s.deadLetter.Publish(ctx, &pubsub.Message{Data: msg.Data, Attributes: attrs}).Get(ctx); err != nil {
+		return fmt.Errorf("failed to publish message to %s: %w", s.deadLetter.ID(), err)
+	}
+	return nil
+}
+
+// messageContext returns a context whose logger carries the message's
+// correlation ID (generating one if the publisher didn't set it), topic,
+// message ID and key.
+func (s *Subscriber) messageContext(ctx context.Context, msg *pubsub.Message) context.Context {
+	id := msg.Attributes[logging.CorrelationIDKafkaHeader]
+	if id == "" {
+		id = logging.NewCorrelationID()
+	}
+	return logging.WithCorrelationID(ctx, id, map[string]any{
+		"topic":      s.topic,
+		"message_id": msg.ID,
+		"order_id":   msg.Attributes[KeyAttribute],
+	})
+}
+
+// Close stops the dead-letter publisher. Consume stops when its context is
+// cancelled and the client belongs to the Client.
+func (s *Subscriber) Close() error {
+	s.deadLetter.Stop()
+	return nil
+}
@@ -0,0 +1,32 @@
+// Package redisclient connects the services to Redis, which holds state
+// shared by every instance of a service, such as per-customer quota counters.
+package redisclient
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Config locates the Redis server.
+type Config struct {
+	Addr     string `key:"addr" env:"REDIS_ADDR" default:"localhost:6379"`
+	Password string `key:"password" env:"REDIS_PASSWORD" secret:"true"`
+	DB       int    `key:"db" env:"REDIS_DB" default:"0"`
+	// Timeout bounds connecting and every command, so that a slow Redis
+	// cannot hold up the requests that depend on it.
+	Timeout time.Duration `key:"timeout" env:"REDIS_TIMEOUT" default:"200ms"`
+}
+
+// New creates a client for the server described by cfg. Connections are
+// opened lazily, so New does not fail when Redis is unreachable.
+func New(cfg Config) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:         cfg.Addr,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  cfg.Timeout,
+		ReadTimeout:  cfg.Timeout,
+		WriteTimeout: cfg.Timeout,
+	})
+}
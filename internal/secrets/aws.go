@@ -0,0 +1,65 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// AWSProvider reads secrets from AWS Secrets Manager. Secrets stored as a JSON
+// object expose each top-level field as a key; any other secret string is
+// available under the empty key, i.e. a reference without a #key suffix.
+type AWSProvider struct {
+	client *secretsmanager.Client
+}
+
+// NewAWSProvider creates an AWSProvider using the default AWS credential chain
+// (environment, shared config, instance or task role). An empty region falls
+// back to AWS_REGION.
+func NewAWSProvider(ctx context.Context, region string) (*AWSProvider, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		opts = append(opts, awsconfig.WithRegion(region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return &AWSProvider{client: secretsmanager.NewFromConfig(cfg)}, nil
+}
+
+// GetSecret returns the current version of the secret named path.
+func (p *AWSProvider) GetSecret(ctx context.Context, path string) (map[string]string, error) {
+	out, err := p.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(path),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.SecretString == nil {
+		return nil, fmt.Errorf("secret %s has no string value", path)
+	}
+	return parseSecretString(*out.SecretString), nil
+}
+
+// parseSecretString splits a JSON object secret into its fields. The raw
+// string is always available under the empty key.
+func parseSecretString(s string) map[string]string {
+	fields := map[string]string{"": s}
+	var object map[string]any
+	if err := json.Unmarshal([]byte(s), &object); err != nil {
+		return fields
+	}
+	for k, v := range object {
+		if str, ok := v.(string); ok {
+			fields[k] = str
+			continue
+		}
+		fields[k] = fmt.Sprint(v)
+	}
+	return fields
+}
@@ -0,0 +1,32 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+)
+
+// NewResolverFromEnv registers the providers configured in the environment.
+// These settings are read from the environment only, since they are needed
+// before the rest of the configuration can be resolved:
+//
+//	VAULT_ADDR, VAULT_TOKEN, VAULT_NAMESPACE  enable vault:// references
+//	AWS_SECRETS_MANAGER_ENABLED, AWS_REGION   enable awssm:// references
+func NewResolverFromEnv(ctx context.Context) (*Resolver, error) {
+	r := NewResolver()
+
+	if addr := os.Getenv("VAULT_ADDR"); addr != "" {
+		r.Register("vault", NewVaultProvider(addr, os.Getenv("VAULT_TOKEN"), os.Getenv("VAULT_NAMESPACE"), 10*time.Second))
+	}
+
+	if enabled, _ := strconv.ParseBool(os.Getenv("AWS_SECRETS_MANAGER_ENABLED")); enabled {
+		p, err := NewAWSProvider(ctx, os.Getenv("AWS_REGION"))
+		if err != nil {
+			return nil, err
+		}
+		r.Register("awssm", p)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,89 @@
+// Package secrets resolves configuration values that reference an external
+// secret store instead of holding the secret in plaintext.
+//
+// A reference has the form scheme://path#key, for example
+//
+//	vault://secret/orderservice#database_url
+//	awssm://prod/orderservice#database_url
+//
+// The scheme selects a Provider, the path names the secret and the key picks
+// one field of it. Values that don't use a registered scheme are returned
+// unchanged, so plaintext settings keep working.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Provider fetches a secret and returns its fields.
+type Provider interface {
+	GetSecret(ctx context.Context, path string) (map[string]string, error)
+}
+
+// Resolver maps reference schemes to providers and caches fetched secrets, so
+// several fields of the same secret cost a single lookup.
+type Resolver struct {
+	providers map[string]Provider
+
+	mu    sync.Mutex
+	cache map[string]map[string]string
+}
+
+// NewResolver creates an empty Resolver; register providers with Register.
+func NewResolver() *Resolver {
+	return &Resolver{
+		providers: map[string]Provider{},
+		cache:     map[string]map[string]string{},
+	}
+}
+
+// Register makes references with scheme resolve through p.
+func (r *Resolver) Register(scheme string, p Provider) {
+	r.providers[scheme] = p
+}
+
+// Resolve returns the secret value value refers to, or value itself when it is
+// not a reference to a registered provider.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+	provider, ok := r.providers[scheme]
+	if !ok {
+		return value, nil
+	}
+
+	path, key, _ := strings.Cut(rest, "#")
+	if path == "" {
+		return "", fmt.Errorf("secret reference %q has no path", value)
+	}
+
+	fields, err := r.fetch(ctx, scheme, provider, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch secret %s://%s: %w", scheme, path, err)
+	}
+	secret, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s://%s has no key %q", scheme, path, key)
+	}
+	return secret, nil
+}
+
+func (r *Resolver) fetch(ctx context.Context, scheme string, p Provider, path string) (map[string]string, error) {
+	cacheKey := scheme + "://" + path
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if fields, ok := r.cache[cacheKey]; ok {
+		return fields, nil
+	}
+	fields, err := p.GetSecret(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	r.cache[cacheKey] = fields
+	return fields, nil
+}
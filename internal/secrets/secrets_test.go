@@ -0,0 +1,84 @@
+package secrets_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	secrets map[string]map[string]string
+	calls   int
+}
+
+func (p *fakeProvider) GetSecret(_ context.Context, path string) (map[string]string, error) {
+	p.calls++
+	fields, ok := p.secrets[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return fields, nil
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	provider := &fakeProvider{secrets: map[string]map[string]string{
+		"prod/orders": {"database_url": "postgres://db", "admin_token": "s3cret"},
+	}}
+	r := secrets.NewResolver()
+	r.Register("fake", provider)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "plaintext", value: "postgres://plain", want: "postgres://plain"},
+		{name: "unregistered scheme", value: "vault://secret/orders#x", want: "vault://secret/orders#x"},
+		{name: "reference", value: "fake://prod/orders#database_url", want: "postgres://db"},
+		{name: "missing key", value: "fake://prod/orders#nope", wantErr: `has no key "nope"`},
+		{name: "missing secret", value: "fake://prod/other#x", wantErr: "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Resolve(ctx, tt.value)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := r.Resolve(ctx, "fake://prod/orders#admin_token")
+	require.NoError(t, err)
+	assert.Equal(t, 2, provider.calls, "fields of an already fetched secret come from the cache")
+}
+
+func TestVaultProvider_GetSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "/v1/secret/data/orderservice", r.URL.Path)
+		_, _ = w.Write([]byte(`{"data":{"data":{"database_url":"postgres://db","port":5432}}}`))
+	}))
+	defer server.Close()
+
+	fields, err := secrets.NewVaultProvider(server.URL, "root", "", time.Second).GetSecret(context.Background(), "secret/orderservice")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"database_url": "postgres://db", "port": "5432"}, fields)
+
+	_, err = secrets.NewVaultProvider(server.URL, "wrong", "", time.Second).GetSecret(context.Background(), "secret/orderservice")
+	assert.ErrorContains(t, err, "unexpected status 403")
+}
@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultProvider reads secrets from a HashiCorp Vault KV version 2 engine. The
+// first segment of a secret path is the engine's mount, so
+// "secret/orderservice" reads /v1/secret/data/orderservice.
+type VaultProvider struct {
+	addr       string
+	token      string
+	namespace  string
+	httpClient *http.Client
+}
+
+// NewVaultProvider creates a VaultProvider for the server at addr,
+// authenticating with token. namespace is only needed for Vault Enterprise.
+func NewVaultProvider(addr, token, namespace string, timeout time.Duration) *VaultProvider {
+	return &VaultProvider{
+		addr:       strings.TrimRight(addr, "/"),
+		token:      token,
+		namespace:  namespace,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+type vaultKVResponse struct {
+	Data struct {
+		Data map[string]any `json:"data"`
+	} `json:"data"`
+}
+
+// GetSecret returns the latest version of the secret at path.
+func (p *VaultProvider) GetSecret(ctx context.Context, path string) (map[string]string, error) {
+	mount, name, ok := strings.Cut(strings.Trim(path, "/"), "/")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("vault secret path %q must be <mount>/<name>", path)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/data/%s", p.addr, mount, name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned unexpected status %d", resp.StatusCode)
+	}
+
+	var body vaultKVResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	fields := make(map[string]string, len(body.Data.Data))
+	for k, v := range body.Data.Data {
+		fields[k] = fmt.Sprint(v)
+	}
+	return fields, nil
+}
@@ -0,0 +1,128 @@
+package snssqs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/circuitbreaker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const tracerName = "github.com/jonamarkin/e-commerce-order-processing/internal/snssqs"
+
+// Option configures a Publisher.
+type Option func(*Publisher)
+
+// WithCircuitBreaker makes a publisher fail fast with a
+// circuitbreaker.OpenError while breaker is open.
+func WithCircuitBreaker(breaker *circuitbreaker.Breaker) Option {
+	return func(p *Publisher) {
+		p.breaker = breaker
+	}
+}
+
+// Publisher publishes messages to the SNS topic of one topic.
+type Publisher struct {
+	sns      snsAPI
+	topic    string
+	topicARN string
+	fifo     bool
+	breaker  *circuitbreaker.Breaker
+}
+
+var _ broker.EventPublisher = (*Publisher)(nil)
+
+// Publisher returns a publisher for topic, creating its SNS topic if needed.
+func (c *Client) Publisher(ctx context.Context, topic string, opts ...Option) (*Publisher, error) {
+	arn, err := c.topicARN(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	p := &Publisher{sns: c.sns, topic: topic, topicARN: arn, fifo: c.cfg.FIFO}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// PublishMessage publishes a key-value message to the topic. On FIFO topics
+// the key is the message group, so messages with the same key are delivered
+// in order, and a message identical to one published in the last five
+// minutes, such as an outbox retry, is dropped as a duplicate.
+func (p *Publisher) PublishMessage(ctx context.Context, key, value []byte) error {
+	headers := propagation.MapCarrier{KeyAttribute: string(key)}
+	if id := logging.CorrelationID(ctx); id != "" {
+		headers[logging.CorrelationIDKafkaHeader] = id
+	}
+	ctx, span := tracing.StartPublishSpan(ctx, tracerName, "aws_sns", p.topic, headers,
+		attribute.String("messaging.destination.name", p.topicARN))
+	defer span.End()
+
+	if err := p.breaker.Allow(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "circuit breaker open")
+		return err
+	}
+	in := &sns.PublishInput{
+		TopicArn:          aws.String(p.topicARN),
+		Message:           aws.String(string(value)),
+		MessageAttributes: snsAttributes(headers),
+	}
+	if p.fifo {
+		in.MessageGroupId = aws.String(messageGroup(key, p.topic))
+		in.MessageDeduplicationId = aws.String(deduplicationID(key, value))
+	}
+	_, err := p.sns.Publish(ctx, in)
+	p.breaker.Done(err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+		return fmt.Errorf("failed to publish message to SNS: %w", err)
+	}
+	return nil
+}
+
+// Close does nothing: the AWS clients belong to the Client.
+func (p *Publisher) Close() error {
+	return nil
+}
+
+// messageGroup returns the FIFO message group of a message: its key, or the
+// topic for the rare message without one, since the group is mandatory.
+func messageGroup(key []byte, topic string) string {
+	if len(key) == 0 {
+		return topic
+	}
+	return string(key)
+}
+
+// deduplicationID identifies a message by its content.
+func deduplicationID(key, value []byte) string {
+	h := sha256.New()
+	h.Write(key)
+	h.Write([]byte{0})
+	h.Write(value)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// snsAttributes converts headers to SNS string message attributes.
+func snsAttributes(headers map[string]string) map[string]snstypes.MessageAttributeValue {
+	attrs := make(map[string]snstypes.MessageAttributeValue, len(headers))
+	for name, value := range headers {
+		if value == "" {
+			continue // SNS rejects empty attribute values
+		}
+		attrs[name] = snstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+	}
+	return attrs
+}
@@ -0,0 +1,278 @@
+// Package snssqs carries the services' events over Amazon SNS and SQS, for
+// teams deploying to AWS without managed Kafka. Every topic is an SNS topic
+// that publishers send to. Each consumer group has an SQS queue per topic,
+// subscribed to the SNS topic with raw message delivery, and a dead-letter
+// queue for the messages it fails to handle:
+//
+//	orders-placed.fifo --> inventory-service-group-orders-placed.fifo
+//	                       inventory-service-group-orders-placed-dlq.fifo
+//
+// With FIFO enabled, topics and queues are FIFO and messages are grouped by
+// key, so each order's events are delivered in the order they were published.
+package snssqs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// KeyAttribute is the message attribute carrying the message key, an order
+// ID, since SNS and SQS messages have none of their own.
+const KeyAttribute = "key"
+
+// fifoSuffix ends the names of FIFO topics and queues.
+const fifoSuffix = ".fifo"
+
+// Config selects the AWS region and account resources the services use.
+type Config struct {
+	// Region is the AWS region of the topics and queues. Empty falls back to
+	// AWS_REGION and the shared AWS configuration.
+	Region string `key:"region" env:"SNS_SQS_REGION"`
+	// Endpoint overrides the SNS and SQS endpoints, e.g. to use LocalStack.
+	Endpoint string `key:"endpoint" env:"SNS_SQS_ENDPOINT"`
+	// NamePrefix is prepended to every topic and queue name, so several
+	// environments can share an account.
+	NamePrefix string `key:"name_prefix" env:"SNS_SQS_NAME_PREFIX"`
+	// FIFO creates FIFO topics and queues that keep each order's events in
+	// sequence. It can't be changed once the topics exist.
+	FIFO bool `key:"fifo" env:"SNS_SQS_FIFO" default:"true"`
+	// VisibilityTimeout is how long a received message is hidden from the
+	// group's other consumers while it is handled.
+	VisibilityTimeout time.Duration `key:"visibility_timeout" env:"SNS_SQS_VISIBILITY_TIMEOUT" default:"30s"`
+	// WaitTime is how long a receive waits for messages to arrive.
+	WaitTime time.Duration `key:"wait_time" env:"SNS_SQS_WAIT_TIME" default:"20s"`
+	// MaxMessages is how many messages a receive returns at most.
+	MaxMessages int `key:"max_messages" env:"SNS_SQS_MAX_MESSAGES" default:"10"`
+	// MaxReceiveCount is how many times a message that is never deleted, for
+	// instance because its consumer crashed, is received before SQS moves it
+	// to the dead-letter queue.
+	MaxReceiveCount int `key:"max_receive_count" env:"SNS_SQS_MAX_RECEIVE_COUNT" default:"5"`
+}
+
+// snsAPI is the part of *sns.Client the package uses.
+type snsAPI interface {
+	CreateTopic(ctx context.Context, in *sns.CreateTopicInput, opts ...func(*sns.Options)) (*sns.CreateTopicOutput, error)
+	Publish(ctx context.Context, in *sns.PublishInput, opts ...func(*sns.Options)) (*sns.PublishOutput, error)
+	Subscribe(ctx context.Context, in *sns.SubscribeInput, opts ...func(*sns.Options)) (*sns.SubscribeOutput, error)
+}
+
+// sqsAPI is the part of *sqs.Client the package uses.
+type sqsAPI interface {
+	CreateQueue(ctx context.Context, in *sqs.CreateQueueInput, opts ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error)
+	GetQueueAttributes(ctx context.Context, in *sqs.GetQueueAttributesInput, opts ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error)
+	SetQueueAttributes(ctx context.Context, in *sqs.SetQueueAttributesInput, opts ...func(*sqs.Options)) (*sqs.SetQueueAttributesOutput, error)
+	ListQueues(ctx context.Context, in *sqs.ListQueuesInput, opts ...func(*sqs.Options)) (*sqs.ListQueuesOutput, error)
+	ReceiveMessage(ctx context.Context, in *sqs.ReceiveMessageInput, opts ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, in *sqs.DeleteMessageInput, opts ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	SendMessage(ctx context.Context, in *sqs.SendMessageInput, opts ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// Client holds the SNS and SQS clients shared by the publishers and
+// subscribers created from it.
+type Client struct {
+	cfg Config
+	sns snsAPI
+	sqs sqsAPI
+
+	mu        sync.Mutex
+	topicARNs map[string]string
+}
+
+// Connect creates clients for the region of cfg using the default AWS
+// credential chain (environment, shared config, instance or task role).
+func Connect(ctx context.Context, cfg Config) (*Client, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.Region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	snsClient := sns.NewFromConfig(awsCfg, func(o *sns.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+	sqsClient := sqs.NewFromConfig(awsCfg, func(o *sqs.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+	return newClient(cfg, snsClient, sqsClient), nil
+}
+
+func newClient(cfg Config, snsClient snsAPI, sqsClient sqsAPI) *Client {
+	return &Client{cfg: cfg, sns: snsClient, sqs: sqsClient, topicARNs: map[string]string{}}
+}
+
+// Check reports whether SQS can be reached with the client's credentials,
+// for health probes.
+func (c *Client) Check(ctx context.Context) error {
+	_, err := c.sqs.ListQueues(ctx, &sqs.ListQueuesInput{
+		QueueNamePrefix: aws.String(c.cfg.NamePrefix),
+		MaxResults:      aws.Int32(1),
+	})
+	if err != nil {
+		return fmt.Errorf("sqs is unreachable: %w", err)
+	}
+	return nil
+}
+
+// Close does nothing: the AWS clients hold no connections that need closing.
+func (c *Client) Close() error {
+	return nil
+}
+
+// topicARN creates the SNS topic of topic if needed and returns its ARN.
+// CreateTopic returns the existing topic when it already exists.
+func (c *Client) topicARN(ctx context.Context, topic string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if arn, ok := c.topicARNs[topic]; ok {
+		return arn, nil
+	}
+	name := c.TopicName(topic)
+	in := &sns.CreateTopicInput{Name: aws.String(name)}
+	if c.cfg.FIFO {
+		in.Attributes = map[string]string{"FifoTopic": "true"}
+	}
+	out, err := c.sns.CreateTopic(ctx, in)
+	if err != nil {
+		return "", fmt.Errorf("failed to create SNS topic %s: %w", name, err)
+	}
+	c.topicARNs[topic] = aws.ToString(out.TopicArn)
+	return c.topicARNs[topic], nil
+}
+
+// queue is an SQS queue's URL and ARN.
+type queue struct {
+	url string
+	arn string
+}
+
+// ensureQueue creates the queue named name if needed and returns it. Only the
+// FIFO attribute is set at creation, since CreateQueue fails for an existing
+// queue whose attributes differ; the others are set afterwards.
+func (c *Client) ensureQueue(ctx context.Context, name string) (queue, error) {
+	in := &sqs.CreateQueueInput{QueueName: aws.String(name)}
+	if c.cfg.FIFO {
+		in.Attributes = map[string]string{string(sqstypes.QueueAttributeNameFifoQueue): "true"}
+	}
+	created, err := c.sqs.CreateQueue(ctx, in)
+	if err != nil {
+		return queue{}, fmt.Errorf("failed to create SQS queue %s: %w", name, err)
+	}
+	got, err := c.sqs.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       created.QueueUrl,
+		AttributeNames: []sqstypes.QueueAttributeName{sqstypes.QueueAttributeNameQueueArn},
+	})
+	if err != nil {
+		return queue{}, fmt.Errorf("failed to read SQS queue %s: %w", name, err)
+	}
+	q := queue{url: aws.ToString(created.QueueUrl), arn: got.Attributes[string(sqstypes.QueueAttributeNameQueueArn)]}
+	if q.arn == "" {
+		return queue{}, errors.New("SQS returned no ARN for queue " + name)
+	}
+	return q, nil
+}
+
+// subscriptionQueues creates the queue of groupID for topic and its
+// dead-letter queue, and subscribes the queue to the topic with raw message
+// delivery, so message bodies and attributes arrive as published.
+func (c *Client) subscriptionQueues(ctx context.Context, topic, groupID string) (main, dlq queue, err error) {
+	topicARN, err := c.topicARN(ctx, topic)
+	if err != nil {
+		return queue{}, queue{}, err
+	}
+	name := c.QueueName(topic, groupID)
+	if dlq, err = c.ensureQueue(ctx, c.resourceName(groupID+"-"+topic+"-dlq")); err != nil {
+		return queue{}, queue{}, err
+	}
+
+	if main, err = c.ensureQueue(ctx, name); err != nil {
+		return queue{}, queue{}, err
+	}
+	redrive, _ := json.Marshal(map[string]any{
+		"deadLetterTargetArn": dlq.arn,
+		"maxReceiveCount":     c.cfg.MaxReceiveCount,
+	})
+	if _, err := c.sqs.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl: aws.String(main.url),
+		Attributes: map[string]string{
+			string(sqstypes.QueueAttributeNameVisibilityTimeout):             seconds(c.cfg.VisibilityTimeout),
+			string(sqstypes.QueueAttributeNameReceiveMessageWaitTimeSeconds): seconds(c.cfg.WaitTime),
+			string(sqstypes.QueueAttributeNameRedrivePolicy):                 string(redrive),
+			string(sqstypes.QueueAttributeNamePolicy):                        queuePolicy(main.arn, topicARN),
+		},
+	}); err != nil {
+		return queue{}, queue{}, fmt.Errorf("failed to configure SQS queue %s: %w", name, err)
+	}
+
+	if _, err := c.sns.Subscribe(ctx, &sns.SubscribeInput{
+		TopicArn:   aws.String(topicARN),
+		Protocol:   aws.String("sqs"),
+		Endpoint:   aws.String(main.arn),
+		Attributes: map[string]string{"RawMessageDelivery": "true"},
+	}); err != nil {
+		return queue{}, queue{}, fmt.Errorf("failed to subscribe SQS queue %s to SNS topic %s: %w", name, topicARN, err)
+	}
+	return main, dlq, nil
+}
+
+// queuePolicy allows the SNS topic topicARN to send messages to the queue
+// queueARN.
+func queuePolicy(queueARN, topicARN string) string {
+	policy, _ := json.Marshal(map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []map[string]any{{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": "sns.amazonaws.com"},
+			"Action":    "sqs:SendMessage",
+			"Resource":  queueARN,
+			"Condition": map[string]any{
+				"ArnEquals": map[string]string{"aws:SourceArn": topicARN},
+			},
+		}},
+	})
+	return string(policy)
+}
+
+// seconds formats d as a whole number of seconds, as SQS attributes expect.
+func seconds(d time.Duration) string {
+	return fmt.Sprint(int(d / time.Second))
+}
+
+// TopicName returns the name of the SNS topic carrying topic: the name
+// prefix followed by the topic with dots, which SNS names can't contain,
+// replaced by hyphens. orders.placed is carried by orders-placed.fifo.
+func (c *Client) TopicName(topic string) string {
+	return c.resourceName(topic)
+}
+
+// QueueName returns the name of the SQS queue through which the consumer
+// group groupID receives topic, e.g.
+// inventory-service-group-orders-placed.fifo. Its dead-letter queue adds a
+// -dlq suffix to the name.
+func (c *Client) QueueName(topic, groupID string) string {
+	return c.resourceName(groupID + "-" + topic)
+}
+
+func (c *Client) resourceName(name string) string {
+	name = c.cfg.NamePrefix + strings.ReplaceAll(name, ".", "-")
+	if c.cfg.FIFO {
+		name += fifoSuffix
+	}
+	return name
+}
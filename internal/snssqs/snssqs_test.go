@@ -0,0 +1,251 @@
+package snssqs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// fakeSNS records the requests made to it.
+type fakeSNS struct {
+	topics     []*sns.CreateTopicInput
+	published  []*sns.PublishInput
+	subscribed []*sns.SubscribeInput
+	err        error
+}
+
+func (f *fakeSNS) CreateTopic(_ context.Context, in *sns.CreateTopicInput, _ ...func(*sns.Options)) (*sns.CreateTopicOutput, error) {
+	f.topics = append(f.topics, in)
+	return &sns.CreateTopicOutput{TopicArn: aws.String("arn:aws:sns:eu-west-1:123456789012:" + *in.Name)}, nil
+}
+
+func (f *fakeSNS) Publish(_ context.Context, in *sns.PublishInput, _ ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.published = append(f.published, in)
+	return &sns.PublishOutput{}, f.err
+}
+
+func (f *fakeSNS) Subscribe(_ context.Context, in *sns.SubscribeInput, _ ...func(*sns.Options)) (*sns.SubscribeOutput, error) {
+	f.subscribed = append(f.subscribed, in)
+	return &sns.SubscribeOutput{}, nil
+}
+
+// fakeSQS records the requests made to it. Queue URLs are the queue names.
+type fakeSQS struct {
+	attributes map[string]map[string]string
+	deleted    []string
+	sent       []*sqs.SendMessageInput
+	sendErr    error
+}
+
+func (f *fakeSQS) CreateQueue(_ context.Context, in *sqs.CreateQueueInput, _ ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	f.attributes[*in.QueueName] = in.Attributes
+	return &sqs.CreateQueueOutput{QueueUrl: in.QueueName}, nil
+}
+
+func (f *fakeSQS) GetQueueAttributes(_ context.Context, in *sqs.GetQueueAttributesInput, _ ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{"QueueArn": "arn:aws:sqs:eu-west-1:123456789012:" + *in.QueueUrl}}, nil
+}
+
+func (f *fakeSQS) SetQueueAttributes(_ context.Context, in *sqs.SetQueueAttributesInput, _ ...func(*sqs.Options)) (*sqs.SetQueueAttributesOutput, error) {
+	for name, value := range in.Attributes {
+		f.attributes[*in.QueueUrl][name] = value
+	}
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func (f *fakeSQS) ListQueues(context.Context, *sqs.ListQueuesInput, ...func(*sqs.Options)) (*sqs.ListQueuesOutput, error) {
+	return &sqs.ListQueuesOutput{}, nil
+}
+
+func (f *fakeSQS) ReceiveMessage(context.Context, *sqs.ReceiveMessageInput, ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	return &sqs.ReceiveMessageOutput{}, nil
+}
+
+func (f *fakeSQS) DeleteMessage(_ context.Context, in *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	f.deleted = append(f.deleted, *in.ReceiptHandle)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeSQS) SendMessage(_ context.Context, in *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.sent = append(f.sent, in)
+	return &sqs.SendMessageOutput{}, f.sendErr
+}
+
+func testClient(fifo bool) (*Client, *fakeSNS, *fakeSQS) {
+	snsClient, sqsClient := &fakeSNS{}, &fakeSQS{attributes: map[string]map[string]string{}}
+	cfg := Config{NamePrefix: "prod-", FIFO: fifo, VisibilityTimeout: 30 * time.Second, WaitTime: 20 * time.Second, MaxMessages: 10, MaxReceiveCount: 5}
+	return newClient(cfg, snsClient, sqsClient), snsClient, sqsClient
+}
+
+func TestNames(t *testing.T) {
+	fifo, _, _ := testClient(true)
+	assert.Equal(t, "prod-orders-placed.fifo", fifo.TopicName("orders.placed"))
+	assert.Equal(t, "prod-inventory-service-group-orders-placed.fifo", fifo.QueueName("orders.placed", "inventory-service-group"))
+
+	standard, _, _ := testClient(false)
+	assert.Equal(t, "prod-orders-placed", standard.TopicName("orders.placed"))
+	assert.Equal(t, "prod-inventory-service-group-orders-placed", standard.QueueName("orders.placed", "inventory-service-group"))
+}
+
+func TestPublisher_PublishMessage(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Run("FIFO messages are grouped by key", func(t *testing.T) {
+		c, snsClient, _ := testClient(true)
+		p, err := c.Publisher(context.Background(), "orders.placed")
+		require.NoError(t, err)
+		ctx := logging.WithCorrelationID(context.Background(), "corr-1", nil)
+
+		require.NoError(t, p.PublishMessage(ctx, []byte("order-1"), []byte(`{"order_id":"order-1"}`)))
+		require.NoError(t, p.PublishMessage(ctx, []byte("order-1"), []byte(`{"order_id":"order-1"}`)))
+
+		require.Len(t, snsClient.topics, 1, "the topic should be created once")
+		assert.Equal(t, map[string]string{"FifoTopic": "true"}, snsClient.topics[0].Attributes)
+		require.Len(t, snsClient.published, 2)
+		in := snsClient.published[0]
+		assert.Equal(t, "arn:aws:sns:eu-west-1:123456789012:prod-orders-placed.fifo", *in.TopicArn)
+		assert.Equal(t, `{"order_id":"order-1"}`, *in.Message)
+		assert.Equal(t, "order-1", *in.MessageGroupId)
+		assert.Equal(t, *in.MessageDeduplicationId, *snsClient.published[1].MessageDeduplicationId,
+			"identical messages should be deduplicated")
+		assert.Equal(t, "order-1", *in.MessageAttributes[KeyAttribute].StringValue)
+		assert.Equal(t, "corr-1", *in.MessageAttributes[logging.CorrelationIDKafkaHeader].StringValue)
+		assert.Contains(t, in.MessageAttributes, "traceparent")
+	})
+
+	t.Run("standard topics have no message group", func(t *testing.T) {
+		c, snsClient, _ := testClient(false)
+		p, err := c.Publisher(context.Background(), "orders.placed")
+		require.NoError(t, err)
+
+		require.NoError(t, p.PublishMessage(context.Background(), []byte("order-1"), []byte("{}")))
+		assert.Nil(t, snsClient.published[0].MessageGroupId)
+		assert.Nil(t, snsClient.published[0].MessageDeduplicationId)
+	})
+
+	t.Run("publish failure", func(t *testing.T) {
+		c, snsClient, _ := testClient(true)
+		snsClient.err = errors.New("throttled")
+		p, err := c.Publisher(context.Background(), "orders.placed")
+		require.NoError(t, err)
+
+		err = p.PublishMessage(context.Background(), []byte("order-1"), []byte("{}"))
+		assert.ErrorIs(t, err, snsClient.err)
+		assert.ErrorContains(t, err, "failed to publish message to SNS")
+	})
+}
+
+func TestClient_Subscriber(t *testing.T) {
+	c, snsClient, sqsClient := testClient(true)
+
+	s, err := c.Subscriber(context.Background(), "orders.placed", "inventory-service-group")
+	require.NoError(t, err)
+
+	queue := "prod-inventory-service-group-orders-placed.fifo"
+	dlq := "prod-inventory-service-group-orders-placed-dlq.fifo"
+	assert.Equal(t, queue, s.queue.url)
+	assert.Equal(t, dlq, s.dlq.url)
+	assert.Equal(t, "true", sqsClient.attributes[dlq]["FifoQueue"])
+
+	attrs := sqsClient.attributes[queue]
+	assert.Equal(t, "true", attrs["FifoQueue"])
+	assert.Equal(t, "30", attrs["VisibilityTimeout"])
+	assert.Equal(t, "20", attrs["ReceiveMessageWaitTimeSeconds"])
+	assert.JSONEq(t, `{"deadLetterTargetArn":"arn:aws:sqs:eu-west-1:123456789012:`+dlq+`","maxReceiveCount":5}`, attrs["RedrivePolicy"])
+	var policy struct {
+		Statement []struct {
+			Resource  string
+			Condition map[string]map[string]string
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(attrs["Policy"]), &policy))
+	require.Len(t, policy.Statement, 1)
+	assert.Equal(t, "arn:aws:sqs:eu-west-1:123456789012:"+queue, policy.Statement[0].Resource)
+	assert.Equal(t, "arn:aws:sns:eu-west-1:123456789012:prod-orders-placed.fifo", policy.Statement[0].Condition["ArnEquals"]["aws:SourceArn"])
+
+	require.Len(t, snsClient.subscribed, 1)
+	sub := snsClient.subscribed[0]
+	assert.Equal(t, "sqs", *sub.Protocol)
+	assert.Equal(t, "arn:aws:sqs:eu-west-1:123456789012:"+queue, *sub.Endpoint)
+	assert.Equal(t, "true", sub.Attributes["RawMessageDelivery"])
+}
+
+func TestSubscriber_Process(t *testing.T) {
+	message := func() sqstypes.Message {
+		return sqstypes.Message{
+			MessageId:     aws.String("msg-1"),
+			ReceiptHandle: aws.String("receipt-1"),
+			Body:          aws.String(`{"order_id":"order-1"}`),
+			Attributes:    map[string]string{"MessageGroupId": "order-1"},
+			MessageAttributes: map[string]sqstypes.MessageAttributeValue{
+				KeyAttribute: {DataType: aws.String("String"), StringValue: aws.String("order-1")},
+			},
+		}
+	}
+
+	t.Run("handled messages are deleted", func(t *testing.T) {
+		c, _, sqsClient := testClient(true)
+		s, err := c.Subscriber(context.Background(), "orders.placed", "inventory-service-group")
+		require.NoError(t, err)
+
+		var gotKey, gotValue string
+		s.process(context.Background(), message(), func(_ context.Context, key, value []byte) error {
+			gotKey, gotValue = string(key), string(value)
+			return nil
+		})
+
+		assert.Equal(t, "order-1", gotKey)
+		assert.Equal(t, `{"order_id":"order-1"}`, gotValue)
+		assert.Equal(t, []string{"receipt-1"}, sqsClient.deleted)
+		assert.Empty(t, sqsClient.sent)
+	})
+
+	t.Run("failed messages are moved to the dead-letter queue", func(t *testing.T) {
+		c, _, sqsClient := testClient(true)
+		s, err := c.Subscriber(context.Background(), "orders.placed", "inventory-service-group")
+		require.NoError(t, err)
+
+		s.process(context.Background(), message(), func(context.Context, []byte, []byte) error {
+			return errors.New("insufficient stock")
+		})
+
+		require.Len(t, sqsClient.sent, 1)
+		sent := sqsClient.sent[0]
+		assert.Equal(t, s.dlq.url, *sent.QueueUrl)
+		assert.Equal(t, `{"order_id":"order-1"}`, *sent.MessageBody)
+		assert.Equal(t, "order-1", *sent.MessageGroupId)
+		assert.Equal(t, "msg-1", *sent.MessageDeduplicationId)
+		assert.Equal(t, "order-1", *sent.MessageAttributes[KeyAttribute].StringValue)
+		assert.Equal(t, "insufficient stock", *sent.MessageAttributes[events.HeaderError].StringValue)
+		assert.Equal(t, "orders.placed", *sent.MessageAttributes[events.HeaderOriginalTopic].StringValue)
+		assert.Equal(t, []string{"receipt-1"}, sqsClient.deleted)
+	})
+
+	t.Run("messages that can't be dead-lettered stay in the queue", func(t *testing.T) {
+		c, _, sqsClient := testClient(true)
+		sqsClient.sendErr = errors.New("access denied")
+		s, err := c.Subscriber(context.Background(), "orders.placed", "inventory-service-group")
+		require.NoError(t, err)
+
+		s.process(context.Background(), message(), func(context.Context, []byte, []byte) error {
+			return errors.New("insufficient stock")
+		})
+
+		assert.Empty(t, sqsClient.deleted)
+	})
+}
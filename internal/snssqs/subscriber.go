@@ -0,0 +1,170 @@
+package snssqs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/broker"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/events"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/logging"
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Subscriber consumes the SQS queue of one consumer group for one topic.
+type Subscriber struct {
+	sqs   sqsAPI
+	cfg   Config
+	topic string
+	group string
+	queue queue
+	dlq   queue
+}
+
+var _ broker.EventSubscriber = (*Subscriber)(nil)
+
+// Subscriber returns a subscriber to topic for the consumer group groupID,
+// creating its queues and subscribing them to the SNS topic if needed. The
+// queue only receives the messages published after it was first subscribed.
+func (c *Client) Subscriber(ctx context.Context, topic, groupID string) (*Subscriber, error) {
+	main, dlq, err := c.subscriptionQueues(ctx, topic, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return &Subscriber{sqs: c.sqs, cfg: c.cfg, topic: topic, group: groupID, queue: main, dlq: dlq}, nil
+}
+
+// Consume receives messages and passes them to handler until ctx is
+// cancelled. A message is deleted once handled, or moved to the dead-letter
+// queue if handler fails. Cancelling ctx stops receiving but lets the messages
+// already received finish and be deleted, so Consume returns only once that
+// work is done.
+func (s *Subscriber) Consume(ctx context.Context, handler broker.MessageHandler) {
+	processCtx := context.WithoutCancel(ctx)
+	log.Info().Str("topic", s.topic).Str("queue", s.queue.url).Msg("Starting SQS consumer")
+	for {
+		out, err := s.sqs.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(s.queue.url),
+			MaxNumberOfMessages:         int32(s.cfg.MaxMessages),
+			WaitTimeSeconds:             int32(s.cfg.WaitTime / time.Second),
+			MessageAttributeNames:       []string{"All"},
+			MessageSystemAttributeNames: []sqstypes.MessageSystemAttributeName{sqstypes.MessageSystemAttributeNameMessageGroupId},
+		})
+		if ctx.Err() != nil {
+			log.Info().Str("topic", s.topic).Msg("SQS consumer context cancelled. Shutting down.")
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Str("topic", s.topic).Msg("Error receiving messages")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		// Messages are handled one at a time, in the order received, so
+		// a FIFO queue's ordering within a message group is preserved.
+		for _, msg := range out.Messages {
+			s.process(processCtx, msg, handler)
+		}
+	}
+}
+
+// process handles a single message and deletes it from the queue.
+func (s *Subscriber) process(ctx context.Context, msg sqstypes.Message, handler broker.MessageHandler) {
+	headers := stringAttributes(msg.MessageAttributes)
+	key := headers[KeyAttribute]
+	msgCtx, span := tracing.StartProcessSpan(s.messageContext(ctx, msg, headers), tracerName, "aws_sqs", s.topic,
+		propagation.MapCarrier(headers),
+		attribute.String("messaging.message.id", aws.ToString(msg.MessageId)),
+		attribute.String("messaging.consumer.group.name", s.group))
+	defer span.End()
+
+	if err := handler(msgCtx, []byte(key), []byte(aws.ToString(msg.Body))); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "handler failed")
+		log.Ctx(msgCtx).Error().Err(err).Msg("Failed to handle message, dead-lettering it")
+		if err := s.deadLetter(msgCtx, msg, err); err != nil {
+			// Left in the queue, the message is received again once its
+			// visibility timeout expires, and SQS dead-letters it after
+			// MaxReceiveCount attempts.
+			log.Ctx(msgCtx).Error().Err(err).Msg("Error dead-lettering message")
+			return
+		}
+	}
+	if _, err := s.sqs.DeleteMessage(msgCtx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(s.queue.url),
+		ReceiptHandle: msg.ReceiptHandle,
+	}); err != nil {
+		log.Ctx(msgCtx).Error().Err(err).Msg("Error deleting message")
+	}
+}
+
+// deadLetter copies msg to the dead-letter queue with the attributes
+// describing the failure that Kafka's dead-letter topics carry as headers.
+func (s *Subscriber) deadLetter(ctx context.Context, msg sqstypes.Message, cause error) error {
+	attrs := make(map[string]sqstypes.MessageAttributeValue, len(msg.MessageAttributes)+3)
+	for name, value := range msg.MessageAttributes {
+		attrs[name] = value
+	}
+	for name, value := range map[string]string{
+		events.HeaderOriginalTopic: s.topic,
+		events.HeaderError:         cause.Error(),
+		events.HeaderFailedAt:      time.Now().UTC().Format(time.RFC3339),
+	} {
+		attrs[name] = sqstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+	}
+	in := &sqs.SendMessageInput{
+		QueueUrl:          aws.String(s.dlq.url),
+		MessageBody:       msg.Body,
+		MessageAttributes: attrs,
+	}
+	if s.cfg.FIFO {
+		in.MessageGroupId = aws.String(msg.Attributes[string(sqstypes.MessageSystemAttributeNameMessageGroupId)])
+		in.MessageDeduplicationId = msg.MessageId
+	}
+	if _, err := s.sqs.SendMessage(ctx, in); err != nil {
+		return fmt.Errorf("failed to send message to SQS queue %s: %w", s.dlq.url, err)
+	}
+	return nil
+}
+
+// messageContext returns a context whose logger carries the message's
+// correlation ID (generating one if the publisher didn't set it), topic,
+// message ID and key.
+func (s *Subscriber) messageContext(ctx context.Context, msg sqstypes.Message, headers map[string]string) context.Context {
+	id := headers[logging.CorrelationIDKafkaHeader]
+	if id == "" {
+		id = logging.NewCorrelationID()
+	}
+	return logging.WithCorrelationID(ctx, id, map[string]any{
+		"topic":      s.topic,
+		"message_id": aws.ToString(msg.MessageId),
+		"order_id":   headers[KeyAttribute],
+	})
+}
+
+// Close does nothing: Consume stops when its context is cancelled and the
+// AWS clients belong to the Client.
+func (s *Subscriber) Close() error {
+	return nil
+}
+
+// stringAttributes returns the string message attributes of a message.
+func stringAttributes(attrs map[string]sqstypes.MessageAttributeValue) map[string]string {
+	headers := make(map[string]string, len(attrs))
+	for name, value := range attrs {
+		if value.StringValue != nil {
+			headers[name] = *value.StringValue
+		}
+	}
+	return headers
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderCarrier adapts Kafka message headers to propagation.TextMapCarrier.
+type HeaderCarrier struct {
+	Headers *[]kafka.Header
+}
+
+var _ propagation.TextMapCarrier = HeaderCarrier{}
+
+// Get returns the value of the first header with the given key.
+func (c HeaderCarrier) Get(key string) string {
+	for _, h := range *c.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces any header with the given key.
+func (c HeaderCarrier) Set(key, value string) {
+	headers := (*c.Headers)[:0]
+	for _, h := range *c.Headers {
+		if h.Key != key {
+			headers = append(headers, h)
+		}
+	}
+	*c.Headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+// Keys lists the header keys.
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, len(*c.Headers))
+	for i, h := range *c.Headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// StartProducerSpan starts a span for publishing msg to topic and injects its
+// context into the message headers.
+func StartProducerSpan(ctx context.Context, tracerName, topic string, msg *kafka.Message) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "publish "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", topic),
+			attribute.String("messaging.kafka.message.key", string(msg.Key)),
+		),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier{Headers: &msg.Headers})
+	return ctx, span
+}
+
+// StartConsumerSpan extracts the trace context from msg and starts a span for
+// processing it.
+func StartConsumerSpan(ctx context.Context, tracerName string, msg kafka.Message) (context.Context, trace.Span) {
+	headers := msg.Headers
+	ctx = otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier{Headers: &headers})
+	return otel.Tracer(tracerName).Start(ctx, "process "+msg.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", msg.Topic),
+			attribute.Int("messaging.kafka.destination.partition", msg.Partition),
+			attribute.Int64("messaging.kafka.message.offset", msg.Offset),
+			attribute.String("messaging.kafka.message.key", string(msg.Key)),
+		),
+	)
+}
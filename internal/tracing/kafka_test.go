@@ -0,0 +1,33 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jonamarkin/e-commerce-order-processing/internal/tracing"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestKafkaTracePropagation(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	msg := kafka.Message{Key: []byte("order-1")}
+	ctx, producerSpan := tracing.StartProducerSpan(context.Background(), "test", "orders.placed", &msg)
+	producerSpan.End()
+
+	assert.NotEmpty(t, tracing.HeaderCarrier{Headers: &msg.Headers}.Get("traceparent"))
+
+	_, consumerSpan := tracing.StartConsumerSpan(context.Background(), "test", msg)
+	defer consumerSpan.End()
+
+	assert.Equal(t,
+		trace.SpanContextFromContext(ctx).TraceID(),
+		consumerSpan.SpanContext().TraceID(),
+		"consumer span should continue the producer's trace")
+}
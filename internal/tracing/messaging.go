@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartPublishSpan starts a span for publishing a message to topic over the
+// messaging system named by system, such as "nats", and injects its context
+// into carrier, the message headers. StartProducerSpan is its Kafka
+// counterpart.
+func StartPublishSpan(ctx context.Context, tracerName, system, topic string, carrier propagation.TextMapCarrier, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "publish "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", system),
+			attribute.String("messaging.destination.name", topic),
+		),
+		trace.WithAttributes(attrs...),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return ctx, span
+}
+
+// StartProcessSpan extracts the trace context from carrier, the headers of a
+// message received from topic, and starts a span for processing it.
+// StartConsumerSpan is its Kafka counterpart.
+func StartProcessSpan(ctx context.Context, tracerName, system, topic string, carrier propagation.TextMapCarrier, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+	return otel.Tracer(tracerName).Start(ctx, "process "+topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", system),
+			attribute.String("messaging.destination.name", topic),
+		),
+		trace.WithAttributes(attrs...),
+	)
+}
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_customers_updated_at ON customers;
+DROP TABLE IF EXISTS customers;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_order_summaries_customer_created_at;
+DROP INDEX IF EXISTS idx_order_summaries_status_created_at;
+DROP INDEX IF EXISTS idx_order_summaries_created_at;
+DROP TABLE IF EXISTS order_summaries;
@@ -0,0 +1,27 @@
+-- Denormalized read model: one row per order with its item totals, kept in
+-- step with orders and order_items by the repository in the same transaction.
+-- Listing and statistics read it instead of scanning and joining the
+-- normalized tables.
+CREATE TABLE IF NOT EXISTS order_summaries (
+    order_id UUID PRIMARY KEY REFERENCES orders(id) ON DELETE CASCADE,
+    customer_id UUID NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    item_count INT NOT NULL,
+    total_quantity INT NOT NULL,
+    total_price NUMERIC(10, 2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_summaries_created_at ON order_summaries(created_at DESC, order_id);
+CREATE INDEX IF NOT EXISTS idx_order_summaries_status_created_at ON order_summaries(status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_order_summaries_customer_created_at ON order_summaries(customer_id, created_at DESC);
+
+-- Summarize the orders placed before this migration.
+INSERT INTO order_summaries (order_id, customer_id, status, item_count, total_quantity, total_price, created_at, updated_at)
+SELECT o.id, o.customer_id, o.status, COUNT(i.id), COALESCE(SUM(i.quantity), 0), o.total_price,
+    COALESCE(o.created_at, NOW()), COALESCE(o.updated_at, NOW())
+FROM orders o
+LEFT JOIN order_items i ON i.order_id = o.id
+GROUP BY o.id
+ON CONFLICT (order_id) DO NOTHING;
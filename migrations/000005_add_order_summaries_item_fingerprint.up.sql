@@ -0,0 +1,4 @@
+-- Fingerprint of each order's item set, used to detect an order repeated by
+-- the same customer shortly after the first (double clicks, client retries).
+-- Orders placed before this migration have none and are never matched.
+ALTER TABLE order_summaries ADD COLUMN IF NOT EXISTS item_fingerprint CHAR(64);
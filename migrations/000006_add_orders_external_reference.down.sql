@@ -0,0 +1,9 @@
+ALTER TABLE order_summaries
+    DROP COLUMN IF EXISTS external_reference,
+    DROP COLUMN IF EXISTS external_source;
+
+ALTER TABLE orders
+    DROP CONSTRAINT IF EXISTS orders_external_reference_key,
+    DROP CONSTRAINT IF EXISTS orders_external_reference_complete,
+    DROP COLUMN IF EXISTS external_reference,
+    DROP COLUMN IF EXISTS external_source;
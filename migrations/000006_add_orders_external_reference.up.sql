@@ -0,0 +1,16 @@
+-- Optional reference of an order in the system it was imported from, such as
+-- a marketplace or ERP order number. A reference may only be used once per
+-- source system, which makes imports idempotent.
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS external_source VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS external_reference VARCHAR(255);
+
+ALTER TABLE orders
+    ADD CONSTRAINT orders_external_reference_complete
+        CHECK ((external_source IS NULL) = (external_reference IS NULL)),
+    ADD CONSTRAINT orders_external_reference_key
+        UNIQUE (external_source, external_reference);
+
+ALTER TABLE order_summaries
+    ADD COLUMN IF NOT EXISTS external_source VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS external_reference VARCHAR(255);
@@ -0,0 +1,37 @@
+-- updated_at is set by the service to the time of each change, in UTC, and
+-- the same value is copied to order_summaries. The trigger now only stamps
+-- updates that leave updated_at alone, such as manual fixes, so it no longer
+-- overwrites the service's timestamp with the transaction's start time.
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.updated_at IS NOT DISTINCT FROM OLD.updated_at THEN
+        NEW.updated_at = NOW();
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER update_order_summaries_updated_at
+BEFORE UPDATE ON order_summaries
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Every order has both timestamps.
+UPDATE orders
+SET created_at = COALESCE(created_at, updated_at, NOW()),
+    updated_at = COALESCE(updated_at, created_at, NOW())
+WHERE created_at IS NULL OR updated_at IS NULL;
+
+UPDATE order_items
+SET created_at = COALESCE(created_at, updated_at, NOW()),
+    updated_at = COALESCE(updated_at, created_at, NOW())
+WHERE created_at IS NULL OR updated_at IS NULL;
+
+ALTER TABLE orders
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET NOT NULL;
+
+ALTER TABLE order_items
+    ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET NOT NULL;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_order_summaries_created_at_asc;
@@ -0,0 +1,4 @@
+-- Exports read order_summaries oldest first in batches, each resuming after
+-- the (created_at, order_id) of the previous batch; this index serves both
+-- the order and the resume condition.
+CREATE INDEX IF NOT EXISTS idx_order_summaries_created_at_asc ON order_summaries(created_at, order_id);
@@ -0,0 +1,24 @@
+-- Event-sourced orders: each order's changes are appended to its stream in
+-- order_events, numbered from 1. The repository rebuilds orders from their
+-- latest snapshot and the events after it, and keeps orders, order_items and
+-- order_summaries up to date in the same transaction for queries.
+CREATE TABLE IF NOT EXISTS order_events (
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    data JSONB NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    recorded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    -- Two writers appending the same version conflict here, and the later
+    -- one is retried against the new state of the stream.
+    PRIMARY KEY (order_id, version)
+);
+
+-- The state of an order at some version, so rebuilding it only replays the
+-- events after that version.
+CREATE TABLE IF NOT EXISTS order_snapshots (
+    order_id UUID PRIMARY KEY REFERENCES orders(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    state JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
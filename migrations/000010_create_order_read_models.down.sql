@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS projection_checkpoints;
+DROP TABLE IF EXISTS order_status_dashboard;
+DROP TABLE IF EXISTS order_list_view;
+DROP INDEX IF EXISTS idx_order_events_commit_order;
+ALTER TABLE order_events
+    DROP COLUMN IF EXISTS position,
+    DROP COLUMN IF EXISTS transaction_id;
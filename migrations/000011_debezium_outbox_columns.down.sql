@@ -0,0 +1,3 @@
+ALTER TABLE outbox DROP COLUMN IF EXISTS type;
+ALTER TABLE outbox RENAME COLUMN aggregateid TO message_key;
+ALTER TABLE outbox RENAME COLUMN aggregatetype TO topic;
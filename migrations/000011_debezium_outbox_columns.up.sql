@@ -0,0 +1,9 @@
+-- Shape the outbox for Debezium's outbox event router, so a Kafka Connect
+-- Postgres connector can publish it instead of the relay: aggregatetype holds
+-- the destination topic (route.topic.replacement=${routedByValue}),
+-- aggregateid the message key, type the event type and payload the event.
+ALTER TABLE outbox RENAME COLUMN topic TO aggregatetype;
+ALTER TABLE outbox RENAME COLUMN message_key TO aggregateid;
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS type VARCHAR(255) NOT NULL DEFAULT '';
+
+UPDATE outbox SET type = 'OrderPlaced' WHERE aggregatetype = 'orders.placed' AND type = '';
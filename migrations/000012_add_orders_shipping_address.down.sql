@@ -0,0 +1,8 @@
+ALTER TABLE order_list_view
+    DROP COLUMN IF EXISTS shipping_address;
+
+ALTER TABLE order_summaries
+    DROP COLUMN IF EXISTS shipping_address;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS shipping_address;
@@ -0,0 +1,10 @@
+-- Optional shipping address of an order, as normalized at creation, with
+-- the fields of domain.Address. Orders placed without one have NULL.
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS shipping_address JSONB;
+
+ALTER TABLE order_summaries
+    ADD COLUMN IF NOT EXISTS shipping_address JSONB;
+
+ALTER TABLE order_list_view
+    ADD COLUMN IF NOT EXISTS shipping_address JSONB;
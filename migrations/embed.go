@@ -0,0 +1,10 @@
+// Package migrations embeds the order service's SQL migrations so binaries can
+// apply them without the files being present on disk.
+package migrations
+
+import "embed"
+
+// FS holds the golang-migrate up/down SQL files.
+//
+//go:embed *.sql
+var FS embed.FS